@@ -27,183 +27,2851 @@ export interface MockOptions {
    * True value indicaes that given mock definition should be ignored.
    */
   skip: boolean
+
+  /**
+   * Deferred start: the mock definition is only registered, the server is started by the first request
+   * to the mock target (or explicitly by `mock.start`). Route definitions can live in importable modules
+   * without each VU spawning a server during init. Deferred definitions are also registered in the
+   * init context of the `setup` function, so the mock may be used in `setup` as well.
+   *
+   * ```js
+   * mock("https://example.com", callback, { deferred: true })
+   * ```
+   */
+  deferred: boolean
+
+  /**
+   * Lazy start: the mock server's address is reserved immediately (requests to the mock target are
+   * already redirected to it), but the mock definition callback runs and the application starts only
+   * when the first request arrives. Reduces resource use in scripts with many conditionally-used mocks.
+   */
+  lazy: boolean
+
+  /**
+   * Not supported, `mock` throws if set. A mock server's handlers run on the event loop of the VU defining
+   * it, and k6 runs a VU's event loop only while the VU is inside an iteration: a server shared by every
+   * VU would hang the requests of the other VUs whenever its VU is between iterations, idle in an
+   * arrival-rate executor or done with its scenario. Every VU defines its own mock server.
+   */
+  shared: boolean
+
+  /**
+   * Name of the mock server in the service registry, its URL can be queried by `mock.service(name)`.
+   */
+  name: string
+
+  /**
+   * Export the mock server's URL into `__ENV`, so existing scripts reading base URLs from environment
+   * variables work against the mock unchanged. The default variable name is `MOCK_<NAME>_URL`, where `NAME`
+   * is the `name` of the mock (or the host name of the target), e.g. `MOCK_PAYMENTS_URL`. Variable name(s)
+   * can be given explicitly as well. Deferred mocks export the mock target (requests to it start the server).
+   *
+   * ```js
+   * mock("https://payments.example.com", callback, { env: "PAYMENTS_BASE_URL" })
+   *
+   * export default function () {
+   *   http.get(`${__ENV.PAYMENTS_BASE_URL}/balance`)
+   * }
+   * ```
+   */
+  env: boolean | string | string[]
+
+  /**
+   * Additional stub file(s) served by the mock server (see `mock.load`).
+   */
+  stubs: string | string[]
+
+  /**
+   * Per-scenario configuration: mock options (e.g. fault profiles, stub sets) bound to scenarios,
+   * keyed by scenario name or scenario exec function name. The options are merged with the options of
+   * the mock definition and applied automatically based on `exec.scenario.name`, so one script can model
+   * multiple backend conditions concurrently. A separate mock server is started for each profile,
+   * on the first request of the scenario.
+   *
+   * ```js
+   * export const options = {
+   *   scenarios: {
+   *     happy: { executor: "constant-vus", vus: 5, duration: "1m", exec: "browse" },
+   *     degraded: { executor: "constant-vus", vus: 5, duration: "1m", exec: "browse" },
+   *   },
+   * }
+   *
+   * mock("https://api.example.com", callback, {
+   *   scenarios: { degraded: { fuzz: 0.2, stubs: "slow.yaml" } },
+   * })
+   * ```
+   */
+  scenarios: Record<string, Partial<MockOptions>>
+
+  /**
+   * Strict mode: requests without matching route are logged as errors. Unmatched requests are
+   * always counted in the `mock_unmatched_reqs` metric, so a threshold can fail the test:
+   *
+   * ```js
+   * export const options = { thresholds: { mock_unmatched_reqs: ['count==0'] } }
+   * ```
+   *
+   * Passing `"abort"` instead of `true` also aborts the test on the first unmatched request.
+   */
+  strict: boolean | "abort"
+
+  /**
+   * Response fuzzing: responses of routes are mutated at the given rate (0..1), to test client
+   * robustness against misbehaving upstreams.
+   *
+   * ```js
+   * mock("https://example.com", callback, { fuzz: { rate: 0.1, mutations: ["drop", "status"] } })
+   * ```
+   */
+  fuzz: number | FuzzOptions
+
+  /**
+   * Clock skew simulation: systematic offset (in milliseconds) applied to all timestamps emitted by the mock,
+   * to test client tolerance of upstream clock skew. Passing an object also allows a drift, which is
+   * a skew growing with the elapsed time (e.g. `0.01` means 36 seconds per hour).
+   *
+   * The skew is applied to the `Date` header, to `app.clock.httpDate()` and to the response body:
+   * RFC 3339 timestamps and `iat`, `exp`, `nbf` claims of JWTs are shifted. Signatures of modified
+   * JWTs are not recomputed. `app.clock.now()` and `app.clock.date()` return the time without skew,
+   * timestamps built from them are shifted once, in the response body.
+   *
+   * ```js
+   * mock("https://example.com", callback, { skew: { offset: -5 * 60 * 1000, drift: 0.01 } })
+   * ```
+   */
+  skew: number | { offset?: number, drift?: number }
+
+  /**
+   * Caching proxy emulation in front of the routes (and stubs) of the mock.
+   *
+   * `GET` and `HEAD` responses are stored according to their `Cache-Control` (`s-maxage`, `max-age`,
+   * `no-store`, `no-cache`, `private`), `Expires` and `Vary` headers. Fresh responses are served from the
+   * cache with `Age` header. The `X-Cache` response header reports `HIT`, `MISS` or `BYPASS` (request
+   * with `Cache-Control: no-cache`). Age is computed using the virtual clock (`app.clock`). Responses are
+   * keyed by the request method, URL and the request headers listed in `Vary`, fuzzed responses and
+   * injected faults are not stored.
+   *
+   * `stale-while-revalidate` and `stale-if-error` extensions are honored as well: within these windows stale
+   * responses are served (reported as `STALE`), while the response is refreshed in the background or the
+   * route responds with server error.
+   *
+   * Stored responses can be purged by sending `PURGE` request to a path or by calling `app.cache.purge()`.
+   */
+  cache: boolean
+
+  /**
+   * CDN edge simulation, implies `cache`.
+   *
+   * In addition to the caching proxy emulation, the `Surrogate-Control` response header takes precedence over
+   * `Cache-Control` and responses can be tagged with space separated surrogate keys in `Surrogate-Key` header.
+   * Both headers are removed from responses sent to the client. Tagged responses can be purged by sending
+   * `PURGE` request with `Surrogate-Key` header or by calling `app.cache.purgeKey()`.
+   *
+   * Requests are served by simulated points of presence (POP). The POP is selected by request header
+   * (`X-Pop` by default) or randomly, and it is reported in `X-Served-By` response header. POP latency is
+   * given in milliseconds, either as a number or as a `[min, max]` range:
+   *
+   * ```js
+   * mock("https://cdn.example.com", callback, { cdn: { pops: { fra: 20, iad: [80, 120] } } })
+   * ```
+   */
+  cdn: boolean | { pops?: Record<string, number | [number, number]>, header?: string }
+
+  /**
+   * Simulated regions of the mocked service, to test geo-routing and failover logic in clients.
+   *
+   * Region definition is either a latency in milliseconds (number or `[min, max]` range) or an object with
+   * `latency`, `target` (region specific URL, e.g. `https://eu.api.example.com`) and `status` (forced response
+   * status of a failing region) properties.
+   *
+   * The region of a request is selected by its region specific target URL, by request header (see `region` option)
+   * or by path prefix (if enabled by `region` option). The selected region is available in route handlers as
+   * `req.region` and it is reported in `X-Served-Region` response header.
+   *
+   * ```js
+   * mock("https://api.example.com", app => {
+   *   app.get('/items', (req, res) => res.json({ region: req.region }))
+   * }, {
+   *   regions: {
+   *     eu: { latency: 20, target: "https://eu.api.example.com" },
+   *     us: { latency: [80, 120], target: "https://us.api.example.com" }
+   *   }
+   * })
+   * ```
+   */
+  regions: Record<string, number | [number, number] | { latency?: number | [number, number], target?: string, status?: number }>
+
+  /**
+   * Region selector: request header name (default `X-Region`) and path prefix selection
+   * (the first path segment is the region name, it is removed before routing).
+   */
+  region: { header?: string, prefix?: boolean }
+
+  /**
+   * Multi-tenant isolation: name of the request header (e.g. `X-Tenant-Id`) selecting an isolated
+   * state namespace (`req.state`). Requests without the header share the default namespace.
+   * Stubs and expectations can be restricted to a tenant too (`withTenant`), so concurrent test jobs
+   * stub and verify the same routes independently.
+   *
+   * ```js
+   * mock("https://api.example.com", app => {
+   *   app.post('/orders', (req, res) => res.json(req.state.resource('orders').create(req.body)))
+   *   app.get('/orders', (req, res) => res.json(req.state.resource('orders').list()))
+   * }, { tenancy: "X-Tenant-Id" })
+   * ```
+   */
+  tenancy: string
+
+  /**
+   * Response URL rewriting: absolute URLs inside JSON and HTML bodies (hypermedia links, next-page URLs)
+   * and in the `Location`, `Content-Location` and `Link` headers are rewritten, so link-following
+   * clients stay inside the mocked world.
+   *
+   * By default (`true` or `"mock"`) URLs of the mock target (and of the additional `origins`) point to
+   * the mock server's address. With `"origin"` the mock server's URLs are rewritten back to the mock target.
+   *
+   * ```js
+   * mock("https://api.example.com", callback, { rewriteURLs: { origins: ["https://cdn.example.com"] } })
+   * ```
+   */
+  rewriteURLs: boolean | "mock" | "origin" | { to?: "mock" | "origin", origins?: string[] }
+
+  /**
+   * Cookie rewriting: the `Set-Cookie` headers of the responses are rewritten, so cookies issued
+   * for the original domain remain usable against the mock server's address (keeping session flows intact).
+   *
+   * The `Domain` attribute is removed (or replaced by `domain`), the path of the mock target is removed
+   * from the `Path` attribute and the `Secure` attribute is dropped unless `secure` is true.
+   *
+   * ```js
+   * mock("https://example.com/api", callback, { rewriteCookies: true })
+   * ```
+   */
+  rewriteCookies: boolean | { domain?: string, secure?: boolean }
+
+  /**
+   * HTML fixture rewriting: absolute and protocol-relative URLs of the mock target (and of the additional
+   * `origins` of the recorded site) inside HTML and CSS stub responses are rewritten to root-relative URLs,
+   * so a browser-module test can navigate an entire recorded site offline.
+   *
+   * ```js
+   * mock.load("site.yaml", { rewriteHTML: { origins: ["https://static.example.com"] } })
+   * ```
+   */
+  rewriteHTML: boolean | { origins?: string[] }
+
+  /**
+   * Answer gRPC-Web and Connect requests of RPC methods without route by echoing the request message,
+   * so a client can connect successfully before specific handlers are written.
+   *
+   * The `grpc.health.v1.Health/Check` method reports `SERVING` status unless routed, regardless of this option.
+   */
+  rpcEcho: boolean
+
+  /**
+   * Protocol buffers schema (`.proto` file name or array of file names) of the native gRPC methods.
+   *
+   * Request messages of the methods defined by the schema are decoded to JSON (fields by JSON name, enums
+   * by name, 64-bit integers as strings and bytes as base64), client streaming methods receive the array of
+   * the messages. JSON responses are encoded by the schema, server streaming methods may respond with an
+   * array of messages. Imports are resolved relative to the importing file (and to the working directory).
+   * The well-known types (`google/protobuf/*.proto`) are bundled and use their JSON mapping: e.g. timestamps
+   * as RFC 3339 strings, durations as `"1.5s"`, wrappers as plain values and `Struct` as JSON object.
+   *
+   * ```js
+   * mock("https://orders.example.com", app => {
+   *   app.post("/orders.v1.OrderService/GetOrder", (req, res) => {
+   *     res.json({ orderId: req.body.orderId, status: "STATUS_OPEN" })
+   *   })
+   * }, { proto: "orders.proto" })
+   * ```
+   */
+  proto: string | string[]
+
+  /**
+   * Retry storm detection: identical requests (same method, URL and body) of a client after error
+   * responses (5xx and 429) are counted as retries. A retry storm is flagged when the number of retries
+   * within the `window` (in milliseconds, default 1000) reaches the `threshold` (default 5).
+   *
+   * Clients are identified by request header (`X-Client-Id` by default), or by remote address without
+   * the header. Retries are reported by the `mock_retries`, `mock_retry_interval` and `mock_retry_storms`
+   * metrics, detected storms are logged (with the client) and available by `app.retryStorms()`. The request
+   * and retry counts of the clients are available by `app.retryClients()`.
+   *
+   * ```js
+   * export const options = { thresholds: { mock_retry_storms: ['count==0'] } }
+   *
+   * mock("https://api.example.com", callback, { fuzz: { rate: 0.5, mutations: ["status"] }, retryStorm: true })
+   * ```
+   */
+  retryStorm: boolean | { header?: string, window?: number, threshold?: number }
+
+  /**
+   * Serve the mock server over TLS with a self-signed certificate of the target's host (and the loopback
+   * addresses), the mock server's URL is an `https://` URL. The certificate is trusted by the `k6/http`
+   * client of the VU automatically when requests are rewritten to the mock server, other clients must skip
+   * certificate verification.
+   *
+   * TLS session resumption by session tickets is enabled by default, it can be disabled by `sessionTickets: false`
+   * to measure the cost of full handshakes. The number of full and resumed handshakes is available by
+   * `app.tls.stats()`. 0-RTT (early data) is not supported.
+   *
+   * An OCSP response with the given certificate status (`good`, `revoked` or `unknown`) is stapled to the
+   * certificate if `ocsp` is set, so revocation checking of clients can be tested. The status can be changed
+   * later by `app.tls.ocsp()`.
+   *
+   * ```js
+   * mock("https://api.example.com", callback, { tls: { sessionTickets: false, ocsp: "revoked" } })
+   * ```
+   */
+  tls: boolean | { sessionTickets?: boolean, ocsp?: "good" | "revoked" | "unknown" }
+
+  /**
+   * Readiness gating: every request is answered with 503 (and `Retry-After: 1` header) until `app.ready()`
+   * is called (`true`) or the warm-up delay passed (milliseconds or duration string), for testing client
+   * startup retry and health-check logic. The status and the `Retry-After` seconds can be changed.
+   *
+   * ```js
+   * mock("https://api.example.com", callback, { warmup: { delay: "5s", status: 503, retryAfter: 2 } })
+   * ```
+   */
+  warmup: boolean | number | string | { delay?: number | string, status?: number, retryAfter?: number }
+
+  /**
+   * Serve liveness (`/healthz`) and readiness (`/readyz`) endpoints, their state is set by the script
+   * (see `app.health`), so health polling of the system under test can be simulated. The endpoints respond
+   * in health check format (`application/health+json`): healthy is `pass` (200), degraded is `warn` (200)
+   * and failing is `fail` (503). The readiness endpoint is failing during warm-up (see `warmup`), the
+   * endpoints are not gated by it. The paths can be changed by `liveness` and `readiness` properties.
+   *
+   * ```js
+   * const app = mock("https://api.example.com", callback, { health: true })
+   *
+   * app.health.liveness("degraded", { db: "slow queries" })
+   * ```
+   */
+  health: boolean | { liveness?: string, readiness?: string }
+
+  /**
+   * Request correlation: the correlation ID of the request is taken from the `X-Request-Id` header
+   * (`true`) or the given header, a random ID is generated if the client didn't send one. The ID is
+   * passed to the route handlers in the same header, echoed in the response header and attached to
+   * the recorded request (`requestId` of hooks and dashboard events) and to the `mock_reqs` and
+   * `mock_req_duration` samples as `request_id` metadata, so client and mock logs can be cross-referenced.
+   *
+   * ```js
+   * mock("https://api.example.com", callback, { correlation: "X-Correlation-Id" })
+   * ```
+   */
+  correlation: boolean | string
+
+  /**
+   * Fault schedule, the chaos timeline of the test: while the elapsed time (measured from the start of the
+   * test run, like `exec.instance.currentTestRunDuration`, for every VU) is within the window of a fault,
+   * requests of its route are failed with the given probability. The window is given by `during` (`"2m-4m"`, the end is optional) or by `stage` (name or
+   * index of a stage of the `stages` option). Faults are checked before the routes, the first matching
+   * fault applies.
+   *
+   * ```js
+   * export const options = {
+   *   stages: [{ duration: "1m", target: 10, name: "ramp" }, { duration: "3m", target: 50, name: "peak" }],
+   * }
+   *
+   * mock("https://api.example.com", callback, {
+   *   stages: options.stages,
+   *   faults: [
+   *     { during: "2m-4m", route: "/pay", errorRate: 0.5 },
+   *     { stage: "peak", route: "/orders/:id", method: "GET", status: 504, delay: "2s", errorRate: 0.1 },
+   *   ],
+   * })
+   * ```
+   */
+  faults: Array<ScheduledFault>
+
+  /**
+   * Stages of the test (the k6 `stages` option, with optional `name` properties), the windows of
+   * the faults are resolved against them (see `faults`).
+   */
+  stages: Array<{ duration: number | string, name?: string }>
+
+  /**
+   * Client keying: clients are identified by a header (`X-Client-Id` by default), a cookie or the source
+   * port (the remote address if the identifier is missing) and assigned to cohorts by the hash of their
+   * identifier, so a fraction of the clients consistently experience different behavior throughout the test.
+   * The client and cohort are available as `req.client` and `req.cohort`, routes can be restricted to
+   * cohorts by the `cohort` route option.
+   *
+   * ```js
+   * const app = mock("https://api.example.com", callback, { clients: { cookie: "sid", cohorts: { degraded: 0.1 } } })
+   *
+   * app.get("/prices", (req, res) => res.status(503).send(""), { cohort: "degraded" })
+   * app.get("/prices", (req, res) => res.json({ book: 10 }))
+   * ```
+   */
+  clients: { header?: string, cookie?: string, port?: boolean, cohorts?: Record<string, number> }
+
+  /**
+   * Capture the raw traffic of the mock server's connections into a pcapng (or pcap) file, so mock sessions
+   * can be inspected by Wireshark. The option is the capture file name or an object with `file`, `format`
+   * (`pcapng` or `pcap`, by the file extension by default) and `keyLog` (key log file name) properties.
+   *
+   * The traffic is wrapped into synthetic TCP segments between the client and the server address. TLS traffic
+   * is captured encrypted: the session keys are embedded into pcapng files (decryption secrets block) and
+   * written into the key log file in NSS key log format (Wireshark's TLS "(Pre)-Master-Secret log filename").
+   * The mock servers of every VU capturing into the same file share it.
+   *
+   * ```js
+   * mock.https("https://api.example.com", app => { ... }, { capture: { file: "api.pcapng", keyLog: "keys.log" } })
+   * ```
+   */
+  capture: string | { file: string, format?: "pcapng" | "pcap", keyLog?: string }
+
+  /**
+   * Resource limits of the overload guard, the guard is disabled by default. The resource usage is sampled
+   * in the given interval (1s by default) and emitted as `mock_cpu`, `mock_heap`, `mock_goroutines` and
+   * `mock_inflight_reqs` metrics. Exceeding any of the limits logs a warning and counts in the
+   * `mock_overload` rate metric. `true` enables the guard with the default limits: the CPU utilization
+   * limit is 0.9, the others are unlimited. Every guarded mock server (in every VU) runs its own sampler,
+   * so the guard is best enabled on a few servers.
+   *
+   * ```js
+   * mock("https://api.example.com", app => { ... }, { overload: { cpu: 0.8, heap: "1GB", inflight: 500 } })
+   * ```
+   */
+  overload: boolean | { interval?: number | string, cpu?: number, heap?: number | string, goroutines?: number, inflight?: number }
+
+  /**
+   * Retention limits and body storage of the request journal (used by `mock.expect`, the HAR, stub
+   * and script exports). The journal keeps the last `limit` (10000 by default) requests, not older
+   * than `retention` (milliseconds or duration string, unlimited by default).
+   * The bodies of the requests and responses are stored according to the `bodies` policy:
+   *  - `memory`: kept in memory as is (default)
+   *  - `disk`: spilled to files in `dir` (temporary directory by default), removed with the evicted requests and when the mock server stops
+   *  - `truncate`: truncated to `maxBody` bytes (1kB by default)
+   *  - `hash`: replaced with their SHA-256 hash (`sha256:<hex>`)
+   *
+   * ```js
+   * mock("https://api.example.com", app => { ... }, { journal: { retention: "30m", bodies: "disk" } })
+   * ```
+   */
+  journal: {
+    limit?: number,
+    retention?: number | string,
+    bodies?: "memory" | "disk" | "truncate" | "hash",
+    maxBody?: number | string,
+    dir?: string
+  }
+
+  /**
+   * Samplers of the request recording and the access logging, so observability keeps up with high
+   * request rates. The `journal` sampler selects the requests recorded by the request journal (every
+   * request by default), expectations, `requests` and the exports see the recorded requests only.
+   * The `log` sampler enables the access log (one info level log line per sampled request).
+   *
+   * A sampler is
+   *  - a number: the fraction of the requests kept (0..1)
+   *  - `"errors"`: the failed requests (status 400 or above) are kept only
+   *  - an object combining `rate` (fraction), `errors` (keep the failed requests) and `first` (keep the
+   *    first N requests of each route), the rate is 0 if `errors` or `first` is given
+   *
+   * ```js
+   * mock("https://api.example.com", app => { ... }, {
+   *   sampling: { journal: { errors: true, first: 10, rate: 0.01 }, log: "errors" },
+   * })
+   * ```
+   */
+  sampling: { journal?: Sampler, log?: Sampler }
+
+  /**
+   * Defaults of all responses of the server. The headers (e.g. `Server` or security headers) are set on
+   * the responses not setting them, the delay applies to the routes without `delay` option. Route groups
+   * and route options take precedence, `delay: 0` disables the default delay of a route.
+   *
+   * ```js
+   * mock("https://api.example.com", app => { ... }, {
+   *   defaults: { headers: { "Server": "nginx", "X-Env": "staging" }, delay: "20ms" },
+   * })
+   * ```
+   */
+  defaults: { headers?: Record<string, string>, delay?: Delay }
+}
+
+/**
+ * Request sampler (see `MockOptions.sampling`).
+ */
+export type Sampler = number | "errors" | { rate?: number, errors?: boolean, first?: number };
+
+/**
+ * A fault of the fault schedule (see `MockOptions.faults`).
+ */
+export interface ScheduledFault {
+  /** Elapsed time window, e.g. `"2m-4m"` or `"30s-"` (until the end of the test). */
+  during?: string;
+  /** Name or index of the stage, the window of the fault is the stage's window. */
+  stage?: string | number;
+  /** Path pattern of the faulty route, every request if omitted. */
+  route?: string;
+  /** HTTP method of the faulty route, any method if omitted. */
+  method?: string;
+  /** Probability of failing a request, 1 by default. */
+  errorRate?: number;
+  /** Status of the fault response, 503 by default. */
+  status?: number;
+  /** Latency of the fault response. */
+  delay?: Delay;
+  /** Transport level fault instead of the status code response (see `Response.fault`). */
+  fault?: TransportFault;
+}
+
+/**
+ * Transport level fault of a response.
+ */
+export type TransportFault = "connection-reset" | "empty-response" | "malformed-chunk" | "random-garbage";
+
+/**
+ * Response fuzzing options.
+ */
+export interface FuzzOptions {
+  /**
+   * Probability of mutating a response (0..1), default 1.
+   */
+  rate: number
+
+  /**
+   * Enabled mutations, default all of them:
+   *  - `drop`: remove a property from a JSON body
+   *  - `type`: change the type of a JSON value
+   *  - `huge`: replace a JSON string value with a huge string (or append it to a non-JSON body)
+   *  - `status`: flip status code (success to error and vice versa)
+   */
+  mutations: Array<"drop" | "type" | "huge" | "status">
+
+  /**
+   * Random seed, for reproducible mutations.
+   */
+  seed: number
+}
+
+/**
+ * Create URL mock definition.
+ * 
+ * This function will create a new Express.js like web application and pass it to the provided
+ * callback function for programming. After mock programming is done, new mock HTTP server will be start.
+ * Whan you use http API from mock module, all matching URLs will be directed to this mock server.
+ * 
+ * You can create as many mock definitions (server) as you want.
+ * 
+ * You can disable the given mock definition quickly by passing options parameter with `skip` set to true.
+ * ```JavaScript
+ * mock(
+ *   'https://example.com',
+ *   app => {
+ *     app.get('/', (req, res) => {
+ *       res.json({ greeting: 'Hello World!' })
+ *     })
+ *   },
+ *   { skip: true }
+ * )
+ * ```
+ * 
+ * Alternatively you can put `.skip` after function name:
+ * ```JavaScript
+ * mock.skip('https://example.com', app => {
+ *  app.get('/', (req, res) => {
+ *    res.json({ greeting: 'Hello World!' })
+ *  })
+ * })
+ * ```
+ * 
+ * The target may contain wildcards, so one mock definition intercepts a family of URLs (e.g. CDN shards or
+ * per-tenant subdomains): `*` matches any characters except `/`, `**` matches any characters. The target
+ * matches the URL prefix, the rest of the URL is kept. If several targets match, the one matching the
+ * longest prefix wins, then the one with less wildcards.
+ * ```JavaScript
+ * mock('https://*.example.com/**', app => {
+ *   app.get('/assets/:name', (req, res) => res.send(req.params.name))
+ * })
+ * ```
+ *
+ * @param target the URL or URL prefix to be mocked, optionally with wildcards
+ * @param callback function to for defining route definitions for mock server
+ * @param options optional flags (`sync`, `skip`)
+ * @returns the application object of the started mock server (undefined if mocking was skipped)
+ */
+export function mock(target: String, callback: (app: MockApplication) => void, options?: MockOptions): MockApplication | undefined;
+
+export namespace mock {
+  /**
+   * Define a mock server serving over TLS with an on-the-fly self-signed certificate, the same as
+   * `mock()` with the `tls` option (see `MockOptions.tls`).
+   *
+   * ```js
+   * mock.https("https://api.example.com", app => {
+   *   app.get("/", (req, res) => res.json({ greeting: "Hello World!" }))
+   * })
+   * ```
+   *
+   * @param target the URL or URL prefix to be mocked
+   * @param callback function to for defining route definitions for mock server
+   * @param options optional flags
+   * @returns the application object of the started mock server (undefined if mocking was skipped)
+   */
+  function https(target: String, callback: (app: MockApplication) => void, options?: MockOptions): MockApplication | undefined;
+
+  /**
+   * Start verification of requests received by a mock server.
+   *
+   * The returned object selects the verified HTTP method and path (string or path pattern).
+   * Results are booleans, so they can be used directly in `check()`:
+   *
+   * ```js
+   * check(null, {
+   *   'order posted once': () =>
+   *     mock.expect('https://example.com').post('/orders').withHeader('Idempotency-Key').once()
+   * })
+   * ```
+   *
+   * @param target the URL or URL prefix of the mock definition (or the application object passed to the mock callback)
+   */
+  function expect(target: String | Application): Expect;
+
+  /**
+   * Create mock definition from a stub file.
+   *
+   * Stub files are created by `exportStubs` method of the application object, so traffic recorded in
+   * one run can be replayed fully offline in the next one. The file format (YAML or JSON) is selected
+   * by the file extension:
+   *
+   * ```yaml
+   * target: https://example.com
+   * stubs:
+   *   - request:
+   *       method: GET
+   *       path: /orders/:id
+   *       query:
+   *         expand: items
+   *     response:
+   *       status: 200
+   *       headers:
+   *         Content-Type: application/json
+   *         Content-Location: /orders/{{request.params.id}}
+   *       body: '{"id":1}'
+   * ```
+   *
+   * Stubs are matched in order. Request method defaults to `GET`, path may contain route parameters and
+   * listed query parameters must be present with the given value. Binary response bodies are stored
+   * base64 encoded, with `encoding: base64`.
+   *
+   * Response header values may contain placeholders of request values: `{{request.method}}`,
+   * `{{request.path}}`, `{{request.url}}` (path and query), `{{request.host}}`, `{{request.headers.<name>}}`,
+   * `{{request.query.<name>}}` and `{{request.params.<name>}}` (route parameters). Unknown placeholders
+   * are replaced with empty string.
+   *
+   * The optional callback may define additional routes, routes take precedence over stubs.
+   *
+   * @param path the stub file path
+   * @param callback optional function for defining additional routes
+   * @param options optional flags (`sync`, `skip`, `strict`)
+   * @returns the application object of the started mock server (undefined if mocking was skipped)
+   */
+  function load(path: string, callback?: (app: MockApplication) => void, options?: MockOptions): MockApplication | undefined;
+
+  /**
+   * Start a mock server defined by an OpenAPI 3 document (YAML or JSON).
+   *
+   * ```js
+   * mock.fromOpenAPI("petstore.yaml", { generate: { seed: 42 } })
+   * ```
+   *
+   * Each operation of the document is served with its response of the lowest 2xx status (or the `default`
+   * response). The body is the example of the response content (`example`, the first of `examples` or the
+   * example of the schema), JSON media types are preferred. Without example, the body is generated from the
+   * response schema for each request (see `generate`). Path templates are converted to route parameters.
+   *
+   * The mocked target is the first server URL of the document (server variables are substituted with
+   * their default values), unless it is given as argument. The optional callback may define additional
+   * routes, routes take precedence over the operations of the document.
+   *
+   * @param path the OpenAPI document path
+   * @param target optional mocked target URL
+   * @param callback optional function for defining additional routes
+   * @param options optional flags, the `generate` property contains the generator options
+   * @returns the application object of the started mock server (undefined if mocking was skipped)
+   */
+  function fromOpenAPI(
+    path: string,
+    target?: string,
+    callback?: (app: MockApplication) => void,
+    options?: OpenAPIOptions
+  ): MockApplication | undefined;
+  function fromOpenAPI(
+    path: string,
+    callback?: (app: MockApplication) => void,
+    options?: OpenAPIOptions
+  ): MockApplication | undefined;
+
+  /**
+   * Install the mock servers as the interception targets of a `k6/browser` page's routes (`page.route`).
+   *
+   * Requests of the page to the mocked targets are served by the mock servers (routes and stubs alike),
+   * so protocol-level and browser-level tests share the same mock definitions.
+   *
+   * ```js
+   * import { browser } from "k6/browser"
+   *
+   * mock.load("site.yaml")
+   *
+   * export default async function () {
+   *   const page = await browser.newPage()
+   *
+   *   await mock.intercept(page)
+   *   await page.goto("https://example.com/")
+   * }
+   * ```
+   *
+   * @param page the browser page
+   * @param targets optional list of intercepted mock targets, all mock targets are intercepted by default
+   * @returns a promise resolved when the routes are installed
+   */
+  function intercept(page: object, targets?: string[]): Promise<void>;
+
+  /**
+   * Start deferred mock servers (see `deferred` option).
+   *
+   * @param target the mock target to start, all deferred mock servers are started if missing
+   * @returns the application object of the given target's mock server
+   */
+  function start(target?: string): MockApplication | undefined;
+
+  /**
+   * Get the URL of a named service, so multi-mock setups don't have to pass URLs around through globals.
+   *
+   * Mock servers are named by the `name` option, their URL is the address of the running mock server
+   * (the mock target if the mock was skipped). Other services can be loaded by `mock.services`.
+   *
+   * ```js
+   * mock("https://payments.example.com", callback, { name: "payments" })
+   *
+   * export default function () {
+   *   http.get(`${mock.service("payments")}/balance`)
+   * }
+   * ```
+   *
+   * @param name the service name
+   * @returns the URL of the service
+   */
+  function service(name: string): string;
+
+  /**
+   * Load service URLs from a JSON or YAML file containing name and URL pairs (see `mock.service`).
+   * Named mock servers take precedence over the loaded services.
+   *
+   * @param path the service file path
+   * @returns the loaded name and URL pairs
+   */
+  function services(path: string): Record<string, string>;
+
+  /**
+   * Get the serializable handle of a mock server, it can be returned from `setup()`, so a mock server
+   * started in setup can be addressed from all VUs (`mock.attach`) and torn down in teardown (`mock.stop`).
+   *
+   * ```js
+   * export function setup() {
+   *   mock("https://payments.example.com", callback, { name: "payments" })
+   *
+   *   return { payments: mock.handle("https://payments.example.com") }
+   * }
+   *
+   * export default function (data) {
+   *   mock.attach(data.payments)
+   *   http.get("https://payments.example.com/balance")
+   * }
+   *
+   * export function teardown(data) {
+   *   mock.stop(data.payments)
+   * }
+   * ```
+   *
+   * @param target the mock target URL or the application object of the mock server
+   * @returns the handle of the mock server
+   */
+  function handle(target: string | MockApplication): MockHandle;
+
+  /**
+   * Make the mock server of the handle (started by another VU, e.g. in setup) the mock of its target.
+   *
+   * @param handle the mock server handle
+   */
+  function attach(handle: MockHandle): void;
+
+  /**
+   * Shut down the mock server of the handle (e.g. in teardown).
+   *
+   * @param handle the mock server handle
+   */
+  function stop(handle: MockHandle): void;
+
+  /**
+   * Start an MQTT 3.1.1 broker mock of the target.
+   *
+   * Topic handlers are called for the messages published by the clients, they may return reply messages
+   * which are published by the broker. Messages are delivered to the subscribers with QoS 0.
+   *
+   * ```js
+   * const broker = mock.mqtt('mqtt://broker.example.com:1883', broker => {
+   *   broker.on('connect', client => client.username === 'device')
+   *   broker.topic('devices/+/commands', msg => ({ topic: msg.topic.replace('commands', 'ack'), payload: 'ok' }))
+   * }, { env: 'MQTT_URL' })
+   *
+   * broker.publish('devices/42/config', JSON.stringify({ interval: 10 }), { retain: true })
+   * ```
+   *
+   * @param target the broker URL to mock
+   * @param callback function defining the broker's behavior
+   * @param options optional mock options (`sync`, `skip`, `name` and `env` are supported)
+   * @returns the broker, undefined if skipped
+   */
+  function mqtt(target: string, callback: (broker: MQTTBroker) => void, options?: MockOptions): MQTTBroker | undefined;
+
+  /**
+   * Start an AMQP 0-9-1 broker mock of the target.
+   *
+   * The broker supports exchanges (direct, topic and fanout), queues, bindings, publishing (including
+   * publisher confirms), consuming and `basic.get`. Handlers are called for the messages published by the
+   * clients, their reply is sent to the message's `replyTo` queue by default.
+   *
+   * ```js
+   * mock.amqp('amqp://rabbitmq.example.com:5672', broker => {
+   *   broker.exchange('orders', 'topic')
+   *   broker.queue('order-events', { orders: 'orders.#' })
+   *   broker.handle('rpc.price', msg => ({ body: JSON.stringify({ price: 42 }), contentType: 'application/json' }))
+   * }, { env: 'AMQP_URL' })
+   * ```
+   *
+   * @param target the broker URL to mock
+   * @param callback function defining the broker's behavior
+   * @param options optional mock options (`sync`, `skip`, `name` and `env` are supported)
+   * @returns the broker, undefined if skipped
+   */
+  function amqp(target: string, callback: (broker: AMQPBroker) => void, options?: MockOptions): AMQPBroker | undefined;
+
+  /**
+   * Start a single node Kafka broker mock of the target.
+   *
+   * The broker implements the ApiVersions, Metadata, Produce, Fetch and ListOffsets APIs with record batches
+   * (Kafka 0.11+ clients). Topics are created on first use with one partition. Consumer groups are not supported,
+   * consumers should read assigned partitions. Topic handlers may return record(s) produced by the broker.
+   *
+   * ```js
+   * const broker = mock.kafka('kafka://kafka.example.com:9092', broker => {
+   *   broker.topic('orders', { partitions: 3 })
+   *   broker.handle('orders', rec => ({ topic: 'invoices', key: rec.key, value: rec.value }))
+   * })
+   *
+   * const producer = new Writer({ brokers: [broker.address], topic: 'orders' })
+   * ```
+   *
+   * @param target the broker URL to mock
+   * @param callback function defining the broker's behavior
+   * @param options optional mock options (`sync`, `skip`, `name` and `env` are supported)
+   * @returns the broker, undefined if skipped
+   */
+  function kafka(target: string, callback: (broker: KafkaBroker) => void, options?: MockOptions): KafkaBroker | undefined;
+
+  /**
+   * Start a Redis server mock (RESP2) of the target.
+   *
+   * Commands are served by the scripted handlers or canned replies. Other commands are served by a built-in
+   * in-memory store supporting `PING`, `ECHO`, `SELECT`, `GET`, `SET`, `DEL`, `EXISTS`, `INCR`, `INCRBY` and `DECR`.
+   *
+   * Handler return values are converted to replies: strings are bulk strings, integral numbers and booleans are
+   * integers, arrays are arrays, `{ status }` and `{ error }` objects are status and error replies, null is the null reply.
+   *
+   * ```js
+   * const server = mock.redis('redis://cache.example.com:6379', server => {
+   *   server.command('HGET', ([key, field]) => (key === 'user:1' ? 'alice' : null))
+   *   server.error('FLUSHALL', 'NOPERM this user has no permissions')
+   *   server.latency('*', [1, 5])
+   * }, { env: 'REDIS_URL' })
+   * ```
+   *
+   * @param target the server URL to mock
+   * @param callback function defining the server's behavior
+   * @param options optional mock options (`sync`, `skip`, `name` and `env` are supported)
+   * @returns the server, undefined if skipped
+   */
+  function redis(target: string, callback: (server: RedisServer) => void, options?: MockOptions): RedisServer | undefined;
+
+  /**
+   * Start a generic TCP mock of the target, serving a scripted conversation.
+   *
+   * Each connection plays the steps defined by the callback: send data (e.g. a banner), expect data
+   * (a string matched literally or a regular expression), reply, echo and hang up. Steps defined after
+   * `repeat()` are repeated until the client disconnects.
+   *
+   * ```js
+   * mock.tcp('tcp://smtp.example.com:25', conv => {
+   *   conv.send('220 smtp.example.com ESMTP\r\n')
+   *   conv.repeat()
+   *   conv.expect(/^HELO (\S+)\r\n/i).reply(match => `250 Hello ${match[1]}\r\n`)
+   *   conv.expect('QUIT\r\n').reply('221 Bye\r\n').hangup()
+   * }, { env: 'SMTP_URL' })
+   * ```
+   *
+   * @param target the URL to mock
+   * @param callback function defining the conversation
+   * @param options optional mock options (`sync`, `skip`, `name` and `env` are supported)
+   * @returns the mock, undefined if skipped
+   */
+  function tcp(target: string, callback: (conv: TCPConversation) => void, options?: MockOptions): TCPConversation | undefined;
+
+  /**
+   * Start a UDP responder mock of the target.
+   *
+   * Datagrams matching the pattern of a scripted reply are answered with the reply, the others are passed
+   * to the handlers. The values returned by the handlers are sent back to the sender.
+   *
+   * ```js
+   * mock.udp('udp://statsd.example.com:8125', server => {
+   *   server.reply(/^health/, 'ok')
+   *   server.handle(dgram => console.log(dgram.data))
+   * }, { env: 'STATSD_URL' })
+   * ```
+   *
+   * @param target the URL to mock
+   * @param callback function defining the responder's behavior
+   * @param options optional mock options (`sync`, `skip`, `name` and `env` are supported)
+   * @returns the responder, undefined if skipped
+   */
+  function udp(target: string, callback: (server: UDPServer) => void, options?: MockOptions): UDPServer | undefined;
+
+  /**
+   * Wrap the WebSocket API of the `k6/ws` or `k6/experimental/websockets` module, so the `ws://` and
+   * `wss://` URLs of the mocked targets are connected to the mock servers (see `MockApplication.ws`).
+   *
+   * The `connect` function (`k6/ws`) and the `WebSocket` class (`k6/experimental/websockets`) of the
+   * module object are replaced, use them through the returned module.
+   *
+   * ```js
+   * import * as websockets from "k6/experimental/websockets"
+   *
+   * const { WebSocket } = mock.websockets(websockets)
+   * ```
+   *
+   * @param module the WebSocket module
+   * @returns the module
+   */
+  function websockets<T extends object>(module: T): T;
+
+  /**
+   * Wrap the HTTP and WebSocket API of another module (e.g. `k6/x/http` or `k6/experimental/websockets`),
+   * so the URLs of the mocked targets are rewritten to the mock servers, whichever HTTP API the script uses.
+   *
+   * The URL functions of the module (`get`, `post`, ..., `request`, `asyncRequest`, `fetch` and `batch`),
+   * the WebSocket API (as in `mock.websockets`) and the classes of the module are replaced: the URL methods
+   * of the objects created by the classes (e.g. `Client`) are wrapped too. Use them through the returned module.
+   *
+   * ```js
+   * import * as xhttp from "k6/x/http"
+   *
+   * const { Client } = mock.wrap(xhttp)
+   *
+   * export default async function () {
+   *   const res = await new Client().request("GET", "https://api.example.com/users")
+   * }
+   * ```
+   *
+   * @param module the HTTP (or WebSocket) module
+   * @returns the module
+   */
+  function wrap<T extends object>(module: T): T;
+
+  /**
+   * Set the handling of the requests to URLs not mocked, so tests accidentally hitting production are caught:
+   *  - `"passthrough"`: the requests go to the real network (default)
+   *  - `"block"`: the requests throw an error
+   *  - `"respond"`: the requests are answered with 501 Not Implemented by a catch-all mock server
+   *  - a handler: the requests are answered by the handler on a catch-all mock server
+   *
+   * Local addresses (`http://localhost`, `http://127.*`) and the URLs of the mock servers (including the
+   * `https://` URLs of TLS mock servers) are always passed through. gRPC addresses are not affected.
+   *
+   * ```js
+   * mock.unmatched("block")
+   * mock.unmatched((req, res) => res.status(502).json({ error: `${req.path} is not mocked` }))
+   * ```
+   *
+   * @param policy the handling of the requests to URLs not mocked
+   */
+  function unmatched(policy: "passthrough" | "block" | "respond" | Middleware): void;
+
+  /**
+   * Wrap the `Client` class of the `k6/net/grpc` module, so the `host:port` addresses of the mocked
+   * targets are connected to the mock servers. The `plaintext` connect parameter is set by the mock server:
+   * native gRPC requires HTTP/2, so the mock server should use TLS (`mock.https` or the `tls` option).
+   *
+   * ```js
+   * import grpc from "k6/net/grpc"
+   *
+   * const { Client } = mock.grpc(grpc)
+   * ```
+   *
+   * @param module the gRPC module
+   * @returns the module
+   */
+  function grpc<T extends object>(module: T): T;
+
+  /**
+   * Decode a message body with the codec registered for the content type.
+   *
+   * The `application/json` codec is built in, other codecs are registered by Go extensions
+   * built into k6 along with xk6-mock-server (see `mock.RegisterCodec`).
+   *
+   * @param body the message body
+   * @param contentType the content type of the body
+   * @returns the decoded value
+   */
+  function decode(body: string | ArrayBuffer, contentType: string): any;
+
+  /**
+   * Encode a value to message body with the codec registered for the content type.
+   *
+   * @param value the value to encode
+   * @param contentType the content type of the body
+   * @returns the encoded body
+   */
+  function encode(value: any, contentType: string): ArrayBuffer;
+
+  /**
+   * Generate random value conforming to a JSON Schema.
+   *
+   * Useful for generating varied, realistic response bodies without hand-written fixtures:
+   *
+   * ```js
+   * app.get('/orders/:id', (req, res) => {
+   *   res.json(mock.generate(openapi, { ref: '#/components/schemas/Order', maxItems: 10 }))
+   * })
+   * ```
+   *
+   * Supported keywords: `type`, `enum`, `const`, `format` (date-time, date, email, uri, hostname, ipv4, uuid),
+   * `minLength`, `maxLength`, `minimum`, `maximum`, `exclusiveMinimum`, `exclusiveMaximum`, `multipleOf`,
+   * `items`, `minItems`, `maxItems`, `properties`, `required`, `allOf`, `oneOf`, `anyOf`, local `$ref`
+   * and OpenAPI's `nullable`.
+   *
+   * @param schema JSON Schema (or the root document referenced by `ref` option, e.g. an OpenAPI document)
+   * @param options optional generator options
+   */
+  function generate(schema: Record<string, any>, options?: GenerateOptions): any;
+
+  /**
+   * Returns a route handler serving the given responses on successive calls, e.g. for polling and retry
+   * testing. After the last response it is repeated, or the sequence starts over with the `loop` option.
+   *
+   * ```js
+   * app.get("/poll", mock.sequence([{ status: 202 }, { status: 202 }, { status: 200, body: { done: true } }]))
+   * ```
+   *
+   * Non-string bodies are sent as JSON. The handler's `reset` method restarts the sequence.
+   *
+   * @param responses the responses in the order of the calls
+   * @param options optional flags
+   */
+  function sequence(responses: Array<{ status?: number, headers?: Record<string, string>, body?: string | ArrayBuffer | object }>, options?: { loop?: boolean }): Middleware & { reset(): void };
+
+  /**
+   * Derive boundary values from a JSON Schema.
+   *
+   * The first value is a valid base value, every other value differs from it in exactly one location,
+   * which is replaced with a boundary value: empty and max size arrays, nulls where allowed, min/max length
+   * strings, min/max numbers, other enum values and missing optional properties.
+   *
+   * Calling `next()` from the route handler cycles responses through the values systematically:
+   *
+   * ```js
+   * const orders = mock.boundaries(openapi, { ref: '#/components/schemas/Order' })
+   *
+   * app.get('/orders/:id', (req, res) => {
+   *   res.json(orders.next())
+   * })
+   * ```
+   *
+   * @param schema JSON Schema (or the root document referenced by `ref` option, e.g. an OpenAPI document)
+   * @param options optional `ref` JSON pointer of the schema within the passed document
+   */
+  function boundaries(schema: Record<string, any>, options?: { ref?: string }): Boundaries;
+
+  /**
+   * Returns mock server statistics for the end-of-test summary.
+   *
+   * The returned object contains requests per route (with status code distribution), unmatched
+   * request count, the number of injected faults (by the `faults` schedule and `res.fault()`) and
+   * `mock.expect` verification results for each mocked target. Verifications are aggregated by name:
+   * the number of evaluations (`runs`), failed evaluations (`failed`) and the request count of the
+   * last one (`count`). It is intended to be used from `handleSummary`:
+   *
+   * ```js
+   * export function handleSummary(data) {
+   *   data.mock = mock.summary()
+   *
+   *   return {
+   *     stdout: textSummary(data) + mock.textSummary(),
+   *     'summary.json': JSON.stringify(data)
+   *   }
+   * }
+   * ```
+   */
+  function summary(): Record<string, any>;
+
+  /**
+   * Returns mock server statistics as human readable text, similar to k6's end-of-test text summary.
+   */
+  function textSummary(): string;
+}
+
+/**
+ * Options of `mock.generate`.
+ */
+export interface OpenAPIOptions extends MockOptions {
+  /** Options of the response body generator. */
+  generate?: Partial<GenerateOptions>;
+}
+
+export interface GenerateOptions {
+  /** JSON pointer of the schema within the passed document, e.g. `#/components/schemas/Order`. */
+  ref: string;
+  /** Random seed, the same seed generates the same value. */
+  seed: number;
+  /** Minimum array length, if not constrained by the schema (default 0). */
+  minItems: number;
+  /** Maximum array length, if not constrained by the schema (default 5). */
+  maxItems: number;
+  /** Maximum string length, if not constrained by the schema (default 16). */
+  maxLength: number;
+  /** Maximum nesting depth (default 8), deeper objects and arrays are generated as null. */
+  maxDepth: number;
+  /** Probability of generating a non-required property (default 0.5). */
+  optional: number;
+}
+
+/**
+ * Boundary values returned by `mock.boundaries`.
+ */
+export interface Boundaries {
+  /** All derived values, the first one is the base value. */
+  values: any[];
+  /** Returns the next value, restarting from the first one after the last. */
+  next(): any;
+}
+
+/**
+ * Request selector of the `mock.expect` API.
+ */
+export interface Expect {
+  get(path: string): Expectation;
+  head(path: string): Expectation;
+  post(path: string): Expectation;
+  put(path: string): Expectation;
+  patch(path: string): Expectation;
+  delete(path: string): Expectation;
+  options(path: string): Expectation;
+  /** Selects requests with any HTTP method. */
+  any(path: string): Expectation;
+}
+
+/**
+ * Request verification created by `mock.expect`.
+ */
+export interface Expectation {
+  /** Only requests having the given header (with the given value, if present) are counted. */
+  withHeader(name: string, value?: string): Expectation;
+  /** Only requests having the given query parameter (with the given value, if present) are counted. */
+  withQuery(name: string, value?: string): Expectation;
+  /** Only requests having body containing the given string are counted. */
+  withBody(part: string): Expectation;
+  /** Only requests of the given tenant are counted (see `tenancy` option of `mock`). */
+  withTenant(name: string): Expectation;
+  /** Returns the number of matching requests. */
+  count(): number;
+  /** True if at least `n` requests matched. */
+  atLeast(n: number): boolean;
+  /** True if at most `n` requests matched. */
+  atMost(n: number): boolean;
+  /** True if exactly `n` requests matched. */
+  times(n: number): boolean;
+  /** True if exactly one request matched. */
+  once(): boolean;
+  /** True if no request matched. */
+  never(): boolean;
+}
+
+/**
+ * Deactivate URL mocking.
+ * 
+ * This function will remove mock definition associated to given URL and stop the related HTTP server.
+ * 
+ * @param target the URL or URL prefix of mock definition to be remove
+ */
+export function unmock(target: String): void;
+
+// muxpress ------------------------------------------------------------------------
+
+/**
+ * Middleware defines middleware and request handler callback function.
+ *
+ * @param req the request object
+ * @param res the response object
+ * @param next calling from middleware enables processing next middleware
+ */
+export type Middleware = (req: Request, res: Response, next: () => void) => void;
+
+/**
+ * An application object represents a web application.
+ * 
+ * The following example starts a server and listens for connections on port 3000.
+ * The application responds with a JSON object for requests to the root URL.
+ * All other routes are answered with a 404 not found message.
+ *
+ * In this example, the name of the constructor is `Application`, but the name you use is up to you.
+ * 
+ * @example
+ * const app = new Application()
+ *
+ * app.get('/', (req, res) => {
+ *   res.json({message:"Hello World!"})
+ * })
+ *
+ * app.listen(3000)
+ */
+export class Application {
+  /**
+   * Creates a new application instance.
+   */
+  constructor();
+
+  /**
+   * Routes HTTP GET requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  get(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Routes HTTP HEAD requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  head(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Routes HTTP POST requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  post(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Routes HTTP PUT requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  put(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Routes HTTP PATCH requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  patch(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Routes HTTP `DELETE` requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  delete(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Routes HTTP OPTIONS requests to the specified path with the specified middleware functions.
+   *
+   * You can provide multiple middleware functions.
+   *
+   * @param path The path for which the middleware function is invoked (string or path pattern)
+   * @param middleware Middleware functions
+   */
+  options(path: string, ...middleware: Middleware[]): void;
+
+  /**
+   * Uses the specified middleware function or functions.
+   *
+   * Middlewares run in registration order with the route handlers, each continues the chain by
+   * calling `next`, responding without calling it ends the chain. Middlewares matching the path
+   * also run for requests not matched by any route, before the 404 Not Found response.
+   *
+   * @param path The path prefix for which the middleware function is invoked (string or path pattern), defaults to "/"
+   * @param middleware Middleware functions
+   */
+  use(path: string, ...middleware: Middleware[]): void;
+  use(...middleware: Middleware[]): void;
+
+  /**
+   * Mount static web content from given source directory.
+   *
+   * @param path The path where the source will be mounted on
+   * @param docroot The source directory path
+   */
+  static(path: string, docroot: string): void;
+
+  /**
+   * Starts the server.
+   *
+   * @param port TCP port number, if 0 or missing then random unused port will be allocated
+   * @param callback host name or IP address for listening on, default 127.0.0.1
+   * @returns The instance for fluent/chaining API
+   */
+  listen(addr?: string, callback?: () => void): void;
+}
+
+/**
+ * The application object of a mock definition.
+ *
+ * It is passed to the `mock` callback function and it is also returned by the `mock` function.
+ */
+export interface MockApplication extends Application {
+  /**
+   * Routes HTTP GET requests, route definition methods accept route options (`RouteOptions`) among middlewares.
+   *
+   * ```js
+   * app.get("/v1/items", (req, res) => res.json([]), { deprecated: true, sunset: "2030-01-01T00:00:00Z" })
+   * ```
+   *
+   * Path parameters may have Express-style regular expression constraints, the parameter matches the
+   * whole path segment only if it matches the expression, so constrained routes can coexist:
+   *
+   * ```js
+   * app.get("/users/:id(\\d+)", (req, res) => res.json({ id: Number(req.params.id) }))
+   * app.get("/users/:slug([a-z-]+)", (req, res) => res.json({ slug: req.params.slug }))
+   * ```
+   *
+   * Overlapping routes are tried by priority (`RouteOptions.priority`), then specificity first regardless
+   * of the order of definition: the path patterns are compared segment by segment (literal segment,
+   * constrained parameter, parameter, wildcard), then the longer pattern wins. So `/users/me` is tried
+   * before `/users/:id`, routes of the same specificity are tried in the order of definition.
+   *
+   * The returned route can match on the request content too (see `Route`), so different responses can
+   * be served for the same URL:
+   *
+   * ```js
+   * app.post("/items", (req, res) => res.json({ discount: 20 })).withHeader("X-Tenant", "acme").withBodyJson({ type: "gold" })
+   * app.post("/items", (req, res) => res.json({ discount: 0 }))
+   * ```
+   */
+  get(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+  head(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+  post(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+  put(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+  patch(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+  delete(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+  options(path: string, ...middleware: Array<Middleware | RouteOptions>): Route;
+
+  /**
+   * Marks the already defined routes matching the path pattern (e.g. `/v1/*`) deprecated.
+   *
+   * @param path path pattern of the deprecated routes
+   * @param options deprecation options, `deprecated` is true by default
+   */
+  deprecate(path: string, options?: DeprecationOptions): MockApplication;
+
+  /**
+   * Register a hook invoked when a request arrives, before it is routed.
+   * Usable for custom metrics, logging or lightweight verification without wrapping every route handler.
+   *
+   * Hooks receive read-only information and run asynchronously on the VU's event loop (in synchronous mode too), they can't alter the response.
+   *
+   * @param hook the hook function
+   */
+  onRequest(hook: (req: HookRequest) => void): MockApplication;
+
+  /**
+   * Register a hook invoked after the response was sent.
+   *
+   * ```js
+   * app.onResponse(res => { if (res.status >= 500) console.warn(`${res.request.path} failed`) })
+   * ```
+   *
+   * @param hook the hook function
+   */
+  onResponse(hook: (res: HookResponse) => void): MockApplication;
+
+  /**
+   * Register a hook invoked when a route handler throws an exception or the application is unreachable.
+   *
+   * @param hook the hook function
+   */
+  onError(hook: (err: HookError) => void): MockApplication;
+
+  /**
+   * Serve the requests selected by a custom matcher with a custom responder.
+   *
+   * Matchers and responders are registered by Go extensions built into k6 along with xk6-mock-server
+   * (see `mock.RegisterMatcher` and `mock.RegisterResponder`). Extensions are checked before the routes.
+   *
+   * ```js
+   * app.extension({ type: 'grpc-method', method: 'orders.Orders/Get' }, { type: 'grpc-fixture', file: 'order.bin' })
+   * ```
+   *
+   * @param matcher the registered matcher name or an object with the name in `type` and the matcher configuration
+   * @param responder the registered responder name or an object with the name in `type` and the responder configuration
+   */
+  extension(matcher: string | ExtensionSpec, responder: string | ExtensionSpec): MockApplication;
+
+  /**
+   * Register a transformer running over every outgoing response, in the order of registration.
+   *
+   * The transformer is a function receiving the response, it may modify the `status`, `headers` and `body`
+   * properties or return a new response object. Built-in transformers are registered by name:
+   *
+   * - `header`: sets the header given by `name` and `value` options
+   * - `links`: rewrites the links of the mock target (and of the `origins` option) to the mock server's URL
+   * - `compress`: gzip encodes the body if the client accepts it
+   *
+   * ```js
+   * app.transform('header', { name: 'X-Powered-By', value: 'mock' })
+   * app.transform(res => { res.headers['Cache-Control'] = 'no-store' })
+   * app.transform('compress')
+   * ```
+   *
+   * @param transformer the transformer function or the name of a built-in transformer
+   * @param options options of the built-in transformer
+   */
+  transform(transformer: string | ((res: TransformedResponse) => TransformedResponse | void), options?: Record<string, any>): MockApplication;
+
+  /**
+   * The virtual clock of the mock server.
+   *
+   * The `Date` header of responses is set from this clock, and route handlers may use it for
+   * time-dependent values (token expiries, `Retry-After`, `Expires`), so time-dependent client logic
+   * can be tested deterministically:
+   *
+   * ```js
+   * app.clock.freeze('2030-01-01T00:00:00Z')
+   *
+   * app.post('/token', (req, res) => {
+   *   res.json({ token: 'abc', expires_at: app.clock.now() + 3600 * 1000 })
+   * })
+   * ```
+   */
+  clock: Clock;
+
+  /**
+   * The cache of the mock server, only available if `cache` or `cdn` option was set.
+   */
+  cache?: Cache;
+
+  /**
+   * Returns the retry storms detected so far, only available if `retryStorm` option was set.
+   */
+  retryStorms?: () => Array<{ client: string, method: string, path: string, start: number, retries: number }>;
+
+  /**
+   * Returns the request, retry and retry storm counts of the clients and their retry rate (retries per
+   * second between the first and the last request), only available if `retryStorm` option was set.
+   */
+  retryClients?: () => Array<{ client: string, requests: number, retries: number, storms: number, rate: number }>;
+
+  /**
+   * Returns the client connections of the mock server (the most recent ones) with the requests served on them,
+   * e.g. for measuring the connection pooling behavior of the client under test.
+   *
+   * ```js
+   * export function teardown() {
+   *   const reused = app.connections().filter((conn) => conn.requests > 1).length;
+   * }
+   * ```
+   */
+  connections(): Array<Connection>;
+
+  /**
+   * Returns the statistics of the routes with concurrency limit (see `RouteOptions.concurrency`).
+   */
+  limits(): Array<{
+    method: string,
+    route: string,
+    limit: number,
+    inFlight: number,
+    queued: number,
+    maxQueued: number,
+    served: number,
+    rejected: number
+  }>;
+
+  /**
+   * Returns the cohort of the client identifier, only available if `clients` option was set.
+   */
+  cohortOf?: (client: string) => string;
+
+  /**
+   * The state of the health endpoints, only available if `health` option was set.
+   */
+  health?: {
+    /** Set the state of the liveness endpoint, with optional details. */
+    liveness(state: HealthState, details?: Record<string, any>): void;
+    /** Set the state of the readiness endpoint, with optional details. */
+    readiness(state: HealthState, details?: Record<string, any>): void;
+    /** Returns the state of the endpoints. */
+    status(): Record<"liveness" | "readiness", { state: HealthState, details: Record<string, any> | null }>;
+  };
+
+  /**
+   * The TLS listener of the mock server, only available if `tls` option was set.
+   */
+  tls?: {
+    /** The number of TLS handshakes and resumed sessions so far. */
+    stats(): { handshakes: number, resumed: number };
+    /** Replace the session ticket keys, so the sessions of the issued tickets can not be resumed. */
+    rotateTicketKeys(): void;
+    /**
+     * The TLS fingerprints of the client connections: the ClientHello parameters, the JA3 string and
+     * its MD5 hash and the HTTP/2 fingerprint of HTTP/2 connections, e.g. for validating that a client
+     * presents the expected fingerprint after upgrades. The fingerprint of each request's connection is
+     * available by `app.requests()` too.
+     */
+    clients(): Array<TLSClient>;
+    /** Change the status of the stapled OCSP response, an empty status disables stapling. */
+    ocsp(status: "good" | "revoked" | "unknown" | ""): void;
+  };
+
+  /**
+   * Define a WebSocket endpoint. The handler is called with the connection of each client.
+   *
+   * ```js
+   * app.ws('/rooms/:room', conn => {
+   *   conn.send(`welcome to ${conn.params.room}`)
+   *   conn.on('message', msg => conn.send(msg))
+   * })
+   * ```
+   *
+   * @param path the path pattern of the endpoint
+   * @param handler the connection handler
+   */
+  ws(path: string, handler: (conn: WebSocketConnection) => void): MockApplication;
+
+  /**
+   * Handle the tunnels of CONNECT requests. The handler is called with the tunnel of each request,
+   * after the `200 Connection Established` response was sent.
+   *
+   * Without connect handler, the mock server itself is served through the tunnels (plain HTTP),
+   * so clients using the mock server as proxy reach the mock's routes.
+   *
+   * @param handler the tunnel handler
+   */
+  connect(handler: (tunnel: StreamConnection) => void): MockApplication;
+
+  /**
+   * Define an endpoint of a protocol upgrade (other than WebSocket, see `ws`). Requests with matching
+   * path and `Upgrade` header are switched to the protocol and the connection is passed to the handler.
+   *
+   * ```js
+   * app.upgrade('echo/1', '/streams/:id', conn => conn.on('data', data => conn.send(data)))
+   * ```
+   *
+   * @param protocol the protocol name of the Upgrade header (case insensitive)
+   * @param path the path pattern of the endpoint
+   * @param handler the connection handler
+   */
+  upgrade(protocol: string, path: string, handler: (conn: StreamConnection) => void): MockApplication;
+
+  /**
+   * Returns the controller of a simulated region (see `regions` option).
+   *
+   * ```js
+   * app.region('eu').down()
+   * ```
+   *
+   * @param name the region name
+   */
+  region(name: string): Region;
+
+  /**
+   * Returns the state namespace of the given tenant (the default namespace if missing),
+   * e.g. for seeding or inspecting the mock state.
+   *
+   * @param tenant the tenant identifier (see `tenancy` option)
+   */
+  state(tenant?: string): State;
+
+  /**
+   * Returns the identifiers of tenants having state namespace.
+   */
+  tenants(): string[];
+
+  /**
+   * Defines a group of routes sharing a path prefix and options, instead of repeating them per route.
+   *
+   * Routes (and middlewares) registered in the callback are prefixed with the path. The group's `delay`
+   * applies to the routes without their own delay, the `headers` are set on the responses unless the
+   * handler sets them, the `middleware` functions run before the handlers of the group's routes.
+   * Groups can be nested, the inner group's options take precedence.
+   *
+   * ```js
+   * app.group("/api/v1", { delay: "50ms", headers: { "Cache-Control": "no-store" }, middleware: auth }, app => {
+   *   app.get("/users/:id", (req, res) => res.json({ id: req.params.id }))
+   *   app.post("/users", (req, res) => res.status(201).json({}))
+   * })
+   * ```
+   *
+   * @param path the path prefix of the group
+   * @param options the options shared by the routes of the group
+   * @param callback function for defining the routes of the group
+   */
+  group(path: string, options: GroupOptions, callback: (app: MockApplication) => void): MockApplication;
+  group(path: string, callback: (app: MockApplication) => void): MockApplication;
+
+  /**
+   * Returns the scenario (a named state machine) with the given name, it is created on the first call.
+   * Routes of a scenario state are served only while the scenario is in that state, and may move the
+   * scenario to another state when they are hit, so multi-step workflows (e.g. OAuth or checkout
+   * flows) can be mocked.
+   *
+   * ```js
+   * const checkout = app.scenario("checkout")
+   *
+   * checkout.state("empty").get("/cart", (req, res) => res.json({ items: [] }))
+   * checkout.state("empty").post("/cart", (req, res) => res.status(201).json({})).transitionTo("has-items")
+   * checkout.state("has-items").get("/cart", (req, res) => res.json({ items: ["book"] }))
+   * checkout.state("has-items").post("/checkout", (req, res) => res.json({ ok: true })).transitionTo("empty")
+   * ```
+   *
+   * @param name the scenario name
+   * @param initial the initial state, the first declared state by default
+   */
+  scenario(name: string, initial?: string): Scenario;
+
+  /**
+   * Defines a version of the route set, e.g. for blue/green deployment simulation.
+   *
+   * Routes (and middlewares) registered in the callback belong to the given version. Routes defined outside
+   * of versions are served regardless of the active version. The first defined version is active by default,
+   * the version serving a request is reported in `X-Served-Version` response header.
+   *
+   * ```js
+   * mock("https://api.example.com", app => {
+   *   app.version("blue", app => app.get("/items", (req, res) => res.json({ items: [] })))
+   *   app.version("green", app => app.get("/items", (req, res) => res.json({ data: [] })))
+   *
+   *   app.split({ blue: 90, green: 10 }).switch("green", { after: 30000 })
+   * })
+   * ```
+   *
+   * @param name the version name
+   * @param callback function for defining the routes of the version
+   */
+  version(name: string, callback: (app: MockApplication) => void): MockApplication;
+
+  /**
+   * Atomically switches all traffic to the given version (optionally after the given delay, in milliseconds
+   * or as duration string, e.g. `"30s"`).
+   *
+   * @param name the version name
+   * @param options optional schedule
+   */
+  switch(name: string, options?: { after?: number | string }): MockApplication;
+
+  /**
+   * Splits traffic between versions by weight (optionally after the given delay, in milliseconds or as
+   * duration string, e.g. `"30s"`).
+   *
+   * @param weights traffic weights by version name, e.g. `{ blue: 90, green: 10 }`
+   * @param options optional schedule
+   */
+  split(weights: Record<string, number>, options?: { after?: number | string }): MockApplication;
+
+  /**
+   * Defines versioned API routes.
+   *
+   * Routes defined in a version callback are registered both with version path prefix (`/v2/items`)
+   * and without it. Requests without path prefix are routed by the `Accept` header: version is given either
+   * as media type parameter (`application/json; version=2`) or in vendor media type
+   * (`application/vnd.example.v2+json`, if `media` option is set). The default version is the `default`
+   * option or the first version (in alphabetical order).
+   *
+   * The `Content-Type` of responses is versioned accordingly (`application/vnd.example.v2+json`
+   * or `application/json; version=2`).
+   *
+   * ```js
+   * app.api({
+   *   v1: app => app.get("/items", (req, res) => res.json({ items: [] })),
+   *   v2: app => app.get("/items", (req, res) => res.json({ data: [] }))
+   * }, { media: "application/vnd.example+json" })
+   * ```
+   *
+   * @param versions route definition callback by version name (e.g. `v1`)
+   * @param options optional vendor media type and default version
+   */
+  api(versions: Record<string, (app: MockApplication) => void>, options?: { media?: string, default?: string }): MockApplication;
+
+  /**
+   * Writes all recorded request/response exchanges of the mock server to a file in
+   * [HAR](http://www.softwareishard.com/blog/har-12-spec/) format, so they can be analyzed in browser devtools.
+   *
+   * @param path the output file path
+   */
+  exportHAR(path: string): void;
+
+  /**
+   * Writes stubs covering the endpoints observed by the mock server to a file, in YAML (`.yaml`, `.yml`)
+   * or JSON format. The file can be loaded with `mock.load` to replay the responses.
+   *
+   * Unmatched requests are skipped, for repeated requests the latest response is kept.
+   *
+   * @param path the output file path
+   */
+  exportStubs(path: string): void;
+
+  /**
+   * Forward the unmatched requests (no route or stub) to a real origin and record the exchanges.
+   *
+   * ```js
+   * mock("https://api.example.com", app => {
+   *   app.record("https://api.example.com", { file: "recordings.json" })
+   * })
+   * ```
+   *
+   * Recorded responses are served for the repeated requests instead of forwarding them. With the `file`
+   * option the recordings are written to the stub file (YAML or JSON) after each exchange, and the
+   * recordings of the existing file are served as well, so only the new requests reach the origin.
+   *
+   * @param origin the absolute URL of the real origin
+   * @param options recording options
+   */
+  record(origin: string, options?: { file?: string }): void;
+
+  /**
+   * Returns the number of the exchanges recorded by `record`.
+   */
+  recorded(): number;
+
+  /**
+   * Serve the recordings of a stub file (created by `record` or `exportStubs`) offline, without the origin.
+   * Call it in the mock definition callback.
+   *
+   * @param path the stub file path
+   */
+  replay(path: string): void;
+
+  /**
+   * Returns the requests received by the mock server, in the order of arrival, optionally filtered
+   * by the matcher. Only the requests retained by the journal (see the `journal` option) are returned.
+   *
+   * ```js
+   * const start = Date.now()
+   *
+   * http.post("https://api.example.com/orders/1", JSON.stringify({ qty: 2 }))
+   *
+   * check(app, { "order placed": (app) => app.requests({ method: "POST", path: "/orders/:id", since: start }).length == 1 })
+   * ```
+   *
+   * @param matcher the request matcher, all requests are returned if omitted
+   */
+  requests(matcher?: RequestMatcher): JournalRequest[];
+
+  /**
+   * Returns the number of the requests received by the mock server matching the matcher.
+   *
+   * @param matcher the request matcher, all requests are counted if omitted
+   */
+  requestCount(matcher?: RequestMatcher): number;
+
+  /**
+   * Removes the requests from the journal, e.g. between test phases.
+   */
+  clearRequests(): void;
+
+  /**
+   * Define a stub serving a fixed response and recording its calls, for verifying how many times
+   * and with what payloads the system under test called the mock.
+   *
+   * ```js
+   * const orders = app.stub("POST", "/orders/:id").returns({ status: 201, body: { ok: true } })
+   *
+   * check(orders, {
+   *   "called once": (stub) => stub.calls.length == 1,
+   *   "with quantity": (stub) => stub.calledWith({ params: { id: "1" }, body: { qty: 2 } }),
+   * })
+   * ```
+   *
+   * Stubs are served without the event loop. They are consulted only when no route matches the request,
+   * so routes take precedence over them; calls are recorded only when the stub serves the request.
+   *
+   * @param method the HTTP method
+   * @param path the path pattern (route parameters are supported)
+   */
+  stub(method: string, path: string): Stub;
+
+  /**
+   * Define a versioned dataset served on GET requests, with `ETag` (the version) and `Last-Modified`
+   * (virtual clock) headers. Conditional requests (`If-None-Match`, `If-Modified-Since`) are answered
+   * with 304 until the script mutates the dataset, so polling clients can be tested.
+   *
+   * ```js
+   * const prices = app.dataset("/prices", { book: 10 })
+   *
+   * // later, e.g. from a timer
+   * prices.mutate({ book: 12 })
+   * ```
+   *
+   * Datasets are served without the event loop, routes take precedence over them.
+   *
+   * @param path the path pattern
+   * @param content the content, strings are served as text, other values as JSON
+   */
+  dataset(path: string, content: string | ArrayBuffer | Record<string, any> | any[]): Dataset;
+
+  /**
+   * Deliver an outbound webhook, modeling real webhook providers: failed deliveries are retried with
+   * exponential backoff until the success criteria are met (any 2xx status by default) or the attempts
+   * are exhausted. Non-string payloads are sent as JSON. Mocked receiver URLs are delivered to their mock
+   * servers, like the requests of the `k6/http` module. Attempts and delivery results are emitted as
+   * `mock_webhook_attempts`, `mock_webhook_deliveries` and `mock_webhook_duration` metrics (tagged with
+   * the host of the receiver URL).
+   *
+   * ```js
+   * const delivery = await app.webhook("http://localhost:8080/hooks", { event: "order.paid" }, {
+   *   maxAttempts: 5,
+   *   backoff: { initial: "500ms", factor: 2, max: "5s" },
+   *   success: { status: [200, 202], body: "ok" },
+   * })
+   * ```
+   *
+   * @param url the URL of the webhook receiver
+   * @param payload the request body
+   * @param opts the delivery policy
+   * @returns promise resolved with the delivery result when the webhook was delivered or the attempts were exhausted
+   */
+  webhook(url: string, payload?: string | ArrayBuffer | Record<string, any>, opts?: WebhookOptions): Promise<WebhookDelivery>;
+
+  /**
+   * Define a GraphQL endpoint (`GET` and `POST` requests, JSON or `application/graphql` body) served by
+   * resolvers instead of string-matching query bodies. The query is parsed and the operation's root fields
+   * are resolved by the resolvers of the operation type, the result is built by the selection sets
+   * (aliases, fragments, variables, `@skip` and `@include` are supported) into a `{ data, errors }` response.
+   *
+   * ```js
+   * app.graphql("/graphql", {
+   *   Query: {
+   *     user: ({ id }, req) => ({ id, name: "Jane", posts: ({ first }) => posts.slice(0, first) }),
+   *   },
+   *   Mutation: {
+   *     rename: ({ id, name }) => ({ id, name }),
+   *   },
+   * })
+   * ```
+   *
+   * Resolvers are called with the field arguments and the request, non-function values are used as is.
+   * Function properties of the resolved objects resolve nested fields the same way. Thrown errors are
+   * reported in `errors` with the path of the field. An operation resolver (a function keyed by the
+   * operation name) returns the whole data of the operation, it is called with the variables and the request.
+   *
+   * There is no schema: fragments with type condition apply to objects without `__typename`, to objects
+   * of the type, or of a possible type of the `possibleTypes` option. Introspection is not supported.
+   *
+   * @param path the path of the endpoint
+   * @param resolvers the resolvers, keyed by operation type (`Query`, `Mutation`) and field name
+   * @param options the route options and the possible types of interfaces and unions
+   */
+  graphql(path: string, resolvers: GraphQLResolvers, options?: GraphQLOptions): MockApplication;
+
+  /**
+   * Mark the mock server ready, requests are not rejected anymore (see `MockOptions.warmup`).
+   */
+  ready(): void;
+
+  /**
+   * Start warming up again (e.g. simulating a restart), requests are rejected until `ready()` is called
+   * or the given delay (milliseconds or duration string) passed.
+   *
+   * @param delay optional warm-up delay
+   */
+  unready(delay?: number | string): void;
+
+  /**
+   * Returns true if the mock server is ready (not warming up).
+   */
+  isReady(): boolean;
+
+  /**
+   * Generates k6 script code from the recorded requests of the mock server.
+   *
+   * Each recorded request is turned into a ready-to-paste `k6/http` API call, helping to bootstrap
+   * client side scripts from traffic observed by the mock.
+   *
+   * @param path optional output file path
+   * @returns the generated script
+   */
+  exportScript(path?: string): string;
+}
+
+/**
+ * Request information passed to hooks.
+ */
+export interface HookRequest {
+  method: string;
+  path: string;
+  query: Record<string, string>;
+  headers: Record<string, string>;
+  body: string;
+  /** Correlation ID of the request, only set if `correlation` option was set. */
+  requestId?: string;
+}
+
+/**
+ * Request matcher of the request journal queries, the omitted properties match every request.
+ */
+export interface RequestMatcher {
+  /** The HTTP method. */
+  method?: string;
+  /** The path pattern (route parameters and wildcards are supported). */
+  path?: string;
+  /** Only the requests received at or after this time (Date, milliseconds since epoch or RFC 3339 string). */
+  since?: Date | number | string;
+  /** The response status code. */
+  status?: number;
+}
+
+/**
+ * Request recorded by the request journal.
+ */
+export interface JournalRequest extends HookRequest {
+  /** Arrival time in milliseconds since epoch. */
+  time: number;
+  /** The response status code. */
+  status: number;
+  /** Path pattern of the matching route, empty if not matched. */
+  route: string;
+  /** Time spent serving the request in milliseconds. */
+  duration: number;
+  /** The TLS fingerprint of the request's connection, only if the `tls` option was set. */
+  tls?: TLSClient;
+}
+
+/**
+ * Response information passed to the `onResponse` hooks.
+ */
+export interface HookResponse {
+  request: HookRequest;
+  status: number;
+  headers: Record<string, string>;
+  body: string;
+  /** Path pattern of the matching route, empty if not matched. */
+  route: string;
+  /** Time spent serving the request in milliseconds. */
+  duration: number;
+}
+
+/**
+ * Error information passed to the `onError` hooks.
+ */
+export interface HookError {
+  request: { method: string, path: string };
+  /** Path pattern of the route, if the error was thrown by a route handler. */
+  route?: string;
+  error: string;
+}
+
+/**
+ * Common interface of the non-HTTP mock endpoints.
+ */
+export interface MockEndpoint {
+  /** The URL of the mock endpoint's listener. */
+  readonly url: string;
+
+  /** The host:port address of the mock endpoint's listener. */
+  readonly address: string;
+
+  /** Stop listening and close the open connections. */
+  close(): void;
+}
+
+/**
+ * MQTT message, as passed to the handlers and returned by `messages()`.
+ */
+export interface MQTTMessage {
+  /** Identifier of the publishing client, empty for messages published by the broker. */
+  clientId: string;
+  topic: string;
+  payload: string;
+  qos: number;
+  retain: boolean;
+}
+
+/**
+ * Scriptable MQTT broker mock (see `mock.mqtt`).
+ */
+export interface MQTTBroker extends MockEndpoint {
+  /**
+   * Register an event handler. Events: `connect` (the handler may refuse the client by returning false),
+   * `disconnect`, `subscribe` and `publish`.
+   */
+  on(event: "connect" | "disconnect" | "subscribe" | "publish", handler: (event: any) => boolean | void): MQTTBroker;
+
+  /**
+   * Register a handler for the messages published to topics matching the filter (`+` and `#` wildcards are supported).
+   * The handler may return reply message(s) to publish.
+   */
+  topic(
+    filter: string,
+    handler: (msg: MQTTMessage) => Partial<MQTTMessage> | Array<Partial<MQTTMessage>> | void
+  ): MQTTBroker;
+
+  /** Publish a message to the subscribers. Retained messages are delivered to the later subscribers as well. */
+  publish(topic: string, payload: string | ArrayBuffer, options?: { retain?: boolean }): void;
+
+  /** The messages published so far, optionally filtered by topic filter. */
+  messages(filter?: string): MQTTMessage[];
+
+  /** The identifiers of the connected clients. */
+  clients(): string[];
+}
+
+/**
+ * AMQP message, as passed to the handlers and returned by `messages()`.
+ */
+export interface AMQPMessage {
+  exchange: string;
+  routingKey: string;
+  contentType: string;
+  correlationId: string;
+  replyTo: string;
+  body: string;
+}
+
+/**
+ * Scriptable AMQP 0-9-1 broker mock (see `mock.amqp`).
+ */
+export interface AMQPBroker extends MockEndpoint {
+  /** Register an event handler. Events: `connect` (the handler may refuse the client by returning false) and `publish`. */
+  on(event: "connect" | "publish", handler: (event: any) => boolean | void): AMQPBroker;
+
+  /**
+   * Register a handler for the messages published with routing key matching the pattern (`*` and `#` wildcards
+   * are supported). The handler may return a reply (a string body or an object), which is published to the
+   * default exchange with the message's `replyTo` as routing key (unless given) and the message's correlation id.
+   */
+  handle(
+    pattern: string,
+    handler: (msg: AMQPMessage) => string | { body: string | ArrayBuffer, exchange?: string, routingKey?: string, contentType?: string } | void
+  ): AMQPBroker;
+
+  /** Declare an exchange of the given type (direct, topic, fanout or headers). */
+  exchange(name: string, type: string): AMQPBroker;
+
+  /** Declare a queue, optionally bound to exchanges (exchange name to binding key). */
+  queue(name: string, bindings?: Record<string, string>): AMQPBroker;
+
+  /** Publish a message. */
+  publish(
+    exchange: string,
+    routingKey: string,
+    body: string | ArrayBuffer,
+    options?: { contentType?: string, correlationId?: string, replyTo?: string }
+  ): void;
+
+  /** The messages published so far, optionally filtered by routing key pattern. */
+  messages(pattern?: string): AMQPMessage[];
+}
+
+/**
+ * Kafka record, as passed to the handlers and returned by `records()`.
+ */
+export interface KafkaRecord {
+  topic: string;
+  partition: number;
+  offset: number;
+  /** Record timestamp in milliseconds. */
+  timestamp: number;
+  key: string;
+  value: string;
+  headers: Record<string, string>;
+}
+
+/**
+ * Record produced by the script, the partition is selected by the key's hash unless given.
+ */
+export interface KafkaProducerRecord {
+  key?: string | ArrayBuffer;
+  value: string | ArrayBuffer;
+  partition?: number;
+  headers?: Record<string, string | ArrayBuffer>;
+}
+
+/**
+ * Scriptable Kafka broker mock (see `mock.kafka`).
+ */
+export interface KafkaBroker extends MockEndpoint {
+  /** Register an event handler. Events: `produce` (called for each record produced by the clients). */
+  on(event: "produce", handler: (rec: KafkaRecord) => void): KafkaBroker;
+
+  /** Register a handler for the records produced to the topic. The handler may return record(s) to produce. */
+  handle(
+    topic: string,
+    handler: (rec: KafkaRecord) => (KafkaProducerRecord & { topic: string }) | Array<KafkaProducerRecord & { topic: string }> | void
+  ): KafkaBroker;
+
+  /** Create (or recreate empty) a topic. */
+  topic(name: string, options?: { partitions?: number }): KafkaBroker;
+
+  /** Produce a record to the topic, consumers fetching the topic receive it. */
+  produce(topic: string, record: KafkaProducerRecord | string | ArrayBuffer): void;
+
+  /** The records produced so far, optionally filtered by topic. */
+  records(topic?: string): KafkaRecord[];
+}
+
+/**
+ * Reply of a Redis command handler.
+ */
+export type RedisReply = string | number | boolean | ArrayBuffer | null | { status: string } | { error: string } | RedisReply[];
+
+/**
+ * Scriptable Redis server mock (see `mock.redis`).
+ */
+export interface RedisServer extends MockEndpoint {
+  /** Register a handler of the command, it is called with the command's arguments. */
+  command(name: string, handler: (args: string[]) => RedisReply | void): RedisServer;
+
+  /** Register a canned reply of the command. */
+  reply(name: string, value: RedisReply): RedisServer;
+
+  /** Register a canned error reply of the command (e.g. `"READONLY You can't write against a read only replica."`). */
+  error(name: string, message: string): RedisServer;
+
+  /**
+   * Inject latency (in milliseconds, either as a number or as a [min, max] range) into the replies of the command.
+   * The latency of `"*"` applies to all commands without their own latency.
+   */
+  latency(name: string, latency: number | [number, number]): RedisServer;
+
+  /** Set a key in the built-in store. */
+  set(key: string, value: string | ArrayBuffer): RedisServer;
+
+  /** Get a key from the built-in store, null if missing. */
+  get(key: string): string | null;
+
+  /** The commands received so far (command name followed by the arguments), optionally filtered by command name. */
+  received(name?: string): string[][];
+}
+
+/**
+ * Scripted conversation of a TCP mock (see `mock.tcp`).
+ */
+export interface TCPConversation extends MockEndpoint {
+  /** Send data to the client. */
+  send(data: string | ArrayBuffer): TCPConversation;
+
+  /**
+   * Wait until the client sends data matching the pattern (a string is matched literally). The data is
+   * consumed up to the end of the match.
+   */
+  expect(pattern: string | RegExp): TCPConversation;
+
+  /** Send the reply, a function is called with the groups of the last expect step's match. */
+  reply(data: string | ArrayBuffer | ((match: string[]) => string | ArrayBuffer)): TCPConversation;
+
+  /** Send back everything the client sends, until it disconnects. */
+  echo(): TCPConversation;
+
+  /** Close the connection. */
+  hangup(): TCPConversation;
+
+  /** Repeat the steps defined after this call until the client disconnects. */
+  repeat(): TCPConversation;
+
+  /** The data matched by the expect steps so far. */
+  received(): string[];
+}
+
+/**
+ * Datagram received by a UDP mock.
+ */
+export interface UDPDatagram {
+  /** The sender's address (host:port). */
+  from: string;
+  data: string;
+  buffer: ArrayBuffer;
+  size: number;
+}
+
+/**
+ * Scriptable UDP responder mock (see `mock.udp`).
+ */
+export interface UDPServer extends MockEndpoint {
+  /** Register a datagram handler, the returned datagram(s) are sent back to the sender. */
+  handle(
+    handler: (dgram: UDPDatagram) => string | ArrayBuffer | Array<string | ArrayBuffer> | void
+  ): UDPServer;
+
+  /** Register a scripted reply of the datagrams matching the pattern (a string is matched literally). */
+  reply(pattern: string | RegExp, data: string | ArrayBuffer): UDPServer;
+
+  /** Send a datagram to the address (host:port). */
+  send(address: string, data: string | ArrayBuffer): void;
+
+  /** The datagrams received so far. */
+  received(): Array<{ from: string, data: string }>;
+}
+
+/**
+ * Client connection of a mocked WebSocket endpoint (see `MockApplication.ws`).
+ */
+export interface WebSocketConnection {
+  /** The request path of the opening handshake. */
+  path: string;
+  /** The path parameters. */
+  params: Record<string, string>;
+  /** The query parameters (first values). */
+  query: Record<string, string>;
+  /** The request headers of the opening handshake. */
+  headers: Record<string, string>;
+
+  /** Send a text message, or a binary message if data is an ArrayBuffer. */
+  send(data: string | ArrayBuffer): void;
+
+  /**
+   * Register an event handler. Message handlers receive text messages as string and binary messages
+   * as ArrayBuffer, close handlers receive the close code.
+   */
+  on(event: "message", handler: (data: string | ArrayBuffer) => void): WebSocketConnection;
+  on(event: "close", handler: (code: number) => void): WebSocketConnection;
+
+  /** Close the connection with the given code (1000 by default) and reason. */
+  close(code?: number, reason?: string): void;
+}
+
+/**
+ * Raw connection of a tunnel or an upgraded protocol (see `MockApplication.connect` and `MockApplication.upgrade`).
+ */
+export interface StreamConnection {
+  /** The requested host:port of a tunnel. */
+  authority?: string;
+  /** The protocol of an upgraded connection. */
+  protocol?: string;
+  /** The request path of an upgraded connection. */
+  path?: string;
+  /** The path parameters of an upgraded connection. */
+  params?: Record<string, string>;
+  /** The request headers. */
+  headers: Record<string, string>;
+
+  /** Send data to the client. */
+  send(data: string | ArrayBuffer): void;
+
+  /** Register an event handler, data handlers receive the data both as string and ArrayBuffer. */
+  on(event: "data", handler: (data: string, buffer: ArrayBuffer) => void): StreamConnection;
+  on(event: "close", handler: () => void): StreamConnection;
+
+  /** Close the connection. */
+  close(): void;
+}
+
+/**
+ * TLS fingerprint of a client connection (see `MockApplication.tls`).
+ */
+export interface TLSClient {
+  /** The client's address (host:port). */
+  remote: string;
+  /** The time of the handshake in milliseconds since epoch. */
+  time: number;
+  /** The JA3 string (GREASE values removed). */
+  ja3: string;
+  /** The MD5 hash of the JA3 string (hex). */
+  ja3Hash: string;
+  /** The legacy version of the ClientHello. */
+  version: number;
+  ciphers: number[];
+  extensions: number[];
+  curves: number[];
+  /** The EC point formats (dash separated, as in JA3). */
+  pointFormats: string;
+  serverName: string;
+  alpn: string[];
+  supportedVersions: number[];
+  signatureAlgorithms: number[];
+  /** The HTTP/2 fingerprint, only for HTTP/2 connections. */
+  http2?: HTTP2Fingerprint;
+}
+
+/**
+ * HTTP/2 fingerprint of a client: the frames of its connection preface up to the first request.
+ */
+export interface HTTP2Fingerprint {
+  /**
+   * The Akamai fingerprint: settings (id:value separated by ";"), window update increment ("00" if not
+   * sent), PRIORITY frames (stream:exclusive:dependency:weight separated by ",", "0" if none) and the
+   * pseudo header order, separated by "|", e.g. `1:65536;4:6291456|15663105|0|m,a,s,p`.
+   */
+  fingerprint: string;
+  /** The MD5 hash of the fingerprint (hex). */
+  hash: string;
+  /** The SETTINGS parameters by name, e.g. `INITIAL_WINDOW_SIZE`. */
+  settings: Record<string, number>;
+  /** The connection window increment of the WINDOW_UPDATE frame, 0 if not sent. */
+  windowUpdate: number;
+  priorities: string[];
+  /** The first letters of the pseudo headers of the first request, in order. */
+  pseudoHeaders: string[];
+}
+
+/**
+ * State of a health endpoint (see `MockOptions.health`).
+ */
+export type HealthState = "healthy" | "degraded" | "failing";
+
+/**
+ * Resolvers of a GraphQL endpoint (see `MockApplication.graphql`).
+ */
+export interface GraphQLResolvers {
+  Query?: Record<string, any>;
+  Mutation?: Record<string, any>;
+  Subscription?: Record<string, any>;
+
+  /** Operation resolvers, keyed by operation name. */
+  [operationName: string]: ((variables: Record<string, any>, req: Request) => any) | Record<string, any> | undefined;
+}
+
+/**
+ * Options of a GraphQL endpoint (see `MockApplication.graphql`).
+ */
+export interface GraphQLOptions extends RouteOptions {
+  /** The object types of interfaces and unions, e.g. `{ Node: ["User", "Post"] }`. */
+  possibleTypes?: Record<string, string[]>;
+}
+
+/**
+ * Delivery policy of an outbound webhook (see `MockApplication.webhook`).
+ */
+export interface WebhookOptions {
+  /** The HTTP method, `POST` by default. */
+  method?: string;
+  /** Request headers. */
+  headers?: Record<string, string>;
+  /** Maximum number of attempts, 3 by default. */
+  maxAttempts?: number;
+  /** Timeout of an attempt (milliseconds or duration string), 10s by default. */
+  timeout?: number | string;
+  /**
+   * Delay before the retries: a fixed delay or exponential backoff (1s initial delay, doubled after each
+   * attempt by default).
+   */
+  backoff?: number | string | { initial?: number | string, factor?: number, max?: number | string };
+  /** Success criteria: the accepted status codes (any 2xx by default) and a string the response body must contain. */
+  success?: { status?: number | number[], body?: string };
+}
+
+/**
+ * Result of a webhook delivery.
+ */
+export interface WebhookDelivery {
+  url: string;
+  /** True if an attempt met the success criteria. */
+  delivered: boolean;
+  /** The attempts, status is 0 and error is set if no response was received. */
+  attempts: Array<{ time: number, status: number, error: string, duration: number }>;
+  /** Time from the first attempt to the result in milliseconds. */
+  duration: number;
+}
+
+/**
+ * Versioned dataset answering conditional GETs (see `MockApplication.dataset`).
+ */
+export interface Dataset {
+  /**
+   * Increment the version of the dataset, so the next conditional request gets fresh content.
+   * The content is replaced if given.
+   */
+  mutate(content?: string | ArrayBuffer | Record<string, any> | any[]): Dataset;
+
+  /** Returns the current version, entity tag and modification time (Unix milliseconds) of the dataset. */
+  status(): { version: number, etag: string, modified: number };
+}
+
+/**
+ * Stub with call verification (see `MockApplication.stub`).
+ */
+export interface Stub {
+  /**
+   * Set the response of the stub (200 with empty body by default). Non-string bodies are sent as JSON.
+   * Header values may contain placeholders of request values, as in stub files (see `mock.load`),
+   * e.g. `{ headers: { "X-Request-Id": "{{request.headers.X-Request-Id}}" } }`.
+   */
+  returns(response: { status?: number, headers?: Record<string, string>, body?: string | ArrayBuffer | object }): Stub;
+
+  /**
+   * Restrict the stub to the requests of the given tenant (see `tenancy` option of `mock`), the
+   * requests of other tenants are not served and not recorded by the stub.
+   */
+  withTenant(name: string): Stub;
+
+  /** The calls of the stub (the most recent ones), since the last reset. */
+  readonly calls: Array<{
+    time: number,
+    method: string,
+    path: string,
+    params: Record<string, string>,
+    query: Record<string, string>,
+    headers: Record<string, string>,
+    body: string
+  }>;
+
+  /**
+   * Returns true if any of the calls matches the expected request parts. Params, query and headers
+   * must have the given values. String body is matched as substring, object body is compared with the
+   * JSON request body (only the given properties).
+   */
+  calledWith(expected: {
+    params?: Record<string, string>,
+    query?: Record<string, string>,
+    headers?: Record<string, string>,
+    body?: string | object
+  }): boolean;
+
+  /** Clear the recorded calls. */
+  reset(): Stub;
+}
+
+/**
+ * Statistics of a client connection (see `MockApplication.connections`).
+ */
+export interface Connection {
+  /** The identifier of the connection (sequence number). */
+  id: number;
+  /** The client's address (host:port). */
+  remote: string;
+  /** The time the connection was accepted in milliseconds since epoch. */
+  opened: number;
+  /** True if the connection is still open. */
+  open: boolean;
+  /** The lifetime of the connection (so far) in milliseconds. */
+  lifetime: number;
+  /** The number of requests served on the connection. */
+  requests: number;
+  /** The bytes received on the connection (TLS overhead included). */
+  bytesIn: number;
+  /** The bytes sent on the connection (TLS overhead included). */
+  bytesOut: number;
+  /** The requests served on the connection (the ones still in the journal). */
+  log: Array<{ method: string, path: string, status: number, time: number }> | null;
+}
+
+/**
+ * Response passed to the transformers (see `MockApplication.transform`).
+ */
+export interface TransformedResponse {
+  request: { method: string, path: string };
+  status: number;
+  /** Response headers, set a header to null to remove it. */
+  headers: Record<string, string | null>;
+  body: string | ArrayBuffer;
+}
+
+/**
+ * Custom matcher or responder specification (see `MockApplication.extension`).
+ */
+export interface ExtensionSpec {
+  /** The registered name of the matcher or responder. */
+  type: string;
+  /** Configuration properties passed to the registered factory. */
+  [key: string]: any;
+}
+
+/**
+ * Serializable reference of a mock server (see `mock.handle`).
+ */
+export interface MockHandle {
+  /** Unique identifier of the mock server. */
+  id: string;
+  /** The mocked target URL. */
+  target: string;
+  /** The mock server's URL. */
+  url: string;
+  /** The mock server's name (see `name` option). */
+  name: string;
+  /** Token authorizing to stop the mock server. */
+  token: string;
 }
 
 /**
- * Create URL mock definition.
- * 
- * This function will create a new Express.js like web application and pass it to the provided
- * callback function for programming. After mock programming is done, new mock HTTP server will be start.
- * Whan you use http API from mock module, all matching URLs will be directed to this mock server.
- * 
- * You can create as many mock definitions (server) as you want.
- * 
- * You can disable the given mock definition quickly by passing options parameter with `skip` set to true.
- * ```JavaScript
- * mock(
- *   'https://example.com',
- *   app => {
- *     app.get('/', (req, res) => {
- *       res.json({ greeting: 'Hello World!' })
- *     })
- *   },
- *   { skip: true }
- * )
+ * Deprecation signals of routes, see [RFC 8594](https://www.rfc-editor.org/rfc/rfc8594).
+ *
+ * Responses of deprecated routes have `Deprecation` header (`true` or the date of deprecation),
+ * `Sunset` header (if sunset date given) and `Link` headers pointing to the documentation.
+ * Dates can be passed as `Date`, milliseconds since epoch or RFC 3339 string.
+ */
+export interface DeprecationOptions {
+  /** True or the date of deprecation. */
+  deprecated?: boolean | Date | number | string;
+  /** The date when the route becomes unresponsive. */
+  sunset?: Date | number | string;
+  /** URL of the deprecation documentation (`rel="deprecation"` link). */
+  link?: string;
+  /** URL of the sunset documentation (`rel="sunset"` link). */
+  sunsetLink?: string;
+}
+
+/**
+ * Options of a route definition.
+ */
+/**
+ * Route returned by the route definition methods. It is the application too, so definitions can be
+ * chained. The request matchers restrict the route to the requests meeting all of them, routes with
+ * more matchers are tried before the otherwise equivalent routes.
+ *
+ * The `without` matchers are the negations of the `with` matchers, e.g. every GET request without
+ * `Authorization` header (except the health check) is rejected:
+ *
+ * ```js
+ * app.get("/*", (req, res) => res.status(401).end(), { priority: 10 }).withoutHeader("Authorization").withoutPath("/health")
  * ```
- * 
- * Alternatively you can put `.skip` after function name:
- * ```JavaScript
- * mock.skip('https://example.com', app => {
- *  app.get('/', (req, res) => {
- *    res.json({ greeting: 'Hello World!' })
- *  })
- * })
+ *
+ * Wildcard routes are less specific than the others, use `priority` to try them first.
+ *
+ * The body matchers are evaluated on the decoded body: bodies encoded by the `Content-Encoding` (`gzip`,
+ * `deflate`, `base64`) or `Content-Transfer-Encoding: base64` header, and gzip compressed bodies are
+ * decoded. Where `withBodyJson` expects an object or array, string fields holding JSON, base64 encoded
+ * (optionally gzip compressed) JSON are decoded too:
+ *
+ * ```js
+ * // matches {"event": "eyJ0eXBlIjoiY2xpY2sifQ=="}
+ * app.post("/events", handler).withBodyJson({ event: { type: "click" } })
  * ```
- * 
- * @param target the URL or URL prefix to be mocked
- * @param callback function to for defining route definitions for mock server
- * @param options optional flags (`sync`, `skip`)
  */
-export function mock(target: String, callback: (app: Application) => void, options?: MockOptions): void;
+export interface Route extends MockApplication {
+  /** Match requests with the header, with the given value if specified. */
+  withHeader(name: string, value?: string): Route;
+  /** Match requests with the query parameter, with the given value if specified. */
+  withQuery(name: string, value?: string): Route;
+  /** Match requests with body containing the string. */
+  withBody(part: string): Route;
+  /** Match requests with JSON body containing the given properties (nested objects are matched the same way). */
+  withBodyJson(expected: Record<string, any> | any[]): Route;
+  /** Match requests without the header, or with other value if the value is specified. */
+  withoutHeader(name: string, value?: string): Route;
+  /** Match requests without the query parameter, or with other value if the value is specified. */
+  withoutQuery(name: string, value?: string): Route;
+  /** Match requests with body not containing the string. */
+  withoutBody(part: string): Route;
+  /** Match requests with path not matching the path pattern. */
+  withoutPath(pattern: string): Route;
+}
 
 /**
- * Deactivate URL mocking.
- * 
- * This function will remove mock definition associated to given URL and stop the related HTTP server.
- * 
- * @param target the URL or URL prefix of mock definition to be remove
+ * JSON Schemas of the request parts of a route (see `RouteOptions.schema`).
  */
-export function unmock(target: String): void;
+export interface RequestSchema {
+  params?: Record<string, any>;
+  query?: Record<string, any>;
+  body?: Record<string, any>;
+}
 
-// muxpress ------------------------------------------------------------------------
+/**
+ * Typed request parts of a route with request schema (see `Request.context`).
+ */
+export interface RequestContext {
+  params: Record<string, any>;
+  query: Record<string, any>;
+  body?: any;
+}
 
 /**
- * Middleware defines middleware and request handler callback function.
- *
- * @param req the request object
- * @param res the response object
- * @param next calling from middleware enables processing next middleware
+ * A named state machine of stateful routes (see `MockApplication.scenario`).
  */
-export type Middleware = (req: Request, res: Response, next: () => void) => void;
+export interface Scenario {
+  /** The scenario name. */
+  readonly name: string;
+  /**
+   * Returns the object defining the routes of the state. The first declared state is the initial one,
+   * unless it was given to `scenario`.
+   */
+  state(name: string): ScenarioState;
+  /** Returns the current state. */
+  current(): string;
+  /** Moves the scenario to the state. */
+  set(state: string): Scenario;
+  /** Moves the scenario to its initial state. */
+  reset(): Scenario;
+}
 
 /**
- * An application object represents a web application.
- * 
- * The following example starts a server and listens for connections on port 3000.
- * The application responds with a JSON object for requests to the root URL.
- * All other routes are answered with a 404 not found message.
- *
- * In this example, the name of the constructor is `Application`, but the name you use is up to you.
- * 
- * @example
- * const app = new Application()
- *
- * app.get('/', (req, res) => {
- *   res.json({message:"Hello World!"})
- * })
- *
- * app.listen(3000)
+ * Defines the routes served only in a state of a scenario, it is the application too.
  */
-export class Application {
+export interface ScenarioState extends MockApplication {
+  get(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+  head(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+  post(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+  put(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+  patch(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+  delete(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+  options(path: string, ...middleware: Array<Middleware | RouteOptions>): ScenarioRoute;
+}
+
+/**
+ * Route of a scenario state.
+ */
+export interface ScenarioRoute extends Route {
+  /** Moves the scenario to the state when the route is hit. */
+  transitionTo(state: string): ScenarioRoute;
+}
+
+/**
+ * Options shared by the routes of a route group (see `MockApplication.group`).
+ */
+export interface GroupOptions {
+  /** Response latency of the routes, as in `RouteOptions.delay`. */
+  delay?: Delay;
+  /** Response headers set unless the handler sets them. */
+  headers?: Record<string, string>;
+  /** Middleware function(s) run before the route handlers. */
+  middleware?: Middleware | Middleware[];
+}
+
+export interface RouteOptions extends DeprecationOptions {
   /**
-   * Creates a new application instance.
+   * Custom matcher evaluated before the handlers. If it returns false, the next matching route is tried.
+   *
+   * ```js
+   * app.post("/orders", { match: req => req.get("X-Dry-Run") === "true" }, (req, res) => res.status(202).json({}))
+   * ```
    */
-  constructor();
+  match?: (req: Request) => boolean;
 
   /**
-   * Routes HTTP GET requests to the specified path with the specified middleware functions.
+   * JSON Schemas of the request parts, checked before the handlers run. The `params` and `query` schemas
+   * describe objects, their string values are converted to the types of the properties (`integer`,
+   * `number`, `boolean`, `array` for repeated query parameters). The `body` schema is checked against
+   * the parsed JSON body. The typed values are available in `req.context`.
    *
-   * You can provide multiple middleware functions.
+   * Requests violating the schemas are answered with `400 Bad Request` without running the handlers,
+   * the JSON response lists the violations, e.g. `{ "error": "invalid request", "violations": ["query.page: must be integer"] }`.
    *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * The type, enum, const, required, properties, additionalProperties, items, minItems, maxItems,
+   * minimum, maximum, minLength, maxLength, pattern, anyOf and oneOf keywords are supported.
    */
-  get(path: string, ...middleware: Middleware[]): void;
+  schema?: RequestSchema;
 
   /**
-   * Routes HTTP HEAD requests to the specified path with the specified middleware functions.
+   * Priority of the route among the routes matching the same request, higher priority routes are tried
+   * first (0 by default). Routes of the same priority are tried in specificity order (see `get`).
    *
-   * You can provide multiple middleware functions.
-   *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/users/*", (req, res) => res.status(503).end(), { priority: 10 })
+   * ```
    */
-  head(path: string, ...middleware: Middleware[]): void;
+  priority?: number;
 
   /**
-   * Routes HTTP POST requests to the specified path with the specified middleware functions.
+   * Slowloris-style fault: the response header is sent byte by byte (or in chunks of `chunk` bytes) with a delay
+   * (in milliseconds) before each of them, the body is sent right after the header and the connection is closed.
+   * It tests client header-read timeouts distinctly from body timeouts.
    *
-   * You can provide multiple middleware functions.
+   * Handlers can inject the fault into individual responses by the `X-Mock-Slow-Headers` header (delay before
+   * each byte in milliseconds), it is not sent to the client.
    *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/orders", (req, res) => res.json([]), { slowHeaders: { interval: 500, chunk: 4 } })
+   * ```
    */
-  post(path: string, ...middleware: Middleware[]): void;
+  slowHeaders?: number | { interval: number, chunk?: number };
 
   /**
-   * Routes HTTP PUT requests to the specified path with the specified middleware functions.
+   * Link header value(s) of a 103 Early Hints response sent before the final response.
    *
-   * You can provide multiple middleware functions.
+   * Handlers can send early hints in individual responses by the `X-Mock-Early-Hints` header
+   * (Link header value), it is not sent in the final response.
    *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/", (req, res) => res.send(page), { earlyHints: "</style.css>; rel=preload; as=style" })
+   * ```
    */
-  put(path: string, ...middleware: Middleware[]): void;
+  earlyHints?: string | string[];
 
   /**
-   * Routes HTTP PATCH requests to the specified path with the specified middleware functions.
-   *
-   * You can provide multiple middleware functions.
+   * Informational (1xx) responses sent before the final response, in order.
+   * Supported status codes are 100, 102 and 103 (default).
+   */
+  informational?: Array<{ status?: number, headers?: Record<string, string | string[]> }>;
+
+  /**
+   * Simulated latency of the route's responses, applied by the mock server without blocking the event loop.
    *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/slow", handler, { delay: "200ms" })
+   * app.get("/jittery", handler, { delay: { mean: 100, stddev: 30 } })
+   * ```
    */
-  patch(path: string, ...middleware: Middleware[]): void;
+  delay?: Delay;
 
   /**
-   * Routes HTTP `DELETE` requests to the specified path with the specified middleware functions.
+   * Hold the route's responses until a response of a request matching the path pattern was sent on the same
+   * connection (after the request arrived), or the timeout elapsed (5 seconds by default).
    *
-   * You can provide multiple middleware functions.
+   * Multiplexed HTTP/2 streams (`tls` option) can be reordered this way, for probing the head-of-line and
+   * multiplexing handling of clients. HTTP/1.1 connections serve the (pipelined) requests one by one,
+   * so their responses are only delayed until the timeout.
    *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/slow", handler, { after: { path: "/fast/:id", timeout: 1000 } })
+   * ```
    */
-  delete(path: string, ...middleware: Middleware[]): void;
+  after?: Order;
 
   /**
-   * Routes HTTP OPTIONS requests to the specified path with the specified middleware functions.
+   * Limit of the route's concurrent in-flight requests, simulating thread pool limited upstreams.
+   * Excess requests are rejected with 503 (and `Retry-After` header), unless queued: up to `queue`
+   * requests wait at most `timeout` (milliseconds or duration string, unbounded by default) for a free slot.
    *
-   * You can provide multiple middleware functions.
-   *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/reports", handler, { concurrency: { limit: 4, queue: 10, timeout: "2s" } })
+   * ```
    */
-  options(path: string, ...middleware: Middleware[]): void;
+  concurrency?: number | { limit: number, queue?: number, timeout?: number | string };
 
   /**
-   * Uses the specified middleware function or functions.
+   * Bandwidth of the response body, simulating slow networks: bits (`bps`, `kbps`, `Mbps`, `Gbps`)
+   * or bytes (`B/s`, `kB/s`, `MB/s`, `GB/s`) per second, a number means bytes per second.
+   * The body is written by the mock server in paced slices, the event loop is not blocked.
    *
-   * @param path The path for which the middleware function is invoked (string or path pattern)
-   * @param middleware Middleware functions
+   * ```js
+   * app.get("/download", handler, { throttle: "50kbps" })
+   * ```
    */
-  use(path: string, ...middleware: Middleware[]): void;
+  throttle?: string | number;
 
   /**
-   * Mount static web content from given source directory.
+   * The client cohorts served by the route (see `clients` option of `mock`), other clients get the next
+   * matching route.
+   */
+  cohort?: string | string[];
+}
+
+/**
+ * Response ordering constraint: the path pattern of the preceding response or an object with the path
+ * pattern and the timeout (milliseconds or duration string).
+ */
+export type Order = string | { path: string, timeout?: number | string };
+
+/**
+ * Virtual clock of a mock server. Time values can be passed as `Date`, milliseconds since epoch or RFC 3339 string.
+ */
+export interface Clock {
+  /** Returns the current virtual time in milliseconds since epoch. */
+  now(): number;
+  /** Returns the current virtual time as `Date`. */
+  date(): Date;
+  /** Returns the virtual time plus the given offset (in milliseconds) formatted as HTTP date, e.g. for `Retry-After`. */
+  httpDate(offset?: number): string;
+  /** Sets the virtual time, the clock keeps running from there unless frozen. */
+  set(time: Date | number | string): Clock;
+  /** Moves the virtual time by the given milliseconds (negative value moves it backward). */
+  advance(millis: number): Clock;
+  /** Stops the clock, optionally at the given time. */
+  freeze(time?: Date | number | string): Clock;
+  /** Restarts a frozen clock from its current virtual time. */
+  resume(): Clock;
+  /** Configures clock skew (see `skew` option of `mock`), offset in milliseconds. */
+  skew(offset: number, drift?: number): Clock;
+  /** Resets the clock to the wall clock and clears the skew. */
+  reset(): Clock;
+}
+
+/**
+ * Caching proxy emulation of a mock server.
+ */
+export interface Cache {
+  /**
+   * Removes stored responses.
    *
-   * @param path The path where the source will be mounted on
-   * @param docroot The source directory path
+   * @param path the request path to purge, all stored responses are removed if missing
+   * @returns the number of removed responses
    */
-  static(path: string, docroot: string): void;
+  purge(path?: string): number;
 
   /**
-   * Starts the server.
+   * Removes stored responses tagged with the given surrogate key (`Surrogate-Key` response header).
    *
-   * @param port TCP port number, if 0 or missing then random unused port will be allocated
-   * @param callback host name or IP address for listening on, default 127.0.0.1
-   * @returns The instance for fluent/chaining API
+   * @param key the surrogate key
+   * @returns the number of removed responses
    */
-  listen(addr?: string, callback?: () => void): void;
+  purgeKey(key: string): number;
+}
+
+/**
+ * Controller of a simulated region.
+ */
+export interface Region {
+  /** The region name. */
+  name: string;
+  /** Makes the region failing, requests are answered with the given status (503 by default). */
+  down(status?: number): Region;
+  /** Makes the region working again. */
+  up(): Region;
+  /** Changes the latency of the region, in milliseconds (number or `[min, max]` range). */
+  latency(latency: number | [number, number]): Region;
+}
+
+/**
+ * Isolated mock state: key-value store, counters and CRUD resources.
+ */
+export interface State {
+  /** Returns the stored value, undefined if missing. */
+  get(key: string): any;
+  /** Stores the value. */
+  set(key: string, value: any): State;
+  /** Removes the stored value. */
+  delete(key: string): void;
+  /** Returns the keys of stored values. */
+  keys(): string[];
+  /** Increments the named counter (by 1 or by the given delta) and returns its new value. */
+  incr(name: string, delta?: number): number;
+  /** Returns the current value of the named counter. */
+  counter(name: string): number;
+  /** Removes all values, counters and resources. */
+  reset(): void;
+  /** Returns the named CRUD resource. */
+  resource(name: string): Resource;
+}
+
+/**
+ * CRUD resource of the mock state. Items are objects identified by their `id` property.
+ */
+export interface Resource {
+  /** Returns all items ordered by id. */
+  list(): Record<string, any>[];
+  /** Returns the item with the given id, undefined if missing. */
+  get(id: string | number): Record<string, any> | undefined;
+  /** Stores the item, sequential id is assigned if it has no `id` property. */
+  create(item: Record<string, any>): Record<string, any>;
+  /** Merges properties into an existing item, returns undefined if missing. */
+  update(id: string | number, props: Record<string, any>): Record<string, any> | undefined;
+  /** Removes the item, returns false if it was missing. */
+  delete(id: string | number): boolean;
+  /** Removes all items. */
+  clear(): void;
 }
 
 /**
@@ -217,14 +2885,44 @@ export class Application {
  * });
  *
  */
-export interface Request {
+export interface Request<Context extends RequestContext = RequestContext> {
+  /**
+   * The typed request parts of the route with request schema (see `RouteOptions.schema`): the params
+   * and query values converted to the types of the schema, and the validated body. Undefined for routes
+   * without schema.
+   *
+   * @example
+   * type Ctx = { params: { id: number }, query: { page?: number }, body: { qty: number } };
+   *
+   * app.put("/orders/:id", (req: Request<Ctx>, res) => res.json({ id: req.context!.params.id + 1 }), {
+   *   schema: { params: { properties: { id: { type: "integer" } } }, body: { type: "object", required: ["qty"] } },
+   * });
+   */
+  context?: Context;
+
   /**
    * Contains key-value pairs of data submitted in the request body.
    * By default, it is undefined, and is populated when the request
-   * Content-Type is `application/json`.
+   * Content-Type is `application/json` (or a `+json` media type), so handlers don't need `JSON.parse`.
+   *
+   * XML bodies (`application/xml`, `text/xml` or a `+xml` media type) are parsed into nested objects
+   * keyed by the local names of the elements: attributes are prefixed with `-`, the text of elements
+   * having attributes or children is `#text`, repeated elements are arrays and text-only elements are strings.
+   *
+   * Bodies of other media types are decoded by the codec registered for the media type by a Go extension
+   * (see `mock.RegisterCodec`), if any.
+   *
+   * @example
+   * app.post("/orders", (req, res) => res.json({ item: req.body.item }));
+   * app.post("/soap", (req, res) => res.json({ id: req.body.Envelope.Body.order["-id"] }));
    */
   body: Record<string, any> | undefined;
 
+  /**
+   * The raw (unparsed) request body, empty string if the request has no body.
+   */
+  rawBody: string;
+
   /**
    * This property is an object that contains cookies sent by the request.
    */
@@ -252,6 +2950,32 @@ export interface Request {
    */
   protocol: string;
 
+  /**
+   * Contains the name of the simulated region selected for the request, empty string if none
+   * (see `regions` option of `mock`).
+   */
+  region: string;
+
+  /**
+   * Contains the tenant identifier of the request, empty string if none (see `tenancy` option of `mock`).
+   */
+  tenant: string;
+
+  /**
+   * Contains the client identifier of the request, empty string if none (see `clients` option of `mock`).
+   */
+  client: string;
+
+  /**
+   * Contains the cohort of the request's client, empty string if none (see `clients` option of `mock`).
+   */
+  cohort: string;
+
+  /**
+   * The state namespace of the request's tenant (see `tenancy` option of `mock`).
+   */
+  state: State;
+
   /**
    * This property is an object containing a property for each query string parameter in the route.
    *
@@ -326,6 +3050,22 @@ export interface Response {
    */
   html: (body: string) => Response;
 
+  /**
+   * Sends the response template with the `{{ }}` placeholders expanded by the request data:
+   * `{{request.method}}`, `{{request.path}}`, `{{request.params.id}}`, `{{request.query.page}}`,
+   * `{{request.headers['x-api-key']}}` (case insensitive) and `{{request.body.name}}` (nested properties
+   * and array indexes as in JavaScript). String templates are sent as is (see `send`), the string values of
+   * object templates are expanded and the result is sent as JSON. Non-string values are inserted as JSON,
+   * missing values as empty string.
+   *
+   * ```js
+   * app.get("/users/:id", (req, res) => res.template({ id: "{{request.params.id}}", key: "{{request.headers['x-api-key']}}" }))
+   * ```
+   *
+   * @param template the response template
+   */
+  template: (template: string | Record<string, any> | any[]) => Response;
+
   /**
    * Sends a binray response. This method sends a response (with the "application/octet-stream" content-type) that is the body paramter.
    *
@@ -380,4 +3120,87 @@ export interface Response {
    * @param loc the location to redirect
    */
   redirect: (code: number, loc: string) => Response;
+
+  /**
+   * Delays the response by the given latency (see `RouteOptions.delay`), it overrides the route's delay.
+   * The response is delayed by the mock server, the event loop is not blocked.
+   *
+   * @param latency the latency specification
+   */
+  delay: (latency: Delay) => Response;
+
+  /**
+   * Holds the response until a response matching the ordering constraint was sent on the connection
+   * (see `RouteOptions.after`), it overrides the route's ordering constraint.
+   *
+   * @param order the ordering constraint
+   */
+  after: (order: Order) => Response;
+
+  /**
+   * Fails the response at the transport level instead of sending it:
+   *  - `connection-reset`: the connection is closed with TCP RST
+   *  - `empty-response`: the connection is closed without response
+   *  - `malformed-chunk`: the chunked response body has an invalid chunk size
+   *  - `random-garbage`: random bytes are sent instead of HTTP response
+   *
+   * The connection is closed after the fault. HTTP/2 streams are aborted with any fault.
+   *
+   * ```JavaScript
+   * app.get("/flaky", (req, res) => res.fault("connection-reset"));
+   * ```
+   *
+   * @param kind the fault
+   */
+  fault: (kind: TransportFault) => Response;
+
+  /**
+   * Appends a chunk to the streamed response, the chunks are sent by `end`.
+   * Strings and ArrayBuffers are sent as is, other values as JSON lines.
+   *
+   * @param chunk the chunk to append
+   */
+  write: (chunk: string | ArrayBuffer | any) => Response;
+
+  /**
+   * Sends the written chunks (and the optional last chunk) as chunked transfer-encoding response:
+   * the mock server flushes the chunks one by one, waiting `interval` (milliseconds or duration string)
+   * between them. The chunk boundaries are kept unless the server modifies the response body.
+   *
+   * @example
+   * app.get("/events", (req, res) => {
+   *   res.type("text/event-stream")
+   *   res.write("data: 1\n\n")
+   *   res.end("data: 2\n\n", { interval: "500ms" })
+   * })
+   *
+   * @param chunk the last chunk
+   * @param opts the interval between the chunks
+   */
+  end: (chunk?: string | ArrayBuffer | any, opts?: { interval?: number | string }) => Response;
+
+  /**
+   * Sends the items of an array, a generator or another iterable as chunks (see `end`).
+   * The items are collected when the handler runs, they are emitted over time by the mock server.
+   *
+   * @example
+   * app.get("/prices", (req, res) => res.stream(function* () {
+   *   for (let i = 0; i < 5; i++) yield { price: 10 + i }
+   * }(), { interval: 100 }))
+   *
+   * @param items the chunks
+   * @param opts the interval between the chunks
+   */
+  stream: (items: Iterable<string | ArrayBuffer | any>, opts?: { interval?: number | string }) => Response;
 }
+
+/**
+ * Simulated latency: milliseconds or duration string (e.g. `"200ms"`, `"1.5s"`), a `[min, max]` range or
+ * `{ min, max }` object (uniform distribution), or a `{ mean, stddev }` object (normal distribution).
+ */
+export type Delay =
+  | number
+  | string
+  | [number | string, number | string]
+  | { min: number | string, max: number | string }
+  | { mean: number | string, stddev: number | string };
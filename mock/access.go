@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// dashboardTokensEnv is the environment variable holding the access tokens of the dashboard API.
+	dashboardTokensEnv = "K6_MOCK_DASHBOARD_TOKENS"
+	// dashboardCertEnv and dashboardKeyEnv are the environment variables holding the PEM encoded
+	// certificate and key files of the dashboard, it is served over TLS if both are set.
+	dashboardCertEnv = "K6_MOCK_DASHBOARD_CERT"
+	dashboardKeyEnv  = "K6_MOCK_DASHBOARD_KEY"
+
+	scopeEvents  = "events"  // request events (events and stream endpoints)
+	scopeSummary = "summary" // summary endpoint
+	scopeAll     = "*"
+)
+
+var accessScopes = map[string]bool{scopeEvents: true, scopeSummary: true, scopeAll: true} // nolint:gochecknoglobals
+
+// accessControl authenticates the requests of the dashboard API by bearer tokens, each token is
+// granted a set of scopes (capabilities). The scopes are read-only: there is no remote-control API,
+// the mock servers are configured, reset and stopped only by the test script running them.
+type accessControl struct {
+	tokens map[string]map[string]bool
+}
+
+// getAccessControl parses the tokens specification: comma separated tokens, each optionally followed
+// by colon and plus sign separated scopes (e.g. "ci:events+summary,ops"). Tokens without scopes are
+// granted every scope. Without tokens nil is returned, the dashboard is open.
+func getAccessControl(spec string) (*accessControl, error) {
+	access := &accessControl{tokens: make(map[string]map[string]bool)}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		token, list, found := strings.Cut(item, ":")
+		if !found {
+			list = scopeAll
+		}
+
+		scopes := make(map[string]bool)
+
+		for _, scope := range strings.Split(list, "+") {
+			if !accessScopes[scope] {
+				return nil, fmt.Errorf("%w: unknown scope %q of dashboard token (the dashboard API is read-only)",
+					errInvalidArg, scope)
+			}
+
+			scopes[scope] = true
+		}
+
+		if len(token) == 0 {
+			return nil, fmt.Errorf("%w: empty dashboard token", errInvalidArg)
+		}
+
+		access.tokens[token] = scopes
+	}
+
+	if len(access.tokens) == 0 {
+		return nil, nil
+	}
+
+	return access, nil
+}
+
+// credential returns the token of the request: bearer token of the Authorization header or the
+// token query parameter (browsers can't set headers of server-sent event streams).
+func credential(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); len(auth) != 0 {
+		if scheme, token, found := strings.Cut(auth, " "); found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+
+		return ""
+	}
+
+	return r.URL.Query().Get("token")
+}
+
+// scopes returns the scopes of the token, nil if the token is unknown. Tokens are compared in
+// constant time.
+func (a *accessControl) scopes(token string) map[string]bool {
+	var granted map[string]bool
+
+	for known, scopes := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			granted = scopes
+		}
+	}
+
+	return granted
+}
+
+// guard returns the handler allowed only for tokens having the scope, the handler is returned as is
+// without access control. Unauthenticated requests are rejected with 401, unauthorized with 403.
+func (a *accessControl) guard(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		scopes := a.scopes(credential(r))
+
+		if scopes == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="xk6-mock"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		if !scopes[scope] && !scopes[scopeAll] {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="xk6-mock", error="insufficient_scope", scope=%q`, scope))
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// export returns the scopes of the tokens, tokens are not exposed.
+func (a *accessControl) export() []string {
+	var all []string
+
+	for _, scopes := range a.tokens {
+		all = append(all, strings.Join(sortedKeys(scopes), "+"))
+	}
+
+	sort.Strings(all)
+
+	return all
+}
@@ -0,0 +1,963 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// AMQP 0-9-1 frame types, classes and methods used by the mock.
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xce
+	amqpFrameMax       = 131072
+
+	amqpClassConnection = 10
+	amqpClassChannel    = 20
+	amqpClassExchange   = 40
+	amqpClassQueue      = 50
+	amqpClassBasic      = 60
+	amqpClassConfirm    = 85
+
+	amqpAccessRefused = 403
+)
+
+var (
+	amqpProtocolHeader = []byte("AMQP\x00\x00\x09\x01") // nolint:gochecknoglobals
+
+	errAMQPFrame = errors.New("invalid AMQP frame")
+)
+
+// amqpReader decodes method arguments, the first decoding error is kept.
+type amqpReader struct {
+	data []byte
+	err  error
+}
+
+// next returns the next size bytes of the frame. Reading past the end of the frame fails the frame,
+// the size comes from the peer, so only the fixed size fields get a zero value.
+func (r *amqpReader) next(size int) []byte {
+	if r.err != nil || size < 0 || len(r.data) < size {
+		r.err = errAMQPFrame
+
+		if size < 0 || size > 8 {
+			return nil
+		}
+
+		return make([]byte, size)
+	}
+
+	chunk := r.data[:size]
+	r.data = r.data[size:]
+
+	return chunk
+}
+
+func (r *amqpReader) octet() byte {
+	return r.next(1)[0]
+}
+
+func (r *amqpReader) bits() byte {
+	return r.octet()
+}
+
+func (r *amqpReader) short() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *amqpReader) long() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *amqpReader) longlong() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *amqpReader) shortstr() string {
+	return string(r.next(int(r.octet())))
+}
+
+func (r *amqpReader) longstr() string {
+	return string(r.next(int(r.long())))
+}
+
+// table skips the field table, its content is not used by the mock.
+func (r *amqpReader) table() {
+	r.next(int(r.long()))
+}
+
+// amqpWriter encodes method arguments.
+type amqpWriter struct {
+	bytes.Buffer
+}
+
+func (w *amqpWriter) octet(v byte) *amqpWriter {
+	w.WriteByte(v) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *amqpWriter) short(v uint16) *amqpWriter {
+	w.Write(binary.BigEndian.AppendUint16(nil, v)) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *amqpWriter) long(v uint32) *amqpWriter {
+	w.Write(binary.BigEndian.AppendUint32(nil, v)) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *amqpWriter) longlong(v uint64) *amqpWriter {
+	w.Write(binary.BigEndian.AppendUint64(nil, v)) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *amqpWriter) shortstr(v string) *amqpWriter {
+	w.octet(byte(len(v)))
+	w.WriteString(v) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *amqpWriter) longstr(v string) *amqpWriter {
+	w.long(uint32(len(v)))
+	w.WriteString(v) // nolint:errcheck,gosec
+
+	return w
+}
+
+func newAMQPMethod(class, method uint16) *amqpWriter {
+	w := new(amqpWriter)
+
+	return w.short(class).short(method)
+}
+
+func readAMQPFrame(reader *bufio.Reader) (byte, uint16, []byte, error) {
+	var header [7]byte
+
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > amqpFrameMax {
+		return 0, 0, nil, errAMQPFrame
+	}
+
+	payload := make([]byte, size+1)
+
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, 0, nil, err
+	}
+
+	if payload[size] != amqpFrameEnd {
+		return 0, 0, nil, errAMQPFrame
+	}
+
+	return header[0], binary.BigEndian.Uint16(header[1:]), payload[:size], nil
+}
+
+func appendAMQPFrame(data []byte, kind byte, channel uint16, payload []byte) []byte {
+	data = append(data, kind)
+	data = binary.BigEndian.AppendUint16(data, channel)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(payload)))
+	data = append(data, payload...)
+
+	return append(data, amqpFrameEnd)
+}
+
+// amqpTopicMatch reports whether the routing key matches the topic pattern (* matches a word, # zero or more words).
+func amqpTopicMatch(pattern, key string) bool {
+	return amqpWordsMatch(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func amqpWordsMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	if pattern[0] == "#" {
+		for idx := 0; idx <= len(words); idx++ {
+			if amqpWordsMatch(pattern[1:], words[idx:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(words) == 0 || (pattern[0] != "*" && pattern[0] != words[0]) {
+		return false
+	}
+
+	return amqpWordsMatch(pattern[1:], words[1:])
+}
+
+type amqpMessage struct {
+	exchange      string
+	routingKey    string
+	contentType   string
+	correlationID string
+	replyTo       string
+	body          []byte
+	size          uint64
+}
+
+func (msg *amqpMessage) object() map[string]interface{} {
+	return map[string]interface{}{
+		"exchange":      msg.exchange,
+		"routingKey":    msg.routingKey,
+		"contentType":   msg.contentType,
+		"correlationId": msg.correlationID,
+		"replyTo":       msg.replyTo,
+		"body":          string(msg.body),
+	}
+}
+
+// properties encodes the basic properties of the message (content type, correlation id and reply to).
+func (msg *amqpMessage) properties() []byte {
+	var flags uint16
+
+	values := new(amqpWriter)
+
+	if len(msg.contentType) != 0 {
+		flags |= 1 << 15
+		values.shortstr(msg.contentType)
+	}
+
+	if len(msg.correlationID) != 0 {
+		flags |= 1 << 10
+		values.shortstr(msg.correlationID)
+	}
+
+	if len(msg.replyTo) != 0 {
+		flags |= 1 << 9
+		values.shortstr(msg.replyTo)
+	}
+
+	return append(binary.BigEndian.AppendUint16(nil, flags), values.Bytes()...)
+}
+
+// parseProperties decodes the basic properties used by the mock from the content header.
+func (msg *amqpMessage) parseProperties(r *amqpReader) {
+	flags := r.short()
+
+	for bit := 15; bit >= 2; bit-- {
+		if flags&(1<<bit) == 0 {
+			continue
+		}
+
+		switch bit {
+		case 15:
+			msg.contentType = r.shortstr()
+		case 13: // headers
+			r.table()
+		case 12, 11: // delivery mode, priority
+			r.octet()
+		case 10:
+			msg.correlationID = r.shortstr()
+		case 9:
+			msg.replyTo = r.shortstr()
+		case 6: // timestamp
+			r.longlong()
+		default:
+			r.shortstr()
+		}
+	}
+}
+
+type amqpConsumer struct {
+	tag   string
+	queue string
+	conn  *amqpConn
+	ch    *amqpChan
+}
+
+type amqpQueue struct {
+	name      string
+	messages  []*amqpMessage
+	consumers []*amqpConsumer
+	next      int
+}
+
+type amqpBinding struct {
+	exchange string
+	queue    string
+	key      string
+}
+
+type amqpChan struct {
+	id      uint16
+	confirm bool
+	seq     uint64 // publish sequence number in confirm mode
+	tag     uint64 // delivery tag
+	pending *amqpMessage
+}
+
+type amqpConn struct {
+	conn     net.Conn
+	mu       sync.Mutex
+	channels map[uint16]*amqpChan
+}
+
+func (c *amqpConn) send(channel uint16, method *amqpWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.Write(appendAMQPFrame(nil, amqpFrameMethod, channel, method.Bytes())) // nolint:errcheck,gosec
+}
+
+// sendContent sends the method followed by the content header and body frames of the message.
+func (c *amqpConn) sendContent(channel uint16, method *amqpWriter, msg *amqpMessage) {
+	header := new(amqpWriter)
+
+	header.short(amqpClassBasic).short(0).longlong(uint64(len(msg.body)))
+	header.Write(msg.properties()) // nolint:errcheck,gosec
+
+	data := appendAMQPFrame(nil, amqpFrameMethod, channel, method.Bytes())
+	data = appendAMQPFrame(data, amqpFrameHeader, channel, header.Bytes())
+
+	for body := msg.body; len(body) != 0; {
+		size := len(body)
+		if size > amqpFrameMax-8 {
+			size = amqpFrameMax - 8
+		}
+
+		data = appendAMQPFrame(data, amqpFrameBody, channel, body[:size])
+		body = body[size:]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.Write(data) // nolint:errcheck,gosec
+}
+
+type amqpHandler struct {
+	pattern string
+	fn      sobek.Callable
+}
+
+// amqpBroker is a minimal, scriptable AMQP 0-9-1 broker.
+type amqpBroker struct {
+	*endpoint
+	mu        sync.Mutex
+	exchanges map[string]string // type by name
+	queues    map[string]*amqpQueue
+	bindings  []*amqpBinding
+	messages  []*amqpMessage
+	events    map[string][]sobek.Callable
+	handlers  []*amqpHandler
+	seq       int
+}
+
+func (mod *Module) newAMQPBroker(args *mockArgs) *amqpBroker {
+	broker := &amqpBroker{
+		endpoint:  mod.newEndpoint(args, "amqp"),
+		exchanges: map[string]string{"": "direct", "amq.direct": "direct", "amq.topic": "topic", "amq.fanout": "fanout"},
+		queues:    make(map[string]*amqpQueue),
+		events:    make(map[string][]sobek.Callable),
+	}
+
+	broker.mustSet("on", func(event string, fn sobek.Callable) *sobek.Object {
+		broker.mu.Lock()
+		broker.events[event] = append(broker.events[event], fn)
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("handle", func(pattern string, fn sobek.Callable) *sobek.Object {
+		broker.mu.Lock()
+		broker.handlers = append(broker.handlers, &amqpHandler{pattern: pattern, fn: fn})
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("exchange", func(name, kind string) *sobek.Object {
+		broker.mu.Lock()
+		broker.exchanges[name] = kind
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("queue", func(name string, bindings sobek.Value) *sobek.Object {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		broker.declareQueue(name)
+
+		if obj, isObj := bindings.(*sobek.Object); isObj {
+			for _, exchange := range obj.Keys() {
+				broker.bindings = append(broker.bindings,
+					&amqpBinding{exchange: exchange, queue: name, key: obj.Get(exchange).String()})
+			}
+		}
+
+		return broker.obj
+	})
+
+	broker.mustSet("publish", func(exchange, routingKey string, body sobek.Value, options sobek.Value) {
+		msg := &amqpMessage{exchange: exchange, routingKey: routingKey, body: payloadBytes(body)}
+
+		if obj, isObj := options.(*sobek.Object); isObj {
+			msg.contentType = optionalString(obj.Get("contentType"))
+			msg.correlationID = optionalString(obj.Get("correlationId"))
+			msg.replyTo = optionalString(obj.Get("replyTo"))
+		}
+
+		broker.publish(msg)
+	})
+
+	broker.mustSet("messages", func(pattern sobek.Value) []interface{} {
+		filter := optionalString(pattern)
+
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		found := make([]interface{}, 0, len(broker.messages))
+
+		for _, msg := range broker.messages {
+			if len(filter) == 0 || amqpTopicMatch(filter, msg.routingKey) {
+				found = append(found, msg.object())
+			}
+		}
+
+		return found
+	})
+
+	return broker
+}
+
+// optionalString returns the string value, empty string for undefined or null.
+func optionalString(value sobek.Value) string {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return ""
+	}
+
+	return value.String()
+}
+
+// declareQueue returns the queue of the given name, it is created if necessary. It must be called with broker.mu held.
+func (broker *amqpBroker) declareQueue(name string) *amqpQueue {
+	if len(name) == 0 {
+		broker.seq++
+		name = "amq.gen-" + strconv.Itoa(broker.seq)
+	}
+
+	queue, found := broker.queues[name]
+	if !found {
+		queue = &amqpQueue{name: name}
+		broker.queues[name] = queue
+	}
+
+	return queue
+}
+
+// route returns the queues of the message. It must be called with broker.mu held.
+func (broker *amqpBroker) route(msg *amqpMessage) []*amqpQueue {
+	if len(msg.exchange) == 0 {
+		if queue, found := broker.queues[msg.routingKey]; found {
+			return []*amqpQueue{queue}
+		}
+
+		return nil
+	}
+
+	kind := broker.exchanges[msg.exchange]
+
+	var queues []*amqpQueue
+
+	for _, binding := range broker.bindings {
+		if binding.exchange != msg.exchange {
+			continue
+		}
+
+		matched := false
+
+		switch kind {
+		case "fanout", "headers":
+			matched = true
+		case "topic":
+			matched = amqpTopicMatch(binding.key, msg.routingKey)
+		default:
+			matched = binding.key == msg.routingKey
+		}
+
+		if queue, found := broker.queues[binding.queue]; matched && found {
+			queues = append(queues, queue)
+		}
+	}
+
+	return queues
+}
+
+// publish records the message and routes it to the queues, messages are delivered to the consumers round robin.
+func (broker *amqpBroker) publish(msg *amqpMessage) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if len(broker.messages) >= journalLimit {
+		broker.messages = broker.messages[1:]
+	}
+
+	broker.messages = append(broker.messages, msg)
+
+	for _, queue := range broker.route(msg) {
+		if len(queue.consumers) == 0 {
+			queue.messages = append(queue.messages, msg)
+
+			continue
+		}
+
+		queue.next = (queue.next + 1) % len(queue.consumers)
+		broker.deliver(queue.consumers[queue.next], msg)
+	}
+}
+
+// deliver sends the message to the consumer. It must be called with broker.mu held.
+func (broker *amqpBroker) deliver(consumer *amqpConsumer, msg *amqpMessage) {
+	consumer.ch.tag++
+
+	method := newAMQPMethod(amqpClassBasic, 60).
+		shortstr(consumer.tag).longlong(consumer.ch.tag).octet(0).shortstr(msg.exchange).shortstr(msg.routingKey)
+
+	consumer.conn.sendContent(consumer.ch.id, method, msg)
+}
+
+// emit calls the event handlers, it reports false if any of them returned false.
+func (broker *amqpBroker) emit(event string, args ...interface{}) bool {
+	broker.mu.Lock()
+	handlers := broker.events[event]
+	broker.mu.Unlock()
+
+	accepted := true
+
+	for _, fn := range handlers {
+		if result := broker.invoke(fn, args...); result != nil {
+			if ok, isBool := result.Export().(bool); isBool && !ok {
+				accepted = false
+			}
+		}
+	}
+
+	return accepted
+}
+
+// handle calls the handlers matching the routing key of the message. A handler may return a reply
+// (object with body and optional exchange, routingKey and contentType), which is sent to the
+// message's replyTo queue by default, with the message's correlation id.
+func (broker *amqpBroker) handle(msg *amqpMessage) {
+	broker.mu.Lock()
+	handlers := broker.handlers
+	broker.mu.Unlock()
+
+	for _, handler := range handlers {
+		if !amqpTopicMatch(handler.pattern, msg.routingKey) {
+			continue
+		}
+
+		result := broker.invoke(handler.fn, msg.object())
+		if result == nil || sobek.IsUndefined(result) || sobek.IsNull(result) {
+			continue
+		}
+
+		reply := &amqpMessage{routingKey: msg.replyTo, correlationID: msg.correlationID}
+
+		err := broker.call(func() error {
+			obj, isObj := result.(*sobek.Object)
+			if !isObj {
+				reply.body = []byte(result.String())
+
+				return nil
+			}
+
+			reply.body = payloadBytes(obj.Get("body"))
+			reply.exchange = optionalString(obj.Get("exchange"))
+			reply.contentType = optionalString(obj.Get("contentType"))
+
+			if key := optionalString(obj.Get("routingKey")); len(key) != 0 {
+				reply.routingKey = key
+			}
+
+			return nil
+		})
+		if err != nil || len(reply.routingKey) == 0 {
+			continue
+		}
+
+		broker.publish(reply)
+	}
+}
+
+// serveConn serves an AMQP client connection.
+func (broker *amqpBroker) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	header := make([]byte, len(amqpProtocolHeader))
+
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return
+	}
+
+	if !bytes.Equal(header, amqpProtocolHeader) {
+		conn.Write(amqpProtocolHeader) // nolint:errcheck,gosec
+
+		return
+	}
+
+	client := &amqpConn{conn: conn, channels: make(map[uint16]*amqpChan)}
+
+	defer broker.cancelConsumers(client)
+
+	start := newAMQPMethod(amqpClassConnection, 10).octet(0).octet(9).long(0).longstr("PLAIN").longstr("en_US")
+
+	client.send(0, start)
+
+	for {
+		kind, channel, payload, err := readAMQPFrame(reader)
+		if err != nil {
+			return
+		}
+
+		switch kind {
+		case amqpFrameMethod:
+			if !broker.method(client, channel, &amqpReader{data: payload}) {
+				return
+			}
+		case amqpFrameHeader, amqpFrameBody:
+			if !broker.content(client, channel, kind, payload) {
+				return
+			}
+		case amqpFrameHeartbeat:
+			client.mu.Lock()
+			conn.Write(appendAMQPFrame(nil, amqpFrameHeartbeat, 0, nil)) // nolint:errcheck,gosec
+			client.mu.Unlock()
+		}
+	}
+}
+
+// method handles a method frame, it reports false if the connection should be closed.
+func (broker *amqpBroker) method(client *amqpConn, channel uint16, r *amqpReader) bool { // nolint:cyclop,funlen
+	class, method := r.short(), r.short()
+
+	switch uint32(class)<<16 | uint32(method) {
+	case amqpClassConnection<<16 | 11: // start-ok
+		r.table()
+		r.shortstr()
+
+		user := strings.Split(r.longstr(), "\x00")
+
+		username := ""
+		if len(user) > 1 {
+			username = user[1]
+		}
+
+		if !broker.emit("connect", map[string]interface{}{"username": username}) {
+			client.send(0, newAMQPMethod(amqpClassConnection, 50).
+				short(amqpAccessRefused).shortstr("ACCESS_REFUSED").short(0).short(0))
+
+			return false
+		}
+
+		client.send(0, newAMQPMethod(amqpClassConnection, 30).short(2047).long(amqpFrameMax).short(0))
+	case amqpClassConnection<<16 | 31: // tune-ok
+	case amqpClassConnection<<16 | 40: // open
+		client.send(0, newAMQPMethod(amqpClassConnection, 41).shortstr(""))
+	case amqpClassConnection<<16 | 50: // close
+		client.send(0, newAMQPMethod(amqpClassConnection, 51))
+
+		return false
+	case amqpClassConnection<<16 | 51: // close-ok
+		return false
+	case amqpClassChannel<<16 | 10: // open
+		client.mu.Lock()
+		client.channels[channel] = &amqpChan{id: channel}
+		client.mu.Unlock()
+
+		client.send(channel, newAMQPMethod(amqpClassChannel, 11).longstr(""))
+	case amqpClassChannel<<16 | 40: // close
+		broker.closeChannel(client, channel)
+		client.send(channel, newAMQPMethod(amqpClassChannel, 41))
+	case amqpClassExchange<<16 | 10: // declare
+		r.short()
+
+		name, kind := r.shortstr(), r.shortstr()
+		noWait := r.bits()&0x10 != 0
+
+		broker.mu.Lock()
+		broker.exchanges[name] = kind
+		broker.mu.Unlock()
+
+		if !noWait {
+			client.send(channel, newAMQPMethod(amqpClassExchange, 11))
+		}
+	case amqpClassQueue<<16 | 10: // declare
+		r.short()
+
+		name := r.shortstr()
+		noWait := r.bits()&0x10 != 0
+
+		broker.mu.Lock()
+		queue := broker.declareQueue(name)
+		count, consumers := len(queue.messages), len(queue.consumers)
+		broker.mu.Unlock()
+
+		if !noWait {
+			client.send(channel, newAMQPMethod(amqpClassQueue, 11).
+				shortstr(queue.name).long(uint32(count)).long(uint32(consumers)))
+		}
+	case amqpClassQueue<<16 | 20: // bind
+		r.short()
+
+		binding := &amqpBinding{queue: r.shortstr(), exchange: r.shortstr(), key: r.shortstr()}
+		noWait := r.bits()&0x01 != 0
+
+		broker.mu.Lock()
+		broker.bindings = append(broker.bindings, binding)
+		broker.mu.Unlock()
+
+		if !noWait {
+			client.send(channel, newAMQPMethod(amqpClassQueue, 21))
+		}
+	case amqpClassBasic<<16 | 10: // qos
+		client.send(channel, newAMQPMethod(amqpClassBasic, 11))
+	case amqpClassBasic<<16 | 20: // consume
+		r.short()
+
+		queue, tag := r.shortstr(), r.shortstr()
+		noWait := r.bits()&0x08 != 0
+
+		broker.consume(client, channel, queue, tag, noWait)
+	case amqpClassBasic<<16 | 30: // cancel
+		tag := r.shortstr()
+
+		broker.cancel(client, tag)
+
+		if r.bits()&0x01 == 0 {
+			client.send(channel, newAMQPMethod(amqpClassBasic, 31).shortstr(tag))
+		}
+	case amqpClassBasic<<16 | 40: // publish
+		r.short()
+
+		msg := &amqpMessage{exchange: r.shortstr(), routingKey: r.shortstr()}
+
+		client.mu.Lock()
+		if ch, found := client.channels[channel]; found {
+			ch.pending = msg
+		}
+		client.mu.Unlock()
+	case amqpClassBasic<<16 | 70: // get
+		r.short()
+
+		broker.get(client, channel, r.shortstr())
+	case amqpClassBasic<<16 | 80, amqpClassBasic<<16 | 90, amqpClassBasic<<16 | 120: // ack, reject, nack
+	case amqpClassConfirm<<16 | 10: // select
+		client.mu.Lock()
+		if ch, found := client.channels[channel]; found {
+			ch.confirm = true
+		}
+		client.mu.Unlock()
+
+		if r.bits()&0x01 == 0 {
+			client.send(channel, newAMQPMethod(amqpClassConfirm, 11))
+		}
+	default:
+		broker.mod.logger.WithField("target", broker.target).WithField("class", class).WithField("method", method).
+			Debug("unsupported AMQP method")
+	}
+
+	return r.err == nil
+}
+
+// content collects the content header and body frames of the message being published, it reports
+// false if the connection should be closed.
+func (broker *amqpBroker) content(client *amqpConn, channel uint16, kind byte, payload []byte) bool {
+	client.mu.Lock()
+
+	ch, found := client.channels[channel]
+	if !found || ch.pending == nil {
+		client.mu.Unlock()
+
+		return true
+	}
+
+	msg := ch.pending
+
+	if kind == amqpFrameHeader {
+		r := &amqpReader{data: payload}
+
+		r.next(4) // class, weight
+
+		msg.size = r.longlong()
+		msg.parseProperties(r)
+
+		if r.err != nil {
+			ch.pending = nil
+			client.mu.Unlock()
+
+			return false
+		}
+	} else {
+		msg.body = append(msg.body, payload...)
+	}
+
+	if uint64(len(msg.body)) < msg.size {
+		client.mu.Unlock()
+
+		return true
+	}
+
+	ch.pending = nil
+
+	var confirm *amqpWriter
+
+	if ch.confirm {
+		ch.seq++
+		confirm = newAMQPMethod(amqpClassBasic, 80).longlong(ch.seq).octet(0)
+	}
+
+	client.mu.Unlock()
+
+	broker.publish(msg)
+
+	if confirm != nil {
+		client.send(channel, confirm)
+	}
+
+	broker.emit("publish", msg.object())
+	broker.handle(msg)
+
+	return true
+}
+
+// consume registers the consumer and delivers the queued messages to it.
+func (broker *amqpBroker) consume(client *amqpConn, channel uint16, name, tag string, noWait bool) {
+	client.mu.Lock()
+	ch, found := client.channels[channel]
+	client.mu.Unlock()
+
+	if !found {
+		return
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if len(tag) == 0 {
+		broker.seq++
+		tag = "amq.ctag-" + strconv.Itoa(broker.seq)
+	}
+
+	queue := broker.declareQueue(name)
+	consumer := &amqpConsumer{tag: tag, queue: queue.name, conn: client, ch: ch}
+
+	queue.consumers = append(queue.consumers, consumer)
+
+	if !noWait {
+		client.send(channel, newAMQPMethod(amqpClassBasic, 21).shortstr(tag))
+	}
+
+	for _, msg := range queue.messages {
+		broker.deliver(consumer, msg)
+	}
+
+	queue.messages = nil
+}
+
+// get serves the Basic.Get method, it returns the first message of the queue.
+func (broker *amqpBroker) get(client *amqpConn, channel uint16, name string) {
+	client.mu.Lock()
+	ch, found := client.channels[channel]
+	client.mu.Unlock()
+
+	if !found {
+		return
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	queue, found := broker.queues[name]
+	if !found || len(queue.messages) == 0 {
+		client.send(channel, newAMQPMethod(amqpClassBasic, 72).shortstr(""))
+
+		return
+	}
+
+	msg := queue.messages[0]
+	queue.messages = queue.messages[1:]
+
+	ch.tag++
+
+	method := newAMQPMethod(amqpClassBasic, 71).longlong(ch.tag).octet(0).
+		shortstr(msg.exchange).shortstr(msg.routingKey).long(uint32(len(queue.messages)))
+
+	client.sendContent(channel, method, msg)
+}
+
+// removeConsumers removes the consumers selected by fn.
+func (broker *amqpBroker) removeConsumers(fn func(*amqpConsumer) bool) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for _, queue := range broker.queues {
+		kept := queue.consumers[:0]
+
+		for _, consumer := range queue.consumers {
+			if !fn(consumer) {
+				kept = append(kept, consumer)
+			}
+		}
+
+		queue.consumers = kept
+	}
+}
+
+func (broker *amqpBroker) cancel(client *amqpConn, tag string) {
+	broker.removeConsumers(func(c *amqpConsumer) bool { return c.conn == client && c.tag == tag })
+}
+
+func (broker *amqpBroker) closeChannel(client *amqpConn, channel uint16) {
+	broker.removeConsumers(func(c *amqpConsumer) bool { return c.conn == client && c.ch.id == channel })
+
+	client.mu.Lock()
+	delete(client.channels, channel)
+	client.mu.Unlock()
+}
+
+func (broker *amqpBroker) cancelConsumers(client *amqpConn) {
+	broker.removeConsumers(func(c *amqpConsumer) bool { return c.conn == client })
+}
+
+// amqp starts an AMQP 0-9-1 broker mock of the target (e.g. amqp://rabbitmq.example.com:5672).
+func (mod *Module) amqp(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+	broker := mod.newAMQPBroker(args)
+
+	return mod.startEndpoint(args, broker.endpoint, broker.serveConn)
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAMQPTopicMatch(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, amqpTopicMatch("orders.*", "orders.created"))
+	assert.True(t, amqpTopicMatch("orders.#", "orders"))
+	assert.True(t, amqpTopicMatch("orders.#", "orders.eu.created"))
+	assert.True(t, amqpTopicMatch("#.created", "orders.eu.created"))
+	assert.False(t, amqpTopicMatch("orders.*", "orders.eu.created"))
+	assert.False(t, amqpTopicMatch("orders.*", "orders"))
+}
+
+func TestAMQPProperties(t *testing.T) {
+	t.Parallel()
+
+	msg := &amqpMessage{contentType: "application/json", correlationID: "42", replyTo: "amq.gen-1"}
+
+	parsed := new(amqpMessage)
+	parsed.parseProperties(&amqpReader{data: msg.properties()})
+
+	assert.Equal(t, msg, parsed)
+}
+
+func TestAMQPFrame(t *testing.T) {
+	t.Parallel()
+
+	data := appendAMQPFrame(nil, amqpFrameMethod, 1, newAMQPMethod(amqpClassChannel, 10).shortstr("").Bytes())
+
+	kind, channel, payload, err := readAMQPFrame(bufio.NewReader(bytes.NewReader(data)))
+
+	require.NoError(t, err)
+	assert.Equal(t, byte(amqpFrameMethod), kind)
+	assert.Equal(t, uint16(1), channel)
+
+	r := &amqpReader{data: payload}
+
+	assert.Equal(t, uint16(amqpClassChannel), r.short())
+	assert.Equal(t, uint16(10), r.short())
+	assert.Empty(t, r.shortstr())
+	assert.NoError(t, r.err)
+
+	r.long()
+
+	assert.ErrorIs(t, r.err, errAMQPFrame)
+
+	r = &amqpReader{data: []byte{0xff, 0xff, 0xff, 0xff, 'x'}}
+
+	assert.Empty(t, r.longstr(), "string longer than the frame")
+	assert.ErrorIs(t, r.err, errAMQPFrame)
+	assert.Zero(t, r.longlong())
+
+	data[len(data)-1] = 0
+
+	_, _, _, err = readAMQPFrame(bufio.NewReader(bytes.NewReader(data)))
+
+	assert.ErrorIs(t, err, errAMQPFrame)
+}
+
+func TestAMQPBroker(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock.amqp("amqp://rabbitmq.example.com:5672", broker => {
+	broker.queue("orders")
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(helper.module.lookup["amqp://rabbitmq.example.com:5672"], "amqp://"))
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	reader := bufio.NewReader(conn)
+
+	expect := func(class, method uint16) *amqpReader {
+		t.Helper()
+
+		_, _, payload, err := readAMQPFrame(reader)
+
+		require.NoError(t, err)
+
+		r := &amqpReader{data: payload}
+
+		require.Equal(t, class, r.short())
+		require.Equal(t, method, r.short())
+
+		return r
+	}
+
+	send := func(channel uint16, method *amqpWriter) {
+		_, err := conn.Write(appendAMQPFrame(nil, amqpFrameMethod, channel, method.Bytes()))
+
+		require.NoError(t, err)
+	}
+
+	_, err = conn.Write(amqpProtocolHeader)
+
+	require.NoError(t, err)
+
+	expect(amqpClassConnection, 10)
+	send(0, newAMQPMethod(amqpClassConnection, 11).long(0).shortstr("PLAIN").longstr("\x00guest\x00guest").shortstr("en_US"))
+	expect(amqpClassConnection, 30)
+	send(0, newAMQPMethod(amqpClassConnection, 31).short(2047).long(amqpFrameMax).short(0))
+	send(0, newAMQPMethod(amqpClassConnection, 40).shortstr("/").shortstr("").octet(0))
+	expect(amqpClassConnection, 41)
+	send(1, newAMQPMethod(amqpClassChannel, 10).shortstr(""))
+	expect(amqpClassChannel, 11)
+
+	msg := &amqpMessage{contentType: "text/plain", body: []byte("order 42")}
+	header := newAMQPMethod(amqpClassBasic, 0).longlong(uint64(len(msg.body)))
+	header.Write(msg.properties()) // nolint:errcheck
+
+	data := appendAMQPFrame(nil, amqpFrameMethod, 1,
+		newAMQPMethod(amqpClassBasic, 40).short(0).shortstr("").shortstr("orders").octet(0).Bytes())
+	data = appendAMQPFrame(data, amqpFrameHeader, 1, header.Bytes())
+	data = appendAMQPFrame(data, amqpFrameBody, 1, msg.body)
+
+	_, err = conn.Write(data)
+
+	require.NoError(t, err)
+
+	send(1, newAMQPMethod(amqpClassBasic, 20).short(0).shortstr("orders").shortstr("ctag").octet(0).long(0))
+	expect(amqpClassBasic, 21)
+
+	deliver := expect(amqpClassBasic, 60)
+
+	assert.Equal(t, "ctag", deliver.shortstr())
+
+	_, _, _, err = readAMQPFrame(reader) // content header
+
+	require.NoError(t, err)
+
+	_, _, body, err := readAMQPFrame(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, "order 42", string(body))
+}
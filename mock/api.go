@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// negotiation selects the API version of requests by Accept header. Version is given either as
+// media type parameter (application/json; version=2) or in vendor media type (application/vnd.example.v2+json).
+type negotiation struct {
+	mu       sync.Mutex
+	media    string // vendor media type without version, e.g. application/vnd.example+json
+	versions []string
+	def      string
+}
+
+// versionNumber returns the version without "v" prefix, e.g. "2" for "v2".
+func versionNumber(version string) string {
+	return strings.TrimPrefix(strings.ToLower(version), "v")
+}
+
+// vendorType returns the vendor media type of the given version, e.g. application/vnd.example.v2+json.
+func (n *negotiation) vendorType(version string) string {
+	base, suffix, found := strings.Cut(n.media, "+")
+	if !found {
+		return n.media + "." + version
+	}
+
+	return base + "." + version + "+" + suffix
+}
+
+func (n *negotiation) register(version string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !contains(n.versions, version) {
+		n.versions = append(n.versions, version)
+	}
+
+	if len(n.def) == 0 {
+		n.def = version
+	}
+}
+
+// negotiate returns the API version requested by the Accept header, the default version if none.
+func (n *negotiation) negotiate(accept string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		for _, version := range n.versions {
+			if value, found := params["version"]; found && versionNumber(value) == versionNumber(version) {
+				return version
+			}
+
+			if value, found := params["v"]; found && versionNumber(value) == versionNumber(version) {
+				return version
+			}
+
+			if len(n.media) != 0 && mediaType == n.vendorType(version) {
+				return version
+			}
+		}
+	}
+
+	return n.def
+}
+
+// contentType returns the decorator setting the versioned Content-Type of responses.
+func (n *negotiation) contentType(version string) func(http.Header) {
+	return func(header http.Header) {
+		if len(n.media) != 0 {
+			header.Set("Content-Type", n.vendorType(version))
+
+			return
+		}
+
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil {
+			mediaType, params = "application/json", map[string]string{}
+		}
+
+		params["version"] = versionNumber(version)
+
+		header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	}
+}
+
+// decorate applies the decorators of the route selected by the dispatcher on the response header.
+// Cookies of all responses are rewritten if enabled, the default headers of the server are set on all
+// responses not setting them.
+func (srv *server) decorate(control, header http.Header) {
+	if srv.cookies != nil {
+		srv.cookies.rewrite(header)
+	}
+
+	if id, err := strconv.Atoi(control.Get(headerRoute)); err == nil {
+		for _, decorator := range srv.router.decorators(id) {
+			decorator(header)
+		}
+	}
+
+	if defaults := srv.defaults(); defaults != nil {
+		setMissing(header, defaults.headers)
+	}
+}
+
+// wrapAPI adds the api method to the application. Routes defined for an API version are registered
+// both with version path prefix (/v1/items) and without it, selected by Accept header.
+func (srv *server) wrapAPI() {
+	srv.mustSet("api", func(versions map[string]sobek.Callable, opts sobek.Value) sobek.Value {
+		if srv.api == nil {
+			srv.api = new(negotiation)
+		}
+
+		if obj, isObj := opts.(*sobek.Object); isObj {
+			if v := obj.Get("media"); v != nil && !sobek.IsUndefined(v) {
+				srv.api.media = v.String()
+			}
+
+			if v := obj.Get("default"); v != nil && !sobek.IsUndefined(v) {
+				srv.api.def = v.String()
+			}
+		}
+
+		for _, version := range sortedKeys(versions) {
+			srv.api.register(version)
+			srv.defineAPI(version, versions[version])
+		}
+
+		return srv.app
+	})
+}
+
+func (srv *server) defineAPI(version string, callback sobek.Callable) {
+	decorator := srv.api.contentType(version)
+
+	define := func() {
+		if _, err := callback(sobek.Undefined(), srv.app); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	// The routes are negotiated by the Accept header, their twins are served under the version prefix.
+	srv.router.fork(func(r *route) {
+		r.api = version
+		r.decorators = append(r.decorators, decorator)
+	}, func(r *route) {
+		r.pattern = "/" + version + "/" + strings.TrimPrefix(r.pattern, "/")
+		r.decorators = append(r.decorators, decorator)
+	}, define)
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiation(t *testing.T) {
+	t.Parallel()
+
+	n := &negotiation{media: "application/vnd.example+json"}
+
+	n.register("v1")
+	n.register("v2")
+
+	assert.Equal(t, "v1", n.negotiate(""))
+	assert.Equal(t, "v1", n.negotiate("application/json"))
+	assert.Equal(t, "v2", n.negotiate("application/json; version=2"))
+	assert.Equal(t, "v2", n.negotiate("text/html, application/vnd.example.v2+json"))
+	assert.Equal(t, "v1", n.negotiate("application/vnd.example.v3+json"))
+
+	header := http.Header{"Content-Type": {"application/json"}}
+
+	n.contentType("v2")(header)
+
+	assert.Equal(t, "application/vnd.example.v2+json", header.Get("Content-Type"))
+
+	n.media = ""
+	header.Set("Content-Type", "application/json; charset=utf-8")
+
+	n.contentType("v2")(header)
+
+	assert.Equal(t, "application/json; charset=utf-8; version=2", header.Get("Content-Type"))
+}
+
+func TestRouterAPI(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+	n := new(negotiation)
+
+	n.register("v1")
+	n.register("v2")
+
+	for _, version := range []string{"v1", "v2"} {
+		version := version
+
+		define := func() { rt.add(http.MethodGet, "/items", nil) }
+
+		rt.with(func(r *route) { r.pattern = "/" + version + r.pattern }, define)
+		rt.with(func(r *route) { r.api = version }, define)
+	}
+
+	routes := rt.candidates(http.MethodGet, "/v2/items", selector{api: "v1"})
+
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "/v2/items", routes[0].pattern)
+
+	routes = rt.candidates(http.MethodGet, "/items", selector{api: n.negotiate("application/json; v=2")})
+
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "v2", routes[0].api)
+}
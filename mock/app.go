@@ -0,0 +1,568 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	dispatchPath = "/__mock__"
+
+	headerMethod = "X-Mock-Method"
+	headerPath   = "X-Mock-Path"
+	headerRoutes = "X-Mock-Routes"
+	headerRoute  = "X-Mock-Route"
+)
+
+// requestSymbol keys the request object of a routed response, it is used by the template method.
+var requestSymbol = sobek.NewSymbol("mock.request") // nolint:gochecknoglobals
+
+// chunksSymbol keys the chunks written to a response object, see chunksOf.
+var chunksSymbol = sobek.NewSymbol("mock.chunks") // nolint:gochecknoglobals
+
+var routeMethods = map[string]string{
+	"get":     http.MethodGet,
+	"head":    http.MethodHead,
+	"post":    http.MethodPost,
+	"put":     http.MethodPut,
+	"patch":   http.MethodPatch,
+	"delete":  http.MethodDelete,
+	"options": http.MethodOptions,
+}
+
+// wrapApplication replaces route definition methods of the muxpress application,
+// so routes are registered into the server's own router.
+func (srv *server) wrapApplication() {
+	post, ok := sobek.AssertFunction(srv.app.Get("post"))
+	if !ok {
+		srv.mod.throwf("missing post method", errInvalidArg)
+	}
+
+	srv.post = post
+
+	for name, method := range routeMethods {
+		srv.wrapRoute(name, method)
+	}
+
+	srv.wrapUse()
+	srv.wrapGroup()
+	srv.wrapScenarios()
+	srv.wrapStatic()
+	srv.wrapVersions()
+	srv.wrapAPI()
+	srv.wrapDeprecate()
+	srv.wrapHooks()
+	srv.wrapExtensions()
+	srv.wrapTransform()
+	srv.wrapWS()
+	srv.wrapTunnels()
+	srv.wrapRecording()
+	srv.wrapSpies()
+	srv.wrapRequests()
+	srv.wrapDatasets()
+	srv.wrapWebhooks()
+	srv.wrapGraphQL()
+	srv.wrapWarmup()
+
+	srv.mustSet("clock", srv.clockObject())
+
+	if srv.cache != nil {
+		srv.mustSet("cache", srv.cacheObject())
+	}
+
+	if srv.retries != nil {
+		srv.mustSet("retryStorms", srv.retries.export)
+		srv.mustSet("retryClients", srv.retries.exportClients)
+	}
+
+	if srv.tls != nil {
+		srv.mustSet("tls", srv.tlsObject())
+	}
+
+	if srv.health != nil {
+		srv.mustSet("health", srv.healthObject())
+	}
+
+	if srv.opts.clients != nil {
+		srv.mustSet("cohortOf", srv.opts.clients.cohortOf)
+	}
+
+	srv.mustSet("limits", srv.limits)
+	srv.mustSet("connections", func() []interface{} { return srv.conns.export(srv.journal.snapshot()) })
+	srv.mustSet("region", srv.regionObject)
+	srv.mustSet("state", func(tenant string) *sobek.Object { return srv.stateObject(srv.state.tenant(tenant)) })
+	srv.mustSet("tenants", srv.state.names)
+
+	srv.mustSet("exportHAR", func(filename string) {
+		if err := srv.exportHAR(filename); err != nil {
+			srv.mod.throw(err)
+		}
+	})
+
+	srv.mustSet("exportStubs", func(filename string) {
+		if err := srv.exportStubs(filename); err != nil {
+			srv.mod.throw(err)
+		}
+	})
+
+	srv.mustSet("exportScript", func(filename string) string {
+		script, err := srv.exportScript(filename)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		return script
+	})
+}
+
+func (srv *server) mustSet(name string, value interface{}) {
+	if err := srv.app.Set(name, value); err != nil {
+		srv.mod.throw(err)
+	}
+}
+
+func (srv *server) handlers(args []sobek.Value) []sobek.Callable {
+	handlers := make([]sobek.Callable, 0, len(args))
+
+	for _, arg := range args {
+		if fn, ok := sobek.AssertFunction(arg); ok {
+			handlers = append(handlers, fn)
+		}
+	}
+
+	return handlers
+}
+
+func (srv *server) wrapRoute(name, method string) {
+	srv.mustSet(name, func(call sobek.FunctionCall) sobek.Value {
+		return srv.routeObject(srv.defineRoute(name, method, call.Arguments))
+	})
+}
+
+// defineRoute registers the route defined by the arguments of a route definition method.
+func (srv *server) defineRoute(name, method string, args []sobek.Value) *route {
+	if len(args) == 0 {
+		srv.mod.throwf("missing path for %s route", errInvalidArg, name)
+	}
+
+	path := args[0].String()
+
+	if err := checkPath(path); err != nil {
+		srv.mod.throwf("invalid parameter constraint of %s route: %s", errInvalidArg, name, err)
+	}
+
+	r := srv.router.add(method, path, srv.handlers(args[1:]))
+
+	srv.routeOptions(r, args[1:])
+
+	return r
+}
+
+// routeOptions applies the route options passed as object argument(s) of a route definition method.
+func (srv *server) routeOptions(r *route, args []sobek.Value) {
+	for _, arg := range args {
+		obj, isObj := arg.(*sobek.Object)
+		if !isObj {
+			continue
+		}
+
+		if _, isFunc := sobek.AssertFunction(arg); isFunc {
+			continue
+		}
+
+		if fn, isFunc := sobek.AssertFunction(obj.Get("match")); isFunc {
+			srv.router.update(r, func(r *route) { r.predicate = fn })
+		}
+
+		if slow := getSlowHeaders(obj.Get("slowHeaders")); slow != nil {
+			srv.router.update(r, func(r *route) { r.slowHeaders = slow })
+		}
+
+		if v := obj.Get("delay"); v != nil && !sobek.IsUndefined(v) {
+			d := getDelay(v.Export())
+			if d == nil {
+				d = new(delay) // no latency, the default delay of the server is not applied
+			}
+
+			srv.router.update(r, func(r *route) { r.delay = d })
+		}
+
+		if v := obj.Get("throttle"); v != nil {
+			if t := getThrottle(v.Export()); t != nil {
+				srv.router.update(r, func(r *route) { r.throttle = t })
+			}
+		}
+
+		if v := obj.Get("schema"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			schema, err := getRequestSchema(v.Export())
+			if err != nil {
+				srv.mod.throw(err)
+			}
+
+			srv.router.update(r, func(r *route) { r.schema = schema })
+		}
+
+		if v := obj.Get("priority"); v != nil {
+			if priority, ok := toFloat(v.Export()); ok {
+				srv.router.prioritize(r, int(priority))
+			}
+		}
+
+		if v := obj.Get("concurrency"); v != nil {
+			if limit := getConcurrency(v.Export()); limit != nil {
+				srv.router.update(r, func(r *route) { r.limit = limit })
+			}
+		}
+
+		if v := obj.Get("after"); v != nil {
+			if spec := getOrder(v.Export()); spec != nil {
+				srv.router.update(r, func(r *route) { r.after = spec })
+			}
+		}
+
+		if v := obj.Get("cohort"); v != nil {
+			if cohorts := getCohorts(v.Export()); len(cohorts) != 0 {
+				srv.router.update(r, func(r *route) { r.cohorts = cohorts })
+			}
+		}
+
+		if responses := getInformational(obj); len(responses) != 0 {
+			srv.router.update(r, func(r *route) { r.informational = responses })
+		}
+
+		if dep := srv.mod.getDeprecation(obj); dep != nil {
+			srv.router.update(r, func(r *route) { r.decorators = append(r.decorators, dep.decorator()) })
+		}
+	}
+}
+
+func (srv *server) wrapUse() {
+	srv.mustSet("use", func(call sobek.FunctionCall) sobek.Value {
+		path := "/"
+		args := call.Arguments
+
+		if len(args) != 0 {
+			if _, isFunc := sobek.AssertFunction(args[0]); !isFunc {
+				path = args[0].String()
+				args = args[1:]
+			}
+		}
+
+		if err := checkPath(path); err != nil {
+			srv.mod.throwf("invalid parameter constraint of middleware: %s", errInvalidArg, err)
+		}
+
+		srv.router.use(path, srv.handlers(args))
+
+		return srv.app
+	})
+}
+
+func (srv *server) wrapStatic() {
+	static, ok := sobek.AssertFunction(srv.app.Get("static"))
+	if !ok {
+		return
+	}
+
+	srv.mustSet("static", func(call sobek.FunctionCall) sobek.Value {
+		if _, err := static(srv.app, call.Arguments...); err != nil {
+			srv.mod.throw(err)
+		}
+
+		srv.router.addStatic(call.Argument(0).String())
+
+		return srv.app
+	})
+}
+
+// mount registers the dispatcher route into the muxpress application.
+// All routed requests are forwarded to this single route by the server.
+func (srv *server) mount() error {
+	runtime := srv.mod.runtime()
+
+	srv.bindMethods()
+
+	_, err := srv.post(srv.app, runtime.ToValue(dispatchPath), runtime.ToValue(srv.dispatch))
+
+	return err
+}
+
+func (srv *server) header(req *sobek.Object, name string) string {
+	get, ok := sobek.AssertFunction(req.Get("get"))
+	if !ok {
+		return ""
+	}
+
+	value, err := get(req, srv.mod.runtime().ToValue(name))
+	if err != nil || value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return ""
+	}
+
+	return value.String()
+}
+
+// boundMethod is a method added to the response objects.
+type boundMethod struct {
+	name  string
+	value sobek.Value
+}
+
+// bindMethods creates the methods added to the response objects by the server. They are created once
+// per server and find the response by this, so dispatching a request doesn't allocate closures. The
+// request state of the methods is kept on the response by symbols (see requestSymbol and chunksSymbol).
+func (srv *server) bindMethods() {
+	runtime := srv.mod.runtime()
+
+	srv.methods = []boundMethod{
+		{name: "delay", value: runtime.ToValue(srv.delayMethod)},
+		{name: "after", value: runtime.ToValue(srv.afterMethod)},
+		{name: "write", value: runtime.ToValue(srv.writeMethod)},
+		{name: "end", value: runtime.ToValue(srv.endMethod)},
+		{name: "stream", value: runtime.ToValue(srv.streamMethod)},
+		{name: "fault", value: runtime.ToValue(srv.faultMethod)},
+	}
+
+	srv.template = runtime.ToValue(srv.templateMethod)
+}
+
+// bindResponse adds the response methods of the server to the response object. Routed responses
+// (with request) get the template method too.
+func (srv *server) bindResponse(req, res *sobek.Object) {
+	for _, method := range srv.methods {
+		if err := res.Set(method.name, method.value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	if req == nil {
+		return
+	}
+
+	if err := res.SetSymbol(requestSymbol, req); err != nil {
+		srv.mod.throw(err)
+	}
+
+	if err := res.Set("template", srv.template); err != nil {
+		srv.mod.throw(err)
+	}
+}
+
+// dispatch routes the request forwarded by the server to the first candidate route accepting it. The
+// request object of the handlers is created once, only its params are changed between the candidates.
+func (srv *server) dispatch(call sobek.FunctionCall) sobek.Value {
+	runtime := srv.mod.runtime()
+
+	req := call.Argument(0).ToObject(runtime)
+	res := call.Argument(1).ToObject(runtime)
+
+	method := srv.header(req, headerMethod)
+	path := srv.header(req, headerPath)
+	ids := srv.header(req, headerRoutes)
+	cohort := srv.header(req, headerCohort)
+
+	var child *sobek.Object
+
+	for rest := ids; len(rest) != 0; {
+		var id string
+
+		id, rest, _ = strings.Cut(rest, ",")
+
+		num, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+
+		target := srv.router.get(num)
+		if target == nil {
+			continue
+		}
+
+		params, ok := target.match(path)
+		if !ok {
+			continue
+		}
+
+		if !target.admits(cohort) {
+			continue
+		}
+
+		if child == nil {
+			child = newRequest(runtime, req, srv.props(req, method, path, params))
+		} else if err := child.Set("params", params); err != nil {
+			srv.mod.throw(err)
+		}
+
+		if !srv.accepts(target, child) {
+			continue
+		}
+
+		srv.set(res, headerRoute, strconv.Itoa(target.id))
+		srv.bindResponse(child, res)
+
+		if !srv.validated(target, params, child, res) {
+			return sobek.Undefined()
+		}
+
+		srv.transition(target)
+
+		srv.serve(target, srv.router.chain(target, path), child, res)
+
+		return sobek.Undefined()
+	}
+
+	if len(ids) == 0 {
+		srv.fallback(req, res, method, path)
+
+		return sobek.Undefined()
+	}
+
+	srv.notFound(res)
+
+	return sobek.Undefined()
+}
+
+// props returns the properties of the request object passed to the handlers.
+func (srv *server) props(req *sobek.Object, method, path string, params map[string]string) map[string]interface{} {
+	tenant := srv.tenant(req)
+
+	props := map[string]interface{}{
+		"method": method,
+		"path":   path,
+		"params": params,
+		"region": srv.header(req, headerRegion),
+		"tenant": tenant,
+		"state":  srv.stateObject(srv.state.tenant(tenant)),
+		"client": srv.header(req, headerClient),
+		"cohort": srv.header(req, headerCohort),
+	}
+
+	if raw, found := srv.bodies.get(srv.header(req, headerBody)); found {
+		for name, value := range bodyProps(srv.header(req, "Content-Type"), raw) {
+			props[name] = value
+		}
+	}
+
+	return props
+}
+
+// fallback runs the path matching middlewares for the request not matched by any route. If none of
+// them responds, the request is reported as unmatched and answered with 404 Not Found.
+func (srv *server) fallback(req, res *sobek.Object, method, path string) {
+	runtime := srv.mod.runtime()
+	child := newRequest(runtime, req, srv.props(req, method, path, map[string]string{}))
+
+	chain := srv.router.fallback(path, srv.header(req, headerServedVersion))
+	chain = append(chain, func(sobek.Value, ...sobek.Value) (sobek.Value, error) {
+		srv.report(method, path)
+		srv.notFound(res)
+
+		return sobek.Undefined(), nil
+	})
+
+	srv.bindResponse(nil, res)
+
+	srv.serve(&route{method: method}, chain, child, res)
+}
+
+// accepts evaluates the custom matcher of the route, routes without matcher accept every request.
+func (srv *server) accepts(target *route, req *sobek.Object) bool {
+	if target.predicate == nil {
+		return true
+	}
+
+	result, err := target.predicate(sobek.Undefined(), req)
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	return result.ToBoolean()
+}
+
+// newRequest returns a request object inheriting from req, with the given properties overridden.
+func newRequest(runtime *sobek.Runtime, req *sobek.Object, props map[string]interface{}) *sobek.Object {
+	obj := runtime.CreateObject(req)
+
+	for name, value := range props {
+		obj.DefineDataProperty(name, runtime.ToValue(value), sobek.FLAG_TRUE, sobek.FLAG_TRUE, sobek.FLAG_TRUE) // nolint:errcheck
+	}
+
+	return obj
+}
+
+// serve runs the handler chain of the target route, each handler is called with the next function
+// continuing the chain. A handler responding without calling next ends the chain.
+func (srv *server) serve(target *route, chain []sobek.Callable, req, res *sobek.Object) {
+	runtime := srv.mod.runtime()
+
+	var next func(sobek.FunctionCall) sobek.Value
+
+	next = func(_ sobek.FunctionCall) sobek.Value {
+		if len(chain) == 0 {
+			return sobek.Undefined()
+		}
+
+		handler := chain[0]
+		chain = chain[1:]
+
+		if _, err := handler(sobek.Undefined(), req, res, runtime.ToValue(next)); err != nil {
+			srv.mod.push(srv.mod.metrics.handlerErrors, 1, map[string]string{
+				"mock":   srv.target,
+				"method": target.method,
+				"route":  target.pattern,
+			})
+
+			srv.emit(hookError, func() map[string]interface{} {
+				return map[string]interface{}{
+					"request": map[string]interface{}{"method": req.Get("method").String(), "path": req.Get("path").String()},
+					"route":   target.pattern,
+					"error":   err.Error(),
+				}
+			})
+
+			srv.mod.throw(err)
+		}
+
+		return sobek.Undefined()
+	}
+
+	next(sobek.FunctionCall{})
+}
+
+func (srv *server) set(res *sobek.Object, name, value string) {
+	set, ok := sobek.AssertFunction(res.Get("set"))
+	if !ok {
+		return
+	}
+
+	runtime := srv.mod.runtime()
+
+	if _, err := set(res, runtime.ToValue(name), runtime.ToValue(value)); err != nil {
+		srv.mod.throw(err)
+	}
+}
+
+func (srv *server) notFound(res *sobek.Object) {
+	runtime := srv.mod.runtime()
+
+	status, _ := sobek.AssertFunction(res.Get("status"))
+	text, _ := sobek.AssertFunction(res.Get("text"))
+
+	if status == nil || text == nil {
+		return
+	}
+
+	if _, err := status(res, runtime.ToValue(http.StatusNotFound)); err != nil {
+		srv.mod.throw(err)
+	}
+
+	if _, err := text(res, runtime.ToValue(http.StatusText(http.StatusNotFound))); err != nil {
+		srv.mod.throw(err)
+	}
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMatcher(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.get("/", { match: req => req.get("X-Dry-Run") === "true" }, (req, res) => res.text("dry"))
+	app.get("/", (req, res) => res.text("wet"))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	base := helper.module.lookup["https://example.com"]
+
+	res, err := req.R().SetHeader("X-Dry-Run", "true").Get(base)
+
+	require.NoError(t, err)
+
+	body, err := res.ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, "dry", body)
+
+	res, err = req.Get(base)
+
+	require.NoError(t, err)
+
+	body, err = res.ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, "wet", body)
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	helper := newHelper(b)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.get("/users/:id", { match: req => req.get("X-Dry-Run") === "true" }, (req, res) => res.text("dry"))
+	app.get("/users/:id", (req, res) => res.json({ id: req.params.id }))
+}, { sync: true })
+`)
+
+	require.NoError(b, err)
+
+	loc := helper.module.lookup["https://example.com"] + "/users/42?verbose=true"
+	client := &http.Client{} // nolint:exhaustruct
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		res, err := client.Get(loc) // nolint:noctx
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		io.Copy(io.Discard, res.Body) // nolint:errcheck
+		res.Body.Close()              // nolint:errcheck,gosec
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const headerBody = "X-Mock-Body"
+
+// bodyStore keeps the raw bodies of the requests forwarded to the application while they are dispatched,
+// the forwarded request refers to its body by the X-Mock-Body header.
+type bodyStore struct {
+	mu    sync.Mutex
+	seq   int
+	items map[string][]byte
+}
+
+func newBodyStore() *bodyStore {
+	return &bodyStore{items: make(map[string][]byte)}
+}
+
+func (s *bodyStore) put(body []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	id := strconv.Itoa(s.seq)
+	s.items[id] = body
+
+	return id
+}
+
+func (s *bodyStore) get(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, found := s.items[id]
+
+	return body, found
+}
+
+func (s *bodyStore) drop(id string) {
+	s.mu.Lock()
+	delete(s.items, id)
+	s.mu.Unlock()
+}
+
+// bodyProps returns the body related properties of the handler's request object: the raw body as rawBody
+// and, for JSON, XML and the content types of the registered codecs (see RegisterCodec), the parsed body
+// as body. Invalid body leaves body untouched.
+func bodyProps(contentType string, raw []byte) map[string]interface{} {
+	props := map[string]interface{}{"rawBody": string(raw)}
+
+	if len(raw) == 0 {
+		return props
+	}
+
+	var (
+		body interface{}
+		err  error
+	)
+
+	switch {
+	case isJSONMedia(contentType):
+		err = json.Unmarshal(raw, &body)
+	case isXMLMedia(contentType):
+		body, err = parseXML(raw)
+	default:
+		codec, cerr := codecFor(contentType)
+		if cerr != nil {
+			return props
+		}
+
+		body, err = codec.Decode(raw)
+	}
+
+	if err == nil {
+		props["body"] = body
+	}
+
+	return props
+}
+
+func isXMLMedia(media string) bool {
+	mediaType, _, err := mime.ParseMediaType(media)
+
+	return err == nil &&
+		(mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"))
+}
+
+var errNoXMLRoot = errors.New("missing XML root element")
+
+// parseXML decodes an XML document into nested maps keyed by the local names of the elements:
+// attributes are prefixed with "-", the text of elements having attributes or children is "#text",
+// repeated elements are collected into arrays and elements having only text are strings.
+func parseXML(data []byte) (map[string]interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, errNoXMLRoot
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := parseXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]interface{}{start.Name.Local: value}, nil
+		}
+	}
+}
+
+func parseXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := make(map[string]interface{})
+
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+
+		node["-"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			child, err := parseXMLElement(decoder, tok)
+			if err != nil {
+				return nil, err
+			}
+
+			addXMLChild(node, tok.Name.Local, child)
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+
+			if len(node) == 0 {
+				return content, nil
+			}
+
+			if len(content) != 0 {
+				node["#text"] = content
+			}
+
+			return node, nil
+		}
+	}
+}
+
+func addXMLChild(node map[string]interface{}, name string, child interface{}) {
+	switch prev := node[name].(type) {
+	case nil:
+		node[name] = child
+	case []interface{}:
+		node[name] = append(prev, child)
+	default:
+		node[name] = []interface{}{prev, child}
+	}
+}
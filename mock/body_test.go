@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyStore(t *testing.T) {
+	t.Parallel()
+
+	store := newBodyStore()
+
+	first := store.put([]byte("first"))
+	second := store.put(nil)
+
+	assert.NotEqual(t, first, second)
+
+	body, found := store.get(first)
+
+	assert.True(t, found)
+	assert.Equal(t, []byte("first"), body)
+
+	store.drop(first)
+
+	_, found = store.get(first)
+
+	assert.False(t, found)
+
+	_, found = store.get("")
+
+	assert.False(t, found)
+}
+
+func TestBodyProps(t *testing.T) {
+	t.Parallel()
+
+	props := bodyProps("application/json; charset=utf-8", []byte(`{"name":"Bob","tags":["a"]}`))
+
+	assert.Equal(t, `{"name":"Bob","tags":["a"]}`, props["rawBody"])
+	assert.Equal(t, map[string]interface{}{"name": "Bob", "tags": []interface{}{"a"}}, props["body"])
+
+	props = bodyProps("application/problem+json", []byte(`[1,2]`))
+
+	assert.Equal(t, []interface{}{1.0, 2.0}, props["body"])
+
+	props = bodyProps("application/json", []byte(`{"name":`))
+
+	assert.Equal(t, `{"name":`, props["rawBody"])
+	assert.NotContains(t, props, "body")
+
+	props = bodyProps("text/plain", []byte(`{"name":"Bob"}`))
+
+	assert.Equal(t, `{"name":"Bob"}`, props["rawBody"])
+	assert.NotContains(t, props, "body")
+
+	props = bodyProps("application/json", nil)
+
+	assert.Equal(t, "", props["rawBody"])
+	assert.NotContains(t, props, "body")
+
+	props = bodyProps("application/x-test-lines", []byte("first\nsecond"))
+
+	assert.Equal(t, []interface{}{"first", "second"}, props["body"])
+}
+
+func TestParseXML(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseXML([]byte(`<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <order id="42" xmlns="urn:orders">
+      <item>book</item>
+      <item>pen</item>
+      <note lang="en">fragile</note>
+      <empty/>
+    </order>
+  </soap:Body>
+</soap:Envelope>`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Envelope": map[string]interface{}{
+			"Body": map[string]interface{}{
+				"order": map[string]interface{}{
+					"-id":   "42",
+					"item":  []interface{}{"book", "pen"},
+					"note":  map[string]interface{}{"-lang": "en", "#text": "fragile"},
+					"empty": "",
+				},
+			},
+		},
+	}, doc)
+
+	_, err = parseXML([]byte(`<order><item>`))
+
+	assert.Error(t, err)
+
+	_, err = parseXML([]byte(`  `))
+
+	assert.ErrorIs(t, err, errNoXMLRoot)
+
+	props := bodyProps("text/xml; charset=utf-8", []byte(`<a><b>1</b></a>`))
+
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"b": "1"}}, props["body"])
+	assert.Equal(t, `<a><b>1</b></a>`, props["rawBody"])
+
+	props = bodyProps("application/soap+xml", []byte(`<a>`))
+
+	assert.NotContains(t, props, "body")
+}
@@ -0,0 +1,316 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	maxSafeInteger        = 1<<53 - 1
+	defaultBoundaryLength = 1024
+)
+
+// boundaries derives values from a JSON Schema where exactly one location of a valid base value
+// is replaced with a boundary value (empty arrays, nulls where allowed, min/max length strings,
+// min/max numbers, missing optional properties).
+func boundaries(root, schema map[string]interface{}) ([]interface{}, error) {
+	gen := newGenerator(root, 1)
+
+	gen.optional = 1
+	gen.minItems = 1
+
+	base, err := gen.generate(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := gen.variants(schema, base, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]interface{}{base}, variants...), nil
+}
+
+func (g *generator) variants(schema map[string]interface{}, value interface{}, depth int) ([]interface{}, error) {
+	schema, err := g.resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema, err = g.merge(schema); err != nil {
+		return nil, err
+	}
+
+	if _, ok := schema["const"]; ok {
+		return nil, nil
+	}
+
+	var found []interface{}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, item := range enum {
+			if !reflect.DeepEqual(item, value) {
+				found = append(found, item)
+			}
+		}
+
+		return found, nil
+	}
+
+	if depth >= g.maxDepth {
+		return nil, nil
+	}
+
+	for _, typ := range schemaTypes(schema) {
+		switch typ {
+		case "null":
+			if value != nil {
+				found = append(found, nil)
+			}
+		case "boolean":
+			flag, _ := value.(bool)
+			found = append(found, !flag)
+		case "integer":
+			found = append(found, numberBoundaries(schema, true)...)
+		case "number":
+			found = append(found, numberBoundaries(schema, false)...)
+		case "string":
+			if _, hasFormat := schema["format"]; !hasFormat {
+				found = append(found, stringBoundaries(schema)...)
+			}
+		case "array":
+			nested, err := g.arrayVariants(schema, value, depth)
+			if err != nil {
+				return nil, err
+			}
+
+			found = append(found, nested...)
+		case "object":
+			nested, err := g.objectVariants(schema, value, depth)
+			if err != nil {
+				return nil, err
+			}
+
+			found = append(found, nested...)
+		}
+	}
+
+	return found, nil
+}
+
+func numberBoundaries(schema map[string]interface{}, integer bool) []interface{} {
+	low, high := -float64(maxSafeInteger), float64(maxSafeInteger)
+
+	if num, ok := toFloat(schema["minimum"]); ok {
+		low = num
+	}
+
+	if num, ok := toFloat(schema["maximum"]); ok {
+		high = num
+	}
+
+	if num, ok := toFloat(schema["exclusiveMinimum"]); ok {
+		low = math.Nextafter(num, math.Inf(1))
+		if integer {
+			low = math.Floor(num) + 1
+		}
+	}
+
+	if num, ok := toFloat(schema["exclusiveMaximum"]); ok {
+		high = math.Nextafter(num, math.Inf(-1))
+		if integer {
+			high = math.Ceil(num) - 1
+		}
+	}
+
+	found := []interface{}{low, high}
+
+	if low < 0 && high > 0 {
+		found = append(found, 0.0)
+	}
+
+	if integer {
+		for idx, num := range found {
+			found[idx] = int64(num.(float64)) // nolint:forcetypeassert
+		}
+	}
+
+	return found
+}
+
+func stringBoundaries(schema map[string]interface{}) []interface{} {
+	low := intKeyword(schema, "minLength", 0)
+	high := intKeyword(schema, "maxLength", defaultBoundaryLength)
+
+	return []interface{}{strings.Repeat("x", low), strings.Repeat("x", high)}
+}
+
+func (g *generator) arrayVariants(schema map[string]interface{}, value interface{}, depth int) ([]interface{}, error) {
+	list, _ := value.([]interface{})
+
+	items, _ := schema["items"].(map[string]interface{})
+	if items == nil {
+		items = map[string]interface{}{}
+	}
+
+	repeat := func(count int) []interface{} {
+		out := make([]interface{}, 0, count)
+
+		for idx := 0; idx < count && len(list) != 0; idx++ {
+			out = append(out, list[idx%len(list)])
+		}
+
+		return out
+	}
+
+	low := intKeyword(schema, "minItems", 0)
+
+	found := []interface{}{repeat(low)}
+
+	if high := intKeyword(schema, "maxItems", -1); high > low {
+		found = append(found, repeat(high))
+	}
+
+	if len(list) == 0 {
+		return found, nil
+	}
+
+	nested, err := g.variants(items, list[0], depth+1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range nested {
+		out := append([]interface{}{item}, list[1:]...)
+		found = append(found, out)
+	}
+
+	return found, nil
+}
+
+func (g *generator) objectVariants(schema map[string]interface{}, value interface{}, depth int) ([]interface{}, error) {
+	obj, _ := value.(map[string]interface{})
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	required := make(map[string]bool)
+
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	with := func(name string, prop interface{}, remove bool) map[string]interface{} {
+		out := make(map[string]interface{}, len(obj))
+
+		for key, value := range obj {
+			out[key] = value
+		}
+
+		if remove {
+			delete(out, name)
+		} else {
+			out[name] = prop
+		}
+
+		return out
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var found []interface{}
+
+	for _, name := range names {
+		if _, present := obj[name]; !present {
+			continue
+		}
+
+		if !required[name] {
+			found = append(found, with(name, nil, true))
+		}
+
+		sub, _ := properties[name].(map[string]interface{})
+		if sub == nil {
+			continue
+		}
+
+		nested, err := g.variants(sub, obj[name], depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, prop := range nested {
+			found = append(found, with(name, prop, false))
+		}
+	}
+
+	return found, nil
+}
+
+// cycle returns the values one after the other, restarting from the first one after the last.
+type cycle struct {
+	mu     sync.Mutex
+	values []interface{}
+	next   int
+}
+
+func (c *cycle) get() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.values) == 0 {
+		return nil
+	}
+
+	value := c.values[c.next%len(c.values)]
+	c.next++
+
+	return value
+}
+
+// boundaries returns an object cycling through the boundary values of the given JSON Schema.
+func (mod *Module) boundaries(call sobek.FunctionCall) sobek.Value {
+	root, ok := call.Argument(0).Export().(map[string]interface{})
+	if !ok {
+		mod.throwf("schema must be an object", errInvalidArg)
+	}
+
+	schema := root
+
+	if opts, isObj := call.Argument(1).(*sobek.Object); isObj {
+		if ref := opts.Get("ref"); ref != nil && !sobek.IsUndefined(ref) {
+			schema = map[string]interface{}{"$ref": ref.String()}
+		}
+	}
+
+	values, err := boundaries(root, schema)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	runtime := mod.runtime()
+	cyc := &cycle{values: values}
+	obj := runtime.NewObject()
+
+	obj.Set("values", values)                                                 // nolint:errcheck
+	obj.Set("next", func() sobek.Value { return runtime.ToValue(cyc.get()) }) // nolint:errcheck
+
+	return obj
+}
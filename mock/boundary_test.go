@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundaries(t *testing.T) {
+	t.Parallel()
+
+	schema := parseSchema(t, `{
+	  "type": "object",
+	  "required": ["id", "tags"],
+	  "properties": {
+	    "id": {"type": "integer", "minimum": 1, "maximum": 100},
+	    "name": {"type": "string", "maxLength": 8, "nullable": true},
+	    "tags": {"type": "array", "maxItems": 3, "items": {"type": "string", "minLength": 1, "maxLength": 2}}
+	  }
+	}`)
+
+	values, err := boundaries(schema, schema)
+
+	require.NoError(t, err)
+
+	has := func(check func(map[string]interface{}) bool) bool {
+		for _, value := range values {
+			if obj, ok := value.(map[string]interface{}); ok && check(obj) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	assert.True(t, has(func(obj map[string]interface{}) bool { return obj["id"] == int64(1) }))
+	assert.True(t, has(func(obj map[string]interface{}) bool { return obj["id"] == int64(100) }))
+	assert.True(t, has(func(obj map[string]interface{}) bool { _, ok := obj["name"]; return !ok }))
+	assert.True(t, has(func(obj map[string]interface{}) bool { v, ok := obj["name"]; return ok && v == nil }))
+	assert.True(t, has(func(obj map[string]interface{}) bool { return obj["name"] == strings.Repeat("x", 8) }))
+	assert.True(t, has(func(obj map[string]interface{}) bool { return obj["name"] == "" }))
+
+	assert.True(t, has(func(obj map[string]interface{}) bool {
+		tags, ok := obj["tags"].([]interface{})
+
+		return ok && len(tags) == 0
+	}))
+
+	assert.True(t, has(func(obj map[string]interface{}) bool {
+		tags, ok := obj["tags"].([]interface{})
+
+		return ok && len(tags) == 3
+	}))
+
+	assert.True(t, has(func(obj map[string]interface{}) bool {
+		tags, ok := obj["tags"].([]interface{})
+
+		return ok && len(tags) != 0 && tags[0] == "xx"
+	}))
+
+	assert.False(t, has(func(obj map[string]interface{}) bool { _, ok := obj["id"]; return !ok }))
+
+	cyc := &cycle{values: values}
+
+	assert.Equal(t, values[0], cyc.get())
+	assert.Equal(t, values[1], cyc.get())
+}
+
+func TestBoundariesObjectEnum(t *testing.T) {
+	t.Parallel()
+
+	schema := parseSchema(t, `{"enum": [{"plan": "free"}, {"plan": "pro"}, ["a", "b"]]}`)
+
+	var (
+		values []interface{}
+		err    error
+	)
+
+	require.NotPanics(t, func() { values, err = boundaries(schema, schema) })
+	require.NoError(t, err)
+	assert.Len(t, values, 3)
+}
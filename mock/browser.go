@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grafana/sobek"
+)
+
+// intercept installs the mock servers as the interception targets of the k6/browser page's routes.
+// Requests of the page to the mocked targets are served by the mock servers (routes and stubs),
+// so protocol-level and browser-level tests share the same mock definitions.
+//
+// The optional second argument filters the intercepted targets. The returned promise resolves
+// when all routes are installed.
+func (mod *Module) intercept(page *sobek.Object, targets []string) sobek.Value {
+	runtime := mod.runtime()
+
+	routeFn, ok := sobek.AssertFunction(page.Get("route"))
+	if !ok {
+		mod.throwf("page.route is not a function", errInvalidArg)
+	}
+
+	mod.startDeferred(func(target string) bool { return len(targets) == 0 || contains(targets, target) })
+
+	ctor := runtime.Get("RegExp")
+
+	installed := make([]interface{}, 0, len(mod.lookup))
+
+	for target, addr := range mod.lookup {
+		if len(targets) != 0 && !contains(targets, target) {
+			continue
+		}
+
+		pattern, err := runtime.New(ctor, runtime.ToValue("^"+regexp.QuoteMeta(target)))
+		if err != nil {
+			mod.throw(err)
+		}
+
+		result, err := routeFn(page, pattern, runtime.ToValue(mod.interceptor(target, addr)))
+		if err != nil {
+			mod.throw(err)
+		}
+
+		installed = append(installed, result)
+	}
+
+	all, _ := sobek.AssertFunction(runtime.Get("Promise").ToObject(runtime).Get("all"))
+
+	result, err := all(runtime.Get("Promise"), runtime.ToValue(installed))
+	if err != nil {
+		mod.throw(err)
+	}
+
+	return result
+}
+
+// interceptClient returns the client forwarding the intercepted requests over the VU's transport, so
+// the certificates of the mock servers are trusted. Redirects are not followed, they are handled by
+// the browser.
+func (mod *Module) interceptClient() *http.Client {
+	return &http.Client{ // nolint:exhaustruct
+		Transport:     mod.transport(),
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// postData returns the body of the intercepted request, binary bodies are read by postDataBuffer.
+func (mod *Module) postData(req *sobek.Object) []byte {
+	if buff, ok := mod.callMethod(req, "postDataBuffer").Export().(sobek.ArrayBuffer); ok {
+		return buff.Bytes()
+	}
+
+	if data := mod.callMethod(req, "postData"); data != nil && !sobek.IsNull(data) && !sobek.IsUndefined(data) {
+		return []byte(data.String())
+	}
+
+	return nil
+}
+
+// interceptURL returns the mock server's URL of the intercepted request's URL.
+func interceptURL(target, addr, loc string) string {
+	return strings.TrimSuffix(addr, "/") + strings.TrimPrefix(loc, strings.TrimSuffix(target, "/"))
+}
+
+// interceptor returns the page route handler forwarding the intercepted requests to the mock server.
+// The request is sent from a separate goroutine, because the mock's handlers run on the event loop.
+func (mod *Module) interceptor(target, addr string) func(*sobek.Object) *sobek.Promise {
+	return func(route *sobek.Object) *sobek.Promise {
+		runtime := mod.runtime()
+
+		req := mod.callMethod(route, "request").ToObject(runtime)
+
+		out, err := http.NewRequest( // nolint:noctx
+			mod.callMethod(req, "method").String(),
+			interceptURL(target, addr, mod.callMethod(req, "url").String()),
+			nil,
+		)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		if data := mod.postData(req); data != nil {
+			out.Body = io.NopCloser(bytes.NewReader(data))
+			out.ContentLength = int64(len(data))
+		}
+
+		if headers, isObj := mod.callMethod(req, "headers").(*sobek.Object); isObj {
+			for _, name := range headers.Keys() {
+				out.Header.Set(name, headers.Get(name).String())
+			}
+		}
+
+		out.Header.Del("Accept-Encoding")
+
+		client := mod.interceptClient()
+
+		promise, resolve, reject := runtime.NewPromise()
+		callback := mod.vu.RegisterCallback()
+
+		go func() {
+			res, err := client.Do(out)
+
+			var body bytes.Buffer
+
+			if err == nil {
+				_, err = body.ReadFrom(res.Body)
+				res.Body.Close() // nolint:errcheck,gosec
+			}
+
+			callback(func() error {
+				if err != nil {
+					return reject(err)
+				}
+
+				result, err := mod.fulfill(route, res, body.Bytes())
+				if err != nil {
+					return reject(err)
+				}
+
+				return resolve(result)
+			})
+		}()
+
+		return promise
+	}
+}
+
+// fulfill fulfills the intercepted route with the mock server's response.
+func (mod *Module) fulfill(route *sobek.Object, res *http.Response, body []byte) (sobek.Value, error) {
+	runtime := mod.runtime()
+
+	fulfill, ok := sobek.AssertFunction(route.Get("fulfill"))
+	if !ok {
+		return nil, errInvalidArg
+	}
+
+	headers := make(map[string]interface{}, len(res.Header))
+
+	for name := range res.Header {
+		sep := ", "
+		if name == "Set-Cookie" {
+			sep = "\n"
+		}
+
+		headers[strings.ToLower(name)] = strings.Join(res.Header.Values(name), sep)
+	}
+
+	opts := map[string]interface{}{"status": res.StatusCode, "headers": headers}
+
+	if utf8.Valid(body) {
+		opts["body"] = string(body)
+	} else {
+		opts["body"] = runtime.NewArrayBuffer(body)
+	}
+
+	return fulfill(route, runtime.ToValue(opts))
+}
+
+// callMethod calls the named method of the object, undefined is returned if the method is missing.
+func (mod *Module) callMethod(obj *sobek.Object, name string, args ...sobek.Value) sobek.Value {
+	method, ok := sobek.AssertFunction(obj.Get(name))
+	if !ok {
+		return sobek.Undefined()
+	}
+
+	value, err := method(obj, args...)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	return value
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptURL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "http://127.0.0.1:8000/items?page=2",
+		interceptURL("https://example.com", "http://127.0.0.1:8000", "https://example.com/items?page=2"))
+	assert.Equal(t, "http://127.0.0.1:8000/items",
+		interceptURL("https://example.com/api/", "http://127.0.0.1:8000/", "https://example.com/api/items"))
+	assert.Equal(t, "http://127.0.0.1:8000/__region__/eu/items",
+		interceptURL("https://eu.example.com", "http://127.0.0.1:8000/__region__/eu", "https://eu.example.com/items"))
+}
+
+func TestInterceptPostData(t *testing.T) {
+	t.Parallel()
+
+	runtime := sobek.New()
+	mod := new(Module)
+
+	binary := []byte{0xff, 0x00, 0xfe}
+
+	req := runtime.NewObject()
+
+	require.NoError(t, req.Set("postData", func() string { return "binary" }))
+	require.NoError(t, req.Set("postDataBuffer", func() sobek.ArrayBuffer { return runtime.NewArrayBuffer(binary) }))
+
+	assert.Equal(t, binary, mod.postData(req))
+
+	text := runtime.NewObject()
+
+	require.NoError(t, text.Set("postData", func() string { return `{"a":1}` }))
+
+	assert.Equal(t, []byte(`{"a":1}`), mod.postData(text))
+
+	empty := runtime.NewObject()
+
+	require.NoError(t, empty.Set("postData", func() sobek.Value { return sobek.Null() }))
+	require.NoError(t, empty.Set("postDataBuffer", func() sobek.Value { return sobek.Null() }))
+
+	assert.Nil(t, mod.postData(empty))
+}
@@ -0,0 +1,434 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	methodPurge = "PURGE"
+
+	headerAge              = "Age"
+	headerXCache           = "X-Cache"
+	headerSurrogateKey     = "Surrogate-Key"
+	headerSurrogateControl = "Surrogate-Control"
+
+	cacheHit    = "HIT"
+	cacheMiss   = "MISS"
+	cacheStale  = "STALE"
+	cacheBypass = "BYPASS"
+)
+
+// cacheControl is a parsed Cache-Control header.
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	cc := make(cacheControl)
+
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if len(name) != 0 {
+			cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, found := cc[name]
+
+	return found
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	value, found := cc[name]
+	if !found {
+		return 0, false
+	}
+
+	secs, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(secs) * time.Second, true
+}
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// cached is a response stored by the cache.
+type cached struct {
+	status int
+	header http.Header
+	body   []byte
+	route  *route
+	keys   []string
+	stored time.Time
+	ttl    time.Duration
+	swr    time.Duration // stale-while-revalidate
+	sie    time.Duration // stale-if-error
+
+	revalidating bool
+}
+
+func (c *cached) age(now time.Time) time.Duration {
+	return now.Sub(c.stored)
+}
+
+func (c *cached) fresh(now time.Time) bool {
+	return c.age(now) < c.ttl
+}
+
+func (c *cached) staleWhileRevalidate(now time.Time) bool {
+	return c.age(now) < c.ttl+c.swr
+}
+
+func (c *cached) staleIfError(now time.Time) bool {
+	return c.age(now) < c.ttl+c.sie
+}
+
+// write copies the stored response to res, including the Age header computed from the given time.
+func (c *cached) write(res *response, now time.Time) {
+	res.header = make(http.Header, len(c.header))
+
+	for name, values := range c.header {
+		res.header[name] = append([]string(nil), values...)
+	}
+
+	res.header.Set(headerAge, strconv.Itoa(int(c.age(now)/time.Second)))
+	res.status = c.status
+	res.body.Reset()
+	res.body.Write(c.body)
+}
+
+// cache emulates a shared caching proxy (or CDN edge) in front of the mock server's routes.
+type cache struct {
+	mu      sync.Mutex
+	entries map[string]*cached  // stored responses by cache key
+	vary    map[string][]string // Vary header names of the stored responses by request key
+	cdn     *cdn
+}
+
+func newCache(edge *cdn) *cache {
+	return &cache{entries: make(map[string]*cached), vary: make(map[string][]string), cdn: edge}
+}
+
+// requestKey returns the key of the request's method and URL.
+func requestKey(r *http.Request) string {
+	return r.Method + " " + r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// cacheKey returns the key of the request's variant: the request key and the values of the request
+// headers listed in the Vary header of the response.
+func cacheKey(r *http.Request, vary []string) string {
+	var key strings.Builder
+
+	key.WriteString(requestKey(r))
+
+	for _, name := range vary {
+		key.WriteString("\n" + name + ": " + r.Header.Get(name))
+	}
+
+	return key.String()
+}
+
+// varyNames returns the sorted canonical names of the Vary headers of the response.
+func varyNames(header http.Header) []string {
+	var names []string
+
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); len(name) != 0 {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// keyPath returns the path of the cache (or request) key.
+func keyPath(key string) string {
+	_, rest, _ := strings.Cut(key, " ")
+	path, _, _ := strings.Cut(rest, "?")
+
+	return path
+}
+
+// lifetime returns the freshness lifetime and the stale windows of the response,
+// false if it should not be stored by a shared cache.
+func (c *cache) lifetime(res *response, now time.Time) (*cached, bool) {
+	if !cacheableStatus[res.status] {
+		return nil, false
+	}
+
+	cc := parseCacheControl(res.header.Get("Cache-Control"))
+
+	if c.cdn != nil && res.header.Get(headerSurrogateControl) != "" {
+		cc = parseCacheControl(res.header.Get(headerSurrogateControl))
+	}
+
+	if cc.has("no-store") || cc.has("private") || cc.has("no-cache") {
+		return nil, false
+	}
+
+	entry := new(cached)
+
+	entry.swr, _ = cc.seconds("stale-while-revalidate")
+	entry.sie, _ = cc.seconds("stale-if-error")
+
+	if d, ok := cc.seconds("s-maxage"); ok {
+		entry.ttl = d
+	} else if d, ok := cc.seconds("max-age"); ok {
+		entry.ttl = d
+	} else if expires, err := http.ParseTime(res.header.Get("Expires")); err == nil {
+		entry.ttl = expires.Sub(now)
+	} else {
+		return nil, false
+	}
+
+	return entry, entry.ttl > 0 || entry.swr > 0 || entry.sie > 0
+}
+
+func (c *cache) lookup(r *http.Request) *cached {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries[cacheKey(r, c.vary[requestKey(r)])]
+}
+
+// store stores the cacheable response. Fuzzed responses and injected faults are not stored, they
+// would be served to every client.
+func (c *cache) store(r *http.Request, res *response, matched *route, now time.Time) bool {
+	if res.faulted || len(res.header.Get(headerFault)) != 0 {
+		return false
+	}
+
+	entry, ok := c.lifetime(res, now)
+	if !ok {
+		return false
+	}
+
+	entry.status = res.status
+	entry.header = res.header.Clone()
+	entry.body = bytes.Clone(res.body.Bytes())
+	entry.route = matched
+	entry.keys = strings.Fields(res.header.Get(headerSurrogateKey))
+	entry.stored = now
+
+	vary := varyNames(res.header)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.vary[requestKey(r)] = vary
+	c.entries[cacheKey(r, vary)] = entry
+
+	return true
+}
+
+// purge removes the stored responses of the given path (all of them if path is empty)
+// and returns the number of removed responses.
+func (c *cache) purge(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+
+	for key := range c.entries {
+		if len(path) == 0 || keyPath(key) == path {
+			count++
+			delete(c.entries, key)
+		}
+	}
+
+	for key := range c.vary {
+		if len(path) == 0 || keyPath(key) == path {
+			delete(c.vary, key)
+		}
+	}
+
+	return count
+}
+
+// purgeKey removes the stored responses tagged with the given surrogate key
+// and returns the number of removed responses.
+func (c *cache) purgeKey(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+
+	for name, entry := range c.entries {
+		if contains(entry.keys, key) {
+			count++
+			delete(c.entries, name)
+		}
+	}
+
+	return count
+}
+
+// startRevalidation marks the entry as being revalidated, false if revalidation is already in progress.
+func (c *cache) startRevalidation(entry *cached) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.revalidating {
+		return false
+	}
+
+	entry.revalidating = true
+
+	return true
+}
+
+func (c *cache) finishRevalidation(entry *cached) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.revalidating = false
+}
+
+// cached serves GET and HEAD requests from the cache if possible, otherwise the produced response
+// is stored if cacheable. PURGE requests remove the stored responses of the request path
+// (or of the surrogate key passed in Surrogate-Key header). The point of presence serving the request
+// is selected after the lookup, so stored responses don't report the one filling the cache.
+func (srv *server) cached(res *response, r *http.Request) *route { // nolint:varnamelen
+	if r.Method == methodPurge {
+		if srv.cache.cdn != nil {
+			srv.cache.cdn.serve(res, r)
+		}
+
+		var count int
+
+		if key := r.Header.Get(headerSurrogateKey); len(key) != 0 {
+			count = srv.cache.purgeKey(key)
+		} else {
+			count = srv.cache.purge(r.URL.Path)
+		}
+
+		res.header.Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"purged":` + strconv.Itoa(count) + `}`)) // nolint:errcheck,gosec
+
+		return nil
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		matched := srv.produce(res, r)
+
+		if srv.cache.cdn != nil {
+			srv.cache.cdn.serve(res, r)
+		}
+
+		return matched
+	}
+
+	matched, status := srv.lookup(res, r)
+
+	res.header.Set(headerXCache, status)
+
+	if srv.cache.cdn != nil {
+		res.header.Del(headerSurrogateKey)
+		res.header.Del(headerSurrogateControl)
+
+		srv.cache.cdn.serve(res, r)
+	}
+
+	return matched
+}
+
+func (srv *server) lookup(res *response, r *http.Request) (*route, string) { // nolint:varnamelen
+	now := srv.clock.now()
+
+	if parseCacheControl(r.Header.Get("Cache-Control")).has("no-cache") {
+		return srv.produce(res, r), cacheBypass
+	}
+
+	entry := srv.cache.lookup(r)
+
+	if entry != nil && entry.fresh(now) {
+		entry.write(res, now)
+
+		return entry.route, cacheHit
+	}
+
+	if entry != nil && entry.staleWhileRevalidate(now) {
+		entry.write(res, now)
+		srv.revalidate(entry, r)
+
+		return entry.route, cacheStale
+	}
+
+	matched := srv.produce(res, r)
+
+	if entry != nil && res.status >= http.StatusInternalServerError && entry.staleIfError(now) {
+		entry.write(res, now)
+
+		return entry.route, cacheStale
+	}
+
+	srv.cache.store(r, res, matched, now)
+
+	return matched, cacheMiss
+}
+
+// revalidate refreshes the stale entry in the background.
+func (srv *server) revalidate(entry *cached, r *http.Request) {
+	if !srv.cache.startRevalidation(entry) {
+		return
+	}
+
+	out := r.Clone(context.Background())
+
+	go func() {
+		defer srv.cache.finishRevalidation(entry)
+
+		res := acquireResponse()
+		defer res.release()
+
+		matched := srv.produce(res, out)
+
+		srv.cache.store(out, res, matched, srv.clock.now())
+	}()
+}
+
+// cacheObject returns the JavaScript interface of the server's cache.
+func (srv *server) cacheObject() *sobek.Object {
+	obj := srv.mod.runtime().NewObject()
+
+	set := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	set("purge", func(path string) int { return srv.cache.purge(path) })
+	set("purgeKey", func(key string) int { return srv.cache.purgeKey(key) })
+
+	return obj
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachedResponse(t *testing.T, cacheControl string, body string) *response {
+	t.Helper()
+
+	res := newResponse()
+
+	res.header.Set("Cache-Control", cacheControl)
+	res.header.Set("Vary", "Accept-Language")
+	res.WriteHeader(http.StatusOK)
+
+	_, err := res.Write([]byte(body))
+
+	require.NoError(t, err)
+
+	return res
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	c := newCache(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/items?page=1", nil)
+	req.Header.Set("Accept-Language", "en")
+
+	assert.Nil(t, c.lookup(req))
+	assert.False(t, c.store(req, newCachedResponse(t, "private, max-age=60", "private"), nil, now))
+	assert.False(t, c.store(req, newCachedResponse(t, "no-store", "none"), nil, now))
+	assert.True(t, c.store(req, newCachedResponse(t, "public, max-age=60, s-maxage=10", "en"), nil, now))
+
+	entry := c.lookup(req)
+
+	require.NotNil(t, entry)
+	assert.Equal(t, 10*time.Second, entry.ttl)
+	assert.True(t, entry.fresh(now.Add(9*time.Second)))
+	assert.False(t, entry.fresh(now.Add(10*time.Second)))
+
+	res := newResponse()
+
+	entry.write(res, now.Add(5*time.Second))
+
+	assert.Equal(t, "5", res.header.Get(headerAge))
+	assert.Equal(t, "en", res.body.String())
+
+	other := httptest.NewRequest(http.MethodGet, "/items?page=1", nil)
+	other.Header.Set("Accept-Language", "de")
+
+	assert.Nil(t, c.lookup(other))
+	assert.Nil(t, c.lookup(httptest.NewRequest(http.MethodGet, "/items?page=2", nil)))
+
+	assert.Equal(t, 0, c.purge("/other"))
+	assert.Equal(t, 1, c.purge("/items"))
+	assert.Nil(t, c.lookup(req))
+}
+
+func TestParseCacheControl(t *testing.T) {
+	t.Parallel()
+
+	cc := parseCacheControl(`public, max-age=60, stale-while-revalidate="30", No-Cache`)
+
+	assert.True(t, cc.has("public"))
+	assert.True(t, cc.has("no-cache"))
+
+	d, ok := cc.seconds("stale-while-revalidate")
+
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+
+	_, ok = cc.seconds("public")
+
+	assert.False(t, ok)
+}
+
+func TestCacheStale(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	c := newCache(newCDN(nil, ""))
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	res := newCachedResponse(t, "max-age=600", "items")
+
+	res.header.Set(headerSurrogateControl, "max-age=10, stale-while-revalidate=20, stale-if-error=60")
+	res.header.Set(headerSurrogateKey, "items catalog")
+
+	assert.True(t, c.store(req, res, nil, now))
+
+	entry := c.lookup(req)
+
+	require.NotNil(t, entry)
+	assert.Equal(t, 10*time.Second, entry.ttl)
+	assert.Equal(t, []string{"items", "catalog"}, entry.keys)
+
+	later := now.Add(15 * time.Second)
+
+	assert.False(t, entry.fresh(later))
+	assert.True(t, entry.staleWhileRevalidate(later))
+	assert.True(t, entry.staleIfError(later))
+
+	later = now.Add(45 * time.Second)
+
+	assert.False(t, entry.staleWhileRevalidate(later))
+	assert.True(t, entry.staleIfError(later))
+
+	assert.True(t, c.startRevalidation(entry))
+	assert.False(t, c.startRevalidation(entry))
+
+	c.finishRevalidation(entry)
+
+	assert.True(t, c.startRevalidation(entry))
+
+	assert.Equal(t, 0, c.purgeKey("other"))
+	assert.Equal(t, 1, c.purgeKey("catalog"))
+	assert.Nil(t, c.lookup(req))
+}
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	c := newCache(nil)
+
+	get := httptest.NewRequest(http.MethodGet, "/items", nil)
+	get.Header.Set("Accept-Language", "en")
+
+	head := httptest.NewRequest(http.MethodHead, "/items", nil)
+	head.Header.Set("Accept-Language", "en")
+
+	assert.True(t, c.store(head, newCachedResponse(t, "max-age=60", ""), nil, now))
+	assert.Nil(t, c.lookup(get))
+
+	assert.True(t, c.store(get, newCachedResponse(t, "max-age=60", "en"), nil, now))
+
+	other := httptest.NewRequest(http.MethodGet, "/items", nil)
+	other.Header.Set("Accept-Language", "de")
+
+	assert.True(t, c.store(other, newCachedResponse(t, "max-age=60", "de"), nil, now))
+
+	require.NotNil(t, c.lookup(get))
+	require.NotNil(t, c.lookup(other))
+	assert.Equal(t, "en", string(c.lookup(get).body))
+	assert.Equal(t, "de", string(c.lookup(other).body))
+	assert.Equal(t, 3, c.purge("/items"))
+
+	fuzzed := newCachedResponse(t, "max-age=60", "fuzzed")
+	fuzzed.faulted = true
+
+	assert.False(t, c.store(get, fuzzed, nil, now))
+
+	fault := newCachedResponse(t, "max-age=60", "")
+	fault.header.Set(headerFault, faultConnectionReset)
+
+	assert.False(t, c.store(get, fault, nil, now))
+	assert.Nil(t, c.lookup(get))
+}
@@ -0,0 +1,553 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// The traffic of the mock server's connections can be written into a pcapng (or pcap) file, so mock
+// sessions can be inspected by Wireshark. The bytes read and written by the connections are wrapped
+// into synthetic IP and TCP headers (with handshake and teardown segments). Traffic of TLS connections
+// is captured encrypted, the session keys are exported in NSS key log format: embedded into the pcapng
+// file as decryption secrets and written into the key log file if given.
+
+const (
+	captureFormatPcap   = "pcap"
+	captureFormatPcapng = "pcapng"
+
+	linkTypeRaw = 101 // raw IPv4 or IPv6 packets
+
+	captureSegment = 16384 // maximum TCP payload of the synthetic segments
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+
+	pcapngSectionHeader      = 0x0a0d0d0a
+	pcapngInterface          = 0x00000001
+	pcapngEnhancedPacket     = 0x00000006
+	pcapngDecryptionSecrets  = 0x0000000a
+	pcapngByteOrderMagic     = 0x1a2b3c4d
+	pcapngTLSKeyLog          = 0x544c534b
+	pcapMagicMicroseconds    = 0xa1b2c3d4
+	pcapSnapLength           = 262144
+	captureFileMode          = 0o644
+	pcapngBlockOverhead      = 12
+	pcapngEnhancedPacketSize = 20
+)
+
+// captureOptions are the settings of the traffic capture.
+type captureOptions struct {
+	file   string
+	format string
+	keyLog string
+}
+
+// getCapture parses the capture option: the name of the capture file or an object with file, format
+// (pcapng or pcap, by the file extension by default) and keyLog (key log file name) properties.
+func getCapture(value sobek.Value) *captureOptions {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	opts := new(captureOptions)
+
+	if obj, isObj := value.(*sobek.Object); isObj {
+		if v := obj.Get("file"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			opts.file = v.String()
+		}
+
+		if v := obj.Get("format"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			opts.format = v.String()
+		}
+
+		if v := obj.Get("keyLog"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			opts.keyLog = v.String()
+		}
+	} else {
+		opts.file = value.String()
+	}
+
+	if len(opts.file) == 0 {
+		return nil
+	}
+
+	if len(opts.format) == 0 {
+		opts.format = captureFormatPcapng
+
+		if strings.EqualFold(filepath.Ext(opts.file), ".pcap") {
+			opts.format = captureFormatPcap
+		}
+	}
+
+	return opts
+}
+
+// capture writes the captured packets and the TLS session keys.
+type capture struct {
+	mu     sync.Mutex
+	out    io.WriteCloser
+	keyLog io.WriteCloser
+	pcapng bool
+	closed bool
+	now    func() time.Time
+
+	format string
+	refs   int // number of servers writing the file, guarded by the registry's lock
+}
+
+func newCapture(opts *captureOptions) (*capture, error) {
+	if opts.format != captureFormatPcap && opts.format != captureFormatPcapng {
+		return nil, fmt.Errorf("%w: unknown capture format %s", errInvalidArg, opts.format)
+	}
+
+	out, err := os.Create(opts.file)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &capture{out: out, pcapng: opts.format == captureFormatPcapng, now: time.Now, format: opts.format}
+
+	if len(opts.keyLog) != 0 {
+		keyLog, err := os.OpenFile(opts.keyLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, captureFileMode)
+		if err != nil {
+			out.Close() // nolint:errcheck,gosec
+
+			return nil, err
+		}
+
+		c.keyLog = keyLog
+	}
+
+	if err := c.header(); err != nil {
+		c.close() // nolint:errcheck,gosec
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// openCapture returns the capture writing the file of the options. The mock servers of every VU
+// capturing into the same file share the capture, so the file is created once and the packets of
+// the servers are not interleaved.
+func (reg *registry) openCapture(opts *captureOptions) (*capture, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	path := capturePath(opts.file)
+
+	if c, found := reg.captures[path]; found {
+		if c.format != opts.format {
+			return nil, fmt.Errorf("%w: capture file %s is already written in %s format", errInvalidArg, opts.file, c.format)
+		}
+
+		c.refs++
+
+		return c, nil
+	}
+
+	c, err := newCapture(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.refs = 1
+	reg.captures[path] = c
+
+	return c, nil
+}
+
+// closeCapture releases the capture, the file is closed by its last user.
+func (reg *registry) closeCapture(c *capture) error {
+	reg.mu.Lock()
+
+	if c.refs--; c.refs > 0 {
+		reg.mu.Unlock()
+
+		return nil
+	}
+
+	for path, other := range reg.captures {
+		if other == c {
+			delete(reg.captures, path)
+		}
+	}
+
+	reg.mu.Unlock()
+
+	return c.close()
+}
+
+func capturePath(file string) string {
+	if path, err := filepath.Abs(file); err == nil {
+		return path
+	}
+
+	return filepath.Clean(file)
+}
+
+// header writes the file header: section header and interface description blocks of pcapng files.
+func (c *capture) header() error {
+	if !c.pcapng {
+		var header [24]byte
+
+		binary.LittleEndian.PutUint32(header[0:], pcapMagicMicroseconds)
+		binary.LittleEndian.PutUint16(header[4:], 2) // version 2.4
+		binary.LittleEndian.PutUint16(header[6:], 4)
+		binary.LittleEndian.PutUint32(header[16:], pcapSnapLength)
+		binary.LittleEndian.PutUint32(header[20:], linkTypeRaw)
+
+		_, err := c.out.Write(header[:])
+
+		return err
+	}
+
+	section := make([]byte, 16)
+
+	binary.LittleEndian.PutUint32(section[0:], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(section[4:], 1) // version 1.0
+	binary.LittleEndian.PutUint64(section[8:], ^uint64(0))
+
+	if err := c.block(pcapngSectionHeader, section); err != nil {
+		return err
+	}
+
+	iface := make([]byte, 8)
+
+	binary.LittleEndian.PutUint16(iface[0:], linkTypeRaw)
+	binary.LittleEndian.PutUint32(iface[4:], pcapSnapLength)
+
+	return c.block(pcapngInterface, iface)
+}
+
+// block writes a pcapng block, the body is padded to 32 bits.
+func (c *capture) block(kind uint32, body []byte) error {
+	padded := (len(body) + 3) &^ 3
+	size := uint32(padded + pcapngBlockOverhead)
+	buff := make([]byte, size)
+
+	binary.LittleEndian.PutUint32(buff[0:], kind)
+	binary.LittleEndian.PutUint32(buff[4:], size)
+	copy(buff[8:], body)
+	binary.LittleEndian.PutUint32(buff[size-4:], size)
+
+	_, err := c.out.Write(buff)
+
+	return err
+}
+
+// packet writes the IP packet with the current timestamp.
+func (c *capture) packet(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	micros := uint64(c.now().UnixMicro())
+
+	if c.pcapng {
+		body := make([]byte, pcapngEnhancedPacketSize+len(data))
+
+		binary.LittleEndian.PutUint32(body[4:], uint32(micros>>32))
+		binary.LittleEndian.PutUint32(body[8:], uint32(micros))
+		binary.LittleEndian.PutUint32(body[12:], uint32(len(data)))
+		binary.LittleEndian.PutUint32(body[16:], uint32(len(data)))
+		copy(body[pcapngEnhancedPacketSize:], data)
+
+		c.block(pcapngEnhancedPacket, body) // nolint:errcheck,gosec
+
+		return
+	}
+
+	var record [16]byte
+
+	binary.LittleEndian.PutUint32(record[0:], uint32(micros/1e6))
+	binary.LittleEndian.PutUint32(record[4:], uint32(micros%1e6))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(data)))
+
+	c.out.Write(append(record[:], data...)) // nolint:errcheck,gosec
+}
+
+// Write receives the TLS session keys (it is the KeyLogWriter of the TLS configuration), they are
+// written as decryption secrets of pcapng files and into the key log file.
+func (c *capture) Write(line []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return len(line), nil
+	}
+
+	if c.keyLog != nil {
+		if _, err := c.keyLog.Write(line); err != nil {
+			return 0, err
+		}
+	}
+
+	if c.pcapng {
+		body := make([]byte, 8+len(line))
+
+		binary.LittleEndian.PutUint32(body[0:], pcapngTLSKeyLog)
+		binary.LittleEndian.PutUint32(body[4:], uint32(len(line)))
+		copy(body[8:], line)
+
+		if err := c.block(pcapngDecryptionSecrets, body); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(line), nil
+}
+
+func (c *capture) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+
+	if c.keyLog != nil {
+		c.keyLog.Close() // nolint:errcheck,gosec
+	}
+
+	return c.out.Close()
+}
+
+// wrap returns a listener capturing the traffic of the accepted connections.
+func (c *capture) wrap(listener net.Listener) net.Listener {
+	return &captureListener{Listener: listener, capture: c}
+}
+
+type captureListener struct {
+	net.Listener
+	capture *capture
+}
+
+func (l *captureListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	client, isClientTCP := conn.RemoteAddr().(*net.TCPAddr)
+	server, isServerTCP := conn.LocalAddr().(*net.TCPAddr)
+
+	if !isClientTCP || !isServerTCP {
+		return conn, nil
+	}
+
+	captured := &capturedConn{
+		Conn:      conn,
+		capture:   l.capture,
+		client:    client,
+		server:    server,
+		clientSeq: rand.Uint32(), // nolint:gosec
+		serverSeq: rand.Uint32(), // nolint:gosec
+	}
+
+	captured.handshake()
+
+	return captured, nil
+}
+
+// capturedConn is a connection whose traffic is captured as TCP segments.
+type capturedConn struct {
+	net.Conn
+	capture *capture
+
+	mu        sync.Mutex
+	client    *net.TCPAddr
+	server    *net.TCPAddr
+	clientSeq uint32
+	serverSeq uint32
+	closed    bool
+}
+
+func (c *capturedConn) handshake() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.send(true, tcpSYN, nil)
+	c.clientSeq++
+	c.send(false, tcpSYN|tcpACK, nil)
+	c.serverSeq++
+	c.send(true, tcpACK, nil)
+}
+
+// send captures a segment of the client (or the server), the sequence numbers are not advanced.
+func (c *capturedConn) send(fromClient bool, flags byte, payload []byte) {
+	if fromClient {
+		c.capture.packet(tcpPacket(c.client, c.server, c.clientSeq, c.serverSeq, flags, payload))
+	} else {
+		c.capture.packet(tcpPacket(c.server, c.client, c.serverSeq, c.clientSeq, flags, payload))
+	}
+}
+
+// data captures the payload as segments of the client (or the server).
+func (c *capturedConn) data(fromClient bool, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(payload) != 0 {
+		size := len(payload)
+		if size > captureSegment {
+			size = captureSegment
+		}
+
+		c.send(fromClient, tcpPSH|tcpACK, payload[:size])
+
+		if fromClient {
+			c.clientSeq += uint32(size)
+		} else {
+			c.serverSeq += uint32(size)
+		}
+
+		payload = payload[size:]
+	}
+}
+
+func (c *capturedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.data(true, b[:n])
+	}
+
+	return n, err
+}
+
+func (c *capturedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.data(false, b[:n])
+	}
+
+	return n, err
+}
+
+// Close captures the teardown segments, the server closes the connection.
+func (c *capturedConn) Close() error {
+	c.mu.Lock()
+
+	if !c.closed {
+		c.closed = true
+
+		c.send(false, tcpFIN|tcpACK, nil)
+		c.serverSeq++
+		c.send(true, tcpFIN|tcpACK, nil)
+		c.clientSeq++
+		c.send(false, tcpACK, nil)
+	}
+
+	c.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+func (c *capturedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// tcpPacket returns an IPv4 (or IPv6) packet of the TCP segment, with valid checksums.
+func tcpPacket(src, dst *net.TCPAddr, seq, ack uint32, flags byte, payload []byte) []byte {
+	segment := make([]byte, 20+len(payload))
+
+	binary.BigEndian.PutUint16(segment[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(segment[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint32(segment[4:], seq)
+
+	if flags&tcpACK != 0 {
+		binary.BigEndian.PutUint32(segment[8:], ack)
+	}
+
+	segment[12] = 5 << 4 // data offset
+	segment[13] = flags
+	binary.BigEndian.PutUint16(segment[14:], 0xffff) // window
+	copy(segment[20:], payload)
+
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+
+	pseudo := make([]byte, 0, 2*len(srcIP)+8)
+	pseudo = append(pseudo, srcIP...)
+	pseudo = append(pseudo, dstIP...)
+	pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(segment)))
+	pseudo = append(pseudo, 0, 0, 0, 6) // protocol TCP
+
+	binary.BigEndian.PutUint16(segment[16:], checksum(pseudo, segment))
+
+	if len(srcIP) == net.IPv4len {
+		header := make([]byte, 20)
+
+		header[0] = 0x45 // version 4, header length 20
+		binary.BigEndian.PutUint16(header[2:], uint16(len(header)+len(segment)))
+		binary.BigEndian.PutUint16(header[6:], 0x4000) // don't fragment
+		header[8] = 64                                 // TTL
+		header[9] = 6                                  // protocol TCP
+		copy(header[12:], srcIP)
+		copy(header[16:], dstIP)
+		binary.BigEndian.PutUint16(header[10:], checksum(header))
+
+		return append(header, segment...)
+	}
+
+	header := make([]byte, 40)
+
+	header[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(header[4:], uint16(len(segment)))
+	header[6] = 6  // next header TCP
+	header[7] = 64 // hop limit
+	copy(header[8:], srcIP)
+	copy(header[24:], dstIP)
+
+	return append(header, segment...)
+}
+
+// checksum returns the internet checksum (RFC 1071) of the data.
+func checksum(data ...[]byte) uint16 {
+	var (
+		sum uint32
+		odd bool
+	)
+
+	for _, chunk := range data {
+		for _, b := range chunk {
+			if odd {
+				sum += uint32(b)
+			} else {
+				sum += uint32(b) << 8
+			}
+
+			odd = !odd
+		}
+	}
+
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,224 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureOptions(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getCapture(nil))
+
+	_, err := newCapture(&captureOptions{file: filepath.Join(t.TempDir(), "x"), format: "har"})
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
+
+// captureSession captures a request-response exchange and returns the capture file content.
+func captureSession(t *testing.T, opts *captureOptions) []byte {
+	t.Helper()
+
+	c, err := newCapture(opts)
+
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	listener = c.wrap(listener)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		buff := make([]byte, 4)
+
+		io.ReadFull(conn, buff)    // nolint:errcheck,gosec
+		conn.Write([]byte("pong")) // nolint:errcheck,gosec
+		conn.Close()               // nolint:errcheck,gosec
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("ping"))
+
+	require.NoError(t, err)
+
+	reply, err := io.ReadAll(conn)
+
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(reply))
+
+	<-done
+
+	require.NoError(t, conn.Close())
+	require.NoError(t, listener.Close())
+
+	_, err = c.Write([]byte("CLIENT_RANDOM 00 11\n"))
+
+	require.NoError(t, err)
+	require.NoError(t, c.close())
+
+	data, err := os.ReadFile(opts.file)
+
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestCapturePcapng(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	opts := &captureOptions{
+		file:   filepath.Join(dir, "session.pcapng"),
+		format: captureFormatPcapng,
+		keyLog: filepath.Join(dir, "keys.log"),
+	}
+	data := captureSession(t, opts)
+
+	var (
+		kinds   []uint32
+		packets [][]byte
+		secrets []byte
+	)
+
+	for len(data) != 0 {
+		kind := binary.LittleEndian.Uint32(data)
+		size := binary.LittleEndian.Uint32(data[4:])
+
+		require.Equal(t, size, binary.LittleEndian.Uint32(data[size-4:]))
+
+		body := data[8 : size-4]
+
+		switch kind {
+		case pcapngEnhancedPacket:
+			packets = append(packets, body[pcapngEnhancedPacketSize:pcapngEnhancedPacketSize+binary.LittleEndian.Uint32(body[12:])])
+		case pcapngDecryptionSecrets:
+			secrets = body[8 : 8+binary.LittleEndian.Uint32(body[4:])]
+		}
+
+		kinds = append(kinds, kind)
+		data = data[size:]
+	}
+
+	assert.Equal(t, []uint32{pcapngSectionHeader, pcapngInterface}, kinds[:2])
+	assert.Equal(t, "CLIENT_RANDOM 00 11\n", string(secrets))
+
+	// handshake, ping, pong, teardown
+	require.Len(t, packets, 8)
+
+	for _, packet := range packets {
+		assert.Equal(t, byte(0x45), packet[0])
+		assert.Zero(t, checksum(packet[:20]), "IP checksum")
+	}
+
+	assert.Equal(t, byte(tcpSYN), packets[0][33])
+	assert.Equal(t, "ping", string(packets[3][40:]))
+	assert.Equal(t, "pong", string(packets[4][40:]))
+	assert.Equal(t, byte(tcpFIN|tcpACK), packets[5][33])
+
+	// the acknowledgment of pong follows the sequence of ping
+	assert.Equal(t, binary.BigEndian.Uint32(packets[3][24:])+4, binary.BigEndian.Uint32(packets[4][28:]))
+
+	keys, err := os.ReadFile(opts.keyLog)
+
+	require.NoError(t, err)
+	assert.Equal(t, "CLIENT_RANDOM 00 11\n", string(keys))
+}
+
+func TestCapturePcap(t *testing.T) {
+	t.Parallel()
+
+	opts := &captureOptions{file: filepath.Join(t.TempDir(), "session.pcap"), format: captureFormatPcap}
+	data := captureSession(t, opts)
+
+	assert.Equal(t, uint32(pcapMagicMicroseconds), binary.LittleEndian.Uint32(data))
+	assert.Equal(t, uint32(linkTypeRaw), binary.LittleEndian.Uint32(data[20:]))
+
+	data = data[24:]
+	count := 0
+
+	for len(data) != 0 {
+		size := binary.LittleEndian.Uint32(data[8:])
+		data = data[16+size:]
+		count++
+	}
+
+	assert.Equal(t, 8, count)
+}
+
+func TestTCPPacket(t *testing.T) {
+	t.Parallel()
+
+	src := &net.TCPAddr{IP: net.IPv6loopback, Port: 50000}
+	dst := &net.TCPAddr{IP: net.IPv6loopback, Port: 443}
+
+	packet := tcpPacket(src, dst, 1, 2, tcpPSH|tcpACK, []byte("hello"))
+
+	require.Len(t, packet, 40+20+5)
+	assert.Equal(t, byte(0x60), packet[0])
+	assert.Equal(t, uint16(25), binary.BigEndian.Uint16(packet[4:]))
+	assert.Equal(t, uint16(443), binary.BigEndian.Uint16(packet[42:]))
+
+	pseudo := append(append(append([]byte(nil), packet[8:40]...), 0, 0, 0, 25), 0, 0, 0, 6)
+
+	assert.Zero(t, checksum(pseudo, packet[40:]), "TCP checksum")
+}
+
+func TestCaptureShared(t *testing.T) {
+	t.Parallel()
+
+	reg := newRegistry()
+	file := filepath.Join(t.TempDir(), "shared.pcap")
+
+	first, err := reg.openCapture(&captureOptions{file: file, format: captureFormatPcap})
+
+	require.NoError(t, err)
+
+	second, err := reg.openCapture(&captureOptions{file: file, format: captureFormatPcap})
+
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	_, err = reg.openCapture(&captureOptions{file: file, format: captureFormatPcapng})
+
+	assert.ErrorIs(t, err, errInvalidArg)
+
+	first.packet([]byte{1, 2, 3, 4})
+
+	require.NoError(t, reg.closeCapture(first))
+	assert.False(t, second.closed)
+
+	second.packet([]byte{5, 6, 7, 8})
+
+	require.NoError(t, reg.closeCapture(second))
+	assert.True(t, second.closed)
+	assert.Empty(t, reg.captures)
+
+	data, err := os.ReadFile(file) // nolint:gosec
+
+	require.NoError(t, err)
+	assert.Len(t, data, 24+2*(16+4)) // one header, two records
+}
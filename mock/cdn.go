@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	defaultPopHeader = "X-Pop"
+	defaultPop       = "edge"
+
+	headerServedBy = "X-Served-By"
+)
+
+// pop is a simulated CDN point of presence with its latency range.
+type pop struct {
+	name     string
+	min, max time.Duration
+}
+
+// cdn simulates the edge of a content delivery network. Requests are served by a point of presence
+// selected by request header (or randomly), with the latency of the point of presence.
+type cdn struct {
+	mu     sync.Mutex
+	pops   []*pop
+	header string
+	rand   *rand.Rand
+	sleep  func(*http.Request, time.Duration)
+}
+
+func newCDN(pops []*pop, header string) *cdn {
+	if len(pops) == 0 {
+		pops = []*pop{{name: defaultPop}}
+	}
+
+	if len(header) == 0 {
+		header = defaultPopHeader
+	}
+
+	return &cdn{
+		pops:   pops,
+		header: header,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())), // nolint:gosec
+		sleep:  sleepContext,
+	}
+}
+
+func sleepContext(r *http.Request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
+
+func millis(value interface{}) time.Duration {
+	num, _ := toFloat(value)
+
+	return time.Duration(num * float64(time.Millisecond))
+}
+
+// latencyRange parses a latency given in milliseconds, either as a number or as a [min, max] range.
+func latencyRange(value interface{}) (time.Duration, time.Duration) {
+	if list, ok := value.([]interface{}); ok {
+		if len(list) == 2 {
+			return millis(list[0]), millis(list[1])
+		}
+
+		return 0, 0
+	}
+
+	return millis(value), millis(value)
+}
+
+// randomLatency returns a random latency from the given range.
+func randomLatency(rnd *rand.Rand, low, high time.Duration) time.Duration {
+	if high > low {
+		return low + time.Duration(rnd.Int63n(int64(high-low)))
+	}
+
+	return low
+}
+
+// getCDN parses the cdn option. It is either a boolean or an object with pops and header properties.
+// Point of presence latency is given in milliseconds, either as a number or as a [min, max] range.
+func getCDN(value sobek.Value) *cdn {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if value.ToBoolean() {
+			return newCDN(nil, "")
+		}
+
+		return nil
+	}
+
+	var header string
+
+	if v := obj.Get("header"); v != nil && !sobek.IsUndefined(v) {
+		header = v.String()
+	}
+
+	var pops []*pop
+
+	if v := obj.Get("pops"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		if def, ok := v.Export().(map[string]interface{}); ok {
+			for _, name := range sortedKeys(def) {
+				p := &pop{name: name}
+
+				p.min, p.max = latencyRange(def[name])
+
+				pops = append(pops, p)
+			}
+		}
+	}
+
+	return newCDN(pops, header)
+}
+
+func (c *cdn) pick(r *http.Request) (*pop, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	selected := c.pops[c.rand.Intn(len(c.pops))]
+
+	if name := r.Header.Get(c.header); len(name) != 0 {
+		idx := sort.Search(len(c.pops), func(i int) bool { return c.pops[i].name >= name })
+		if idx < len(c.pops) && c.pops[idx].name == name {
+			selected = c.pops[idx]
+		}
+	}
+
+	return selected, randomLatency(c.rand, selected.min, selected.max)
+}
+
+// serve applies the latency of the selected point of presence and reports it in the X-Served-By header.
+func (c *cdn) serve(res *response, r *http.Request) {
+	selected, latency := c.pick(r)
+
+	c.sleep(r, latency)
+
+	res.header.Set(headerServedBy, selected.name)
+}
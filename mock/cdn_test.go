@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCDN(t *testing.T) {
+	t.Parallel()
+
+	edge := newCDN([]*pop{
+		{name: "fra", min: 20 * time.Millisecond, max: 20 * time.Millisecond},
+		{name: "iad", min: 80 * time.Millisecond, max: 120 * time.Millisecond},
+	}, "")
+
+	var slept time.Duration
+
+	edge.sleep = func(_ *http.Request, d time.Duration) { slept = d }
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(defaultPopHeader, "iad")
+
+	for idx := 0; idx < 10; idx++ {
+		res := newResponse()
+
+		edge.serve(res, req)
+
+		assert.Equal(t, "iad", res.header.Get(headerServedBy))
+		assert.GreaterOrEqual(t, slept, 80*time.Millisecond)
+		assert.Less(t, slept, 120*time.Millisecond)
+	}
+
+	req.Header.Set(defaultPopHeader, "fra")
+
+	res := newResponse()
+
+	edge.serve(res, req)
+
+	assert.Equal(t, "fra", res.header.Get(headerServedBy))
+	assert.Equal(t, 20*time.Millisecond, slept)
+
+	res = newResponse()
+
+	newCDN(nil, "").serve(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, defaultPop, res.header.Get(headerServedBy))
+}
+
+func TestCDNCacheHit(t *testing.T) {
+	t.Parallel()
+
+	edge := newCDN([]*pop{{name: "fra"}, {name: "iad"}}, "")
+	edge.sleep = func(_ *http.Request, _ time.Duration) {}
+
+	srv := &server{cache: newCache(edge), clock: newClock(time.Now)}
+
+	fill := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	srv.cache.store(fill, newCachedResponse(t, "max-age=60", "items"), nil, srv.clock.now())
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(defaultPopHeader, "iad")
+
+	res := newResponse()
+
+	srv.cached(res, req)
+
+	require.Equal(t, cacheHit, res.header.Get(headerXCache))
+	assert.Equal(t, "iad", res.header.Get(headerServedBy))
+}
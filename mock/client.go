@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"net"
+	"net/http"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	headerClient = "X-Mock-Client"
+	headerCohort = "X-Mock-Cohort"
+)
+
+// cohort is a named fraction of the clients, clients are assigned by the hash of their identifier.
+type cohort struct {
+	name  string
+	until float64 // upper bound of the cohort's hash range
+}
+
+// clientKeying identifies the clients of a mock server and assigns them to cohorts, so a fraction
+// of the clients consistently experience different behavior throughout the test.
+type clientKeying struct {
+	header  string
+	cookie  string
+	port    bool
+	cohorts []cohort
+}
+
+// getClientKeying parses the clients option: the client identifier source (header, cookie or
+// source port) and the cohorts (name to fraction of the clients).
+func getClientKeying(value sobek.Value) *clientKeying {
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return nil
+	}
+
+	spec, ok := obj.Export().(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	keying := new(clientKeying)
+
+	keying.header, _ = spec["header"].(string)
+	keying.cookie, _ = spec["cookie"].(string)
+	keying.port, _ = spec["port"].(bool)
+
+	if len(keying.header) == 0 && len(keying.cookie) == 0 && !keying.port {
+		keying.header = defaultClientHeader
+	}
+
+	fractions, _ := spec["cohorts"].(map[string]interface{})
+	names := sortedKeys(fractions)
+
+	var total float64
+
+	for _, name := range names {
+		fraction, _ := toFloat(fractions[name])
+		if fraction <= 0 {
+			continue
+		}
+
+		total = math.Min(total+fraction, 1)
+		keying.cohorts = append(keying.cohorts, cohort{name: name, until: total})
+	}
+
+	return keying
+}
+
+// identify returns the identifier of the request's client: the header, the cookie or the source port,
+// the remote address if the identifier is missing.
+func (k *clientKeying) identify(r *http.Request) string {
+	if len(k.header) != 0 {
+		if id := r.Header.Get(k.header); len(id) != 0 {
+			return id
+		}
+	}
+
+	if len(k.cookie) != 0 {
+		if c, err := r.Cookie(k.cookie); err == nil && len(c.Value) != 0 {
+			return c.Value
+		}
+	}
+
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	if k.port {
+		return port
+	}
+
+	return host
+}
+
+// cohortOf returns the cohort of the client, empty string if the client is not in any cohort.
+func (k *clientKeying) cohortOf(client string) string {
+	sum := sha256.Sum256([]byte(client))
+	point := float64(binary.BigEndian.Uint64(sum[:8])) / float64(math.MaxUint64)
+
+	for _, c := range k.cohorts {
+		if point < c.until {
+			return c.name
+		}
+	}
+
+	return ""
+}
+
+// admits reports whether the route serves the cohort, routes without cohort option serve every client.
+func (r *route) admits(cohort string) bool {
+	if len(r.cohorts) == 0 {
+		return true
+	}
+
+	for _, name := range r.cohorts {
+		if name == cohort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getCohorts parses the cohort route option: a cohort name or an array of names.
+func getCohorts(value interface{}) []string {
+	switch val := value.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		names := make([]string, 0, len(val))
+
+		for _, name := range val {
+			if str, ok := name.(string); ok {
+				names = append(names, str)
+			}
+		}
+
+		return names
+	}
+
+	return nil
+}
+
+// keyClient passes the client identifier and cohort of the request to the application.
+func (srv *server) keyClient(r, out *http.Request) {
+	if srv.opts.clients == nil {
+		out.Header.Del(headerClient)
+		out.Header.Del(headerCohort)
+
+		return
+	}
+
+	client := srv.opts.clients.identify(r)
+
+	out.Header.Set(headerClient, client)
+	out.Header.Set(headerCohort, srv.opts.clients.cohortOf(client))
+}
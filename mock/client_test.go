@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientKeying(t *testing.T) {
+	t.Parallel()
+
+	keying := &clientKeying{header: "X-Client-Id", cookie: "sid"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:40000"
+
+	assert.Equal(t, "10.0.0.1", keying.identify(r))
+
+	r.AddCookie(&http.Cookie{Name: "sid", Value: "session"})
+
+	assert.Equal(t, "session", keying.identify(r))
+
+	r.Header.Set("X-Client-Id", "vu-1")
+
+	assert.Equal(t, "vu-1", keying.identify(r))
+
+	keying = &clientKeying{port: true}
+
+	assert.Equal(t, "40000", keying.identify(r))
+}
+
+func TestCohortOf(t *testing.T) {
+	t.Parallel()
+
+	keying := &clientKeying{cohorts: []cohort{{name: "canary", until: 0.1}, {name: "slow", until: 0.3}}}
+
+	counts := make(map[string]int)
+
+	for i := 0; i < 10000; i++ {
+		client := "vu-" + strconv.Itoa(i)
+		name := keying.cohortOf(client)
+
+		assert.Equal(t, name, keying.cohortOf(client), "assignment must be consistent")
+
+		counts[name]++
+	}
+
+	assert.InDelta(t, 1000, counts["canary"], 150)
+	assert.InDelta(t, 2000, counts["slow"], 200)
+	assert.InDelta(t, 7000, counts[""], 250)
+}
+
+func TestRouteAdmits(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, (&route{}).admits(""))
+	assert.True(t, (&route{cohorts: getCohorts("canary")}).admits("canary"))
+	assert.False(t, (&route{cohorts: getCohorts([]interface{}{"canary", "slow"})}).admits(""))
+	assert.Nil(t, getCohorts(42))
+}
@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// clock is the virtual clock of a mock server. It can be frozen, moved and skewed
+// independently of the wall clock.
+type clock struct {
+	mu     sync.Mutex
+	wall   func() time.Time
+	origin time.Time // wall clock time of the last adjustment
+	value  time.Time // virtual time at origin
+	frozen bool
+
+	offset time.Duration // systematic skew of emitted timestamps
+	drift  float64       // skew growing with elapsed time, e.g. 0.01 means 36s per hour
+	since  time.Time     // wall clock time of the skew configuration
+}
+
+func newClock(wall func() time.Time) *clock {
+	now := wall()
+
+	return &clock{wall: wall, origin: now, value: now}
+}
+
+func (c *clock) current() time.Time {
+	if c.frozen {
+		return c.value
+	}
+
+	return c.value.Add(c.wall().Sub(c.origin))
+}
+
+func (c *clock) delta() time.Duration {
+	return c.offset + time.Duration(c.drift*float64(c.wall().Sub(c.since)))
+}
+
+// now returns the virtual time, without the configured skew.
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current()
+}
+
+// emitted returns the virtual time including the configured skew. The skew is applied only where the
+// timestamps leave the server (Date header, HTTP dates and the response body, see skewBody), so it is
+// not applied twice.
+func (c *clock) emitted() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current().Add(c.delta())
+}
+
+// skew configures a systematic offset and drift applied to all timestamps emitted by the server.
+func (c *clock) skew(offset time.Duration, drift float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.offset = offset
+	c.drift = drift
+	c.since = c.wall()
+}
+
+func (c *clock) skewed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.offset != 0 || c.drift != 0
+}
+
+// skewDelta returns the current skew.
+func (c *clock) skewDelta() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.delta()
+}
+
+func (c *clock) adjust(fn func(now time.Time) time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.value = fn(c.current())
+	c.origin = c.wall()
+}
+
+func (c *clock) set(t time.Time) {
+	c.adjust(func(time.Time) time.Time { return t })
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.adjust(func(now time.Time) time.Time { return now.Add(d) })
+}
+
+func (c *clock) freeze() {
+	c.adjust(func(now time.Time) time.Time { return now })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frozen = true
+}
+
+func (c *clock) resume() {
+	c.adjust(func(now time.Time) time.Time { return now })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frozen = false
+}
+
+func (c *clock) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.origin = c.wall()
+	c.value = c.origin
+	c.frozen = false
+	c.offset = 0
+	c.drift = 0
+}
+
+// exportTime converts a JavaScript time value (Date, milliseconds since epoch or RFC 3339 string) to time.Time.
+func (mod *Module) exportTime(value sobek.Value) time.Time {
+	switch v := value.Export().(type) {
+	case time.Time:
+		return v
+	case int64:
+		return time.UnixMilli(v)
+	case float64:
+		return time.UnixMilli(int64(v))
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			mod.throwf("invalid time %s", errInvalidArg, v)
+		}
+
+		return t
+	}
+
+	mod.throwf("invalid time %s", errInvalidArg, value.String())
+
+	return time.Time{}
+}
+
+// clockObject returns the JavaScript interface of the server's virtual clock.
+func (srv *server) clockObject() *sobek.Object {
+	runtime := srv.mod.runtime()
+	obj := runtime.NewObject()
+
+	set := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	set("now", func() int64 { return srv.clock.now().UnixMilli() })
+	set("date", func() sobek.Value {
+		date, err := runtime.New(runtime.Get("Date"), runtime.ToValue(srv.clock.now().UnixMilli()))
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		return date
+	})
+	set("httpDate", func(offset int64) string {
+		return srv.clock.emitted().Add(time.Duration(offset) * time.Millisecond).UTC().Format(http.TimeFormat)
+	})
+	set("set", func(value sobek.Value) sobek.Value {
+		srv.clock.set(srv.mod.exportTime(value))
+
+		return obj
+	})
+	set("advance", func(millis int64) sobek.Value {
+		srv.clock.advance(time.Duration(millis) * time.Millisecond)
+
+		return obj
+	})
+	set("freeze", func(call sobek.FunctionCall) sobek.Value {
+		if len(call.Arguments) != 0 && !sobek.IsUndefined(call.Argument(0)) {
+			srv.clock.set(srv.mod.exportTime(call.Argument(0)))
+		}
+
+		srv.clock.freeze()
+
+		return obj
+	})
+	set("resume", func() sobek.Value {
+		srv.clock.resume()
+
+		return obj
+	})
+	set("skew", func(offset int64, drift float64) sobek.Value {
+		srv.clock.skew(time.Duration(offset)*time.Millisecond, drift)
+
+		return obj
+	})
+	set("reset", func() sobek.Value {
+		srv.clock.reset()
+
+		return obj
+	})
+
+	return obj
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	wall := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	clk := newClock(func() time.Time { return wall })
+
+	assert.Equal(t, wall, clk.now())
+
+	wall = wall.Add(time.Second)
+
+	assert.Equal(t, wall, clk.now())
+
+	clk.advance(time.Hour)
+
+	assert.Equal(t, wall.Add(time.Hour), clk.now())
+
+	clk.freeze()
+	wall = wall.Add(time.Minute)
+
+	assert.Equal(t, wall.Add(time.Hour-time.Minute), clk.now())
+
+	clk.resume()
+	wall = wall.Add(time.Minute)
+
+	assert.Equal(t, wall.Add(time.Hour-time.Minute), clk.now())
+
+	fixed := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	clk.set(fixed)
+
+	assert.Equal(t, fixed, clk.now())
+
+	clk.reset()
+
+	assert.Equal(t, wall, clk.now())
+}
+
+func TestRecorderDate(t *testing.T) {
+	t.Parallel()
+
+	fixed := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	w := httptest.NewRecorder()
+	rec := newRecorder(w, newClock(func() time.Time { return fixed }))
+
+	rec.Header().Set(headerRoute, "1")
+	rec.WriteHeader(http.StatusOK)
+
+	assert.Equal(t, "Sat, 01 Jun 2030 12:00:00 GMT", w.Header().Get("Date"))
+	assert.Empty(t, w.Header().Get(headerRoute))
+	assert.Equal(t, "1", rec.control.Get(headerRoute))
+}
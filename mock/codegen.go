@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// skippedHeaders are request headers set by the HTTP client itself, so they are omitted from generated code.
+var skippedHeaders = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"User-Agent":        true,
+	"X-Forwarded-For":   true,
+	"X-Forwarded-Host":  true,
+	"X-Forwarded-Proto": true,
+}
+
+func jsString(str string) string {
+	data, _ := json.Marshal(str) // nolint:errchkjson
+
+	return string(data)
+}
+
+func jsParams(header http.Header) string {
+	fields := make([]string, 0, len(header))
+
+	for _, name := range sortedKeys(header) {
+		if !skippedHeaders[name] {
+			fields = append(fields, jsString(name)+": "+jsString(strings.Join(header[name], ", ")))
+		}
+	}
+
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return "{ headers: { " + strings.Join(fields, ", ") + " } }"
+}
+
+// codegenCall returns a k6 http API call equivalent to the recorded request.
+func codegenCall(target string, record *entry) string {
+	loc := jsString(mockURL(target, record))
+	params := jsParams(record.header)
+
+	body := "null"
+	if reqBody := record.requestBody(); len(reqBody) != 0 {
+		body = jsString(string(reqBody))
+	}
+
+	var args []string
+
+	switch record.method {
+	case http.MethodGet, http.MethodHead:
+		args = []string{loc}
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		args = []string{loc, body}
+	default:
+		args = []string{jsString(record.method), loc, body}
+	}
+
+	if len(params) != 0 {
+		args = append(args, params)
+	}
+
+	fn := map[string]string{
+		http.MethodGet:     "get",
+		http.MethodHead:    "head",
+		http.MethodPost:    "post",
+		http.MethodPut:     "put",
+		http.MethodPatch:   "patch",
+		http.MethodDelete:  "del",
+		http.MethodOptions: "options",
+	}[record.method]
+
+	if len(fn) == 0 {
+		fn = "request"
+	}
+
+	return "http." + fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+// codegen returns a k6 script replaying the recorded requests.
+func codegen(target string, records []*entry) string {
+	var buff strings.Builder
+
+	buff.WriteString("import http from 'k6/http'\n\nexport default function () {\n")
+
+	for _, record := range records {
+		buff.WriteString("  " + codegenCall(target, record) + "\n")
+	}
+
+	buff.WriteString("}\n")
+
+	return buff.String()
+}
+
+// exportScript returns a k6 script generated from the recorded requests and optionally writes it to the given file.
+func (srv *server) exportScript(filename string) (string, error) {
+	script := codegen(srv.target, srv.journal.snapshot())
+
+	if len(filename) == 0 {
+		return script, nil
+	}
+
+	return script, os.WriteFile(filename, []byte(script), 0o600)
+}
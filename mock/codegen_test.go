@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodegen(t *testing.T) {
+	t.Parallel()
+
+	records := []*entry{
+		{
+			method: http.MethodGet,
+			path:   "/orders",
+			query:  url.Values{"page": {"2"}},
+			header: http.Header{"User-Agent": {"k6"}},
+		},
+		{
+			method: http.MethodPost,
+			path:   "/orders",
+			query:  url.Values{},
+			header: http.Header{"Content-Type": {"application/json"}, "Content-Length": {"15"}},
+			body:   []byte(`{"item":"book"}`),
+		},
+		{
+			method: http.MethodDelete,
+			path:   "/orders/1",
+			query:  url.Values{},
+			header: http.Header{},
+		},
+		{
+			method: "PURGE",
+			path:   "/cache",
+			query:  url.Values{},
+			header: http.Header{},
+		},
+	}
+
+	script := codegen("https://example.com", records)
+
+	assert.Contains(t, script, "import http from 'k6/http'")
+	assert.Contains(t, script, `http.get("https://example.com/orders?page=2")`)
+	assert.Contains(t, script, `http.post("https://example.com/orders", "{\"item\":\"book\"}", { headers: { "Content-Type": "application/json" } })`)
+	assert.Contains(t, script, `http.del("https://example.com/orders/1", null)`)
+	assert.Contains(t, script, `http.request("PURGE", "https://example.com/cache", null)`)
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.k6.io/k6/metrics"
+)
+
+type (
+	connContextKey  struct{}
+	helloContextKey struct{}
+)
+
+// connStats are the statistics of a client connection.
+type connStats struct {
+	id       int
+	remote   string
+	opened   time.Time
+	closed   time.Time
+	requests int64
+	bytesIn  int64
+	bytesOut int64
+	order    ordering
+}
+
+// trackedConn counts the bytes transferred on the connection (TLS overhead included).
+type trackedConn struct {
+	net.Conn
+	stats   *connStats
+	once    sync.Once
+	onClose func(*connStats)
+}
+
+func (c *trackedConn) Read(data []byte) (int, error) {
+	n, err := c.Conn.Read(data)
+
+	atomic.AddInt64(&c.stats.bytesIn, int64(n))
+
+	return n, err
+}
+
+func (c *trackedConn) Write(data []byte) (int, error) {
+	n, err := c.Conn.Write(data)
+
+	atomic.AddInt64(&c.stats.bytesOut, int64(n))
+
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.onClose(c.stats) })
+
+	return c.Conn.Close()
+}
+
+// connTracker tracks the client connections of a mock server, the most recent connections are kept.
+type connTracker struct {
+	mu      sync.Mutex
+	seq     int
+	conns   []*connStats
+	onOpen  func(*connStats)
+	onClose func(*connStats)
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{onOpen: func(*connStats) {}, onClose: func(*connStats) {}}
+}
+
+func (t *connTracker) open(conn net.Conn) *trackedConn {
+	t.mu.Lock()
+
+	t.seq++
+
+	stats := &connStats{id: t.seq, remote: conn.RemoteAddr().String(), opened: time.Now()}
+
+	if len(t.conns) >= journalLimit {
+		t.conns = t.conns[1:]
+	}
+
+	t.conns = append(t.conns, stats)
+
+	t.mu.Unlock()
+
+	t.onOpen(stats)
+
+	return &trackedConn{Conn: conn, stats: stats, onClose: func(stats *connStats) {
+		t.mu.Lock()
+		stats.closed = time.Now()
+		t.mu.Unlock()
+
+		t.onClose(stats)
+	}}
+}
+
+// export returns the statistics of the connections with the requests served on them.
+func (t *connTracker) export(entries []*entry) []interface{} {
+	log := make(map[int][]interface{})
+
+	for _, record := range entries {
+		if record.conn != 0 {
+			log[record.conn] = append(log[record.conn], map[string]interface{}{
+				"method": record.method,
+				"path":   record.path,
+				"status": record.status,
+				"time":   record.time.UnixMilli(),
+			})
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]interface{}, 0, len(t.conns))
+
+	for _, stats := range t.conns {
+		end := stats.closed
+		if end.IsZero() {
+			end = time.Now()
+		}
+
+		out = append(out, map[string]interface{}{
+			"id":       stats.id,
+			"remote":   stats.remote,
+			"opened":   stats.opened.UnixMilli(),
+			"open":     stats.closed.IsZero(),
+			"lifetime": float64(end.Sub(stats.opened)) / float64(time.Millisecond),
+			"requests": atomic.LoadInt64(&stats.requests),
+			"bytesIn":  atomic.LoadInt64(&stats.bytesIn),
+			"bytesOut": atomic.LoadInt64(&stats.bytesOut),
+			"log":      log[stats.id],
+		})
+	}
+
+	return out
+}
+
+// trackingListener tracks the accepted connections.
+type trackingListener struct {
+	net.Listener
+	tracker *connTracker
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return l.tracker.open(conn), nil
+}
+
+// connContext stores the statistics and the TLS fingerprint of the connection in its context, see
+// http.Server.ConnContext.
+func connContext(ctx context.Context, conn net.Conn) context.Context {
+	for conn != nil {
+		switch conn := conn.(type) {
+		case *trackedConn:
+			return context.WithValue(ctx, connContextKey{}, conn.stats)
+		case *helloConn:
+			ctx = context.WithValue(ctx, helloContextKey{}, conn)
+		}
+
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+
+		conn = wrapper.NetConn()
+	}
+
+	return ctx
+}
+
+// connOf returns the statistics of the request's connection.
+func connOf(ctx context.Context) *connStats {
+	stats, _ := ctx.Value(connContextKey{}).(*connStats)
+
+	return stats
+}
+
+// connMetrics emits the connection metrics of the server.
+func (srv *server) connMetrics() {
+	tags := map[string]string{"mock": srv.target}
+
+	srv.conns.onOpen = func(*connStats) {
+		srv.mod.push(srv.mod.metrics.conns, 1, tags)
+	}
+
+	srv.conns.onClose = func(stats *connStats) {
+		srv.mod.push(srv.mod.metrics.connDuration, metrics.D(stats.closed.Sub(stats.opened)), tags)
+		srv.mod.push(srv.mod.metrics.connRequests, float64(atomic.LoadInt64(&stats.requests)), tags)
+		srv.mod.push(srv.mod.metrics.connDataReceived, float64(atomic.LoadInt64(&stats.bytesIn)), tags)
+		srv.mod.push(srv.mod.metrics.connDataSent, float64(atomic.LoadInt64(&stats.bytesOut)), tags)
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnTracker(t *testing.T) {
+	t.Parallel()
+
+	tracker := newConnTracker()
+
+	var closed int
+
+	tracker.onClose = func(*connStats) { closed++ }
+
+	client, server := net.Pipe()
+
+	defer client.Close() // nolint:errcheck
+
+	conn := tracker.open(server)
+
+	assert.Equal(t, 1, conn.stats.id)
+
+	conn.Close() // nolint:errcheck,gosec
+	conn.Close() // nolint:errcheck,gosec
+
+	assert.Equal(t, 1, closed)
+	assert.False(t, conn.stats.closed.IsZero())
+
+	tracker.open(server)
+
+	out := tracker.export([]*entry{{conn: 1, method: http.MethodGet, path: "/", status: 200, time: time.Now()}})
+
+	require.Len(t, out, 2)
+
+	first, _ := out[0].(map[string]interface{})
+	second, _ := out[1].(map[string]interface{})
+
+	assert.Equal(t, false, first["open"])
+	assert.Len(t, first["log"], 1)
+	assert.Equal(t, 2, second["id"])
+	assert.Equal(t, true, second["open"])
+	assert.Nil(t, second["log"])
+}
+
+func TestTrackingListener(t *testing.T) {
+	t.Parallel()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	tracker := newConnTracker()
+	listener := &trackingListener{Listener: tcp, tracker: tracker}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stats := connOf(r.Context()); stats != nil {
+			atomic.AddInt64(&stats.requests, 1)
+		}
+
+		w.Write([]byte("Hello, World!")) // nolint:errcheck,gosec
+	})
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: time.Second, ConnContext: connContext} // nolint:exhaustruct
+
+	go server.Serve(listener) // nolint:errcheck
+
+	defer server.Close() // nolint:errcheck
+
+	client := &http.Client{} // nolint:exhaustruct
+
+	for i := 0; i < 3; i++ {
+		res, err := client.Get("http://" + tcp.Addr().String()) // nolint:noctx
+
+		require.NoError(t, err)
+
+		io.Copy(io.Discard, res.Body) // nolint:errcheck,gosec
+		res.Body.Close()              // nolint:errcheck,gosec
+	}
+
+	out := tracker.export(nil)
+
+	require.Len(t, out, 1)
+
+	stats, _ := out[0].(map[string]interface{})
+
+	assert.Equal(t, int64(3), stats["requests"])
+	assert.Greater(t, stats["bytesIn"], int64(0))
+	assert.Greater(t, stats["bytesOut"], int64(0))
+}
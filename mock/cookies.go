@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// cookieRewriter rewrites the attributes of the cookies set by the responses, so cookies issued for
+// the original domain remain usable against the mock server's address.
+type cookieRewriter struct {
+	domain string // the Domain attribute of the rewritten cookies, host-only cookies if empty
+	prefix string // path prefix of the mock target, removed from the Path attributes
+	secure bool   // keep the Secure attribute (the mock server is plain HTTP)
+}
+
+// getCookies parses the rewriteCookies option. It is either a boolean or an object
+// with domain and secure properties.
+func getCookies(value sobek.Value) *cookieRewriter {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	rewriter := new(cookieRewriter)
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if !value.ToBoolean() {
+			return nil
+		}
+
+		return rewriter
+	}
+
+	if v := obj.Get("domain"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		rewriter.domain = v.String()
+	}
+
+	if v := obj.Get("secure"); v != nil {
+		rewriter.secure = v.ToBoolean()
+	}
+
+	return rewriter
+}
+
+// forTarget returns a copy of the rewriter removing the path prefix of the given mock target.
+func (cr *cookieRewriter) forTarget(target string) *cookieRewriter {
+	rewriter := *cr
+
+	if loc, err := url.Parse(target); err == nil {
+		rewriter.prefix = strings.TrimSuffix(loc.Path, "/")
+	}
+
+	return &rewriter
+}
+
+// rewrite replaces the Set-Cookie headers with the rewritten cookies.
+func (cr *cookieRewriter) rewrite(header http.Header) {
+	cookies := (&http.Response{Header: header}).Cookies() // nolint:exhaustruct
+	if len(cookies) == 0 {
+		return
+	}
+
+	header.Del("Set-Cookie")
+
+	for _, cookie := range cookies {
+		cookie.Domain = cr.domain
+		cookie.Path = cr.path(cookie.Path)
+
+		if !cr.secure {
+			cookie.Secure = false
+
+			// SameSite=None cookies are rejected without the Secure attribute
+			if cookie.SameSite == http.SameSiteNoneMode {
+				cookie.SameSite = http.SameSiteLaxMode
+			}
+		}
+
+		header.Add("Set-Cookie", cookie.String())
+	}
+}
+
+func (cr *cookieRewriter) path(path string) string {
+	if len(cr.prefix) == 0 || len(path) == 0 {
+		return path
+	}
+
+	if path == cr.prefix {
+		return "/"
+	}
+
+	if strings.HasPrefix(path, cr.prefix+"/") {
+		return strings.TrimPrefix(path, cr.prefix)
+	}
+
+	return path
+}
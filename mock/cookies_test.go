@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieRewriter(t *testing.T) {
+	t.Parallel()
+
+	header := make(http.Header)
+
+	header.Add("Set-Cookie", "session=abc; Domain=example.com; Path=/api/user; Secure; HttpOnly; SameSite=None")
+	header.Add("Set-Cookie", "theme=dark; Path=/api")
+	header.Add("Set-Cookie", "lang=en; Path=/apidocs")
+
+	new(cookieRewriter).forTarget("https://example.com/api/").rewrite(header)
+
+	assert.Equal(t, []string{
+		"session=abc; Path=/user; HttpOnly; SameSite=Lax",
+		"theme=dark; Path=/",
+		"lang=en; Path=/apidocs",
+	}, header.Values("Set-Cookie"))
+
+	header = make(http.Header)
+
+	header.Add("Set-Cookie", "session=abc; Domain=example.com; Path=/; Secure")
+
+	(&cookieRewriter{domain: "localhost", secure: true}).forTarget("https://example.com").rewrite(header)
+
+	assert.Equal(t, []string{"session=abc; Path=/; Domain=localhost; Secure"}, header.Values("Set-Cookie"))
+
+	header = make(http.Header)
+
+	new(cookieRewriter).rewrite(header)
+
+	assert.Empty(t, header.Values("Set-Cookie"))
+}
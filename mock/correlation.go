@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+
+	"github.com/grafana/sobek"
+)
+
+const defaultCorrelationHeader = "X-Request-Id"
+
+// getCorrelation parses the correlation option: true (X-Request-Id header) or the name of the header.
+func getCorrelation(value sobek.Value) string {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return ""
+	}
+
+	if val, isBool := value.Export().(bool); isBool {
+		if !val {
+			return ""
+		}
+
+		return defaultCorrelationHeader
+	}
+
+	return http.CanonicalHeaderKey(value.String())
+}
+
+// correlate returns the correlation ID of the request, a new one is generated and set on the request
+// if the client didn't send it. The ID is echoed in the response header.
+// Without correlation option the empty string is returned.
+func (srv *server) correlate(r *http.Request, rec *recorder) string {
+	name := srv.opts.correlation
+	if len(name) == 0 {
+		return ""
+	}
+
+	id := r.Header.Get(name)
+	if len(id) == 0 {
+		id = randomID()
+		r.Header.Set(name, id)
+	}
+
+	decorate := rec.decorate
+
+	rec.decorate = func(control, header http.Header) {
+		if decorate != nil {
+			decorate(control, header)
+		}
+
+		header.Set(name, id)
+	}
+
+	return id
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelate(t *testing.T) {
+	t.Parallel()
+
+	srv := &server{opts: &options{}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	assert.Empty(t, srv.correlate(r, newRecorder(w, nil)))
+
+	srv.opts.correlation = defaultCorrelationHeader
+
+	rec := newRecorder(w, nil)
+	id := srv.correlate(r, rec)
+
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, r.Header.Get("X-Request-Id"))
+
+	rec.WriteHeader(http.StatusNoContent)
+
+	assert.Equal(t, id, w.Header().Get("X-Request-Id"))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "client-id")
+
+	w = httptest.NewRecorder()
+	rec = newRecorder(w, nil)
+
+	assert.Equal(t, "client-id", srv.correlate(r, rec))
+
+	rec.WriteHeader(http.StatusOK)
+
+	assert.Equal(t, "client-id", w.Header().Get("X-Request-Id"))
+}
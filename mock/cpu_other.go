@@ -0,0 +1,14 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !unix
+
+package mock
+
+import "time"
+
+// processCPU reports the CPU time of the process as not available.
+func processCPU() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,23 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+//go:build unix
+
+package mock
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPU returns the CPU time (user and system) consumed by the process.
+func processCPU() (time.Duration, bool) {
+	var usage syscall.Rusage
+
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
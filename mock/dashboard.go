@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dashboardEnv is the environment variable holding the listen address of the dashboard.
+	dashboardEnv = "K6_MOCK_DASHBOARD"
+	// dashboardRedactEnv is the environment variable holding the comma separated names of the request
+	// headers redacted in the events besides the sensitive headers (see sensitiveHeaders).
+	dashboardRedactEnv = "K6_MOCK_DASHBOARD_REDACT"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// newDashboard returns the handler of the dashboard, the API endpoints are guarded by the access control
+// (the page itself is public, it passes its token query parameter to the API).
+func newDashboard(events *hub, stats *statistics, access *accessControl) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardHTML) // nolint:errcheck
+	})
+
+	mux.HandleFunc("/api/events", access.guard(scopeEvents, func(w http.ResponseWriter, r *http.Request) {
+		since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+
+		writeJSON(w, events.since(since))
+	}))
+
+	mux.HandleFunc("/api/stream", access.guard(scopeEvents, func(w http.ResponseWriter, r *http.Request) {
+		stream(w, r, events)
+	}))
+
+	mux.HandleFunc("/api/summary", access.guard(scopeSummary, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.export())
+	}))
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// stream sends events as server-sent events until the client disconnects.
+// The standard Last-Event-ID header (or since query parameter) can be used to resume the stream.
+func stream(w http.ResponseWriter, r *http.Request, events *hub) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+
+		return
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if len(last) == 0 {
+		last = r.URL.Query().Get("since")
+	}
+
+	since, _ := strconv.ParseUint(last, 10, 64)
+
+	ch, cancel := events.subscribe(since)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "id: %d\nevent: request\ndata: %s\n\n", ev.ID, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// startDashboard starts the dashboard web server once per process, if enabled by environment variable.
+// The API requires bearer tokens if tokens are given, the dashboard is served over TLS if certificate
+// and key files are given. Invalid token specification prevents starting the dashboard.
+func (root *RootModule) startDashboard(lookupEnv func(string) (string, bool), logger logrus.FieldLogger) {
+	if lookupEnv == nil {
+		return
+	}
+
+	addr, found := lookupEnv(dashboardEnv)
+	if !found || len(addr) == 0 {
+		return
+	}
+
+	env := func(name string) string {
+		value, _ := lookupEnv(name)
+
+		return value
+	}
+
+	root.dashboard.Do(func() {
+		access, err := getAccessControl(env(dashboardTokensEnv))
+		if err != nil {
+			logger.WithError(err).Error("mock dashboard not started")
+
+			return
+		}
+
+		for _, name := range strings.Split(env(dashboardRedactEnv), ",") {
+			if name = strings.TrimSpace(name); len(name) != 0 {
+				root.hub.redact(name)
+			}
+		}
+
+		cert, key := env(dashboardCertEnv), env(dashboardKeyEnv)
+
+		srv := &http.Server{Addr: addr, Handler: newDashboard(root.hub, root.stats, access), ReadHeaderTimeout: readHeaderTimeout} // nolint:exhaustruct
+
+		go func() {
+			var err error
+
+			if len(cert) != 0 && len(key) != 0 {
+				err = srv.ListenAndServeTLS(cert, key)
+			} else {
+				err = srv.ListenAndServe()
+			}
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.WithError(err).Error("mock dashboard stopped")
+			}
+		}()
+
+		fields := logrus.Fields{"addr": addr, "tls": len(cert) != 0 && len(key) != 0}
+
+		if access != nil {
+			fields["tokens"] = access.export()
+		}
+
+		logger.WithFields(fields).Info("mock dashboard started")
+	})
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	t.Parallel()
+
+	events := newHub()
+
+	events.redact("x-session")
+	events.publish(&event{Target: "https://example.com", Method: http.MethodGet, Path: "/", Status: 200})
+	events.publish(&event{
+		Target: "https://example.com", Method: http.MethodGet, Path: "/missing", Status: 404,
+		Header: map[string]string{"Authorization": "Bearer secret", "X-Session": "42", "Accept": "*/*"},
+	})
+
+	handler := newDashboard(events, newStatistics(), nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "xk6-mock dashboard")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events?since=1", nil))
+
+	var found []*event
+
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &found))
+	assert.Len(t, found, 1)
+	assert.Equal(t, "/missing", found[0].Path)
+	assert.Equal(t, map[string]string{"Authorization": redactedValue, "X-Session": redactedValue, "Accept": "*/*"}, found[0].Header)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/summary", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"servers"`)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDashboardEventSource(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.stub("POST", "/orders/:id").returns({ status: 201, body: { ok: true } })
+	app.get("/drop", (req, res) => res.fault("empty-response"))
+	app.get("/", (req, res) => res.text("Hello World!"))
+}, { sync: true, faults: [{ during: "0s", route: "/pay", errorRate: 1, status: 503 }] })
+`)
+
+	require.NoError(t, err)
+
+	base := helper.module.lookup["https://example.com"]
+
+	for _, path := range []string{"/", "/pay", "/drop"} {
+		res, err := http.Get(base + path) // nolint:noctx
+		if err == nil {
+			res.Body.Close() // nolint:errcheck,gosec
+		}
+	}
+
+	res, err := http.Post(base+"/orders/1", "application/json", strings.NewReader("{}")) // nolint:noctx
+
+	require.NoError(t, err)
+	res.Body.Close() // nolint:errcheck,gosec
+
+	// the events are published after the response was written
+	require.Eventually(t, func() bool { return len(helper.module.hub.since(0)) == 4 }, time.Second, 5*time.Millisecond)
+
+	events := make(map[string]*event)
+
+	for _, ev := range helper.module.hub.since(0) {
+		events[ev.Path] = ev
+	}
+
+	assert.Empty(t, events["/"].Stub)
+	assert.Empty(t, events["/"].Fault)
+	assert.Equal(t, "POST /orders/:id", events["/orders/1"].Stub)
+	assert.Empty(t, events["/orders/1"].Fault)
+	assert.Equal(t, faultStatus, events["/pay"].Fault)
+	assert.Equal(t, http.StatusServiceUnavailable, events["/pay"].Status)
+	assert.Equal(t, faultEmptyResponse, events["/drop"].Fault)
+}
+
+func TestDashboardStream(t *testing.T) {
+	t.Parallel()
+
+	events := newHub()
+
+	events.publish(&event{Target: "https://example.com", Method: http.MethodGet, Path: "/first"})
+
+	srv := httptest.NewServer(newDashboard(events, newStatistics(), nil))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/stream", nil) // nolint:noctx
+	assert.NoError(t, err)
+
+	req.Header.Set("Last-Event-ID", "1")
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	defer res.Body.Close() // nolint:errcheck
+
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	events.publish(&event{Target: "https://example.com", Method: http.MethodGet, Path: "/second"})
+
+	reader := bufio.NewReader(res.Body)
+
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "id: 2\n", line)
+
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "event: request\n", line)
+
+	line, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Contains(t, line, `"path":"/second"`)
+}
+
+func TestDashboardAccess(t *testing.T) {
+	t.Parallel()
+
+	access, err := getAccessControl("reader:events, ops")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*", "events"}, access.export())
+
+	handler := newDashboard(newHub(), newStatistics(), access)
+
+	get := func(path, auth string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+
+		if len(auth) != 0 {
+			req.Header.Set("Authorization", auth)
+		}
+
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, get("/", "").Code)
+
+	rec := get("/api/events", "")
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="xk6-mock"`, rec.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(t, http.StatusUnauthorized, get("/api/events", "Bearer unknown").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/api/events", "Basic cmVhZGVy").Code)
+	assert.Equal(t, http.StatusOK, get("/api/events", "Bearer reader").Code)
+	assert.Equal(t, http.StatusOK, get("/api/events?token=reader", "").Code)
+
+	rec = get("/api/summary", "Bearer reader")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+
+	assert.Equal(t, http.StatusOK, get("/api/summary", "bearer ops").Code)
+
+	access, err = getAccessControl("")
+
+	assert.NoError(t, err)
+	assert.Nil(t, access)
+
+	_, err = getAccessControl("ci:reconfigure")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+	assert.ErrorContains(t, err, "read-only")
+
+	_, err = getAccessControl(":events")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
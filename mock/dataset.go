@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// dataset is a versioned resource defined by the script: conditional GET requests (If-None-Match,
+// If-Modified-Since) are answered with 304 until the script mutates it, so polling clients can be tested.
+type dataset struct {
+	route *route
+
+	mu          sync.Mutex
+	version     int
+	modified    time.Time
+	body        []byte
+	contentType string
+}
+
+func newDataset(path string, content interface{}, now time.Time) (*dataset, error) {
+	ds := &dataset{route: &route{method: http.MethodGet, pattern: path, segments: compilePath(path)}}
+
+	if err := ds.mutate(content, now); err != nil {
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+// mutate increments the version of the dataset, the content is replaced unless it is nil.
+// Strings are served as text, other content is encoded as JSON.
+func (ds *dataset) mutate(content interface{}, now time.Time) error {
+	var (
+		body        []byte
+		contentType string
+	)
+
+	switch val := content.(type) {
+	case nil:
+	case string:
+		body, contentType = []byte(val), "text/plain; charset=utf-8"
+	case sobek.ArrayBuffer:
+		body, contentType = val.Bytes(), "application/octet-stream"
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+
+		body, contentType = data, "application/json"
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	modified := now.UTC().Truncate(time.Second)
+	if ds.version > 0 && !modified.After(ds.modified) {
+		modified = ds.modified.Add(time.Second) // If-Modified-Since has second precision
+	}
+
+	ds.version++
+	ds.modified = modified
+
+	if content != nil {
+		ds.body, ds.contentType = body, contentType
+	}
+
+	return nil
+}
+
+func (ds *dataset) etag() string {
+	return `"` + strconv.Itoa(ds.version) + `"`
+}
+
+// serve serves the dataset, the route is returned if the request was served.
+func (ds *dataset) serve(w http.ResponseWriter, r *http.Request) *route {
+	if !ds.route.accepts(r.Method) || !isMatch(ds.route, r.URL.Path) {
+		return nil
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	header := w.Header()
+
+	header.Set("ETag", ds.etag())
+	header.Set("Last-Modified", ds.modified.Format(http.TimeFormat))
+	header.Set("Cache-Control", "no-cache")
+
+	if ds.notModified(r) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return ds.route
+	}
+
+	header.Set("Content-Type", ds.contentType)
+	header.Set("Content-Length", strconv.Itoa(len(ds.body)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		w.Write(ds.body) // nolint:errcheck,gosec
+	}
+
+	return ds.route
+}
+
+// notModified evaluates the preconditions of the request, If-None-Match takes precedence over If-Modified-Since.
+func (ds *dataset) notModified(r *http.Request) bool {
+	if match := r.Header.Get("If-None-Match"); len(match) != 0 {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+			if tag == "*" || tag == ds.etag() {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+	return err == nil && !ds.modified.After(since)
+}
+
+func (ds *dataset) export() map[string]interface{} {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	return map[string]interface{}{
+		"version":  ds.version,
+		"etag":     ds.etag(),
+		"modified": ds.modified.UnixMilli(),
+	}
+}
+
+// served serves the request from a dataset, the route of the dataset is returned if it was served.
+func (srv *server) served(w http.ResponseWriter, r *http.Request) *route {
+	srv.datasetMu.RLock()
+	defer srv.datasetMu.RUnlock()
+
+	for _, ds := range srv.datasets {
+		if matched := ds.serve(w, r); matched != nil {
+			return matched
+		}
+	}
+
+	return nil
+}
+
+// wrapDatasets adds the dataset method to the application object.
+func (srv *server) wrapDatasets() {
+	srv.mustSet("dataset", func(path string, content sobek.Value) *sobek.Object {
+		if err := checkPath(path); err != nil {
+			srv.mod.throwf("invalid parameter constraint of dataset: %s", errInvalidArg, err)
+		}
+
+		ds, err := newDataset(path, exportContent(content), srv.clock.now())
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		srv.datasetMu.Lock()
+		srv.datasets = append(srv.datasets, ds)
+		srv.datasetMu.Unlock()
+
+		return srv.datasetObject(ds)
+	})
+}
+
+// exportContent exports the content of a dataset, undefined content is nil.
+func exportContent(value sobek.Value) interface{} {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	return value.Export()
+}
+
+func (srv *server) datasetObject(ds *dataset) *sobek.Object {
+	obj := srv.mod.runtime().NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	mustSet("mutate", func(content sobek.Value) *sobek.Object {
+		if err := ds.mutate(exportContent(content), srv.clock.now()); err != nil {
+			srv.mod.throw(err)
+		}
+
+		return obj
+	})
+
+	mustSet("status", ds.export)
+
+	return obj
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataset(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	ds, err := newDataset("/items", []interface{}{"a"}, now)
+
+	require.NoError(t, err)
+
+	get := func(path string, header map[string]string) (*route, *httptest.ResponseRecorder) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, value := range header {
+			r.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+
+		return ds.serve(w, r), w
+	}
+
+	matched, _ := get("/other", nil)
+
+	assert.Nil(t, matched)
+
+	matched, w := get("/items", nil)
+
+	require.NotNil(t, matched)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `["a"]`, w.Body.String())
+
+	_, w = get("/items", map[string]string{"If-None-Match": `"1"`})
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	_, w = get("/items", map[string]string{"If-Modified-Since": now.Format(http.TimeFormat)})
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	require.NoError(t, ds.mutate(nil, now))
+
+	_, w = get("/items", map[string]string{"If-None-Match": `W/"0", "1"`})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.JSONEq(t, `["a"]`, w.Body.String())
+
+	_, w = get("/items", map[string]string{"If-Modified-Since": now.Format(http.TimeFormat)})
+
+	assert.Equal(t, http.StatusOK, w.Code, "mutation in the same second is a newer modification")
+
+	require.NoError(t, ds.mutate("fresh", now.Add(time.Minute)))
+
+	_, w = get("/items", map[string]string{"If-None-Match": `"2"`})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "fresh", w.Body.String())
+
+	status := ds.export()
+
+	assert.Equal(t, 3, status["version"])
+	assert.Equal(t, `"3"`, status["etag"])
+}
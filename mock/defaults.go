@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+
+	"github.com/grafana/sobek"
+)
+
+// responseDefaults are applied to every response of the server, unless the route (or the handler)
+// overrides them.
+type responseDefaults struct {
+	headers http.Header
+	delay   *delay
+}
+
+// getResponseDefaults parses the defaults option: headers (set on the responses not setting them) and
+// delay (latency of the responses without route or response delay, see getDelay).
+func getResponseDefaults(value sobek.Value) *responseDefaults {
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return nil
+	}
+
+	defaults := new(responseDefaults)
+
+	if v := obj.Get("delay"); v != nil {
+		defaults.delay = getDelay(v.Export())
+	}
+
+	if headers, ok := obj.Get("headers").(*sobek.Object); ok {
+		defaults.headers = make(http.Header)
+
+		for _, key := range headers.Keys() {
+			defaults.headers.Set(key, headers.Get(key).String())
+		}
+	}
+
+	return defaults
+}
+
+// defaults returns the response defaults of the server, nil if not set.
+func (srv *server) defaults() *responseDefaults {
+	if srv.opts == nil {
+		return nil
+	}
+
+	return srv.opts.defaults
+}
+
+// setMissing sets the headers not set yet.
+func setMissing(header, defaults http.Header) {
+	for key, values := range defaults {
+		if len(header.Values(key)) == 0 {
+			header[key] = values
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMissing(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{"Server": {"handler"}}
+
+	setMissing(header, http.Header{"Server": {"mock"}, "X-Env": {"test"}})
+
+	assert.Equal(t, http.Header{"Server": {"handler"}, "X-Env": {"test"}}, header)
+}
+
+func TestResponseDefaults(t *testing.T) {
+	t.Parallel()
+
+	srv := &server{router: newRouter(), opts: &options{defaults: &responseDefaults{
+		headers: http.Header{"Server": {"mock"}, "X-Content-Type-Options": {"nosniff"}},
+		delay:   getDelay("100ms"),
+	}}}
+
+	fast := srv.router.add(http.MethodGet, "/fast", nil)
+	fast.delay = new(delay)
+	fast.decorators = append(fast.decorators, func(h http.Header) { h.Set("Server", "route") })
+
+	plain := srv.router.add(http.MethodGet, "/plain", nil)
+
+	header := http.Header{}
+	srv.decorate(http.Header{headerRoute: {strconv.Itoa(fast.id)}}, header)
+
+	assert.Equal(t, "route", header.Get("Server"))
+	assert.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+
+	header = http.Header{}
+	srv.decorate(http.Header{headerRoute: {strconv.Itoa(plain.id)}}, header)
+
+	assert.Equal(t, "mock", header.Get("Server"))
+
+	assert.Zero(t, srv.delayOf(http.Header{headerRoute: {strconv.Itoa(fast.id)}}))
+	assert.Equal(t, 100*time.Millisecond, srv.delayOf(http.Header{headerRoute: {strconv.Itoa(plain.id)}}))
+	assert.Equal(t, 100*time.Millisecond, srv.delayOf(http.Header{}))
+	assert.Equal(t, 20*time.Millisecond, srv.delayOf(http.Header{headerDelay: {"20"}}))
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import "github.com/grafana/sobek"
+
+// isDeferred reports whether the mock definition arguments contain the deferred option.
+// Deferred definitions are registered even in the init context of the setup VU.
+func isDeferred(args []sobek.Value) bool {
+	for _, arg := range args {
+		obj, isObj := arg.(*sobek.Object)
+		if !isObj {
+			continue
+		}
+
+		if _, isFunc := sobek.AssertFunction(arg); isFunc {
+			continue
+		}
+
+		if v := obj.Get("deferred"); v != nil && v.ToBoolean() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// define starts the mock server, or registers the definition if the server start is deferred.
+// Deferred servers are started by the first request to the mock target or explicitly by mock.start.
+func (mod *Module) define(args *mockArgs) sobek.Value {
+	if args.options.shared {
+		mod.throwf("%s: %s", errInvalidArg, args.target, errSharedServer)
+	}
+
+	mod.register(args)
+	mod.defineProfiles(args)
+
+	if !args.options.deferred {
+		return mod.start(args)
+	}
+
+	if args.options.skip {
+		return sobek.Undefined()
+	}
+
+	mod.deferred[args.target] = args
+
+	// the server is started by the first request to the target
+	mod.exportEnv(args, args.target)
+
+	return sobek.Undefined()
+}
+
+// startDeferred starts the deferred mock servers whose target is selected by the given function.
+func (mod *Module) startDeferred(selected func(target string) bool) {
+	for target, args := range mod.deferred {
+		if !selected(target) {
+			continue
+		}
+
+		delete(mod.deferred, target)
+
+		mod.start(args)
+	}
+}
+
+// startFor starts the deferred mock server of the given location.
+func (mod *Module) startFor(loc string) {
+	if len(mod.deferred) == 0 {
+		return
+	}
+
+	mod.startDeferred(func(target string) bool {
+		_, ok := matchTarget(target, loc)
+
+		return ok
+	})
+}
+
+// startMock starts the deferred mock server of the given target (all of them if target is missing)
+// and returns the application object of the mock server.
+func (mod *Module) startMock(target sobek.Value) sobek.Value {
+	if target == nil || sobek.IsUndefined(target) || sobek.IsNull(target) {
+		mod.startDeferred(func(string) bool { return true })
+
+		return sobek.Undefined()
+	}
+
+	key := target.String()
+
+	mod.startDeferred(func(target string) bool { return target == key })
+
+	if srv, ok := mod.servers[key]; ok {
+		return srv.app
+	}
+
+	return sobek.Undefined()
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeferredMock(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	require.NoError(t, helper.vu.Runtime().Set("__VU", 0))
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => { app.get("/", (req, res) => res.text("hello")) }, { sync: true, deferred: true })
+mock("https://example.net", app => {}, { sync: true, deferred: true })
+mock("https://example.org", app => {}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	assert.Len(t, helper.module.deferred, 2)
+	assert.Empty(t, helper.module.servers)
+	assert.Empty(t, helper.module.lookup)
+
+	loc, ok := helper.module.rewriteURL("https://example.com/")
+
+	assert.True(t, ok)
+	assert.Equal(t, helper.module.lookup["https://example.com"]+"/", loc)
+	assert.Len(t, helper.module.deferred, 1)
+	assert.Len(t, helper.module.servers, 1)
+
+	helper.module.unmock(helper.vu.Runtime().ToValue("https://example.net"))
+
+	assert.Empty(t, helper.module.deferred)
+}
+
+func TestSharedRejected(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`mock("https://example.com", app => {}, { shared: true })`)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errSharedServer.Error())
+	assert.Empty(t, helper.module.servers)
+}
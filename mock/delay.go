@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const headerDelay = "X-Mock-Delay"
+
+// delay is the simulated latency of a route: fixed, uniformly distributed in [min, max] or normally
+// distributed with mean and standard deviation (negative samples are cut to zero).
+type delay struct {
+	min, max     time.Duration
+	mean, stddev time.Duration
+	normal       bool
+}
+
+// duration parses a duration given in milliseconds or as Go duration string (e.g. 200ms, 1.5s).
+func duration(value interface{}) time.Duration {
+	if str, isStr := value.(string); isStr {
+		if d, err := time.ParseDuration(str); err == nil {
+			return d
+		}
+	}
+
+	return millis(value)
+}
+
+// getDelay parses the delay specification: a duration, a [min, max] range, an object with min and max
+// properties (uniform distribution) or an object with mean and stddev properties (normal distribution).
+func getDelay(value interface{}) *delay {
+	var spec *delay
+
+	switch val := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		if len(val) != 2 {
+			return nil
+		}
+
+		spec = &delay{min: duration(val[0]), max: duration(val[1])}
+	case map[string]interface{}:
+		if mean, found := val["mean"]; found {
+			spec = &delay{mean: duration(mean), stddev: duration(val["stddev"]), normal: true}
+		} else {
+			spec = &delay{min: duration(val["min"]), max: duration(val["max"])}
+		}
+	default:
+		spec = &delay{min: duration(val), max: duration(val)}
+	}
+
+	if spec.max < spec.min {
+		spec.max = spec.min
+	}
+
+	if spec.max <= 0 && spec.mean <= 0 {
+		return nil
+	}
+
+	return spec
+}
+
+// sample returns a random latency of the distribution.
+func (d *delay) sample() time.Duration {
+	if d.normal {
+		sampled := d.mean + time.Duration(rand.NormFloat64()*float64(d.stddev)) // nolint:gosec
+		if sampled < 0 {
+			return 0
+		}
+
+		return sampled
+	}
+
+	if d.max > d.min {
+		return d.min + time.Duration(rand.Int63n(int64(d.max-d.min))) // nolint:gosec
+	}
+
+	return d.min
+}
+
+// delayOf returns the latency of the response: the X-Mock-Delay control header (set by res.delay), the
+// delay option of the route selected by the dispatcher or the default delay of the server.
+func (srv *server) delayOf(control http.Header) time.Duration {
+	if value := control.Get(headerDelay); len(value) != 0 {
+		var spec interface{}
+
+		if err := json.Unmarshal([]byte(value), &spec); err != nil {
+			spec = value
+		}
+
+		if d := getDelay(spec); d != nil {
+			return d.sample()
+		}
+	}
+
+	if id, err := strconv.Atoi(control.Get(headerRoute)); err == nil {
+		if r := srv.router.get(id); r != nil && r.delay != nil {
+			return r.delay.sample()
+		}
+	}
+
+	if defaults := srv.defaults(); defaults != nil && defaults.delay != nil {
+		return defaults.delay.sample()
+	}
+
+	return 0
+}
+
+// delayMethod is the delay method of the response objects, it sets the latency of the response.
+func (srv *server) delayMethod(call sobek.FunctionCall) sobek.Value {
+	res := call.This.ToObject(srv.mod.runtime())
+
+	spec, err := json.Marshal(call.Argument(0).Export())
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	srv.set(res, headerDelay, string(spec))
+
+	return res
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDelay(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getDelay(nil))
+	assert.Nil(t, getDelay(int64(0)))
+	assert.Equal(t, &delay{min: 200 * time.Millisecond, max: 200 * time.Millisecond}, getDelay("200ms"))
+	assert.Equal(t, &delay{min: 150 * time.Millisecond, max: 150 * time.Millisecond}, getDelay(int64(150)))
+	assert.Equal(t, &delay{min: time.Second, max: 2 * time.Second}, getDelay([]interface{}{"1s", int64(2000)}))
+	assert.Equal(t, &delay{min: 100 * time.Millisecond, max: 300 * time.Millisecond},
+		getDelay(map[string]interface{}{"min": 100.0, "max": "300ms"}))
+	assert.Equal(t, &delay{mean: 100 * time.Millisecond, stddev: 20 * time.Millisecond, normal: true},
+		getDelay(map[string]interface{}{"mean": "100ms", "stddev": 20.0}))
+}
+
+func TestDelaySample(t *testing.T) {
+	t.Parallel()
+
+	uniform := &delay{min: 100 * time.Millisecond, max: 200 * time.Millisecond}
+	normal := &delay{mean: 10 * time.Millisecond, stddev: 50 * time.Millisecond, normal: true}
+
+	for i := 0; i < 100; i++ {
+		sample := uniform.sample()
+
+		assert.GreaterOrEqual(t, sample, uniform.min)
+		assert.Less(t, sample, uniform.max)
+		assert.GreaterOrEqual(t, normal.sample(), time.Duration(0))
+	}
+}
+
+func TestDelayOf(t *testing.T) {
+	t.Parallel()
+
+	srv := &server{router: newRouter()}
+
+	r := srv.router.add(http.MethodGet, "/slow", nil)
+	r.delay = getDelay("250ms")
+
+	assert.Equal(t, 250*time.Millisecond, srv.delayOf(http.Header{headerRoute: {strconv.Itoa(r.id)}}))
+	assert.Equal(t, 50*time.Millisecond, srv.delayOf(http.Header{headerRoute: {strconv.Itoa(r.id)}, headerDelay: {"50"}}))
+	assert.Equal(t, time.Second, srv.delayOf(http.Header{headerDelay: {`"1s"`}}))
+	assert.Zero(t, srv.delayOf(http.Header{}))
+}
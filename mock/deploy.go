@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const headerServedVersion = "X-Served-Version"
+
+// deployment selects the active version(s) of the route set. Traffic is either switched to a single
+// version or split between versions by weight.
+type deployment struct {
+	mu       sync.Mutex
+	versions []string
+	weights  map[string]float64
+	rand     *rand.Rand
+	timers   []*time.Timer
+}
+
+func newDeployment() *deployment {
+	return &deployment{rand: rand.New(rand.NewSource(time.Now().UnixNano()))} // nolint:gosec
+}
+
+// register adds a version, the first registered version is active by default.
+func (d *deployment) register(version string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if contains(d.versions, version) {
+		return
+	}
+
+	d.versions = append(d.versions, version)
+
+	if d.weights == nil {
+		d.weights = map[string]float64{version: 1}
+	}
+}
+
+func (d *deployment) has(version string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return contains(d.versions, version)
+}
+
+// split sets the traffic weights of versions atomically.
+func (d *deployment) split(weights map[string]float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.weights = weights
+}
+
+// pick returns the version serving the next request, empty string if there are no versions.
+func (d *deployment) pick() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var total float64
+
+	for _, version := range d.versions {
+		total += d.weights[version]
+	}
+
+	if total <= 0 {
+		return ""
+	}
+
+	value := d.rand.Float64() * total
+
+	for _, version := range d.versions {
+		if value -= d.weights[version]; value < 0 && d.weights[version] > 0 {
+			return version
+		}
+	}
+
+	return d.versions[len(d.versions)-1]
+}
+
+// schedule applies the weights after the given delay.
+func (d *deployment) schedule(after time.Duration, weights map[string]float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.timers = append(d.timers, time.AfterFunc(after, func() { d.split(weights) }))
+}
+
+func (d *deployment) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, timer := range d.timers {
+		timer.Stop()
+	}
+
+	d.timers = nil
+}
+
+// wrapVersions adds the version, switch and split methods to the application.
+func (srv *server) wrapVersions() {
+	srv.mustSet("version", func(name string, callback sobek.Callable) sobek.Value {
+		srv.deploy.register(name)
+		srv.router.define(name, func() {
+			if _, err := callback(sobek.Undefined(), srv.app); err != nil {
+				srv.mod.throw(err)
+			}
+		})
+
+		return srv.app
+	})
+
+	srv.mustSet("switch", func(name string, opts sobek.Value) sobek.Value {
+		srv.scheduleWeights(map[string]float64{name: 1}, opts)
+
+		return srv.app
+	})
+
+	srv.mustSet("split", func(weights map[string]float64, opts sobek.Value) sobek.Value {
+		srv.scheduleWeights(weights, opts)
+
+		return srv.app
+	})
+}
+
+func (srv *server) scheduleWeights(weights map[string]float64, opts sobek.Value) {
+	for name := range weights {
+		if !srv.deploy.has(name) {
+			srv.mod.throwf("unknown version %s", errInvalidArg, name)
+		}
+	}
+
+	if obj, isObj := opts.(*sobek.Object); isObj {
+		if v := obj.Get("after"); v != nil && !sobek.IsUndefined(v) {
+			srv.deploy.schedule(duration(v.Export()), weights)
+
+			return
+		}
+	}
+
+	srv.deploy.split(weights)
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeployment(t *testing.T) {
+	t.Parallel()
+
+	d := newDeployment()
+
+	assert.Empty(t, d.pick())
+
+	d.register("blue")
+	d.register("green")
+
+	assert.True(t, d.has("green"))
+	assert.False(t, d.has("red"))
+	assert.Equal(t, "blue", d.pick())
+
+	d.split(map[string]float64{"green": 1})
+
+	assert.Equal(t, "green", d.pick())
+
+	d.split(map[string]float64{"blue": 90, "green": 10})
+
+	counts := make(map[string]int)
+
+	for idx := 0; idx < 1000; idx++ {
+		counts[d.pick()]++
+	}
+
+	assert.Greater(t, counts["blue"], counts["green"])
+	assert.Positive(t, counts["green"])
+
+	d.schedule(10*time.Millisecond, map[string]float64{"blue": 1})
+	d.split(map[string]float64{"green": 1})
+
+	assert.Eventually(t, func() bool { return d.pick() == "blue" }, time.Second, 5*time.Millisecond)
+
+	d.stop()
+}
+
+func TestDeploymentAfter(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+function versions(app) {
+	app.version("blue", app => app.get("/", (req, res) => res.text("blue")))
+	app.version("green", app => app.get("/", (req, res) => res.text("green")))
+}
+
+mock("https://example.com", versions, { sync: true }).switch("green", { after: "1h" })
+mock("https://example.net", versions, { sync: true }).switch("green", { after: 10 })
+`)
+
+	require.NoError(t, err)
+
+	later := helper.module.servers["https://example.com"].deploy
+	soon := helper.module.servers["https://example.net"].deploy
+
+	assert.Eventually(t, func() bool { return soon.pick() == "green" }, time.Second, 5*time.Millisecond)
+	assert.Never(t, func() bool { return later.pick() == "green" }, 50*time.Millisecond, 5*time.Millisecond)
+
+	later.stop()
+	soon.stop()
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// deprecation describes the deprecation of a route, see RFC 8594 and RFC 9745.
+type deprecation struct {
+	since      time.Time // zero if the date of deprecation is unknown
+	sunset     time.Time
+	link       string
+	sunsetLink string
+}
+
+// decorator returns the decorator setting the Deprecation, Sunset and Link headers.
+func (dep *deprecation) decorator() func(http.Header) {
+	return func(header http.Header) {
+		if dep.since.IsZero() {
+			header.Set("Deprecation", "true")
+		} else {
+			header.Set("Deprecation", "@"+strconv.FormatInt(dep.since.Unix(), 10))
+		}
+
+		if !dep.sunset.IsZero() {
+			header.Set("Sunset", dep.sunset.UTC().Format(http.TimeFormat))
+		}
+
+		if len(dep.link) != 0 {
+			header.Add("Link", "<"+dep.link+`>; rel="deprecation"; type="text/html"`)
+		}
+
+		if len(dep.sunsetLink) != 0 {
+			header.Add("Link", "<"+dep.sunsetLink+`>; rel="sunset"; type="text/html"`)
+		}
+	}
+}
+
+// getDeprecation parses deprecation options: deprecated (true or date), sunset (date),
+// link and sunsetLink (URLs of the human readable documentation). Nil is returned if the route is not deprecated.
+func (mod *Module) getDeprecation(obj *sobek.Object) *deprecation {
+	isSet := func(v sobek.Value) bool {
+		return v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v)
+	}
+
+	deprecated, sunset := obj.Get("deprecated"), obj.Get("sunset")
+
+	if (!isSet(deprecated) || !deprecated.ToBoolean()) && !isSet(sunset) {
+		return nil
+	}
+
+	dep := new(deprecation)
+
+	if isSet(deprecated) {
+		if _, isBool := deprecated.Export().(bool); !isBool {
+			dep.since = mod.exportTime(deprecated)
+		}
+	}
+
+	if isSet(sunset) {
+		dep.sunset = mod.exportTime(sunset)
+	}
+
+	if v := obj.Get("link"); isSet(v) {
+		dep.link = v.String()
+	}
+
+	if v := obj.Get("sunsetLink"); isSet(v) {
+		dep.sunsetLink = v.String()
+	}
+
+	return dep
+}
+
+// wrapDeprecate adds the deprecate method to the application, it decorates the already
+// defined routes matching the given path pattern.
+func (srv *server) wrapDeprecate() {
+	srv.mustSet("deprecate", func(pattern string, opts *sobek.Object) sobek.Value {
+		if opts == nil {
+			opts = srv.mod.runtime().NewObject()
+		}
+
+		if v := opts.Get("deprecated"); v == nil || sobek.IsUndefined(v) {
+			opts.Set("deprecated", true) // nolint:errcheck
+		}
+
+		dep := srv.mod.getDeprecation(opts)
+		if dep == nil {
+			return srv.app
+		}
+
+		selector := &route{pattern: pattern, prefix: true, segments: compilePath(pattern)}
+
+		srv.router.each(func(r *route) {
+			if _, ok := selector.match(r.pattern); ok && !r.static {
+				r.decorators = append(r.decorators, dep.decorator())
+			}
+		})
+
+		return srv.app
+	})
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecation(t *testing.T) {
+	t.Parallel()
+
+	header := make(http.Header)
+
+	(&deprecation{}).decorator()(header)
+
+	assert.Equal(t, "true", header.Get("Deprecation"))
+	assert.Empty(t, header.Get("Sunset"))
+	assert.Empty(t, header.Get("Link"))
+
+	header = make(http.Header)
+
+	dep := &deprecation{
+		since:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		sunset:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		link:       "https://example.com/deprecation",
+		sunsetLink: "https://example.com/sunset",
+	}
+
+	dep.decorator()(header)
+
+	assert.Equal(t, "@1704067200", header.Get("Deprecation"))
+	assert.Equal(t, "Wed, 01 Jan 2025 00:00:00 GMT", header.Get("Sunset"))
+	assert.Equal(t, []string{
+		`<https://example.com/deprecation>; rel="deprecation"; type="text/html"`,
+		`<https://example.com/sunset>; rel="sunset"; type="text/html"`,
+	}, header.Values("Link"))
+}
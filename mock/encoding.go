@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodeBody returns the request body decoded by its Content-Encoding (gzip, deflate and base64, in
+// reverse order of application) and Content-Transfer-Encoding (base64) headers. Gzip compressed bodies
+// are decoded without header too. The body is returned as is if it can't be decoded.
+func decodeBody(header http.Header, body []byte) []byte {
+	var encodings []string
+
+	for _, value := range header.Values("Content-Encoding") {
+		for _, item := range strings.Split(value, ",") {
+			encodings = append(encodings, strings.ToLower(strings.TrimSpace(item)))
+		}
+	}
+
+	if strings.EqualFold(header.Get("Content-Transfer-Encoding"), "base64") {
+		encodings = append(encodings, "base64")
+	}
+
+	decoded := body
+
+	for idx := len(encodings) - 1; idx >= 0; idx-- {
+		var ok bool
+
+		switch encodings[idx] {
+		case "gzip", "x-gzip":
+			decoded, ok = gunzip(decoded)
+		case "deflate":
+			decoded, ok = inflate(decoded)
+		case "base64":
+			decoded, ok = unbase64(string(decoded))
+		case "identity", "":
+			ok = true
+		}
+
+		if !ok {
+			return body
+		}
+	}
+
+	if unzipped, ok := gunzip(decoded); ok {
+		return unzipped
+	}
+
+	return decoded
+}
+
+// decodeNested returns the JSON document encoded in the string: JSON text, or base64 encoded (and
+// optionally gzip compressed) JSON text. Only objects and arrays are decoded.
+func decodeNested(value string) (interface{}, bool) {
+	if doc, ok := decodeDocument([]byte(value)); ok {
+		return doc, true
+	}
+
+	data, ok := unbase64(value)
+	if !ok {
+		return nil, false
+	}
+
+	if unzipped, ok := gunzip(data); ok {
+		data = unzipped
+	}
+
+	return decodeDocument(data)
+}
+
+func decodeDocument(data []byte) (interface{}, bool) {
+	var doc interface{}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+
+	switch doc.(type) {
+	case map[string]interface{}, []interface{}:
+		return doc, true
+	default:
+		return nil, false
+	}
+}
+
+// encodedSubset is jsonSubset decoding the encoded fields (see decodeNested) where an object or array
+// is expected.
+func encodedSubset(expected, actual interface{}) bool {
+	if str, isStr := actual.(string); isStr {
+		switch expected.(type) {
+		case map[string]interface{}, []interface{}:
+			if doc, ok := decodeNested(str); ok {
+				actual = doc
+			}
+		}
+	}
+
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		obj, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for key, value := range exp {
+			if !encodedSubset(value, obj[key]) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		list, ok := actual.([]interface{})
+		if !ok || len(list) != len(exp) {
+			return false
+		}
+
+		for idx := range exp {
+			if !encodedSubset(exp[idx], list[idx]) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return expected == actual
+	}
+}
+
+func gunzip(data []byte) ([]byte, bool) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return nil, false
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	return readAll(reader)
+}
+
+func inflate(data []byte) ([]byte, bool) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	return readAll(reader)
+}
+
+func readAll(reader io.ReadCloser) ([]byte, bool) {
+	defer reader.Close() // nolint:errcheck
+
+	data, err := io.ReadAll(reader)
+
+	return data, err == nil
+}
+
+// unbase64 decodes standard or URL safe base64, with or without padding.
+func unbase64(value string) ([]byte, bool) {
+	value = strings.TrimSpace(value)
+
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding,
+	} {
+		if data, err := enc.DecodeString(value); err == nil {
+			return data, true
+		}
+	}
+
+	return nil, false
+}
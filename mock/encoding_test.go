@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buff bytes.Buffer
+
+	writer := gzip.NewWriter(&buff)
+
+	_, err := writer.Write([]byte(data))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	return buff.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	t.Parallel()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"type":"gold"}`))
+
+	assert.Equal(t, `{"type":"gold"}`, string(decodeBody(http.Header{}, gzipped(t, `{"type":"gold"}`))))
+	assert.Equal(t, `{"type":"gold"}`, string(decodeBody(http.Header{"Content-Encoding": {"gzip"}}, gzipped(t, `{"type":"gold"}`))))
+	assert.Equal(t, `{"type":"gold"}`, string(decodeBody(http.Header{"Content-Transfer-Encoding": {"base64"}}, []byte(encoded))))
+	assert.Equal(t, `{"type":"gold"}`, string(decodeBody(http.Header{"Content-Encoding": {"gzip, base64"}},
+		[]byte(base64.StdEncoding.EncodeToString(gzipped(t, `{"type":"gold"}`))))))
+	assert.Equal(t, encoded, string(decodeBody(http.Header{}, []byte(encoded))))
+	assert.Equal(t, "plain", string(decodeBody(http.Header{"Content-Encoding": {"gzip"}}, []byte("plain"))))
+}
+
+func TestEncodedSubset(t *testing.T) {
+	t.Parallel()
+
+	inner := base64.StdEncoding.EncodeToString([]byte(`{"user":{"id":42}}`))
+	zipped := base64.URLEncoding.EncodeToString(gzipped(t, `[1,2]`))
+
+	actual := map[string]interface{}{"payload": inner, "json": `{"a":"b"}`, "list": zipped, "name": inner}
+
+	assert.True(t, encodedSubset(map[string]interface{}{"payload": map[string]interface{}{"user": map[string]interface{}{"id": 42.0}}}, actual))
+	assert.True(t, encodedSubset(map[string]interface{}{"json": map[string]interface{}{"a": "b"}}, actual))
+	assert.True(t, encodedSubset(map[string]interface{}{"list": []interface{}{1.0, 2.0}}, actual))
+	assert.True(t, encodedSubset(map[string]interface{}{"name": inner}, actual))
+	assert.False(t, encodedSubset(map[string]interface{}{"payload": map[string]interface{}{"user": 1.0}}, actual))
+	assert.False(t, jsonSubset(map[string]interface{}{"json": map[string]interface{}{"a": "b"}}, actual))
+}
+
+func TestEncodedBodyConditions(t *testing.T) {
+	t.Parallel()
+
+	srv := &server{router: newRouter()}
+
+	gold := srv.router.add(http.MethodPost, "/items", nil)
+
+	srv.router.reorder(gold, func(r *route) {
+		r.conditions = append(r.conditions, jsonCondition(map[string]interface{}{"item": map[string]interface{}{"type": "gold"}}))
+	})
+
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"type":"gold"}`))
+
+	req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(gzipped(t, `{"item":"`+payload+`"}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	candidates := srv.router.candidates(http.MethodPost, "/items", selector{})
+
+	assert.Equal(t, []*route{gold}, srv.conditional(candidates, req))
+}
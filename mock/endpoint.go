@@ -0,0 +1,257 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/modules"
+)
+
+// endpoint is the listener of a non-HTTP mock (e.g. a message broker). The protocol implementation
+// serves the accepted connections (or the datagrams of packet endpoints), scripted handlers are
+// called on the VU's event loop.
+type endpoint struct {
+	mod      *Module
+	target   string
+	scheme   string
+	opts     *options
+	obj      *sobek.Object // JavaScript interface of the endpoint
+	listener net.Listener
+	packet   net.PacketConn
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+func (mod *Module) newEndpoint(args *mockArgs, scheme string) *endpoint {
+	ep := &endpoint{
+		mod:    mod,
+		target: args.target,
+		scheme: scheme,
+		opts:   args.options,
+		obj:    mod.runtime().NewObject(),
+		conns:  make(map[net.Conn]struct{}),
+	}
+
+	ep.mustSet("close", func() {
+		if err := ep.close(); err != nil {
+			mod.throw(err)
+		}
+	})
+
+	return ep
+}
+
+func (ep *endpoint) mustSet(name string, value interface{}) {
+	if err := ep.obj.Set(name, value); err != nil {
+		ep.mod.throw(err)
+	}
+}
+
+// addr returns the address of the endpoint's listener.
+func (ep *endpoint) addr() net.Addr {
+	if ep.packet != nil {
+		return ep.packet.LocalAddr()
+	}
+
+	return ep.listener.Addr()
+}
+
+// url returns the URL of the endpoint's listener.
+func (ep *endpoint) url() string {
+	return ep.scheme + "://" + ep.addr().String()
+}
+
+// serve starts listening on a private address, each accepted connection is served by handler on its own goroutine.
+func (ep *endpoint) serve(handler func(net.Conn)) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	ep.listener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					ep.mod.logger.WithError(err).WithField("target", ep.target).Error("mock endpoint stopped")
+				}
+
+				return
+			}
+
+			ep.mu.Lock()
+			ep.conns[conn] = struct{}{}
+			ep.mu.Unlock()
+
+			go func() {
+				defer ep.drop(conn)
+
+				handler(conn)
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// servePacket starts listening for datagrams on a private address, they are served by handler on its own goroutine.
+func (ep *endpoint) servePacket(handler func(net.PacketConn)) error {
+	packet, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	ep.packet = packet
+
+	go handler(packet)
+
+	return nil
+}
+
+// drop closes the connection and forgets it.
+func (ep *endpoint) drop(conn net.Conn) {
+	ep.mu.Lock()
+	delete(ep.conns, conn)
+	ep.mu.Unlock()
+
+	conn.Close() // nolint:errcheck,gosec
+}
+
+// close stops listening and closes the open connections.
+func (ep *endpoint) close() error {
+	if ep.packet != nil {
+		if err := ep.packet.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+
+		return nil
+	}
+
+	if ep.listener == nil {
+		return nil
+	}
+
+	err := ep.listener.Close()
+
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	for conn := range ep.conns {
+		conn.Close() // nolint:errcheck,gosec
+	}
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// call runs fn on the VU's event loop and waits for its completion.
+func (ep *endpoint) call(fn func() error) error {
+	return onEventLoop(ep.mod.vu, ep.opts.sync, fn)
+}
+
+// invoke calls the scripted handler with the given arguments on the event loop. Handler errors are logged.
+func (ep *endpoint) invoke(fn sobek.Callable, args ...interface{}) sobek.Value {
+	var result sobek.Value
+
+	err := ep.call(func() error {
+		runtime := ep.mod.runtime()
+		values := make([]sobek.Value, 0, len(args))
+
+		for _, arg := range args {
+			values = append(values, runtime.ToValue(arg))
+		}
+
+		var err error
+
+		result, err = fn(sobek.Undefined(), values...)
+
+		return err
+	})
+	if err != nil {
+		ep.mod.logger.WithError(err).WithField("target", ep.target).Warn("mock handler failed")
+
+		return nil
+	}
+
+	return result
+}
+
+// startEndpoint runs the definition callback with the endpoint's JavaScript interface and starts serving connections.
+func (mod *Module) startEndpoint(args *mockArgs, ep *endpoint, handler func(net.Conn)) sobek.Value {
+	return mod.runEndpoint(args, ep, func() error { return ep.serve(handler) })
+}
+
+// startPacketEndpoint runs the definition callback with the endpoint's JavaScript interface and starts serving datagrams.
+func (mod *Module) startPacketEndpoint(args *mockArgs, ep *endpoint, handler func(net.PacketConn)) sobek.Value {
+	return mod.runEndpoint(args, ep, func() error { return ep.servePacket(handler) })
+}
+
+func (mod *Module) runEndpoint(args *mockArgs, ep *endpoint, serve func() error) sobek.Value {
+	if args.options.skip {
+		return sobek.Undefined()
+	}
+
+	if _, err := args.callback(sobek.Undefined(), ep.obj); err != nil {
+		mod.throw(err)
+	}
+
+	if err := serve(); err != nil {
+		mod.throw(err)
+	}
+
+	ep.mustSet("url", ep.url())
+	ep.mustSet("address", ep.addr().String())
+
+	mod.endpoints[args.target] = ep
+	mod.lookup[args.target] = ep.url()
+
+	mod.exportEnv(args, ep.url())
+
+	return ep.obj
+}
+
+// unmockEndpoint closes the endpoint of the target, it reports whether the target was an endpoint.
+func (mod *Module) unmockEndpoint(target string) bool {
+	ep, found := mod.endpoints[target]
+	if !found {
+		return false
+	}
+
+	delete(mod.endpoints, target)
+	delete(mod.lookup, target)
+
+	if err := ep.close(); err != nil {
+		mod.throw(err)
+	}
+
+	return true
+}
+
+// onEventLoop runs fn on the VU's event loop and waits for its completion.
+// In synchronous mode the application is not bound to the event loop, fn is called directly.
+func onEventLoop(vu modules.VU, sync bool, fn func() error) error { // nolint:varnamelen
+	if sync {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+
+	newRunner(vu)(func() error {
+		done <- fn()
+
+		return nil
+	})
+
+	return <-done
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+var envInvalidChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// envOption contains the environment variable names the mock server's URL is exported to.
+type envOption struct {
+	names []string // default name is used if empty
+}
+
+// getEnv parses the env option. It is either a boolean, a variable name or an array of variable names.
+func getEnv(value sobek.Value) *envOption {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	switch exported := value.Export().(type) {
+	case bool:
+		if !exported {
+			return nil
+		}
+
+		return new(envOption)
+	case string:
+		return &envOption{names: []string{exported}}
+	case []interface{}:
+		opt := new(envOption)
+
+		for _, item := range exported {
+			if name, ok := item.(string); ok {
+				opt.names = append(opt.names, name)
+			}
+		}
+
+		return opt
+	}
+
+	return nil
+}
+
+// envName returns the default environment variable name of the mock server: MOCK_<NAME>_URL,
+// where NAME is the name of the mock server or the host name of the target.
+func envName(name, target string) string {
+	if len(name) == 0 {
+		if loc, err := url.Parse(target); err == nil && len(loc.Hostname()) != 0 {
+			name = loc.Hostname()
+		} else {
+			name = target
+		}
+	}
+
+	name = strings.Trim(envInvalidChars.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+
+	return "MOCK_" + name + "_URL"
+}
+
+// exportEnv sets the environment variables of the mock definition to the given URL in __ENV.
+func (mod *Module) exportEnv(args *mockArgs, loc string) {
+	opt := args.options.env
+	if opt == nil {
+		return
+	}
+
+	env, isObj := mod.runtime().Get("__ENV").(*sobek.Object)
+	if !isObj {
+		return
+	}
+
+	names := opt.names
+	if len(names) == 0 {
+		names = []string{envName(args.options.name, args.target)}
+	}
+
+	for _, name := range names {
+		if err := env.Set(name, loc); err != nil {
+			mod.throw(err)
+		}
+	}
+}
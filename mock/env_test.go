@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "MOCK_PAYMENTS_URL", envName("payments", "https://pay.example.com"))
+	assert.Equal(t, "MOCK_ORDER_SERVICE_URL", envName("order-service", "https://orders.example.com"))
+	assert.Equal(t, "MOCK_ORDERS_EXAMPLE_COM_URL", envName("", "https://orders.example.com:8443/api"))
+}
+
+func TestExportEnv(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+var __ENV = {}
+
+mock("https://payments.example.com", app => {}, { sync: true, name: "payments", env: true })
+mock("https://orders.example.com", app => {}, { sync: true, env: ["ORDERS_URL", "ORDERS_BASE_URL"] })
+mock("https://inventory.example.com", app => {}, { sync: true, env: "INVENTORY_URL", deferred: true })
+`)
+
+	require.NoError(t, err)
+
+	env := helper.vu.Runtime().Get("__ENV").ToObject(helper.vu.Runtime())
+
+	assert.Equal(t, helper.module.lookup["https://payments.example.com"], env.Get("MOCK_PAYMENTS_URL").String())
+	assert.Equal(t, helper.module.lookup["https://orders.example.com"], env.Get("ORDERS_URL").String())
+	assert.Equal(t, helper.module.lookup["https://orders.example.com"], env.Get("ORDERS_BASE_URL").String())
+	assert.Equal(t, "https://inventory.example.com", env.Get("INVENTORY_URL").String())
+}
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	eventsLimit       = 1000
+	subscriberBacklog = 256
+
+	redactedValue = "[REDACTED]"
+)
+
+// sensitiveHeaders are the request headers redacted in the published events by default.
+var sensitiveHeaders = []string{ // nolint:gochecknoglobals
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Csrf-Token",
+}
+
+// event is the public, JSON serializable view of a recorded exchange.
+type event struct {
+	ID        uint64            `json:"id"`
+	Time      time.Time         `json:"time"`
+	Target    string            `json:"target"`
+	VU        uint64            `json:"vu"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Query     string            `json:"query,omitempty"`
+	Header    map[string]string `json:"header,omitempty"`
+	Status    int               `json:"status"`
+	Route     string            `json:"route,omitempty"`
+	Duration  float64           `json:"duration"`
+	RequestID string            `json:"requestId,omitempty"`
+	Stub      string            `json:"stub,omitempty"`
+	Fault     string            `json:"fault,omitempty"`
+}
+
+// hub keeps the latest events of all mock servers of the process.
+type hub struct {
+	mu          sync.RWMutex
+	seq         uint64
+	events      []*event
+	subscribers map[chan *event]struct{}
+	redacted    map[string]bool // canonical names of the redacted headers
+}
+
+func newHub() *hub {
+	h := &hub{subscribers: make(map[chan *event]struct{}), redacted: make(map[string]bool)}
+
+	h.redact(sensitiveHeaders...)
+
+	return h
+}
+
+// redact adds the headers to the ones whose values are replaced in the published events.
+func (h *hub) redact(names ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, name := range names {
+		h.redacted[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// publish stores the event and delivers it to the subscribers, values of the sensitive headers
+// are redacted.
+func (h *hub) publish(ev *event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	ev.ID = h.seq
+
+	for name := range ev.Header {
+		if h.redacted[http.CanonicalHeaderKey(name)] {
+			ev.Header[name] = redactedValue
+		}
+	}
+
+	if len(h.events) >= eventsLimit {
+		h.events = h.events[1:]
+	}
+
+	h.events = append(h.events, ev)
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default: // slow subscriber, event dropped
+		}
+	}
+}
+
+// subscribe returns a channel receiving the events published after the given event ID.
+// Already stored events are delivered first. The returned function cancels the subscription.
+func (h *hub) subscribe(id uint64) (<-chan *event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	backlog := 0
+
+	for _, ev := range h.events {
+		if ev.ID > id {
+			backlog++
+		}
+	}
+
+	ch := make(chan *event, subscriberBacklog+backlog)
+
+	for _, ev := range h.events {
+		if ev.ID > id {
+			ch <- ev
+		}
+	}
+
+	h.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subscribers, ch)
+	}
+}
+
+// since returns events published after the event with the given ID.
+func (h *hub) since(id uint64) []*event {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	found := make([]*event, 0)
+
+	for _, ev := range h.events {
+		if ev.ID > id {
+			found = append(found, ev)
+		}
+	}
+
+	return found
+}
+
+func (srv *server) publish(record *entry) {
+	ev := &event{
+		Time:      record.time,
+		Target:    srv.target,
+		Method:    record.method,
+		Path:      record.path,
+		Query:     record.query.Encode(),
+		Header:    make(map[string]string, len(record.header)),
+		Status:    record.status,
+		Route:     record.route,
+		Duration:  float64(record.duration) / float64(time.Millisecond),
+		RequestID: record.correlation,
+		Stub:      record.stub,
+		Fault:     record.fault,
+	}
+
+	for name := range record.header {
+		ev.Header[name] = record.header.Get(name)
+	}
+
+	if state := srv.mod.vu.State(); state != nil {
+		ev.VU = state.VUID
+	}
+
+	srv.mod.hub.publish(ev)
+}
@@ -0,0 +1,229 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// expectation is a request verification built by the fluent expect API.
+type expectation struct {
+	journal *journal
+	method  string
+	path    *route
+	filters []func(*entry) bool
+	name    []string
+	target  string
+	tenancy string // header identifying the tenant, see tenancy option
+	stats   *statistics
+}
+
+// verify evaluates the expectation and records the result for the end-of-test summary.
+func (exp *expectation) verify(check string, pass func(count int) bool) bool {
+	count := exp.count()
+	passed := pass(count)
+
+	if exp.stats != nil {
+		exp.stats.verify(exp.target, &verification{
+			name:   strings.Join(append(exp.name, check), " "),
+			count:  count,
+			passed: passed,
+		})
+	}
+
+	return passed
+}
+
+func (exp *expectation) count() int {
+	count := 0
+
+	for _, record := range exp.journal.snapshot() {
+		if exp.matches(record) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (exp *expectation) matches(record *entry) bool {
+	if len(exp.method) != 0 && record.method != exp.method {
+		return false
+	}
+
+	if _, ok := exp.path.match(record.path); !ok {
+		return false
+	}
+
+	for _, filter := range exp.filters {
+		if !filter(record) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (exp *expectation) withHeader(name string, value ...string) {
+	exp.name = append(exp.name, "withHeader("+strings.Join(append([]string{name}, value...), ", ")+")")
+	exp.filters = append(exp.filters, func(record *entry) bool {
+		values, ok := record.header[http.CanonicalHeaderKey(name)]
+
+		return ok && (len(value) == 0 || contains(values, value[0]))
+	})
+}
+
+func (exp *expectation) withQuery(name string, value ...string) {
+	exp.name = append(exp.name, "withQuery("+strings.Join(append([]string{name}, value...), ", ")+")")
+	exp.filters = append(exp.filters, func(record *entry) bool {
+		values, ok := record.query[name]
+
+		return ok && (len(value) == 0 || contains(values, value[0]))
+	})
+}
+
+func (exp *expectation) withBody(part string) {
+	exp.name = append(exp.name, "withBody("+part+")")
+	exp.filters = append(exp.filters, func(record *entry) bool {
+		return strings.Contains(string(record.requestBody()), part)
+	})
+}
+
+// withTenant restricts the expectation to the requests of the tenant, so jobs sharing the mock
+// verify only their own requests.
+func (exp *expectation) withTenant(name string) error {
+	if len(exp.tenancy) == 0 {
+		return fmt.Errorf("%w: tenancy is not enabled", errInvalidArg)
+	}
+
+	exp.name = append(exp.name, "withTenant("+name+")")
+	exp.filters = append(exp.filters, func(record *entry) bool {
+		return record.header.Get(exp.tenancy) == name
+	})
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// server returns the server of the mock definition identified by its target URL or its application object.
+func (mod *Module) server(value sobek.Value) *server {
+	if obj, isObj := value.(*sobek.Object); isObj {
+		for _, srv := range mod.servers {
+			if srv.app.SameAs(obj) {
+				return srv
+			}
+		}
+	}
+
+	if srv, found := mod.servers[value.String()]; found {
+		return srv
+	}
+
+	mod.throwf("unknown mock target: %s", errInvalidArg, value.String())
+
+	return nil
+}
+
+func (mod *Module) expect(target sobek.Value) *sobek.Object {
+	srv := mod.server(target)
+	obj := mod.runtime().NewObject()
+
+	set := func(name string, method string) {
+		err := obj.Set(name, func(path string) *sobek.Object {
+			return mod.expectation(&expectation{
+				journal: srv.journal,
+				method:  method,
+				path:    newPathMatcher(path),
+				name:    []string{strings.TrimSpace(method + " " + path)},
+				target:  srv.target,
+				tenancy: srv.opts.tenancy,
+				stats:   mod.stats,
+			})
+		})
+		if err != nil {
+			mod.throw(err)
+		}
+	}
+
+	for name, method := range routeMethods {
+		set(name, method)
+	}
+
+	set("any", "")
+
+	return obj
+}
+
+func newPathMatcher(path string) *route {
+	return &route{pattern: path, segments: compilePath(path)}
+}
+
+func (mod *Module) expectation(exp *expectation) *sobek.Object {
+	obj := mod.runtime().NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	mustSet("withHeader", func(name string, value ...string) *sobek.Object {
+		exp.withHeader(name, value...)
+
+		return obj
+	})
+
+	mustSet("withQuery", func(name string, value ...string) *sobek.Object {
+		exp.withQuery(name, value...)
+
+		return obj
+	})
+
+	mustSet("withBody", func(part string) *sobek.Object {
+		exp.withBody(part)
+
+		return obj
+	})
+
+	mustSet("withTenant", func(name string) *sobek.Object {
+		if err := exp.withTenant(name); err != nil {
+			mod.throw(err)
+		}
+
+		return obj
+	})
+
+	mustSet("count", exp.count)
+	mustSet("atLeast", func(n int) bool {
+		return exp.verify(fmt.Sprintf("atLeast(%d)", n), func(count int) bool { return count >= n })
+	})
+	mustSet("atMost", func(n int) bool {
+		return exp.verify(fmt.Sprintf("atMost(%d)", n), func(count int) bool { return count <= n })
+	})
+	mustSet("times", func(n int) bool {
+		return exp.verify(fmt.Sprintf("times(%d)", n), func(count int) bool { return count == n })
+	})
+	mustSet("once", func() bool {
+		return exp.verify("once()", func(count int) bool { return count == 1 })
+	})
+	mustSet("never", func() bool {
+		return exp.verify("never()", func(count int) bool { return count == 0 })
+	})
+
+	return obj
+}
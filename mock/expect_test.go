@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectationCount(t *testing.T) {
+	t.Parallel()
+
+	jrnl := newJournal(journalLimit)
+
+	jrnl.add(&entry{
+		method: http.MethodPost,
+		path:   "/orders",
+		header: http.Header{"Idempotency-Key": {"abc"}},
+		query:  url.Values{"dry": {"true"}},
+		body:   []byte(`{"item":"book"}`),
+	})
+	jrnl.add(&entry{method: http.MethodPost, path: "/orders", header: http.Header{}, query: url.Values{}})
+	jrnl.add(&entry{method: http.MethodGet, path: "/orders/42", header: http.Header{}, query: url.Values{}})
+
+	newExp := func(method, path string) *expectation {
+		return &expectation{journal: jrnl, method: method, path: newPathMatcher(path)}
+	}
+
+	assert.Equal(t, 2, newExp(http.MethodPost, "/orders").count())
+	assert.Equal(t, 1, newExp(http.MethodGet, "/orders/:id").count())
+	assert.Equal(t, 3, newExp("", "/orders/*").count())
+
+	exp := newExp(http.MethodPost, "/orders")
+	exp.withHeader("idempotency-key")
+	assert.Equal(t, 1, exp.count())
+
+	exp = newExp(http.MethodPost, "/orders")
+	exp.withHeader("Idempotency-Key", "other")
+	assert.Equal(t, 0, exp.count())
+
+	exp = newExp(http.MethodPost, "/orders")
+	exp.withQuery("dry", "true")
+	exp.withBody(`"book"`)
+	assert.Equal(t, 1, exp.count())
+}
+
+func TestExpectationTenant(t *testing.T) {
+	t.Parallel()
+
+	jrnl := newJournal(journalLimit)
+
+	jrnl.add(&entry{method: http.MethodGet, path: "/items", header: http.Header{"X-Tenant": {"blue"}}})
+	jrnl.add(&entry{method: http.MethodGet, path: "/items", header: http.Header{"X-Tenant": {"green"}}})
+	jrnl.add(&entry{method: http.MethodGet, path: "/items", header: http.Header{"X-Tenant": {"green"}}})
+
+	exp := &expectation{journal: jrnl, method: http.MethodGet, path: newPathMatcher("/items"), tenancy: "X-Tenant"}
+
+	require.NoError(t, exp.withTenant("green"))
+	assert.Equal(t, 2, exp.count())
+
+	exp = &expectation{journal: jrnl, method: http.MethodGet, path: newPathMatcher("/items")}
+
+	assert.ErrorIs(t, exp.withTenant("green"), errInvalidArg)
+}
+
+func TestJournalLimit(t *testing.T) {
+	t.Parallel()
+
+	jrnl := newJournal(2)
+
+	jrnl.add(&entry{path: "/1"})
+	jrnl.add(&entry{path: "/2"})
+	jrnl.add(&entry{path: "/3"})
+
+	entries := jrnl.snapshot()
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "/2", entries[0].path)
+	assert.Equal(t, "/3", entries[1].path)
+}
@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// Matcher selects the requests served by a custom responder.
+type Matcher interface {
+	Match(r *http.Request) bool
+}
+
+// MatcherFunc is an adapter to use ordinary functions as matchers.
+type MatcherFunc func(r *http.Request) bool
+
+// Match calls fn(r).
+func (fn MatcherFunc) Match(r *http.Request) bool {
+	return fn(r)
+}
+
+// Responder serves the requests selected by a matcher.
+type Responder interface {
+	Respond(w http.ResponseWriter, r *http.Request)
+}
+
+// ResponderFunc is an adapter to use ordinary functions as responders.
+type ResponderFunc func(w http.ResponseWriter, r *http.Request)
+
+// Respond calls fn(w, r).
+func (fn ResponderFunc) Respond(w http.ResponseWriter, r *http.Request) {
+	fn(w, r)
+}
+
+// Codec converts message bodies of a media type to and from JavaScript compatible values
+// (maps, slices, strings, numbers and booleans).
+type Codec interface {
+	Decode(data []byte) (interface{}, error)
+	Encode(value interface{}) ([]byte, error)
+}
+
+// MatcherFactory creates a matcher from the configuration given in the test script.
+type MatcherFactory func(config map[string]interface{}) (Matcher, error)
+
+// ResponderFactory creates a responder from the configuration given in the test script.
+type ResponderFactory func(config map[string]interface{}) (Responder, error)
+
+var (
+	errUnknownExtension = errors.New("unknown extension")
+	errUnknownCodec     = errors.New("no codec for media type")
+)
+
+// extensions contains the registered Go-level extensions of the process.
+var extensions = struct { // nolint:gochecknoglobals
+	mu         sync.RWMutex
+	matchers   map[string]MatcherFactory
+	responders map[string]ResponderFactory
+	codecs     map[string]Codec
+}{
+	matchers:   make(map[string]MatcherFactory),
+	responders: make(map[string]ResponderFactory),
+	codecs:     map[string]Codec{"application/json": jsonCodec{}},
+}
+
+// RegisterMatcher registers a custom matcher type. It is intended to be called from the init function
+// of Go packages built into k6 along with this extension.
+func RegisterMatcher(name string, factory MatcherFactory) {
+	extensions.mu.Lock()
+	defer extensions.mu.Unlock()
+
+	extensions.matchers[name] = factory
+}
+
+// RegisterResponder registers a custom responder type.
+func RegisterResponder(name string, factory ResponderFactory) {
+	extensions.mu.Lock()
+	defer extensions.mu.Unlock()
+
+	extensions.responders[name] = factory
+}
+
+// RegisterCodec registers the body codec of the given media type (e.g. "application/x-protobuf").
+func RegisterCodec(mediaType string, codec Codec) {
+	extensions.mu.Lock()
+	defer extensions.mu.Unlock()
+
+	extensions.codecs[mediaType] = codec
+}
+
+func newMatcher(name string, config map[string]interface{}) (Matcher, error) {
+	extensions.mu.RLock()
+	factory, found := extensions.matchers[name]
+	extensions.mu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: matcher %s", errUnknownExtension, name)
+	}
+
+	return factory(config)
+}
+
+func newResponder(name string, config map[string]interface{}) (Responder, error) {
+	extensions.mu.RLock()
+	factory, found := extensions.responders[name]
+	extensions.mu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: responder %s", errUnknownExtension, name)
+	}
+
+	return factory(config)
+}
+
+// codecFor returns the codec of the content type's media type.
+func codecFor(contentType string) (Codec, error) {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		media = contentType
+	}
+
+	extensions.mu.RLock()
+	defer extensions.mu.RUnlock()
+
+	codec, found := extensions.codecs[media]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", errUnknownCodec, contentType)
+	}
+
+	return codec, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+
+	err := json.Unmarshal(data, &value)
+
+	return value, err
+}
+
+func (jsonCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// extension is a custom matcher and responder pair added to a mock server.
+type extension struct {
+	route     *route
+	matcher   Matcher
+	responder Responder
+}
+
+// getExtensionSpec parses the extension specification, it is either a registered name or an object
+// with type property (the registered name) and configuration properties.
+func getExtensionSpec(value sobek.Value) (string, map[string]interface{}) {
+	config := make(map[string]interface{})
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return value.String(), config
+	}
+
+	for _, key := range obj.Keys() {
+		if key != "type" {
+			config[key] = obj.Get(key).Export()
+		}
+	}
+
+	return obj.Get("type").String(), config
+}
+
+// wrapExtensions adds the extension method to the application, it registers a custom matcher and
+// responder pair. Extensions are checked before the routes.
+func (srv *server) wrapExtensions() {
+	srv.mustSet("extension", func(matcherSpec, responderSpec sobek.Value) *sobek.Object {
+		matcherName, matcherConfig := getExtensionSpec(matcherSpec)
+
+		matcher, err := newMatcher(matcherName, matcherConfig)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		responderName, responderConfig := getExtensionSpec(responderSpec)
+
+		responder, err := newResponder(responderName, responderConfig)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		srv.extMu.Lock()
+		srv.exts = append(srv.exts, &extension{
+			route:     &route{pattern: matcherName},
+			matcher:   matcher,
+			responder: responder,
+		})
+		srv.extMu.Unlock()
+
+		return srv.app
+	})
+}
+
+// extension returns the first extension matching the request.
+func (srv *server) extension(r *http.Request) *extension {
+	srv.extMu.RLock()
+	defer srv.extMu.RUnlock()
+
+	for _, ext := range srv.exts {
+		if ext.matcher.Match(r) {
+			return ext
+		}
+	}
+
+	return nil
+}
+
+// decode converts the body to a JavaScript value with the codec of the content type.
+func (mod *Module) decode(data sobek.Value, contentType string) interface{} {
+	codec, err := codecFor(contentType)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	var raw []byte
+
+	if buf, isBuf := data.Export().(sobek.ArrayBuffer); isBuf {
+		raw = buf.Bytes()
+	} else {
+		raw = []byte(data.String())
+	}
+
+	value, err := codec.Decode(raw)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	return value
+}
+
+// encode converts the value to a body with the codec of the content type.
+func (mod *Module) encode(value sobek.Value, contentType string) sobek.ArrayBuffer {
+	codec, err := codecFor(contentType)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	data, err := codec.Encode(value.Export())
+	if err != nil {
+		mod.throw(err)
+	}
+
+	return mod.runtime().NewArrayBuffer(data)
+}
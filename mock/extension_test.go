@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() { // nolint:gochecknoinits
+	RegisterMatcher("test-prefix", func(config map[string]interface{}) (Matcher, error) {
+		prefix, _ := config["prefix"].(string)
+
+		return MatcherFunc(func(r *http.Request) bool {
+			return strings.HasPrefix(r.URL.Path, prefix)
+		}), nil
+	})
+
+	RegisterCodec("application/x-test-lines", linesCodec{})
+
+	RegisterResponder("test-teapot", func(_ map[string]interface{}) (Responder, error) {
+		return ResponderFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}), nil
+	})
+}
+
+func TestCodecFor(t *testing.T) {
+	t.Parallel()
+
+	codec, err := codecFor("application/json; charset=utf-8")
+
+	require.NoError(t, err)
+
+	value, err := codec.Decode([]byte(`{"id":1}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, value)
+
+	_, err = codecFor("application/x-unknown")
+
+	assert.ErrorIs(t, err, errUnknownCodec)
+}
+
+func TestNewMatcher(t *testing.T) {
+	t.Parallel()
+
+	matcher, err := newMatcher("test-prefix", map[string]interface{}{"prefix": "/rpc/"})
+
+	require.NoError(t, err)
+
+	assert.True(t, matcher.Match(httptest.NewRequest(http.MethodGet, "/rpc/call", nil)))
+	assert.False(t, matcher.Match(httptest.NewRequest(http.MethodGet, "/api", nil)))
+
+	_, err = newMatcher("missing", nil)
+
+	assert.ErrorIs(t, err, errUnknownExtension)
+
+	_, err = newResponder("missing", nil)
+
+	assert.ErrorIs(t, err, errUnknownExtension)
+}
+
+func TestExtension(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.extension({ type: "test-prefix", prefix: "/rpc/" }, "test-teapot")
+	app.get("/rpc/call", (req, res) => res.text("Hello World!"))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	res, err := req.Get(helper.module.lookup["https://example.com"] + "/rpc/call")
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+}
+
+type linesCodec struct{}
+
+func (linesCodec) Decode(data []byte) (interface{}, error) {
+	lines := make([]interface{}, 0)
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+func (linesCodec) Encode(value interface{}) ([]byte, error) {
+	return []byte(fmt.Sprint(value)), nil
+}
+
+func TestExtensionCodecBody(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.post("/lines", (req, res) => res.json({ count: req.body.length, first: req.body[0], raw: req.rawBody }))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	res, err := req.R().
+		SetHeader("Content-Type", "application/x-test-lines; charset=utf-8").
+		SetBody("first\nsecond\n").
+		Post(helper.module.lookup["https://example.com"] + "/lines")
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := res.ToString()
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"count":2,"first":"first","raw":"first\nsecond\n"}`, body)
+}
@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	headerFault       = "X-Mock-Fault"
+	headerFaultStatus = "X-Mock-Fault-Status" // error status response of the fault schedule
+
+	faultStatus = "status" // the fault of the events of error status faults
+)
+
+// Transport level faults, the response is replaced by a failure of the client connection.
+const (
+	faultConnectionReset = "connection-reset" // connection closed with TCP RST
+	faultEmptyResponse   = "empty-response"   // connection closed without response
+	faultMalformedChunk  = "malformed-chunk"  // chunked response with invalid chunk size
+	faultRandomGarbage   = "random-garbage"   // random bytes instead of HTTP response
+)
+
+const garbageSize = 512
+
+var transportFaults = map[string]bool{ // nolint:gochecknoglobals
+	faultConnectionReset: true,
+	faultEmptyResponse:   true,
+	faultMalformedChunk:  true,
+	faultRandomGarbage:   true,
+}
+
+// getTransportFault validates the transport fault kind, empty string means no fault.
+func getTransportFault(kind string) (string, error) {
+	if len(kind) != 0 && !transportFaults[kind] {
+		return "", fmt.Errorf("%w: unknown fault %q", errInvalidArg, kind)
+	}
+
+	return kind, nil
+}
+
+// faultMethod is the fault method of the response objects. The response is sent with the
+// X-Mock-Fault control header, the server replaces it with the transport level fault.
+func (srv *server) faultMethod(call sobek.FunctionCall) sobek.Value {
+	runtime := srv.mod.runtime()
+	res := call.This.ToObject(runtime)
+	kind := call.Argument(0).String()
+
+	if _, err := getTransportFault(kind); err != nil {
+		srv.mod.throw(err)
+	}
+
+	srv.set(res, headerFault, kind)
+	srv.mod.stats.fault(srv.target)
+
+	send, ok := sobek.AssertFunction(res.Get("send"))
+	if !ok {
+		srv.mod.throwf("missing send method", errInvalidArg)
+	}
+
+	if _, err := send(res, runtime.ToValue("")); err != nil {
+		srv.mod.throw(err)
+	}
+
+	return res
+}
+
+// writeFault writes the transport level fault to the hijacked connection, the connection is closed
+// by the caller afterwards.
+func writeFault(w *bufio.Writer, kind string, status int, header http.Header) error {
+	switch kind {
+	case faultMalformedChunk:
+		fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+
+		header = header.Clone()
+
+		header.Del("Content-Length")
+		header.Set("Transfer-Encoding", "chunked")
+		header.Write(w) // nolint:errcheck,gosec
+
+		w.WriteString("\r\n5\r\nmock \r\nzz\r\nmalformed chunk\r\n") // nolint:errcheck,gosec
+	case faultRandomGarbage:
+		garbage := make([]byte, garbageSize)
+
+		rand.Read(garbage) // nolint:errcheck,gosec
+
+		w.Write(garbage) // nolint:errcheck,gosec
+	}
+
+	return w.Flush()
+}
+
+// resetConn makes closing the connection send TCP RST instead of FIN: lingering of the underlying
+// TCP connection is disabled.
+func resetConn(conn net.Conn) {
+	for conn != nil {
+		if tcp, ok := conn.(interface{ SetLinger(sec int) error }); ok {
+			tcp.SetLinger(0) // nolint:errcheck,gosec
+
+			return
+		}
+
+		if tracked, ok := conn.(*trackedConn); ok {
+			conn = tracked.Conn
+
+			continue
+		}
+
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return
+		}
+
+		conn = wrapper.NetConn()
+	}
+}
+
+// injectFault replaces the response held back by the recorder with the transport level fault. The
+// connection is hijacked, if it is not possible (e.g. HTTP/2) the stream is aborted.
+func (srv *server) injectFault(w http.ResponseWriter, rec *recorder) {
+	conn, buff, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	defer conn.Close() // nolint:errcheck
+
+	if rec.fault == faultConnectionReset {
+		resetConn(conn)
+
+		return
+	}
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	writeFault(buff.Writer, rec.fault, status, rec.header) // nolint:errcheck,gosec
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectFault(t *testing.T) {
+	t.Parallel()
+
+	srv := new(server)
+
+	for _, kind := range []string{faultConnectionReset, faultEmptyResponse, faultMalformedChunk, faultRandomGarbage} {
+		kind := kind
+
+		t.Run(kind, func(t *testing.T) {
+			t.Parallel()
+
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rec := newRecorder(w, nil)
+
+				rec.Header().Set(headerFault, kind)
+				rec.Header().Set("Content-Type", "text/plain")
+				rec.Write([]byte("held back")) // nolint:errcheck
+
+				assert.Equal(t, kind, rec.fault)
+
+				srv.injectFault(w, rec)
+			}))
+			defer backend.Close()
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true, MaxIdleConns: -1}}
+
+			res, err := client.Get(backend.URL) // nolint:noctx
+			if err == nil {
+				_, err = io.ReadAll(res.Body)
+
+				res.Body.Close() // nolint:errcheck,gosec
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTransportFault(t *testing.T) {
+	t.Parallel()
+
+	kind, err := getTransportFault(faultRandomGarbage)
+
+	require.NoError(t, err)
+	assert.Equal(t, faultRandomGarbage, kind)
+
+	kind, err = getTransportFault("")
+
+	require.NoError(t, err)
+	assert.Empty(t, kind)
+
+	_, err = getTransportFault("meltdown")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
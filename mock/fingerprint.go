@@ -0,0 +1,530 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // nolint:gosec
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	tlsRecordHandshake  = 22
+	tlsClientHello      = 1
+	tlsMaxHelloSize     = 64 << 10
+	extServerName       = 0
+	extSupportedGroups  = 10
+	extPointFormats     = 11
+	extSignatureAlgs    = 13
+	extALPN             = 16
+	extSupportedVersion = 43
+
+	http2MaxPrefaceSize = 64 << 10
+	http2HeaderTable    = 4096
+)
+
+var errNotClientHello = errors.New("not a TLS ClientHello")
+
+// clientHello is the fingerprint of a TLS client, the parameters of its ClientHello message.
+type clientHello struct {
+	remote       string
+	time         time.Time
+	version      uint16
+	ciphers      []uint16
+	extensions   []uint16
+	curves       []uint16
+	points       []uint8
+	serverName   string
+	alpn         []string
+	versions     []uint16
+	signatureAlg []uint16
+	http2        atomic.Pointer[http2Settings] // nil if the connection does not use HTTP/2
+}
+
+// isGREASE reports whether the value is a GREASE value (RFC 8701), JA3 ignores them.
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func joinValues[T uint8 | uint16](values []T) string {
+	parts := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if !isGREASE(uint16(value)) {
+			parts = append(parts, strconv.Itoa(int(value)))
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// ja3 returns the JA3 string of the ClientHello.
+func (h *clientHello) ja3() string {
+	return strings.Join([]string{
+		strconv.Itoa(int(h.version)),
+		joinValues(h.ciphers),
+		joinValues(h.extensions),
+		joinValues(h.curves),
+		joinValues(h.points),
+	}, ",")
+}
+
+func (h *clientHello) object() map[string]interface{} {
+	ja3 := h.ja3()
+	hash := md5.Sum([]byte(ja3)) // nolint:gosec
+
+	list := func(values []uint16) []int {
+		out := make([]int, 0, len(values))
+
+		for _, value := range values {
+			out = append(out, int(value))
+		}
+
+		return out
+	}
+
+	obj := map[string]interface{}{
+		"remote":              h.remote,
+		"time":                h.time.UnixMilli(),
+		"ja3":                 ja3,
+		"ja3Hash":             hex.EncodeToString(hash[:]),
+		"version":             int(h.version),
+		"ciphers":             list(h.ciphers),
+		"extensions":          list(h.extensions),
+		"curves":              list(h.curves),
+		"pointFormats":        joinValues(h.points),
+		"serverName":          h.serverName,
+		"alpn":                h.alpn,
+		"supportedVersions":   list(h.versions),
+		"signatureAlgorithms": list(h.signatureAlg),
+	}
+
+	if settings := h.http2.Load(); settings != nil {
+		obj["http2"] = settings.object()
+	}
+
+	return obj
+}
+
+// http2Settings is the HTTP/2 fingerprint of a client, the frames of its connection preface up to the
+// first HEADERS frame.
+type http2Settings struct {
+	settings      []http2.Setting
+	windowUpdate  uint32   // connection window increment, zero if not sent
+	priorities    []string // PRIORITY frames as stream:exclusive:dependency:weight
+	pseudoHeaders []string // order of the pseudo headers of the first request, e.g. m, a, s, p
+}
+
+// fingerprint returns the HTTP/2 fingerprint in the Akamai format: settings, window update, priorities
+// and pseudo header order separated by "|".
+func (s *http2Settings) fingerprint() string {
+	settings := make([]string, 0, len(s.settings))
+
+	for _, setting := range s.settings {
+		settings = append(settings, fmt.Sprintf("%d:%d", setting.ID, setting.Val))
+	}
+
+	window := "00"
+	if s.windowUpdate != 0 {
+		window = strconv.FormatUint(uint64(s.windowUpdate), 10)
+	}
+
+	priorities := "0"
+	if len(s.priorities) != 0 {
+		priorities = strings.Join(s.priorities, ",")
+	}
+
+	return strings.Join([]string{
+		strings.Join(settings, ";"),
+		window,
+		priorities,
+		strings.Join(s.pseudoHeaders, ","),
+	}, "|")
+}
+
+func (s *http2Settings) object() map[string]interface{} {
+	settings := make(map[string]interface{}, len(s.settings))
+
+	for _, setting := range s.settings {
+		settings[setting.ID.String()] = int(setting.Val)
+	}
+
+	fingerprint := s.fingerprint()
+	hash := md5.Sum([]byte(fingerprint)) // nolint:gosec
+
+	return map[string]interface{}{
+		"fingerprint":   fingerprint,
+		"hash":          hex.EncodeToString(hash[:]),
+		"settings":      settings,
+		"windowUpdate":  int(s.windowUpdate),
+		"priorities":    s.priorities,
+		"pseudoHeaders": s.pseudoHeaders,
+	}
+}
+
+// readHTTP2Settings returns the HTTP/2 fingerprint of the connection preface, complete is false if more
+// data is needed. The fingerprint is nil if the data is not an HTTP/2 connection preface.
+func readHTTP2Settings(data []byte) (*http2Settings, bool) {
+	preface := []byte(http2.ClientPreface)
+
+	if len(data) < len(preface) {
+		return nil, !bytes.HasPrefix(preface, data)
+	}
+
+	if !bytes.HasPrefix(data, preface) {
+		return nil, true
+	}
+
+	framer := http2.NewFramer(io.Discard, bytes.NewReader(data[len(preface):]))
+	framer.ReadMetaHeaders = hpack.NewDecoder(http2HeaderTable, nil)
+
+	settings := new(http2Settings)
+
+	for {
+		frame, err := framer.ReadFrame()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false
+		}
+
+		if err != nil {
+			return settings, true
+		}
+
+		switch frame := frame.(type) {
+		case *http2.SettingsFrame:
+			frame.ForeachSetting(func(setting http2.Setting) error { // nolint:errcheck,gosec
+				settings.settings = append(settings.settings, setting)
+
+				return nil
+			})
+		case *http2.WindowUpdateFrame:
+			if frame.StreamID == 0 {
+				settings.windowUpdate = frame.Increment
+			}
+		case *http2.PriorityFrame:
+			exclusive := 0
+			if frame.Exclusive {
+				exclusive = 1
+			}
+
+			settings.priorities = append(settings.priorities,
+				fmt.Sprintf("%d:%d:%d:%d", frame.StreamID, exclusive, frame.StreamDep, int(frame.Weight)+1))
+		case *http2.MetaHeadersFrame:
+			for _, field := range frame.PseudoFields() {
+				settings.pseudoHeaders = append(settings.pseudoHeaders, field.Name[1:2])
+			}
+
+			return settings, true
+		}
+	}
+}
+
+// settingsConn records the HTTP/2 fingerprint of the connection while the HTTP/2 server reads the
+// connection preface.
+type settingsConn struct {
+	*tls.Conn
+	buff  []byte
+	hello *clientHello
+}
+
+func newSettingsConn(conn *tls.Conn) *settingsConn {
+	c := &settingsConn{Conn: conn}
+
+	if hello, ok := conn.NetConn().(*helloConn); ok {
+		c.hello = hello.hello.Load()
+	}
+
+	return c
+}
+
+func (c *settingsConn) Read(data []byte) (int, error) {
+	n, err := c.Conn.Read(data)
+
+	if c.hello != nil && n > 0 {
+		c.buff = append(c.buff, data[:n]...)
+
+		settings, complete := readHTTP2Settings(c.buff)
+		if complete || len(c.buff) > http2MaxPrefaceSize {
+			if settings != nil {
+				c.hello.http2.Store(settings)
+			}
+
+			c.hello, c.buff = nil, nil
+		}
+	}
+
+	return n, err
+}
+
+// configureHTTP2 serves the HTTP/2 connections by golang.org/x/net/http2 instead of the server bundled
+// in net/http, so the connection preface can be read for the HTTP/2 fingerprint.
+func configureHTTP2(hs *http.Server) error {
+	h2 := new(http2.Server)
+
+	if err := http2.ConfigureServer(hs, h2); err != nil {
+		return err
+	}
+
+	hs.TLSNextProto[http2.NextProtoTLS] = func(hs *http.Server, conn *tls.Conn, handler http.Handler) {
+		ctx := context.Background()
+
+		// net/http passes the connection's base context by the handler, see http2.ConfigureServer
+		if base, ok := handler.(interface{ BaseContext() context.Context }); ok {
+			ctx = base.BaseContext()
+		}
+
+		h2.ServeConn(newSettingsConn(conn), &http2.ServeConnOpts{Context: ctx, Handler: handler, BaseConfig: hs})
+	}
+
+	return nil
+}
+
+// helloReader reads the fields of a ClientHello message.
+type helloReader struct {
+	data []byte
+	err  error
+}
+
+func (r *helloReader) next(size int) []byte {
+	if r.err != nil || len(r.data) < size {
+		r.err = errNotClientHello
+
+		return nil
+	}
+
+	out := r.data[:size]
+	r.data = r.data[size:]
+
+	return out
+}
+
+func (r *helloReader) uint8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (r *helloReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+// vector returns a length prefixed vector, the length has the given size in bytes.
+func (r *helloReader) vector(size int) *helloReader {
+	var length int
+
+	if size == 1 {
+		length = int(r.uint8())
+	} else {
+		length = int(r.uint16())
+	}
+
+	return &helloReader{data: r.next(length), err: r.err}
+}
+
+func (r *helloReader) uint16s() []uint16 {
+	var out []uint16
+
+	for r.err == nil && len(r.data) >= 2 {
+		out = append(out, r.uint16())
+	}
+
+	return out
+}
+
+// parseClientHello parses the ClientHello handshake message (without the handshake header).
+func parseClientHello(msg []byte) (*clientHello, error) {
+	r := &helloReader{data: msg}
+	hello := &clientHello{version: r.uint16()}
+
+	r.next(32)  // random
+	r.vector(1) // session id
+
+	hello.ciphers = r.vector(2).uint16s()
+
+	r.vector(1) // compression methods
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	exts := r.vector(2)
+
+	for exts.err == nil && len(exts.data) != 0 {
+		typ := exts.uint16()
+		ext := exts.vector(2)
+
+		hello.extensions = append(hello.extensions, typ)
+
+		switch typ {
+		case extServerName:
+			names := ext.vector(2)
+			if names.uint8() == 0 { // host_name
+				hello.serverName = string(names.vector(2).data)
+			}
+		case extSupportedGroups:
+			hello.curves = ext.vector(2).uint16s()
+		case extPointFormats:
+			hello.points = ext.vector(1).data
+		case extSignatureAlgs:
+			hello.signatureAlg = ext.vector(2).uint16s()
+		case extALPN:
+			protos := ext.vector(2)
+			for protos.err == nil && len(protos.data) != 0 {
+				hello.alpn = append(hello.alpn, string(protos.vector(1).data))
+			}
+		case extSupportedVersion:
+			versions := ext.vector(1)
+			hello.versions = versions.uint16s()
+		}
+	}
+
+	return hello, exts.err
+}
+
+// readClientHello returns the ClientHello message of the TLS records, complete is false if more data is needed.
+func readClientHello(data []byte) (*clientHello, bool, error) {
+	var msg []byte
+
+	for len(data) >= 5 {
+		if data[0] != tlsRecordHandshake {
+			return nil, true, errNotClientHello
+		}
+
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+size {
+			break
+		}
+
+		msg = append(msg, data[5:5+size]...)
+		data = data[5+size:]
+
+		if len(msg) >= 4 {
+			if msg[0] != tlsClientHello {
+				return nil, true, errNotClientHello
+			}
+
+			length := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+			if len(msg) >= 4+length {
+				hello, err := parseClientHello(msg[4 : 4+length])
+
+				return hello, true, err
+			}
+		}
+	}
+
+	return nil, false, nil
+}
+
+// helloConn records the ClientHello of the connection while the TLS server reads it.
+type helloConn struct {
+	net.Conn
+	buff   []byte
+	done   bool
+	record func(*clientHello)
+	hello  atomic.Pointer[clientHello]
+}
+
+func (c *helloConn) Read(data []byte) (int, error) {
+	n, err := c.Conn.Read(data)
+
+	if !c.done && n > 0 {
+		c.buff = append(c.buff, data[:n]...)
+
+		hello, complete, perr := readClientHello(c.buff)
+		if complete || len(c.buff) > tlsMaxHelloSize {
+			c.done, c.buff = true, nil
+
+			if perr == nil && hello != nil {
+				hello.remote = c.RemoteAddr().String()
+				hello.time = time.Now()
+				c.record(hello)
+				c.hello.Store(hello)
+			}
+		}
+	}
+
+	return n, err
+}
+
+// NetConn returns the underlying connection.
+func (c *helloConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// helloListener records the ClientHello messages of the accepted connections.
+type helloListener struct {
+	net.Listener
+	record func(*clientHello)
+}
+
+func (l *helloListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &helloConn{Conn: conn, record: l.record}, nil
+}
+
+// clients is the bounded list of recorded client fingerprints.
+type clients struct {
+	mu    sync.Mutex
+	hello []*clientHello
+}
+
+func (c *clients) add(hello *clientHello) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.hello) >= journalLimit {
+		c.hello = c.hello[1:]
+	}
+
+	c.hello = append(c.hello, hello)
+}
+
+func (c *clients) export() []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]interface{}, 0, len(c.hello))
+
+	for _, hello := range c.hello {
+		out = append(out, hello.object())
+	}
+
+	return out
+}
+
+// helloOf returns the fingerprint of the request's TLS client, nil if not recorded.
+func helloOf(ctx context.Context) *clientHello {
+	if conn, ok := ctx.Value(helloContextKey{}).(*helloConn); ok {
+		return conn.hello.Load()
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestJA3(t *testing.T) {
+	t.Parallel()
+
+	hello := &clientHello{
+		version:    0x0303,
+		ciphers:    []uint16{0x0a0a, 4865, 4866},
+		extensions: []uint16{0x1a1a, 0, 10, 11},
+		curves:     []uint16{29, 23},
+		points:     []uint8{0},
+	}
+
+	assert.Equal(t, "771,4865-4866,0-10-11,29-23,0", hello.ja3())
+	assert.Equal(t, "38eaca597c62da4c9db8cfad482f14ad", hello.object()["ja3Hash"])
+}
+
+func TestClientHello(t *testing.T) {
+	t.Parallel()
+
+	listener, err := newTLSListener("https://example.com", &tlsOptions{})
+
+	require.NoError(t, err)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	server := listener.wrap(tcp)
+
+	defer server.Close() // nolint:errcheck
+
+	go func() {
+		for {
+			conn, err := server.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.(*tls.Conn).Handshake() // nolint:errcheck,forcetypeassert,gosec
+			conn.Close()                 // nolint:errcheck,gosec
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", tcp.Addr().String(), &tls.Config{ // nolint:exhaustruct,gosec
+		ServerName:         "example.com",
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	found := listener.clients.export()
+
+	require.Len(t, found, 1)
+
+	client, _ := found[0].(map[string]interface{})
+
+	assert.Equal(t, "example.com", client["serverName"])
+	assert.Equal(t, []string{"h2", "http/1.1"}, client["alpn"])
+	assert.Equal(t, conn.LocalAddr().String(), client["remote"])
+	assert.True(t, strings.HasPrefix(client["ja3"].(string), "771,"))
+	assert.Contains(t, client["supportedVersions"], int(tls.VersionTLS13))
+	assert.Len(t, client["ja3Hash"], 32)
+}
+
+func TestHTTP2Settings(t *testing.T) {
+	t.Parallel()
+
+	var buff bytes.Buffer
+
+	buff.WriteString(http2.ClientPreface)
+
+	framer := http2.NewFramer(&buff, nil)
+
+	require.NoError(t, framer.WriteSettings(
+		http2.Setting{ID: http2.SettingHeaderTableSize, Val: 65536},
+		http2.Setting{ID: http2.SettingInitialWindowSize, Val: 6291456},
+	))
+	require.NoError(t, framer.WriteWindowUpdate(0, 15663105))
+	require.NoError(t, framer.WritePriority(3, http2.PriorityParam{StreamDep: 0, Exclusive: false, Weight: 200}))
+
+	var block bytes.Buffer
+
+	encoder := hpack.NewEncoder(&block)
+
+	for _, field := range []hpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":path", Value: "/"},
+		{Name: "accept", Value: "*/*"},
+	} {
+		require.NoError(t, encoder.WriteField(field))
+	}
+
+	require.NoError(t, framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: block.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+	}))
+
+	data := buff.Bytes()
+
+	settings, complete := readHTTP2Settings(data[:len(data)-5])
+
+	assert.False(t, complete)
+	assert.Nil(t, settings)
+
+	settings, complete = readHTTP2Settings(data)
+
+	require.True(t, complete)
+	require.NotNil(t, settings)
+	assert.Equal(t, "1:65536;4:6291456|15663105|3:0:0:201|m,a,s,p", settings.fingerprint())
+	assert.Equal(t, 65536, settings.object()["settings"].(map[string]interface{})["HEADER_TABLE_SIZE"]) // nolint:forcetypeassert
+
+	settings, complete = readHTTP2Settings([]byte("GET / HTTP/1.1\r\n"))
+
+	assert.True(t, complete)
+	assert.Nil(t, settings)
+}
+
+func TestHTTP2Fingerprint(t *testing.T) {
+	t.Parallel()
+
+	listener, err := newTLSListener("https://example.com", &tlsOptions{})
+
+	require.NoError(t, err)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	fingerprints := make(chan interface{}, 1)
+
+	server := &http.Server{ // nolint:exhaustruct
+		ReadHeaderTimeout: readHeaderTimeout,
+		ConnContext:       connContext,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fingerprints <- helloOf(r.Context()).object()["http2"]
+
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+
+	require.NoError(t, configureHTTP2(server))
+
+	go server.Serve(listener.wrap(tcp)) // nolint:errcheck
+
+	defer server.Close() // nolint:errcheck
+
+	client := &http.Client{Transport: &http.Transport{ // nolint:exhaustruct
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{ServerName: "example.com", InsecureSkipVerify: true}, // nolint:exhaustruct,gosec
+	}}
+
+	res, err := client.Get("https://" + tcp.Addr().String() + "/")
+
+	require.NoError(t, err)
+
+	io.Copy(io.Discard, res.Body) // nolint:errcheck,gosec
+	res.Body.Close()              // nolint:errcheck,gosec
+
+	assert.Equal(t, 2, res.ProtoMajor)
+
+	fingerprint := <-fingerprints
+
+	require.NotNil(t, fingerprint)
+
+	settings, _ := fingerprint.(map[string]interface{})
+
+	assert.ElementsMatch(t, []string{"m", "a", "s", "p"}, settings["pseudoHeaders"])
+	assert.Len(t, settings["hash"], 32)
+
+	found := listener.clients.export()
+
+	require.Len(t, found, 1)
+	assert.Equal(t, fingerprint, found[0].(map[string]interface{})["http2"]) // nolint:forcetypeassert
+}
@@ -0,0 +1,300 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	mutationDrop   = "drop"
+	mutationType   = "type"
+	mutationHuge   = "huge"
+	mutationStatus = "status"
+
+	hugeStringLength = 64 * 1024
+)
+
+var allMutations = []string{mutationDrop, mutationType, mutationHuge, mutationStatus}
+
+// fuzzer mutates responses at a configured rate, to test client robustness against misbehaving upstreams.
+type fuzzer struct {
+	mu        sync.Mutex
+	rate      float64
+	mutations []string
+	rand      *rand.Rand
+}
+
+func newFuzzer(rate float64, mutations []string, seed int64) *fuzzer {
+	if len(mutations) == 0 {
+		mutations = allMutations
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &fuzzer{rate: rate, mutations: mutations, rand: rand.New(rand.NewSource(seed))} // nolint:gosec
+}
+
+// getFuzzer parses the fuzz option. It is either a rate number or an object with
+// rate, mutations and seed properties.
+func getFuzzer(value sobek.Value) *fuzzer {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if rate := value.ToFloat(); rate > 0 {
+			return newFuzzer(rate, nil, 0)
+		}
+
+		return nil
+	}
+
+	rate := 1.0
+	if v := obj.Get("rate"); v != nil && !sobek.IsUndefined(v) {
+		rate = v.ToFloat()
+	}
+
+	var seed int64
+	if v := obj.Get("seed"); v != nil && !sobek.IsUndefined(v) {
+		seed = v.ToInteger()
+	}
+
+	var mutations []string
+	if v := obj.Get("mutations"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		switch list := v.Export().(type) {
+		case []string:
+			mutations = list
+		case []interface{}:
+			for _, item := range list {
+				if name, ok := item.(string); ok {
+					mutations = append(mutations, name)
+				}
+			}
+		}
+	}
+
+	return newFuzzer(rate, mutations, seed)
+}
+
+// fuzz mutates the response with the configured probability and returns the applied mutation (if any).
+func (f *fuzzer) fuzz(res *response) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.rand.Float64() >= f.rate {
+		return ""
+	}
+
+	var doc interface{}
+
+	isJSON := json.Unmarshal(res.body.Bytes(), &doc) == nil
+
+	candidates := make([]string, 0, len(f.mutations))
+
+	for _, name := range f.mutations {
+		switch name {
+		case mutationDrop, mutationType:
+			if isJSON && len(collectFields(doc, nil)) != 0 {
+				candidates = append(candidates, name)
+			}
+		case mutationHuge, mutationStatus:
+			candidates = append(candidates, name)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	mutation := candidates[f.rand.Intn(len(candidates))]
+
+	switch mutation {
+	case mutationStatus:
+		res.status = f.flipStatus(res.status)
+	case mutationHuge:
+		if isJSON {
+			doc = f.hugeField(doc)
+		} else {
+			res.body.WriteString(strings.Repeat("x", hugeStringLength))
+		}
+	case mutationDrop:
+		doc = f.dropField(doc)
+	case mutationType:
+		doc = f.changeType(doc)
+	}
+
+	if isJSON && mutation != mutationStatus {
+		data, err := json.Marshal(doc)
+		if err == nil {
+			res.body = *bytes.NewBuffer(data)
+		}
+	}
+
+	return mutation
+}
+
+func (f *fuzzer) flipStatus(status int) int {
+	if status >= http.StatusBadRequest {
+		return http.StatusOK
+	}
+
+	codes := []int{
+		http.StatusBadRequest,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+	}
+
+	return codes[f.rand.Intn(len(codes))]
+}
+
+// field refers to a property of a JSON object or an item of a JSON array.
+type field struct {
+	object map[string]interface{}
+	array  []interface{}
+	key    string
+	index  int
+}
+
+func (fld *field) get() interface{} {
+	if fld.object != nil {
+		return fld.object[fld.key]
+	}
+
+	return fld.array[fld.index]
+}
+
+func (fld *field) set(value interface{}) {
+	if fld.object != nil {
+		fld.object[fld.key] = value
+	} else {
+		fld.array[fld.index] = value
+	}
+}
+
+// collectFields returns all object properties and array items of the JSON document in deterministic order.
+func collectFields(doc interface{}, found []*field) []*field {
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			found = append(found, &field{object: node, key: key})
+			found = collectFields(node[key], found)
+		}
+	case []interface{}:
+		for idx := range node {
+			found = append(found, &field{array: node, index: idx})
+			found = collectFields(node[idx], found)
+		}
+	}
+
+	return found
+}
+
+func (f *fuzzer) pick(doc interface{}, filter func(*field) bool) *field {
+	var found []*field
+
+	for _, fld := range collectFields(doc, nil) {
+		if filter(fld) {
+			found = append(found, fld)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	return found[f.rand.Intn(len(found))]
+}
+
+func (f *fuzzer) dropField(doc interface{}) interface{} {
+	fld := f.pick(doc, func(fld *field) bool { return fld.object != nil })
+	if fld == nil {
+		return f.changeType(doc)
+	}
+
+	delete(fld.object, fld.key)
+
+	return doc
+}
+
+func (f *fuzzer) changeType(doc interface{}) interface{} {
+	fld := f.pick(doc, func(*field) bool { return true })
+	if fld == nil {
+		return doc
+	}
+
+	switch value := fld.get().(type) {
+	case string:
+		fld.set(len(value))
+	case float64:
+		fld.set(strconv.FormatFloat(value, 'f', -1, 64))
+	case bool:
+		fld.set(map[bool]string{true: "true", false: "false"}[value])
+	case nil:
+		fld.set(map[string]interface{}{})
+	case map[string]interface{}:
+		fld.set([]interface{}{value})
+	case []interface{}:
+		fld.set(nil)
+	}
+
+	return doc
+}
+
+func (f *fuzzer) hugeField(doc interface{}) interface{} {
+	huge := strings.Repeat("x", hugeStringLength)
+
+	fld := f.pick(doc, func(fld *field) bool {
+		_, isString := fld.get().(string)
+
+		return isString
+	})
+	if fld == nil {
+		fld = f.pick(doc, func(*field) bool { return true })
+	}
+
+	if fld == nil {
+		return huge
+	}
+
+	fld.set(huge)
+
+	return doc
+}
+
+// fuzz applies the fuzzer of the server on a response produced by a route.
+func (srv *server) fuzz(res *response, r *http.Request) {
+	if mutation := srv.opts.fuzz.fuzz(res); len(mutation) != 0 {
+		res.faulted = true
+
+		srv.mod.logger.WithField("target", srv.target).
+			WithField("method", r.Method).
+			WithField("path", r.URL.Path).
+			WithField("mutation", mutation).
+			Debug("mock response fuzzed")
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newJSONResponse(t *testing.T, status int, body string) *response {
+	t.Helper()
+
+	res := newResponse()
+
+	res.WriteHeader(status)
+
+	_, err := res.Write([]byte(body))
+
+	require.NoError(t, err)
+
+	return res
+}
+
+func TestFuzzer(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"id":1,"name":"book","tags":["new"]}`
+
+	res := newJSONResponse(t, http.StatusOK, body)
+
+	assert.Empty(t, newFuzzer(0, nil, 1).fuzz(res))
+	assert.JSONEq(t, body, res.body.String())
+
+	res = newJSONResponse(t, http.StatusOK, body)
+
+	assert.Equal(t, mutationStatus, newFuzzer(1, []string{mutationStatus}, 1).fuzz(res))
+	assert.GreaterOrEqual(t, res.status, http.StatusBadRequest)
+
+	res = newJSONResponse(t, http.StatusOK, body)
+
+	assert.Equal(t, mutationDrop, newFuzzer(1, []string{mutationDrop}, 1).fuzz(res))
+
+	var doc map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(res.body.Bytes(), &doc))
+	assert.Len(t, doc, 2)
+
+	res = newJSONResponse(t, http.StatusOK, body)
+
+	assert.Equal(t, mutationType, newFuzzer(1, []string{mutationType}, 1).fuzz(res))
+	assert.NotEqual(t, body, res.body.String())
+
+	res = newJSONResponse(t, http.StatusOK, body)
+
+	assert.Equal(t, mutationHuge, newFuzzer(1, []string{mutationHuge}, 1).fuzz(res))
+	assert.Greater(t, res.body.Len(), hugeStringLength)
+
+	res = newJSONResponse(t, http.StatusOK, "plain text")
+
+	assert.Empty(t, newFuzzer(1, []string{mutationDrop}, 1).fuzz(res))
+	assert.Equal(t, mutationHuge, newFuzzer(1, []string{mutationDrop, mutationHuge}, 1).fuzz(res))
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// globs caches the compiled glob targets.
+var globs sync.Map // nolint:gochecknoglobals
+
+// isGlob reports whether the mock target contains wildcards.
+func isGlob(target string) bool {
+	return strings.Contains(target, "*")
+}
+
+// globTarget returns the regular expression of the glob target matching the prefix of the URLs. The
+// * wildcard matches any characters except /, the ** wildcard matches any characters. A trailing /**
+// is dropped, the targets match the URL prefix anyway.
+func globTarget(target string) *regexp.Regexp {
+	if re, found := globs.Load(target); found {
+		return re.(*regexp.Regexp) // nolint:forcetypeassert
+	}
+
+	pattern := strings.TrimSuffix(strings.TrimSuffix(target, "**"), "/")
+
+	var expr strings.Builder
+
+	expr.WriteString("^")
+
+	for idx, part := range strings.Split(pattern, "**") {
+		if idx != 0 {
+			expr.WriteString(".*")
+		}
+
+		for j, literal := range strings.Split(part, "*") {
+			if j != 0 {
+				expr.WriteString("[^/]*")
+			}
+
+			expr.WriteString(regexp.QuoteMeta(literal))
+		}
+	}
+
+	re := regexp.MustCompile(expr.String())
+
+	globs.Store(target, re)
+
+	return re
+}
+
+// matchTarget returns the prefix of the URL matched by the mock target, ok is false if the URL doesn't
+// start with the target. Targets may contain wildcards (see globTarget).
+func matchTarget(target, loc string) (string, bool) {
+	if !isGlob(target) {
+		return target, strings.HasPrefix(loc, target)
+	}
+
+	prefix := globTarget(target).FindString(loc)
+
+	return prefix, len(prefix) != 0
+}
+
+// betterTarget reports whether the target matching the given URL prefix wins over the current best
+// match: the longer matched prefix wins, then the target with less wildcards (e.g. the exact host).
+func betterTarget(target, prefix, best, bestPrefix string) bool {
+	if len(prefix) != len(bestPrefix) {
+		return len(prefix) > len(bestPrefix)
+	}
+
+	return strings.Count(target, "*") < strings.Count(best, "*")
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchTarget(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		target, loc, prefix string
+		ok                  bool
+	}{
+		{"https://api.example.com", "https://api.example.com/users", "https://api.example.com", true},
+		{"https://api.example.com", "https://cdn.example.com/users", "", false},
+		{"https://*.example.com/**", "https://cdn1.example.com/img/a.png", "https://cdn1.example.com", true},
+		{"https://*.example.com", "https://acme.example.com", "https://acme.example.com", true},
+		{"https://*.example.com", "https://example.com/x.example.com", "", false},
+		{"https://*.example.com/v1", "https://acme.example.com/v1/users", "https://acme.example.com/v1", true},
+		{"https://example.com/**/files", "https://example.com/a/b/files/1", "https://example.com/a/b/files", true},
+		{"https://*.example.com", "https://api.example.org", "", false},
+	} {
+		prefix, ok := matchTarget(tc.target, tc.loc)
+
+		assert.Equal(t, tc.ok, ok, tc.target+" "+tc.loc)
+
+		if tc.ok {
+			assert.Equal(t, tc.prefix, prefix, tc.target+" "+tc.loc)
+		}
+	}
+}
+
+func TestBetterTarget(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, betterTarget("https://api.example.com", "https://api.example.com", "https://*.example.com", "https://api.example.com"))
+	assert.False(t, betterTarget("https://*.example.com", "https://api.example.com", "https://api.example.com", "https://api.example.com"))
+	assert.True(t, betterTarget("https://*.example.com/v1", "https://api.example.com/v1", "https://api.example.com", "https://api.example.com"))
+}
@@ -0,0 +1,1292 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// GraphQL endpoints are served by resolvers defined per operation type and field, the GraphQL document
+// of the request is parsed and the result is built by the selection sets, so handlers don't need to
+// string-match query bodies. Only the executable subset of the language is parsed (no schema).
+
+var (
+	errGraphQLSyntax   = errors.New("syntax error")
+	errPendingResolver = errors.New("resolver promise is not settled")
+)
+
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  int
+	value string
+	line  int
+	col   int
+}
+
+// gqlError is a GraphQL error of the response, with location of the syntax errors and path of the field errors.
+type gqlError struct {
+	Message   string        `json:"message"`
+	Locations []gqlLocation `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (e *gqlError) Error() string {
+	return e.Message
+}
+
+// gqlVar is a variable reference of an argument value.
+type gqlVar string
+
+type gqlDirective struct {
+	name string
+	args map[string]interface{}
+}
+
+// gqlSelection is a field, a fragment spread (spread is the fragment name) or an inline fragment.
+type gqlSelection struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives []gqlDirective
+	selections []*gqlSelection
+
+	spread        string
+	inline        bool
+	typeCondition string
+}
+
+// key returns the response key of the field.
+func (s *gqlSelection) key() string {
+	if len(s.alias) != 0 {
+		return s.alias
+	}
+
+	return s.name
+}
+
+type gqlOperation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	defaults   map[string]interface{} // default values of the variables
+	selections []*gqlSelection
+}
+
+type gqlFragment struct {
+	typeCondition string
+	selections    []*gqlSelection
+}
+
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+// gqlLexer splits the GraphQL source into tokens, ignored tokens (whitespace, commas, comments) are dropped.
+type gqlLexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func (l *gqlLexer) errorf(line, col int, format string, args ...interface{}) error {
+	return &gqlError{
+		Message:   fmt.Sprintf("%s: %s", errGraphQLSyntax, fmt.Sprintf(format, args...)),
+		Locations: []gqlLocation{{Line: line, Column: col}},
+	}
+}
+
+func (l *gqlLexer) advance(n int) {
+	for _, char := range l.src[l.pos : l.pos+n] {
+		if char == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+
+	l.pos += n
+}
+
+func isNameChar(char byte, first bool) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
+		(!first && char >= '0' && char <= '9')
+}
+
+func (l *gqlLexer) next() (gqlToken, error) { // nolint:cyclop
+	for l.pos < len(l.src) {
+		char := l.src[l.pos]
+
+		if char == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+
+			continue
+		}
+
+		if char != ' ' && char != '\t' && char != '\n' && char != '\r' && char != ',' {
+			break
+		}
+
+		l.advance(1)
+	}
+
+	tok := gqlToken{line: l.line, col: l.col}
+
+	if l.pos >= len(l.src) {
+		return tok, nil
+	}
+
+	rest := l.src[l.pos:]
+	char := rest[0]
+
+	switch {
+	case strings.HasPrefix(rest, "..."):
+		tok.kind, tok.value = gqlPunct, "..."
+	case strings.ContainsRune("!$&():=@[]{}|", rune(char)):
+		tok.kind, tok.value = gqlPunct, string(char)
+	case isNameChar(char, true):
+		end := 1
+		for end < len(rest) && isNameChar(rest[end], false) {
+			end++
+		}
+
+		tok.kind, tok.value = gqlName, rest[:end]
+	case char == '-' || (char >= '0' && char <= '9'):
+		return l.number(tok)
+	case strings.HasPrefix(rest, `"""`):
+		return l.blockString(tok)
+	case char == '"':
+		return l.string(tok)
+	default:
+		return tok, l.errorf(tok.line, tok.col, "unexpected character %q", char)
+	}
+
+	l.advance(len(tok.value))
+
+	return tok, nil
+}
+
+func (l *gqlLexer) number(tok gqlToken) (gqlToken, error) {
+	rest := l.src[l.pos:]
+	end := 0
+	tok.kind = gqlInt
+
+	if rest[0] == '-' {
+		end++
+	}
+
+	digits := func() {
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+	}
+
+	digits()
+
+	if end < len(rest) && rest[end] == '.' {
+		tok.kind = gqlFloat
+		end++
+		digits()
+	}
+
+	if end < len(rest) && (rest[end] == 'e' || rest[end] == 'E') {
+		tok.kind = gqlFloat
+		end++
+
+		if end < len(rest) && (rest[end] == '+' || rest[end] == '-') {
+			end++
+		}
+
+		digits()
+	}
+
+	tok.value = rest[:end]
+
+	if _, err := strconv.ParseFloat(tok.value, 64); err != nil {
+		return tok, l.errorf(tok.line, tok.col, "invalid number %s", tok.value)
+	}
+
+	l.advance(end)
+
+	return tok, nil
+}
+
+func (l *gqlLexer) string(tok gqlToken) (gqlToken, error) {
+	rest := l.src[l.pos:]
+
+	end := 1
+	for end < len(rest) && rest[end] != '"' && rest[end] != '\n' {
+		if rest[end] == '\\' {
+			end++
+		}
+
+		end++
+	}
+
+	if end >= len(rest) || rest[end] != '"' {
+		return tok, l.errorf(tok.line, tok.col, "unterminated string")
+	}
+
+	// the escape sequences of GraphQL strings are the same as of JSON strings
+	if err := json.Unmarshal([]byte(rest[:end+1]), &tok.value); err != nil {
+		return tok, l.errorf(tok.line, tok.col, "invalid string %s", rest[:end+1])
+	}
+
+	tok.kind = gqlString
+
+	l.advance(end + 1)
+
+	return tok, nil
+}
+
+func (l *gqlLexer) blockString(tok gqlToken) (gqlToken, error) {
+	rest := l.src[l.pos+3:]
+
+	end := strings.Index(strings.ReplaceAll(rest, `\"""`, "xxxx"), `"""`)
+	if end < 0 {
+		return tok, l.errorf(tok.line, tok.col, "unterminated block string")
+	}
+
+	lines := strings.Split(strings.ReplaceAll(rest[:end], `\"""`, `"""`), "\n")
+	indent := -1
+
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if len(trimmed) != 0 && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+
+	for idx := 1; indent > 0 && idx < len(lines); idx++ {
+		if len(lines[idx]) >= indent {
+			lines[idx] = lines[idx][indent:]
+		}
+	}
+
+	for len(lines) != 0 && len(strings.TrimSpace(lines[0])) == 0 {
+		lines = lines[1:]
+	}
+
+	for len(lines) != 0 && len(strings.TrimSpace(lines[len(lines)-1])) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	tok.kind, tok.value = gqlString, strings.Join(lines, "\n")
+
+	l.advance(end + 6)
+
+	return tok, nil
+}
+
+// gqlParser is a recursive descent parser of GraphQL executable documents.
+type gqlParser struct {
+	lexer *gqlLexer
+	tok   gqlToken
+}
+
+// parseGraphQL parses the GraphQL document, it must contain at least one operation.
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{lexer: &gqlLexer{src: src, line: 1, col: 1}}
+
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+
+	doc := &gqlDocument{fragments: make(map[string]*gqlFragment)}
+
+	for p.tok.kind != gqlEOF {
+		if p.tok.kind == gqlName && p.tok.value == "fragment" {
+			name, fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+
+			doc.fragments[name] = fragment
+
+			continue
+		}
+
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+
+		doc.operations = append(doc.operations, op)
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, &gqlError{Message: "document contains no operation"}
+	}
+
+	return doc, nil
+}
+
+func (p *gqlParser) read() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.tok = tok
+
+	return nil
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.tok.kind == gqlEOF {
+		return p.lexer.errorf(p.tok.line, p.tok.col, "unexpected end of document")
+	}
+
+	return p.lexer.errorf(p.tok.line, p.tok.col, "unexpected %q", p.tok.value)
+}
+
+// is reports whether the current token is the given punctuator.
+func (p *gqlParser) is(punct string) bool {
+	return p.tok.kind == gqlPunct && p.tok.value == punct
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if !p.is(punct) {
+		return p.unexpected()
+	}
+
+	return p.read()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.kind != gqlName {
+		return "", p.unexpected()
+	}
+
+	name := p.tok.value
+
+	return name, p.read()
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: "query", defaults: make(map[string]interface{})}
+
+	if !p.is("{") {
+		kind, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+
+		if kind != "query" && kind != "mutation" && kind != "subscription" {
+			return nil, p.lexer.errorf(p.tok.line, p.tok.col, "unexpected %q", kind)
+		}
+
+		op.kind = kind
+
+		if p.tok.kind == gqlName {
+			op.name = p.tok.value
+
+			if err := p.read(); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := p.variables(op); err != nil {
+			return nil, err
+		}
+
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+	}
+
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	op.selections = selections
+
+	return op, nil
+}
+
+func (p *gqlParser) variables(op *gqlOperation) error {
+	if !p.is("(") {
+		return nil
+	}
+
+	if err := p.read(); err != nil {
+		return err
+	}
+
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+
+		if p.is("=") {
+			if err := p.read(); err != nil {
+				return err
+			}
+
+			value, err := p.value(true)
+			if err != nil {
+				return err
+			}
+
+			op.defaults[name] = value
+		}
+
+		if _, err := p.directives(); err != nil {
+			return err
+		}
+	}
+
+	return p.read()
+}
+
+// typeRef skips the type of a variable definition.
+func (p *gqlParser) typeRef() error {
+	if p.is("[") {
+		if err := p.read(); err != nil {
+			return err
+		}
+
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+
+	if p.is("!") {
+		return p.read()
+	}
+
+	return nil
+}
+
+func (p *gqlParser) fragment() (string, *gqlFragment, error) {
+	if err := p.read(); err != nil { // fragment
+		return "", nil, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if on, err := p.name(); err != nil || on != "on" {
+		return "", nil, p.lexer.errorf(p.tok.line, p.tok.col, "expected type condition of fragment %s", name)
+	}
+
+	fragment := new(gqlFragment)
+
+	if fragment.typeCondition, err = p.name(); err != nil {
+		return "", nil, err
+	}
+
+	if _, err := p.directives(); err != nil {
+		return "", nil, err
+	}
+
+	if fragment.selections, err = p.selectionSet(); err != nil {
+		return "", nil, err
+	}
+
+	return name, fragment, nil
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*gqlSelection
+
+	for !p.is("}") {
+		if p.tok.kind == gqlEOF {
+			return nil, p.unexpected()
+		}
+
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+
+		selections = append(selections, selection)
+	}
+
+	return selections, p.read()
+}
+
+func (p *gqlParser) selection() (*gqlSelection, error) { // nolint:cyclop
+	sel := new(gqlSelection)
+
+	var err error
+
+	if p.is("...") {
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case p.tok.kind == gqlName && p.tok.value == "on":
+			if err := p.read(); err != nil {
+				return nil, err
+			}
+
+			if sel.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+
+			sel.inline = true
+		case p.tok.kind == gqlName:
+			sel.spread = p.tok.value
+
+			if err := p.read(); err != nil {
+				return nil, err
+			}
+		default:
+			sel.inline = true
+		}
+
+		if sel.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+
+		if sel.inline {
+			sel.selections, err = p.selectionSet()
+		}
+
+		return sel, err
+	}
+
+	if sel.name, err = p.name(); err != nil {
+		return nil, err
+	}
+
+	if p.is(":") {
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+
+		sel.alias = sel.name
+
+		if sel.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if sel.args, err = p.arguments(); err != nil {
+		return nil, err
+	}
+
+	if sel.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+
+	if p.is("{") {
+		sel.selections, err = p.selectionSet()
+	}
+
+	return sel, err
+}
+
+func (p *gqlParser) arguments() (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+
+	if !p.is("(") {
+		return args, nil
+	}
+
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+
+	for !p.is(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		if args[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+
+	return args, p.read()
+}
+
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var directives []gqlDirective
+
+	for p.is("@") {
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+
+		directives = append(directives, gqlDirective{name: name, args: args})
+	}
+
+	return directives, nil
+}
+
+// value parses an input value, variables are not allowed in constant values (defaults).
+func (p *gqlParser) value(constant bool) (interface{}, error) { // nolint:cyclop
+	tok := p.tok
+
+	switch {
+	case p.is("$") && !constant:
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+
+		name, err := p.name()
+
+		return gqlVar(name), err
+	case p.is("["):
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+
+		list := []interface{}{}
+
+		for !p.is("]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, item)
+		}
+
+		return list, p.read()
+	case p.is("{"):
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+
+		obj := make(map[string]interface{})
+
+		for !p.is("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+
+			if obj[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+
+		return obj, p.read()
+	case tok.kind == gqlInt:
+		num, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.lexer.errorf(tok.line, tok.col, "invalid integer %s", tok.value)
+		}
+
+		return num, p.read()
+	case tok.kind == gqlFloat:
+		num, _ := strconv.ParseFloat(tok.value, 64)
+
+		return num, p.read()
+	case tok.kind == gqlString:
+		return tok.value, p.read()
+	case tok.kind == gqlName:
+		var value interface{} = tok.value // enum values are strings
+
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		}
+
+		return value, p.read()
+	}
+
+	return nil, p.unexpected()
+}
+
+// operation returns the operation to execute, selected by name if the document has more operations.
+func (doc *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if len(name) == 0 {
+		if len(doc.operations) > 1 {
+			return nil, &gqlError{Message: "operationName is required for documents with multiple operations"}
+		}
+
+		return doc.operations[0], nil
+	}
+
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+
+	return nil, &gqlError{Message: fmt.Sprintf("unknown operation %q", name)}
+}
+
+// resolveValue substitutes the variables of the argument value.
+func resolveValue(value interface{}, vars map[string]interface{}) interface{} {
+	switch val := value.(type) {
+	case gqlVar:
+		return vars[string(val)]
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+
+		for _, item := range val {
+			list = append(list, resolveValue(item, vars))
+		}
+
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(val))
+
+		for name, item := range val {
+			obj[name] = resolveValue(item, vars)
+		}
+
+		return obj
+	}
+
+	return value
+}
+
+// included evaluates the skip and include directives.
+func included(directives []gqlDirective, vars map[string]interface{}) bool {
+	for _, directive := range directives {
+		cond, _ := resolveValue(directive.args["if"], vars).(bool)
+
+		if (directive.name == "skip" && cond) || (directive.name == "include" && !cond) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// collectFields returns the fields of the selection set for an object of the given type, fragments are
+// expanded. Fragments apply if the type of the object is unknown, equals the type condition or it is
+// one of the possible types of the condition (interface or union).
+func (doc *gqlDocument) collectFields(
+	selections []*gqlSelection,
+	typename string,
+	vars map[string]interface{},
+	possibleTypes map[string][]string,
+) []*gqlSelection {
+	var (
+		fields []*gqlSelection
+		merged = make(map[string]*gqlSelection)
+	)
+
+	var collect func(selections []*gqlSelection, visited map[string]bool)
+
+	collect = func(selections []*gqlSelection, visited map[string]bool) {
+		for _, sel := range selections {
+			if !included(sel.directives, vars) {
+				continue
+			}
+
+			switch {
+			case len(sel.spread) != 0:
+				fragment, found := doc.fragments[sel.spread]
+				if !found || visited[sel.spread] || !typeMatches(fragment.typeCondition, typename, possibleTypes) {
+					continue
+				}
+
+				visited[sel.spread] = true
+
+				collect(fragment.selections, visited)
+			case sel.inline:
+				if typeMatches(sel.typeCondition, typename, possibleTypes) {
+					collect(sel.selections, visited)
+				}
+			default:
+				if prev, found := merged[sel.key()]; found {
+					merged[sel.key()] = &gqlSelection{
+						alias: prev.alias, name: prev.name, args: prev.args,
+						selections: append(append([]*gqlSelection(nil), prev.selections...), sel.selections...),
+					}
+
+					for idx, field := range fields {
+						if field == prev {
+							fields[idx] = merged[sel.key()]
+						}
+					}
+
+					continue
+				}
+
+				merged[sel.key()] = sel
+				fields = append(fields, sel)
+			}
+		}
+	}
+
+	collect(selections, make(map[string]bool))
+
+	return fields
+}
+
+func typeMatches(condition, typename string, possibleTypes map[string][]string) bool {
+	if len(condition) == 0 || len(typename) == 0 || condition == typename {
+		return true
+	}
+
+	for _, possible := range possibleTypes[condition] {
+		if possible == typename {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gqlResult is a JSON object keeping the order of the fields, as the response keys follow the selection order.
+type gqlResult struct {
+	keys   []string
+	values []interface{}
+}
+
+func (r *gqlResult) set(key string, value interface{}) {
+	r.keys = append(r.keys, key)
+	r.values = append(r.values, value)
+}
+
+func (r *gqlResult) MarshalJSON() ([]byte, error) {
+	var buff bytes.Buffer
+
+	buff.WriteByte('{')
+
+	for idx, key := range r.keys {
+		if idx != 0 {
+			buff.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(key)
+		buff.Write(name)
+		buff.WriteByte(':')
+
+		value, err := json.Marshal(r.values[idx])
+		if err != nil {
+			return nil, err
+		}
+
+		buff.Write(value)
+	}
+
+	buff.WriteByte('}')
+
+	return buff.Bytes(), nil
+}
+
+// gqlRequest contains the GraphQL request parameters of a GET or POST request.
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+var gqlOperationTypes = map[string]string{ // nolint:gochecknoglobals
+	"query":        "Query",
+	"mutation":     "Mutation",
+	"subscription": "Subscription",
+}
+
+// gqlExecution executes an operation by calling the resolvers of the script.
+type gqlExecution struct {
+	srv       *server
+	doc       *gqlDocument
+	vars      map[string]interface{}
+	req       *sobek.Object
+	resolvers *sobek.Object
+	possible  map[string][]string
+	errors    []*gqlError
+}
+
+// wrapGraphQL adds the graphql method to the application: the GraphQL endpoint accepts GET and POST
+// requests (JSON or application/graphql body) and responds with a GraphQL response envelope.
+func (srv *server) wrapGraphQL() {
+	srv.mustSet("graphql", func(path string, resolvers *sobek.Object, opts sobek.Value) sobek.Value {
+		if err := checkPath(path); err != nil {
+			srv.mod.throwf("invalid parameter constraint of graphql endpoint: %s", errInvalidArg, err)
+		}
+
+		if resolvers == nil {
+			srv.mod.throwf("missing resolvers of graphql endpoint %s", errInvalidArg, path)
+		}
+
+		possible := getPossibleTypes(optionOf(srv.mod.runtime(), opts, "possibleTypes"))
+
+		handler, _ := sobek.AssertFunction(srv.mod.runtime().ToValue(func(call sobek.FunctionCall) sobek.Value {
+			runtime := srv.mod.runtime()
+
+			srv.graphql(resolvers, possible, call.Argument(0).ToObject(runtime), call.Argument(1).ToObject(runtime))
+
+			return sobek.Undefined()
+		}))
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			srv.routeOptions(srv.router.add(method, path, []sobek.Callable{handler}), []sobek.Value{opts})
+		}
+
+		return srv.app
+	})
+}
+
+// getPossibleTypes parses the possibleTypes option, it maps interfaces and unions to their object types.
+func getPossibleTypes(value sobek.Value) map[string][]string {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	spec, _ := value.Export().(map[string]interface{})
+	possible := make(map[string][]string, len(spec))
+
+	for name, types := range spec {
+		list, _ := types.([]interface{})
+
+		for _, typename := range list {
+			possible[name] = append(possible[name], fmt.Sprint(typename))
+		}
+	}
+
+	return possible
+}
+
+// graphqlRequest returns the GraphQL request parameters of the HTTP request.
+func (srv *server) graphqlRequest(req *sobek.Object) (*gqlRequest, error) {
+	params := new(gqlRequest)
+
+	if req.Get("method").String() == http.MethodGet {
+		query, _ := req.Get("query").Export().(map[string]interface{})
+
+		params.Query, _ = query["query"].(string)
+		params.OperationName, _ = query["operationName"].(string)
+
+		if vars, _ := query["variables"].(string); len(vars) != 0 {
+			if err := json.Unmarshal([]byte(vars), &params.Variables); err != nil {
+				return nil, err
+			}
+		}
+
+		return params, nil
+	}
+
+	raw := req.Get("rawBody")
+	if raw == nil || sobek.IsUndefined(raw) {
+		return params, nil
+	}
+
+	if strings.HasPrefix(srv.header(req, "Content-Type"), "application/graphql") {
+		params.Query = raw.String()
+
+		return params, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw.String()), params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// graphql serves the GraphQL request.
+func (srv *server) graphql(resolvers *sobek.Object, possible map[string][]string, req, res *sobek.Object) {
+	params, err := srv.graphqlRequest(req)
+	if err == nil && len(params.Query) == 0 {
+		err = &gqlError{Message: "missing query"}
+	}
+
+	if err != nil {
+		srv.sendGraphQL(res, http.StatusBadRequest, map[string]interface{}{"errors": []*gqlError{{Message: err.Error()}}})
+
+		return
+	}
+
+	doc, err := parseGraphQL(params.Query)
+
+	var op *gqlOperation
+
+	if err == nil {
+		op, err = doc.operation(params.OperationName)
+	}
+
+	if err != nil {
+		var gerr *gqlError
+
+		if !errors.As(err, &gerr) {
+			gerr = &gqlError{Message: err.Error()}
+		}
+
+		srv.sendGraphQL(res, http.StatusOK, map[string]interface{}{"errors": []*gqlError{gerr}})
+
+		return
+	}
+
+	if op.kind != "query" && req.Get("method").String() == http.MethodGet {
+		srv.set(res, "Allow", http.MethodPost)
+		srv.sendGraphQL(res, http.StatusMethodNotAllowed, map[string]interface{}{
+			"errors": []*gqlError{{Message: op.kind + " operations require POST"}},
+		})
+
+		return
+	}
+
+	vars := make(map[string]interface{}, len(op.defaults)+len(params.Variables))
+
+	for name, value := range op.defaults {
+		vars[name] = value
+	}
+
+	for name, value := range params.Variables {
+		vars[name] = value
+	}
+
+	exec := &gqlExecution{srv: srv, doc: doc, vars: vars, req: req, resolvers: resolvers, possible: possible}
+	body := map[string]interface{}{"data": exec.operation(op, params.OperationName)}
+
+	if len(exec.errors) != 0 {
+		body["errors"] = exec.errors
+	}
+
+	srv.sendGraphQL(res, http.StatusOK, body)
+}
+
+func (srv *server) sendGraphQL(res *sobek.Object, status int, body map[string]interface{}) {
+	runtime := srv.mod.runtime()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	statusFn, _ := sobek.AssertFunction(res.Get("status"))
+	send, _ := sobek.AssertFunction(res.Get("send"))
+
+	if statusFn == nil || send == nil {
+		srv.mod.throwf("missing status or send method", errInvalidArg)
+	}
+
+	if _, err := statusFn(res, runtime.ToValue(status)); err != nil {
+		srv.mod.throw(err)
+	}
+
+	srv.set(res, "Content-Type", "application/json")
+
+	if _, err := send(res, runtime.ToValue(string(data))); err != nil {
+		srv.mod.throw(err)
+	}
+}
+
+// operation executes the operation. An operation resolver (a function keyed by the operation name)
+// returns the whole data, otherwise the root fields are resolved by the resolvers of the operation type.
+func (e *gqlExecution) operation(op *gqlOperation, name string) interface{} {
+	runtime := e.srv.mod.runtime()
+	typename := gqlOperationTypes[op.kind]
+
+	if len(name) != 0 {
+		if fn, ok := sobek.AssertFunction(e.resolvers.Get(name)); ok && gqlOperationTypes[name] == "" {
+			value, err := fn(e.resolvers, runtime.ToValue(e.vars), e.req)
+			if err != nil {
+				e.fail(err, nil)
+
+				return nil
+			}
+
+			return e.complete(value, op.selections, typename, nil)
+		}
+	}
+
+	var root sobek.Value = runtime.NewObject()
+
+	if obj := e.resolvers.Get(typename); obj != nil && !sobek.IsUndefined(obj) && !sobek.IsNull(obj) {
+		root = obj
+	}
+
+	result := new(gqlResult)
+
+	for _, field := range e.doc.collectFields(op.selections, typename, e.vars, e.possible) {
+		path := []interface{}{field.key()}
+
+		if field.name == "__typename" {
+			result.set(field.key(), typename)
+
+			continue
+		}
+
+		value := root.ToObject(runtime).Get(field.name)
+		if value == nil || sobek.IsUndefined(value) {
+			e.errors = append(e.errors, &gqlError{
+				Message: fmt.Sprintf("Cannot query field %q on type %q.", field.name, typename),
+				Path:    path,
+			})
+
+			result.set(field.key(), nil)
+
+			continue
+		}
+
+		result.set(field.key(), e.field(root.ToObject(runtime), value, field, path))
+	}
+
+	return result
+}
+
+// field resolves the value of the field: functions are called with the arguments of the field and the
+// request, other values are used as is.
+func (e *gqlExecution) field(parent *sobek.Object, value sobek.Value, field *gqlSelection, path []interface{}) interface{} {
+	runtime := e.srv.mod.runtime()
+
+	if fn, ok := sobek.AssertFunction(value); ok {
+		args, _ := resolveValue(field.args, e.vars).(map[string]interface{})
+
+		result, err := fn(parent, runtime.ToValue(args), e.req)
+		if err != nil {
+			e.fail(err, path)
+
+			return nil
+		}
+
+		value = result
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	if promise, ok := value.Export().(*sobek.Promise); ok {
+		switch promise.State() {
+		case sobek.PromiseStateFulfilled:
+			value = promise.Result()
+		case sobek.PromiseStateRejected:
+			e.errors = append(e.errors, &gqlError{Message: promise.Result().String(), Path: path})
+
+			return nil
+		default:
+			e.fail(errPendingResolver, path)
+
+			return nil
+		}
+	}
+
+	return e.complete(value, field.selections, "", path)
+}
+
+// complete builds the result value by the selection set, fields of nested objects may be resolvers too.
+func (e *gqlExecution) complete(value sobek.Value, selections []*gqlSelection, typename string, path []interface{}) interface{} {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	if len(selections) == 0 {
+		return value.Export()
+	}
+
+	runtime := e.srv.mod.runtime()
+	obj := value.ToObject(runtime)
+
+	if obj.ClassName() == "Array" {
+		length := int(obj.Get("length").ToInteger())
+		list := make([]interface{}, 0, length)
+
+		for idx := 0; idx < length; idx++ {
+			item := obj.Get(strconv.Itoa(idx))
+			list = append(list, e.complete(item, selections, typename, append(path[:len(path):len(path)], idx)))
+		}
+
+		return list
+	}
+
+	if tn := obj.Get("__typename"); tn != nil && !sobek.IsUndefined(tn) {
+		typename = tn.String()
+	}
+
+	result := new(gqlResult)
+
+	for _, field := range e.doc.collectFields(selections, typename, e.vars, e.possible) {
+		if field.name == "__typename" {
+			result.set(field.key(), typename)
+
+			continue
+		}
+
+		result.set(field.key(), e.field(obj, obj.Get(field.name), field, append(path[:len(path):len(path)], field.key())))
+	}
+
+	return result
+}
+
+func (e *gqlExecution) fail(err error, path []interface{}) {
+	message := err.Error()
+
+	var exception *sobek.Exception
+	if errors.As(err, &exception) {
+		if msg := exception.Value().ToObject(e.srv.mod.runtime()).Get("message"); msg != nil && !sobek.IsUndefined(msg) {
+			message = msg.String()
+		}
+	}
+
+	e.errors = append(e.errors, &gqlError{Message: message, Path: path})
+}
@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userQuery = `
+# fetch a user
+query GetUser($id: ID!, $withPosts: Boolean = false) {
+  user(id: $id, filter: { roles: [ADMIN, "editor"], limit: 10, ratio: -1.5e2 }) {
+    ...UserFields
+    posts @include(if: $withPosts) { title }
+    ... on Admin { permissions }
+    ... on Editor { sections }
+  }
+}
+
+fragment UserFields on User {
+  id
+  displayName: name
+  bio(format: """
+    plain
+      text
+  """)
+}
+
+mutation Rename { rename(name: "new\nname") { id } }
+`
+
+func TestParseGraphQL(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseGraphQL(userQuery)
+
+	require.NoError(t, err)
+	require.Len(t, doc.operations, 2)
+
+	_, err = doc.operation("")
+
+	assert.Error(t, err)
+
+	op, err := doc.operation("GetUser")
+
+	require.NoError(t, err)
+	assert.Equal(t, "query", op.kind)
+	assert.Equal(t, map[string]interface{}{"withPosts": false}, op.defaults)
+
+	user := op.selections[0]
+
+	assert.Equal(t, "user", user.name)
+	assert.Equal(t, map[string]interface{}{
+		"id": gqlVar("id"),
+		"filter": map[string]interface{}{
+			"roles": []interface{}{"ADMIN", "editor"},
+			"limit": int64(10),
+			"ratio": -150.0,
+		},
+	}, user.args)
+
+	assert.Equal(t, "plain\n  text", doc.fragments["UserFields"].selections[2].args["format"])
+
+	op, err = doc.operation("Rename")
+
+	require.NoError(t, err)
+	assert.Equal(t, "mutation", op.kind)
+	assert.Equal(t, "new\nname", op.selections[0].args["name"])
+
+	_, err = doc.operation("Missing")
+
+	assert.Error(t, err)
+
+	anonymous, err := parseGraphQL("{ a, b }")
+
+	require.NoError(t, err)
+	assert.Len(t, anonymous.operations[0].selections, 2)
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, src := range []string{"", "fragment F on T { a }", "{ a", "query { a(x: ) }", "{ a } }", `{ a(x: "open) }`, "{ a % }"} {
+		_, err := parseGraphQL(src)
+
+		assert.Error(t, err, src)
+	}
+
+	_, err := parseGraphQL("{\n  user(id: ) }")
+
+	var gerr *gqlError
+
+	require.ErrorAs(t, err, &gerr)
+	assert.ErrorContains(t, err, errGraphQLSyntax.Error())
+	assert.Equal(t, []gqlLocation{{Line: 2, Column: 12}}, gerr.Locations)
+}
+
+func TestCollectFields(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseGraphQL(userQuery)
+
+	require.NoError(t, err)
+
+	op, _ := doc.operation("GetUser")
+	selections := op.selections[0].selections
+
+	possible := map[string][]string{"User": {"Admin", "Editor"}}
+
+	keys := func(typename string, vars map[string]interface{}) []string {
+		var names []string
+
+		for _, field := range doc.collectFields(selections, typename, vars, possible) {
+			names = append(names, field.key())
+		}
+
+		return names
+	}
+
+	assert.Equal(t, []string{"id", "displayName", "bio", "permissions", "sections"}, keys("", nil))
+	assert.Equal(t, []string{"id", "displayName", "bio", "permissions"}, keys("Admin", nil))
+	assert.Equal(t, []string{"id", "displayName", "bio", "posts", "sections"},
+		keys("Editor", map[string]interface{}{"withPosts": true}))
+
+	possible = nil
+
+	assert.Equal(t, []string{"permissions"}, keys("Admin", nil))
+	assert.Equal(t, []string{"sections"}, keys("Editor", map[string]interface{}{"withPosts": false}))
+
+	merged, err := parseGraphQL("{ user { id } user { name } }")
+
+	require.NoError(t, err)
+
+	fields := merged.collectFields(merged.operations[0].selections, "Query", nil, nil)
+
+	require.Len(t, fields, 1)
+	assert.Len(t, fields[0].selections, 2)
+}
+
+func TestResolveValue(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]interface{}{"id": "42", "tags": []interface{}{"a"}}
+
+	assert.Equal(t,
+		map[string]interface{}{"id": "42", "nested": map[string]interface{}{"tags": []interface{}{"a"}, "missing": nil}},
+		resolveValue(map[string]interface{}{
+			"id":     gqlVar("id"),
+			"nested": map[string]interface{}{"tags": gqlVar("tags"), "missing": gqlVar("none")},
+		}, vars),
+	)
+
+	assert.True(t, included([]gqlDirective{{name: "skip", args: map[string]interface{}{"if": false}}}, nil))
+	assert.False(t, included([]gqlDirective{{name: "include", args: map[string]interface{}{"if": gqlVar("x")}}}, nil))
+}
+
+func TestGraphQLResult(t *testing.T) {
+	t.Parallel()
+
+	result := new(gqlResult)
+
+	result.set("zeta", 1)
+	result.set("alpha", []interface{}{"x"})
+
+	data, err := json.Marshal(map[string]interface{}{"data": result})
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"data":{"zeta":1,"alpha":["x"]}}`, string(data))
+}
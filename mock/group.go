@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// group contains the options shared by the routes defined in a route group.
+type group struct {
+	prefix     string
+	delay      *delay
+	headers    http.Header
+	middleware []sobek.Callable
+}
+
+// getGroup parses the route group options: delay (see getDelay), headers (response header defaults)
+// and middleware (function or array of functions run before the route handlers).
+func getGroup(prefix string, value sobek.Value) (*group, error) {
+	grp := &group{prefix: "/" + strings.Trim(prefix, "/")}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return grp, nil
+	}
+
+	if v := obj.Get("delay"); v != nil {
+		grp.delay = getDelay(v.Export())
+	}
+
+	if headers, ok := obj.Get("headers").(*sobek.Object); ok {
+		grp.headers = make(http.Header)
+
+		for _, key := range headers.Keys() {
+			grp.headers.Set(key, headers.Get(key).String())
+		}
+	}
+
+	switch mw := obj.Get("middleware").(type) {
+	case nil:
+	case *sobek.Object:
+		if fn, isFunc := sobek.AssertFunction(mw); isFunc {
+			grp.middleware = append(grp.middleware, fn)
+
+			break
+		}
+
+		for _, key := range mw.Keys() {
+			fn, isFunc := sobek.AssertFunction(mw.Get(key))
+			if !isFunc {
+				return nil, fmt.Errorf("%w: group middleware must be a function", errInvalidArg)
+			}
+
+			grp.middleware = append(grp.middleware, fn)
+		}
+	default:
+		if !sobek.IsUndefined(mw) && !sobek.IsNull(mw) {
+			return nil, fmt.Errorf("%w: group middleware must be a function", errInvalidArg)
+		}
+	}
+
+	return grp, nil
+}
+
+// apply applies the group options on the route defined in the group. The options of the route (and
+// of the nested groups) take precedence, the group middlewares run before the route handlers.
+func (grp *group) apply(r *route) {
+	if grp.prefix != "/" {
+		r.pattern = grp.prefix + "/" + strings.TrimPrefix(r.pattern, "/")
+	}
+
+	if r.prefix {
+		return
+	}
+
+	if r.delay == nil {
+		r.delay = grp.delay
+	}
+
+	if len(grp.headers) != 0 {
+		r.decorators = append(r.decorators, grp.decorate)
+	}
+
+	if len(grp.middleware) != 0 {
+		r.handlers = append(append([]sobek.Callable{}, grp.middleware...), r.handlers...)
+	}
+}
+
+// decorate sets the group's headers not set by the handlers.
+func (grp *group) decorate(header http.Header) {
+	setMissing(header, grp.headers)
+}
+
+// wrapGroup adds the group method to the application: the routes and middlewares defined by the
+// callback are prefixed with the path and share the group options.
+func (srv *server) wrapGroup() {
+	srv.mustSet("group", func(prefix string, opts sobek.Value, callback sobek.Value) sobek.Value {
+		if _, isFunc := sobek.AssertFunction(opts); isFunc {
+			opts, callback = nil, opts
+		}
+
+		fn, isFunc := sobek.AssertFunction(callback)
+		if !isFunc {
+			srv.mod.throwf("missing group callback", errInvalidArg)
+		}
+
+		if err := checkPath(prefix); err != nil {
+			srv.mod.throwf("invalid parameter constraint of group: %s", errInvalidArg, err)
+		}
+
+		grp, err := getGroup(prefix, opts)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		srv.router.with(grp.apply, func() {
+			if _, err := fn(sobek.Undefined(), srv.app); err != nil {
+				srv.mod.throw(err)
+			}
+		})
+
+		return srv.app
+	})
+}
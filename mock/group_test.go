@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupApply(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	outer := &group{prefix: "/api", delay: &delay{min: time.Second, max: time.Second}, headers: http.Header{}}
+	inner := &group{prefix: "/v1", delay: &delay{min: time.Millisecond, max: time.Millisecond}}
+
+	outer.headers.Set("Cache-Control", "no-store")
+
+	var users, health, mw *route
+
+	rt.with(outer.apply, func() {
+		health = rt.add(http.MethodGet, "/health", nil)
+
+		rt.with(inner.apply, func() {
+			users = rt.add(http.MethodGet, "/users/:id", nil)
+			mw = rt.use("/", nil)
+		})
+	})
+
+	assert.Equal(t, "/api/health", health.pattern)
+	assert.Equal(t, "/api/v1/users/:id", users.pattern)
+	assert.Equal(t, "/api/v1/", mw.pattern)
+	assert.Equal(t, outer.delay, health.delay)
+	assert.Equal(t, inner.delay, users.delay)
+
+	assert.Equal(t, []*route{users}, rt.candidates(http.MethodGet, "/api/v1/users/42", selector{}))
+
+	header := http.Header{}
+
+	for _, decorator := range users.decorators {
+		decorator(header)
+	}
+
+	assert.Equal(t, "no-store", header.Get("Cache-Control"))
+
+	header = http.Header{"Cache-Control": []string{"max-age=60"}}
+
+	outer.decorate(header)
+
+	assert.Equal(t, "max-age=60", header.Get("Cache-Control"))
+}
@@ -0,0 +1,351 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/grafana/sobek"
+)
+
+// Native gRPC requests (HTTP/2, so the mock server should use TLS) are routed like gRPC-Web requests.
+// With the proto option the request messages are decoded to JSON and the JSON responses of the handlers
+// are encoded by the schema of the method. Client streaming requests are routed with the array of the
+// messages, server streaming methods respond with an array of messages.
+
+const grpcCompressed = 1
+
+// readFrames returns the payloads of the length-prefixed messages, compressed messages are decompressed.
+func readFrames(data []byte) ([][]byte, error) {
+	var frames [][]byte
+
+	for len(data) != 0 {
+		if len(data) < grpcFrameHeader {
+			return nil, errInvalidFrame
+		}
+
+		size := binary.BigEndian.Uint32(data[1:grpcFrameHeader])
+		if uint32(len(data)-grpcFrameHeader) < size {
+			return nil, errInvalidFrame
+		}
+
+		payload := data[grpcFrameHeader : grpcFrameHeader+int(size)]
+
+		if data[0]&grpcCompressed != 0 {
+			reader, err := gzip.NewReader(bytes.NewReader(payload))
+			if err != nil {
+				return nil, err
+			}
+
+			if payload, err = io.ReadAll(reader); err != nil {
+				return nil, err
+			}
+		}
+
+		frames = append(frames, payload)
+		data = data[grpcFrameHeader+int(size):]
+	}
+
+	return frames, nil
+}
+
+// grpcRequest returns the message of the request routed to the handlers and its content type.
+func grpcRequest(method *protoMethod, contentType string, frames [][]byte) ([]byte, string, error) {
+	if method == nil || messageType(contentType) == "application/json" {
+		if len(frames) == 0 {
+			return nil, messageType(contentType), nil
+		}
+
+		return frames[0], messageType(contentType), nil
+	}
+
+	messages := make([]interface{}, 0, len(frames))
+
+	for _, frame := range frames {
+		message, err := method.in.decode(frame)
+		if err != nil {
+			return nil, "", err
+		}
+
+		messages = append(messages, message)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	switch {
+	case method.clientStreaming:
+		data, err = json.Marshal(messages)
+	case len(messages) == 0:
+		data = []byte("{}")
+	default:
+		data, err = json.Marshal(messages[0])
+	}
+
+	return data, "application/json", err
+}
+
+// grpcResponse returns the response messages. JSON responses of schema methods are encoded, the response
+// of server streaming methods may be an array of messages.
+func grpcResponse(method *protoMethod, res *response) ([][]byte, error) {
+	if res.body.Len() == 0 {
+		return nil, nil
+	}
+
+	if method == nil || messageType(res.header.Get("Content-Type")) != "application/json" {
+		return [][]byte{res.body.Bytes()}, nil
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(res.body.Bytes(), &value); err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{value}
+
+	if list, isList := value.([]interface{}); isList && method.serverStreaming {
+		values = list
+	}
+
+	messages := make([][]byte, 0, len(values))
+
+	for _, item := range values {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s expected", errProtoMessage, method.output)
+		}
+
+		message, err := method.out.encode(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// grpcNative serves the native gRPC request. The gRPC status is sent in the trailers, or in the header
+// of trailers-only responses.
+func (srv *server) grpcNative(w http.ResponseWriter, r *http.Request) *route { // nolint:varnamelen
+	contentType := r.Header.Get("Content-Type")
+	method := srv.proto.method(r.URL.Path)
+
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		var frames [][]byte
+
+		if frames, err = readFrames(data); err == nil {
+			data, contentType, err = grpcRequest(method, contentType, frames)
+		}
+	}
+
+	if err != nil {
+		writeGRPCStatus(w, nil, 13, err.Error()) // INTERNAL
+
+		return nil
+	}
+
+	out := r.Clone(r.Context())
+
+	out.Body = io.NopCloser(bytes.NewReader(data))
+	out.ContentLength = int64(len(data))
+	out.Header.Set("Content-Type", contentType)
+	out.Header.Del("Content-Length")
+
+	res := acquireResponse()
+	defer res.release()
+
+	var matched *route
+
+	if !srv.builtinRPC(res, out) {
+		matched = srv.respond(res, out)
+	}
+
+	code, message := grpcStatus(res)
+
+	var messages [][]byte
+
+	if code == 0 {
+		if messages, err = grpcResponse(method, res); err != nil {
+			code, message, messages = 13, err.Error(), nil
+		}
+	}
+
+	header := w.Header()
+
+	for name, values := range res.header {
+		if name != "Content-Type" && name != "Content-Length" {
+			header[name] = values
+		}
+	}
+
+	writeGRPCStatus(w, messages, code, message)
+
+	return matched
+}
+
+func writeGRPCStatus(w http.ResponseWriter, messages [][]byte, code int, message string) {
+	header := w.Header()
+
+	header.Set("Content-Type", "application/grpc")
+	header.Del(headerGRPCStatus)
+	header.Del(headerGRPCMessage)
+
+	status := func(prefix string) {
+		header.Set(prefix+headerGRPCStatus, strconv.Itoa(code))
+
+		if len(message) != 0 {
+			header.Set(prefix+headerGRPCMessage, url.PathEscape(message))
+		}
+	}
+
+	if len(messages) == 0 {
+		status("")
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	var body bytes.Buffer
+
+	for _, msg := range messages {
+		writeFrame(&body, 0, msg)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes()) // nolint:errcheck,gosec
+
+	status(http.TrailerPrefix)
+}
+
+// rewriteGRPC rewrites the host:port address of a mocked target to the address of its mock server.
+// The target is looked up as https (and http) URL, it is reported whether the mock server uses TLS.
+func (mod *Module) rewriteGRPC(addr string) (string, bool, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, false, false
+	}
+
+	var locs []string
+
+	switch port {
+	case "443":
+		locs = append(locs, "https://"+host)
+	case "80":
+		locs = append(locs, "http://"+host)
+	}
+
+	locs = append(locs, "https://"+addr, "http://"+addr)
+
+	for _, loc := range locs {
+		rewritten, ok := mod.mockedURL(loc)
+		if !ok {
+			continue
+		}
+
+		target, err := url.Parse(rewritten)
+		if err != nil {
+			continue
+		}
+
+		secure := target.Scheme == "https"
+
+		if len(target.Port()) != 0 {
+			return target.Host, secure, true
+		}
+
+		if secure {
+			return net.JoinHostPort(target.Hostname(), "443"), true, true
+		}
+
+		return net.JoinHostPort(target.Hostname(), "80"), false, true
+	}
+
+	return addr, false, false
+}
+
+// grpc wraps the Client class of the k6/net/grpc module, so the addresses of the mocked targets are
+// rewritten to the mock servers when the client connects. The plaintext parameter follows the mock server.
+func (mod *Module) grpc(module *sobek.Object) *sobek.Object {
+	runtime := mod.runtime()
+
+	ctor := module.Get("Client")
+	if ctor == nil || sobek.IsUndefined(ctor) {
+		return module
+	}
+
+	wrapper := func(call sobek.ConstructorCall) *sobek.Object {
+		client, err := runtime.New(ctor, call.Arguments...)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		connect, ok := sobek.AssertFunction(client.Get("connect"))
+		if !ok {
+			return client
+		}
+
+		wrapped := runtime.NewObject()
+
+		if err := wrapped.SetPrototype(client); err != nil {
+			mod.throw(err)
+		}
+
+		rewrite := func(call sobek.FunctionCall) sobek.Value {
+			args := call.Arguments
+
+			if len(args) != 0 {
+				if addr, secure, ok := mod.rewriteGRPC(args[0].String()); ok {
+					params := runtime.NewObject()
+
+					if len(args) > 1 {
+						if obj, isObj := args[1].(*sobek.Object); isObj {
+							params = obj
+						}
+					}
+
+					if err := params.Set("plaintext", !secure); err != nil {
+						mod.throw(err)
+					}
+
+					args = []sobek.Value{runtime.ToValue(addr), params}
+				}
+			}
+
+			result, err := connect(client, args...)
+			if err != nil {
+				mod.throw(err)
+			}
+
+			return result
+		}
+
+		if err := wrapped.Set("connect", rewrite); err != nil {
+			mod.throw(err)
+		}
+
+		return wrapped
+	}
+
+	if err := module.Set("Client", wrapper); err != nil {
+		mod.throw(err)
+	}
+
+	return module
+}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFrames(t *testing.T) {
+	t.Parallel()
+
+	var compressed bytes.Buffer
+
+	zw := gzip.NewWriter(&compressed)
+
+	zw.Write([]byte("second")) // nolint:errcheck,gosec
+	zw.Close()                 // nolint:errcheck,gosec
+
+	var buff bytes.Buffer
+
+	writeFrame(&buff, 0, []byte("first"))
+	writeFrame(&buff, grpcCompressed, compressed.Bytes())
+
+	frames, err := readFrames(buff.Bytes())
+
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("first"), []byte("second")}, frames)
+
+	_, err = readFrames(buff.Bytes()[:7])
+
+	assert.ErrorIs(t, err, errInvalidFrame)
+}
+
+func TestGRPCMessages(t *testing.T) {
+	t.Parallel()
+
+	schema := newProtoSchema()
+
+	require.NoError(t, schema.parse(ordersProto))
+	require.NoError(t, schema.resolve())
+
+	get := schema.method("/orders.v1.OrderService/GetOrder")
+	watch := schema.method("/orders.v1.OrderService/WatchOrders")
+
+	request := []byte{0x0a, 0x02, '4', '2'}
+
+	data, contentType, err := grpcRequest(get, "application/grpc", [][]byte{request})
+
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	assert.JSONEq(t, `{"orderId":"42"}`, string(data))
+
+	data, _, err = grpcRequest(watch, "application/grpc", [][]byte{request, request})
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"orderId":"42"},{"orderId":"42"}]`, string(data))
+
+	data, contentType, err = grpcRequest(nil, "application/grpc", [][]byte{request})
+
+	require.NoError(t, err)
+	assert.Equal(t, "application/proto", contentType)
+	assert.Equal(t, request, data)
+
+	res := acquireResponse()
+	defer res.release()
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Write([]byte(`[{"orderId":"1"},{"orderId":"2"}]`)) // nolint:errcheck,gosec
+
+	messages, err := grpcResponse(watch, res)
+
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{{0x0a, 0x01, '1'}, {0x0a, 0x01, '2'}}, messages)
+
+	_, err = grpcResponse(get, res)
+
+	assert.ErrorIs(t, err, errProtoMessage)
+}
+
+func TestWriteGRPCStatus(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+
+	writeGRPCStatus(w, nil, 5, "order not found")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/grpc", w.Header().Get("Content-Type"))
+	assert.Equal(t, "5", w.Header().Get(headerGRPCStatus))
+	assert.Equal(t, "order%20not%20found", w.Header().Get(headerGRPCMessage))
+
+	w = httptest.NewRecorder()
+
+	writeGRPCStatus(w, [][]byte{{0x0a, 0x01, '1'}}, 0, "")
+
+	result := w.Result()
+	defer result.Body.Close() // nolint:errcheck
+
+	assert.Equal(t, []byte{0, 0, 0, 0, 3, 0x0a, 0x01, '1'}, w.Body.Bytes())
+	assert.Empty(t, result.Header.Get(headerGRPCStatus))
+	assert.Equal(t, "0", result.Trailer.Get(headerGRPCStatus))
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+var errInvalidHandle = errors.New("invalid mock server handle")
+
+// handle is the serializable reference of a mock server. It can be passed from setup() to the VUs
+// and to teardown() as part of the setup data.
+type handle struct {
+	ID     string
+	Target string
+	URL    string
+	Name   string
+	Token  string
+}
+
+// object returns the plain (JSON serializable) object of the handle.
+func (h *handle) object() map[string]interface{} {
+	return map[string]interface{}{"id": h.ID, "target": h.Target, "url": h.URL, "name": h.Name, "token": h.Token}
+}
+
+// registry keeps the mock servers of the process, so they can be addressed by their handle from any VU.
+type registry struct {
+	mu      sync.Mutex
+	servers map[string]*server
+
+	captures map[string]*capture // open capture files by path
+}
+
+func newRegistry() *registry {
+	return &registry{
+		servers:  make(map[string]*server),
+		captures: make(map[string]*capture),
+	}
+}
+
+func (reg *registry) add(srv *server) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.servers[srv.id] = srv
+}
+
+func (reg *registry) remove(srv *server) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	delete(reg.servers, srv.id)
+}
+
+// take removes and returns the server of the handle, the handle's token must match the server's token.
+func (reg *registry) take(h *handle) (*server, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	srv, found := reg.servers[h.ID]
+	if !found || srv.token != h.Token {
+		return nil, errInvalidHandle
+	}
+
+	delete(reg.servers, h.ID)
+
+	return srv, nil
+}
+
+// randomID returns a random hexadecimal identifier.
+func randomID() string {
+	data := make([]byte, 16)
+
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(data)
+}
+
+// getHandle converts the handle value (e.g. setup data) to handle.
+func (mod *Module) getHandle(value sobek.Value) *handle {
+	h := new(handle)
+
+	if obj, isObj := value.(*sobek.Object); isObj {
+		get := func(name string) string {
+			if v := obj.Get(name); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+				return v.String()
+			}
+
+			return ""
+		}
+
+		h.ID, h.Target, h.URL, h.Name, h.Token = get("id"), get("target"), get("url"), get("name"), get("token")
+	}
+
+	if len(h.ID) == 0 || len(h.Target) == 0 || len(h.URL) == 0 {
+		mod.throwf("missing id, target or url", errInvalidHandle)
+	}
+
+	return h
+}
+
+// handle returns the serializable handle of the mock server identified by its target URL or its application object.
+func (mod *Module) handle(value sobek.Value) map[string]interface{} {
+	srv := mod.server(value)
+
+	h := &handle{
+		ID:     srv.id,
+		Target: srv.target,
+		URL:    mod.lookup[srv.target],
+		Name:   srv.opts.name,
+		Token:  srv.token,
+	}
+
+	return h.object()
+}
+
+// attach makes the mock server of the handle (started by another VU, e.g. in setup) the mock of its target in this VU.
+func (mod *Module) attach(value sobek.Value) {
+	h := mod.getHandle(value)
+
+	mod.lookup[h.Target] = h.URL
+
+	if len(h.Name) != 0 {
+		mod.names[h.Name] = h.Target
+	}
+}
+
+// stop shuts down the mock server of the handle (e.g. in teardown).
+func (mod *Module) stop(value sobek.Value) {
+	h := mod.getHandle(value)
+
+	srv, err := mod.registry.take(h)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	if mod.lookup[h.Target] == h.URL {
+		delete(mod.lookup, h.Target)
+	}
+
+	if local, found := mod.servers[h.Target]; found && local == srv {
+		delete(mod.servers, h.Target)
+	}
+
+	if err := srv.shutdown(); err != nil {
+		mod.throw(err)
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	reg := newRegistry()
+	srv := &server{id: randomID(), token: randomID()}
+
+	reg.add(srv)
+
+	_, err := reg.take(&handle{ID: srv.id, Token: "invalid"})
+
+	require.ErrorIs(t, err, errInvalidHandle)
+
+	found, err := reg.take(&handle{ID: srv.id, Token: srv.token})
+
+	require.NoError(t, err)
+	assert.Same(t, srv, found)
+
+	_, err = reg.take(&handle{ID: srv.id, Token: srv.token})
+
+	require.ErrorIs(t, err, errInvalidHandle)
+}
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	setup := newHelper(t)
+
+	_, err := setup.vu.Runtime().RunString(`
+mock("https://example.com", app => {}, { sync: true, name: "example" })
+
+var data = JSON.parse(JSON.stringify(mock.handle("https://example.com")))
+`)
+
+	require.NoError(t, err)
+
+	data := setup.vu.Runtime().Get("data")
+	h := setup.module.getHandle(data)
+
+	assert.Equal(t, "https://example.com", h.Target)
+	assert.Equal(t, setup.module.lookup["https://example.com"], h.URL)
+	assert.Equal(t, "example", h.Name)
+
+	vu := newHelper(t)
+
+	vu.module.registry = setup.module.registry
+	vu.module.attach(data)
+
+	assert.Equal(t, h.URL, vu.module.lookup["https://example.com"])
+	assert.Equal(t, h.URL, vu.module.service("example"))
+
+	vu.module.stop(data)
+
+	assert.Empty(t, vu.module.lookup)
+	assert.Panics(t, func() { vu.module.stop(data) })
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/
+
+type harFile struct {
+	Log *harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator *harCreator `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string       `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         *harRequest  `json:"request"`
+	Response        *harResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         *harTimings  `json:"timings"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	QueryString []*harNameValue `json:"queryString"`
+	PostData    *harPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	Content     *harContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+const harHTTPVersion = "HTTP/1.1"
+
+func harHeaders(header http.Header) []*harNameValue {
+	found := make([]*harNameValue, 0, len(header))
+
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			found = append(found, &harNameValue{Name: name, Value: value})
+		}
+	}
+
+	return found
+}
+
+func harCookies(cookies []*http.Cookie) []*harNameValue {
+	found := make([]*harNameValue, 0, len(cookies))
+
+	for _, cookie := range cookies {
+		found = append(found, &harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	return found
+}
+
+// mockURL returns the original (mocked) URL of the recorded request.
+func mockURL(target string, record *entry) string {
+	loc := strings.TrimSuffix(target, "/") + record.path
+
+	if query := record.query.Encode(); len(query) != 0 {
+		loc += "?" + query
+	}
+
+	return loc
+}
+
+func newHAREntry(target string, record *entry) *harEntry {
+	millis := float64(record.duration) / float64(time.Millisecond)
+	reqBody, resBody := record.requestBody(), record.responseBody()
+
+	req := &harRequest{
+		Method:      record.method,
+		URL:         mockURL(target, record),
+		HTTPVersion: harHTTPVersion,
+		Cookies:     harCookies((&http.Request{Header: record.header}).Cookies()), // nolint:exhaustruct
+		Headers:     harHeaders(record.header),
+		QueryString: make([]*harNameValue, 0, len(record.query)),
+		HeadersSize: -1,
+		BodySize:    len(reqBody),
+	}
+
+	for _, name := range sortedKeys(record.query) {
+		for _, value := range record.query[name] {
+			req.QueryString = append(req.QueryString, &harNameValue{Name: name, Value: value})
+		}
+	}
+
+	if len(reqBody) != 0 {
+		req.PostData = &harPostData{MimeType: record.header.Get("Content-Type"), Text: string(reqBody)}
+	}
+
+	res := &harResponse{
+		Status:      record.status,
+		StatusText:  http.StatusText(record.status),
+		HTTPVersion: harHTTPVersion,
+		Cookies:     harCookies((&http.Response{Header: record.resHeader}).Cookies()), // nolint:exhaustruct
+		Headers:     harHeaders(record.resHeader),
+		Content:     &harContent{Size: len(resBody), MimeType: record.resHeader.Get("Content-Type")},
+		RedirectURL: record.resHeader.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(resBody),
+	}
+
+	if utf8.Valid(resBody) {
+		res.Content.Text = string(resBody)
+	} else {
+		res.Content.Text = base64.StdEncoding.EncodeToString(resBody)
+		res.Content.Encoding = "base64"
+	}
+
+	return &harEntry{
+		StartedDateTime: record.time.Format(time.RFC3339Nano),
+		Time:            millis,
+		Request:         req,
+		Response:        res,
+		Timings:         &harTimings{Wait: millis},
+	}
+}
+
+func newHAR(target string, records []*entry) *harFile {
+	log := &harLog{
+		Version: "1.2",
+		Creator: &harCreator{Name: "xk6-mock", Version: "1.0"},
+		Entries: make([]*harEntry, 0, len(records)),
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].time.Before(records[j].time) })
+
+	for _, record := range records {
+		log.Entries = append(log.Entries, newHAREntry(target, record))
+	}
+
+	return &harFile{Log: log}
+}
+
+// exportHAR writes all recorded exchanges of the server to the given file in HAR format.
+func (srv *server) exportHAR(filename string) error {
+	data, err := json.MarshalIndent(newHAR(srv.target, srv.journal.snapshot()), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0o600)
+}
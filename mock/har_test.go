@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHAR(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	records := []*entry{
+		{
+			time:      now.Add(time.Second),
+			method:    http.MethodGet,
+			path:      "/binary",
+			query:     url.Values{},
+			header:    http.Header{},
+			status:    http.StatusOK,
+			resHeader: http.Header{"Content-Type": {"application/octet-stream"}},
+			resBody:   []byte{0xff, 0xfe},
+		},
+		{
+			time:      now,
+			duration:  2 * time.Millisecond,
+			method:    http.MethodPost,
+			path:      "/orders",
+			query:     url.Values{"dry": {"true"}},
+			header:    http.Header{"Content-Type": {"application/json"}, "Cookie": {"session=abc"}},
+			body:      []byte(`{"item":"book"}`),
+			status:    http.StatusCreated,
+			resHeader: http.Header{"Content-Type": {"application/json"}},
+			resBody:   []byte(`{"id":1}`),
+		},
+	}
+
+	har := newHAR("https://example.com/", records)
+
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Len(t, har.Log.Entries, 2)
+
+	first := har.Log.Entries[0]
+
+	assert.Equal(t, "https://example.com/orders?dry=true", first.Request.URL)
+	assert.Equal(t, float64(2), first.Time)
+	assert.Equal(t, `{"item":"book"}`, first.Request.PostData.Text)
+	assert.Equal(t, "session", first.Request.Cookies[0].Name)
+	assert.Equal(t, "Created", first.Response.StatusText)
+	assert.Equal(t, `{"id":1}`, first.Response.Content.Text)
+
+	second := har.Log.Entries[1]
+
+	assert.Nil(t, second.Request.PostData)
+	assert.Equal(t, "base64", second.Response.Content.Encoding)
+	assert.Equal(t, "//4=", second.Response.Content.Text)
+}
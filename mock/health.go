@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	defaultLivenessPath  = "/healthz"
+	defaultReadinessPath = "/readyz"
+
+	healthHealthy  = "healthy"
+	healthDegraded = "degraded"
+	healthFailing  = "failing"
+)
+
+// healthStatus maps the health states to the status of the health check response format
+// (draft-inadarei-api-health-check) and the HTTP status code.
+var healthStatus = map[string]struct {
+	status string
+	code   int
+}{
+	healthHealthy:  {"pass", http.StatusOK},
+	healthDegraded: {"warn", http.StatusOK},
+	healthFailing:  {"fail", http.StatusServiceUnavailable},
+}
+
+type healthState struct {
+	state   string
+	details map[string]interface{}
+}
+
+// healthKit serves the liveness and readiness endpoints of a mock server, their state is set by the script.
+// The readiness endpoint is failing during warm-up as well.
+type healthKit struct {
+	liveness  *route
+	readiness *route
+
+	mu    sync.Mutex
+	live  healthState
+	ready healthState
+}
+
+// getHealth parses the health option: true (default paths) or an object with liveness and readiness paths.
+func getHealth(value sobek.Value) *healthKit {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	live, ready := defaultLivenessPath, defaultReadinessPath
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if !value.ToBoolean() {
+			return nil
+		}
+	} else {
+		if v := obj.Get("liveness"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			live = v.String()
+		}
+
+		if v := obj.Get("readiness"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			ready = v.String()
+		}
+	}
+
+	return newHealthKit(live, ready)
+}
+
+func newHealthKit(live, ready string) *healthKit {
+	return &healthKit{
+		liveness:  &route{method: http.MethodGet, pattern: live, segments: compilePath(live)},
+		readiness: &route{method: http.MethodGet, pattern: ready, segments: compilePath(ready)},
+		live:      healthState{state: healthHealthy},
+		ready:     healthState{state: healthHealthy},
+	}
+}
+
+// set changes the state of the liveness (or readiness) endpoint.
+func (h *healthKit) set(readiness bool, state string, details map[string]interface{}) error {
+	if _, found := healthStatus[state]; !found {
+		return fmt.Errorf("%w: unknown health state %s", errInvalidArg, state)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if readiness {
+		h.ready = healthState{state: state, details: details}
+	} else {
+		h.live = healthState{state: state, details: details}
+	}
+
+	return nil
+}
+
+// serve serves the health endpoints, the route of the endpoint is returned if the request was served.
+func (h *healthKit) serve(w http.ResponseWriter, r *http.Request, warmingUp bool) *route {
+	var (
+		matched *route
+		current healthState
+	)
+
+	h.mu.Lock()
+
+	switch {
+	case !h.liveness.accepts(r.Method):
+	case isMatch(h.liveness, r.URL.Path):
+		matched, current = h.liveness, h.live
+	case isMatch(h.readiness, r.URL.Path):
+		matched, current = h.readiness, h.ready
+
+		if warmingUp {
+			current = healthState{state: healthFailing, details: map[string]interface{}{"warmup": "warming up"}}
+		}
+	}
+
+	h.mu.Unlock()
+
+	if matched == nil {
+		return nil
+	}
+
+	status := healthStatus[current.state]
+	body := map[string]interface{}{"status": status.status}
+
+	if len(current.details) != 0 {
+		body["details"] = current.details
+	}
+
+	data, _ := json.Marshal(body)
+
+	w.Header().Set("Content-Type", "application/health+json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status.code)
+	w.Write(data) // nolint:errcheck,gosec
+
+	return matched
+}
+
+func isMatch(r *route, path string) bool {
+	_, ok := r.match(path)
+
+	return ok
+}
+
+func (h *healthKit) export() map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := func(s healthState) map[string]interface{} {
+		return map[string]interface{}{"state": s.state, "details": s.details}
+	}
+
+	return map[string]interface{}{"liveness": state(h.live), "readiness": state(h.ready)}
+}
+
+// healthObject returns the object controlling the health endpoints.
+func (srv *server) healthObject() *sobek.Object {
+	obj := srv.mod.runtime().NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	setter := func(readiness bool) func(string, map[string]interface{}) {
+		return func(state string, details map[string]interface{}) {
+			if err := srv.health.set(readiness, state, details); err != nil {
+				srv.mod.throw(err)
+			}
+		}
+	}
+
+	mustSet("liveness", setter(false))
+	mustSet("readiness", setter(true))
+	mustSet("status", srv.health.export)
+
+	return obj
+}
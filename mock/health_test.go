@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthKit(t *testing.T) {
+	t.Parallel()
+
+	kit := newHealthKit(defaultLivenessPath, defaultReadinessPath)
+
+	serve := func(method, path string, warmingUp bool) (*route, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+
+		return kit.serve(w, httptest.NewRequest(method, path, nil), warmingUp), w
+	}
+
+	matched, _ := serve(http.MethodGet, "/other", false)
+
+	assert.Nil(t, matched)
+
+	matched, _ = serve(http.MethodPost, "/healthz", false)
+
+	assert.Nil(t, matched)
+
+	matched, w := serve(http.MethodGet, "/healthz", false)
+
+	require.NotNil(t, matched)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/health+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status":"pass"}`, w.Body.String())
+
+	_, w = serve(http.MethodGet, "/readyz", true)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"fail","details":{"warmup":"warming up"}}`, w.Body.String())
+
+	require.NoError(t, kit.set(false, healthDegraded, map[string]interface{}{"db": "slow"}))
+
+	_, w = serve(http.MethodGet, "/healthz", false)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"warn","details":{"db":"slow"}}`, w.Body.String())
+
+	require.NoError(t, kit.set(true, healthFailing, nil))
+
+	_, w = serve(http.MethodHead, "/readyz", false)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	assert.ErrorIs(t, kit.set(true, "sick", nil), errInvalidArg)
+
+	status := kit.export()
+
+	assert.Equal(t, healthDegraded, status["liveness"].(map[string]interface{})["state"]) // nolint:forcetypeassert
+	assert.Equal(t, healthFailing, status["readiness"].(map[string]interface{})["state"]) // nolint:forcetypeassert
+}
@@ -19,7 +19,7 @@ type testHelper struct {
 	module  *Module
 }
 
-func newHelper(t *testing.T) *testHelper {
+func newHelper(t testing.TB) *testHelper {
 	t.Helper()
 
 	runtime := modulestest.NewRuntime(t)
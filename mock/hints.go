@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/grafana/sobek"
+)
+
+const headerEarlyHints = "X-Mock-Early-Hints"
+
+// informational is a 1xx response sent before the final response.
+type informational struct {
+	status int
+	header http.Header
+}
+
+// getInformational parses the earlyHints and informational route options. The earlyHints option is
+// the Link header value(s) of a 103 Early Hints response, the informational option is an array of
+// objects with status (100, 102 or 103) and headers properties.
+func getInformational(obj *sobek.Object) []*informational {
+	var responses []*informational
+
+	if links := obj.Get("earlyHints"); links != nil && !sobek.IsUndefined(links) && !sobek.IsNull(links) {
+		header := make(http.Header)
+
+		for _, link := range stringList(links.Export()) {
+			header.Add("Link", link)
+		}
+
+		responses = append(responses, &informational{status: http.StatusEarlyHints, header: header})
+	}
+
+	list, isList := obj.Get("informational").Export().([]interface{})
+	if !isList {
+		return responses
+	}
+
+	for _, item := range list {
+		spec, isMap := item.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+
+		res := &informational{status: http.StatusEarlyHints, header: make(http.Header)}
+
+		if status, ok := spec["status"].(int64); ok {
+			res.status = int(status)
+		}
+
+		if headers, ok := spec["headers"].(map[string]interface{}); ok {
+			for name, value := range headers {
+				for _, v := range stringList(value) {
+					res.header.Add(name, v)
+				}
+			}
+		}
+
+		if validInformational(res.status) {
+			responses = append(responses, res)
+		}
+	}
+
+	return responses
+}
+
+// stringList returns the exported JavaScript string or array of strings as string slice.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+
+		return list
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// validInformational reports whether the status can be sent before the final response,
+// 101 Switching Protocols is a final response of the exchange.
+func validInformational(status int) bool {
+	return status == http.StatusContinue || status == http.StatusProcessing || status == http.StatusEarlyHints
+}
+
+// informationalOf returns the informational responses of the response: the X-Mock-Early-Hints control
+// header (Link header value of a 103 response) and the options of the route selected by the dispatcher.
+func (srv *server) informationalOf(control http.Header) []*informational {
+	var responses []*informational
+
+	if links := control.Values(headerEarlyHints); len(links) != 0 {
+		header := make(http.Header)
+		header["Link"] = links
+
+		responses = append(responses, &informational{status: http.StatusEarlyHints, header: header})
+	}
+
+	id, err := strconv.Atoi(control.Get(headerRoute))
+	if err != nil {
+		return responses
+	}
+
+	if r := srv.router.get(id); r != nil {
+		responses = append(responses, r.informational...)
+	}
+
+	return responses
+}
+
+// writeInformational sends the informational responses, the header of the final response is preserved.
+func writeInformational(w http.ResponseWriter, responses []*informational) {
+	header := w.Header()
+	final := header.Clone()
+
+	for _, res := range responses {
+		for name := range header {
+			delete(header, name)
+		}
+
+		for name, values := range res.header {
+			header[name] = values
+		}
+
+		w.WriteHeader(res.status)
+	}
+
+	for name := range header {
+		delete(header, name)
+	}
+
+	for name, values := range final {
+		header[name] = values
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInformational(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newRecorder(w, nil)
+		rec.informational = func(control http.Header) []*informational {
+			return []*informational{{
+				status: http.StatusEarlyHints,
+				header: http.Header{"Link": control.Values(headerEarlyHints)},
+			}}
+		}
+
+		rec.Header().Set(headerEarlyHints, "</style.css>; rel=preload; as=style")
+		rec.Header().Set("Content-Type", "text/html")
+		rec.WriteHeader(http.StatusOK)
+		rec.Write([]byte("<html></html>")) // nolint:errcheck,gosec
+	}))
+
+	defer srv.Close()
+
+	var hints []textproto.MIMEHeader
+
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header)
+			}
+
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL, nil)
+
+	require.NoError(t, err)
+
+	res, err := http.DefaultClient.Do(req)
+
+	require.NoError(t, err)
+
+	defer res.Body.Close() // nolint:errcheck
+
+	require.Len(t, hints, 1)
+	assert.Equal(t, "</style.css>; rel=preload; as=style", hints[0].Get("Link"))
+	assert.Empty(t, hints[0].Get("Content-Type"))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/html", res.Header.Get("Content-Type"))
+	assert.Empty(t, res.Header.Get("Link"))
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	hookRequest  = "request"
+	hookResponse = "response"
+	hookError    = "error"
+)
+
+// hooks contains the event hook functions of a mock server.
+type hooks struct {
+	mu        sync.RWMutex
+	callbacks map[string][]sobek.Callable
+}
+
+func newHooks() *hooks {
+	return &hooks{callbacks: make(map[string][]sobek.Callable)}
+}
+
+func (h *hooks) add(event string, fn sobek.Callable) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.callbacks[event] = append(h.callbacks[event], fn)
+}
+
+func (h *hooks) get(event string) []sobek.Callable {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.callbacks[event]
+}
+
+func flatHeader(header http.Header) map[string]interface{} {
+	flat := make(map[string]interface{}, len(header))
+
+	for name, values := range header {
+		flat[name] = strings.Join(values, ", ")
+	}
+
+	return flat
+}
+
+func requestInfo(record *entry) map[string]interface{} {
+	query := make(map[string]interface{}, len(record.query))
+
+	for name := range record.query {
+		query[name] = record.query.Get(name)
+	}
+
+	info := map[string]interface{}{
+		"method":  record.method,
+		"path":    record.path,
+		"query":   query,
+		"headers": flatHeader(record.header),
+		"body":    string(record.requestBody()),
+	}
+
+	if len(record.correlation) != 0 {
+		info["requestId"] = record.correlation
+	}
+
+	return info
+}
+
+func responseInfo(record *entry) map[string]interface{} {
+	return map[string]interface{}{
+		"request":  requestInfo(record),
+		"status":   record.status,
+		"headers":  flatHeader(record.resHeader),
+		"body":     string(record.responseBody()),
+		"route":    record.route,
+		"duration": float64(record.duration) / float64(time.Millisecond),
+	}
+}
+
+// emit calls the hooks of the event with the info built by the given function. The hooks always run
+// on the VU's event loop, even in synchronous mode: the response hooks are emitted after the response
+// was written, when the VU's script is no longer blocked by the request, so calling them on the HTTP
+// goroutine would race with the script on the VU's runtime. The request is not blocked by them.
+func (srv *server) emit(event string, info func() map[string]interface{}) {
+	callbacks := srv.hooks.get(event)
+	if len(callbacks) == 0 {
+		return
+	}
+
+	call := func() error {
+		value := srv.mod.runtime().ToValue(info())
+
+		for _, fn := range callbacks {
+			if _, err := fn(sobek.Undefined(), value); err != nil {
+				srv.mod.logger.WithError(err).WithField("target", srv.target).WithField("event", event).
+					Warn("mock hook failed")
+			}
+		}
+
+		return nil
+	}
+
+	newRunner(srv.mod.vu)(call)
+}
+
+// wrapHooks adds the onRequest, onResponse and onError methods to the application.
+func (srv *server) wrapHooks() {
+	for method, event := range map[string]string{
+		"onRequest":  hookRequest,
+		"onResponse": hookResponse,
+		"onError":    hookError,
+	} {
+		event := event
+
+		srv.mustSet(method, func(fn sobek.Callable) *sobek.Object {
+			srv.hooks.add(event, fn)
+
+			return srv.app
+		})
+	}
+}
+
+// proxyError reports the failure of forwarding the request to the application.
+func (srv *server) proxyError(w http.ResponseWriter, r *http.Request, err error) { // nolint:varnamelen
+	srv.mod.logger.WithError(err).WithField("target", srv.target).Error("mock application unreachable")
+
+	method, path := r.Header.Get(headerMethod), r.Header.Get(headerPath)
+	if len(method) == 0 {
+		method, path = r.Method, r.URL.Path
+	}
+
+	srv.emit(hookError, func() map[string]interface{} {
+		return map[string]interface{}{
+			"request": map[string]interface{}{"method": method, "path": path},
+			"error":   err.Error(),
+		}
+	})
+
+	w.WriteHeader(http.StatusBadGateway)
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookInfo(t *testing.T) {
+	t.Parallel()
+
+	record := &entry{
+		method:    http.MethodPost,
+		path:      "/orders",
+		query:     url.Values{"dry": []string{"true"}},
+		header:    http.Header{"Accept": []string{"text/plain", "application/json"}},
+		body:      []byte(`{"id":1}`),
+		status:    http.StatusCreated,
+		route:     "/orders",
+		duration:  1500 * time.Microsecond,
+		resHeader: http.Header{"Content-Type": []string{"application/json"}},
+		resBody:   []byte(`{"ok":true}`),
+	}
+
+	info := responseInfo(record)
+
+	assert.Equal(t, http.StatusCreated, info["status"])
+	assert.Equal(t, "/orders", info["route"])
+	assert.InDelta(t, 1.5, info["duration"], 0)
+	assert.Equal(t, `{"ok":true}`, info["body"])
+	assert.Equal(t, map[string]interface{}{"Content-Type": "application/json"}, info["headers"])
+
+	assert.Equal(t, map[string]interface{}{
+		"method":  http.MethodPost,
+		"path":    "/orders",
+		"query":   map[string]interface{}{"dry": "true"},
+		"headers": map[string]interface{}{"Accept": "text/plain, application/json"},
+		"body":    `{"id":1}`,
+	}, info["request"])
+}
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+var events = []
+
+mock("https://example.com", app => {
+	app.onRequest(req => events.push("request " + req.path))
+	app.onResponse(res => events.push("response " + res.status))
+	app.get("/", (req, res) => res.text("Hello World!"))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	_, err = req.Get(helper.module.lookup["https://example.com"])
+
+	require.NoError(t, err)
+
+	// the hooks are queued to the event loop, the response hook may be queued after the response
+	assert.Eventually(t, func() bool {
+		helper.runtime.EventLoop.WaitOnRegistered()
+
+		return len(helper.vu.Runtime().Get("events").Export().([]interface{})) == 2 // nolint:forcetypeassert
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, []interface{}{"request /", "response 200"}, helper.vu.Runtime().Get("events").Export())
+}
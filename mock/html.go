@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// htmlRewriter rewrites the absolute (and protocol-relative) URLs of the recorded origins inside
+// HTML and CSS fixtures to root-relative URLs, so a browser navigating the mock stays on the mock's origin.
+type htmlRewriter struct {
+	origins []string
+	pattern *regexp.Regexp
+}
+
+// getHTML parses the rewriteHTML option. It is either a boolean or an object with origins property,
+// listing the additional origins of the recorded site served by the mock.
+func getHTML(value sobek.Value) *htmlRewriter {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if !value.ToBoolean() {
+			return nil
+		}
+
+		return new(htmlRewriter)
+	}
+
+	rewriter := new(htmlRewriter)
+
+	if v := obj.Get("origins"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		if list, ok := v.Export().([]interface{}); ok {
+			for _, item := range list {
+				if origin, ok := item.(string); ok {
+					rewriter.origins = append(rewriter.origins, origin)
+				}
+			}
+		}
+	}
+
+	return rewriter
+}
+
+// forTarget returns a copy of the rewriter compiled for the given mock target.
+// URLs are matched regardless of their scheme, the path prefix of the target is removed.
+// It returns nil if none of the origins is a valid URL.
+func (hr *htmlRewriter) forTarget(target string) *htmlRewriter {
+	alternatives := make([]string, 0, len(hr.origins)+1)
+
+	for _, origin := range append([]string{target}, hr.origins...) {
+		loc, err := url.Parse(origin)
+		if err != nil || len(loc.Host) == 0 {
+			continue
+		}
+
+		alternatives = append(alternatives, regexp.QuoteMeta(loc.Host+strings.TrimSuffix(loc.Path, "/")))
+	}
+
+	if len(alternatives) == 0 {
+		return nil
+	}
+
+	pattern := `(?i)(?:https?:)?//(?:` + strings.Join(alternatives, "|") + `)([/?#"'\s()<>\\]|$)`
+
+	return &htmlRewriter{origins: hr.origins, pattern: regexp.MustCompile(pattern)}
+}
+
+func (hr *htmlRewriter) rewrite(data []byte) []byte {
+	return hr.pattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		next := hr.pattern.FindSubmatch(match)[1]
+
+		if len(next) == 1 && next[0] == '/' {
+			return next
+		}
+
+		return append([]byte{'/'}, next...)
+	})
+}
+
+// serve serves the fixture (stub) response, rewriting the URLs of HTML and CSS bodies.
+func (hr *htmlRewriter) serve(handler http.Handler, w http.ResponseWriter, r *http.Request) { // nolint:varnamelen
+	res := acquireResponse()
+	defer res.release()
+
+	handler.ServeHTTP(res, r)
+
+	if len(res.header.Get("Content-Encoding")) == 0 && isMarkup(res.header.Get("Content-Type")) {
+		body := hr.rewrite(res.body.Bytes())
+
+		res.body.Reset()
+		res.body.Write(body)
+	}
+
+	for _, name := range linkHeaders {
+		values := res.header.Values(name)
+
+		for idx, value := range values {
+			values[idx] = string(hr.rewrite([]byte(value)))
+		}
+	}
+
+	res.flush(w)
+}
+
+// isMarkup reports whether the body with the given content type is an HTML page or a stylesheet.
+func isMarkup(contentType string) bool {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return media == "text/html" || media == "application/xhtml+xml" || media == "text/css"
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLRewriter(t *testing.T) {
+	t.Parallel()
+
+	rewriter := (&htmlRewriter{origins: []string{"https://static.example.com"}}).forTarget("https://www.example.com")
+
+	require.NotNil(t, rewriter)
+
+	page := `<a href="https://www.example.com">Home</a>` +
+		`<a href='http://www.example.com/about?x=1'>About</a>` +
+		`<img src="//static.example.com/logo.png" srcset="https://static.example.com/logo@2x.png 2x">` +
+		`<a href="https://www.example.com.evil/">Other</a>` +
+		`<style>body{background:url(https://static.example.com)}</style>`
+
+	assert.Equal(t, `<a href="/">Home</a>`+
+		`<a href='/about?x=1'>About</a>`+
+		`<img src="/logo.png" srcset="/logo@2x.png 2x">`+
+		`<a href="https://www.example.com.evil/">Other</a>`+
+		`<style>body{background:url(/)}</style>`, string(rewriter.rewrite([]byte(page))))
+
+	assert.Nil(t, new(htmlRewriter).forTarget("example"))
+}
+
+func TestHTMLRewriterServe(t *testing.T) {
+	t.Parallel()
+
+	rewriter := new(htmlRewriter).forTarget("https://example.com/shop")
+
+	fixture := &stub{Response: &stubResponse{
+		Status:  http.StatusOK,
+		Headers: map[string]string{"Content-Type": "text/html", "Location": "https://example.com/shop/cart"},
+		Body:    `<a href="https://example.com/shop/cart">Cart</a><a href="https://example.com/blog">Blog</a>`,
+	}}
+
+	rec := httptest.NewRecorder()
+
+	rewriter.serve(fixture, rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "/cart", rec.Header().Get("Location"))
+	assert.Equal(t, `<a href="/cart">Cart</a><a href="https://example.com/blog">Blog</a>`, rec.Body.String())
+
+	fixture.Response.Headers["Content-Type"] = "application/json"
+
+	rec = httptest.NewRecorder()
+
+	rewriter.serve(fixture, rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, fixture.Response.Body, rec.Body.String())
+}
@@ -7,10 +7,9 @@ package mock
 import (
 	"github.com/grafana/sobek"
 	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
 )
 
-// XXX: add batch function support
-
 var (
 	urlFirstMethods  = []string{"get", "head", "post", "put", "patch", "options", "del"}
 	urlSecondMethods = []string{"request", "asyncRequest"}
@@ -24,6 +23,242 @@ func (mod *Module) wrapHTTPExports(defaults *sobek.Object) {
 	for _, method := range urlSecondMethods {
 		mod.wrap(defaults, method, 1)
 	}
+
+	mod.wrapBatch(defaults)
+	mod.wrapRedirects(defaults)
+}
+
+// wrapModule wraps the HTTP and WebSocket APIs of the other modules (e.g. k6/experimental/websockets or
+// k6/x/http), so the URLs of the mocked targets are rewritten to the mock servers. The URL methods
+// and the batch function of the module are wrapped, as well as the methods of the objects created by
+// the module's classes (e.g. Client). The module is modified in place and returned.
+func (mod *Module) wrapModule(module *sobek.Object) *sobek.Object {
+	mod.wrapMethods(module)
+
+	if _, ok := sobek.AssertFunction(module.Get("batch")); ok {
+		mod.wrapBatch(module)
+	}
+
+	mod.websockets(module)
+
+	for _, name := range module.Keys() {
+		if name == "WebSocket" || len(name) == 0 || name[0] < 'A' || name[0] > 'Z' {
+			continue
+		}
+
+		mod.wrapClass(module, name)
+	}
+
+	return module
+}
+
+// wrapMethods wraps the URL methods (see urlFirstMethods and urlSecondMethods, and fetch) of the object,
+// the missing ones are skipped. Unlike wrap, the methods are called on the object.
+func (mod *Module) wrapMethods(obj *sobek.Object) {
+	indexes := map[string]int{"fetch": 0}
+
+	for _, method := range urlFirstMethods {
+		indexes[method] = 0
+	}
+
+	for _, method := range urlSecondMethods {
+		indexes[method] = 1
+	}
+
+	for _, method := range sortedKeys(indexes) {
+		callable, ok := sobek.AssertFunction(obj.Get(method))
+		if !ok {
+			continue
+		}
+
+		index := indexes[method]
+
+		wrapper := func(call sobek.FunctionCall) sobek.Value {
+			if len(call.Arguments) > index {
+				mod.rewrite(call.Arguments, index)
+			}
+
+			v, err := callable(call.This, call.Arguments...)
+			if err != nil {
+				mod.throw(err)
+			}
+
+			return v
+		}
+
+		if err := obj.Set(method, wrapper); err != nil {
+			mod.throw(err)
+		}
+	}
+}
+
+// wrapClass replaces the class of the module by a constructor wrapping the URL methods of the created
+// objects. Exports that are not constructors are left as is.
+func (mod *Module) wrapClass(module *sobek.Object, name string) {
+	ctor := module.Get(name)
+
+	if _, ok := sobek.AssertConstructor(ctor); !ok {
+		return
+	}
+
+	runtime := mod.runtime()
+
+	wrapper := func(call sobek.ConstructorCall) *sobek.Object {
+		obj, err := runtime.New(ctor, call.Arguments...)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		mod.wrapMethods(obj)
+
+		return obj
+	}
+
+	if err := module.Set(name, wrapper); err != nil {
+		mod.throw(err)
+	}
+}
+
+// rewriteValue returns the mock server's URL of the given URL value, ok is false if it is not mocked.
+// The value may be a string, an URL object (with href property) or an http.url tagged template.
+func (mod *Module) rewriteValue(value sobek.Value) (sobek.Value, bool) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return value, false
+	}
+
+	runtime := mod.runtime()
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		loc, ok := mod.rewriteURL(value.String())
+
+		return runtime.ToValue(loc), ok
+	}
+
+	if tag, isTag := obj.Export().(httpext.URL); isTag {
+		loc, ok := mod.rewriteURL(tag.URL)
+		if !ok {
+			return value, false
+		}
+
+		// the name is kept, so metrics are still tagged with the original URL template
+		rewritten, err := httpext.NewURL(loc, tag.Name)
+		if err != nil {
+			return value, false
+		}
+
+		return runtime.ToValue(rewritten), true
+	}
+
+	if href := obj.Get("href"); href != nil && !sobek.IsUndefined(href) && !sobek.IsNull(href) {
+		if loc, ok := mod.rewriteURL(href.String()); ok {
+			return runtime.ToValue(loc), true
+		}
+	}
+
+	return value, false
+}
+
+// rewriteRequest rewrites the URL of a batch request. The request is an URL value, an array
+// ([method, url, body, params]) or an object with url property. Arrays and objects are copied.
+func (mod *Module) rewriteRequest(value sobek.Value) (sobek.Value, bool) {
+	if rewritten, ok := mod.rewriteValue(value); ok {
+		return rewritten, true
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return value, false
+	}
+
+	key := "url"
+	if obj.ClassName() == "Array" {
+		key = "1"
+	}
+
+	loc, ok := mod.rewriteValue(obj.Get(key))
+	if !ok {
+		return value, false
+	}
+
+	out := mod.copyObject(obj)
+
+	if err := out.Set(key, loc); err != nil {
+		mod.throw(err)
+	}
+
+	return out, true
+}
+
+// rewriteBatch rewrites the URLs of the batch requests given as array or object.
+func (mod *Module) rewriteBatch(value sobek.Value) (sobek.Value, bool) {
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return value, false
+	}
+
+	out := mod.copyObject(obj)
+	changed := false
+
+	for _, key := range obj.Keys() {
+		rewritten, ok := mod.rewriteRequest(obj.Get(key))
+		if !ok {
+			continue
+		}
+
+		changed = true
+
+		if err := out.Set(key, rewritten); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	return out, changed
+}
+
+// copyObject returns a shallow copy of the array or object.
+func (mod *Module) copyObject(obj *sobek.Object) *sobek.Object {
+	var out *sobek.Object
+
+	if obj.ClassName() == "Array" {
+		out = mod.runtime().NewArray()
+	} else {
+		out = mod.runtime().NewObject()
+	}
+
+	for _, key := range obj.Keys() {
+		if err := out.Set(key, obj.Get(key)); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	return out
+}
+
+func (mod *Module) wrapBatch(this *sobek.Object) {
+	callable, ok := sobek.AssertFunction(this.Get("batch"))
+	if !ok {
+		mod.throwf("batch must be callable", errInvalidArg)
+	}
+
+	wrapper := func(call sobek.FunctionCall) sobek.Value {
+		if len(call.Arguments) != 0 {
+			if requests, ok := mod.rewriteBatch(call.Arguments[0]); ok {
+				call.Arguments[0] = requests
+			}
+		}
+
+		v, err := callable(mod.runtime().GlobalObject(), call.Arguments...)
+		if err != nil {
+			common.Throw(mod.runtime(), err)
+		}
+
+		return v
+	}
+
+	if err := this.Set("batch", mod.runtime().ToValue(wrapper)); err != nil {
+		common.Throw(mod.runtime(), err)
+	}
 }
 
 func (mod *Module) parseBody(args []sobek.Value, index int) {
@@ -44,6 +44,37 @@ func TestModuleWrap(t *testing.T) {
 	assert.Equal(t, "https://example.net", actual)
 }
 
+func TestModuleWrapModule(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+	runtime := helper.vu.Runtime()
+
+	module, err := runtime.RunString(`({
+		get: (url) => url,
+		request: (method, url) => url,
+		Client: class { fetch(url) { return url } },
+	})`)
+
+	assert.NoError(t, err)
+
+	helper.module.lookup["https://example.com"] = "https://example.net"
+	helper.module.wrapModule(module.ToObject(runtime))
+
+	assert.NoError(t, runtime.Set("module", module))
+
+	for _, script := range []string{
+		`module.get("https://example.com/a")`,
+		`module.request("GET", "https://example.com/a")`,
+		`new module.Client().fetch("https://example.com/a")`,
+	} {
+		actual, err := runtime.RunString(script)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.net/a", actual.String(), script)
+	}
+}
+
 func TestParseBodyWithStringBody(t *testing.T) {
 	t.Parallel()
 
@@ -370,3 +401,153 @@ func TestModuleWrapWithXMLBodyNoHeaders(t *testing.T) {
 	// Check if the XML body is correctly passed
 	assert.Equal(t, xmlBody, capturedRequest.Body)
 }
+
+func TestModuleWrapBatch(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+	runtime := helper.vu.Runtime()
+
+	target := runtime.NewObject()
+
+	var actual sobek.Value
+
+	assert.NoError(t, target.Set("batch", func(requests sobek.Value) { actual = requests }))
+
+	helper.module.lookup["https://example.com"] = "https://example.net"
+
+	helper.module.wrapBatch(target)
+
+	requests, err := runtime.RunString(`[
+		"https://example.com/a",
+		["GET", "https://example.com/b", null, {}],
+		{ method: "GET", url: "https://example.com/c" },
+		{ method: "GET", url: { href: "https://example.com/d" } },
+		"https://example.org/e"
+	]`)
+
+	assert.NoError(t, err)
+
+	callable, ok := sobek.AssertFunction(target.Get("batch"))
+
+	assert.True(t, ok)
+
+	_, err = callable(sobek.Undefined(), requests)
+
+	assert.NoError(t, err)
+
+	obj := actual.ToObject(runtime)
+
+	assert.Equal(t, "https://example.net/a", obj.Get("0").String())
+	assert.Equal(t, "https://example.net/b", obj.Get("1").ToObject(runtime).Get("1").String())
+	assert.Equal(t, "https://example.net/c", obj.Get("2").ToObject(runtime).Get("url").String())
+	assert.Equal(t, "https://example.net/d", obj.Get("3").ToObject(runtime).Get("url").String())
+	assert.Equal(t, "https://example.org/e", obj.Get("4").String())
+
+	// original requests are not modified
+	assert.Equal(t, "https://example.com/c", requests.ToObject(runtime).Get("2").ToObject(runtime).Get("url").String())
+}
+
+func TestModuleRewriteURL(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	helper.module.lookup["https://example.com"] = "http://127.0.0.1:8000"
+	helper.module.lookup["https://example.com/eu"] = "http://127.0.0.1:8000/__region__/eu"
+
+	loc, ok := helper.module.rewriteURL("https://example.com/eu/items")
+
+	assert.True(t, ok)
+	assert.Equal(t, "http://127.0.0.1:8000/__region__/eu/items", loc)
+
+	loc, ok = helper.module.rewriteURL("http://127.0.0.1:8000/items")
+
+	assert.False(t, ok)
+	assert.Equal(t, "http://127.0.0.1:8000/items", loc)
+}
+
+func TestModuleWrapRedirects(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+	runtime := helper.vu.Runtime()
+
+	module, err := runtime.RunString(`
+		var calls = []
+
+		function send(method, url, body, params) {
+			calls.push({ method, url, body, params })
+
+			const redirects = {
+				"https://example.net/login": { status: 302, headers: { Location: "https://example.com/session" } },
+				"https://example.net/session": { status: 307, headers: { Location: "/home?from=login" } },
+				"https://example.net/home?from=login": { status: 303, headers: { Location: "https://example.org/" } },
+			}
+
+			return Object.assign({ url, status: 200, headers: {} }, redirects[url])
+		}
+
+		({
+			get: (url, params) => send("GET", url, null, params),
+			head: (url, params) => send("HEAD", url, null, params),
+			post: (url, body, params) => send("POST", url, body, params),
+			put: (url, body, params) => send("PUT", url, body, params),
+			patch: (url, body, params) => send("PATCH", url, body, params),
+			del: (url, body, params) => send("DELETE", url, body, params),
+			options: (url, body, params) => send("OPTIONS", url, body, params),
+			request: send,
+			asyncRequest: (method, url, body, params) => Promise.resolve(send(method, url, body, params)),
+			batch: (requests) => requests,
+		})`)
+
+	assert.NoError(t, err)
+
+	helper.module.lookup["https://example.com"] = "https://example.net"
+	helper.module.wrapHTTPExports(module.ToObject(runtime))
+
+	assert.NoError(t, runtime.Set("http", module))
+
+	params := `{ headers: { Authorization: "Bearer token", "X-Test": "1" }, tags: { name: "login" } }`
+
+	res, err := runtime.RunString(`http.post("https://example.com/login", "user=admin", ` + params + `)`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.org/", res.ToObject(runtime).Get("url").String())
+
+	calls, err := runtime.RunString(`JSON.stringify(calls.map((c) => [c.method, c.url, c.body, c.params.redirects,
+		c.params.headers.Authorization || "", c.params.headers["X-Test"], c.params.tags.name]))`)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		["POST", "https://example.net/login", "user=admin", 0, "Bearer token", "1", "login"],
+		["GET", "https://example.net/session", null, 0, "Bearer token", "1", "login"],
+		["GET", "https://example.net/home?from=login", null, 0, "Bearer token", "1", "login"],
+		["GET", "https://example.org/", null, 0, "", "1", "login"]
+	]`, calls.String())
+
+	// the redirects param limits the redirects followed
+	res, err = runtime.RunString(`calls = []; http.get("https://example.com/login", { redirects: 1 })`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(307), res.ToObject(runtime).Get("status").ToInteger())
+
+	// asynchronous requests are followed by the promise
+	_, err = runtime.RunString(`
+		calls = []
+		var result
+		http.asyncRequest("GET", "https://example.com/session", null, { tags: { name: "session" } }).then((r) => { result = r })`)
+
+	assert.NoError(t, err)
+
+	res, err = runtime.RunString(`JSON.stringify([result.url, calls.map((c) => c.params.tags.name)])`)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["https://example.org/", ["session", "session", "session"]]`, res.String())
+
+	// requests of URLs not mocked are left to k6
+	res, err = runtime.RunString(`calls = []; http.get("https://example.org/login"); calls[0].params`)
+
+	assert.NoError(t, err)
+	assert.True(t, sobek.IsUndefined(res))
+}
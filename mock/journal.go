@@ -0,0 +1,284 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const journalLimit = 10000
+
+// entry is a single request/response exchange recorded by the server.
+type entry struct {
+	time        time.Time
+	duration    time.Duration
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        []byte
+	status      int
+	route       string
+	conn        int          // identifier of the client connection
+	correlation string       // correlation ID of the request
+	client      *clientHello // TLS fingerprint of the client
+	stub        string       // the stub serving the request
+	fault       string       // the injected transport fault, or status for error status faults
+
+	resHeader http.Header
+	resBody   []byte
+	spilled   *spilledBodies // bodies spilled to disk by the journal
+}
+
+// journal keeps the recent exchanges of the server, within the count and age limits. The bodies are
+// stored according to the body storage policy (see journalOptions).
+type journal struct {
+	mu        sync.RWMutex
+	limit     int
+	retention time.Duration
+	bodies    string
+	maxBody   int
+	disk      *diskBodies
+	entries   []*entry
+}
+
+func newJournal(limit int) *journal {
+	return &journal{limit: limit}
+}
+
+// configure applies the journal options.
+func (j *journal) configure(opts *journalOptions) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.limit, j.retention, j.bodies, j.maxBody = opts.limit, opts.retention, opts.bodies, opts.maxBody
+
+	if opts.bodies != bodiesDisk {
+		return nil
+	}
+
+	var err error
+
+	j.disk, err = newDiskBodies(opts.dir)
+
+	return err
+}
+
+func (j *journal) add(e *entry) {
+	e = j.store(e)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	evict := 0
+
+	if j.limit > 0 && len(j.entries) >= j.limit {
+		evict = len(j.entries) - j.limit + 1
+	}
+
+	if j.retention > 0 {
+		for evict < len(j.entries) && e.time.Sub(j.entries[evict].time) > j.retention {
+			evict++
+		}
+	}
+
+	for _, old := range j.entries[:evict] {
+		if old.spilled != nil {
+			j.disk.release(old.spilled)
+		}
+	}
+
+	j.entries = append(j.entries[evict:], e)
+}
+
+// close removes the spilled bodies.
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.disk == nil {
+		return nil
+	}
+
+	j.entries = nil
+
+	return j.disk.close()
+}
+
+func (j *journal) snapshot() []*entry {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return append([]*entry(nil), j.entries...)
+}
+
+// recorder captures the response status and the control headers set by the dispatcher.
+// Control headers are removed, the Date header is set from the server's clock and the route's
+// decorators are applied before the response header is written to the client.
+// The response is delayed by the route's latency, informational (1xx) responses of the route are sent
+// before the final response header.
+// Responses with slow header fault are held back, they are sent by the server afterwards. Responses
+// with transport fault are held back too, the server fails the connection instead.
+// Streamed responses are written in chunks (see streaming), throttled responses are written at the
+// route's bandwidth (see throttle).
+type recorder struct {
+	http.ResponseWriter
+	status        int
+	clock         *clock
+	decorate      func(control, header http.Header)
+	faults        func(control http.Header) *slowHeaders
+	slow          *slowHeaders
+	fault         string // transport fault of the response (X-Mock-Fault control header)
+	control       http.Header
+	header        http.Header
+	body          bytes.Buffer
+	informational func(control http.Header) []*informational
+	delay         func(control http.Header)
+	hold          func(control http.Header)
+	stream        func(control http.Header) *streaming
+	streaming     *streaming
+	throttle      func(control http.Header) *throttle
+	throttled     *throttle
+}
+
+func newRecorder(w http.ResponseWriter, clk *clock) *recorder {
+	return &recorder{ResponseWriter: w, clock: clk, control: make(http.Header)}
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.status != 0 {
+		return
+	}
+
+	rec.status = code
+
+	header := rec.Header()
+
+	for name, values := range header {
+		if strings.HasPrefix(name, "X-Mock-") {
+			rec.control[name] = values
+			header.Del(name)
+		}
+	}
+
+	if rec.clock != nil {
+		header.Set("Date", rec.clock.emitted().UTC().Format(http.TimeFormat))
+	}
+
+	if rec.decorate != nil {
+		rec.decorate(rec.control, header)
+	}
+
+	rec.header = header.Clone()
+
+	if rec.delay != nil {
+		rec.delay(rec.control)
+	}
+
+	if rec.hold != nil {
+		rec.hold(rec.control)
+	}
+
+	if rec.fault = rec.control.Get(headerFault); len(rec.fault) != 0 {
+		return
+	}
+
+	if rec.faults != nil {
+		if rec.slow = rec.faults(rec.control); rec.slow != nil {
+			return
+		}
+	}
+
+	if rec.informational != nil {
+		writeInformational(rec.ResponseWriter, rec.informational(rec.control))
+	}
+
+	if rec.stream != nil {
+		if rec.streaming = rec.stream(rec.control); rec.streaming != nil {
+			header.Del("Content-Length")
+		}
+	}
+
+	if rec.throttle != nil {
+		rec.throttled = rec.throttle(rec.control)
+	}
+
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	rec.body.Write(data)
+
+	if rec.slow != nil || len(rec.fault) != 0 {
+		return len(data), nil
+	}
+
+	var w http.ResponseWriter = rec.ResponseWriter
+
+	if rec.throttled != nil {
+		w = &throttledWriter{ResponseWriter: w, throttle: rec.throttled}
+	}
+
+	if rec.streaming != nil {
+		return rec.streaming.write(w, data)
+	}
+
+	return w.Write(data)
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// queryOf returns the parsed query of the URL, nil (an empty query) if the URL has no query.
+func queryOf(u *url.URL) url.Values {
+	if len(u.RawQuery) == 0 {
+		return nil
+	}
+
+	return u.Query()
+}
+
+// readBody reads the request body and replaces it with an in-memory copy. The body already read is
+// returned without reading (and copying) it again, requests without body are not read at all.
+func readBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	if read, ok := r.Body.(*readBuffer); ok {
+		return read.data
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil
+	}
+
+	r.Body.Close() // nolint:errcheck,gosec
+	r.Body = &readBuffer{Reader: bytes.NewReader(body), data: body}
+
+	return body
+}
+
+// readBuffer is the in-memory copy of a request body read by readBody.
+type readBuffer struct {
+	*bytes.Reader
+	data []byte
+}
+
+func (*readBuffer) Close() error {
+	return nil
+}
@@ -0,0 +1,1041 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// Kafka API keys and error codes used by the mock.
+const (
+	kafkaProduce     = 0
+	kafkaFetch       = 1
+	kafkaListOffsets = 2
+	kafkaMetadata    = 3
+	kafkaAPIVersions = 18
+
+	kafkaNoError            = 0
+	kafkaOffsetOutOfRange   = 1
+	kafkaUnknownPartition   = 3
+	kafkaUnsupportedVersion = 35
+
+	kafkaNodeID         = 0
+	kafkaMaxRequest     = 100 << 20
+	kafkaBatchHeader    = 61 // size of the record batch header (magic 2)
+	kafkaMaxWait        = 5 * time.Second
+	kafkaLatestOffset   = -1
+	kafkaEarliestOffset = -2
+)
+
+var (
+	errKafkaRequest = errors.New("invalid Kafka request")
+
+	kafkaCRC = crc32.MakeTable(crc32.Castagnoli) // nolint:gochecknoglobals
+
+	// kafkaVersions contains the supported version range of the APIs. Record batches (magic 2) are used
+	// exclusively, so the older message set based versions of Produce and Fetch are not supported.
+	kafkaVersions = map[int16][2]int16{ // nolint:gochecknoglobals
+		kafkaProduce:     {3, 5},
+		kafkaFetch:       {4, 5},
+		kafkaListOffsets: {1, 1},
+		kafkaMetadata:    {1, 4},
+		kafkaAPIVersions: {0, 2},
+	}
+)
+
+// kafkaReader decodes request fields, the first decoding error is kept.
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kafkaReader) next(size int) []byte {
+	if r.err != nil || size < 0 || len(r.data) < size {
+		r.err = errKafkaRequest
+
+		return make([]byte, 8)
+	}
+
+	chunk := r.data[:size]
+	r.data = r.data[size:]
+
+	return chunk
+}
+
+func (r *kafkaReader) int8() int8 {
+	return int8(r.next(1)[0])
+}
+
+func (r *kafkaReader) int16() int16 {
+	return int16(binary.BigEndian.Uint16(r.next(2)))
+}
+
+func (r *kafkaReader) int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+func (r *kafkaReader) int64() int64 {
+	return int64(binary.BigEndian.Uint64(r.next(8)))
+}
+
+func (r *kafkaReader) string() string {
+	size := r.int16()
+	if size < 0 {
+		return ""
+	}
+
+	return string(r.next(int(size)))
+}
+
+func (r *kafkaReader) bytes() []byte {
+	size := r.int32()
+	if size < 0 {
+		return nil
+	}
+
+	return r.next(int(size))
+}
+
+// array calls fn for each element of the array, it returns false for null arrays.
+func (r *kafkaReader) array(fn func()) bool {
+	count := r.int32()
+	if count < 0 {
+		return false
+	}
+
+	for idx := int32(0); idx < count && r.err == nil; idx++ {
+		fn()
+	}
+
+	return true
+}
+
+// kafkaWriter encodes response fields.
+type kafkaWriter struct {
+	bytes.Buffer
+}
+
+func (w *kafkaWriter) int8(v int8) *kafkaWriter {
+	w.WriteByte(byte(v)) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *kafkaWriter) int16(v int16) *kafkaWriter {
+	w.Write(binary.BigEndian.AppendUint16(nil, uint16(v))) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *kafkaWriter) int32(v int32) *kafkaWriter {
+	w.Write(binary.BigEndian.AppendUint32(nil, uint32(v))) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *kafkaWriter) int64(v int64) *kafkaWriter {
+	w.Write(binary.BigEndian.AppendUint64(nil, uint64(v))) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *kafkaWriter) string(v string) *kafkaWriter {
+	w.int16(int16(len(v)))
+	w.WriteString(v) // nolint:errcheck,gosec
+
+	return w
+}
+
+func (w *kafkaWriter) nullString() *kafkaWriter {
+	return w.int16(-1)
+}
+
+func (w *kafkaWriter) bytes(v []byte) *kafkaWriter {
+	w.int32(int32(len(v)))
+	w.Write(v) // nolint:errcheck,gosec
+
+	return w
+}
+
+type kafkaHeader struct {
+	key   string
+	value []byte
+}
+
+type kafkaRecord struct {
+	topic     string
+	partition int32
+	offset    int64
+	timestamp int64
+	key       []byte
+	value     []byte
+	headers   []kafkaHeader
+}
+
+func (rec *kafkaRecord) object() map[string]interface{} {
+	headers := make(map[string]interface{}, len(rec.headers))
+
+	for _, header := range rec.headers {
+		headers[header.key] = string(header.value)
+	}
+
+	return map[string]interface{}{
+		"topic":     rec.topic,
+		"partition": rec.partition,
+		"offset":    rec.offset,
+		"timestamp": rec.timestamp,
+		"key":       string(rec.key),
+		"value":     string(rec.value),
+		"headers":   headers,
+	}
+}
+
+func appendVarint(data []byte, value int64) []byte {
+	return binary.AppendVarint(data, value)
+}
+
+func appendVarBytes(data []byte, value []byte) []byte {
+	if value == nil {
+		return appendVarint(data, -1)
+	}
+
+	return append(appendVarint(data, int64(len(value))), value...)
+}
+
+// encodeBatch returns the uncompressed record batch (magic 2) of the records.
+func encodeBatch(records []*kafkaRecord, baseOffset int64) []byte {
+	var body []byte
+
+	first := records[0].timestamp
+	maxTimestamp := first
+
+	for idx, rec := range records {
+		var data []byte
+
+		data = append(data, 0) // attributes
+		data = appendVarint(data, rec.timestamp-first)
+		data = appendVarint(data, int64(idx))
+		data = appendVarBytes(data, rec.key)
+		data = appendVarBytes(data, rec.value)
+		data = appendVarint(data, int64(len(rec.headers)))
+
+		for _, header := range rec.headers {
+			data = appendVarBytes(data, []byte(header.key))
+			data = appendVarBytes(data, header.value)
+		}
+
+		body = append(appendVarint(body, int64(len(data))), data...)
+
+		if rec.timestamp > maxTimestamp {
+			maxTimestamp = rec.timestamp
+		}
+	}
+
+	tail := new(kafkaWriter)
+
+	tail.int16(0) // attributes
+	tail.int32(int32(len(records) - 1))
+	tail.int64(first)
+	tail.int64(maxTimestamp)
+	tail.int64(-1) // producer id
+	tail.int16(-1) // producer epoch
+	tail.int32(-1) // base sequence
+	tail.int32(int32(len(records)))
+	tail.Write(body) // nolint:errcheck,gosec
+
+	batch := new(kafkaWriter)
+
+	batch.int64(baseOffset)
+	batch.int32(int32(4 + 1 + 4 + tail.Len())) // leader epoch, magic, crc and the rest
+	batch.int32(0)
+	batch.int8(2)
+	batch.int32(int32(crc32.Checksum(tail.Bytes(), kafkaCRC)))
+	batch.Write(tail.Bytes()) // nolint:errcheck,gosec
+
+	return batch.Bytes()
+}
+
+// decodeRecords returns the records of the record batch. Records of compressed batches are decoded
+// only if compressed with gzip.
+func decodeRecords(batch []byte, baseOffset int64) []*kafkaRecord {
+	if len(batch) < kafkaBatchHeader || batch[16] != 2 {
+		return nil
+	}
+
+	attributes := binary.BigEndian.Uint16(batch[21:])
+	first := int64(binary.BigEndian.Uint64(batch[27:]))
+	count := int(binary.BigEndian.Uint32(batch[57:]))
+	data := batch[kafkaBatchHeader:]
+
+	switch attributes & 0x07 {
+	case 0:
+	case 1:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	// the count is sent by the client, each record takes at least one byte
+	if count > len(data) {
+		count = len(data)
+	}
+
+	records := make([]*kafkaRecord, 0, count)
+
+	for idx := 0; idx < count; idx++ {
+		rec, rest, ok := decodeRecord(data)
+		if !ok {
+			break
+		}
+
+		rec.offset += baseOffset
+		rec.timestamp += first
+		records = append(records, rec)
+		data = rest
+	}
+
+	return records
+}
+
+func decodeRecord(data []byte) (*kafkaRecord, []byte, bool) {
+	size, n := binary.Varint(data)
+	if n <= 0 || size <= 0 || int64(len(data)-n) < size {
+		return nil, nil, false
+	}
+
+	rest := data[n+int(size):]
+	data = data[n+1 : n+int(size)] // skip attributes
+
+	ok := true
+
+	varint := func() int64 {
+		value, n := binary.Varint(data)
+		if n <= 0 {
+			ok = false
+
+			return 0
+		}
+
+		data = data[n:]
+
+		return value
+	}
+
+	varbytes := func() []byte {
+		length := varint()
+		if length < 0 || !ok {
+			return nil
+		}
+
+		if int64(len(data)) < length {
+			ok = false
+
+			return nil
+		}
+
+		value := data[:length]
+		data = data[length:]
+
+		return value
+	}
+
+	rec := new(kafkaRecord)
+
+	rec.timestamp = varint()
+	rec.offset = varint()
+	rec.key = varbytes()
+	rec.value = varbytes()
+
+	for count := varint(); count > 0 && ok; count-- {
+		rec.headers = append(rec.headers, kafkaHeader{key: string(varbytes()), value: varbytes()})
+	}
+
+	return rec, rest, ok
+}
+
+type kafkaBatch struct {
+	base  int64
+	count int64
+	data  []byte
+}
+
+type kafkaPartition struct {
+	batches []*kafkaBatch
+	next    int64
+}
+
+// append stores the record batches with offsets assigned, it returns the base offset and the stored records.
+func (p *kafkaPartition) append(data []byte) (int64, []*kafkaRecord) {
+	base := p.next
+
+	var records []*kafkaRecord
+
+	for len(data) >= kafkaBatchHeader {
+		// the batch length is sent by the client, batches shorter than the header or the data are rejected
+		size := int(binary.BigEndian.Uint32(data[8:])) + 12
+		if size < kafkaBatchHeader || size > len(data) || data[16] != 2 {
+			break
+		}
+
+		batch := append([]byte(nil), data[:size]...)
+		count := int64(binary.BigEndian.Uint32(batch[23:])) + 1
+
+		binary.BigEndian.PutUint64(batch, uint64(p.next))
+
+		p.batches = append(p.batches, &kafkaBatch{base: p.next, count: count, data: batch})
+		records = append(records, decodeRecords(batch, p.next)...)
+
+		p.next += count
+		data = data[size:]
+	}
+
+	return base, records
+}
+
+// read returns the record batches from the given offset, at least one batch is returned if available.
+func (p *kafkaPartition) read(offset int64, limit int) []byte {
+	var data []byte
+
+	for _, batch := range p.batches {
+		if batch.base+batch.count <= offset {
+			continue
+		}
+
+		if len(data) != 0 && len(data)+len(batch.data) > limit {
+			break
+		}
+
+		data = append(data, batch.data...)
+	}
+
+	return data
+}
+
+type kafkaTopic struct {
+	partitions []*kafkaPartition
+}
+
+func newKafkaTopic(count int) *kafkaTopic {
+	topic := &kafkaTopic{partitions: make([]*kafkaPartition, count)}
+
+	for idx := range topic.partitions {
+		topic.partitions[idx] = new(kafkaPartition)
+	}
+
+	return topic
+}
+
+type kafkaHandler struct {
+	topic string
+	fn    sobek.Callable
+}
+
+// kafkaBroker is a single node Kafka broker mock implementing the Metadata, Produce, Fetch and ListOffsets APIs.
+type kafkaBroker struct {
+	*endpoint
+	mu       sync.Mutex
+	topics   map[string]*kafkaTopic
+	records  []*kafkaRecord
+	events   map[string][]sobek.Callable
+	handlers []*kafkaHandler
+	changed  chan struct{} // closed when records are appended
+}
+
+func (mod *Module) newKafkaBroker(args *mockArgs) *kafkaBroker {
+	broker := &kafkaBroker{
+		endpoint: mod.newEndpoint(args, "kafka"),
+		topics:   make(map[string]*kafkaTopic),
+		events:   make(map[string][]sobek.Callable),
+		changed:  make(chan struct{}),
+	}
+
+	broker.mustSet("on", func(event string, fn sobek.Callable) *sobek.Object {
+		broker.mu.Lock()
+		broker.events[event] = append(broker.events[event], fn)
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("handle", func(topic string, fn sobek.Callable) *sobek.Object {
+		broker.mu.Lock()
+		broker.handlers = append(broker.handlers, &kafkaHandler{topic: topic, fn: fn})
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("topic", func(name string, options sobek.Value) *sobek.Object {
+		count := 1
+
+		if obj, isObj := options.(*sobek.Object); isObj {
+			if v := obj.Get("partitions"); v != nil && v.ToInteger() > 0 {
+				count = int(v.ToInteger())
+			}
+		}
+
+		broker.mu.Lock()
+		broker.topics[name] = newKafkaTopic(count)
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("produce", func(topic string, value sobek.Value) {
+		broker.produceRecord(broker.getRecord(topic, value))
+	})
+
+	broker.mustSet("records", func(topic sobek.Value) []interface{} {
+		name := optionalString(topic)
+
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		found := make([]interface{}, 0, len(broker.records))
+
+		for _, rec := range broker.records {
+			if len(name) == 0 || rec.topic == name {
+				found = append(found, rec.object())
+			}
+		}
+
+		return found
+	})
+
+	return broker
+}
+
+// getRecord converts the record object (key, value, headers and partition properties) to record.
+func (broker *kafkaBroker) getRecord(topic string, value sobek.Value) *kafkaRecord {
+	rec := &kafkaRecord{topic: topic, partition: -1, timestamp: time.Now().UnixMilli()}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		rec.value = payloadBytes(value)
+
+		return rec
+	}
+
+	if key := obj.Get("key"); key != nil && !sobek.IsUndefined(key) && !sobek.IsNull(key) {
+		rec.key = payloadBytes(key)
+	}
+
+	rec.value = payloadBytes(obj.Get("value"))
+
+	if partition := obj.Get("partition"); partition != nil && !sobek.IsUndefined(partition) {
+		rec.partition = int32(partition.ToInteger())
+	}
+
+	if headers, isObj := obj.Get("headers").(*sobek.Object); isObj {
+		for _, key := range headers.Keys() {
+			rec.headers = append(rec.headers, kafkaHeader{key: key, value: payloadBytes(headers.Get(key))})
+		}
+	}
+
+	return rec
+}
+
+// topic returns the topic of the given name, it is created with one partition if necessary.
+// It must be called with broker.mu held.
+func (broker *kafkaBroker) topic(name string) *kafkaTopic {
+	topic, found := broker.topics[name]
+	if !found {
+		topic = newKafkaTopic(1)
+		broker.topics[name] = topic
+	}
+
+	return topic
+}
+
+// produceRecord appends the record produced by the script. The partition is selected by the key's hash if not given.
+func (broker *kafkaBroker) produceRecord(rec *kafkaRecord) {
+	broker.mu.Lock()
+
+	topic := broker.topic(rec.topic)
+
+	if rec.partition < 0 || int(rec.partition) >= len(topic.partitions) {
+		rec.partition = 0
+
+		if len(rec.key) != 0 {
+			hash := fnv.New32a()
+			hash.Write(rec.key) // nolint:errcheck,gosec
+			rec.partition = int32(hash.Sum32() % uint32(len(topic.partitions)))
+		}
+	}
+
+	partition := topic.partitions[rec.partition]
+	_, records := partition.append(encodeBatch([]*kafkaRecord{rec}, 0))
+
+	broker.journal(rec.topic, rec.partition, records)
+
+	broker.mu.Unlock()
+}
+
+// journal records the appended records and wakes the waiting fetch requests. It must be called with broker.mu held.
+func (broker *kafkaBroker) journal(topic string, partition int32, records []*kafkaRecord) {
+	close(broker.changed)
+	broker.changed = make(chan struct{})
+
+	for _, rec := range records {
+		rec.topic, rec.partition = topic, partition
+
+		if len(broker.records) >= journalLimit {
+			broker.records = broker.records[1:]
+		}
+
+		broker.records = append(broker.records, rec)
+	}
+}
+
+// handle calls the produce event handlers and the handlers of the record's topic. Handlers may return
+// record(s) (objects with topic, key, value, headers and partition properties) produced by the broker.
+func (broker *kafkaBroker) handle(rec *kafkaRecord) {
+	broker.mu.Lock()
+	events := broker.events["produce"]
+	handlers := broker.handlers
+	broker.mu.Unlock()
+
+	for _, fn := range events {
+		broker.invoke(fn, rec.object())
+	}
+
+	for _, handler := range handlers {
+		if handler.topic != rec.topic {
+			continue
+		}
+
+		result := broker.invoke(handler.fn, rec.object())
+		if result == nil || sobek.IsUndefined(result) || sobek.IsNull(result) {
+			continue
+		}
+
+		var replies []*kafkaRecord
+
+		err := broker.call(func() error {
+			obj := result.ToObject(broker.mod.runtime())
+			items := []sobek.Value{obj}
+
+			if _, isArray := result.Export().([]interface{}); isArray {
+				items = items[:0]
+
+				for _, key := range obj.Keys() {
+					items = append(items, obj.Get(key))
+				}
+			}
+
+			for _, item := range items {
+				if obj, isObj := item.(*sobek.Object); isObj {
+					replies = append(replies, broker.getRecord(optionalString(obj.Get("topic")), obj))
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			broker.mod.logger.WithError(err).WithField("target", broker.target).Warn("mock handler failed")
+
+			continue
+		}
+
+		for _, reply := range replies {
+			broker.produceRecord(reply)
+		}
+	}
+}
+
+// serveConn serves a Kafka client connection, requests are served in order.
+func (broker *kafkaBroker) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	for {
+		var size [4]byte
+
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return
+		}
+
+		length := binary.BigEndian.Uint32(size[:])
+		if length > kafkaMaxRequest {
+			return
+		}
+
+		data := make([]byte, length)
+
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return
+		}
+
+		res, ok := broker.serveRequest(&kafkaReader{data: data})
+		if !ok {
+			return
+		}
+
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(res))), res...)); err != nil {
+			return
+		}
+	}
+}
+
+// serveRequest returns the response of the request, ok is false if the request is not supported.
+func (broker *kafkaBroker) serveRequest(r *kafkaReader) ([]byte, bool) {
+	key, version, correlation := r.int16(), r.int16(), r.int32()
+
+	r.string() // client id
+
+	if r.err != nil {
+		return nil, false
+	}
+
+	res := new(kafkaWriter)
+
+	res.int32(correlation)
+
+	versions, found := kafkaVersions[key]
+	if !found {
+		return nil, false
+	}
+
+	if version < versions[0] || version > versions[1] {
+		if key != kafkaAPIVersions {
+			return nil, false
+		}
+
+		// clients retry with a supported version, the error response uses version 0
+		broker.apiVersions(res, 0, kafkaUnsupportedVersion)
+
+		return res.Bytes(), true
+	}
+
+	switch key {
+	case kafkaAPIVersions:
+		broker.apiVersions(res, version, kafkaNoError)
+	case kafkaMetadata:
+		broker.metadata(r, res, version)
+	case kafkaProduce:
+		broker.produce(r, res, version)
+	case kafkaFetch:
+		broker.fetch(r, res, version)
+	case kafkaListOffsets:
+		broker.listOffsets(r, res)
+	}
+
+	return res.Bytes(), r.err == nil
+}
+
+func (broker *kafkaBroker) apiVersions(res *kafkaWriter, version int16, code int16) {
+	res.int16(code)
+	res.int32(int32(len(kafkaVersions)))
+
+	for _, key := range []int16{kafkaProduce, kafkaFetch, kafkaListOffsets, kafkaMetadata, kafkaAPIVersions} {
+		res.int16(key).int16(kafkaVersions[key][0]).int16(kafkaVersions[key][1])
+	}
+
+	if version >= 1 {
+		res.int32(0) // throttle time
+	}
+}
+
+func (broker *kafkaBroker) metadata(r *kafkaReader, res *kafkaWriter, version int16) {
+	var names []string
+
+	all := !r.array(func() { names = append(names, r.string()) })
+
+	if version >= 3 {
+		res.int32(0) // throttle time
+	}
+
+	host, port, _ := net.SplitHostPort(broker.addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	res.int32(1).int32(kafkaNodeID).string(host).int32(int32(portNum)).nullString()
+
+	if version >= 2 {
+		res.nullString() // cluster id
+	}
+
+	res.int32(kafkaNodeID)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if all {
+		for name := range broker.topics {
+			names = append(names, name)
+		}
+	}
+
+	res.int32(int32(len(names)))
+
+	for _, name := range names {
+		topic := broker.topic(name)
+
+		res.int16(kafkaNoError).string(name).int8(0)
+		res.int32(int32(len(topic.partitions)))
+
+		for idx := range topic.partitions {
+			res.int16(kafkaNoError).int32(int32(idx)).int32(kafkaNodeID)
+			res.int32(1).int32(kafkaNodeID) // replicas
+			res.int32(1).int32(kafkaNodeID) // in-sync replicas
+		}
+	}
+}
+
+func (broker *kafkaBroker) produce(r *kafkaReader, res *kafkaWriter, version int16) {
+	r.string() // transactional id
+	r.int16()  // acks
+	r.int32()  // timeout
+
+	var produced []*kafkaRecord
+
+	topics := new(kafkaWriter)
+	count := int32(0)
+
+	r.array(func() {
+		name := r.string()
+
+		topics.string(name)
+
+		partitions := new(kafkaWriter)
+		pcount := int32(0)
+
+		r.array(func() {
+			index, data := r.int32(), r.bytes()
+
+			pcount++
+
+			broker.mu.Lock()
+
+			topic := broker.topic(name)
+			if index < 0 || int(index) >= len(topic.partitions) {
+				broker.mu.Unlock()
+				partitions.int32(index).int16(kafkaUnknownPartition).int64(-1).int64(-1)
+
+				if version >= 5 {
+					partitions.int64(-1)
+				}
+
+				return
+			}
+
+			base, records := topic.partitions[index].append(data)
+			broker.journal(name, index, records)
+			broker.mu.Unlock()
+
+			produced = append(produced, records...)
+
+			partitions.int32(index).int16(kafkaNoError).int64(base).int64(-1)
+
+			if version >= 5 {
+				partitions.int64(0) // log start offset
+			}
+		})
+
+		topics.int32(pcount)
+		topics.Write(partitions.Bytes()) // nolint:errcheck,gosec
+
+		count++
+	})
+
+	res.int32(count)
+	res.Write(topics.Bytes()) // nolint:errcheck,gosec
+	res.int32(0)              // throttle time
+
+	for _, rec := range produced {
+		broker.handle(rec)
+	}
+}
+
+type kafkaFetchPartition struct {
+	topic     string
+	partition int32
+	offset    int64
+	limit     int32
+}
+
+func (broker *kafkaBroker) fetch(r *kafkaReader, res *kafkaWriter, version int16) {
+	r.int32() // replica id
+
+	maxWait := time.Duration(r.int32()) * time.Millisecond
+
+	r.int32() // min bytes
+	r.int32() // max bytes
+	r.int8()  // isolation level
+
+	var requested []*kafkaFetchPartition
+
+	r.array(func() {
+		name := r.string()
+
+		r.array(func() {
+			part := &kafkaFetchPartition{topic: name, partition: r.int32(), offset: r.int64()}
+
+			if version >= 5 {
+				r.int64() // log start offset
+			}
+
+			part.limit = r.int32()
+			requested = append(requested, part)
+		})
+	})
+
+	if maxWait > kafkaMaxWait {
+		maxWait = kafkaMaxWait
+	}
+
+	broker.waitRecords(requested, maxWait)
+
+	res.int32(0) // throttle time
+	res.int32(int32(len(requested)))
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for _, part := range requested {
+		res.string(part.topic).int32(1).int32(part.partition)
+
+		topic := broker.topic(part.topic)
+		if part.partition < 0 || int(part.partition) >= len(topic.partitions) {
+			res.int16(kafkaUnknownPartition).int64(-1).int64(-1)
+
+			if version >= 5 {
+				res.int64(-1)
+			}
+
+			res.int32(0).bytes(nil)
+
+			continue
+		}
+
+		partition := topic.partitions[part.partition]
+
+		code := int16(kafkaNoError)
+		if part.offset > partition.next {
+			code = kafkaOffsetOutOfRange
+		}
+
+		res.int16(code).int64(partition.next).int64(partition.next)
+
+		if version >= 5 {
+			res.int64(0) // log start offset
+		}
+
+		res.int32(0) // aborted transactions
+		res.bytes(partition.read(part.offset, int(part.limit)))
+	}
+}
+
+// waitRecords waits until records are available for any of the requested partitions or the timeout elapses.
+func (broker *kafkaBroker) waitRecords(requested []*kafkaFetchPartition, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		broker.mu.Lock()
+
+		changed := broker.changed
+		available := false
+
+		for _, part := range requested {
+			topic := broker.topic(part.topic)
+			if part.partition >= 0 && int(part.partition) < len(topic.partitions) &&
+				topic.partitions[part.partition].next > part.offset {
+				available = true
+
+				break
+			}
+		}
+
+		broker.mu.Unlock()
+
+		if available || len(requested) == 0 {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+func (broker *kafkaBroker) listOffsets(r *kafkaReader, res *kafkaWriter) {
+	r.int32() // replica id
+
+	topics := new(kafkaWriter)
+	count := int32(0)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	r.array(func() {
+		name := r.string()
+		topic := broker.topic(name)
+
+		topics.string(name)
+
+		partitions := new(kafkaWriter)
+		pcount := int32(0)
+
+		r.array(func() {
+			index, timestamp := r.int32(), r.int64()
+
+			pcount++
+
+			if index < 0 || int(index) >= len(topic.partitions) {
+				partitions.int32(index).int16(kafkaUnknownPartition).int64(-1).int64(-1)
+
+				return
+			}
+
+			offset := topic.partitions[index].next
+			if timestamp == kafkaEarliestOffset {
+				offset = 0
+			}
+
+			partitions.int32(index).int16(kafkaNoError).int64(-1).int64(offset)
+		})
+
+		topics.int32(pcount)
+		topics.Write(partitions.Bytes()) // nolint:errcheck,gosec
+
+		count++
+	})
+
+	res.int32(count)
+	res.Write(topics.Bytes()) // nolint:errcheck,gosec
+}
+
+// kafka starts a Kafka broker mock of the target (e.g. kafka://kafka.example.com:9092).
+func (mod *Module) kafka(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+	broker := mod.newKafkaBroker(args)
+
+	return mod.startEndpoint(args, broker.endpoint, broker.serveConn)
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaBatch(t *testing.T) {
+	t.Parallel()
+
+	records := []*kafkaRecord{
+		{timestamp: 1000, key: []byte("order-1"), value: []byte("created")},
+		{timestamp: 1005, value: []byte("paid"), headers: []kafkaHeader{{key: "source", value: []byte("test")}}},
+	}
+
+	batch := encodeBatch(records, 0)
+
+	assert.Equal(t, int(binary.BigEndian.Uint32(batch[8:]))+12, len(batch))
+	assert.Equal(t, crc32.Checksum(batch[21:], kafkaCRC), binary.BigEndian.Uint32(batch[17:]))
+
+	partition := new(kafkaPartition)
+
+	partition.append(encodeBatch(records[:1], 0))
+
+	base, decoded := partition.append(batch)
+
+	assert.Equal(t, int64(1), base)
+	assert.Equal(t, int64(3), partition.next)
+	require.Len(t, decoded, 2)
+	assert.Equal(t, int64(2), decoded[1].offset)
+	assert.Equal(t, int64(1005), decoded[1].timestamp)
+	assert.Equal(t, "order-1", string(decoded[0].key))
+	assert.Nil(t, decoded[1].key)
+	assert.Equal(t, "test", string(decoded[1].headers[0].value))
+
+	assert.Len(t, partition.read(2, 1), len(batch))
+	assert.Empty(t, partition.read(3, 1024))
+}
+
+func TestKafkaMalformedBatch(t *testing.T) {
+	t.Parallel()
+
+	batch := encodeBatch([]*kafkaRecord{{timestamp: 1000, value: []byte("created")}}, 0)
+
+	short := append([]byte(nil), batch...)
+	binary.BigEndian.PutUint32(short[8:], 0)
+
+	partition := new(kafkaPartition)
+
+	assert.NotPanics(t, func() { partition.append(short) })
+	assert.Empty(t, partition.batches)
+
+	huge := append([]byte(nil), batch...)
+	binary.BigEndian.PutUint32(huge[57:], 0xffffffff)
+
+	assert.NotPanics(t, func() { decodeRecords(huge, 0) })
+
+	for _, data := range [][]byte{{0x00, 0x01}, {0x01, 0x00}, {0x7e, 0x00}} {
+		_, _, ok := decodeRecord(data)
+
+		assert.False(t, ok)
+	}
+}
+
+func kafkaRequest(t *testing.T, conn net.Conn, key, version int16, body *kafkaWriter) *kafkaReader {
+	t.Helper()
+
+	req := new(kafkaWriter)
+
+	req.int16(key).int16(version).int32(42).string("test")
+	req.Write(body.Bytes()) // nolint:errcheck
+
+	_, err := conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(req.Len())), req.Bytes()...))
+
+	require.NoError(t, err)
+
+	var size [4]byte
+
+	_, err = io.ReadFull(conn, size[:])
+
+	require.NoError(t, err)
+
+	data := make([]byte, binary.BigEndian.Uint32(size[:]))
+
+	_, err = io.ReadFull(conn, data)
+
+	require.NoError(t, err)
+
+	res := &kafkaReader{data: data}
+
+	assert.Equal(t, int32(42), res.int32())
+
+	return res
+}
+
+func TestKafkaBroker(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+const broker = mock.kafka("kafka://kafka.example.com:9092", broker => {
+	broker.topic("orders", { partitions: 2 })
+	broker.handle("orders", rec => ({ topic: "invoices", key: rec.key, value: rec.value.toUpperCase() }))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(helper.module.lookup["kafka://kafka.example.com:9092"], "kafka://"))
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	res := kafkaRequest(t, conn, kafkaAPIVersions, 3, new(kafkaWriter))
+
+	assert.Equal(t, int16(kafkaUnsupportedVersion), res.int16())
+
+	res = kafkaRequest(t, conn, kafkaMetadata, 1, new(kafkaWriter).int32(1).string("orders"))
+
+	assert.Equal(t, int32(1), res.int32())
+	assert.Equal(t, int32(kafkaNodeID), res.int32())
+	assert.Equal(t, "127.0.0.1", res.string())
+
+	produce := new(kafkaWriter)
+
+	produce.nullString().int16(1).int32(1000)
+	produce.int32(1).string("orders").int32(1).int32(1)
+	produce.bytes(encodeBatch([]*kafkaRecord{{timestamp: time.Now().UnixMilli(), value: []byte("created")}}, 0))
+
+	res = kafkaRequest(t, conn, kafkaProduce, 3, produce)
+
+	assert.Equal(t, int32(1), res.int32())
+	assert.Equal(t, "orders", res.string())
+	assert.Equal(t, int32(1), res.int32())
+	assert.Equal(t, int32(1), res.int32())
+	assert.Equal(t, int16(kafkaNoError), res.int16())
+	assert.Equal(t, int64(0), res.int64())
+
+	fetch := new(kafkaWriter)
+
+	fetch.int32(-1).int32(100).int32(1).int32(1 << 20).int8(0)
+	fetch.int32(1).string("invoices").int32(1).int32(0).int64(0).int32(1 << 20)
+
+	res = kafkaRequest(t, conn, kafkaFetch, 4, fetch)
+
+	res.int32() // throttle time
+
+	assert.Equal(t, int32(1), res.int32())
+	assert.Equal(t, "invoices", res.string())
+	assert.Equal(t, int32(1), res.int32())
+	assert.Equal(t, int32(0), res.int32())
+	assert.Equal(t, int16(kafkaNoError), res.int16())
+	assert.Equal(t, int64(1), res.int64())
+
+	res.int64() // last stable offset
+	res.int32() // aborted transactions
+
+	records := decodeRecords(res.bytes(), 0)
+
+	require.Len(t, records, 1)
+	assert.Equal(t, "CREATED", string(records[0].value))
+}
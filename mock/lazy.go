@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+// ready starts the application of a lazy mock server on the first request.
+// It reports whether the application is running.
+func (srv *server) ready() bool {
+	srv.once.Do(func() {
+		if srv.pending == nil {
+			return
+		}
+
+		if err := srv.onEventLoop(srv.pending); err != nil {
+			srv.mod.logger.WithError(err).WithField("target", srv.target).Error("mock server not started")
+		}
+	})
+
+	return srv.proxy != nil
+}
+
+// onEventLoop runs fn on the VU's event loop and waits for its completion.
+func (srv *server) onEventLoop(fn func() error) error {
+	return onEventLoop(srv.mod.vu, srv.opts.sync, fn)
+}
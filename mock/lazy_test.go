@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyMock(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+var started = false
+
+mock("https://example.com", app => {
+	started = true
+	app.get("/", (req, res) => res.text("Hello World!"))
+}, { sync: true, lazy: true })
+`)
+
+	require.NoError(t, err)
+
+	require.Contains(t, helper.module.lookup, "https://example.com")
+	assert.False(t, helper.vu.Runtime().Get("started").ToBoolean())
+
+	res, err := req.Get(helper.module.lookup["https://example.com"])
+
+	require.NoError(t, err)
+
+	body, err := res.ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, "Hello World!", body)
+	assert.True(t, helper.vu.Runtime().Get("started").ToBoolean())
+
+	helper.module.unmock(helper.vu.Runtime().ToValue("https://example.com"))
+
+	assert.Empty(t, helper.module.servers)
+}
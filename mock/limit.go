@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// concurrency limits the in-flight requests of a route, simulating thread pool limited upstreams.
+// Excess requests are queued (up to queue length, waiting at most timeout) or rejected with 503.
+type concurrency struct {
+	limit   int
+	queue   int
+	timeout time.Duration
+	slots   chan struct{}
+
+	mu        sync.Mutex
+	queued    int
+	maxQueued int
+	rejected  int
+	served    int
+}
+
+// getConcurrency parses the concurrency limit: the number of in-flight requests (excess is rejected)
+// or an object with limit, queue and timeout properties.
+func getConcurrency(value interface{}) *concurrency {
+	var limit *concurrency
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		limit = &concurrency{
+			limit:   intKeyword(val, "limit", 0),
+			queue:   intKeyword(val, "queue", 0),
+			timeout: duration(val["timeout"]),
+		}
+	default:
+		num, _ := toFloat(val)
+		limit = &concurrency{limit: int(num)}
+	}
+
+	if limit.limit <= 0 {
+		return nil
+	}
+
+	limit.slots = make(chan struct{}, limit.limit)
+
+	return limit
+}
+
+// acquire takes an in-flight slot, false is returned if the request was rejected.
+func (c *concurrency) acquire(ctx context.Context) bool {
+	select {
+	case c.slots <- struct{}{}:
+		c.count(0)
+
+		return true
+	default:
+	}
+
+	c.mu.Lock()
+
+	if c.queued >= c.queue {
+		c.rejected++
+		c.mu.Unlock()
+
+		return false
+	}
+
+	c.queued++
+
+	if c.queued > c.maxQueued {
+		c.maxQueued = c.queued
+	}
+
+	c.mu.Unlock()
+
+	var expired <-chan time.Time
+
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		c.count(-1)
+
+		return true
+	case <-expired:
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	c.queued--
+	c.rejected++
+	c.mu.Unlock()
+
+	return false
+}
+
+func (c *concurrency) count(queued int) {
+	c.mu.Lock()
+	c.queued += queued
+	c.served++
+	c.mu.Unlock()
+}
+
+func (c *concurrency) release() {
+	<-c.slots
+}
+
+func (c *concurrency) export() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return map[string]interface{}{
+		"limit":     c.limit,
+		"inFlight":  len(c.slots),
+		"queued":    c.queued,
+		"maxQueued": c.maxQueued,
+		"served":    c.served,
+		"rejected":  c.rejected,
+	}
+}
+
+// reject responds to the request exceeding the concurrency limit of the route.
+func reject(w http.ResponseWriter, c *concurrency) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "concurrency limit ("+strconv.Itoa(c.limit)+") exceeded", http.StatusServiceUnavailable)
+}
+
+// limits returns the concurrency statistics of the limited routes.
+func (srv *server) limits() []interface{} {
+	out := make([]interface{}, 0)
+
+	srv.router.each(func(r *route) {
+		if r.limit == nil {
+			return
+		}
+
+		stats := r.limit.export()
+
+		stats["method"] = r.method
+		stats["route"] = r.pattern
+
+		out = append(out, stats)
+	})
+
+	return out
+}
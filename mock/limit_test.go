@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConcurrency(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getConcurrency(nil))
+	assert.Nil(t, getConcurrency(int64(0)))
+
+	limit := getConcurrency(int64(4))
+
+	require.NotNil(t, limit)
+	assert.Equal(t, 4, limit.limit)
+	assert.Equal(t, 4, cap(limit.slots))
+
+	limit = getConcurrency(map[string]interface{}{"limit": 2.0, "queue": int64(10), "timeout": "1s"})
+
+	require.NotNil(t, limit)
+	assert.Equal(t, 2, limit.limit)
+	assert.Equal(t, 10, limit.queue)
+	assert.Equal(t, time.Second, limit.timeout)
+}
+
+func TestConcurrency(t *testing.T) {
+	t.Parallel()
+
+	limit := getConcurrency(map[string]interface{}{"limit": 1.0, "queue": 1.0, "timeout": 500.0})
+	ctx := context.Background()
+
+	require.True(t, limit.acquire(ctx))
+
+	acquired := make(chan bool)
+
+	go func() { acquired <- limit.acquire(ctx) }()
+
+	assert.Eventually(t, func() bool { return limit.export()["queued"] == 1 }, time.Second, time.Millisecond)
+
+	assert.False(t, limit.acquire(ctx)) // queue is full
+
+	limit.release()
+
+	assert.True(t, <-acquired)
+
+	stats := limit.export()
+
+	assert.Equal(t, 1, stats["inFlight"])
+	assert.Equal(t, 0, stats["queued"])
+	assert.Equal(t, 1, stats["maxQueued"])
+	assert.Equal(t, 2, stats["served"])
+	assert.Equal(t, 1, stats["rejected"])
+
+	w := httptest.NewRecorder()
+
+	reject(w, limit)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
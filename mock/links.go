@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"mime"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	linksToMock   = "mock"
+	linksToOrigin = "origin"
+)
+
+// linkHeaders contains the response headers holding URLs to be rewritten.
+var linkHeaders = []string{"Location", "Content-Location", "Link"}
+
+// linkOptions contains the URL rewriting options of a mock server.
+type linkOptions struct {
+	origin  bool     // rewrite the mock's URLs back to the original origin
+	origins []string // additional origins served by the mock
+}
+
+// getLinks parses the rewriteURLs option. It is either a boolean, the direction of the
+// rewriting ("mock" or "origin") or an object with to and origins properties.
+func getLinks(value sobek.Value) *linkOptions {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		switch value.String() {
+		case linksToOrigin:
+			return &linkOptions{origin: true}
+		case linksToMock:
+			return new(linkOptions)
+		}
+
+		if !value.ToBoolean() {
+			return nil
+		}
+
+		return new(linkOptions)
+	}
+
+	opts := new(linkOptions)
+
+	if v := obj.Get("to"); v != nil && !sobek.IsUndefined(v) {
+		opts.origin = v.String() == linksToOrigin
+	}
+
+	if v := obj.Get("origins"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		if list, ok := v.Export().([]interface{}); ok {
+			for _, item := range list {
+				if origin, ok := item.(string); ok {
+					opts.origins = append(opts.origins, origin)
+				}
+			}
+		}
+	}
+
+	return opts
+}
+
+type linkRule struct {
+	pattern *regexp.Regexp
+	to      string
+}
+
+// linkRewriter rewrites absolute URLs of the given locations in response bodies and headers.
+// Only whole origins (or path prefixes) are rewritten, the rest of the URL is kept as is.
+type linkRewriter struct {
+	rules []*linkRule
+}
+
+// newLinkRewriter creates a rewriter replacing the keys of the mapping with the corresponding values.
+// Longer locations take precedence, JSON escaped forms of the locations are rewritten as well.
+func newLinkRewriter(mapping map[string]string) *linkRewriter {
+	from := make([]string, 0, len(mapping))
+
+	for loc := range mapping {
+		from = append(from, loc)
+	}
+
+	sort.Slice(from, func(i, j int) bool { return len(from[i]) > len(from[j]) })
+
+	rewriter := new(linkRewriter)
+
+	for _, loc := range from {
+		to := strings.TrimSuffix(mapping[loc], "/")
+		loc = strings.TrimSuffix(loc, "/")
+
+		rewriter.add(loc, to)
+
+		if escaped := strings.ReplaceAll(loc, "/", `\/`); escaped != loc {
+			rewriter.add(escaped, strings.ReplaceAll(to, "/", `\/`))
+		}
+	}
+
+	return rewriter
+}
+
+func (lr *linkRewriter) add(from, to string) {
+	// the location must not continue with host name characters (or a port)
+	pattern := regexp.MustCompile(regexp.QuoteMeta(from) + `([^A-Za-z0-9.:\-]|$)`)
+
+	lr.rules = append(lr.rules, &linkRule{pattern: pattern, to: to})
+}
+
+func (lr *linkRewriter) rewrite(data []byte) []byte {
+	for _, rule := range lr.rules {
+		data = rule.pattern.ReplaceAllFunc(data, func(match []byte) []byte {
+			sub := rule.pattern.FindSubmatch(match)
+
+			return append([]byte(rule.to), sub[1]...)
+		})
+	}
+
+	return data
+}
+
+// rewriteResponse rewrites the URLs found in the JSON or HTML body and in the link headers of the response.
+func (lr *linkRewriter) rewriteResponse(res *response) {
+	for _, name := range linkHeaders {
+		values := res.header.Values(name)
+
+		for idx, value := range values {
+			values[idx] = string(lr.rewrite([]byte(value)))
+		}
+	}
+
+	if len(res.header.Get("Content-Encoding")) != 0 || !isLinkable(res.header.Get("Content-Type")) {
+		return
+	}
+
+	body := lr.rewrite(res.body.Bytes())
+
+	res.body.Reset()
+	res.body.Write(body)
+}
+
+// isLinkable reports whether the body with the given content type may contain rewritable URLs.
+func isLinkable(contentType string) bool {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return media == "application/json" || strings.HasSuffix(media, "+json") ||
+		media == "text/html" || media == "application/xhtml+xml"
+}
+
+// linkMapping returns the locations to be rewritten by the server, in the direction of the options.
+func (srv *server) linkMapping() map[string]string {
+	mapping := map[string]string{srv.target: srv.baseURL()}
+
+	for _, origin := range srv.opts.links.origins {
+		mapping[origin] = srv.baseURL()
+	}
+
+	for target, addr := range srv.regionTargets() {
+		mapping[target] = addr
+	}
+
+	if !srv.opts.links.origin {
+		return mapping
+	}
+
+	reversed := make(map[string]string, len(mapping))
+
+	for origin, addr := range mapping {
+		if _, found := reversed[addr]; !found || origin == srv.target {
+			reversed[addr] = origin
+		}
+	}
+
+	return reversed
+}
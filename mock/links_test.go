@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkRewriter(t *testing.T) {
+	t.Parallel()
+
+	rewriter := newLinkRewriter(map[string]string{
+		"https://example.com":        "http://127.0.0.1:8000",
+		"https://example.com/eu/":    "http://127.0.0.1:8000/__region__/eu",
+		"https://cdn.example.com":    "http://127.0.0.1:8000",
+		"https://unrelated.test:443": "http://127.0.0.1:9000",
+	})
+
+	body := `{"next":"https://example.com/items?page=2","eu":"https://example.com/eu/items",` +
+		`"escaped":"https:\/\/example.com\/items","other":"https://example.com.evil/items",` +
+		`"port":"https://example.com:8443/items","cdn":"https://cdn.example.com"}`
+
+	assert.JSONEq(t, `{"next":"http://127.0.0.1:8000/items?page=2","eu":"http://127.0.0.1:8000/__region__/eu/items",`+
+		`"escaped":"http://127.0.0.1:8000/items","other":"https://example.com.evil/items",`+
+		`"port":"https://example.com:8443/items","cdn":"http://127.0.0.1:8000"}`,
+		string(rewriter.rewrite([]byte(body))))
+
+	res := newResponse()
+
+	res.header.Set("Content-Type", "text/html; charset=utf-8")
+	res.header.Set("Location", "https://example.com/login")
+	res.body.WriteString(`<a href="https://example.com/about">About</a>`)
+
+	rewriter.rewriteResponse(res)
+
+	assert.Equal(t, "http://127.0.0.1:8000/login", res.header.Get("Location"))
+	assert.Equal(t, `<a href="http://127.0.0.1:8000/about">About</a>`, res.body.String())
+
+	res = newResponse()
+
+	res.header.Set("Content-Type", "text/plain")
+	res.body.WriteString("https://example.com/about")
+
+	rewriter.rewriteResponse(res)
+
+	assert.Equal(t, "https://example.com/about", res.body.String())
+
+	res = newResponse()
+
+	res.header.Set("Content-Type", "application/json")
+	res.header.Set("Content-Encoding", "gzip")
+	res.body.WriteString(`"https://example.com"`)
+
+	rewriter.rewriteResponse(res)
+
+	assert.Equal(t, `"https://example.com"`, res.body.String())
+}
+
+func TestIsLinkable(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isLinkable("application/json"))
+	assert.True(t, isLinkable("application/hal+json"))
+	assert.True(t, isLinkable("text/html; charset=utf-8"))
+	assert.False(t, isLinkable("text/plain"))
+	assert.False(t, isLinkable(""))
+	assert.False(t, isLinkable(http.DetectContentType([]byte{0})))
+}
@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// condition is a request content matcher of a route, the route is a candidate only if all of its
+// conditions are met.
+type condition struct {
+	body bool // the condition needs the request body
+	test func(r *http.Request, body []byte) bool
+}
+
+// headerCondition matches if the header is present, with the value if given.
+func headerCondition(name string, value ...string) condition {
+	return condition{test: func(r *http.Request, _ []byte) bool {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+
+		return ok && (len(value) == 0 || contains(values, value[0]))
+	}}
+}
+
+// queryCondition matches if the query parameter is present, with the value if given.
+func queryCondition(name string, value ...string) condition {
+	return condition{test: func(r *http.Request, _ []byte) bool {
+		values, ok := r.URL.Query()[name]
+
+		return ok && (len(value) == 0 || contains(values, value[0]))
+	}}
+}
+
+// bodyCondition matches if the request body contains the string.
+func bodyCondition(part string) condition {
+	return condition{body: true, test: func(_ *http.Request, body []byte) bool {
+		return strings.Contains(string(body), part)
+	}}
+}
+
+// jsonCondition matches if the request body is JSON containing the expected value (see jsonSubset). The
+// encoded fields (e.g. base64 JSON inside JSON) are decoded where an object or array is expected.
+func jsonCondition(expected interface{}) condition {
+	expected = normalizeJSON(expected)
+
+	return condition{body: true, test: func(_ *http.Request, body []byte) bool {
+		var actual interface{}
+
+		if err := json.Unmarshal(body, &actual); err != nil {
+			return false
+		}
+
+		return encodedSubset(expected, actual)
+	}}
+}
+
+// pathCondition matches if the request path matches the path pattern.
+func pathCondition(pattern string) condition {
+	matcher := newPathMatcher(pattern)
+
+	return condition{test: func(r *http.Request, _ []byte) bool {
+		return matcher.matches(r.URL.Path)
+	}}
+}
+
+// negate returns the condition met if the given one is not met, e.g. the header is absent.
+func negate(cond condition) condition {
+	return condition{body: cond.body, test: func(r *http.Request, body []byte) bool {
+		return !cond.test(r, body)
+	}}
+}
+
+// conditional returns the routes whose conditions are met by the request. The body is read only if
+// a condition needs it, the conditions are evaluated on the decoded body (see decodeBody).
+func (srv *server) conditional(routes []*route, r *http.Request) []*route {
+	var (
+		body   []byte
+		read   bool
+		passed []*route
+	)
+
+	for _, candidate := range routes {
+		ok := true
+
+		for _, cond := range candidate.conditions {
+			if cond.body && !read {
+				body, read = decodeBody(r.Header, readBody(r)), true
+			}
+
+			if !cond.test(r, body) {
+				ok = false
+
+				break
+			}
+		}
+
+		if ok {
+			passed = append(passed, candidate)
+		}
+	}
+
+	return passed
+}
+
+// routeObject returns the object returned by the route definition methods. It inherits from the
+// application object, so definitions can be chained, and adds the request matchers of the route.
+// The without matchers are the negations of the with matchers.
+func (srv *server) routeObject(r *route) *sobek.Object {
+	obj := srv.mod.runtime().CreateObject(srv.app)
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	when := func(cond condition) *sobek.Object {
+		srv.router.reorder(r, func(r *route) { r.conditions = append(r.conditions, cond) })
+
+		return obj
+	}
+
+	mustSet("withHeader", func(name string, value ...string) *sobek.Object {
+		return when(headerCondition(name, value...))
+	})
+
+	mustSet("withQuery", func(name string, value ...string) *sobek.Object {
+		return when(queryCondition(name, value...))
+	})
+
+	mustSet("withBody", func(part string) *sobek.Object {
+		return when(bodyCondition(part))
+	})
+
+	mustSet("withBodyJson", func(expected sobek.Value) *sobek.Object {
+		return when(jsonCondition(expected.Export()))
+	})
+
+	mustSet("withoutHeader", func(name string, value ...string) *sobek.Object {
+		return when(negate(headerCondition(name, value...)))
+	})
+
+	mustSet("withoutQuery", func(name string, value ...string) *sobek.Object {
+		return when(negate(queryCondition(name, value...)))
+	})
+
+	mustSet("withoutBody", func(part string) *sobek.Object {
+		return when(negate(bodyCondition(part)))
+	})
+
+	mustSet("withoutPath", func(pattern string) *sobek.Object {
+		if err := checkPath(pattern); err != nil {
+			srv.mod.throwf("invalid parameter constraint of withoutPath: %s", errInvalidArg, err)
+		}
+
+		return when(negate(pathCondition(pattern)))
+	})
+
+	if r.step != nil {
+		mustSet("transitionTo", func(next string) *sobek.Object {
+			srv.router.update(r, func(r *route) { r.step.next = next })
+
+			return obj
+		})
+	}
+
+	return obj
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditions(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodPost, "/items?page=2", strings.NewReader(`{"type":"gold","qty":2}`))
+	req.Header.Set("X-Tenant", "acme")
+
+	body := []byte(`{"type":"gold","qty":2}`)
+
+	assert.True(t, headerCondition("X-Tenant").test(req, body))
+	assert.True(t, headerCondition("x-tenant", "acme").test(req, body))
+	assert.False(t, headerCondition("X-Tenant", "other").test(req, body))
+	assert.True(t, queryCondition("page", "2").test(req, body))
+	assert.False(t, queryCondition("size").test(req, body))
+	assert.True(t, bodyCondition("gold").test(req, body))
+	assert.True(t, jsonCondition(map[string]interface{}{"type": "gold", "qty": int64(2)}).test(req, body))
+	assert.False(t, jsonCondition(map[string]interface{}{"type": "silver"}).test(req, body))
+	assert.False(t, jsonCondition(map[string]interface{}{"type": "gold"}).test(req, []byte("gold")))
+}
+
+func TestNegatedConditions(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42?page=2", nil)
+	req.Header.Set("X-Tenant", "acme")
+
+	assert.True(t, negate(headerCondition("Authorization")).test(req, nil))
+	assert.False(t, negate(headerCondition("X-Tenant")).test(req, nil))
+	assert.True(t, negate(headerCondition("X-Tenant", "other")).test(req, nil))
+	assert.True(t, negate(queryCondition("page", "3")).test(req, nil))
+	assert.False(t, negate(queryCondition("page")).test(req, nil))
+	assert.True(t, negate(pathCondition("/health")).test(req, nil))
+	assert.False(t, negate(pathCondition("/users/:id")).test(req, nil))
+	assert.True(t, negate(bodyCondition("gold")).body)
+
+	srv := &server{router: newRouter()}
+
+	user := srv.router.add(http.MethodGet, "/users/:id", nil)
+	denied := srv.router.add(http.MethodGet, "/*", nil)
+
+	srv.router.reorder(denied, func(r *route) {
+		r.priority = 10
+		r.conditions = append(r.conditions, negate(headerCondition("Authorization")), negate(pathCondition("/health")))
+	})
+
+	candidates := srv.router.candidates(http.MethodGet, "/users/42", selector{})
+
+	assert.Equal(t, []*route{denied, user}, srv.conditional(candidates, req))
+
+	req.Header.Set("Authorization", "Bearer token")
+
+	assert.Equal(t, []*route{user}, srv.conditional(candidates, req))
+}
+
+func TestConditional(t *testing.T) {
+	t.Parallel()
+
+	srv := &server{router: newRouter()}
+
+	generic := srv.router.add(http.MethodPost, "/items", nil)
+	gold := srv.router.add(http.MethodPost, "/items", nil)
+	acme := srv.router.add(http.MethodPost, "/items", nil)
+
+	srv.router.reorder(gold, func(r *route) {
+		r.conditions = append(r.conditions, jsonCondition(map[string]interface{}{"type": "gold"}))
+	})
+	srv.router.reorder(acme, func(r *route) { r.conditions = append(r.conditions, headerCondition("X-Tenant", "acme")) })
+
+	newRequest := func(tenant, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+		req.Header.Set("X-Tenant", tenant)
+
+		return req
+	}
+
+	candidates := srv.router.candidates(http.MethodPost, "/items", selector{})
+
+	assert.Equal(t, []*route{gold, acme, generic}, candidates)
+
+	req := newRequest("acme", `{"type":"gold"}`)
+
+	assert.Equal(t, []*route{gold, acme, generic}, srv.conditional(candidates, req))
+	assert.Equal(t, `{"type":"gold"}`, string(readBody(req)))
+
+	assert.Equal(t, []*route{acme, generic}, srv.conditional(candidates, newRequest("acme", `{"type":"silver"}`)))
+	assert.Equal(t, []*route{generic}, srv.conditional(candidates, newRequest("other", "")))
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"strconv"
+	"time"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/metrics"
+)
+
+type mockMetrics struct {
+	requests      *metrics.Metric
+	duration      *metrics.Metric
+	unmatched     *metrics.Metric
+	handlerErrors *metrics.Metric
+	retries       *metrics.Metric
+	retryInterval *metrics.Metric
+	retryStorms   *metrics.Metric
+
+	conns            *metrics.Metric
+	connDuration     *metrics.Metric
+	connRequests     *metrics.Metric
+	connDataReceived *metrics.Metric
+	connDataSent     *metrics.Metric
+
+	webhookAttempts   *metrics.Metric
+	webhookDeliveries *metrics.Metric
+	webhookDuration   *metrics.Metric
+
+	cpu        *metrics.Metric
+	heap       *metrics.Metric
+	goroutines *metrics.Metric
+	inflight   *metrics.Metric
+	overload   *metrics.Metric
+}
+
+func newMetrics(vu modules.VU) *mockMetrics { // nolint:varnamelen
+	m := new(mockMetrics)
+
+	if vu.InitEnv() == nil || vu.InitEnv().Registry == nil {
+		return m
+	}
+
+	registry := vu.InitEnv().Registry
+
+	mustNewMetric := func(name string, typ metrics.MetricType, vt ...metrics.ValueType) *metrics.Metric {
+		metric, err := registry.NewMetric(name, typ, vt...)
+		if err != nil {
+			common.Throw(vu.Runtime(), err)
+		}
+
+		return metric
+	}
+
+	m.requests = mustNewMetric("mock_reqs", metrics.Counter)
+	m.duration = mustNewMetric("mock_req_duration", metrics.Trend, metrics.Time)
+	m.unmatched = mustNewMetric("mock_unmatched_reqs", metrics.Counter)
+	m.handlerErrors = mustNewMetric("mock_handler_errors", metrics.Counter)
+	m.retries = mustNewMetric("mock_retries", metrics.Counter)
+	m.retryInterval = mustNewMetric("mock_retry_interval", metrics.Trend, metrics.Time)
+	m.retryStorms = mustNewMetric("mock_retry_storms", metrics.Counter)
+	m.conns = mustNewMetric("mock_conns", metrics.Counter)
+	m.connDuration = mustNewMetric("mock_conn_duration", metrics.Trend, metrics.Time)
+	m.connRequests = mustNewMetric("mock_conn_reqs", metrics.Trend)
+	m.connDataReceived = mustNewMetric("mock_conn_data_received", metrics.Counter, metrics.Data)
+	m.connDataSent = mustNewMetric("mock_conn_data_sent", metrics.Counter, metrics.Data)
+	m.webhookAttempts = mustNewMetric("mock_webhook_attempts", metrics.Counter)
+	m.webhookDeliveries = mustNewMetric("mock_webhook_deliveries", metrics.Counter)
+	m.webhookDuration = mustNewMetric("mock_webhook_duration", metrics.Trend, metrics.Time)
+	m.cpu = mustNewMetric("mock_cpu", metrics.Gauge)
+	m.heap = mustNewMetric("mock_heap", metrics.Gauge, metrics.Data)
+	m.goroutines = mustNewMetric("mock_goroutines", metrics.Gauge)
+	m.inflight = mustNewMetric("mock_inflight_reqs", metrics.Gauge)
+	m.overload = mustNewMetric("mock_overload", metrics.Rate)
+
+	return m
+}
+
+// push emits a metric sample tagged with the current VU tags. It is safe to call from
+// the server's goroutines, samples are silently dropped outside of the VU context.
+func (mod *Module) push(metric *metrics.Metric, value float64, tags map[string]string) {
+	mod.pushWithMetadata(metric, value, tags, nil)
+}
+
+// pushWithMetadata emits a sample with metadata, high cardinality values (e.g. request IDs) are passed
+// as metadata instead of tags.
+func (mod *Module) pushWithMetadata(metric *metrics.Metric, value float64, tags, metadata map[string]string) {
+	state := mod.vu.State()
+	if metric == nil || state == nil || state.Samples == nil {
+		return
+	}
+
+	tagSet := state.Tags.GetCurrentValues().Tags
+
+	for k, v := range tags {
+		tagSet = tagSet.With(k, v)
+	}
+
+	metrics.PushIfNotDone(mod.vu.Context(), state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tagSet},
+		Time:       time.Now(),
+		Value:      value,
+		Metadata:   metadata,
+	})
+}
+
+// observe emits the request metrics of a recorded exchange.
+func (srv *server) observe(record *entry) {
+	tags := map[string]string{
+		"mock":   srv.target,
+		"method": record.method,
+		"status": strconv.Itoa(record.status),
+	}
+
+	if len(record.route) != 0 {
+		tags["route"] = record.route
+	}
+
+	var metadata map[string]string
+
+	if len(record.correlation) != 0 {
+		metadata = map[string]string{"request_id": record.correlation}
+	}
+
+	srv.mod.pushWithMetadata(srv.mod.metrics.requests, 1, tags, metadata)
+	srv.mod.pushWithMetadata(srv.mod.metrics.duration, metrics.D(record.duration), tags, metadata)
+}
@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.k6.io/k6/js/modulestest"
+)
+
+func TestNewMetrics(t *testing.T) {
+	t.Parallel()
+
+	vu := modulestest.NewRuntime(t).VU // nolint:varnamelen
+
+	m := newMetrics(vu)
+
+	assert.Equal(t, "mock_reqs", m.requests.Name)
+	assert.Equal(t, "mock_req_duration", m.duration.Name)
+	assert.Equal(t, "mock_unmatched_reqs", m.unmatched.Name)
+	assert.Equal(t, "mock_handler_errors", m.handlerErrors.Name)
+
+	vu.InitEnvField = nil
+
+	m = newMetrics(vu)
+
+	assert.Nil(t, m.requests)
+}
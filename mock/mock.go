@@ -5,6 +5,7 @@
 package mock
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 
@@ -13,7 +14,8 @@ import (
 
 func (mod *Module) skipMock() bool {
 	if v := mod.runtime().Get("__VU"); v == nil || v.ToInteger() == 0 {
-		return true
+		// setup() and teardown() run with __VU 0 as well, mocks are skipped only in the init context
+		return mod.vu.State() == nil
 	}
 
 	return false
@@ -23,6 +25,8 @@ type mockArgs struct {
 	target   string
 	callback sobek.Callable
 	options  *options
+	object   *sobek.Object // the options object
+	stubs    []*stub
 }
 
 func (mod *Module) newMockArgs(call sobek.FunctionCall) *mockArgs {
@@ -37,6 +41,7 @@ func (mod *Module) newMockArgs(call sobek.FunctionCall) *mockArgs {
 
 		if obj, isObj := call.Argument(idx).(*sobek.Object); isObj {
 			args.options = getopts(obj)
+			args.object = obj
 
 			continue
 		}
@@ -62,83 +67,268 @@ func (mod *Module) newMockArgs(call sobek.FunctionCall) *mockArgs {
 }
 
 func (mod *Module) mock(call sobek.FunctionCall) sobek.Value {
-	if mod.skipMock() {
+	if mod.skipMock() && !isDeferred(call.Arguments) {
 		return sobek.Undefined()
 	}
 
-	args := mod.newMockArgs(call)
+	return mod.define(mod.newMockArgs(call))
+}
 
-	if args.options.skip {
+// load starts a mock server defined by a stub file. The optional callback may define additional routes,
+// routes take precedence over stubs.
+func (mod *Module) load(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() && !isDeferred(call.Arguments) {
 		return sobek.Undefined()
 	}
 
-	app, listen := mod.newApplication(args.options.sync)
+	filename := call.Argument(0).String()
 
-	_, err := args.callback(mod.runtime().GlobalObject(), app)
+	file, err := readStubFile(filename)
 	if err != nil {
 		mod.throw(err)
 	}
 
-	_, err = listen(app)
+	if len(file.Target) == 0 {
+		mod.throwf("missing target in stub file %s", errInvalidArg, filename)
+	}
+
+	args := &mockArgs{target: file.Target, options: new(options), stubs: file.Stubs}
+
+	for idx := 1; idx < len(call.Arguments); idx++ {
+		arg := call.Argument(idx)
+
+		if c, isFunc := sobek.AssertFunction(arg); isFunc {
+			args.callback = c
+		} else if obj, isObj := arg.(*sobek.Object); isObj {
+			args.options = getopts(obj)
+			args.object = obj
+		}
+	}
+
+	if args.callback == nil {
+		args.callback = func(sobek.Value, ...sobek.Value) (sobek.Value, error) { return sobek.Undefined(), nil }
+	}
+
+	return mod.define(args)
+}
+
+func (mod *Module) start(args *mockArgs) sobek.Value {
+	srv := mod.newMockServer(args)
+	if srv == nil {
+		return sobek.Undefined()
+	}
+
+	mod.servers[args.target] = srv
+	mod.lookup[args.target] = srv.baseURL()
+	mod.registry.add(srv)
+
+	for target, addr := range srv.regionTargets() {
+		mod.lookup[target] = addr
+	}
+
+	mod.exportEnv(args, mod.lookup[args.target])
+
+	return srv.app
+}
+
+// newMockServer creates and starts the mock server of the definition, nil is returned if it was not started.
+func (mod *Module) newMockServer(args *mockArgs) *server {
+	if args.options.skip {
+		return nil
+	}
+
+	app, listen := mod.newApplication(args.options.sync)
+	srv := mod.newServer(args.target, app, args.options)
+	srv.stubs = args.stubs
+
+	for _, filename := range args.options.stubFiles {
+		file, err := readStubFile(filename)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		srv.stubs = append(srv.stubs, file.Stubs...)
+	}
+
+	schema, err := loadProto(args.options.protoFiles)
 	if err != nil {
 		mod.throw(err)
 	}
 
-	addr := app.Get("host")
-	if addr == nil || len(addr.String()) == 0 {
-		mod.logger.WithField("target", args.target).Warn("mock server not started")
+	srv.proto = schema
 
-		return sobek.Undefined()
+	srv.wrapApplication()
+
+	if args.options.lazy {
+		srv.pending = func() error { return srv.launch(args.callback, listen) }
+	} else if err := srv.launch(args.callback, listen); err != nil {
+		if errors.Is(err, errNotStarted) {
+			mod.logger.WithField("target", args.target).Warn("mock server not started")
+
+			return nil
+		}
+
+		mod.throw(err)
 	}
 
-	mod.apps[args.target] = app
-	mod.lookup[args.target] = "http://" + addr.String()
+	if err := srv.bind(); err != nil {
+		mod.throw(err)
+	}
 
-	return sobek.Undefined()
+	return srv
 }
 
 func (mod *Module) mockWithSkip() sobek.Value {
 	function := mod.runtime().ToValue(mod.mock).(*sobek.Object) // nolint:forcetypeassert
 
 	function.Set("skip", func(_ sobek.FunctionCall) sobek.Value { return sobek.Undefined() }) // nolint:errcheck
+	function.Set("expect", mod.expect)                                                        // nolint:errcheck
+	function.Set("load", mod.load)                                                            // nolint:errcheck
+	function.Set("fromOpenAPI", mod.fromOpenAPI)                                              // nolint:errcheck
+	function.Set("https", mod.https)                                                          // nolint:errcheck
+	function.Set("intercept", mod.intercept)                                                  // nolint:errcheck
+	function.Set("start", mod.startMock)                                                      // nolint:errcheck
+	function.Set("service", mod.service)                                                      // nolint:errcheck
+	function.Set("services", mod.loadServices)                                                // nolint:errcheck
+	function.Set("handle", mod.handle)                                                        // nolint:errcheck
+	function.Set("attach", mod.attach)                                                        // nolint:errcheck
+	function.Set("stop", mod.stop)                                                            // nolint:errcheck
+	function.Set("amqp", mod.amqp)                                                            // nolint:errcheck
+	function.Set("mqtt", mod.mqtt)                                                            // nolint:errcheck
+	function.Set("kafka", mod.kafka)                                                          // nolint:errcheck
+	function.Set("redis", mod.redis)                                                          // nolint:errcheck
+	function.Set("tcp", mod.tcp)                                                              // nolint:errcheck
+	function.Set("udp", mod.udp)                                                              // nolint:errcheck
+	function.Set("websockets", mod.websockets)                                                // nolint:errcheck
+	function.Set("wrap", mod.wrapModule)                                                      // nolint:errcheck
+	function.Set("unmatched", mod.unmatched)                                                  // nolint:errcheck
+	function.Set("grpc", mod.grpc)                                                            // nolint:errcheck
+	function.Set("decode", mod.decode)                                                        // nolint:errcheck
+	function.Set("encode", mod.encode)                                                        // nolint:errcheck
+	function.Set("generate", mod.generate)                                                    // nolint:errcheck
+	function.Set("sequence", mod.sequence)                                                    // nolint:errcheck
+	function.Set("boundaries", mod.boundaries)                                                // nolint:errcheck
+	function.Set("summary", mod.stats.export)                                                 // nolint:errcheck
+	function.Set("textSummary", mod.stats.text)                                               // nolint:errcheck
 
 	return function
 }
 
 func (mod *Module) unmock(target sobek.Value) {
+	key := target.String()
+
+	delete(mod.deferred, key)
+
+	mod.unmockProfiles(key)
+
 	if mod.skipMock() {
 		return
 	}
 
-	key := target.String()
+	if mod.unmockEndpoint(key) {
+		return
+	}
 
-	app, ok := mod.apps[key]
+	srv, ok := mod.servers[key]
 	if !ok {
 		return
 	}
 
-	delete(mod.apps, key)
+	delete(mod.servers, key)
 	delete(mod.lookup, key)
 
-	shutdown, _ := sobek.AssertFunction(app.Get("shutdown"))
+	for target := range srv.regionTargets() {
+		delete(mod.lookup, target)
+	}
 
-	if _, err := shutdown(app); err != nil {
+	mod.registry.remove(srv)
+
+	if err := srv.shutdown(); err != nil {
 		mod.throw(err)
 	}
 }
 
+// rewrite replaces the URL argument at the given index with the mock server's URL, if it is mocked.
 func (mod *Module) rewrite(args []sobek.Value, index int) {
-	loc := args[index].String()
+	if value, ok := mod.rewriteValue(args[index]); ok {
+		args[index] = value
+	}
+}
+
+// rewriteURL returns the mock server's URL of the given location, ok is false if it is not mocked.
+// The locations not mocked are handled by the unmatched policy (see mock.unmatched).
+func (mod *Module) rewriteURL(loc string) (string, bool) {
+	if mod.ownURL(loc) {
+		return loc, false
+	}
 
+	if rewritten, ok := mod.mockedURL(loc); ok {
+		return rewritten, true
+	}
+
+	return mod.unmockedURL(loc)
+}
+
+// ownURL reports whether the location is local or served by one of the module's servers: the base
+// URLs of the mock servers and endpoints (any scheme, e.g. the https URLs of TLS mock servers), the
+// scenario profile servers and the catch-all server. These locations are never rewritten.
+func (mod *Module) ownURL(loc string) bool {
 	if strings.HasPrefix(loc, "http://localhost") || strings.HasPrefix(loc, "http://127.") {
-		return
+		return true
 	}
 
-	for k, v := range mod.lookup {
-		if strings.HasPrefix(loc, k) {
-			args[index] = mod.runtime().ToValue(strings.Replace(loc, k, v, 1))
+	for _, base := range mod.lookup {
+		if underBase(loc, base) {
+			return true
+		}
+	}
 
-			break
+	for _, profiles := range mod.profiles {
+		for _, prof := range profiles {
+			if prof.srv != nil && underBase(loc, prof.srv.baseURL()) {
+				return true
+			}
 		}
 	}
+
+	return mod.policy != nil && mod.policy.srv != nil && underBase(loc, mod.policy.srv.baseURL())
+}
+
+// underBase reports whether the location is the base URL or below it.
+func underBase(loc, base string) bool {
+	if len(base) == 0 || !strings.HasPrefix(loc, base) {
+		return false
+	}
+
+	return len(loc) == len(base) || strings.ContainsRune("/?#", rune(loc[len(base)]))
+}
+
+// mockedURL returns the mock server's URL of the given location, ok is false if it is not mocked.
+// The mock target matching the longest prefix of the location wins (see matchTarget).
+func (mod *Module) mockedURL(loc string) (string, bool) {
+	if mod.ownURL(loc) {
+		return loc, false
+	}
+
+	mod.startFor(loc)
+	mod.trust()
+	mod.trackExecution()
+
+	if rewritten, ok := mod.rewriteProfile(loc); ok {
+		return rewritten, true
+	}
+
+	found, prefix := "", ""
+
+	for k := range mod.lookup {
+		if matched, ok := matchTarget(k, loc); ok && (len(found) == 0 || betterTarget(k, matched, found, prefix)) {
+			found, prefix = k, matched
+		}
+	}
+
+	if len(found) == 0 {
+		return loc, false
+	}
+
+	return mod.lookup[found] + strings.TrimPrefix(loc, prefix), true
 }
@@ -7,31 +7,55 @@ package mock
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/grafana/sobek"
 	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
 	"go.k6.io/k6/js/modules/k6/http"
+	"go.k6.io/k6/lib"
 )
 
 type RootModule struct {
 	*http.RootModule
+	stats     *statistics
+	hub       *hub
+	registry  *registry
+	dashboard sync.Once
 }
 
 func New() modules.Module {
-	return &RootModule{RootModule: http.New()}
+	return &RootModule{RootModule: http.New(), stats: newStatistics(), hub: newHub(), registry: newRegistry()}
 }
 
 func (root *RootModule) NewModuleInstance(vu modules.VU) modules.Instance { // nolint:varnamelen
+	logger := newLogger(vu)
+
+	if env := vu.InitEnv(); env != nil && env.TestPreInitState != nil {
+		root.startDashboard(env.LookupEnv, logger)
+	}
+
 	return &Module{
 		ModuleInstance: root.RootModule.NewModuleInstance(vu).(*http.ModuleInstance), // nolint:forcetypeassert
 		vu:             vu,
 		appCtor:        newApplicationCtor(vu, false),
 		appCtorSync:    newApplicationCtor(vu, true),
-		logger:         newLogger(vu),
-		apps:           make(map[string]*sobek.Object),
+		logger:         logger,
+		metrics:        newMetrics(vu),
+		stats:          root.stats,
+		hub:            root.hub,
+		registry:       root.registry,
+		servers:        make(map[string]*server),
+		endpoints:      make(map[string]*endpoint),
 		lookup:         make(map[string]string),
+		deferred:       make(map[string]*mockArgs),
+		names:          make(map[string]string),
+		services:       make(map[string]string),
+		profiles:       make(map[string]map[string]*profile),
+		trusted:        make(map[*tlsListener]bool),
 	}
 }
 
@@ -40,9 +64,21 @@ type Module struct {
 	vu          modules.VU
 	appCtor     func(sobek.ConstructorCall) *sobek.Object
 	appCtorSync func(sobek.ConstructorCall) *sobek.Object
-	apps        map[string]*sobek.Object
+	servers     map[string]*server
+	endpoints   map[string]*endpoint // non-HTTP mocks by target
 	lookup      map[string]string
+	deferred    map[string]*mockArgs
+	names       map[string]string              // mock target by name
+	services    map[string]string              // service URL by name, loaded from file
+	profiles    map[string]map[string]*profile // by scenario (or exec function) name and target
 	logger      logrus.FieldLogger
+	metrics     *mockMetrics
+	stats       *statistics
+	hub         *hub
+	registry    *registry
+	trusted     map[*tlsListener]bool              // certificates trusted by the VU's HTTP client
+	policy      *unmatchedPolicy                   // handling of the URLs not mocked, nil to pass them through
+	execution   atomic.Pointer[lib.ExecutionState] // execution state of the test run, see trackExecution
 }
 
 var (
@@ -82,8 +118,48 @@ func (mod *Module) Exports() modules.Exports {
 }
 
 type options struct {
-	sync bool
-	skip bool
+	sync   bool
+	skip   bool
+	shared bool // rejected, see errSharedServer
+	strict bool
+	abort  bool
+	fuzz   *fuzzer
+	offset time.Duration
+	drift  float64
+	cache  bool
+	cdn    *cdn
+
+	regions *regions
+	tenancy string
+	links   *linkOptions
+	cookies *cookieRewriter
+	html    *htmlRewriter
+
+	deferred bool
+	lazy     bool
+	name     string
+	env      *envOption
+
+	stubFiles  []string
+	protoFiles []string
+	rpcEcho    bool
+
+	retryStorm *retryDetector
+	tls        *tlsOptions
+	warmup     *warmup
+	health     *healthKit
+
+	correlation string // correlation ID header
+	faults      *faultSchedule
+	faultsErr   error // invalid faults option, thrown when the server is created
+	clients     *clientKeying
+	capture     *captureOptions
+	overload    *overloadGuard
+	journal     *journalOptions
+	journalErr  error // invalid journal option, thrown when the server is created
+	sampling    *sampling
+	samplingErr error // invalid sampling option, thrown when the server is created
+	defaults    *responseDefaults
 }
 
 func getopts(value sobek.Value) *options {
@@ -98,9 +174,57 @@ func getopts(value sobek.Value) *options {
 
 		opts.sync = flag("sync")
 		opts.skip = flag("skip")
+		opts.shared = flag("shared")
+		opts.strict = flag("strict")
+		opts.cache = flag("cache")
+		opts.deferred = flag("deferred")
+		opts.lazy = flag("lazy")
+		opts.rpcEcho = flag("rpcEcho")
+		opts.env = getEnv(obj.Get("env"))
+		opts.stubFiles = getStubFiles(obj.Get("stubs"))
+		opts.protoFiles = getStubFiles(obj.Get("proto"))
+		opts.cdn = getCDN(obj.Get("cdn"))
+		opts.regions = getRegions(obj.Get("regions"), obj.Get("region"))
+
+		if v := obj.Get("tenancy"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			opts.tenancy = v.String()
+		}
+
+		if v := obj.Get("name"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+			opts.name = v.String()
+		}
+
+		if v := obj.Get("strict"); v != nil && v.String() == "abort" {
+			opts.abort = true
+		}
+
+		opts.fuzz = getFuzzer(obj.Get("fuzz"))
+		opts.offset, opts.drift = getSkew(obj.Get("skew"))
+		opts.links = getLinks(obj.Get("rewriteURLs"))
+		opts.cookies = getCookies(obj.Get("rewriteCookies"))
+		opts.html = getHTML(obj.Get("rewriteHTML"))
+		opts.retryStorm = getRetryStorm(obj.Get("retryStorm"))
+		opts.tls = getTLS(obj.Get("tls"))
+		opts.warmup = getWarmup(obj.Get("warmup"))
+		opts.health = getHealth(obj.Get("health"))
+		opts.correlation = getCorrelation(obj.Get("correlation"))
+		opts.faults, opts.faultsErr = getFaultSchedule(obj.Get("faults"), obj.Get("stages"))
+		opts.clients = getClientKeying(obj.Get("clients"))
+		opts.capture = getCapture(obj.Get("capture"))
+		opts.overload = getOverloadGuard(obj.Get("overload"))
+		opts.journal, opts.journalErr = getJournalOptions(obj.Get("journal"))
+		opts.sampling, opts.samplingErr = getSampling(obj.Get("sampling"))
+		opts.defaults = getResponseDefaults(obj.Get("defaults"))
 	}
 
 	return opts
 }
 
 var errInvalidArg = errors.New("invalid argument")
+
+// errSharedServer explains why the shared option is rejected: the handlers of a mock server run on the
+// event loop of the VU defining it, and k6 runs a VU's event loop only while the VU is inside an
+// iteration. A server shared by every VU would hang the requests of the other VUs whenever its VU is
+// between iterations, idle in an arrival-rate executor or done with its scenario.
+var errSharedServer = errors.New("mock servers can't be shared by VUs, " +
+	"the handlers run on the event loop of the VU defining the server")
@@ -0,0 +1,637 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttPubrec      = 5
+	mqttPubrel      = 6
+	mqttPubcomp     = 7
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttUnsubscribe = 10
+	mqttUnsuback    = 11
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttDisconnect  = 14
+
+	mqttNotAuthorized = 5
+)
+
+var errMalformedPacket = errors.New("malformed packet")
+
+type mqttPacket struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func readMQTTPacket(reader *bufio.Reader) (*mqttPacket, error) {
+	first, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length, multiplier := 0, 1
+
+	for idx := 0; ; idx++ {
+		if idx == 4 {
+			return nil, errMalformedPacket
+		}
+
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+
+	return &mqttPacket{kind: first >> 4, flags: first & 0x0f, body: body}, nil
+}
+
+func encodeMQTTPacket(kind, flags byte, body []byte) []byte {
+	data := []byte{kind<<4 | flags}
+
+	length := len(body)
+
+	for {
+		digit := byte(length % 128)
+		length /= 128
+
+		if length > 0 {
+			digit |= 0x80
+		}
+
+		data = append(data, digit)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	return append(data, body...)
+}
+
+func readMQTTString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, errMalformedPacket
+	}
+
+	size := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+size {
+		return "", nil, errMalformedPacket
+	}
+
+	return string(data[2 : 2+size]), data[2+size:], nil
+}
+
+func appendMQTTString(data []byte, str string) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(str)))
+
+	return append(data, str...)
+}
+
+// topicMatch reports whether the topic name matches the topic filter, including the + and # wildcards.
+func topicMatch(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	filters := strings.Split(filter, "/")
+	levels := strings.Split(topic, "/")
+
+	for idx, part := range filters {
+		if part == "#" {
+			return true
+		}
+
+		if idx >= len(levels) {
+			return false
+		}
+
+		if part != "+" && part != levels[idx] {
+			return false
+		}
+	}
+
+	return len(filters) == len(levels)
+}
+
+type mqttMessage struct {
+	client  string
+	topic   string
+	payload []byte
+	qos     byte
+	retain  bool
+}
+
+func (msg *mqttMessage) object() map[string]interface{} {
+	return map[string]interface{}{
+		"clientId": msg.client,
+		"topic":    msg.topic,
+		"payload":  string(msg.payload),
+		"qos":      int(msg.qos),
+		"retain":   msg.retain,
+	}
+}
+
+type mqttClient struct {
+	id   string
+	conn net.Conn
+	mu   sync.Mutex
+	subs map[string]struct{}
+}
+
+func (c *mqttClient) write(kind, flags byte, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.Write(encodeMQTTPacket(kind, flags, body)) // nolint:errcheck,gosec
+}
+
+// deliver sends the message if the client is subscribed to its topic. Messages are delivered with QoS 0.
+func (c *mqttClient) deliver(msg *mqttMessage, retained bool) {
+	c.mu.Lock()
+
+	matched := false
+
+	for filter := range c.subs {
+		if topicMatch(filter, msg.topic) {
+			matched = true
+
+			break
+		}
+	}
+
+	c.mu.Unlock()
+
+	if !matched {
+		return
+	}
+
+	var flags byte
+
+	if retained {
+		flags = 0x01
+	}
+
+	c.write(mqttPublish, flags, append(appendMQTTString(nil, msg.topic), msg.payload...))
+}
+
+type mqttTopic struct {
+	filter string
+	fn     sobek.Callable
+}
+
+// mqttBroker is a scriptable MQTT 3.1.1 broker.
+type mqttBroker struct {
+	*endpoint
+	mu       sync.RWMutex
+	clients  map[*mqttClient]struct{}
+	retained map[string]*mqttMessage
+	messages []*mqttMessage
+	events   map[string][]sobek.Callable
+	topics   []*mqttTopic
+}
+
+func (mod *Module) newMQTTBroker(args *mockArgs) *mqttBroker {
+	broker := &mqttBroker{
+		endpoint: mod.newEndpoint(args, "mqtt"),
+		clients:  make(map[*mqttClient]struct{}),
+		retained: make(map[string]*mqttMessage),
+		events:   make(map[string][]sobek.Callable),
+	}
+
+	broker.mustSet("on", func(event string, fn sobek.Callable) *sobek.Object {
+		broker.mu.Lock()
+		broker.events[event] = append(broker.events[event], fn)
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("topic", func(filter string, fn sobek.Callable) *sobek.Object {
+		broker.mu.Lock()
+		broker.topics = append(broker.topics, &mqttTopic{filter: filter, fn: fn})
+		broker.mu.Unlock()
+
+		return broker.obj
+	})
+
+	broker.mustSet("publish", func(topic string, payload sobek.Value, options sobek.Value) {
+		msg := &mqttMessage{topic: topic, payload: payloadBytes(payload)}
+
+		if obj, isObj := options.(*sobek.Object); isObj {
+			if v := obj.Get("retain"); v != nil {
+				msg.retain = v.ToBoolean()
+			}
+		}
+
+		broker.publish(msg)
+	})
+
+	broker.mustSet("messages", func(filter sobek.Value) []interface{} {
+		pattern := "#"
+		if filter != nil && !sobek.IsUndefined(filter) && !sobek.IsNull(filter) {
+			pattern = filter.String()
+		}
+
+		broker.mu.RLock()
+		defer broker.mu.RUnlock()
+
+		found := make([]interface{}, 0, len(broker.messages))
+
+		for _, msg := range broker.messages {
+			if topicMatch(pattern, msg.topic) {
+				found = append(found, msg.object())
+			}
+		}
+
+		return found
+	})
+
+	broker.mustSet("clients", func() []string {
+		broker.mu.RLock()
+		defer broker.mu.RUnlock()
+
+		ids := make([]string, 0, len(broker.clients))
+
+		for client := range broker.clients {
+			ids = append(ids, client.id)
+		}
+
+		return ids
+	})
+
+	return broker
+}
+
+// payloadBytes converts the string or ArrayBuffer payload to bytes.
+func payloadBytes(value sobek.Value) []byte {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	if buf, isBuf := value.Export().(sobek.ArrayBuffer); isBuf {
+		return buf.Bytes()
+	}
+
+	return []byte(value.String())
+}
+
+// publish records the message, keeps it if retained and delivers it to the subscribed clients.
+func (broker *mqttBroker) publish(msg *mqttMessage) {
+	broker.mu.Lock()
+
+	if len(broker.messages) >= journalLimit {
+		broker.messages = broker.messages[1:]
+	}
+
+	broker.messages = append(broker.messages, msg)
+
+	if msg.retain {
+		if len(msg.payload) == 0 {
+			delete(broker.retained, msg.topic)
+		} else {
+			broker.retained[msg.topic] = msg
+		}
+	}
+
+	clients := make([]*mqttClient, 0, len(broker.clients))
+	for client := range broker.clients {
+		clients = append(clients, client)
+	}
+
+	broker.mu.Unlock()
+
+	for _, client := range clients {
+		client.deliver(msg, false)
+	}
+}
+
+// emit calls the event handlers, it reports false if any of them returned false.
+func (broker *mqttBroker) emit(event string, args ...interface{}) bool {
+	broker.mu.RLock()
+	handlers := broker.events[event]
+	broker.mu.RUnlock()
+
+	accepted := true
+
+	for _, fn := range handlers {
+		if result := broker.invoke(fn, args...); result != nil {
+			if ok, isBool := result.Export().(bool); isBool && !ok {
+				accepted = false
+			}
+		}
+	}
+
+	return accepted
+}
+
+// handle calls the handlers of the topics matching the message. Handlers may return reply messages
+// (objects with topic and payload properties, or an array of them) which are published by the broker.
+func (broker *mqttBroker) handle(msg *mqttMessage) {
+	broker.mu.RLock()
+	topics := broker.topics
+	broker.mu.RUnlock()
+
+	for _, topic := range topics {
+		if !topicMatch(topic.filter, msg.topic) {
+			continue
+		}
+
+		result := broker.invoke(topic.fn, msg.object())
+		if result == nil || sobek.IsUndefined(result) || sobek.IsNull(result) {
+			continue
+		}
+
+		var replies []sobek.Value
+
+		err := broker.call(func() error {
+			obj := result.ToObject(broker.mod.runtime())
+
+			if _, isArray := result.Export().([]interface{}); isArray {
+				for _, key := range obj.Keys() {
+					replies = append(replies, obj.Get(key))
+				}
+			} else {
+				replies = append(replies, obj)
+			}
+
+			for _, reply := range replies {
+				if obj, isObj := reply.(*sobek.Object); isObj {
+					broker.publish(&mqttMessage{
+						topic:   obj.Get("topic").String(),
+						payload: payloadBytes(obj.Get("payload")),
+						retain:  obj.Get("retain") != nil && obj.Get("retain").ToBoolean(),
+					})
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			broker.mod.logger.WithError(err).WithField("target", broker.target).Warn("mock handler failed")
+		}
+	}
+}
+
+// serveConn serves an MQTT client connection.
+func (broker *mqttBroker) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	client, err := broker.connect(conn, reader)
+	if err != nil || client == nil {
+		return
+	}
+
+	defer func() {
+		broker.mu.Lock()
+		delete(broker.clients, client)
+		broker.mu.Unlock()
+
+		broker.emit("disconnect", map[string]interface{}{"clientId": client.id})
+	}()
+
+	for {
+		packet, err := readMQTTPacket(reader)
+		if err != nil {
+			return
+		}
+
+		if !broker.dispatch(client, packet) {
+			return
+		}
+	}
+}
+
+// connect handles the CONNECT packet. The connect handlers may refuse the client by returning false.
+func (broker *mqttBroker) connect(conn net.Conn, reader *bufio.Reader) (*mqttClient, error) {
+	packet, err := readMQTTPacket(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if packet.kind != mqttConnect {
+		return nil, errMalformedPacket
+	}
+
+	_, rest, err := readMQTTString(packet.body) // protocol name
+	if err != nil || len(rest) < 4 {
+		return nil, errMalformedPacket
+	}
+
+	flags := rest[1]
+	rest = rest[4:] // level, flags, keep alive
+
+	id, rest, err := readMQTTString(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	if flags&0x04 != 0 { // will topic and message
+		if _, rest, err = readMQTTString(rest); err == nil {
+			_, rest, err = readMQTTString(rest)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var username string
+
+	if flags&0x80 != 0 {
+		if username, _, err = readMQTTString(rest); err != nil {
+			return nil, err
+		}
+	}
+
+	client := &mqttClient{id: id, conn: conn, subs: make(map[string]struct{})}
+
+	if !broker.emit("connect", map[string]interface{}{"clientId": id, "username": username}) {
+		client.write(mqttConnack, 0, []byte{0, mqttNotAuthorized})
+
+		return nil, nil
+	}
+
+	broker.mu.Lock()
+	broker.clients[client] = struct{}{}
+	broker.mu.Unlock()
+
+	client.write(mqttConnack, 0, []byte{0, 0})
+
+	return client, nil
+}
+
+// dispatch handles a control packet of a connected client, it reports false if the connection should be closed.
+func (broker *mqttBroker) dispatch(client *mqttClient, packet *mqttPacket) bool {
+	switch packet.kind {
+	case mqttPublish:
+		return broker.receive(client, packet)
+	case mqttPubrel:
+		if len(packet.body) < 2 {
+			return false
+		}
+
+		client.write(mqttPubcomp, 0, packet.body[:2])
+	case mqttSubscribe:
+		return broker.subscribe(client, packet.body)
+	case mqttUnsubscribe:
+		return broker.unsubscribe(client, packet.body)
+	case mqttPingreq:
+		client.write(mqttPingresp, 0, nil)
+	case mqttDisconnect:
+		return false
+	}
+
+	return true
+}
+
+func (broker *mqttBroker) receive(client *mqttClient, packet *mqttPacket) bool {
+	topic, rest, err := readMQTTString(packet.body)
+	if err != nil {
+		return false
+	}
+
+	qos := (packet.flags >> 1) & 0x03
+
+	if qos > 0 {
+		if len(rest) < 2 {
+			return false
+		}
+
+		if qos == 1 {
+			client.write(mqttPuback, 0, rest[:2])
+		} else {
+			client.write(mqttPubrec, 0, rest[:2])
+		}
+
+		rest = rest[2:]
+	}
+
+	msg := &mqttMessage{client: client.id, topic: topic, payload: rest, qos: qos, retain: packet.flags&0x01 != 0}
+
+	broker.publish(msg)
+	broker.emit("publish", msg.object())
+	broker.handle(msg)
+
+	return true
+}
+
+func (broker *mqttBroker) subscribe(client *mqttClient, body []byte) bool {
+	if len(body) < 2 {
+		return false
+	}
+
+	ack := append([]byte(nil), body[:2]...)
+	rest := body[2:]
+
+	var filters []string
+
+	for len(rest) != 0 {
+		filter, next, err := readMQTTString(rest)
+		if err != nil || len(next) == 0 {
+			return false
+		}
+
+		rest = next[1:] // requested QoS, messages are delivered with QoS 0
+		filters = append(filters, filter)
+		ack = append(ack, 0)
+	}
+
+	client.mu.Lock()
+	for _, filter := range filters {
+		client.subs[filter] = struct{}{}
+	}
+	client.mu.Unlock()
+
+	client.write(mqttSuback, 0, ack)
+
+	broker.mu.RLock()
+	retained := make([]*mqttMessage, 0, len(broker.retained))
+	for _, msg := range broker.retained {
+		retained = append(retained, msg)
+	}
+	broker.mu.RUnlock()
+
+	for _, msg := range retained {
+		client.deliver(msg, true)
+	}
+
+	for _, filter := range filters {
+		broker.emit("subscribe", map[string]interface{}{"clientId": client.id, "topic": filter})
+	}
+
+	return true
+}
+
+func (broker *mqttBroker) unsubscribe(client *mqttClient, body []byte) bool {
+	if len(body) < 2 {
+		return false
+	}
+
+	rest := body[2:]
+
+	client.mu.Lock()
+
+	for len(rest) != 0 {
+		filter, next, err := readMQTTString(rest)
+		if err != nil {
+			client.mu.Unlock()
+
+			return false
+		}
+
+		delete(client.subs, filter)
+		rest = next
+	}
+
+	client.mu.Unlock()
+
+	client.write(mqttUnsuback, 0, body[:2])
+
+	return true
+}
+
+// mqtt starts an MQTT broker mock of the target (e.g. mqtt://broker.example.com:1883).
+func (mod *Module) mqtt(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+	broker := mod.newMQTTBroker(args)
+
+	return mod.startEndpoint(args, broker.endpoint, broker.serveConn)
+}
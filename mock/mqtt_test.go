@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicMatch(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, topicMatch("devices/+/state", "devices/42/state"))
+	assert.True(t, topicMatch("devices/#", "devices/42/state"))
+	assert.True(t, topicMatch("devices/#", "devices"))
+	assert.True(t, topicMatch("devices/42/state", "devices/42/state"))
+	assert.False(t, topicMatch("devices/+", "devices/42/state"))
+	assert.False(t, topicMatch("devices/+/state", "devices/42"))
+	assert.False(t, topicMatch("#", "$SYS/uptime"))
+}
+
+func TestMQTTPacket(t *testing.T) {
+	t.Parallel()
+
+	body := appendMQTTString(nil, strings.Repeat("x", 200))
+	data := encodeMQTTPacket(mqttPublish, 0x01, body)
+
+	assert.Equal(t, []byte{mqttPublish<<4 | 0x01, 0xca, 0x01}, data[:3])
+
+	packet, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(data)))
+
+	require.NoError(t, err)
+	assert.Equal(t, byte(mqttPublish), packet.kind)
+	assert.Equal(t, byte(0x01), packet.flags)
+
+	topic, rest, err := readMQTTString(packet.body)
+
+	require.NoError(t, err)
+	assert.Len(t, topic, 200)
+	assert.Empty(t, rest)
+
+	_, err = readMQTTPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0xff})))
+
+	assert.ErrorIs(t, err, errMalformedPacket)
+}
+
+func TestMQTTBroker(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+const broker = mock.mqtt("mqtt://broker.example.com:1883", broker => {
+	broker.topic("devices/+/ping", msg => ({ topic: msg.topic.replace("ping", "pong"), payload: msg.payload }))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(helper.module.lookup["mqtt://broker.example.com:1883"], "mqtt://"))
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	reader := bufio.NewReader(conn)
+
+	connect := appendMQTTString(nil, "MQTT")
+	connect = append(connect, 4, 0x02, 0, 60)
+	connect = appendMQTTString(connect, "device-42")
+
+	_, err = conn.Write(encodeMQTTPacket(mqttConnect, 0, connect))
+
+	require.NoError(t, err)
+
+	packet, err := readMQTTPacket(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, byte(mqttConnack), packet.kind)
+	assert.Equal(t, []byte{0, 0}, packet.body)
+
+	_, err = conn.Write(encodeMQTTPacket(mqttSubscribe, 0x02, append(appendMQTTString([]byte{0, 1}, "devices/42/pong"), 0)))
+
+	require.NoError(t, err)
+
+	packet, err = readMQTTPacket(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, byte(mqttSuback), packet.kind)
+
+	_, err = conn.Write(encodeMQTTPacket(mqttPublish, 0, append(appendMQTTString(nil, "devices/42/ping"), "hello"...)))
+
+	require.NoError(t, err)
+
+	packet, err = readMQTTPacket(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, byte(mqttPublish), packet.kind)
+
+	topic, payload, err := readMQTTString(packet.body)
+
+	require.NoError(t, err)
+	assert.Equal(t, "devices/42/pong", topic)
+	assert.Equal(t, "hello", string(payload))
+}
@@ -6,10 +6,12 @@ package mock
 
 import (
 	"testing"
+	"time"
 
 	"github.com/grafana/sobek"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.k6.io/k6/js/modules"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
@@ -87,6 +89,46 @@ func TestGetopts(t *testing.T) {
 
 	assert.True(t, opts.sync)
 	assert.True(t, opts.skip)
+	assert.False(t, opts.strict)
+
+	assert.NoError(t, obj.Set("strict", "abort"))
+
+	opts = getopts(obj)
+
+	assert.True(t, opts.strict)
+	assert.True(t, opts.abort)
+	assert.Nil(t, opts.fuzz)
+
+	assert.NoError(t, obj.Set("fuzz", 0.5))
+
+	opts = getopts(obj)
+
+	require.NotNil(t, opts.fuzz)
+	assert.InDelta(t, 0.5, opts.fuzz.rate, 0)
+	assert.Equal(t, allMutations, opts.fuzz.mutations)
+
+	assert.NoError(t, obj.Set("fuzz", map[string]interface{}{"mutations": []string{"status"}}))
+
+	opts = getopts(obj)
+
+	require.NotNil(t, opts.fuzz)
+	assert.InDelta(t, 1, opts.fuzz.rate, 0)
+	assert.Equal(t, []string{mutationStatus}, opts.fuzz.mutations)
+	assert.Zero(t, opts.offset)
+
+	assert.NoError(t, obj.Set("skew", 1500))
+
+	opts = getopts(obj)
+
+	assert.Equal(t, 1500*time.Millisecond, opts.offset)
+	assert.Zero(t, opts.drift)
+
+	assert.NoError(t, obj.Set("skew", map[string]interface{}{"offset": -1000, "drift": 0.01}))
+
+	opts = getopts(obj)
+
+	assert.Equal(t, -time.Second, opts.offset)
+	assert.InDelta(t, 0.01, opts.drift, 0)
 }
 
 func TestNewRunner(t *testing.T) {
@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const ocspValidity = 24 * time.Hour
+
+var (
+	oidOCSPBasic     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidECDSAWithSHA2 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+	ocspStatuses = map[string]int{"good": 0, "revoked": 1, "unknown": 2}
+)
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	KeyHash       []byte
+	SerialNumber  *big.Int
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	CertStatus asn1.RawValue
+	ThisUpdate time.Time `asn1:"generalized"`
+	NextUpdate time.Time `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ocspStatus returns the CertStatus of the status name (good, revoked or unknown).
+func ocspStatus(status string, now time.Time) (asn1.RawValue, error) {
+	tag, found := ocspStatuses[status]
+	if !found {
+		return asn1.RawValue{}, fmt.Errorf("%w: unknown OCSP status %q", errInvalidArg, status)
+	}
+
+	value := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag} // nolint:exhaustruct
+
+	if tag == ocspStatuses["revoked"] {
+		revoked, err := asn1.MarshalWithParams(now.Add(-time.Hour).UTC(), "generalized")
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+
+		value.IsCompound = true
+		value.Bytes = revoked
+	}
+
+	return value, nil
+}
+
+// ocspStaple returns a DER encoded OCSP response of the certificate with the given status, signed by the
+// issuer (the self-signed certificate itself) as the responder.
+func ocspStaple(cert, issuer *x509.Certificate, signer crypto.Signer, status string) ([]byte, error) {
+	now := time.Now().Truncate(time.Minute)
+
+	certStatus, err := ocspStatus(status, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var spki subjectPublicKeyInfo
+
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+
+	nameHash := sha1.Sum(issuer.RawSubject)          // nolint:gosec
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign()) // nolint:gosec
+	responderID, err := asn1.Marshal(keyHash[:])     // byKey
+	if err != nil {
+		return nil, err
+	}
+
+	tbs, err := asn1.Marshal(ocspResponseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: responderID}, // nolint:exhaustruct
+		ProducedAt:  now.UTC(),
+		Responses: []ocspSingleResponse{{
+			CertID: ocspCertID{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				NameHash:      nameHash[:],
+				KeyHash:       keyHash[:],
+				SerialNumber:  cert.SerialNumber,
+			},
+			CertStatus: certStatus,
+			ThisUpdate: now.UTC(),
+			NextUpdate: now.Add(ocspValidity).UTC(),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(tbs)
+
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},                         // nolint:exhaustruct
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA2}, // nolint:exhaustruct
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Status:        0, // successful
+		ResponseBytes: ocspResponseBytes{ResponseType: oidOCSPBasic, Response: basic},
+	})
+}
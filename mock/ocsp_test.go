@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/asn1"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOCSPStaple(t *testing.T) {
+	t.Parallel()
+
+	cert, err := selfSignedCert("https://example.com")
+
+	require.NoError(t, err)
+
+	key, _ := cert.PrivateKey.(*ecdsa.PrivateKey)
+
+	for status, tag := range ocspStatuses {
+		staple, err := ocspStaple(cert.Leaf, cert.Leaf, key, status)
+
+		require.NoError(t, err)
+
+		var (
+			response ocspResponse
+			basic    ocspBasicResponse
+			data     ocspResponseData
+		)
+
+		_, err = asn1.Unmarshal(staple, &response)
+
+		require.NoError(t, err)
+		assert.Equal(t, oidOCSPBasic, response.ResponseBytes.ResponseType)
+
+		_, err = asn1.Unmarshal(response.ResponseBytes.Response, &basic)
+
+		require.NoError(t, err)
+
+		digest := sha256.Sum256(basic.TBSResponseData.FullBytes)
+
+		assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], basic.Signature.Bytes))
+
+		_, err = asn1.Unmarshal(basic.TBSResponseData.FullBytes, &data)
+
+		require.NoError(t, err)
+		require.Len(t, data.Responses, 1)
+		assert.Equal(t, tag, data.Responses[0].CertStatus.Tag, status)
+		assert.Equal(t, cert.Leaf.SerialNumber, data.Responses[0].CertID.SerialNumber)
+	}
+
+	_, err = ocspStaple(cert.Leaf, cert.Leaf, key, "expired")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
+
+func TestTLSStaple(t *testing.T) {
+	t.Parallel()
+
+	listener, err := newTLSListener("https://example.com", &tlsOptions{ocsp: "revoked"})
+
+	require.NoError(t, err)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	server := listener.wrap(tcp)
+
+	defer server.Close() // nolint:errcheck
+
+	go func() {
+		for {
+			conn, err := server.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.(*tls.Conn).Handshake() // nolint:errcheck,forcetypeassert,gosec
+			conn.Close()                 // nolint:errcheck,gosec
+		}
+	}()
+
+	handshake := func() []byte {
+		conn, err := tls.Dial("tcp", tcp.Addr().String(), &tls.Config{InsecureSkipVerify: true}) // nolint:exhaustruct,gosec
+
+		require.NoError(t, err)
+
+		defer conn.Close() // nolint:errcheck
+
+		return conn.ConnectionState().OCSPResponse
+	}
+
+	assert.NotEmpty(t, handshake())
+
+	require.NoError(t, listener.staple(""))
+
+	assert.Empty(t, handshake())
+}
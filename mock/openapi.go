@@ -0,0 +1,374 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIMethods are the operations of an OpenAPI path item.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var reOpenAPIParam = regexp.MustCompile(`{([^/{}]+)}`)
+
+// normalizeYAML converts the maps with non-string keys (e.g. response codes) decoded by YAML parser.
+func normalizeYAML(value interface{}) interface{} {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			val[key] = normalizeYAML(item)
+		}
+
+		return val
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+
+		for key, item := range val {
+			out[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+
+		return out
+	case []interface{}:
+		for idx, item := range val {
+			val[idx] = normalizeYAML(item)
+		}
+
+		return val
+	default:
+		return value
+	}
+}
+
+// readOpenAPI reads an OpenAPI 3 document in YAML or JSON format.
+func readOpenAPI(filename string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filename) // nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+
+	if isYAML(filename) {
+		err = yaml.Unmarshal(data, &doc)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := normalizeYAML(doc).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not an OpenAPI document", errInvalidArg, filename)
+	}
+
+	if version, _ := root["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("%w: %s is not an OpenAPI 3 document", errInvalidArg, filename)
+	}
+
+	return root, nil
+}
+
+// openAPITarget returns the absolute URL of the first server of the document, empty if none.
+func openAPITarget(doc map[string]interface{}) string {
+	servers, _ := doc["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+
+	server, _ := servers[0].(map[string]interface{})
+	loc, _ := server["url"].(string)
+
+	// server variables are substituted with their default values
+	vars, _ := server["variables"].(map[string]interface{})
+	loc = reOpenAPIParam.ReplaceAllStringFunc(loc, func(param string) string {
+		variable, _ := vars[strings.Trim(param, "{}")].(map[string]interface{})
+		if def, ok := variable["default"].(string); ok {
+			return def
+		}
+
+		return param
+	})
+
+	if u, err := url.Parse(loc); err != nil || !u.IsAbs() {
+		return ""
+	}
+
+	return strings.TrimSuffix(loc, "/")
+}
+
+// openAPIPath converts the templated path of the document to a route pattern, e.g. /pets/{id} to /pets/:id.
+func openAPIPath(path string) string {
+	return reOpenAPIParam.ReplaceAllString(path, ":$1")
+}
+
+// openAPIResponse selects the response of the operation: the lowest 2xx status, the default or the lowest status.
+func openAPIResponse(op map[string]interface{}) (int, map[string]interface{}) {
+	responses, _ := op["responses"].(map[string]interface{})
+	codes := make([]int, 0, len(responses))
+
+	for key := range responses {
+		if code, err := strconv.Atoi(key); err == nil {
+			codes = append(codes, code)
+		}
+	}
+
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		if code >= 200 && code < 300 {
+			res, _ := responses[strconv.Itoa(code)].(map[string]interface{})
+
+			return code, res
+		}
+	}
+
+	if res, ok := responses["default"].(map[string]interface{}); ok {
+		return http.StatusOK, res
+	}
+
+	if len(codes) != 0 {
+		res, _ := responses[strconv.Itoa(codes[0])].(map[string]interface{})
+
+		return codes[0], res
+	}
+
+	return http.StatusOK, nil
+}
+
+func isJSONMedia(media string) bool {
+	mediaType, _, err := mime.ParseMediaType(media)
+
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// openAPIMedia selects the content of the response, JSON media types are preferred.
+func openAPIMedia(res map[string]interface{}) (string, map[string]interface{}) {
+	content, _ := res["content"].(map[string]interface{})
+	types := make([]string, 0, len(content))
+
+	for media := range content {
+		types = append(types, media)
+	}
+
+	if len(types) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if isJSONMedia(types[i]) != isJSONMedia(types[j]) {
+			return isJSONMedia(types[i])
+		}
+
+		return types[i] < types[j]
+	})
+
+	media, _ := content[types[0]].(map[string]interface{})
+
+	return types[0], media
+}
+
+// openAPIExample returns the example of the media: the example, the first of the examples or the example
+// of the schema. Found is false if there is no example.
+func openAPIExample(gen *generator, media map[string]interface{}) (interface{}, bool, error) {
+	if example, ok := media["example"]; ok {
+		return example, true, nil
+	}
+
+	if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) != 0 {
+		names := make([]string, 0, len(examples))
+
+		for name := range examples {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		example, _ := examples[names[0]].(map[string]interface{})
+
+		example, err := gen.resolve(example)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if value, ok := example["value"]; ok {
+			return value, true, nil
+		}
+	}
+
+	if schema, ok := media["schema"].(map[string]interface{}); ok {
+		schema, err := gen.resolve(schema)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if example, ok := schema["example"]; ok {
+			return example, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// encodeExample returns the body of the example value.
+func encodeExample(media string, value interface{}) (string, error) {
+	if str, isStr := value.(string); isStr && !isJSONMedia(media) {
+		return str, nil
+	}
+
+	data, err := json.Marshal(value)
+
+	return string(data), err
+}
+
+// openAPIStubs creates a stub for each operation of the document. Responses without example are generated
+// from the schema for each request. Paths without parameters precede the parameterized ones.
+func openAPIStubs(doc map[string]interface{}, gen *generator) ([]*stub, error) {
+	paths, _ := doc["paths"].(map[string]interface{})
+	keys := make([]string, 0, len(paths))
+
+	for path := range paths {
+		keys = append(keys, path)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := strings.Count(keys[i], "{"), strings.Count(keys[j], "{")
+		if ci != cj {
+			return ci < cj
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	stubs := make([]*stub, 0, len(keys))
+
+	for _, path := range keys {
+		item, _ := paths[path].(map[string]interface{})
+		pattern := openAPIPath(path)
+
+		if err := checkPath(pattern); err != nil {
+			return nil, err
+		}
+
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			s, err := openAPIStub(gen, strings.ToUpper(method), pattern, op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+
+			stubs = append(stubs, s)
+		}
+	}
+
+	return stubs, nil
+}
+
+func openAPIStub(gen *generator, method, pattern string, op map[string]interface{}) (*stub, error) {
+	status, res := openAPIResponse(op)
+
+	s := &stub{
+		Request:  &stubRequest{Method: method, Path: pattern},
+		Response: &stubResponse{Status: status},
+		route:    &route{method: method, pattern: pattern, segments: compilePath(pattern)},
+	}
+
+	res, err := gen.resolve(res)
+	if err != nil || res == nil {
+		return s, err
+	}
+
+	media, content := openAPIMedia(res)
+	if len(media) == 0 {
+		return s, nil
+	}
+
+	s.Response.Headers = map[string]string{"Content-Type": media}
+
+	example, found, err := openAPIExample(gen, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		s.Response.Body, err = encodeExample(media, example)
+
+		return s, err
+	}
+
+	if schema, ok := content["schema"].(map[string]interface{}); ok {
+		s.schema, s.gen = schema, gen
+	}
+
+	return s, nil
+}
+
+// fromOpenAPI starts a mock server defined by an OpenAPI 3 document. The target is the first server URL
+// of the document, unless given as second argument. The optional callback may define additional routes,
+// routes take precedence over the operations of the document.
+func (mod *Module) fromOpenAPI(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() && !isDeferred(call.Arguments) {
+		return sobek.Undefined()
+	}
+
+	filename := call.Argument(0).String()
+
+	doc, err := readOpenAPI(filename)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	args := &mockArgs{target: openAPITarget(doc), options: new(options)}
+	gen := newGenerator(doc, 0)
+
+	for idx := 1; idx < len(call.Arguments); idx++ {
+		arg := call.Argument(idx)
+
+		if c, isFunc := sobek.AssertFunction(arg); isFunc {
+			args.callback = c
+		} else if obj, isObj := arg.(*sobek.Object); isObj {
+			args.options = getopts(obj)
+			args.object = obj
+
+			if opts, isObj := obj.Get("generate").(*sobek.Object); isObj {
+				gen.configure(opts)
+			}
+		} else if arg.ExportType() != nil && arg.ExportType().Kind() == reflect.String {
+			args.target = arg.String()
+		}
+	}
+
+	if len(args.target) == 0 {
+		mod.throwf("missing target, %s has no absolute server URL", errInvalidArg, filename)
+	}
+
+	if args.stubs, err = openAPIStubs(doc, gen); err != nil {
+		mod.throw(err)
+	}
+
+	if args.callback == nil {
+		args.callback = func(sobek.Value, ...sobek.Value) (sobek.Value, error) { return sobek.Undefined(), nil }
+	}
+
+	return mod.define(args)
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petstore = `
+openapi: 3.0.3
+servers:
+  - url: https://{env}.example.com/v1
+    variables:
+      env:
+        default: petstore
+paths:
+  /pets/{petId}:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+        404:
+          description: not found
+    delete:
+      responses:
+        204:
+          description: deleted
+  /pets:
+    get:
+      responses:
+        default:
+          content:
+            text/plain:
+              example: no pets
+            application/json:
+              examples:
+                many:
+                  $ref: "#/components/examples/Pets"
+components:
+  examples:
+    Pets:
+      value: [{ id: 1, name: Rex }]
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id: { type: integer, minimum: 1 }
+        name: { type: string }
+`
+
+func TestReadOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "petstore.yaml")
+
+	require.NoError(t, os.WriteFile(filename, []byte(petstore), 0o600))
+
+	doc, err := readOpenAPI(filename)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://petstore.example.com/v1", openAPITarget(doc))
+
+	require.NoError(t, os.WriteFile(filename, []byte("swagger: '2.0'"), 0o600))
+
+	_, err = readOpenAPI(filename)
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
+
+func TestOpenAPIStubs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "petstore.yml")
+
+	require.NoError(t, os.WriteFile(filename, []byte(petstore), 0o600))
+
+	doc, err := readOpenAPI(filename)
+
+	require.NoError(t, err)
+
+	stubs, err := openAPIStubs(doc, newGenerator(doc, 1))
+
+	require.NoError(t, err)
+	require.Len(t, stubs, 3)
+
+	assert.Equal(t, "/pets", stubs[0].Request.Path)
+	assert.Equal(t, "/pets/:petId", stubs[1].Request.Path)
+	assert.Equal(t, http.MethodDelete, stubs[2].Request.Method)
+
+	srv := &server{stubs: stubs}
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+
+		srv.stub(r).ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := serve(http.MethodGet, "/pets")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"id":1,"name":"Rex"}]`, w.Body.String())
+
+	w = serve(http.MethodGet, "/pets/42")
+
+	var pet map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pet))
+	assert.Contains(t, pet, "id")
+	assert.IsType(t, "", pet["name"])
+
+	w = serve(http.MethodDelete, "/pets/42")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/pets/:petId/toys/:toyId", openAPIPath("/pets/{petId}/toys/{toyId}"))
+	assert.Equal(t, "/pets", openAPIPath("/pets"))
+}
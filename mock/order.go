@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	headerAfter = "X-Mock-After"
+
+	defaultOrderTimeout = 5 * time.Second
+	orderLimit          = 64
+)
+
+// order is the ordering constraint of a response: it is held until a response of a request matching the
+// path pattern was sent on the same connection (after the request arrived), or the timeout elapsed.
+// Multiplexed HTTP/2 streams can be reordered this way, HTTP/1.1 connections serve requests one by one,
+// so pipelined responses are never reordered, they are only delayed until the timeout.
+type order struct {
+	route   *route
+	timeout time.Duration
+}
+
+// getOrder parses the ordering specification: a path pattern or an object with path and timeout properties.
+func getOrder(value interface{}) *order {
+	spec := &order{timeout: defaultOrderTimeout}
+
+	var pattern string
+
+	switch val := value.(type) {
+	case string:
+		pattern = val
+	case map[string]interface{}:
+		pattern, _ = val["path"].(string)
+
+		if timeout, found := val["timeout"]; found {
+			spec.timeout = duration(timeout)
+		}
+	}
+
+	if len(pattern) == 0 || spec.timeout <= 0 {
+		return nil
+	}
+
+	spec.route = &route{pattern: pattern, segments: compilePath(pattern)}
+
+	return spec
+}
+
+type sentResponse struct {
+	seq  int
+	path string
+}
+
+// ordering tracks the responses sent on a connection, the held responses wait for them.
+type ordering struct {
+	mu      sync.Mutex
+	seq     int
+	sent    []sentResponse
+	waiters int
+	wake    chan struct{}
+}
+
+// mark returns the sequence number of the last sent response.
+func (o *ordering) mark() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.seq
+}
+
+// done reports the response of the path sent. The response is flushed first if other responses wait for it.
+func (o *ordering) done(path string, w http.ResponseWriter) {
+	o.mu.Lock()
+	waiting := o.waiters != 0
+	o.mu.Unlock()
+
+	if flusher, ok := w.(http.Flusher); ok && waiting {
+		flusher.Flush()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+
+	if len(o.sent) >= orderLimit {
+		o.sent = o.sent[1:]
+	}
+
+	o.sent = append(o.sent, sentResponse{seq: o.seq, path: path})
+
+	if o.wake != nil {
+		close(o.wake)
+		o.wake = nil
+	}
+}
+
+// wait waits for a response matching the order sent after the mark, false is returned on timeout.
+func (o *ordering) wait(ctx context.Context, mark int, spec *order) bool {
+	timer := time.NewTimer(spec.timeout)
+	defer timer.Stop()
+
+	o.mu.Lock()
+	o.waiters++
+	o.mu.Unlock()
+
+	defer func() {
+		o.mu.Lock()
+		o.waiters--
+		o.mu.Unlock()
+	}()
+
+	for {
+		o.mu.Lock()
+
+		for _, sent := range o.sent {
+			if _, ok := spec.route.match(sent.path); ok && sent.seq > mark {
+				o.mu.Unlock()
+
+				return true
+			}
+		}
+
+		if o.wake == nil {
+			o.wake = make(chan struct{})
+		}
+
+		wake := o.wake
+
+		o.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// orderOf returns the ordering constraint of the response: the X-Mock-After control header (set by res.after)
+// or the after option of the route selected by the dispatcher.
+func (srv *server) orderOf(control http.Header) *order {
+	if value := control.Get(headerAfter); len(value) != 0 {
+		var spec interface{}
+
+		if err := json.Unmarshal([]byte(value), &spec); err != nil {
+			spec = value
+		}
+
+		return getOrder(spec)
+	}
+
+	id, err := strconv.Atoi(control.Get(headerRoute))
+	if err != nil {
+		return nil
+	}
+
+	if r := srv.router.get(id); r != nil {
+		return r.after
+	}
+
+	return nil
+}
+
+// hold holds the response until the responses it is ordered after were sent on the connection.
+func (srv *server) hold(r *http.Request, stats *connStats, mark int, control http.Header) {
+	spec := srv.orderOf(control)
+	if spec == nil || stats == nil {
+		return
+	}
+
+	if !stats.order.wait(r.Context(), mark, spec) {
+		srv.mod.logger.WithField("target", srv.target).
+			WithField("path", r.URL.Path).
+			WithField("after", spec.route.pattern).
+			Debug("response order timed out")
+	}
+}
+
+// afterMethod is the after method of the response objects, it sets the ordering constraint of the response.
+func (srv *server) afterMethod(call sobek.FunctionCall) sobek.Value {
+	res := call.This.ToObject(srv.mod.runtime())
+
+	spec, err := json.Marshal(call.Argument(0).Export())
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	srv.set(res, headerAfter, string(spec))
+
+	return res
+}
@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrder(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getOrder(nil))
+	assert.Nil(t, getOrder(""))
+	assert.Nil(t, getOrder(map[string]interface{}{"path": "/b", "timeout": 0.0}))
+
+	spec := getOrder("/b/:id")
+
+	require.NotNil(t, spec)
+	assert.Equal(t, defaultOrderTimeout, spec.timeout)
+	assert.Equal(t, "/b/:id", spec.route.pattern)
+
+	spec = getOrder(map[string]interface{}{"path": "/b", "timeout": "100ms"})
+
+	require.NotNil(t, spec)
+	assert.Equal(t, 100*time.Millisecond, spec.timeout)
+}
+
+func TestOrdering(t *testing.T) {
+	t.Parallel()
+
+	var o ordering
+
+	w := httptest.NewRecorder()
+
+	o.done("/b/1", w)
+
+	mark := o.mark()
+
+	// responses sent before the mark don't count
+	assert.False(t, o.wait(context.Background(), mark, getOrder(map[string]interface{}{"path": "/b/:id", "timeout": 10.0})))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		o.done("/a", w)
+		o.done("/b/2", w)
+	}()
+
+	assert.True(t, o.wait(context.Background(), mark, getOrder("/b/:id")))
+	assert.True(t, w.Flushed)
+}
+
+func TestOrderingHTTP2(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := connOf(r.Context())
+		mark := stats.order.mark()
+
+		if r.URL.Path == "/first" {
+			stats.order.wait(r.Context(), mark, getOrder("/second"))
+		}
+
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		mu.Unlock()
+
+		w.Write([]byte(r.URL.Path)) // nolint:errcheck,gosec
+
+		stats.order.done(r.URL.Path, w)
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.Config.ConnContext = connContext
+	server.Listener = &trackingListener{Listener: server.Listener, tracker: newConnTracker()}
+	server.StartTLS()
+
+	defer server.Close()
+
+	client := server.Client()
+
+	// warm up the connection, so both requests are multiplexed on it
+	res, err := client.Get(server.URL + "/warmup") // nolint:noctx
+
+	require.NoError(t, err)
+	require.Equal(t, 2, res.ProtoMajor)
+
+	res.Body.Close() // nolint:errcheck,gosec
+
+	order = nil
+
+	get := func(path string) {
+		defer wg.Done()
+
+		res, err := client.Get(server.URL + path) // nolint:noctx
+		if err != nil {
+			return
+		}
+
+		io.Copy(io.Discard, res.Body) // nolint:errcheck,gosec
+		res.Body.Close()              // nolint:errcheck,gosec
+	}
+
+	wg.Add(2)
+
+	go get("/first")
+
+	time.Sleep(50 * time.Millisecond)
+
+	go get("/second")
+
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"/second", "/first"}, order)
+}
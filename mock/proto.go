@@ -0,0 +1,1116 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A minimal protocol buffers implementation: .proto files (messages, enums, maps, oneofs and services)
+// are parsed into a schema, messages are converted between binary wire format and JSON compatible values
+// (fields by JSON name, enums by name, bytes as base64). Imports are resolved relative to the importing
+// file (and to the working directory), the well-known types are bundled (see wellknown.go).
+
+var (
+	errProtoSyntax  = errors.New("invalid proto file")
+	errProtoType    = errors.New("unknown proto type")
+	errProtoWire    = errors.New("invalid protobuf message")
+	errProtoMessage = errors.New("invalid message value")
+)
+
+type protoField struct {
+	name     string
+	jsonName string
+	number   int
+	typeName string // scalar type or the (resolved) full name of the message or enum
+	repeated bool
+	message  *protoMessage
+	enum     *protoEnum
+}
+
+type protoMessage struct {
+	fullName string
+	fields   []*protoField
+	byNumber map[int]*protoField
+	mapEntry bool // synthetic message of a map field, fields are key (1) and value (2)
+}
+
+type protoEnum struct {
+	fullName string
+	byName   map[string]int
+	byNumber map[int]string
+}
+
+type protoMethod struct {
+	path            string // /package.Service/Method
+	scope           string // package of the service, the input and output types are resolved in
+	input, output   string
+	in, out         *protoMessage
+	clientStreaming bool
+	serverStreaming bool
+}
+
+// protoSchema contains the messages, enums and methods of the loaded .proto files.
+type protoSchema struct {
+	messages map[string]*protoMessage
+	enums    map[string]*protoEnum
+	methods  map[string]*protoMethod
+
+	scopes map[*protoField]string // scope of the unresolved field types
+	loaded map[string]bool        // loaded files and imports
+}
+
+func newProtoSchema() *protoSchema {
+	return &protoSchema{
+		messages: make(map[string]*protoMessage),
+		enums:    make(map[string]*protoEnum),
+		methods:  make(map[string]*protoMethod),
+		scopes:   make(map[*protoField]string),
+		loaded:   make(map[string]bool),
+	}
+}
+
+// loadProto parses the .proto files, nil is returned without files.
+func loadProto(filenames []string) (*protoSchema, error) {
+	if len(filenames) == 0 {
+		return nil, nil
+	}
+
+	schema := newProtoSchema()
+
+	for _, filename := range filenames {
+		if err := schema.load(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := schema.resolve(); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// load parses the .proto file and the files imported by it.
+func (s *protoSchema) load(filename string) error {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		key = filepath.Clean(filename)
+	}
+
+	if s.loaded[key] {
+		return nil
+	}
+
+	s.loaded[key] = true
+
+	data, err := os.ReadFile(filename) // nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	imports, err := s.parseFile(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	for _, path := range imports {
+		if err := s.include(filepath.Dir(filename), path); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// include loads the imported file: a bundled well-known type, a file relative to the directory of the
+// importing file or to the working directory.
+func (s *protoSchema) include(dir, path string) error {
+	if src, found := wellKnownProtos[path]; found {
+		if s.loaded[path] {
+			return nil
+		}
+
+		s.loaded[path] = true
+
+		_, err := s.parseFile(src)
+
+		return err
+	}
+
+	if relative := filepath.Join(dir, path); fileExists(relative) {
+		return s.load(relative)
+	}
+
+	if fileExists(path) {
+		return s.load(path)
+	}
+
+	return fmt.Errorf("%w: import %q not found", errProtoSyntax, path)
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+
+	return err == nil && !info.IsDir()
+}
+
+// method returns the method of the path (/package.Service/Method), nil if not found.
+func (s *protoSchema) method(path string) *protoMethod {
+	if s == nil {
+		return nil
+	}
+
+	return s.methods[path]
+}
+
+// protoTokens splits the .proto source into tokens, comments are dropped.
+func protoTokens(src string) []string {
+	var tokens []string
+
+	for idx := 0; idx < len(src); {
+		char := src[idx]
+
+		switch {
+		case unicode.IsSpace(rune(char)):
+			idx++
+		case strings.HasPrefix(src[idx:], "//"):
+			for idx < len(src) && src[idx] != '\n' {
+				idx++
+			}
+		case strings.HasPrefix(src[idx:], "/*"):
+			end := strings.Index(src[idx+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+
+			idx += end + 4
+		case char == '"' || char == '\'':
+			end := idx + 1
+			for end < len(src) && src[end] != char {
+				if src[end] == '\\' {
+					end++
+				}
+
+				end++
+			}
+
+			if end >= len(src) {
+				end = len(src) - 1
+			}
+
+			tokens = append(tokens, src[idx:end+1])
+			idx = end + 1
+		case char == '_' || char == '.' || char == '-' || char == '+' ||
+			unicode.IsLetter(rune(char)) || unicode.IsDigit(rune(char)):
+			end := idx + 1
+			for end < len(src) && (src[end] == '_' || src[end] == '.' ||
+				unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+
+			tokens = append(tokens, src[idx:end])
+			idx = end
+		default:
+			tokens = append(tokens, string(char))
+			idx++
+		}
+	}
+
+	return tokens
+}
+
+// protoParser is a recursive descent parser of the .proto source.
+type protoParser struct {
+	tokens []string
+	pos    int
+	schema *protoSchema
+}
+
+// parse parses the .proto source, the imports are not loaded (see load).
+func (s *protoSchema) parse(src string) error {
+	_, err := s.parseFile(src)
+
+	return err
+}
+
+// parseFile parses the .proto source and returns the paths of its imports.
+func (s *protoSchema) parseFile(src string) ([]string, error) {
+	p := &protoParser{tokens: protoTokens(src), schema: s}
+
+	pkg := ""
+
+	var imports []string
+
+	for !p.done() {
+		switch tok := p.next(); tok {
+		case "package":
+			pkg = p.next()
+
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "import":
+			path := p.next()
+			if path == "public" || path == "weak" {
+				path = p.next()
+			}
+
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+
+			imports = append(imports, strings.Trim(path, `"'`))
+		case "message":
+			if err := p.message(pkg); err != nil {
+				return nil, err
+			}
+		case "enum":
+			if err := p.enum(pkg); err != nil {
+				return nil, err
+			}
+		case "service":
+			if err := p.service(pkg); err != nil {
+				return nil, err
+			}
+		case ";":
+		default: // syntax, option, extend
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return imports, nil
+}
+
+func (p *protoParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+
+	return tok
+}
+
+func (p *protoParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return fmt.Errorf("%w: expected %q, got %q", errProtoSyntax, tok, got)
+	}
+
+	return nil
+}
+
+// skip skips the rest of the statement, ended by semicolon or a block.
+func (p *protoParser) skip() error {
+	for depth := 0; !p.done(); {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+
+			if depth <= 0 {
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if len(scope) == 0 {
+		return name
+	}
+
+	return scope + "." + name
+}
+
+func (p *protoParser) message(scope string) error {
+	msg := &protoMessage{fullName: qualify(scope, p.next()), byNumber: make(map[int]*protoField)}
+
+	p.schema.messages[msg.fullName] = msg
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	return p.messageBody(msg)
+}
+
+func (p *protoParser) messageBody(msg *protoMessage) error {
+	for !p.done() {
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case "message":
+			if err := p.message(msg.fullName); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.enum(msg.fullName); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+
+			if err := p.messageBody(msg); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend", ";":
+			if tok != ";" {
+				if err := p.skip(); err != nil {
+					return err
+				}
+			}
+		case "map":
+			if err := p.mapField(msg); err != nil {
+				return err
+			}
+		default:
+			if err := p.field(msg, tok); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: unterminated message %s", errProtoSyntax, msg.fullName)
+}
+
+func (p *protoParser) field(msg *protoMessage, tok string) error {
+	field := new(protoField)
+
+	switch tok {
+	case "repeated":
+		field.repeated = true
+		tok = p.next()
+	case "optional", "required":
+		tok = p.next()
+	}
+
+	field.typeName = tok
+	field.name = p.next()
+
+	if err := p.expect("="); err != nil {
+		return err
+	}
+
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return fmt.Errorf("%w: invalid field number of %s.%s", errProtoSyntax, msg.fullName, field.name)
+	}
+
+	field.number = number
+	field.jsonName = jsonName(field.name)
+
+	msg.add(field)
+	p.schema.scopes[field] = msg.fullName
+
+	return p.skip() // field options
+}
+
+// mapField parses a map field, it is a repeated field of a synthetic entry message.
+func (p *protoParser) mapField(msg *protoMessage) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+
+	keyType := p.next()
+
+	if err := p.expect(","); err != nil {
+		return err
+	}
+
+	valueType := p.next()
+
+	if err := p.expect(">"); err != nil {
+		return err
+	}
+
+	name := p.next()
+
+	entry := &protoMessage{
+		fullName: qualify(msg.fullName, name+"Entry"),
+		byNumber: make(map[int]*protoField),
+		mapEntry: true,
+	}
+
+	key := &protoField{name: "key", jsonName: "key", number: 1, typeName: keyType}
+	value := &protoField{name: "value", jsonName: "value", number: 2, typeName: valueType}
+
+	entry.add(key)
+	entry.add(value)
+
+	p.schema.messages[entry.fullName] = entry
+	p.schema.scopes[value] = msg.fullName
+
+	if err := p.expect("="); err != nil {
+		return err
+	}
+
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return fmt.Errorf("%w: invalid field number of %s.%s", errProtoSyntax, msg.fullName, name)
+	}
+
+	msg.add(&protoField{
+		name:     name,
+		jsonName: jsonName(name),
+		number:   number,
+		typeName: entry.fullName,
+		repeated: true,
+		message:  entry,
+	})
+
+	return p.skip()
+}
+
+func (p *protoParser) enum(scope string) error {
+	enum := &protoEnum{fullName: qualify(scope, p.next()), byName: make(map[string]int), byNumber: make(map[int]string)}
+
+	p.schema.enums[enum.fullName] = enum
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for !p.done() {
+		tok := p.next()
+
+		switch tok {
+		case "}":
+			return nil
+		case "option", "reserved":
+			if err := p.skip(); err != nil {
+				return err
+			}
+
+			continue
+		case ";":
+			continue
+		}
+
+		if err := p.expect("="); err != nil {
+			return err
+		}
+
+		number, err := strconv.Atoi(p.next())
+		if err != nil {
+			return fmt.Errorf("%w: invalid value of %s.%s", errProtoSyntax, enum.fullName, tok)
+		}
+
+		enum.byName[tok] = number
+
+		if _, found := enum.byNumber[number]; !found {
+			enum.byNumber[number] = tok
+		}
+
+		if err := p.skip(); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: unterminated enum %s", errProtoSyntax, enum.fullName)
+}
+
+func (p *protoParser) service(pkg string) error {
+	service := qualify(pkg, p.next())
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for !p.done() {
+		switch p.next() {
+		case "}":
+			return nil
+		case "rpc":
+			if err := p.rpc(pkg, service); err != nil {
+				return err
+			}
+		case ";":
+		default:
+			if err := p.skip(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: unterminated service %s", errProtoSyntax, service)
+}
+
+func (p *protoParser) rpc(pkg, service string) error {
+	method := &protoMethod{path: "/" + service + "/" + p.next(), scope: pkg}
+
+	messageType := func() (string, bool, error) {
+		if err := p.expect("("); err != nil {
+			return "", false, err
+		}
+
+		stream := p.peek() == "stream"
+		if stream {
+			p.next()
+		}
+
+		name := p.next()
+
+		return name, stream, p.expect(")")
+	}
+
+	var err error
+
+	if method.input, method.clientStreaming, err = messageType(); err != nil {
+		return err
+	}
+
+	if err := p.expect("returns"); err != nil {
+		return err
+	}
+
+	if method.output, method.serverStreaming, err = messageType(); err != nil {
+		return err
+	}
+
+	p.schema.methods[method.path] = method
+
+	return p.skip()
+}
+
+func (msg *protoMessage) add(field *protoField) {
+	msg.fields = append(msg.fields, field)
+	msg.byNumber[field.number] = field
+}
+
+// jsonName returns the lowerCamelCase JSON name of the field.
+func jsonName(name string) string {
+	var buff strings.Builder
+
+	upper := false
+
+	for _, char := range name {
+		if char == '_' {
+			upper = true
+
+			continue
+		}
+
+		if upper {
+			char = unicode.ToUpper(char)
+			upper = false
+		}
+
+		buff.WriteRune(char)
+	}
+
+	return buff.String()
+}
+
+var protoScalars = map[string]bool{ // nolint:gochecknoglobals
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+	"bool": true, "string": true, "bytes": true,
+}
+
+// lookup resolves the type name in the scope by the protobuf scoping rules (innermost scope first).
+func (s *protoSchema) lookup(scope, name string) (*protoMessage, *protoEnum, bool) {
+	if strings.HasPrefix(name, ".") {
+		name = name[1:]
+		scope = ""
+	}
+
+	for {
+		full := qualify(scope, name)
+
+		if msg, found := s.messages[full]; found {
+			return msg, nil, true
+		}
+
+		if enum, found := s.enums[full]; found {
+			return nil, enum, true
+		}
+
+		if len(scope) == 0 {
+			return nil, nil, false
+		}
+
+		if idx := strings.LastIndex(scope, "."); idx >= 0 {
+			scope = scope[:idx]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// resolve resolves the message and enum types of the fields and methods.
+func (s *protoSchema) resolve() error {
+	for field, scope := range s.scopes {
+		if protoScalars[field.typeName] {
+			continue
+		}
+
+		msg, enum, found := s.lookup(scope, field.typeName)
+		if !found {
+			return fmt.Errorf("%w: %s", errProtoType, field.typeName)
+		}
+
+		field.message, field.enum = msg, enum
+
+		if msg != nil {
+			field.typeName = msg.fullName
+		} else {
+			field.typeName = enum.fullName
+		}
+	}
+
+	s.scopes = make(map[*protoField]string)
+
+	resolveMethodType := func(scope, name string) (*protoMessage, string, error) {
+		if msg, _, found := s.lookup(scope, name); found && msg != nil {
+			return msg, msg.fullName, nil
+		}
+
+		return nil, "", fmt.Errorf("%w: %s", errProtoType, name)
+	}
+
+	for _, method := range s.methods {
+		if method.in != nil {
+			continue
+		}
+
+		var err error
+
+		if method.in, method.input, err = resolveMethodType(method.scope, method.input); err != nil {
+			return err
+		}
+
+		if method.out, method.output, err = resolveMethodType(method.scope, method.output); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// wireType returns the wire type of the (non-packed) field.
+func (f *protoField) wireType() int {
+	switch f.typeName {
+	case "double", "fixed64", "sfixed64":
+		return wireFixed64
+	case "float", "fixed32", "sfixed32":
+		return wireFixed32
+	case "string", "bytes":
+		return wireBytes
+	}
+
+	if f.message != nil {
+		return wireBytes
+	}
+
+	return wireVarint
+}
+
+// decode decodes the binary message into a JSON compatible value.
+func (msg *protoMessage) decode(data []byte) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+
+	for len(data) != 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errProtoWire
+		}
+
+		data = data[n:]
+		number, wire := int(key>>3), int(key&7)
+
+		raw, rest, err := wireValue(data, wire)
+		if err != nil {
+			return nil, err
+		}
+
+		data = rest
+
+		field, found := msg.byNumber[number]
+		if !found {
+			continue
+		}
+
+		if err := field.decodeInto(out, wire, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// wireValue splits the value of the given wire type from the data.
+func wireValue(data []byte, wire int) ([]byte, []byte, error) {
+	switch wire {
+	case wireVarint:
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, errProtoWire
+		}
+
+		return data[:n], data[n:], nil
+	case wireFixed64:
+		if len(data) < 8 {
+			return nil, nil, errProtoWire
+		}
+
+		return data[:8], data[8:], nil
+	case wireFixed32:
+		if len(data) < 4 {
+			return nil, nil, errProtoWire
+		}
+
+		return data[:4], data[4:], nil
+	case wireBytes:
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return nil, nil, errProtoWire
+		}
+
+		return data[n : n+int(size)], data[n+int(size):], nil
+	}
+
+	return nil, nil, errProtoWire
+}
+
+func (f *protoField) decodeInto(out map[string]interface{}, wire int, raw []byte) error {
+	if f.message != nil && f.message.mapEntry {
+		entry, err := f.message.decode(raw)
+		if err != nil {
+			return err
+		}
+
+		values, _ := out[f.jsonName].(map[string]interface{})
+		if values == nil {
+			values = make(map[string]interface{})
+			out[f.jsonName] = values
+		}
+
+		values[fmt.Sprint(entry["key"])] = entry["value"]
+
+		return nil
+	}
+
+	var values []interface{}
+
+	if wire == wireBytes && f.wireType() != wireBytes { // packed repeated scalars
+		for len(raw) != 0 {
+			item, rest, err := wireValue(raw, f.wireType())
+			if err != nil {
+				return err
+			}
+
+			value, err := f.decodeValue(item)
+			if err != nil {
+				return err
+			}
+
+			values = append(values, value)
+			raw = rest
+		}
+	} else {
+		value, err := f.decodeValue(raw)
+		if err != nil {
+			return err
+		}
+
+		values = []interface{}{value}
+	}
+
+	if !f.repeated {
+		if len(values) != 0 {
+			out[f.jsonName] = values[len(values)-1]
+		}
+
+		return nil
+	}
+
+	list, _ := out[f.jsonName].([]interface{})
+	out[f.jsonName] = append(list, values...)
+
+	return nil
+}
+
+func (f *protoField) decodeValue(raw []byte) (interface{}, error) {
+	switch f.typeName {
+	case "string":
+		return string(raw), nil
+	case "bytes":
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case "double":
+		return math.Float64frombits(binary.LittleEndian.Uint64(raw)), nil
+	case "float":
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(raw))), nil
+	case "fixed64":
+		return binary.LittleEndian.Uint64(raw), nil
+	case "sfixed64":
+		return int64(binary.LittleEndian.Uint64(raw)), nil
+	case "fixed32":
+		return binary.LittleEndian.Uint32(raw), nil
+	case "sfixed32":
+		return int32(binary.LittleEndian.Uint32(raw)), nil
+	}
+
+	if f.message != nil {
+		value, err := f.message.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return wellKnownValue(f.message, value), nil
+	}
+
+	num, _ := binary.Uvarint(raw)
+
+	switch f.typeName {
+	case "bool":
+		return num != 0, nil
+	case "int32":
+		return int32(num), nil
+	case "int64":
+		return int64(num), nil
+	case "uint32":
+		return uint32(num), nil
+	case "uint64":
+		return num, nil
+	case "sint32", "sint64":
+		return int64(num>>1) ^ -int64(num&1), nil
+	}
+
+	if f.enum != nil {
+		if name, found := f.enum.byNumber[int(int32(num))]; found {
+			return name, nil
+		}
+
+		return int32(num), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errProtoType, f.typeName)
+}
+
+// encode encodes the JSON compatible value into binary message, fields are accepted by JSON or proto name.
+func (msg *protoMessage) encode(value map[string]interface{}) ([]byte, error) {
+	var out []byte
+
+	fields := append([]*protoField(nil), msg.fields...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].number < fields[j].number })
+
+	for _, field := range fields {
+		val, found := value[field.jsonName]
+		if !found {
+			val, found = value[field.name]
+		}
+
+		if !found || val == nil {
+			continue
+		}
+
+		var err error
+
+		if out, err = field.encode(out, val); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", msg.fullName, field.name, err)
+		}
+	}
+
+	return out, nil
+}
+
+func (f *protoField) encode(out []byte, value interface{}) ([]byte, error) {
+	if f.message != nil && f.message.mapEntry {
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errProtoMessage
+		}
+
+		for _, key := range sortedKeys(entries) {
+			entry, err := f.message.encode(map[string]interface{}{"key": key, "value": entries[key]})
+			if err != nil {
+				return nil, err
+			}
+
+			out = binary.AppendUvarint(out, uint64(f.number)<<3|wireBytes)
+			out = binary.AppendUvarint(out, uint64(len(entry)))
+			out = append(out, entry...)
+		}
+
+		return out, nil
+	}
+
+	if !f.repeated {
+		return f.encodeValue(out, value)
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, errProtoMessage
+	}
+
+	if f.wireType() == wireBytes {
+		for _, item := range items {
+			var err error
+
+			if out, err = f.encodeValue(out, item); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+
+	var packed []byte
+
+	for _, item := range items {
+		var err error
+
+		if packed, err = f.appendScalar(packed, item); err != nil {
+			return nil, err
+		}
+	}
+
+	out = binary.AppendUvarint(out, uint64(f.number)<<3|wireBytes)
+	out = binary.AppendUvarint(out, uint64(len(packed)))
+
+	return append(out, packed...), nil
+}
+
+func (f *protoField) encodeValue(out []byte, value interface{}) ([]byte, error) {
+	out = binary.AppendUvarint(out, uint64(f.number)<<3|uint64(f.wireType()))
+
+	switch {
+	case f.message != nil:
+		fields, ok := wellKnownMessage(f.message, value).(map[string]interface{})
+		if !ok {
+			return nil, errProtoMessage
+		}
+
+		data, err := f.message.encode(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		out = binary.AppendUvarint(out, uint64(len(data)))
+
+		return append(out, data...), nil
+	case f.typeName == "string":
+		str := fmt.Sprint(value)
+		out = binary.AppendUvarint(out, uint64(len(str)))
+
+		return append(out, str...), nil
+	case f.typeName == "bytes":
+		str, _ := value.(string)
+
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, err
+		}
+
+		out = binary.AppendUvarint(out, uint64(len(data)))
+
+		return append(out, data...), nil
+	}
+
+	return f.appendScalar(out, value)
+}
+
+// appendScalar appends the numeric, boolean or enum value without field key.
+func (f *protoField) appendScalar(out []byte, value interface{}) ([]byte, error) {
+	if f.typeName == "bool" {
+		flag, _ := value.(bool)
+		if flag {
+			return append(out, 1), nil
+		}
+
+		return append(out, 0), nil
+	}
+
+	num, err := f.number64(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.typeName {
+	case "double":
+		return binary.LittleEndian.AppendUint64(out, math.Float64bits(num)), nil
+	case "float":
+		return binary.LittleEndian.AppendUint32(out, math.Float32bits(float32(num))), nil
+	case "fixed64", "sfixed64":
+		return binary.LittleEndian.AppendUint64(out, uint64(int64(num))), nil
+	case "fixed32", "sfixed32":
+		return binary.LittleEndian.AppendUint32(out, uint32(int32(num))), nil
+	case "sint32", "sint64":
+		n := int64(num)
+
+		return binary.AppendUvarint(out, uint64(n<<1)^uint64(n>>63)), nil
+	case "uint64":
+		return binary.AppendUvarint(out, uint64(num)), nil
+	}
+
+	return binary.AppendUvarint(out, uint64(int64(num))), nil
+}
+
+// number64 converts the JSON value to number, 64-bit integers may be strings and enums names.
+func (f *protoField) number64(value interface{}) (float64, error) {
+	if num, ok := toFloat(value); ok {
+		return num, nil
+	}
+
+	switch val := value.(type) {
+	case uint32:
+		return float64(val), nil
+	case uint64:
+		return float64(val), nil
+	case string:
+		if f.enum != nil {
+			if num, found := f.enum.byName[val]; found {
+				return float64(num), nil
+			}
+		}
+
+		if num, err := strconv.ParseFloat(val, 64); err == nil {
+			return num, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %v is not %s", errProtoMessage, value, f.typeName)
+}
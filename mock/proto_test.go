@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ordersProto = `
+syntax = "proto3";
+
+package orders.v1;
+
+import "google/protobuf/empty.proto";
+
+option go_package = "example.com/orders/v1";
+
+// An order.
+message Order {
+  string order_id = 1;
+  int64 amount = 2 [json_name = "amount"];
+  Status status = 3;
+  repeated string tags = 4;
+  repeated int32 quantities = 5;
+  map<string, Item> items = 6;
+  bytes signature = 7;
+  bool paid = 8;
+  sint32 balance = 9;
+  double rate = 10;
+
+  message Item {
+    string sku = 1;
+  }
+
+  oneof payment {
+    string card = 11;
+    string iban = 12;
+  }
+
+  reserved 13, 14;
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_OPEN = 1;
+  STATUS_CLOSED = 2;
+}
+
+message GetOrderRequest { string order_id = 1; }
+
+service OrderService {
+  rpc GetOrder (GetOrderRequest) returns (Order);
+  rpc WatchOrders (stream GetOrderRequest) returns (stream Order) {}
+}
+`
+
+func TestProtoSchema(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "orders.proto")
+
+	require.NoError(t, os.WriteFile(filename, []byte(ordersProto), 0o600))
+
+	schema, err := loadProto([]string{filename})
+
+	require.NoError(t, err)
+
+	method := schema.method("/orders.v1.OrderService/GetOrder")
+
+	require.NotNil(t, method)
+	assert.Equal(t, "orders.v1.GetOrderRequest", method.input)
+	assert.Equal(t, "orders.v1.Order", method.output)
+	assert.False(t, method.clientStreaming || method.serverStreaming)
+
+	watch := schema.method("/orders.v1.OrderService/WatchOrders")
+
+	require.NotNil(t, watch)
+	assert.True(t, watch.clientStreaming && watch.serverStreaming)
+
+	assert.Nil(t, schema.method("/orders.v1.OrderService/Missing"))
+	assert.Nil(t, (*protoSchema)(nil).method("/orders.v1.OrderService/GetOrder"))
+
+	schema, err = loadProto(nil)
+
+	assert.NoError(t, err)
+	assert.Nil(t, schema)
+}
+
+func TestProtoCodec(t *testing.T) {
+	t.Parallel()
+
+	schema := newProtoSchema()
+
+	require.NoError(t, schema.parse(ordersProto))
+	require.NoError(t, schema.resolve())
+
+	order := schema.messages["orders.v1.Order"]
+
+	data, err := order.encode(map[string]interface{}{
+		"orderId":    "42",
+		"amount":     "1500",
+		"status":     "STATUS_OPEN",
+		"tags":       []interface{}{"a", "b"},
+		"quantities": []interface{}{1.0, 2.0, 300.0},
+		"items":      map[string]interface{}{"x": map[string]interface{}{"sku": "X-1"}},
+		"signature":  "AQI=",
+		"paid":       true,
+		"balance":    -3.0,
+		"rate":       0.5,
+		"card":       "4242",
+	})
+
+	require.NoError(t, err)
+
+	decoded, err := order.decode(data)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"orderId":    "42",
+		"amount":     int64(1500),
+		"status":     "STATUS_OPEN",
+		"tags":       []interface{}{"a", "b"},
+		"quantities": []interface{}{int32(1), int32(2), int32(300)},
+		"items":      map[string]interface{}{"x": map[string]interface{}{"sku": "X-1"}},
+		"signature":  "AQI=",
+		"paid":       true,
+		"balance":    int64(-3),
+		"rate":       0.5,
+		"card":       "4242",
+	}, decoded)
+
+	data, err = order.encode(map[string]interface{}{"order_id": "7"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x0a, 0x01, '7'}, data)
+
+	_, err = order.encode(map[string]interface{}{"status": "STATUS_LOST"})
+
+	assert.ErrorIs(t, err, errProtoMessage)
+
+	_, err = order.decode([]byte{0x0a, 0x05, '7'})
+
+	assert.ErrorIs(t, err, errProtoWire)
+
+	assert.ErrorIs(t, newProtoSchema().parse("message Broken { string id = one; }"), errProtoSyntax)
+
+	unknown := newProtoSchema()
+
+	require.NoError(t, unknown.parse("message Order { Missing item = 1; }"))
+	assert.ErrorIs(t, unknown.resolve(), errProtoType)
+}
+
+func TestProtoImports(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common.proto"), []byte(`
+syntax = "proto3";
+package common;
+import "google/protobuf/timestamp.proto";
+message Audit { google.protobuf.Timestamp created = 1; }
+`), 0o600))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "events.proto"), []byte(`
+syntax = "proto3";
+package events;
+import public "common.proto";
+import "google/protobuf/duration.proto";
+import "google/protobuf/empty.proto";
+import "google/protobuf/field_mask.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/wrappers.proto";
+message Event {
+  common.Audit audit = 1;
+  google.protobuf.Duration ttl = 2;
+  google.protobuf.Struct payload = 3;
+  google.protobuf.StringValue note = 4;
+  google.protobuf.Int32Value retries = 5;
+  google.protobuf.FieldMask mask = 6;
+}
+service Events { rpc Ping (google.protobuf.Empty) returns (google.protobuf.Empty); }
+`), 0o600))
+
+	schema, err := loadProto([]string{filepath.Join(dir, "events.proto")})
+
+	require.NoError(t, err)
+	require.NotNil(t, schema.method("/events.Events/Ping"))
+	assert.Equal(t, "google.protobuf.Empty", schema.method("/events.Events/Ping").input)
+
+	event := schema.messages["events.Event"]
+
+	value := map[string]interface{}{
+		"audit":   map[string]interface{}{"created": "2024-01-02T03:04:05.5Z"},
+		"ttl":     "1.5s",
+		"payload": map[string]interface{}{"ok": true, "name": "x", "count": 2.0, "tags": []interface{}{"a", nil}},
+		"note":    "hello",
+		"retries": int64(0),
+		"mask":    "a.b,c",
+	}
+
+	data, err := event.encode(value)
+
+	require.NoError(t, err)
+
+	decoded, err := event.decode(data)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"audit":   map[string]interface{}{"created": "2024-01-02T03:04:05.5Z"},
+		"ttl":     "1.5s",
+		"payload": map[string]interface{}{"ok": true, "name": "x", "count": 2.0, "tags": []interface{}{"a", nil}},
+		"note":    "hello",
+		"retries": int32(0),
+		"mask":    "a.b,c",
+	}, decoded)
+
+	_, err = loadProto([]string{filepath.Join(dir, "missing.proto")})
+
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.proto"), []byte(`import "nowhere.proto";`), 0o600))
+
+	_, err = loadProto([]string{filepath.Join(dir, "broken.proto")})
+
+	assert.ErrorIs(t, err, errProtoSyntax)
+}
+
+func TestFormatDuration(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "3s", formatDuration(3, 0))
+	assert.Equal(t, "1.5s", formatDuration(1, 500000000))
+	assert.Equal(t, "-0.000001s", formatDuration(0, -1000))
+}
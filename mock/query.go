@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// journalQuery selects the journal entries by method, path pattern, time and status. Zero values
+// match every entry.
+type journalQuery struct {
+	method string
+	path   *route
+	since  time.Time
+	status int
+}
+
+// getJournalQuery parses the request matcher: method, path (path pattern), since (Date, milliseconds
+// since epoch or RFC 3339 string) and status properties.
+func getJournalQuery(value sobek.Value) (*journalQuery, error) {
+	query := new(journalQuery)
+
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return query, nil
+	}
+
+	spec, isObj := value.Export().(map[string]interface{})
+	if !isObj {
+		return nil, fmt.Errorf("%w: request matcher must be an object", errInvalidArg)
+	}
+
+	if method, ok := spec["method"].(string); ok {
+		query.method = strings.ToUpper(method)
+	}
+
+	if path, ok := spec["path"].(string); ok {
+		if err := checkPath(path); err != nil {
+			return nil, fmt.Errorf("%w: invalid parameter constraint of request matcher: %s", errInvalidArg, err)
+		}
+
+		query.path = newPathMatcher(path)
+	}
+
+	since, err := getTime(spec["since"])
+	if err != nil {
+		return nil, err
+	}
+
+	query.since = since
+	query.status = intKeyword(spec, "status", 0)
+
+	return query, nil
+}
+
+// getTime converts Date, milliseconds since epoch or RFC 3339 string to time, zero time if missing.
+func getTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: invalid time %q", errInvalidArg, v)
+		}
+
+		return t, nil
+	default:
+		if ms, ok := toFloat(v); ok {
+			return time.UnixMilli(int64(ms)), nil
+		}
+
+		return time.Time{}, fmt.Errorf("%w: invalid time %v", errInvalidArg, v)
+	}
+}
+
+func (q *journalQuery) matches(record *entry) bool {
+	if len(q.method) != 0 && record.method != q.method {
+		return false
+	}
+
+	if q.path != nil {
+		if _, ok := q.path.match(record.path); !ok {
+			return false
+		}
+	}
+
+	if !q.since.IsZero() && record.time.Before(q.since) {
+		return false
+	}
+
+	return q.status == 0 || record.status == q.status
+}
+
+// query returns the entries matching the query, in the order of arrival.
+func (j *journal) query(q *journalQuery) []*entry {
+	var found []*entry
+
+	for _, record := range j.snapshot() {
+		if q.matches(record) {
+			found = append(found, record)
+		}
+	}
+
+	return found
+}
+
+// clear removes the entries, the spilled bodies are released.
+func (j *journal) clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, old := range j.entries {
+		if old.spilled != nil {
+			j.disk.release(old.spilled)
+		}
+	}
+
+	j.entries = nil
+}
+
+// journalInfo returns the plain object of the journal entry.
+func journalInfo(record *entry) map[string]interface{} {
+	info := requestInfo(record)
+
+	info["time"] = record.time.UnixMilli()
+	info["status"] = record.status
+	info["route"] = record.route
+	info["duration"] = float64(record.duration) / float64(time.Millisecond)
+
+	if record.client != nil {
+		info["tls"] = record.client.object()
+	}
+
+	return info
+}
+
+// wrapRequests adds the request journal methods to the application object.
+func (srv *server) wrapRequests() {
+	query := func(value sobek.Value) *journalQuery {
+		q, err := getJournalQuery(value)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		return q
+	}
+
+	srv.mustSet("requests", func(matcher sobek.Value) []interface{} {
+		found := srv.journal.query(query(matcher))
+		all := make([]interface{}, 0, len(found))
+
+		for _, record := range found {
+			all = append(all, journalInfo(record))
+		}
+
+		return all
+	})
+
+	srv.mustSet("requestCount", func(matcher sobek.Value) int {
+		return len(srv.journal.query(query(matcher)))
+	})
+
+	srv.mustSet("clearRequests", srv.journal.clear)
+}
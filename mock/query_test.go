@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalQuery(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	jrnl := newJournal(journalLimit)
+
+	jrnl.add(&entry{time: now.Add(-time.Minute), method: "GET", path: "/users/1", status: 200})
+	jrnl.add(&entry{time: now, method: "POST", path: "/users", status: 201})
+	jrnl.add(&entry{time: now, method: "GET", path: "/orders/2", status: 404})
+
+	assert.Len(t, jrnl.query(&journalQuery{}), 3)
+	assert.Len(t, jrnl.query(&journalQuery{method: "GET"}), 2)
+	assert.Len(t, jrnl.query(&journalQuery{path: newPathMatcher("/users/:id")}), 1)
+	assert.Len(t, jrnl.query(&journalQuery{since: now}), 2)
+	assert.Len(t, jrnl.query(&journalQuery{status: 404}), 1)
+
+	found := jrnl.query(&journalQuery{method: "GET", since: now})
+
+	assert.Len(t, found, 1)
+	assert.Equal(t, "/orders/2", found[0].path)
+
+	jrnl.clear()
+
+	assert.Empty(t, jrnl.query(&journalQuery{}))
+}
+
+func TestGetTime(t *testing.T) {
+	t.Parallel()
+
+	at, err := getTime(int64(1700000000000))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000000), at.UnixMilli())
+
+	at, err = getTime("2023-11-14T22:13:20Z")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000000), at.UnixMilli())
+
+	at, err = getTime(nil)
+
+	assert.NoError(t, err)
+	assert.True(t, at.IsZero())
+
+	_, err = getTime("yesterday")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
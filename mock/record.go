@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+type recordingContextKey struct{}
+
+// recording forwards the unmatched requests to the real origin and records the exchanges as stubs.
+// The recorded stubs are served instead of forwarding the repeated requests, and persisted to the
+// recording file (if any) after each exchange, so subsequent runs replay them without the origin.
+type recording struct {
+	target string
+	file   string
+	proxy  *httputil.ReverseProxy
+
+	mu    sync.Mutex
+	stubs []*stub
+	index map[string]int
+}
+
+func newRecording(target, origin, file string) (*recording, error) {
+	backend, err := url.Parse(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	if !backend.IsAbs() {
+		return nil, fmt.Errorf("%w: recording origin must be an absolute URL: %s", errInvalidArg, origin)
+	}
+
+	rec := &recording{target: target, file: file, index: make(map[string]int)}
+
+	if len(file) != 0 {
+		recorded, err := readStubFile(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		if recorded != nil {
+			for _, s := range recorded.Stubs {
+				rec.index[stubKey(s)] = len(rec.stubs)
+				rec.stubs = append(rec.stubs, s)
+			}
+		}
+	}
+
+	rec.proxy = &httputil.ReverseProxy{ // nolint:exhaustruct
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(backend)
+		},
+		ModifyResponse: rec.capture,
+	}
+
+	return rec, nil
+}
+
+func stubKey(s *stub) string {
+	query := make(url.Values, len(s.Request.Query))
+
+	for name, value := range s.Request.Query {
+		query.Set(name, value)
+	}
+
+	return s.Request.Method + " " + s.Request.Path + "?" + query.Encode()
+}
+
+// serve serves the request from the recorded stubs or forwards it to the origin.
+func (rec *recording) serve(w http.ResponseWriter, r *http.Request) *route { // nolint:varnamelen
+	rec.mu.Lock()
+
+	var matched *stub
+
+	for _, s := range rec.stubs {
+		if s.matches(r) {
+			matched = s
+
+			break
+		}
+	}
+
+	rec.mu.Unlock()
+
+	if matched != nil {
+		matched.ServeHTTP(w, r)
+
+		return matched.route
+	}
+
+	rec.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), recordingContextKey{}, r.URL)))
+
+	return nil
+}
+
+// capture records the response of the origin.
+func (rec *recording) capture(res *http.Response) error {
+	loc, ok := res.Request.Context().Value(recordingContextKey{}).(*url.URL)
+	if !ok {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close() // nolint:errcheck,gosec
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	s := newStub(&entry{
+		method:    res.Request.Method,
+		path:      loc.Path,
+		query:     loc.Query(),
+		status:    res.StatusCode,
+		resHeader: res.Header,
+		resBody:   body,
+	})
+
+	s.route = &route{method: s.Request.Method, pattern: s.Request.Path, segments: compilePath(s.Request.Path)}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	key := stubKey(s)
+
+	if idx, found := rec.index[key]; found {
+		rec.stubs[idx] = s
+	} else {
+		rec.index[key] = len(rec.stubs)
+		rec.stubs = append(rec.stubs, s)
+	}
+
+	if len(rec.file) == 0 {
+		return nil
+	}
+
+	file := &stubFile{Target: rec.target, Stubs: rec.stubs}
+
+	return file.write(rec.file)
+}
+
+// recorded returns the number of the recorded exchanges.
+func (rec *recording) recorded() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return len(rec.stubs)
+}
+
+// wrapRecording adds the record and replay methods to the application object.
+func (srv *server) wrapRecording() {
+	srv.mustSet("record", func(origin string, opts *sobek.Object) {
+		var file string
+
+		if opts != nil {
+			if v := opts.Get("file"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+				file = v.String()
+			}
+		}
+
+		rec, err := newRecording(srv.target, origin, file)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		srv.recording = rec
+	})
+
+	srv.mustSet("recorded", func() int {
+		if srv.recording == nil {
+			return 0
+		}
+
+		return srv.recording.recorded()
+	})
+
+	srv.mustSet("replay", func(filename string) {
+		file, err := readStubFile(filename)
+		if err != nil {
+			srv.mod.throw(err)
+		}
+
+		srv.stubs = append(srv.stubs, file.Stubs...)
+	})
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	t.Parallel()
+
+	var hits int64
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"path":"` + r.URL.Path + `","page":"` + r.URL.Query().Get("page") + `"}`)) // nolint:errcheck,gosec
+	}))
+
+	defer origin.Close()
+
+	file := filepath.Join(t.TempDir(), "recordings.json")
+
+	rec, err := newRecording("https://api.example.com", origin.URL+"/v1", file)
+
+	require.NoError(t, err)
+
+	get := func(rec *recording, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+
+		rec.serve(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w
+	}
+
+	w := get(rec, "/users?page=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"path":"/v1/users","page":"2"}`, w.Body.String())
+
+	w = get(rec, "/users?page=2")
+
+	assert.JSONEq(t, `{"path":"/v1/users","page":"2"}`, w.Body.String())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&hits))
+	assert.Equal(t, 1, rec.recorded())
+
+	get(rec, "/users?page=3")
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hits))
+
+	recorded, err := readStubFile(file)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com", recorded.Target)
+	require.Len(t, recorded.Stubs, 2)
+	assert.Equal(t, "/users", recorded.Stubs[0].Request.Path)
+	assert.Equal(t, map[string]string{"page": "2"}, recorded.Stubs[0].Request.Query)
+
+	replay, err := newRecording("https://api.example.com", origin.URL, file)
+
+	require.NoError(t, err)
+
+	w = get(replay, "/users?page=3")
+
+	assert.JSONEq(t, `{"path":"/v1/users","page":"3"}`, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hits))
+
+	_, err = newRecording("https://api.example.com", "/relative", "")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
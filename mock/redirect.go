@@ -0,0 +1,328 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+const defaultMaxRedirects = 10
+
+// httpCall contains the argument indexes of a k6/http request function.
+type httpCall struct {
+	method string // empty if the method is the first argument
+	url    int
+	body   int // negative if the function has no body argument
+	params int
+	async  bool
+}
+
+// httpCalls contains the request functions of k6/http by name.
+var httpCalls = map[string]*httpCall{ // nolint:gochecknoglobals
+	"get":          {method: http.MethodGet, url: 0, body: -1, params: 1},
+	"head":         {method: http.MethodHead, url: 0, body: -1, params: 1},
+	"post":         {method: http.MethodPost, url: 0, body: 1, params: 2},
+	"put":          {method: http.MethodPut, url: 0, body: 1, params: 2},
+	"patch":        {method: http.MethodPatch, url: 0, body: 1, params: 2},
+	"del":          {method: http.MethodDelete, url: 0, body: 1, params: 2},
+	"options":      {method: http.MethodOptions, url: 0, body: 1, params: 2},
+	"request":      {url: 1, body: 2, params: 3},
+	"asyncRequest": {url: 1, body: 2, params: 3, async: true},
+}
+
+// sensitiveRedirectHeaders are not sent to other domains, like by the redirect policy of net/http.
+var sensitiveRedirectHeaders = []string{"Authorization", "Cookie", "Cookie2", "Www-Authenticate"} // nolint:gochecknoglobals
+
+// redirect is a request of a mocked URL followed by the wrapper. The location is the original (not
+// rewritten) URL, the params are passed to each request with redirects disabled.
+type redirect struct {
+	method    string
+	loc       string
+	body      sobek.Value
+	params    *sobek.Object
+	remaining int
+}
+
+// wrapRedirects wraps the request functions of k6/http (after wrap), so the redirects of the requests
+// to mocked URLs are followed by the wrapper instead of k6: the locations are resolved against the
+// original URLs and rewritten like the URL arguments, so redirects within (or between) the mocked
+// targets reach the mock servers, and the params of the request (headers, tags, timeout, etc.) are
+// preserved. The redirect limit is the redirects param or the maxRedirects option.
+func (mod *Module) wrapRedirects(defaults *sobek.Object) {
+	request, _ := sobek.AssertFunction(defaults.Get("request"))
+	asyncRequest, _ := sobek.AssertFunction(defaults.Get("asyncRequest"))
+
+	for _, name := range sortedKeys(httpCalls) {
+		spec := httpCalls[name]
+
+		callable, ok := sobek.AssertFunction(defaults.Get(name))
+		if !ok {
+			continue
+		}
+
+		send := request
+		if spec.async {
+			send = asyncRequest
+		}
+
+		wrapper := func(call sobek.FunctionCall) sobek.Value {
+			hop, args := mod.redirectable(spec, call.Arguments)
+
+			v, err := callable(mod.runtime().GlobalObject(), args...)
+			if err != nil {
+				common.Throw(mod.runtime(), err)
+			}
+
+			if hop == nil || send == nil {
+				return v
+			}
+
+			return mod.follow(v, hop, send, spec.async)
+		}
+
+		if err := defaults.Set(name, mod.runtime().ToValue(wrapper)); err != nil {
+			common.Throw(mod.runtime(), err)
+		}
+	}
+}
+
+// redirectable returns the redirect state of the call and its arguments with redirects disabled, the
+// redirect is nil (and the arguments are unchanged) if the URL is not mocked or redirects are disabled.
+func (mod *Module) redirectable(spec *httpCall, args []sobek.Value) (*redirect, []sobek.Value) {
+	if len(args) <= spec.url {
+		return nil, args
+	}
+
+	loc, ok := urlOf(args[spec.url])
+	if !ok {
+		return nil, args
+	}
+
+	if _, mocked := mod.mockedURL(loc); !mocked {
+		return nil, args
+	}
+
+	hop := &redirect{method: spec.method, loc: loc, body: sobek.Null(), remaining: mod.maxRedirects()}
+
+	if len(hop.method) == 0 {
+		hop.method = strings.ToUpper(args[0].String())
+	}
+
+	if spec.body >= 0 && len(args) > spec.body {
+		hop.body = args[spec.body]
+	}
+
+	if len(args) > spec.params {
+		if params, isObj := args[spec.params].(*sobek.Object); isObj {
+			hop.params = mod.copyObject(params)
+		}
+	}
+
+	if hop.params == nil {
+		hop.params = mod.runtime().NewObject()
+	}
+
+	redirects := hop.params.Get("redirects")
+	if redirects != nil && !sobek.IsUndefined(redirects) && !sobek.IsNull(redirects) {
+		hop.remaining = int(redirects.ToInteger())
+	}
+
+	if hop.remaining <= 0 {
+		return nil, args
+	}
+
+	out := make([]sobek.Value, spec.params+1)
+
+	for idx := range out {
+		if idx < len(args) {
+			out[idx] = args[idx]
+		} else {
+			out[idx] = sobek.Undefined()
+		}
+	}
+
+	out[spec.params] = hop.noRedirects(mod)
+
+	return hop, out
+}
+
+// maxRedirects returns the maxRedirects option of the test.
+func (mod *Module) maxRedirects() int {
+	if state := mod.vu.State(); state != nil && state.Options.MaxRedirects.Valid {
+		return int(state.Options.MaxRedirects.Int64)
+	}
+
+	return defaultMaxRedirects
+}
+
+// follow returns the response of the request, following its redirects by the send function (the
+// request or asyncRequest function of k6/http). Asynchronous responses are followed by the promise.
+func (mod *Module) follow(res sobek.Value, hop *redirect, send sobek.Callable, async bool) sobek.Value {
+	next := func(res sobek.Value) sobek.Value {
+		redirected := hop.next(mod, res)
+		if redirected == nil {
+			return res
+		}
+
+		runtime := mod.runtime()
+
+		v, err := send(sobek.Undefined(),
+			runtime.ToValue(redirected.method), runtime.ToValue(redirected.loc), redirected.body, redirected.noRedirects(mod))
+		if err != nil {
+			common.Throw(runtime, err)
+		}
+
+		return mod.follow(v, redirected, send, async)
+	}
+
+	if !async {
+		return next(res)
+	}
+
+	promise, isObj := res.(*sobek.Object)
+	if !isObj {
+		return res
+	}
+
+	then, ok := sobek.AssertFunction(promise.Get("then"))
+	if !ok {
+		return res
+	}
+
+	v, err := then(promise, mod.runtime().ToValue(next))
+	if err != nil {
+		common.Throw(mod.runtime(), err)
+	}
+
+	return v
+}
+
+// next returns the redirect of the response, nil if the response is not a redirect or the redirect
+// limit was reached. The method and the body are changed like by net/http: 301, 302 and 303 redirects
+// of requests other than GET and HEAD are sent as GET without body. The sensitive headers of the params
+// are not sent to other domains.
+func (hop *redirect) next(mod *Module, res sobek.Value) *redirect {
+	obj, isObj := res.(*sobek.Object)
+	if !isObj || hop.remaining <= 0 {
+		return nil
+	}
+
+	status := 0
+	if v := obj.Get("status"); v != nil {
+		status = int(v.ToInteger())
+	}
+
+	headers, isObj := obj.Get("headers").(*sobek.Object)
+	if !isObj {
+		return nil
+	}
+
+	location := headers.Get("Location")
+	if location == nil || sobek.IsUndefined(location) || sobek.IsNull(location) || len(location.String()) == 0 {
+		return nil
+	}
+
+	next := &redirect{method: hop.method, body: hop.body, params: hop.params, remaining: hop.remaining - 1}
+
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if hop.method != http.MethodGet && hop.method != http.MethodHead {
+			next.method, next.body = http.MethodGet, sobek.Null()
+		}
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+
+	base, err := url.Parse(hop.loc)
+	if err != nil {
+		return nil
+	}
+
+	ref, err := url.Parse(location.String())
+	if err != nil {
+		return nil
+	}
+
+	target := base.ResolveReference(ref)
+	next.loc = target.String()
+
+	if !isDomainOrSubdomain(target.Hostname(), base.Hostname()) {
+		next.params = mod.withoutHeaders(hop.params, sensitiveRedirectHeaders)
+	}
+
+	return next
+}
+
+// noRedirects returns a copy of the params with redirects disabled.
+func (hop *redirect) noRedirects(mod *Module) *sobek.Object {
+	params := mod.copyObject(hop.params)
+
+	if err := params.Set("redirects", 0); err != nil {
+		common.Throw(mod.runtime(), err)
+	}
+
+	return params
+}
+
+// withoutHeaders returns a copy of the params without the given headers.
+func (mod *Module) withoutHeaders(params *sobek.Object, names []string) *sobek.Object {
+	headers, isObj := params.Get("headers").(*sobek.Object)
+	if !isObj {
+		return params
+	}
+
+	out, filtered := mod.copyObject(params), mod.copyObject(headers)
+
+	for _, key := range headers.Keys() {
+		for _, name := range names {
+			if strings.EqualFold(name, key) {
+				if err := filtered.Delete(key); err != nil {
+					mod.throw(err)
+				}
+			}
+		}
+	}
+
+	if err := out.Set("headers", filtered); err != nil {
+		mod.throw(err)
+	}
+
+	return out
+}
+
+// isDomainOrSubdomain reports whether the host is the parent domain or its subdomain.
+func isDomainOrSubdomain(host, parent string) bool {
+	return host == parent || strings.HasSuffix(host, "."+parent)
+}
+
+// urlOf returns the location of the URL value: a string, an URL object (with href property) or an
+// http.url tagged template.
+func urlOf(value sobek.Value) (string, bool) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return "", false
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return value.String(), true
+	}
+
+	if tag, isTag := obj.Export().(httpext.URL); isTag {
+		return tag.URL, true
+	}
+
+	if href := obj.Get("href"); href != nil && !sobek.IsUndefined(href) && !sobek.IsNull(href) {
+		return href.String(), true
+	}
+
+	return "", false
+}
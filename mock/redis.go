@@ -0,0 +1,443 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	redisMaxBulk  = 512 << 20
+	redisMaxArray = 1 << 20
+)
+
+var errProtocol = errors.New("ERR Protocol error")
+
+// RESP replies are converted from JavaScript values to these types (besides nil, int64, []byte and []interface{}).
+type (
+	redisStatus string
+	redisError  string
+)
+
+// readRESPLine returns the line without the trailing CRLF.
+func readRESPLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// readRESPCommand reads a command, either as an array of bulk strings or as an inline command.
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > redisMaxArray {
+		return nil, errProtocol
+	}
+
+	args := make([]string, 0, count)
+
+	for idx := 0; idx < count; idx++ {
+		header, err := readRESPLine(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(header, "$") {
+			return nil, errProtocol
+		}
+
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 || size > redisMaxBulk {
+			return nil, errProtocol
+		}
+
+		data := make([]byte, size+2)
+
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(data[:size]))
+	}
+
+	return args, nil
+}
+
+// appendRESP appends the RESP2 encoding of the reply.
+func appendRESP(data []byte, reply interface{}) []byte {
+	switch value := reply.(type) {
+	case nil:
+		return append(data, "$-1\r\n"...)
+	case redisStatus:
+		return append(append(append(data, '+'), value...), "\r\n"...)
+	case redisError:
+		return append(append(append(data, '-'), value...), "\r\n"...)
+	case int64:
+		return append(strconv.AppendInt(append(data, ':'), value, 10), "\r\n"...)
+	case []byte:
+		data = strconv.AppendInt(append(data, '$'), int64(len(value)), 10)
+
+		return append(append(append(data, "\r\n"...), value...), "\r\n"...)
+	case []interface{}:
+		data = append(strconv.AppendInt(append(data, '*'), int64(len(value)), 10), "\r\n"...)
+
+		for _, item := range value {
+			data = appendRESP(data, item)
+		}
+
+		return data
+	case string:
+		return appendRESP(data, []byte(value))
+	}
+
+	return data
+}
+
+// redisReply converts the value returned by a script to RESP reply. Strings are bulk strings, integral
+// numbers and booleans are integers, arrays are arrays, `{ status }` and `{ error }` objects are status
+// and error replies, null and undefined are null replies.
+func redisReply(runtime *sobek.Runtime, value sobek.Value) interface{} {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	switch exported := value.Export().(type) {
+	case bool:
+		if exported {
+			return int64(1)
+		}
+
+		return int64(0)
+	case int64:
+		return exported
+	case float64:
+		if exported == float64(int64(exported)) {
+			return int64(exported)
+		}
+
+		return []byte(value.String())
+	case sobek.ArrayBuffer:
+		return exported.Bytes()
+	case []interface{}:
+		obj := value.ToObject(runtime)
+		items := make([]interface{}, 0, len(exported))
+
+		for _, key := range obj.Keys() {
+			items = append(items, redisReply(runtime, obj.Get(key)))
+		}
+
+		return items
+	case map[string]interface{}:
+		obj := value.ToObject(runtime)
+
+		if msg := obj.Get("error"); msg != nil && !sobek.IsUndefined(msg) {
+			return redisError(msg.String())
+		}
+
+		if status := obj.Get("status"); status != nil && !sobek.IsUndefined(status) {
+			return redisStatus(status.String())
+		}
+	}
+
+	return []byte(value.String())
+}
+
+type redisCommand struct {
+	fn    sobek.Callable // scripted handler, the canned reply is used if nil
+	reply interface{}
+}
+
+// redisServer is a Redis server mock. Commands are served by the scripted handlers or canned replies,
+// the basic string commands are served by a built-in in-memory store.
+type redisServer struct {
+	*endpoint
+	mu       sync.Mutex
+	commands map[string]*redisCommand
+	latency  map[string][2]time.Duration
+	store    map[string][]byte
+	journal  [][]string
+	rand     *rand.Rand
+}
+
+func (mod *Module) newRedisServer(args *mockArgs) *redisServer {
+	server := &redisServer{
+		endpoint: mod.newEndpoint(args, "redis"),
+		commands: make(map[string]*redisCommand),
+		latency:  make(map[string][2]time.Duration),
+		store:    make(map[string][]byte),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())), // nolint:gosec
+	}
+
+	register := func(name string, cmd *redisCommand) *sobek.Object {
+		server.mu.Lock()
+		server.commands[strings.ToUpper(name)] = cmd
+		server.mu.Unlock()
+
+		return server.obj
+	}
+
+	server.mustSet("command", func(name string, fn sobek.Callable) *sobek.Object {
+		return register(name, &redisCommand{fn: fn})
+	})
+
+	server.mustSet("reply", func(name string, value sobek.Value) *sobek.Object {
+		return register(name, &redisCommand{reply: redisReply(mod.runtime(), value)})
+	})
+
+	server.mustSet("error", func(name string, message string) *sobek.Object {
+		return register(name, &redisCommand{reply: redisError(message)})
+	})
+
+	server.mustSet("latency", func(name string, value sobek.Value) *sobek.Object {
+		low, high := latencyRange(value.Export())
+
+		server.mu.Lock()
+		server.latency[strings.ToUpper(name)] = [2]time.Duration{low, high}
+		server.mu.Unlock()
+
+		return server.obj
+	})
+
+	server.mustSet("set", func(key string, value sobek.Value) *sobek.Object {
+		server.mu.Lock()
+		server.store[key] = payloadBytes(value)
+		server.mu.Unlock()
+
+		return server.obj
+	})
+
+	server.mustSet("get", func(key string) sobek.Value {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		value, found := server.store[key]
+		if !found {
+			return sobek.Null()
+		}
+
+		return mod.runtime().ToValue(string(value))
+	})
+
+	server.mustSet("received", func(name sobek.Value) []interface{} {
+		filter := strings.ToUpper(optionalString(name))
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		found := make([]interface{}, 0, len(server.journal))
+
+		for _, args := range server.journal {
+			if len(filter) == 0 || strings.ToUpper(args[0]) == filter {
+				found = append(found, args)
+			}
+		}
+
+		return found
+	})
+
+	return server
+}
+
+// delay returns the injected latency of the command, the latency of "*" applies to all commands.
+func (server *redisServer) delay(name string) time.Duration {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	latency, found := server.latency[name]
+	if !found {
+		latency = server.latency["*"]
+	}
+
+	return randomLatency(server.rand, latency[0], latency[1])
+}
+
+// execute returns the reply of the command.
+func (server *redisServer) execute(args []string) interface{} {
+	name := strings.ToUpper(args[0])
+
+	server.mu.Lock()
+
+	if len(server.journal) >= journalLimit {
+		server.journal = server.journal[1:]
+	}
+
+	server.journal = append(server.journal, args)
+	cmd, found := server.commands[name]
+
+	server.mu.Unlock()
+
+	time.Sleep(server.delay(name))
+
+	if !found {
+		return server.builtin(name, args[1:])
+	}
+
+	if cmd.fn == nil {
+		return cmd.reply
+	}
+
+	var reply interface{}
+
+	err := server.call(func() error {
+		runtime := server.mod.runtime()
+
+		result, err := cmd.fn(sobek.Undefined(), runtime.ToValue(args[1:]))
+		if err != nil {
+			return err
+		}
+
+		reply = redisReply(runtime, result)
+
+		return nil
+	})
+	if err != nil {
+		server.mod.logger.WithError(err).WithField("target", server.target).Warn("mock handler failed")
+
+		return redisError("ERR " + err.Error())
+	}
+
+	return reply
+}
+
+// builtin serves the connection commands and the basic string commands from the in-memory store.
+func (server *redisServer) builtin(name string, args []string) interface{} { // nolint:cyclop
+	arity := map[string]int{"ECHO": 1, "GET": 1, "SET": 2, "INCR": 1, "DECR": 1, "INCRBY": 2, "DEL": 1, "EXISTS": 1}
+
+	if len(args) < arity[name] {
+		return redisError("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	switch name {
+	case "PING":
+		if len(args) != 0 {
+			return []byte(args[0])
+		}
+
+		return redisStatus("PONG")
+	case "ECHO":
+		return []byte(args[0])
+	case "SELECT", "QUIT":
+		return redisStatus("OK")
+	case "GET":
+		value, found := server.store[args[0]]
+		if !found {
+			return nil
+		}
+
+		return value
+	case "SET":
+		server.store[args[0]] = []byte(args[1])
+
+		return redisStatus("OK")
+	case "INCR", "DECR", "INCRBY":
+		delta := int64(1)
+
+		switch name {
+		case "DECR":
+			delta = -1
+		case "INCRBY":
+			var err error
+
+			if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return redisError("ERR value is not an integer or out of range")
+			}
+		}
+
+		var current int64
+
+		if value, found := server.store[args[0]]; found {
+			var err error
+
+			if current, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+				return redisError("ERR value is not an integer or out of range")
+			}
+		}
+
+		current += delta
+		server.store[args[0]] = []byte(strconv.FormatInt(current, 10))
+
+		return current
+	case "DEL", "EXISTS":
+		count := int64(0)
+
+		for _, key := range args {
+			if _, found := server.store[key]; found {
+				count++
+
+				if name == "DEL" {
+					delete(server.store, key)
+				}
+			}
+		}
+
+		return count
+	}
+
+	return redisError("ERR unknown command '" + strings.ToLower(name) + "'")
+}
+
+// serveConn serves a Redis client connection, commands are served in order (pipelining is supported).
+func (server *redisServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				conn.Write(appendRESP(nil, redisError(err.Error()))) // nolint:errcheck,gosec
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if _, err := conn.Write(appendRESP(nil, server.execute(args))); err != nil {
+			return
+		}
+
+		if strings.EqualFold(args[0], "QUIT") {
+			return
+		}
+	}
+}
+
+// redis starts a Redis server mock of the target (e.g. redis://cache.example.com:6379).
+func (mod *Module) redis(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+	server := mod.newRedisServer(args)
+
+	return mod.startEndpoint(args, server.endpoint, server.serveConn)
+}
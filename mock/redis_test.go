@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESP(t *testing.T) {
+	t.Parallel()
+
+	reader := bufio.NewReader(strings.NewReader("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nva\r\nl\r\nPING hello\r\n*1\r\n+GET\r\n"))
+
+	args, err := readRESPCommand(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET", "key", "va\r\nl"}, args)
+
+	args, err = readRESPCommand(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PING", "hello"}, args)
+
+	_, err = readRESPCommand(reader)
+
+	assert.ErrorIs(t, err, errProtocol)
+
+	for _, malformed := range []string{"*-1\r\n", "*1\r\n$-1\r\n"} {
+		_, err = readRESPCommand(bufio.NewReader(strings.NewReader(malformed)))
+
+		assert.ErrorIs(t, err, errProtocol, malformed)
+	}
+
+	reply := []interface{}{redisStatus("OK"), redisError("ERR boom"), int64(42), []byte("value"), nil}
+
+	assert.Equal(t, "*5\r\n+OK\r\n-ERR boom\r\n:42\r\n$5\r\nvalue\r\n$-1\r\n", string(appendRESP(nil, reply)))
+}
+
+func TestRedisBuiltin(t *testing.T) {
+	t.Parallel()
+
+	server := &redisServer{store: make(map[string][]byte)}
+
+	assert.Equal(t, redisStatus("PONG"), server.builtin("PING", nil))
+	assert.Equal(t, redisStatus("OK"), server.builtin("SET", []string{"counter", "41"}))
+	assert.Equal(t, int64(42), server.builtin("INCR", []string{"counter"}))
+	assert.Equal(t, []byte("42"), server.builtin("GET", []string{"counter"}))
+	assert.Equal(t, int64(1), server.builtin("DEL", []string{"counter", "missing"}))
+	assert.Nil(t, server.builtin("GET", []string{"counter"}))
+	assert.Equal(t, redisError("ERR wrong number of arguments for 'set' command"), server.builtin("SET", []string{"key"}))
+	assert.Equal(t, redisError("ERR unknown command 'hello'"), server.builtin("HELLO", []string{"3"}))
+}
+
+func TestRedisServer(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+const server = mock.redis("redis://cache.example.com:6379", server => {
+	server.command("GET", args => args[0] === "session" ? "alice" : null)
+	server.error("FLUSHALL", "NOPERM this user has no permissions")
+	server.latency("GET", 10)
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(helper.module.lookup["redis://cache.example.com:6379"], "redis://"))
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	_, err = conn.Write([]byte("*2\r\n$3\r\nGET\r\n$7\r\nsession\r\nGET other\r\nFLUSHALL\r\n"))
+
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+
+	for _, expected := range []string{"$5", "alice", "$-1", "-NOPERM this user has no permissions"} {
+		line, err := readRESPLine(reader)
+
+		require.NoError(t, err)
+		assert.Equal(t, expected, line)
+	}
+}
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	defaultRegionHeader = "X-Region"
+	regionPathPrefix    = "/__region__/"
+
+	headerRegion         = "X-Mock-Region"
+	headerServedByRegion = "X-Served-Region"
+)
+
+// region is a simulated deployment region of the mocked service.
+type region struct {
+	name     string
+	target   string
+	min, max time.Duration
+	status   int // forced response status of a failing region, zero if the region is up
+}
+
+// regions selects the simulated region of requests. The region is selected by the region specific
+// target URL (listener), by request header or by path prefix.
+type regions struct {
+	mu     sync.Mutex
+	byName map[string]*region
+	header string
+	prefix bool
+	rand   *rand.Rand
+	sleep  func(*http.Request, time.Duration)
+}
+
+func newRegions() *regions {
+	return &regions{
+		byName: make(map[string]*region),
+		header: defaultRegionHeader,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())), // nolint:gosec
+		sleep:  sleepContext,
+	}
+}
+
+// getRegions parses the regions and region options. Region definition is either a latency
+// (see latencyRange) or an object with latency, target and status properties.
+func getRegions(value, selector sobek.Value) *regions {
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return nil
+	}
+
+	def, ok := obj.Export().(map[string]interface{})
+	if !ok || len(def) == 0 {
+		return nil
+	}
+
+	regs := newRegions()
+
+	for name, spec := range def {
+		reg := &region{name: name}
+
+		if props, isMap := spec.(map[string]interface{}); isMap {
+			reg.min, reg.max = latencyRange(props["latency"])
+			reg.target, _ = props["target"].(string)
+
+			if status, ok := toFloat(props["status"]); ok {
+				reg.status = int(status)
+			}
+		} else {
+			reg.min, reg.max = latencyRange(spec)
+		}
+
+		regs.byName[name] = reg
+	}
+
+	if sel, isObj := selector.(*sobek.Object); isObj {
+		if v := sel.Get("header"); v != nil && !sobek.IsUndefined(v) {
+			regs.header = v.String()
+		}
+
+		if v := sel.Get("prefix"); v != nil {
+			regs.prefix = v.ToBoolean()
+		}
+	}
+
+	return regs
+}
+
+func (regs *regions) get(name string) *region {
+	regs.mu.Lock()
+	defer regs.mu.Unlock()
+
+	return regs.byName[name]
+}
+
+// selectRegion returns the region of the request. The returned request has the region
+// selector path prefix removed.
+func (regs *regions) selectRegion(r *http.Request) (*region, *http.Request) {
+	strip := func(prefix string) *http.Request {
+		out := r.Clone(r.Context())
+
+		out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		out.URL.RawPath = ""
+
+		return out
+	}
+
+	if rest, found := strings.CutPrefix(r.URL.Path, regionPathPrefix); found {
+		name, _, _ := strings.Cut(rest, "/")
+
+		if reg := regs.get(name); reg != nil {
+			return reg, strip(regionPathPrefix + name)
+		}
+	}
+
+	if reg := regs.get(r.Header.Get(regs.header)); reg != nil {
+		return reg, r
+	}
+
+	if regs.prefix {
+		name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+		if reg := regs.get(name); reg != nil {
+			return reg, strip("/" + name)
+		}
+	}
+
+	return nil, r
+}
+
+// delay returns a random latency of the region and its forced status.
+func (regs *regions) delay(reg *region) (time.Duration, int) {
+	regs.mu.Lock()
+	defer regs.mu.Unlock()
+
+	return randomLatency(regs.rand, reg.min, reg.max), reg.status
+}
+
+func (regs *regions) update(name string, fn func(reg *region)) {
+	regs.mu.Lock()
+	defer regs.mu.Unlock()
+
+	if reg, found := regs.byName[name]; found {
+		fn(reg)
+	}
+}
+
+// regional applies the simulated region of the request: the region's latency is applied, failing regions
+// respond with their forced status. False is returned if the request should not be routed.
+func (srv *server) regional(w http.ResponseWriter, r *http.Request) (*http.Request, bool) { // nolint:varnamelen
+	if srv.regions == nil {
+		return r, true
+	}
+
+	reg, out := srv.regions.selectRegion(r)
+	if reg == nil {
+		return out, true
+	}
+
+	latency, status := srv.regions.delay(reg)
+
+	srv.regions.sleep(r, latency)
+
+	w.Header().Set(headerServedByRegion, reg.name)
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+
+		return out, false
+	}
+
+	if out == r {
+		out = r.Clone(r.Context())
+	}
+
+	out.Header.Set(headerRegion, reg.name)
+
+	return out, true
+}
+
+// regionTargets returns the region specific target URLs and the corresponding address on the server.
+func (srv *server) regionTargets() map[string]string {
+	found := make(map[string]string)
+
+	if srv.regions == nil {
+		return found
+	}
+
+	srv.regions.mu.Lock()
+	defer srv.regions.mu.Unlock()
+
+	for name, reg := range srv.regions.byName {
+		if len(reg.target) != 0 {
+			found[reg.target] = srv.baseURL() + regionPathPrefix + name
+		}
+	}
+
+	return found
+}
+
+// regionObject returns the JavaScript interface for controlling the given region.
+func (srv *server) regionObject(name string) sobek.Value {
+	if srv.regions == nil || srv.regions.get(name) == nil {
+		srv.mod.throwf("unknown region %s", errInvalidArg, name)
+	}
+
+	obj := srv.mod.runtime().NewObject()
+
+	set := func(prop string, value interface{}) {
+		if err := obj.Set(prop, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	set("name", name)
+	set("down", func(status int) sobek.Value {
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+
+		srv.regions.update(name, func(reg *region) { reg.status = status })
+
+		return obj
+	})
+	set("up", func() sobek.Value {
+		srv.regions.update(name, func(reg *region) { reg.status = 0 })
+
+		return obj
+	})
+	set("latency", func(value sobek.Value) sobek.Value {
+		low, high := latencyRange(value.Export())
+
+		srv.regions.update(name, func(reg *region) { reg.min, reg.max = low, high })
+
+		return obj
+	})
+
+	return obj
+}
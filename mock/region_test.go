@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegions(t *testing.T) {
+	t.Parallel()
+
+	regs := newRegions()
+
+	regs.byName["eu"] = &region{name: "eu", min: 10 * time.Millisecond, max: 10 * time.Millisecond}
+	regs.byName["us"] = &region{name: "us", target: "https://us.example.com", status: http.StatusServiceUnavailable}
+
+	reg, out := regs.selectRegion(httptest.NewRequest(http.MethodGet, "/eu/items", nil))
+
+	assert.Nil(t, reg)
+	assert.Equal(t, "/eu/items", out.URL.Path)
+
+	regs.prefix = true
+
+	reg, out = regs.selectRegion(httptest.NewRequest(http.MethodGet, "/eu/items", nil))
+
+	require.NotNil(t, reg)
+	assert.Equal(t, "eu", reg.name)
+	assert.Equal(t, "/items", out.URL.Path)
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(defaultRegionHeader, "us")
+
+	reg, out = regs.selectRegion(req)
+
+	require.NotNil(t, reg)
+	assert.Equal(t, "us", reg.name)
+	assert.Equal(t, "/items", out.URL.Path)
+
+	reg, out = regs.selectRegion(httptest.NewRequest(http.MethodGet, regionPathPrefix+"us/items/1", nil))
+
+	require.NotNil(t, reg)
+	assert.Equal(t, "us", reg.name)
+	assert.Equal(t, "/items/1", out.URL.Path)
+
+	latency, status := regs.delay(regs.get("us"))
+
+	assert.Zero(t, latency)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	regs.update("us", func(reg *region) { reg.status = 0 })
+
+	latency, status = regs.delay(regs.get("eu"))
+
+	assert.Equal(t, 10*time.Millisecond, latency)
+	assert.Zero(t, status)
+	assert.Zero(t, regs.get("us").status)
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+// placeholder matches the {{ expression }} placeholders of the response templates.
+var placeholder = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`) // nolint:gochecknoglobals
+
+// templatePath splits the placeholder expression into its property names, e.g.
+// request.headers['x-api-key'] and request.body.items[0].name. It reports false on syntax error.
+func templatePath(expr string) ([]string, bool) {
+	var path []string
+
+	for rest := expr; len(rest) != 0; {
+		switch {
+		case rest[0] == '.' && len(path) != 0:
+			rest = rest[1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, false
+			}
+
+			key := strings.TrimSpace(rest[1:end])
+			if unquoted, err := strconv.Unquote(strings.ReplaceAll(key, "'", `"`)); err == nil {
+				key = unquoted
+			}
+
+			path = append(path, key)
+			rest = rest[end+1:]
+
+			continue
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+
+		if end == 0 {
+			return nil, false
+		}
+
+		path = append(path, rest[:end])
+		rest = rest[end:]
+	}
+
+	return path, len(path) != 0
+}
+
+// renderTemplate expands the placeholders of the text, the values are looked up by the resolve function.
+// Strings are inserted as is, other values JSON encoded, unknown placeholders are replaced by empty string.
+func renderTemplate(text string, resolve func(path []string) (interface{}, bool)) string {
+	return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+		path, ok := templatePath(placeholder.FindStringSubmatch(match)[1])
+		if !ok {
+			return match
+		}
+
+		value, found := resolve(path)
+		if !found || value == nil {
+			return ""
+		}
+
+		if str, isStr := value.(string); isStr {
+			return str
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+
+		return string(data)
+	})
+}
+
+// renderValue expands the placeholders of the string values of the decoded JSON value.
+func renderValue(value interface{}, resolve func(path []string) (interface{}, bool)) interface{} {
+	switch v := value.(type) {
+	case string:
+		return renderTemplate(v, resolve)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			out[key] = renderValue(item, resolve)
+		}
+
+		return out
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+
+		for _, item := range v {
+			out = append(out, renderValue(item, resolve))
+		}
+
+		return out
+	default:
+		return value
+	}
+}
+
+// property returns the named property of the decoded JSON value (object property or array index).
+func property(value interface{}, name string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		item, found := v[name]
+
+		return item, found
+	case []interface{}:
+		idx, err := strconv.Atoi(name)
+		if err != nil || idx < 0 || idx >= len(v) {
+			return nil, false
+		}
+
+		return v[idx], true
+	case string:
+		var doc interface{}
+
+		if err := json.Unmarshal([]byte(v), &doc); err != nil {
+			return nil, false
+		}
+
+		return property(doc, name)
+	default:
+		return nil, false
+	}
+}
+
+// resolver returns the lookup function of the request placeholders: request.method, request.path,
+// request.params, request.query, request.headers (case insensitive) and request.body.
+func (srv *server) resolver(req *sobek.Object) func(path []string) (interface{}, bool) {
+	return func(path []string) (interface{}, bool) {
+		if len(path) < 2 || path[0] != "request" {
+			return nil, false
+		}
+
+		var value interface{}
+
+		switch path[1] {
+		case "headers":
+			if len(path) != 3 {
+				return nil, false
+			}
+
+			header := srv.header(req, path[2])
+
+			return header, len(header) != 0
+		case "method", "path", "params", "query", "body":
+			prop := req.Get(path[1])
+			if prop == nil || sobek.IsUndefined(prop) || sobek.IsNull(prop) {
+				return nil, false
+			}
+
+			value = exportJSON(prop.Export())
+		default:
+			return nil, false
+		}
+
+		for _, name := range path[2:] {
+			var found bool
+
+			if value, found = property(value, name); !found {
+				return nil, false
+			}
+		}
+
+		return value, true
+	}
+}
+
+// templateMethod is the template method of the response objects. A string template is sent as text,
+// the string values of other templates are expanded and the result is sent as JSON. The templates
+// are resolved against the request of the response (see requestSymbol).
+func (srv *server) templateMethod(call sobek.FunctionCall) sobek.Value {
+	runtime := srv.mod.runtime()
+	res := call.This.ToObject(runtime)
+	tmpl := call.Argument(0)
+
+	req, isObj := res.GetSymbol(requestSymbol).(*sobek.Object)
+	if !isObj {
+		srv.mod.throwf("template is not available in middlewares of unmatched requests", errInvalidArg)
+	}
+
+	resolve := srv.resolver(req)
+
+	method, body := "send", interface{}(nil)
+
+	if _, isObj := tmpl.(*sobek.Object); isObj {
+		method, body = "json", renderValue(exportJSON(tmpl.Export()), resolve)
+	} else {
+		body = renderTemplate(tmpl.String(), resolve)
+	}
+
+	send, ok := sobek.AssertFunction(res.Get(method))
+	if !ok {
+		srv.mod.throwf("missing %s method", errInvalidArg, method)
+	}
+
+	result, err := send(res, runtime.ToValue(body))
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	return result
+}
+
+// exportJSON converts the exported value to decoded JSON types (e.g. map[string]string to
+// map[string]interface{}), so it can be navigated and its strings are found.
+func exportJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var doc interface{}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return value
+	}
+
+	return doc
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplatePath(t *testing.T) {
+	t.Parallel()
+
+	for expr, expected := range map[string][]string{
+		"request.params.id":            {"request", "params", "id"},
+		"request.headers['x-api-key']": {"request", "headers", "x-api-key"},
+		`request.headers["X-Tenant"]`:  {"request", "headers", "X-Tenant"},
+		"request.body.items[0].name":   {"request", "body", "items", "0", "name"},
+	} {
+		path, ok := templatePath(expr)
+
+		assert.True(t, ok, expr)
+		assert.Equal(t, expected, path, expr)
+	}
+
+	for _, expr := range []string{"", ".request", "request..id", "request.headers['x"} {
+		_, ok := templatePath(expr)
+
+		assert.False(t, ok, expr)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	t.Parallel()
+
+	body := map[string]interface{}{"name": "Jane", "tags": []interface{}{"a", "b"}, "age": 42.0}
+
+	resolve := func(path []string) (interface{}, bool) {
+		value := interface{}(map[string]interface{}{"request": map[string]interface{}{"body": body}})
+
+		for _, name := range path {
+			var found bool
+
+			if value, found = property(value, name); !found {
+				return nil, false
+			}
+		}
+
+		return value, true
+	}
+
+	assert.Equal(t, "Hello Jane!", renderTemplate("Hello {{ request.body.name }}!", resolve))
+	assert.Equal(t, `b ["a","b"] 42`, renderTemplate("{{request.body.tags[1]}} {{request.body.tags}} {{request.body.age}}", resolve))
+	assert.Equal(t, "[]", renderTemplate("[{{request.body.missing}}]", resolve))
+	assert.Equal(t, "{{ .bad }}", renderTemplate("{{ .bad }}", resolve))
+
+	assert.Equal(t,
+		map[string]interface{}{"greeting": "Hi Jane", "list": []interface{}{"a"}, "n": 1.0},
+		renderValue(map[string]interface{}{"greeting": "Hi {{request.body.name}}", "list": []interface{}{"{{request.body.tags[0]}}"}, "n": 1.0}, resolve),
+	)
+}
+
+func TestProperty(t *testing.T) {
+	t.Parallel()
+
+	value, found := property(`{"user":{"id":7}}`, "user")
+
+	assert.True(t, found)
+	assert.Equal(t, map[string]interface{}{"id": 7.0}, value)
+
+	_, found = property([]interface{}{1.0}, "1")
+
+	assert.False(t, found)
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// maxPooledBody is the capacity limit of the response buffers returned to the pool,
+// larger buffers are left to the garbage collector.
+const maxPooledBody = 64 << 10
+
+var responsePool = sync.Pool{ // nolint:gochecknoglobals
+	New: func() interface{} { return newResponse() },
+}
+
+// response is an in-memory http.ResponseWriter. It is used when the response should be
+// modified by the server after it was produced by the application.
+type response struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+
+	faulted bool // fuzzed response or injected fault, it is not cached
+}
+
+func newResponse() *response {
+	return &response{header: make(http.Header)}
+}
+
+// acquireResponse returns an empty response from the pool.
+func acquireResponse() *response {
+	return responsePool.Get().(*response) // nolint:forcetypeassert
+}
+
+// release resets the response and returns it to the pool, it must not be used afterwards.
+func (res *response) release() {
+	if res.body.Cap() > maxPooledBody {
+		return
+	}
+
+	for name := range res.header {
+		delete(res.header, name)
+	}
+
+	res.status = 0
+	res.body.Reset()
+	res.faulted = false
+
+	responsePool.Put(res)
+}
+
+func (res *response) Header() http.Header {
+	return res.header
+}
+
+func (res *response) WriteHeader(code int) {
+	if res.status == 0 {
+		res.status = code
+	}
+}
+
+func (res *response) Write(data []byte) (int, error) {
+	if res.status == 0 {
+		res.status = http.StatusOK
+	}
+
+	return res.body.Write(data)
+}
+
+// dispatched reports whether the response was produced by a route of the application.
+func (res *response) dispatched() bool {
+	return len(res.header.Get(headerRoute)) != 0
+}
+
+// flush writes the (possibly modified) response to the given writer.
+func (res *response) flush(w http.ResponseWriter) {
+	header := w.Header()
+
+	for name, values := range res.header {
+		header[name] = values
+	}
+
+	if res.status == 0 {
+		res.status = http.StatusOK
+	}
+
+	header.Set("Content-Length", strconv.Itoa(res.body.Len()))
+
+	w.WriteHeader(res.status)
+	w.Write(res.body.Bytes()) // nolint:errcheck,gosec
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseRelease(t *testing.T) {
+	t.Parallel()
+
+	res := acquireResponse()
+
+	res.Header().Set("Content-Type", "text/plain")
+	res.WriteHeader(http.StatusCreated)
+	res.Write([]byte("Hello World!")) // nolint:errcheck
+
+	rec := httptest.NewRecorder()
+
+	res.flush(rec)
+	res.release()
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "Hello World!", rec.Body.String())
+
+	assert.Empty(t, res.header)
+	assert.Zero(t, res.status)
+	assert.Zero(t, res.body.Len())
+
+	large := acquireResponse()
+
+	large.body.WriteString(strings.Repeat("x", maxPooledBody+1))
+	large.release()
+
+	assert.NotZero(t, large.body.Len(), "large responses are not reset and pooled")
+}
+
+func BenchmarkResponse(b *testing.B) {
+	body := []byte(strings.Repeat("x", 4096))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		res := acquireResponse()
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(body) // nolint:errcheck
+		res.flush(httptest.NewRecorder())
+		res.release()
+	}
+}
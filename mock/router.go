@@ -0,0 +1,581 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+type segment struct {
+	literal    string
+	param      string
+	wildcard   bool
+	constraint *regexp.Regexp // Express-style regular expression constraint of the parameter, e.g. :id(\d+)
+}
+
+// accepts reports whether the parameter segment accepts the path part.
+func (seg *segment) accepts(part string) bool {
+	return seg.constraint == nil || seg.constraint.MatchString(part)
+}
+
+type route struct {
+	id         int
+	version    string
+	api        string
+	method     string
+	pattern    string
+	prefix     bool
+	static     bool
+	segments   []segment
+	handlers   []sobek.Callable
+	decorators []func(http.Header)
+	predicate  sobek.Callable // custom matcher evaluated before the handlers
+	priority   int            // routes with higher priority are tried first
+	conditions []condition    // request content matchers
+	schema     *requestSchema // schemas of the request parts, validated before the handlers
+	step       *step          // state of the scenario the route is served in
+
+	slowHeaders   *slowHeaders
+	delay         *delay
+	after         *order
+	limit         *concurrency
+	throttle      *throttle // bandwidth of the response body
+	informational []*informational
+	cohorts       []string // client cohorts served by the route, all clients if empty
+
+	twins []*route // variants of the route defined by the same call, updated together
+}
+
+// family returns the route with its twins.
+func (r *route) family() []*route {
+	return append([]*route{r}, r.twins...)
+}
+
+// clone returns a copy of the route not sharing the slices modified by the modifiers.
+func (r *route) clone() *route {
+	c := *r
+
+	c.handlers = append([]sobek.Callable{}, r.handlers...)
+	c.decorators = append([]func(http.Header){}, r.decorators...)
+	c.conditions = append([]condition{}, r.conditions...)
+	c.twins = nil
+
+	return &c
+}
+
+// compilePath compiles the path pattern, parameters with invalid constraint are matched literally
+// (see checkPath).
+func compilePath(pattern string) []segment {
+	parts := splitPattern(pattern)
+	segments := make([]segment, 0, len(parts))
+
+	for _, part := range parts {
+		switch {
+		case part == "*":
+			segments = append(segments, segment{wildcard: true})
+		case strings.HasPrefix(part, ":"):
+			seg, err := compileParam(part[1:])
+			if err != nil {
+				seg = segment{literal: part}
+			}
+
+			segments = append(segments, seg)
+		default:
+			segments = append(segments, segment{literal: part})
+		}
+	}
+
+	return segments
+}
+
+// compileParam compiles a parameter segment with optional constraint, e.g. id(\d+).
+func compileParam(param string) (segment, error) {
+	start := strings.IndexByte(param, '(')
+	if start < 0 || !strings.HasSuffix(param, ")") {
+		return segment{param: param}, nil
+	}
+
+	constraint, err := regexp.Compile("^(?:" + param[start+1:len(param)-1] + ")$")
+	if err != nil {
+		return segment{}, err
+	}
+
+	return segment{param: param[:start], constraint: constraint}, nil
+}
+
+// checkPath reports the invalid parameter constraint of the path pattern.
+func checkPath(pattern string) error {
+	for _, part := range splitPattern(pattern) {
+		if strings.HasPrefix(part, ":") {
+			if _, err := compileParam(part[1:]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitPattern splits the path pattern into segments, slashes of parameter constraints are not separators.
+func splitPattern(pattern string) []string {
+	pattern = strings.Trim(pattern, "/")
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	var (
+		parts []string
+		depth int
+		start int
+	)
+
+	for idx := 0; idx < len(pattern); idx++ {
+		switch pattern[idx] {
+		case '\\':
+			idx++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '/':
+			if depth == 0 {
+				parts = append(parts, pattern[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+
+	return append(parts, pattern[start:])
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func (r *route) match(path string) (map[string]string, bool) {
+	parts := splitPath(path)
+	params := make(map[string]string)
+
+	for idx, seg := range r.segments {
+		if seg.wildcard {
+			params["0"] = strings.Join(parts[idx:], "/")
+
+			return params, true
+		}
+
+		if idx >= len(parts) {
+			return nil, false
+		}
+
+		if len(seg.param) != 0 {
+			if !seg.accepts(parts[idx]) {
+				return nil, false
+			}
+
+			params[seg.param] = parts[idx]
+
+			continue
+		}
+
+		if seg.literal != parts[idx] {
+			return nil, false
+		}
+	}
+
+	if len(parts) > len(r.segments) && !r.prefix {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// matches reports whether the path matches the route. Unlike match it does not allocate,
+// it is used on the hot path where the parameters are not needed.
+func (r *route) matches(path string) bool {
+	rest := strings.Trim(path, "/")
+
+	for _, seg := range r.segments {
+		if seg.wildcard {
+			return true
+		}
+
+		if len(rest) == 0 {
+			return false
+		}
+
+		part := rest
+
+		if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+			part, rest = rest[:idx], rest[idx+1:]
+		} else {
+			rest = ""
+		}
+
+		if len(seg.param) == 0 && seg.literal != part {
+			return false
+		}
+
+		if len(seg.param) != 0 && !seg.accepts(part) {
+			return false
+		}
+	}
+
+	return len(rest) == 0 || r.prefix
+}
+
+// Ranks of the segment kinds, more specific segments are tried first.
+const (
+	rankWildcard = iota
+	rankParam
+	rankConstrained
+	rankLiteral
+)
+
+func (seg *segment) rank() int {
+	switch {
+	case seg.wildcard:
+		return rankWildcard
+	case seg.constraint != nil:
+		return rankConstrained
+	case len(seg.param) != 0:
+		return rankParam
+	default:
+		return rankLiteral
+	}
+}
+
+// precedes reports whether the route is tried before the other one: higher priority first, then the
+// more specific path pattern. Patterns are compared segment by segment (literal, constrained parameter,
+// parameter, wildcard), then the longer pattern, the one with more request conditions and the exact
+// one (over the prefix) win.
+func (r *route) precedes(other *route) bool {
+	if r.priority != other.priority {
+		return r.priority > other.priority
+	}
+
+	for idx := 0; idx < len(r.segments) && idx < len(other.segments); idx++ {
+		if rank, otherRank := r.segments[idx].rank(), other.segments[idx].rank(); rank != otherRank {
+			return rank > otherRank
+		}
+	}
+
+	if len(r.segments) != len(other.segments) {
+		return len(r.segments) > len(other.segments)
+	}
+
+	if len(r.conditions) != len(other.conditions) {
+		return len(r.conditions) > len(other.conditions)
+	}
+
+	return !r.prefix && other.prefix
+}
+
+func (r *route) accepts(method string) bool {
+	return r.method == method || (method == http.MethodHead && r.method == http.MethodGet)
+}
+
+type router struct {
+	mu          sync.RWMutex
+	seq         int
+	modifiers   []modifier // applied on the routes being defined
+	routes      []*route
+	middlewares []*route
+}
+
+// modifier modifies the routes being defined, a modifier with fork set also defines a twin of each
+// route modified by fork instead of apply.
+type modifier struct {
+	apply func(*route)
+	fork  func(*route)
+}
+
+func newRouter() *router {
+	return new(router)
+}
+
+// newRoute creates a route with the active modifiers applied, it must be called with rt.mu held.
+// Modifiers are applied from the innermost one, so nested path prefixes compose. Forking modifiers
+// add the twins of the route.
+func (rt *router) newRoute(pattern string, handlers []sobek.Callable, prefix bool) *route {
+	family := []*route{{pattern: pattern, handlers: handlers, prefix: prefix}}
+
+	for idx := len(rt.modifiers) - 1; idx >= 0; idx-- {
+		mod := rt.modifiers[idx]
+
+		var forked []*route
+
+		for _, r := range family {
+			if mod.fork != nil {
+				twin := r.clone()
+				mod.fork(twin)
+				forked = append(forked, twin)
+			}
+
+			mod.apply(r)
+		}
+
+		family = append(family, forked...)
+	}
+
+	for _, r := range family {
+		rt.seq++
+		r.id = rt.seq
+		r.segments = compilePath(r.pattern)
+	}
+
+	r := family[0]
+
+	r.twins = family[1:]
+
+	return r
+}
+
+func (rt *router) add(method, pattern string, handlers []sobek.Callable) *route {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	r := rt.newRoute(pattern, handlers, false)
+
+	for _, each := range r.family() {
+		each.method = method
+
+		rt.insert(each)
+	}
+
+	return r
+}
+
+// insert adds the route before the first route it precedes, it must be called with rt.mu held.
+// Equivalent routes keep the order of definition.
+func (rt *router) insert(r *route) {
+	idx := sort.Search(len(rt.routes), func(i int) bool { return r.precedes(rt.routes[i]) })
+
+	rt.routes = append(rt.routes, nil)
+	copy(rt.routes[idx+1:], rt.routes[idx:])
+	rt.routes[idx] = r
+}
+
+func (rt *router) addStatic(pattern string) *route {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	r := rt.newRoute(pattern, nil, true)
+
+	for _, each := range r.family() {
+		each.static = true
+
+		rt.insert(each)
+	}
+
+	return r
+}
+
+func (rt *router) use(pattern string, handlers []sobek.Callable) *route {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	r := rt.newRoute(pattern, handlers, true)
+
+	rt.middlewares = append(rt.middlewares, r.family()...)
+
+	return r
+}
+
+func (rt *router) get(id int) *route {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	for _, r := range rt.routes {
+		if r.id == id {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// each calls fn for every route, it may modify the routes.
+func (rt *router) each(fn func(*route)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, r := range rt.routes {
+		fn(r)
+	}
+}
+
+// update calls fn for the given route and its twins, it may modify the routes.
+func (rt *router) update(r *route, fn func(*route)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, each := range r.family() {
+		fn(each)
+	}
+}
+
+// prioritize sets the priority of the route and moves it to its place in the order of routes.
+func (rt *router) prioritize(r *route, priority int) {
+	rt.reorder(r, func(r *route) { r.priority = priority })
+}
+
+// reorder calls fn for the given route and its twins and moves the modified routes to their place in
+// the order of routes.
+func (rt *router) reorder(r *route, fn func(*route)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, each := range r.family() {
+		for idx, other := range rt.routes {
+			if other == each {
+				rt.routes = append(rt.routes[:idx], rt.routes[idx+1:]...)
+
+				break
+			}
+		}
+
+		fn(each)
+
+		rt.insert(each)
+	}
+}
+
+// decorators returns the response header decorators of the route with the given id.
+func (rt *router) decorators(id int) []func(http.Header) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	for _, r := range rt.routes {
+		if r.id == id {
+			return append([]func(http.Header){}, r.decorators...)
+		}
+	}
+
+	return nil
+}
+
+// with applies the modifier on all routes and middlewares defined by fn.
+func (rt *router) with(apply func(*route), fn func()) {
+	rt.scope(modifier{apply: apply}, fn)
+}
+
+// fork applies the modifier on all routes and middlewares defined by fn, and defines a twin of each
+// of them with the variant applied instead.
+func (rt *router) fork(apply, variant func(*route), fn func()) {
+	rt.scope(modifier{apply: apply, fork: variant}, fn)
+}
+
+func (rt *router) scope(mod modifier, fn func()) {
+	rt.mu.Lock()
+	rt.modifiers = append(rt.modifiers, mod)
+	rt.mu.Unlock()
+
+	defer func() {
+		rt.mu.Lock()
+		rt.modifiers = rt.modifiers[:len(rt.modifiers)-1]
+		rt.mu.Unlock()
+	}()
+
+	fn()
+}
+
+// define registers the routes defined by fn as the given version of the route set.
+func (rt *router) define(version string, fn func()) {
+	rt.with(func(r *route) { r.version = version }, fn)
+}
+
+// selector contains the request properties selecting route variants.
+type selector struct {
+	version string // active route set version
+	api     string // negotiated API version
+}
+
+// candidates returns routes matching the given method and path in the order they should be tried.
+// Routes defined outside of route set versions (and API versions) are always candidates.
+func (rt *router) candidates(method, path string, sel selector) []*route {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	var found []*route
+
+	for _, r := range rt.routes {
+		if !r.static && !r.accepts(method) {
+			continue
+		}
+
+		if len(r.version) != 0 && r.version != sel.version {
+			continue
+		}
+
+		if len(r.api) != 0 && r.api != sel.api {
+			continue
+		}
+
+		if r.matches(path) {
+			found = append(found, r)
+		}
+	}
+
+	return found
+}
+
+// fallback returns the handlers of the path matching middlewares of the given version, they run for
+// the requests not matched by any route.
+func (rt *router) fallback(path, version string) []sobek.Callable {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	var found []sobek.Callable
+
+	for _, mw := range rt.middlewares {
+		if len(mw.version) != 0 && mw.version != version {
+			continue
+		}
+
+		if mw.matches(path) {
+			found = append(found, mw.handlers...)
+		}
+	}
+
+	return found
+}
+
+// chain returns the handler chain for the given route, including the path matching middlewares
+// registered before and after the route.
+func (rt *router) chain(target *route, path string) []sobek.Callable {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	var before, after []sobek.Callable
+
+	for _, mw := range rt.middlewares {
+		if len(mw.version) != 0 && mw.version != target.version {
+			continue
+		}
+
+		if !mw.matches(path) {
+			continue
+		}
+
+		if mw.id < target.id {
+			before = append(before, mw.handlers...)
+		} else {
+			after = append(after, mw.handlers...)
+		}
+	}
+
+	return append(append(before, target.handlers...), after...)
+}
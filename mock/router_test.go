@@ -0,0 +1,255 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatch(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	root := rt.add(http.MethodGet, "/", nil)
+	user := rt.add(http.MethodGet, "/user/:id", nil)
+	files := rt.add(http.MethodGet, "/files/*", nil)
+
+	_, ok := root.match("/")
+	assert.True(t, ok)
+
+	_, ok = root.match("/other")
+	assert.False(t, ok)
+
+	params, ok := user.match("/user/42")
+	assert.True(t, ok)
+	assert.Equal(t, "42", params["id"])
+
+	_, ok = user.match("/user")
+	assert.False(t, ok)
+
+	_, ok = user.match("/user/42/orders")
+	assert.False(t, ok)
+
+	params, ok = files.match("/files/a/b.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "a/b.txt", params["0"])
+}
+
+func TestRouterPrecedence(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	files := rt.add(http.MethodGet, "/users/*", nil)
+	user := rt.add(http.MethodGet, "/users/:id", nil)
+	numeric := rt.add(http.MethodGet, `/users/:id(\d+)`, nil)
+	me := rt.add(http.MethodGet, "/users/me", nil)
+	orders := rt.add(http.MethodGet, "/users/:id/orders", nil)
+	static := rt.addStatic("/users/me")
+
+	assert.Equal(t, []*route{me, static, user, files}, rt.candidates(http.MethodGet, "/users/me", selector{}))
+	assert.Equal(t, []*route{numeric, user, files}, rt.candidates(http.MethodGet, "/users/42", selector{}))
+	assert.Equal(t, []*route{orders, files}, rt.candidates(http.MethodGet, "/users/42/orders", selector{}))
+
+	rt.prioritize(files, 10)
+
+	assert.Equal(t, []*route{files, me, static, user}, rt.candidates(http.MethodGet, "/users/me", selector{}))
+
+	rt.prioritize(user, 10)
+
+	assert.Equal(t, []*route{user, files, me, static}, rt.candidates(http.MethodGet, "/users/me", selector{}))
+
+	first := rt.add(http.MethodGet, "/items/:id", nil)
+	second := rt.add(http.MethodGet, "/items/:name", nil)
+
+	assert.Equal(t, []*route{first, second}, rt.candidates(http.MethodGet, "/items/1", selector{}))
+}
+
+func TestRouteConstraints(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	numeric := rt.add(http.MethodGet, `/users/:id(\d+)`, nil)
+	slug := rt.add(http.MethodGet, "/users/:slug([a-z-]+)", nil)
+	path := rt.add(http.MethodGet, "/files/:path(.+/.+)/raw", nil)
+
+	assert.Equal(t, []*route{numeric}, rt.candidates(http.MethodGet, "/users/42", selector{}))
+	assert.Equal(t, []*route{slug}, rt.candidates(http.MethodGet, "/users/jane-doe", selector{}))
+	assert.Empty(t, rt.candidates(http.MethodGet, "/users/Jane", selector{}))
+
+	params, ok := numeric.match("/users/42")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "42"}, params)
+
+	_, ok = numeric.match("/users/4a")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"files", ":path(.+/.+)", "raw"}, splitPattern(path.pattern))
+
+	assert.NoError(t, checkPath(`/users/:id(\d+)/orders`))
+	assert.Error(t, checkPath("/users/:id([0-9)"))
+}
+
+func TestRouteMatches(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	routes := []*route{
+		rt.add(http.MethodGet, "/", nil),
+		rt.add(http.MethodGet, "/user/:id", nil),
+		rt.add(http.MethodGet, "/user/:id/orders", nil),
+		rt.add(http.MethodGet, "/files/*", nil),
+		rt.add(http.MethodGet, `/user/:id(\d+)/orders`, nil),
+		rt.use("/api", nil),
+	}
+
+	paths := []string{"/", "", "/other", "/user", "/user/42", "/user/42/", "/user//orders", "/user/42/orders", "/user/x/orders",
+		"/files", "/files/a/b.txt", "/api", "/api/items"}
+
+	for _, r := range routes {
+		for _, path := range paths {
+			_, ok := r.match(path)
+
+			assert.Equal(t, ok, r.matches(path), "%s %s", r.pattern, path)
+		}
+	}
+}
+
+func BenchmarkRouterCandidates(b *testing.B) {
+	rt := newRouter()
+
+	for _, pattern := range []string{"/", "/users", "/users/:id", "/users/:id/orders", "/orders/:id", "/files/*"} {
+		rt.add(http.MethodGet, pattern, nil)
+		rt.add(http.MethodPost, pattern, nil)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rt.candidates(http.MethodGet, "/users/42/orders", selector{})
+	}
+}
+
+func TestRouterCandidates(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	get := rt.add(http.MethodGet, "/items", nil)
+	post := rt.add(http.MethodPost, "/items", nil)
+	static := rt.addStatic("/assets")
+
+	assert.Equal(t, []*route{get}, rt.candidates(http.MethodGet, "/items", selector{}))
+	assert.Equal(t, []*route{get}, rt.candidates(http.MethodHead, "/items", selector{}))
+	assert.Equal(t, []*route{post}, rt.candidates(http.MethodPost, "/items", selector{}))
+	assert.Empty(t, rt.candidates(http.MethodDelete, "/items", selector{}))
+	assert.Equal(t, []*route{static}, rt.candidates(http.MethodGet, "/assets/app.js", selector{}))
+	assert.Same(t, post, rt.get(post.id))
+	assert.Nil(t, rt.get(100))
+}
+
+func TestRouterChain(t *testing.T) {
+	t.Parallel()
+
+	handler := func(sobek.Value, ...sobek.Value) (sobek.Value, error) { return sobek.Undefined(), nil }
+	handlers := []sobek.Callable{handler}
+
+	rt := newRouter()
+
+	rt.use("/", handlers)
+	target := rt.add(http.MethodGet, "/api/items", []sobek.Callable{handler, handler})
+	rt.use("/api", handlers)
+	rt.use("/other", handlers)
+
+	assert.Len(t, rt.chain(target, "/api/items"), 4)
+	assert.Len(t, rt.chain(target, "/items"), 3)
+}
+
+func TestRouterFallback(t *testing.T) {
+	t.Parallel()
+
+	handler := func(sobek.Value, ...sobek.Value) (sobek.Value, error) { return sobek.Undefined(), nil }
+	handlers := []sobek.Callable{handler}
+
+	rt := newRouter()
+
+	rt.use("/", handlers)
+	rt.use("/api", []sobek.Callable{handler, handler})
+	rt.define("blue", func() { rt.use("/", handlers) })
+
+	assert.Len(t, rt.fallback("/api/missing", ""), 3)
+	assert.Len(t, rt.fallback("/missing", ""), 1)
+	assert.Len(t, rt.fallback("/missing", "blue"), 2)
+	assert.Empty(t, newRouter().fallback("/missing", ""))
+}
+
+func TestRouterVersions(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	common := rt.add(http.MethodGet, "/health", nil)
+
+	var blue, green *route
+
+	rt.define("blue", func() { blue = rt.add(http.MethodGet, "/items", nil) })
+	rt.define("green", func() {
+		rt.use("/", nil)
+		green = rt.add(http.MethodGet, "/items", nil)
+	})
+
+	assert.Equal(t, "blue", blue.version)
+	assert.Empty(t, rt.add(http.MethodGet, "/other", nil).version)
+
+	assert.Equal(t, []*route{blue}, rt.candidates(http.MethodGet, "/items", selector{version: "blue"}))
+	assert.Equal(t, []*route{green}, rt.candidates(http.MethodGet, "/items", selector{version: "green"}))
+	assert.Equal(t, []*route{common}, rt.candidates(http.MethodGet, "/health", selector{version: "green"}))
+	assert.Empty(t, rt.candidates(http.MethodGet, "/items", selector{}))
+}
+
+func TestRouterFork(t *testing.T) {
+	t.Parallel()
+
+	rt := newRouter()
+
+	calls := 0
+
+	var items *route
+
+	rt.with(func(r *route) { r.pattern = "/api" + r.pattern }, func() {
+		rt.fork(func(r *route) { r.api = "v1" }, func(r *route) { r.pattern = "/v1" + r.pattern }, func() {
+			calls++
+
+			items = rt.add(http.MethodGet, "/items", nil)
+		})
+	})
+
+	assert.Equal(t, 1, calls)
+	assert.Len(t, items.twins, 1)
+
+	twin := items.twins[0]
+
+	assert.Equal(t, "/api/items", items.pattern)
+	assert.Equal(t, "v1", items.api)
+	assert.Equal(t, "/api/v1/items", twin.pattern)
+	assert.Empty(t, twin.api)
+	assert.NotEqual(t, items.id, twin.id)
+
+	assert.Equal(t, []*route{items}, rt.candidates(http.MethodGet, "/api/items", selector{api: "v1"}))
+	assert.Empty(t, rt.candidates(http.MethodGet, "/api/items", selector{}))
+	assert.Equal(t, []*route{twin}, rt.candidates(http.MethodGet, "/api/v1/items", selector{}))
+
+	rt.prioritize(items, 5)
+
+	assert.Equal(t, 5, twin.priority)
+}
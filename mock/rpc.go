@@ -0,0 +1,319 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC-Web and Connect requests are translated to plain HTTP requests of the mock's routes.
+// The method path (e.g. /orders.v1.OrderService/GetOrder) is routed as a POST request, the handler
+// receives the request message as body (application/json or application/proto) and its response
+// is translated back to the protocol of the client.
+
+const (
+	rpcNone = iota
+	rpcGRPCWeb
+	rpcGRPCWebText
+	rpcConnect
+	rpcGRPC
+
+	grpcFrameHeader  = 5
+	grpcFrameTrailer = 0x80
+
+	headerGRPCStatus  = "Grpc-Status"
+	headerGRPCMessage = "Grpc-Message"
+
+	healthCheckPath = "/grpc.health.v1.Health/Check"
+)
+
+var errInvalidFrame = errors.New("invalid gRPC-Web frame")
+
+// rpcProtocol returns the RPC protocol of the request.
+func rpcProtocol(r *http.Request) int {
+	if r.Method != http.MethodPost {
+		return rpcNone
+	}
+
+	contentType := r.Header.Get("Content-Type")
+
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web-text"):
+		return rpcGRPCWebText
+	case strings.HasPrefix(contentType, "application/grpc-web"):
+		return rpcGRPCWeb
+	case strings.HasPrefix(contentType, "application/grpc"):
+		return rpcGRPC
+	case len(r.Header.Get("Connect-Protocol-Version")) != 0:
+		return rpcConnect
+	}
+
+	return rpcNone
+}
+
+// grpcCodes contains the gRPC status codes of HTTP statuses, as specified by the gRPC HTTP mapping.
+var grpcCodes = map[int]int{ // nolint:gochecknoglobals
+	http.StatusBadRequest:         13, // INTERNAL
+	http.StatusUnauthorized:       16, // UNAUTHENTICATED
+	http.StatusForbidden:          7,  // PERMISSION_DENIED
+	http.StatusNotFound:           12, // UNIMPLEMENTED
+	http.StatusTooManyRequests:    14, // UNAVAILABLE
+	http.StatusBadGateway:         14,
+	http.StatusServiceUnavailable: 14,
+	http.StatusGatewayTimeout:     14,
+}
+
+// connectCodes contains the Connect error codes of HTTP statuses.
+var connectCodes = map[int]string{ // nolint:gochecknoglobals
+	http.StatusBadRequest:          "invalid_argument",
+	http.StatusUnauthorized:        "unauthenticated",
+	http.StatusForbidden:           "permission_denied",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "already_exists",
+	http.StatusTooManyRequests:     "resource_exhausted",
+	http.StatusNotImplemented:      "unimplemented",
+	http.StatusServiceUnavailable:  "unavailable",
+	http.StatusGatewayTimeout:      "deadline_exceeded",
+	http.StatusInternalServerError: "internal",
+}
+
+// grpcStatus returns the gRPC status of the response. Handlers may set it explicitly by the
+// Grpc-Status and Grpc-Message headers, otherwise it is derived from the HTTP status.
+func grpcStatus(res *response) (int, string) {
+	message := res.header.Get(headerGRPCMessage)
+
+	if value := res.header.Get(headerGRPCStatus); len(value) != 0 {
+		if code, err := strconv.Atoi(value); err == nil {
+			return code, message
+		}
+	}
+
+	if res.status < http.StatusBadRequest {
+		return 0, message
+	}
+
+	if len(message) == 0 {
+		message = strings.TrimSpace(res.body.String())
+	}
+
+	if code, found := grpcCodes[res.status]; found {
+		return code, message
+	}
+
+	return 2, message // UNKNOWN
+}
+
+// readFrame returns the payload of the first data frame of the gRPC-Web request body.
+func readFrame(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if len(data) < grpcFrameHeader {
+		return nil, errInvalidFrame
+	}
+
+	size := binary.BigEndian.Uint32(data[1:grpcFrameHeader])
+	if uint32(len(data)-grpcFrameHeader) < size {
+		return nil, errInvalidFrame
+	}
+
+	return data[grpcFrameHeader : grpcFrameHeader+int(size)], nil
+}
+
+func writeFrame(buff *bytes.Buffer, flag byte, payload []byte) {
+	var header [grpcFrameHeader]byte
+
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	buff.Write(header[:])
+	buff.Write(payload)
+}
+
+// messageType returns the content type of the message carried by the gRPC-Web request.
+func messageType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (media == "application/json" || strings.HasSuffix(media, "+json")) {
+		return "application/json"
+	}
+
+	return "application/proto"
+}
+
+// healthServing is the grpc.health.v1.HealthCheckResponse message with SERVING status.
+var healthServing = map[string][]byte{ // nolint:gochecknoglobals
+	"application/json":  []byte(`{"status":"SERVING"}`),
+	"application/proto": {0x08, 0x01},
+}
+
+// routed reports whether the RPC request is served by a route, stub or extension of the mock.
+func (srv *server) routed(r *http.Request) bool {
+	return len(srv.router.candidates(r.Method, r.URL.Path, selector{})) != 0 || srv.stub(r) != nil ||
+		srv.extension(r) != nil
+}
+
+// builtinRPC serves the RPC request without route: the health check reports SERVING status and with
+// the rpcEcho option other methods echo the request message. It reports whether the request was served.
+func (srv *server) builtinRPC(res *response, r *http.Request) bool {
+	if (r.URL.Path != healthCheckPath && !srv.opts.rpcEcho) || srv.routed(r) {
+		return false
+	}
+
+	contentType := messageType(r.Header.Get("Content-Type"))
+
+	body := healthServing[contentType]
+	if r.URL.Path != healthCheckPath {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return false
+		}
+
+		body = data
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	res.WriteHeader(http.StatusOK)
+	res.Write(body) // nolint:errcheck,gosec
+
+	return true
+}
+
+// respondRPC translates gRPC, gRPC-Web and Connect requests, other requests are routed as is.
+func (srv *server) respondRPC(w http.ResponseWriter, r *http.Request) *route { // nolint:varnamelen
+	switch proto := rpcProtocol(r); proto {
+	case rpcGRPCWeb, rpcGRPCWebText:
+		return srv.grpcWeb(w, r, proto == rpcGRPCWebText)
+	case rpcConnect:
+		return srv.connect(w, r)
+	case rpcGRPC:
+		return srv.grpcNative(w, r)
+	default:
+		return srv.respond(w, r)
+	}
+}
+
+// grpcWeb serves the unary gRPC-Web request: the message is unframed before routing and the
+// response is framed with the trailers carrying the gRPC status.
+func (srv *server) grpcWeb(w http.ResponseWriter, r *http.Request, text bool) *route { // nolint:varnamelen
+	contentType := r.Header.Get("Content-Type")
+
+	data, err := io.ReadAll(r.Body)
+	if err == nil && text {
+		data, err = base64.StdEncoding.DecodeString(string(data))
+	}
+
+	if err == nil {
+		data, err = readFrame(data)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return nil
+	}
+
+	out := r.Clone(r.Context())
+
+	out.Body = io.NopCloser(bytes.NewReader(data))
+	out.ContentLength = int64(len(data))
+	out.Header.Set("Content-Type", messageType(contentType))
+	out.Header.Del("Content-Length")
+
+	res := acquireResponse()
+	defer res.release()
+
+	var matched *route
+
+	if !srv.builtinRPC(res, out) {
+		matched = srv.respond(res, out)
+	}
+
+	code, message := grpcStatus(res)
+
+	var body bytes.Buffer
+
+	if code == 0 && res.body.Len() != 0 {
+		writeFrame(&body, 0, res.body.Bytes())
+	}
+
+	trailer := fmt.Sprintf("grpc-status:%d\r\ngrpc-message:%s\r\n", code, message)
+
+	writeFrame(&body, grpcFrameTrailer, []byte(trailer))
+
+	header := w.Header()
+
+	for name, values := range res.header {
+		if name != "Content-Type" && name != "Content-Length" {
+			header[name] = values
+		}
+	}
+
+	header.Set("Content-Type", contentType)
+	header.Set(headerGRPCStatus, strconv.Itoa(code))
+
+	if len(message) != 0 {
+		header.Set(headerGRPCMessage, message)
+	}
+
+	if text {
+		encoded := base64.StdEncoding.EncodeToString(body.Bytes())
+
+		body.Reset()
+		body.WriteString(encoded)
+	}
+
+	header.Set("Content-Length", strconv.Itoa(body.Len()))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes()) // nolint:errcheck,gosec
+
+	return matched
+}
+
+// connect serves the Connect unary request. The request is routed as is, error responses without
+// JSON body are translated to Connect error messages.
+func (srv *server) connect(w http.ResponseWriter, r *http.Request) *route { // nolint:varnamelen
+	res := acquireResponse()
+	defer res.release()
+
+	var matched *route
+
+	if !srv.builtinRPC(res, r) {
+		matched = srv.respond(res, r)
+	}
+
+	if res.status >= http.StatusBadRequest && messageType(res.header.Get("Content-Type")) != "application/json" {
+		code, found := connectCodes[res.status]
+		if !found {
+			code = "unknown"
+		}
+
+		message := strings.TrimSpace(res.body.String())
+		if len(message) == 0 {
+			message = http.StatusText(res.status)
+		}
+
+		body, _ := json.Marshal(map[string]string{"code": code, "message": message}) // nolint:errchkjson
+
+		res.header.Set("Content-Type", "application/json")
+		res.body.Reset()
+		res.body.Write(body)
+	}
+
+	res.flush(w)
+
+	return matched
+}
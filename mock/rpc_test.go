@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCProtocol(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func(method, contentType, version string) *http.Request {
+		r := httptest.NewRequest(method, "/orders.v1.OrderService/GetOrder", nil)
+
+		r.Header.Set("Content-Type", contentType)
+
+		if len(version) != 0 {
+			r.Header.Set("Connect-Protocol-Version", version)
+		}
+
+		return r
+	}
+
+	assert.Equal(t, rpcGRPCWeb, rpcProtocol(newRequest(http.MethodPost, "application/grpc-web+json", "")))
+	assert.Equal(t, rpcGRPCWeb, rpcProtocol(newRequest(http.MethodPost, "application/grpc-web", "")))
+	assert.Equal(t, rpcGRPCWebText, rpcProtocol(newRequest(http.MethodPost, "application/grpc-web-text", "")))
+	assert.Equal(t, rpcGRPC, rpcProtocol(newRequest(http.MethodPost, "application/grpc", "")))
+	assert.Equal(t, rpcGRPC, rpcProtocol(newRequest(http.MethodPost, "application/grpc+proto", "")))
+	assert.Equal(t, rpcConnect, rpcProtocol(newRequest(http.MethodPost, "application/json", "1")))
+	assert.Equal(t, rpcNone, rpcProtocol(newRequest(http.MethodPost, "application/json", "")))
+	assert.Equal(t, rpcNone, rpcProtocol(newRequest(http.MethodGet, "application/grpc-web", "")))
+
+	assert.Equal(t, "application/json", messageType("application/grpc-web+json"))
+	assert.Equal(t, "application/json", messageType("application/json; charset=utf-8"))
+	assert.Equal(t, "application/proto", messageType("application/grpc-web+proto"))
+}
+
+func TestFrame(t *testing.T) {
+	t.Parallel()
+
+	var buff bytes.Buffer
+
+	writeFrame(&buff, 0, []byte(`{"id":"42"}`))
+
+	assert.Equal(t, []byte{0, 0, 0, 0, 11}, buff.Bytes()[:grpcFrameHeader])
+
+	payload, err := readFrame(buff.Bytes())
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"42"}`, string(payload))
+
+	_, err = readFrame(buff.Bytes()[:8])
+
+	assert.ErrorIs(t, err, errInvalidFrame)
+
+	_, err = readFrame([]byte{0, 0})
+
+	assert.ErrorIs(t, err, errInvalidFrame)
+}
+
+func TestGRPCStatus(t *testing.T) {
+	t.Parallel()
+
+	res := newResponse()
+	res.status = http.StatusOK
+
+	code, _ := grpcStatus(res)
+
+	assert.Equal(t, 0, code)
+
+	res.status = http.StatusNotFound
+	res.body.WriteString("Not Found\n")
+
+	code, message := grpcStatus(res)
+
+	assert.Equal(t, 12, code)
+	assert.Equal(t, "Not Found", message)
+
+	res.header.Set(headerGRPCStatus, "5")
+	res.header.Set(headerGRPCMessage, "order not found")
+
+	code, message = grpcStatus(res)
+
+	assert.Equal(t, 5, code)
+	assert.Equal(t, "order not found", message)
+}
+
+func TestGRPCWeb(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.post("/orders.v1.OrderService/GetOrder", (req, res) => res.json({ id: "42", status: "shipped" }))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	var body bytes.Buffer
+
+	writeFrame(&body, 0, []byte(`{"id":"42"}`))
+
+	res, err := req.R().
+		SetHeader("Content-Type", "application/grpc-web+json").
+		SetBody(body.Bytes()).
+		Post(helper.module.lookup["https://example.com"] + "/orders.v1.OrderService/GetOrder")
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.GetStatusCode())
+	assert.Equal(t, "0", res.GetHeader(headerGRPCStatus))
+	assert.Equal(t, "application/grpc-web+json", res.GetHeader("Content-Type"))
+}
+
+func TestBuiltinRPC(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.post("/orders.v1.OrderService/GetOrder", (req, res) => res.json({ id: "42", status: "shipped" }))
+}, { sync: true, rpcEcho: true })
+`)
+
+	require.NoError(t, err)
+
+	res, err := req.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Connect-Protocol-Version", "1").
+		SetBody(`{"service":"orders"}`).
+		Post(helper.module.lookup["https://example.com"] + healthCheckPath)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.GetStatusCode())
+
+	body, err := res.ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":"SERVING"}`, body)
+
+	res, err = req.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Connect-Protocol-Version", "1").
+		SetBody(`{"id":"42"}`).
+		Post(helper.module.lookup["https://example.com"] + "/orders.v1.OrderService/CancelOrder")
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.GetStatusCode())
+
+	body, err = res.ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"42"}`, body)
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+const samplerErrors = "errors"
+
+// sampler selects the requests to be recorded or logged: errors (status 400 or above), the first
+// requests of each route, and a random fraction of the rest.
+type sampler struct {
+	rate   float64 // fraction of the requests kept
+	errors bool    // keep the failed requests
+	first  int     // number of requests kept per route
+
+	mu   sync.Mutex
+	seen map[string]int // number of requests per route
+}
+
+// sampling defines the samplers of the request journal and the access log, nil samplers keep
+// every request of the journal and disable the access log.
+type sampling struct {
+	journal *sampler
+	log     *sampler
+}
+
+// getSampling parses the sampling option, journal and log properties are samplers.
+func getSampling(value sobek.Value) (*sampling, error) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil, nil
+	}
+
+	spec, isObj := value.Export().(map[string]interface{})
+	if !isObj {
+		return nil, fmt.Errorf("%w: sampling must be an object", errInvalidArg)
+	}
+
+	journal, err := getSampler(spec["journal"])
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := getSampler(spec["log"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &sampling{journal: journal, log: log}, nil
+}
+
+// getSampler parses the sampler: a number is the sampled fraction (0..1), "errors" keeps the failed
+// requests only. An object combines rate (fraction), errors (boolean) and first (requests kept per
+// route) properties, the rate is 0 if errors or first is given and 1 otherwise.
+func getSampler(value interface{}) (*sampler, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v != samplerErrors {
+			return nil, fmt.Errorf("%w: unknown sampler %q", errInvalidArg, v)
+		}
+
+		return newSampler(0, true, 0), nil
+	case map[string]interface{}:
+		errors, _ := v["errors"].(bool)
+		first := intKeyword(v, "first", 0)
+
+		rate := 1.0
+		if errors || first > 0 {
+			rate = 0
+		}
+
+		if n, ok := toFloat(v["rate"]); ok {
+			rate = n
+		}
+
+		return newSampler(rate, errors, first), nil
+	default:
+		if n, ok := toFloat(v); ok {
+			return newSampler(n, false, 0), nil
+		}
+
+		return nil, fmt.Errorf("%w: invalid sampler %v", errInvalidArg, v)
+	}
+}
+
+func newSampler(rate float64, errors bool, first int) *sampler {
+	return &sampler{rate: rate, errors: errors, first: first, seen: make(map[string]int)}
+}
+
+// sample reports whether the exchange is kept. Unmatched requests are counted as a single route.
+func (s *sampler) sample(record *entry) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.errors && record.status >= http.StatusBadRequest {
+		return true
+	}
+
+	if s.first > 0 {
+		key := record.method + " " + record.route
+
+		s.mu.Lock()
+		seen := s.seen[key]
+
+		if seen < s.first {
+			s.seen[key] = seen + 1
+		}
+
+		s.mu.Unlock()
+
+		if seen < s.first {
+			return true
+		}
+	}
+
+	return s.rate >= 1 || (s.rate > 0 && rand.Float64() < s.rate) // nolint:gosec
+}
+
+// record adds the exchange to the journal and the access log, as selected by the samplers.
+func (srv *server) record(record *entry) {
+	if srv.opts.sampling == nil {
+		srv.journal.add(record)
+
+		return
+	}
+
+	if srv.opts.sampling.journal.sample(record) {
+		srv.journal.add(record)
+	}
+
+	if srv.opts.sampling.log != nil && srv.opts.sampling.log.sample(record) {
+		srv.mod.logger.WithField("target", srv.target).
+			WithField("method", record.method).
+			WithField("path", record.path).
+			WithField("status", record.status).
+			WithField("route", record.route).
+			WithField("duration", record.duration).
+			Info("mock request")
+	}
+}
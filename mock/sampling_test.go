@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSampler(t *testing.T) {
+	t.Parallel()
+
+	s, err := getSampler(0.25)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0.25, s.rate)
+
+	s, err = getSampler("errors")
+
+	require.NoError(t, err)
+	assert.True(t, s.errors)
+	assert.Equal(t, 0.0, s.rate)
+
+	s, err = getSampler(map[string]interface{}{"first": int64(3)})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, s.first)
+	assert.Equal(t, 0.0, s.rate)
+
+	s, err = getSampler(map[string]interface{}{"errors": true, "rate": 0.1})
+
+	require.NoError(t, err)
+	assert.True(t, s.errors)
+	assert.Equal(t, 0.1, s.rate)
+
+	s, err = getSampler(nil)
+
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = getSampler("slow")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
+
+func TestSamplerSample(t *testing.T) {
+	t.Parallel()
+
+	var none *sampler
+
+	assert.True(t, none.sample(&entry{status: 200}))
+
+	errors := newSampler(0, true, 0)
+
+	assert.True(t, errors.sample(&entry{status: 503}))
+	assert.False(t, errors.sample(&entry{status: 200}))
+
+	first := newSampler(0, false, 2)
+	users := &entry{method: "GET", route: "/users/:id", status: 200}
+	orders := &entry{method: "GET", route: "/orders", status: 200}
+
+	assert.True(t, first.sample(users))
+	assert.True(t, first.sample(users))
+	assert.False(t, first.sample(users))
+	assert.True(t, first.sample(orders))
+
+	all := newSampler(1, false, 0)
+
+	assert.True(t, all.sample(users))
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"strings"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/lib"
+)
+
+// profile is a mock configuration bound to a scenario (or to a scenario exec function).
+// Its server is started on the first request of the scenario to the mock target.
+type profile struct {
+	args *mockArgs
+	srv  *server
+}
+
+// getStubFiles parses the stubs (or proto) option, it is a file name or an array of file names.
+func getStubFiles(value sobek.Value) []string {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	switch exported := value.Export().(type) {
+	case string:
+		return []string{exported}
+	case []interface{}:
+		files := make([]string, 0, len(exported))
+
+		for _, item := range exported {
+			if name, ok := item.(string); ok {
+				files = append(files, name)
+			}
+		}
+
+		return files
+	}
+
+	return nil
+}
+
+// defineProfiles registers the scenario profiles of the mock definition. Profile options are
+// merged with the options of the definition.
+func (mod *Module) defineProfiles(args *mockArgs) {
+	if args.object == nil {
+		return
+	}
+
+	profiles, isObj := args.object.Get("scenarios").(*sobek.Object)
+	if !isObj {
+		return
+	}
+
+	for _, name := range profiles.Keys() {
+		overrides, isObj := profiles.Get(name).(*sobek.Object)
+		if !isObj {
+			continue
+		}
+
+		merged := mod.runtime().NewObject()
+
+		for _, obj := range []*sobek.Object{args.object, overrides} {
+			for _, key := range obj.Keys() {
+				if key == "scenarios" || key == "env" || key == "name" || key == "deferred" {
+					continue
+				}
+
+				if err := merged.Set(key, obj.Get(key)); err != nil {
+					mod.throw(err)
+				}
+			}
+		}
+
+		if _, found := mod.profiles[name]; !found {
+			mod.profiles[name] = make(map[string]*profile)
+		}
+
+		mod.profiles[name][args.target] = &profile{args: &mockArgs{
+			target:   args.target,
+			callback: args.callback,
+			options:  getopts(merged),
+			stubs:    args.stubs,
+		}}
+	}
+}
+
+// scenarioKeys returns the profile keys of the current scenario: its name and its exec function name.
+func (mod *Module) scenarioKeys() []string {
+	state := mod.vu.State()
+	if state == nil {
+		return nil
+	}
+
+	scenario := lib.GetScenarioState(mod.vu.Context())
+	if scenario == nil {
+		return nil
+	}
+
+	keys := []string{scenario.Name}
+
+	if config, found := state.Options.Scenarios[scenario.Name]; found && config != nil {
+		keys = append(keys, config.GetExec())
+	}
+
+	return keys
+}
+
+// rewriteProfile returns the URL of the current scenario's profile server for the given location.
+// The profile server is started on its first use.
+func (mod *Module) rewriteProfile(loc string) (string, bool) {
+	if len(mod.profiles) == 0 {
+		return loc, false
+	}
+
+	for _, key := range mod.scenarioKeys() {
+		for target, prof := range mod.profiles[key] {
+			prefix, ok := matchTarget(target, loc)
+			if !ok {
+				continue
+			}
+
+			if prof.srv == nil {
+				prof.srv = mod.newMockServer(prof.args)
+			}
+
+			if prof.srv == nil {
+				return loc, false
+			}
+
+			return prof.srv.baseURL() + strings.TrimPrefix(loc, prefix), true
+		}
+	}
+
+	return loc, false
+}
+
+// unmockProfiles shuts down the profile servers of the given target.
+func (mod *Module) unmockProfiles(target string) {
+	for _, profiles := range mod.profiles {
+		prof, found := profiles[target]
+		if !found {
+			continue
+		}
+
+		delete(profiles, target)
+
+		if prof.srv == nil {
+			continue
+		}
+
+		if err := prof.srv.shutdown(); err != nil {
+			mod.throw(err)
+		}
+	}
+}
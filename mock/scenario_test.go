@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStubFiles(t *testing.T) {
+	t.Parallel()
+
+	runtime := newHelper(t).vu.Runtime()
+
+	assert.Nil(t, getStubFiles(nil))
+	assert.Equal(t, []string{"a.yaml"}, getStubFiles(runtime.ToValue("a.yaml")))
+	assert.Equal(t, []string{"a.yaml", "b.json"}, getStubFiles(runtime.ToValue([]interface{}{"a.yaml", "b.json"})))
+}
+
+func TestDefineProfiles(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {}, {
+	sync: true,
+	strict: true,
+	scenarios: {
+		degraded: { fuzz: 0.5 },
+		checkout: { strict: false },
+	},
+})
+`)
+
+	require.NoError(t, err)
+
+	require.Contains(t, helper.module.profiles, "degraded")
+	require.Contains(t, helper.module.profiles, "checkout")
+
+	degraded := helper.module.profiles["degraded"]["https://example.com"]
+
+	require.NotNil(t, degraded)
+	assert.Nil(t, degraded.srv)
+	assert.True(t, degraded.args.options.sync)
+	assert.True(t, degraded.args.options.strict)
+	require.NotNil(t, degraded.args.options.fuzz)
+
+	checkout := helper.module.profiles["checkout"]["https://example.com"]
+
+	require.NotNil(t, checkout)
+	assert.False(t, checkout.args.options.strict)
+	assert.Nil(t, checkout.args.options.fuzz)
+
+	helper.module.unmock(helper.vu.Runtime().ToValue("https://example.com"))
+
+	assert.Empty(t, helper.module.profiles["degraded"])
+}
@@ -0,0 +1,230 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/lib"
+)
+
+// scheduledFault is a fault of the fault schedule: requests of the route are failed with the given
+// probability while the elapsed time is within the window.
+type scheduledFault struct {
+	from, to  time.Duration // zero to means until the end of the test
+	route     *route        // nil means every request
+	errorRate float64
+	status    int
+	fault     string // transport fault instead of the status code response
+	delay     *delay
+}
+
+// faultSchedule is the chaos timeline of a mock server, the elapsed time is the current duration of
+// the test run (see testElapsed), so the timeline of every server (and VU) is aligned with the stages.
+type faultSchedule struct {
+	faults []*scheduledFault
+}
+
+// getFaultSchedule parses the faults option. The window of a fault is given by during ("2m-4m", the end
+// is optional) or by stage (name or index of the stages option, k6 stages with optional name property).
+func getFaultSchedule(value, stages sobek.Value) (*faultSchedule, error) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil, nil
+	}
+
+	specs, ok := value.Export().([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: faults must be an array", errInvalidArg)
+	}
+
+	windows := stageWindows(stages)
+	schedule := new(faultSchedule)
+
+	for _, item := range specs {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: fault must be an object", errInvalidArg)
+		}
+
+		fault, err := getScheduledFault(spec, windows)
+		if err != nil {
+			return nil, err
+		}
+
+		schedule.faults = append(schedule.faults, fault)
+	}
+
+	return schedule, nil
+}
+
+func getScheduledFault(spec map[string]interface{}, windows map[string][2]time.Duration) (*scheduledFault, error) {
+	fault := &scheduledFault{errorRate: 1, status: intKeyword(spec, "status", http.StatusServiceUnavailable)}
+
+	if rate, ok := toFloat(spec["errorRate"]); ok {
+		fault.errorRate = rate
+	}
+
+	fault.delay = getDelay(spec["delay"])
+
+	if kind, ok := spec["fault"].(string); ok {
+		var err error
+
+		if fault.fault, err = getTransportFault(kind); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case spec["during"] != nil:
+		from, to, err := parseWindow(fmt.Sprint(spec["during"]))
+		if err != nil {
+			return nil, err
+		}
+
+		fault.from, fault.to = from, to
+	case spec["stage"] != nil:
+		window, found := windows[fmt.Sprint(spec["stage"])]
+		if !found {
+			return nil, fmt.Errorf("%w: unknown stage %v", errInvalidArg, spec["stage"])
+		}
+
+		fault.from, fault.to = window[0], window[1]
+	}
+
+	if path, ok := spec["route"].(string); ok {
+		if err := checkPath(path); err != nil {
+			return nil, fmt.Errorf("%w: invalid parameter constraint of fault route: %s", errInvalidArg, err)
+		}
+
+		method, _ := spec["method"].(string)
+
+		fault.route = &route{method: strings.ToUpper(method), pattern: path, segments: compilePath(path)}
+	}
+
+	return fault, nil
+}
+
+// parseWindow parses an elapsed time window: start-end durations, the end may be omitted.
+func parseWindow(value string) (time.Duration, time.Duration, error) {
+	from, to, _ := strings.Cut(value, "-")
+
+	start, err := time.ParseDuration(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid fault window %s", errInvalidArg, value)
+	}
+
+	if len(strings.TrimSpace(to)) == 0 {
+		return start, 0, nil
+	}
+
+	end, err := time.ParseDuration(strings.TrimSpace(to))
+	if err != nil || end <= start {
+		return 0, 0, fmt.Errorf("%w: invalid fault window %s", errInvalidArg, value)
+	}
+
+	return start, end, nil
+}
+
+// stageWindows returns the elapsed time windows of the stages by name and by index.
+func stageWindows(value sobek.Value) map[string][2]time.Duration {
+	windows := make(map[string][2]time.Duration)
+
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return windows
+	}
+
+	stages, _ := value.Export().([]interface{})
+
+	var elapsed time.Duration
+
+	for idx, item := range stages {
+		stage, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		window := [2]time.Duration{elapsed, elapsed + duration(stage["duration"])}
+		elapsed = window[1]
+
+		windows[fmt.Sprint(idx)] = window
+
+		if name, ok := stage["name"].(string); ok {
+			windows[name] = window
+		}
+	}
+
+	return windows
+}
+
+// active returns the fault applied to the request at the elapsed time of the test run, nil if the
+// request is not failed.
+func (s *faultSchedule) active(r *http.Request, elapsed time.Duration) *scheduledFault {
+	for _, fault := range s.faults {
+		if elapsed < fault.from || (fault.to > 0 && elapsed >= fault.to) {
+			continue
+		}
+
+		if fault.route != nil {
+			if len(fault.route.method) != 0 && !fault.route.accepts(r.Method) {
+				continue
+			}
+
+			if !isMatch(fault.route, r.URL.Path) {
+				continue
+			}
+		}
+
+		if rand.Float64() < fault.errorRate { // nolint:gosec
+			return fault
+		}
+	}
+
+	return nil
+}
+
+// testElapsed returns the current duration of the test run (like exec.instance.currentTestRunDuration),
+// zero before the test run starts or while the execution state is not known yet.
+func (mod *Module) testElapsed() time.Duration {
+	if es := mod.execution.Load(); es != nil {
+		return es.GetCurrentTestRunDuration()
+	}
+
+	return 0
+}
+
+// trackExecution stores the execution state of the test run, so the fault schedules can read it on
+// the goroutines serving the requests. It is called on the VU's goroutine, the execution state is
+// not available in the init context.
+func (mod *Module) trackExecution() {
+	if mod.execution.Load() != nil || mod.vu.State() == nil {
+		return
+	}
+
+	if es := lib.GetExecutionState(mod.vu.Context()); es != nil {
+		mod.execution.Store(es)
+	}
+}
+
+// inject responds to the request with the fault, transport faults are injected by the server
+// after the response.
+func (fault *scheduledFault) inject(w http.ResponseWriter, r *http.Request) {
+	if fault.delay != nil {
+		sleepContext(r, fault.delay.sample())
+	}
+
+	if len(fault.fault) != 0 {
+		w.Header().Set(headerFault, fault.fault)
+	} else {
+		w.Header().Set(headerFaultStatus, strconv.Itoa(fault.status))
+	}
+
+	http.Error(w, "mock fault injected", fault.status)
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	t.Parallel()
+
+	from, to, err := parseWindow("2m-4m")
+
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, from)
+	assert.Equal(t, 4*time.Minute, to)
+
+	from, to, err = parseWindow("30s-")
+
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, from)
+	assert.Zero(t, to)
+
+	_, _, err = parseWindow("4m-2m")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+
+	_, _, err = parseWindow("soon")
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
+
+func TestScheduledFault(t *testing.T) {
+	t.Parallel()
+
+	windows := map[string][2]time.Duration{"peak": {time.Minute, 3 * time.Minute}}
+
+	fault, err := getScheduledFault(map[string]interface{}{"stage": "peak", "route": "/pay/:id", "method": "post"}, windows)
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, fault.from)
+	assert.Equal(t, 3*time.Minute, fault.to)
+	assert.Equal(t, http.StatusServiceUnavailable, fault.status)
+	assert.Equal(t, 1.0, fault.errorRate)
+
+	_, err = getScheduledFault(map[string]interface{}{"stage": "missing"}, windows)
+
+	assert.ErrorIs(t, err, errInvalidArg)
+
+	reset, err := getScheduledFault(map[string]interface{}{"fault": faultConnectionReset}, windows)
+
+	require.NoError(t, err)
+	assert.Equal(t, faultConnectionReset, reset.fault)
+
+	_, err = getScheduledFault(map[string]interface{}{"fault": "meltdown"}, windows)
+
+	assert.ErrorIs(t, err, errInvalidArg)
+
+	schedule := &faultSchedule{faults: []*scheduledFault{fault}}
+
+	post := httptest.NewRequest(http.MethodPost, "/pay/1", nil)
+
+	assert.Nil(t, schedule.active(post, 30*time.Second))
+	assert.Same(t, fault, schedule.active(post, 2*time.Minute))
+	assert.Nil(t, schedule.active(post, 3*time.Minute))
+	assert.Nil(t, schedule.active(httptest.NewRequest(http.MethodGet, "/pay/1", nil), 2*time.Minute))
+	assert.Nil(t, schedule.active(httptest.NewRequest(http.MethodPost, "/orders", nil), 2*time.Minute))
+
+	fault.errorRate = 0
+
+	assert.Nil(t, schedule.active(post, 2*time.Minute))
+
+	w := httptest.NewRecorder()
+
+	(&scheduledFault{status: http.StatusBadGateway}).inject(w, post)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
@@ -0,0 +1,503 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	defaultMaxItems  = 5
+	defaultMaxLength = 16
+	defaultMaxDepth  = 8
+	defaultOptional  = 0.5
+	defaultMaxNumber = 1000
+	maxReferenceHops = 32 // references resolving to references, independent of maxDepth
+
+	alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+var errInvalidSchema = errors.New("invalid schema")
+
+// generator creates random values conforming to a JSON Schema. OpenAPI schema extensions
+// (nullable, components references) are supported as well.
+type generator struct {
+	mu        sync.Mutex
+	root      map[string]interface{}
+	rand      *rand.Rand
+	minItems  int
+	maxItems  int
+	maxLength int
+	maxDepth  int
+	optional  float64
+}
+
+func newGenerator(root map[string]interface{}, seed int64) *generator {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &generator{
+		root:      root,
+		rand:      rand.New(rand.NewSource(seed)), // nolint:gosec
+		maxItems:  defaultMaxItems,
+		maxLength: defaultMaxLength,
+		maxDepth:  defaultMaxDepth,
+		optional:  defaultOptional,
+	}
+}
+
+func nonNegative(num int) int {
+	if num < 0 {
+		return 0
+	}
+
+	return num
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch num := value.(type) {
+	case float64:
+		return num, true
+	case float32:
+		return float64(num), true
+	case int:
+		return float64(num), true
+	case int64:
+		return float64(num), true
+	case int32:
+		return float64(num), true
+	}
+
+	return 0, false
+}
+
+func intKeyword(schema map[string]interface{}, name string, def int) int {
+	if num, ok := toFloat(schema[name]); ok {
+		return int(num)
+	}
+
+	return def
+}
+
+// resolve follows local references ("#/components/schemas/Name" style JSON pointers).
+func (g *generator) resolve(schema map[string]interface{}) (map[string]interface{}, error) {
+	for hop := 0; hop < maxReferenceHops; hop++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema, nil
+		}
+
+		var node interface{} = g.root
+
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+			if len(token) == 0 {
+				continue
+			}
+
+			token, _ = url.PathUnescape(token)
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+			obj, isObj := node.(map[string]interface{})
+			if !isObj {
+				return nil, fmt.Errorf("%w: unresolvable reference %s", errInvalidSchema, ref)
+			}
+
+			node = obj[token]
+		}
+
+		next, isObj := node.(map[string]interface{})
+		if !isObj {
+			return nil, fmt.Errorf("%w: unresolvable reference %s", errInvalidSchema, ref)
+		}
+
+		schema = next
+	}
+
+	return nil, fmt.Errorf("%w: too deep references", errInvalidSchema)
+}
+
+func schemaTypes(schema map[string]interface{}) []string {
+	var types []string
+
+	switch typ := schema["type"].(type) {
+	case string:
+		types = append(types, typ)
+	case []interface{}:
+		for _, item := range typ {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+
+	if len(types) == 0 {
+		switch {
+		case schema["properties"] != nil:
+			types = append(types, "object")
+		case schema["items"] != nil:
+			types = append(types, "array")
+		default:
+			types = append(types, "string")
+		}
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		types = append(types, "null")
+	}
+
+	return types
+}
+
+// merge combines allOf subschemas into a single schema.
+func (g *generator) merge(schema map[string]interface{}) (map[string]interface{}, error) {
+	all, ok := schema["allOf"].([]interface{})
+	if !ok {
+		return schema, nil
+	}
+
+	merged := make(map[string]interface{}, len(schema))
+	properties := make(map[string]interface{})
+
+	var required []interface{}
+
+	for key, value := range schema {
+		if key != "allOf" {
+			merged[key] = value
+		}
+	}
+
+	for _, item := range all {
+		sub, isObj := item.(map[string]interface{})
+		if !isObj {
+			continue
+		}
+
+		sub, err := g.resolve(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		if sub, err = g.merge(sub); err != nil {
+			return nil, err
+		}
+
+		for key, value := range sub {
+			merged[key] = value
+		}
+
+		if props, ok := sub["properties"].(map[string]interface{}); ok {
+			for key, value := range props {
+				properties[key] = value
+			}
+		}
+
+		if req, ok := sub["required"].([]interface{}); ok {
+			required = append(required, req...)
+		}
+	}
+
+	merged["properties"] = properties
+	merged["required"] = required
+
+	return merged, nil
+}
+
+func (g *generator) generate(schema map[string]interface{}) (interface{}, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value(schema, 0)
+}
+
+func (g *generator) value(schema map[string]interface{}, depth int) (interface{}, error) {
+	schema, err := g.resolve(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema, err = g.merge(schema); err != nil {
+		return nil, err
+	}
+
+	if value, ok := schema["const"]; ok {
+		return value, nil
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) != 0 {
+		return enum[g.rand.Intn(len(enum))], nil
+	}
+
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if choices, ok := schema[keyword].([]interface{}); ok && len(choices) != 0 {
+			if sub, isObj := choices[g.rand.Intn(len(choices))].(map[string]interface{}); isObj {
+				return g.value(sub, depth+1)
+			}
+		}
+	}
+
+	types := schemaTypes(schema)
+	typ := types[g.rand.Intn(len(types))]
+
+	if depth >= g.maxDepth && (typ == "object" || typ == "array") {
+		return nil, nil
+	}
+
+	switch typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		return g.rand.Intn(2) == 1, nil
+	case "integer":
+		return int64(math.Round(g.number(schema, true))), nil
+	case "number":
+		return g.number(schema, false), nil
+	case "array":
+		return g.array(schema, depth)
+	case "object":
+		return g.object(schema, depth)
+	default:
+		return g.string(schema), nil
+	}
+}
+
+func (g *generator) number(schema map[string]interface{}, integer bool) float64 {
+	low, high := 0.0, float64(defaultMaxNumber)
+
+	if num, ok := toFloat(schema["minimum"]); ok {
+		low = num
+		if high < low {
+			high = low + defaultMaxNumber
+		}
+	}
+
+	if num, ok := toFloat(schema["maximum"]); ok {
+		high = num
+		if low > high {
+			low = high - defaultMaxNumber
+		}
+	}
+
+	if num, ok := toFloat(schema["exclusiveMinimum"]); ok {
+		low = math.Nextafter(num, math.Inf(1))
+		if integer {
+			low = math.Floor(num) + 1
+		}
+	}
+
+	if num, ok := toFloat(schema["exclusiveMaximum"]); ok {
+		high = math.Nextafter(num, math.Inf(-1))
+		if integer {
+			high = math.Ceil(num) - 1
+		}
+	}
+
+	if integer {
+		low, high = math.Ceil(low), math.Floor(high)
+	}
+
+	value := low + g.rand.Float64()*(high-low)
+
+	if step, ok := toFloat(schema["multipleOf"]); ok && step > 0 {
+		value = math.Ceil(low/step)*step + math.Floor((value-low)/step)*step
+		if value > high {
+			value = math.Ceil(low/step) * step
+		}
+	}
+
+	return value
+}
+
+// length returns a random length within the bounds of the schema, negative bounds are clamped to 0.
+func (g *generator) length(schema map[string]interface{}, minKey, maxKey string, def, maxDef int) int {
+	low := nonNegative(intKeyword(schema, minKey, def))
+	high := nonNegative(intKeyword(schema, maxKey, maxDef))
+
+	if high < low {
+		high = low
+	}
+
+	return low + g.rand.Intn(high-low+1)
+}
+
+func (g *generator) string(schema map[string]interface{}) string {
+	switch schema["format"] {
+	case "date-time":
+		return time.Unix(g.rand.Int63n(time.Now().Unix()), 0).UTC().Format(time.RFC3339)
+	case "date":
+		return time.Unix(g.rand.Int63n(time.Now().Unix()), 0).UTC().Format(time.DateOnly)
+	case "email":
+		return g.word(8) + "@example.com"
+	case "uri", "url":
+		return "https://example.com/" + g.word(8)
+	case "hostname":
+		return g.word(8) + ".example.com"
+	case "ipv4":
+		return fmt.Sprintf("10.%d.%d.%d", g.rand.Intn(256), g.rand.Intn(256), g.rand.Intn(256))
+	case "uuid":
+		return fmt.Sprintf("%08x-%04x-4%03x-%04x-%012x",
+			g.rand.Uint32(), g.rand.Intn(0x10000), g.rand.Intn(0x1000), 0x8000|g.rand.Intn(0x4000), g.rand.Int63n(1<<48))
+	}
+
+	minLength := intKeyword(schema, "minLength", 1)
+	maxLength := g.maxLength
+
+	if maxLength < minLength {
+		maxLength = minLength
+	}
+
+	return g.word(g.length(schema, "minLength", "maxLength", minLength, maxLength))
+}
+
+func (g *generator) word(length int) string {
+	var buff strings.Builder
+
+	for idx := 0; idx < length; idx++ {
+		buff.WriteByte(alphabet[g.rand.Intn(len(alphabet))])
+	}
+
+	return buff.String()
+}
+
+func (g *generator) array(schema map[string]interface{}, depth int) (interface{}, error) {
+	items, _ := schema["items"].(map[string]interface{})
+	if items == nil {
+		items = map[string]interface{}{}
+	}
+
+	minItems := g.minItems
+	if minItems > g.maxItems {
+		minItems = g.maxItems
+	}
+
+	count := g.length(schema, "minItems", "maxItems", intKeyword(schema, "minItems", minItems), g.maxItems)
+	list := make([]interface{}, 0, count)
+
+	for idx := 0; idx < count; idx++ {
+		value, err := g.value(items, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, value)
+	}
+
+	return list, nil
+}
+
+func (g *generator) object(schema map[string]interface{}, depth int) (interface{}, error) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	required := make(map[string]bool)
+
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	obj := make(map[string]interface{}, len(names))
+
+	for _, name := range names {
+		if !required[name] && g.rand.Float64() >= g.optional {
+			continue
+		}
+
+		sub, _ := properties[name].(map[string]interface{})
+		if sub == nil {
+			sub = map[string]interface{}{}
+		}
+
+		value, err := g.value(sub, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		obj[name] = value
+	}
+
+	return obj, nil
+}
+
+// generate returns a random value conforming to the given JSON Schema.
+// The optional second parameter may contain generator options.
+func (mod *Module) generate(call sobek.FunctionCall) sobek.Value {
+	root, ok := call.Argument(0).Export().(map[string]interface{})
+	if !ok {
+		mod.throwf("schema must be an object", errInvalidArg)
+	}
+
+	schema := root
+	gen := newGenerator(root, 0)
+
+	if opts, isObj := call.Argument(1).(*sobek.Object); isObj {
+		gen.configure(opts)
+
+		if ref := opts.Get("ref"); ref != nil && !sobek.IsUndefined(ref) {
+			schema = map[string]interface{}{"$ref": ref.String()}
+		}
+	}
+
+	value, err := gen.generate(schema)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	return mod.runtime().ToValue(value)
+}
+
+func (g *generator) configure(opts *sobek.Object) {
+	number := func(name string) (float64, bool) {
+		v := opts.Get(name)
+		if v == nil || sobek.IsUndefined(v) || sobek.IsNull(v) {
+			return 0, false
+		}
+
+		return v.ToFloat(), true
+	}
+
+	if seed, ok := number("seed"); ok {
+		g.rand = rand.New(rand.NewSource(int64(seed))) // nolint:gosec
+	}
+
+	if num, ok := number("minItems"); ok {
+		g.minItems = nonNegative(int(num))
+	}
+
+	if num, ok := number("maxItems"); ok {
+		g.maxItems = nonNegative(int(num))
+	}
+
+	if num, ok := number("maxLength"); ok {
+		g.maxLength = nonNegative(int(num))
+	}
+
+	if num, ok := number("maxDepth"); ok {
+		g.maxDepth = nonNegative(int(num))
+	}
+
+	if num, ok := number("optional"); ok {
+		g.optional = num
+	}
+}
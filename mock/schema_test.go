@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSchema(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+
+	var schema map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(text), &schema))
+
+	return schema
+}
+
+func TestGenerator(t *testing.T) {
+	t.Parallel()
+
+	root := parseSchema(t, `{
+	  "components": {
+	    "schemas": {
+	      "Order": {
+	        "type": "object",
+	        "required": ["id", "status", "items", "created"],
+	        "properties": {
+	          "id": {"type": "integer", "minimum": 1, "maximum": 10},
+	          "status": {"enum": ["new", "shipped"]},
+	          "created": {"type": "string", "format": "date-time"},
+	          "note": {"type": "string", "nullable": true},
+	          "items": {"type": "array", "minItems": 1, "maxItems": 3, "items": {"$ref": "#/components/schemas/Item"}}
+	        }
+	      },
+	      "Item": {
+	        "allOf": [
+	          {"type": "object", "required": ["sku"], "properties": {"sku": {"type": "string", "minLength": 4, "maxLength": 4}}},
+	          {"required": ["price"], "properties": {"price": {"type": "number", "exclusiveMinimum": 0, "maximum": 5}}}
+	        ]
+	      }
+	    }
+	  }
+	}`)
+
+	gen := newGenerator(root, 1)
+
+	for idx := 0; idx < 20; idx++ {
+		value, err := gen.generate(map[string]interface{}{"$ref": "#/components/schemas/Order"})
+
+		require.NoError(t, err)
+
+		order, ok := value.(map[string]interface{})
+
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, order["id"], int64(1))
+		assert.LessOrEqual(t, order["id"], int64(10))
+		assert.Contains(t, []interface{}{"new", "shipped"}, order["status"])
+		assert.Regexp(t, `^\d{4}-\d{2}-\d{2}T`, order["created"])
+
+		items, ok := order["items"].([]interface{})
+
+		require.True(t, ok)
+		assert.NotEmpty(t, items)
+		assert.LessOrEqual(t, len(items), 3)
+
+		for _, value := range items {
+			item, ok := value.(map[string]interface{})
+
+			require.True(t, ok)
+			assert.Len(t, item["sku"], 4)
+			assert.Greater(t, item["price"], 0.0)
+			assert.LessOrEqual(t, item["price"], 5.0)
+		}
+	}
+
+	_, err := gen.generate(map[string]interface{}{"$ref": "#/components/schemas/Missing"})
+
+	assert.ErrorIs(t, err, errInvalidSchema)
+}
+
+func TestGeneratorBounds(t *testing.T) {
+	t.Parallel()
+
+	gen := newGenerator(nil, 1)
+
+	gen.maxItems = 2
+	gen.maxLength = 3
+
+	value, err := gen.generate(parseSchema(t, `{"type": "array", "items": {"type": "string"}}`))
+
+	require.NoError(t, err)
+
+	list, ok := value.([]interface{})
+
+	require.True(t, ok)
+	assert.LessOrEqual(t, len(list), 2)
+
+	for _, item := range list {
+		assert.LessOrEqual(t, len(item.(string)), 3) // nolint:forcetypeassert
+	}
+
+	value, err = gen.generate(parseSchema(t, `{"type": "integer", "minimum": 10, "multipleOf": 5}`))
+
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, value, int64(10))
+	assert.Zero(t, value.(int64)%5) // nolint:forcetypeassert
+
+	value, err = gen.generate(parseSchema(t, `{"type": "array", "minItems": -3, "maxItems": -1, "items": {"type": "string"}}`))
+
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	value, err = gen.generate(parseSchema(t, `{"type": "string", "minLength": -5, "maxLength": -2}`))
+
+	require.NoError(t, err)
+	assert.Empty(t, value)
+}
+
+func TestGeneratorReferences(t *testing.T) {
+	t.Parallel()
+
+	root := parseSchema(t, `{
+		"definitions": {"Name": {"$ref": "#/definitions/Text"}, "Text": {"type": "string", "maxLength": 4}}
+	}`)
+
+	gen := newGenerator(root, 1)
+
+	gen.maxDepth = 0
+
+	value, err := gen.generate(map[string]interface{}{"$ref": "#/definitions/Name"})
+
+	require.NoError(t, err)
+	assert.IsType(t, "", value)
+
+	root["definitions"].(map[string]interface{})["Loop"] = map[string]interface{}{"$ref": "#/definitions/Loop"} // nolint:forcetypeassert
+
+	_, err = gen.generate(map[string]interface{}{"$ref": "#/definitions/Loop"})
+
+	assert.ErrorIs(t, err, errInvalidSchema)
+}
@@ -6,10 +6,15 @@ package mock
 
 import (
 	"testing"
+	"time"
 
 	"github.com/grafana/sobek"
 	"github.com/imroc/req/v3"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
 )
 
 type scriptSuite struct {
@@ -59,7 +64,7 @@ mock("https://example.com", handler, {sync:true})
 `)
 
 	suite.Run("mock", func() {
-		suite.Equal(1, len(suite.module.apps))
+		suite.Equal(1, len(suite.module.servers))
 		suite.Equal(1, len(suite.module.lookup))
 
 		args := []sobek.Value{suite.vu.Runtime().ToValue("https://example.com")}
@@ -81,7 +86,7 @@ mock("https://example.com", handler, {sync:true})
 		suite.js(`unmock("https://example.com")`)
 
 		suite.Empty(suite.module.lookup)
-		suite.Empty(suite.module.apps)
+		suite.Empty(suite.module.servers)
 
 		args := []sobek.Value{suite.vu.Runtime().ToValue("https://example.com")}
 
@@ -105,7 +110,7 @@ mock("http://127.0.0.1", handler, {sync:true})
 // !js
 `)
 
-	suite.Equal(2, len(suite.module.apps))
+	suite.Equal(2, len(suite.module.servers))
 	suite.Equal(2, len(suite.module.lookup))
 
 	args := []sobek.Value{suite.vu.Runtime().ToValue("http://localhost")}
@@ -153,5 +158,117 @@ mock("https://after.example.com", handler, { skip:true })
 // !js
 `)
 
-	suite.Empty(suite.module.apps)
+	suite.Empty(suite.module.servers)
+}
+
+func (suite *scriptSuite) TestScriptStrict() {
+	suite.js(`
+// js
+mock("https://strict.example.com", app => {
+	app.get('/', (req, res) => {
+		res.text("Hello World!")
+  })
+}, {sync:true, strict:"abort"})
+// !js
+`)
+
+	defer suite.js(`unmock("https://strict.example.com")`)
+
+	srv := suite.module.servers["https://strict.example.com"]
+
+	suite.True(srv.opts.strict)
+	suite.True(srv.opts.abort)
+
+	samples := make(chan metrics.SampleContainer, 100)
+
+	suite.vu.StateField = &lib.State{ // nolint:exhaustruct
+		Samples: samples,
+		Tags:    lib.NewVUStateTags(suite.vu.InitEnvField.Registry.RootTagSet()),
+		Logger:  logrus.StandardLogger(),
+	}
+
+	defer func() { suite.vu.StateField = nil }()
+
+	args := []sobek.Value{suite.vu.Runtime().ToValue("https://strict.example.com/")}
+
+	suite.module.rewrite(args, 0)
+
+	res, err := req.Get(args[0].String())
+
+	suite.NoError(err)
+	suite.Equal(200, res.GetStatusCode())
+
+	args = []sobek.Value{suite.vu.Runtime().ToValue("https://strict.example.com/missing")}
+
+	suite.module.rewrite(args, 0)
+
+	res, err = req.Get(args[0].String())
+
+	suite.NoError(err)
+	suite.Equal(404, res.GetStatusCode())
+
+	// the exchange is published last, the samples of the request were pushed by then
+	suite.Eventually(func() bool {
+		for _, ev := range suite.module.hub.since(0) {
+			if ev.Target == "https://strict.example.com" && ev.Path == "/missing" {
+				return true
+			}
+		}
+
+		return false
+	}, time.Second, 5*time.Millisecond)
+
+	var unmatched []metrics.Sample
+
+	for _, container := range metrics.GetBufferedSamples(samples) {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric == suite.module.metrics.unmatched {
+				unmatched = append(unmatched, sample)
+			}
+		}
+	}
+
+	suite.Require().Len(unmatched, 1)
+	suite.InDelta(1.0, unmatched[0].Value, 0)
+
+	target, _ := unmatched[0].Tags.Get("mock")
+	method, _ := unmatched[0].Tags.Get("method")
+
+	suite.Equal("https://strict.example.com", target)
+	suite.Equal("GET", method)
+
+	// the test run is aborted from the VU's event loop
+	_, err = suite.runtime.RunOnEventLoop(``)
+
+	var interrupt *errext.InterruptError
+
+	suite.Require().ErrorAs(err, &interrupt)
+	suite.Equal("unmatched mock request: GET https://strict.example.com/missing", interrupt.Reason)
+}
+
+func (suite *scriptSuite) TestScriptExpect() {
+	suite.js(`
+// js
+mock("https://expect.example.com", app => {
+	app.post('/orders', (req, res) => {
+		res.json({ id: 1 })
+  })
+}, {sync:true})
+// !js
+`)
+
+	defer suite.js(`unmock("https://expect.example.com")`)
+
+	args := []sobek.Value{suite.vu.Runtime().ToValue("https://expect.example.com/orders")}
+
+	suite.module.rewrite(args, 0)
+
+	_, err := req.R().SetHeader("Idempotency-Key", "abc").Post(args[0].String())
+
+	suite.NoError(err)
+
+	suite.True(suite.js(`mock.expect("https://expect.example.com").post('/orders').withHeader('Idempotency-Key').atLeast(1)`).ToBoolean())
+	suite.True(suite.js(`mock.expect("https://expect.example.com").post('/orders').withHeader('Idempotency-Key', 'abc').once()`).ToBoolean())
+	suite.True(suite.js(`mock.expect("https://expect.example.com").get('/orders').never()`).ToBoolean())
+	suite.False(suite.js(`mock.expect("https://expect.example.com").post('/orders').times(2)`).ToBoolean())
 }
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// sequence serves its responses one after the other on successive calls. After the last response it
+// starts over if it loops, otherwise the last response is repeated.
+type sequence struct {
+	mu        sync.Mutex
+	responses []*stubResponse
+	loop      bool
+	next      int
+}
+
+// getSequence parses the responses of the sequence, each of them is a response specification (see
+// getStubResponse).
+func getSequence(value interface{}, loop bool) (*sequence, error) {
+	list, isList := value.([]interface{})
+	if !isList || len(list) == 0 {
+		return nil, errInvalidArg
+	}
+
+	seq := &sequence{responses: make([]*stubResponse, 0, len(list)), loop: loop}
+
+	for _, item := range list {
+		spec, isMap := item.(map[string]interface{})
+		if !isMap {
+			return nil, errInvalidArg
+		}
+
+		res, err := getStubResponse(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		seq.responses = append(seq.responses, res)
+	}
+
+	return seq, nil
+}
+
+// take returns the response of the current call and advances the sequence.
+func (seq *sequence) take() *stubResponse {
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+
+	res := seq.responses[seq.next]
+
+	switch {
+	case seq.next+1 < len(seq.responses):
+		seq.next++
+	case seq.loop:
+		seq.next = 0
+	}
+
+	return res
+}
+
+func (seq *sequence) reset() {
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+
+	seq.next = 0
+}
+
+// sequence returns a route handler serving the given responses on successive calls. The loop option
+// starts the sequence over after the last response. The handler's reset method restarts the sequence.
+func (mod *Module) sequence(responses sobek.Value, opts sobek.Value) sobek.Value {
+	runtime := mod.runtime()
+
+	loop := false
+	if obj, isObj := opts.(*sobek.Object); isObj {
+		if v := obj.Get("loop"); v != nil {
+			loop = v.ToBoolean()
+		}
+	}
+
+	seq, err := getSequence(responses.Export(), loop)
+	if err != nil {
+		mod.throwf("sequence must be a non-empty array of responses", errInvalidArg)
+	}
+
+	handler := runtime.ToValue(func(call sobek.FunctionCall) sobek.Value {
+		mod.respond(call.Argument(1).ToObject(runtime), seq.take())
+
+		return sobek.Undefined()
+	}).(*sobek.Object) // nolint:forcetypeassert
+
+	if err := handler.Set("reset", seq.reset); err != nil {
+		mod.throw(err)
+	}
+
+	return handler
+}
+
+// respond sends the response through the response object of the application.
+func (mod *Module) respond(res *sobek.Object, spec *stubResponse) {
+	runtime := mod.runtime()
+
+	call := func(name string, args ...interface{}) {
+		fn, ok := sobek.AssertFunction(res.Get(name))
+		if !ok {
+			mod.throwf("missing %s method", errInvalidArg, name)
+		}
+
+		values := make([]sobek.Value, 0, len(args))
+
+		for _, arg := range args {
+			values = append(values, runtime.ToValue(arg))
+		}
+
+		if _, err := fn(res, values...); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	call("status", spec.Status)
+
+	for _, name := range sortedKeys(spec.Headers) {
+		call("set", name, spec.Headers[name])
+	}
+
+	call("send", spec.Body)
+}
@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequence(t *testing.T) {
+	t.Parallel()
+
+	responses := []interface{}{
+		map[string]interface{}{"status": int64(http.StatusAccepted)},
+		map[string]interface{}{"status": int64(http.StatusOK), "body": map[string]interface{}{"done": true}},
+	}
+
+	statuses := func(seq *sequence, n int) []int {
+		var all []int
+
+		for i := 0; i < n; i++ {
+			all = append(all, seq.take().Status)
+		}
+
+		return all
+	}
+
+	seq, err := getSequence(responses, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, []int{202, 200, 200, 200}, statuses(seq, 4))
+	assert.Equal(t, `{"done":true}`, seq.responses[1].Body)
+	assert.Equal(t, "application/json", seq.responses[1].Headers["Content-Type"])
+
+	seq.reset()
+
+	assert.Equal(t, []int{202}, statuses(seq, 1))
+
+	seq, err = getSequence(responses, true)
+
+	require.NoError(t, err)
+	assert.Equal(t, []int{202, 200, 202, 200, 202}, statuses(seq, 5))
+
+	_, err = getSequence([]interface{}{}, false)
+
+	assert.ErrorIs(t, err, errInvalidArg)
+
+	_, err = getSequence([]interface{}{"ok"}, false)
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
@@ -0,0 +1,619 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/errext"
+)
+
+const readHeaderTimeout = 10 * time.Second
+
+var errNotStarted = errors.New("mock server not started")
+
+// server is the HTTP front of a mock definition. It routes incoming requests using the
+// mock's own router and forwards them to the muxpress application listening on a private address.
+type server struct {
+	id        string
+	token     string // authorizes stopping the server by its handle
+	mod       *Module
+	target    string
+	app       *sobek.Object
+	post      sobek.Callable
+	opts      *options
+	router    *router
+	journal   *journal
+	clock     *clock
+	cache     *cache
+	regions   *regions
+	state     *stateStore
+	deploy    *deployment
+	api       *negotiation
+	stubs     []*stub
+	spyMu     sync.RWMutex
+	spies     []*spy
+	datasetMu sync.RWMutex
+	datasets  []*dataset
+	sceneMu   sync.Mutex
+	scenes    map[string]*scenario
+	hooks     *hooks
+	extMu     sync.RWMutex
+	exts      []*extension
+	trans     *transformers
+	links     *linkRewriter
+	cookies   *cookieRewriter
+	html      *htmlRewriter
+	retries   *retryDetector
+	tls       *tlsListener
+	conns     *connTracker
+	warmup    *warmup
+	health    *healthKit
+	recording *recording
+	schedule  *faultSchedule
+	bodies    *bodyStore
+	proto     *protoSchema
+	capture   *capture
+	usage     *usageMonitor
+	proxy     *httputil.ReverseProxy
+	methods   []boundMethod // response methods, see bindResponse
+	template  sobek.Value   // template method of the routed responses
+	pending   func() error  // lazy application start
+	once      sync.Once
+	listener  net.Listener
+	http      *http.Server
+}
+
+func (mod *Module) newServer(target string, app *sobek.Object, opts *options) *server {
+	srv := &server{
+		id:      randomID(),
+		token:   randomID(),
+		mod:     mod,
+		target:  target,
+		app:     app,
+		opts:    opts,
+		router:  newRouter(),
+		journal: newJournal(journalLimit),
+		clock:   newClock(time.Now),
+		regions: opts.regions,
+		state:   newStateStore(),
+		deploy:  newDeployment(),
+		hooks:   newHooks(),
+		trans:   newTransformers(),
+		conns:   newConnTracker(),
+		warmup:  newWarmup(opts.warmup),
+		bodies:  newBodyStore(),
+	}
+
+	if opts.faultsErr != nil {
+		mod.throw(opts.faultsErr)
+	}
+
+	srv.schedule = opts.faults
+	mod.trackExecution()
+
+	if opts.journalErr != nil {
+		mod.throw(opts.journalErr)
+	}
+
+	if opts.samplingErr != nil {
+		mod.throw(opts.samplingErr)
+	}
+
+	if opts.journal != nil {
+		if err := srv.journal.configure(opts.journal); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	srv.connMetrics()
+
+	srv.clock.skew(opts.offset, opts.drift)
+
+	if opts.cookies != nil {
+		srv.cookies = opts.cookies.forTarget(target)
+	}
+
+	if opts.html != nil {
+		srv.html = opts.html.forTarget(target)
+	}
+
+	if opts.cache || opts.cdn != nil {
+		srv.cache = newCache(opts.cdn)
+	}
+
+	if opts.retryStorm != nil {
+		srv.retries = opts.retryStorm.clone()
+	}
+
+	if opts.tls != nil {
+		listener, err := newTLSListener(target, opts.tls)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		srv.tls = listener
+	}
+
+	if opts.health != nil {
+		srv.health = newHealthKit(opts.health.liveness.pattern, opts.health.readiness.pattern)
+	}
+
+	return srv
+}
+
+// launch runs the mock definition callback and starts the application, requests are forwarded to it.
+func (srv *server) launch(callback, listen sobek.Callable) error {
+	if _, err := callback(srv.mod.runtime().GlobalObject(), srv.app); err != nil {
+		return err
+	}
+
+	if err := srv.mount(); err != nil {
+		return err
+	}
+
+	if _, err := listen(srv.app); err != nil {
+		return err
+	}
+
+	host := srv.app.Get("host")
+	if host == nil || len(host.String()) == 0 {
+		return errNotStarted
+	}
+
+	backend, err := url.Parse("http://" + host.String())
+	if err != nil {
+		return err
+	}
+
+	srv.proxy = httputil.NewSingleHostReverseProxy(backend)
+	srv.proxy.ErrorHandler = srv.proxyError
+
+	return nil
+}
+
+// bind starts listening on the mock server's own address.
+func (srv *server) bind() error {
+	var err error
+
+	srv.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	if srv.opts.capture != nil {
+		if srv.capture, err = srv.mod.registry.openCapture(srv.opts.capture); err != nil {
+			return err
+		}
+
+		srv.listener = srv.capture.wrap(srv.listener)
+
+		if srv.tls != nil {
+			srv.tls.config.KeyLogWriter = srv.capture
+		}
+	}
+
+	srv.listener = &trackingListener{Listener: srv.listener, tracker: srv.conns}
+
+	if srv.tls != nil {
+		srv.listener = srv.tls.wrap(srv.listener)
+	}
+
+	if srv.opts.links != nil {
+		srv.links = newLinkRewriter(srv.linkMapping())
+	}
+
+	srv.http = &http.Server{Handler: srv, ReadHeaderTimeout: readHeaderTimeout, ConnContext: connContext} // nolint:exhaustruct
+
+	if srv.tls != nil {
+		if err := configureHTTP2(srv.http); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		if err := srv.http.Serve(srv.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			srv.mod.logger.WithError(err).WithField("target", srv.target).Error("mock server stopped")
+		}
+	}()
+
+	if srv.opts.overload != nil {
+		srv.usage = newUsageMonitor(srv.opts.overload)
+		srv.usage.start(srv.observeUsage)
+	}
+
+	return nil
+}
+
+func (srv *server) addr() string {
+	return srv.listener.Addr().String()
+}
+
+func (srv *server) shutdown() error {
+	if err := srv.stop(); err != nil {
+		return err
+	}
+
+	return srv.shutdownApp()
+}
+
+// stop stops the front server and the background tasks of the server. Unlike the application, they
+// can be stopped from any goroutine.
+func (srv *server) stop() error {
+	srv.deploy.stop()
+
+	if srv.usage != nil {
+		srv.usage.stop()
+	}
+
+	if srv.http != nil {
+		if err := srv.http.Shutdown(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	if srv.capture != nil {
+		if err := srv.mod.registry.closeCapture(srv.capture); err != nil {
+			return err
+		}
+	}
+
+	return srv.journal.close()
+}
+
+// shutdownApp shuts down the application, it must run on the event loop of the VU defining the server.
+func (srv *server) shutdownApp() error {
+	// the application of a lazy server may not have been started
+	if srv.proxy == nil {
+		return nil
+	}
+
+	shutdown, ok := sobek.AssertFunction(srv.app.Get("shutdown"))
+	if !ok {
+		return nil
+	}
+
+	_, err := shutdown(srv.app)
+
+	return err
+}
+
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) { // nolint:varnamelen
+	if !srv.ready() {
+		http.Error(w, errNotStarted.Error(), http.StatusBadGateway)
+
+		return
+	}
+
+	if isWebSocket(r) {
+		if endpoint := srv.socket(r); endpoint != nil {
+			srv.serveWS(w, r, endpoint)
+
+			return
+		}
+	}
+
+	if r.Method == http.MethodConnect {
+		srv.serveConnect(w, r)
+
+		return
+	}
+
+	if protocol := upgradeProtocol(r); len(protocol) != 0 && srv.serveUpgrade(w, r, protocol) {
+		return
+	}
+
+	if srv.usage != nil {
+		atomic.AddInt64(&srv.usage.inflight, 1)
+		defer atomic.AddInt64(&srv.usage.inflight, -1)
+	}
+
+	rec := newRecorder(w, srv.clock)
+	rec.decorate = srv.decorate
+	rec.faults = srv.slowHeadersOf
+	rec.informational = srv.informationalOf
+	rec.delay = func(control http.Header) { sleepContext(r, srv.delayOf(control)) }
+	rec.stream = func(control http.Header) *streaming { return streamOf(r, control) }
+	rec.throttle = func(control http.Header) *throttle { return srv.throttleOf(r, control) }
+
+	correlation := srv.correlate(r, rec)
+
+	stats := connOf(r.Context())
+	if stats != nil {
+		mark := stats.order.mark()
+		rec.hold = func(control http.Header) { srv.hold(r, stats, mark, control) }
+	}
+
+	record := &entry{
+		time:        time.Now(),
+		method:      r.Method,
+		path:        r.URL.Path,
+		query:       queryOf(r.URL),
+		header:      r.Header, // the request is cloned before the control headers are set
+		body:        readBody(r),
+		correlation: correlation,
+		client:      helloOf(r.Context()),
+	}
+
+	if stats != nil {
+		atomic.AddInt64(&stats.requests, 1)
+		record.conn = stats.id
+	}
+
+	srv.emit(hookRequest, func() map[string]interface{} { return requestInfo(record) })
+
+	if out, ok := srv.regional(rec, r); ok {
+		if matched := srv.respondRPC(rec, out); matched != nil {
+			record.route = matched.pattern
+		}
+	}
+
+	if id, err := strconv.Atoi(rec.control.Get(headerRoute)); err == nil {
+		if matched := srv.router.get(id); matched != nil {
+			record.route = matched.pattern
+		}
+	}
+
+	if rec.slow != nil {
+		srv.flushSlow(w, r, rec)
+	}
+
+	record.duration = time.Since(record.time)
+	record.status = rec.status
+	record.stub = rec.control.Get(headerStub)
+	record.fault = rec.fault
+
+	if len(record.fault) == 0 && len(rec.control.Get(headerFaultStatus)) != 0 {
+		record.fault = faultStatus
+	}
+
+	record.resHeader = rec.header
+	record.resBody = rec.body.Bytes()
+
+	if stats != nil {
+		stats.order.done(r.URL.Path, w)
+	}
+
+	srv.record(record)
+	srv.observe(record)
+
+	if srv.retries != nil {
+		srv.detectRetry(r, record)
+	}
+
+	srv.mod.stats.request(srv.target, record)
+	srv.publish(record)
+	srv.emit(hookResponse, func() map[string]interface{} { return responseInfo(record) })
+
+	if len(rec.fault) != 0 {
+		srv.injectFault(w, rec)
+	}
+}
+
+// respond routes the request. Responses are buffered if the server modifies them after they
+// were produced by the application.
+func (srv *server) respond(w http.ResponseWriter, r *http.Request) *route { // nolint:varnamelen
+	if !srv.buffered() {
+		return srv.route(w, r)
+	}
+
+	res := acquireResponse()
+	defer res.release()
+
+	var matched *route
+
+	if srv.cache != nil {
+		matched = srv.cached(res, r)
+	} else {
+		matched = srv.produce(res, r)
+	}
+
+	res.flush(w)
+
+	return matched
+}
+
+// buffered reports whether the responses are modified by the server after they were produced.
+func (srv *server) buffered() bool {
+	return srv.cache != nil || srv.opts.fuzz != nil || srv.clock.skewed() || srv.links != nil ||
+		!srv.trans.empty()
+}
+
+// produce routes the request and applies the response modifications of the server.
+func (srv *server) produce(res *response, r *http.Request) *route { // nolint:varnamelen
+	matched := srv.route(res, r)
+
+	if srv.opts.fuzz != nil && (matched != nil || res.dispatched()) {
+		srv.fuzz(res, r)
+	}
+
+	if srv.clock.skewed() && len(res.header.Get("Content-Encoding")) == 0 {
+		body := skewBody(res.body.Bytes(), srv.clock.skewDelta())
+
+		res.body.Reset()
+		res.body.Write(body)
+	}
+
+	if srv.links != nil {
+		srv.links.rewriteResponse(res)
+	}
+
+	srv.trans.apply(res, r)
+
+	return matched
+}
+
+// route forwards the request to the application or serves it from a stub. The static or stub route
+// is returned if the request was not dispatched, otherwise the route selected by the dispatcher is reported in control header.
+func (srv *server) route(w http.ResponseWriter, r *http.Request) *route { // nolint:varnamelen
+	if srv.health != nil {
+		if matched := srv.health.serve(w, r, !srv.warmup.isReady()); matched != nil {
+			return matched
+		}
+	}
+
+	if !srv.warmup.isReady() {
+		srv.warmup.reject(w)
+
+		return nil
+	}
+
+	if srv.schedule != nil {
+		if fault := srv.schedule.active(r, srv.mod.testElapsed()); fault != nil {
+			fault.inject(w, r)
+			srv.mod.stats.fault(srv.target)
+
+			if res, ok := w.(*response); ok {
+				res.faulted = true
+			}
+
+			return fault.route
+		}
+	}
+
+	sel := selector{version: srv.deploy.pick()}
+	if len(sel.version) != 0 {
+		w.Header().Set(headerServedVersion, sel.version)
+	}
+
+	if srv.api != nil {
+		sel.api = srv.api.negotiate(r.Header.Get("Accept"))
+	}
+
+	if ext := srv.extension(r); ext != nil {
+		ext.responder.Respond(w, r)
+
+		return ext.route
+	}
+
+	routes := srv.conditional(srv.router.candidates(r.Method, r.URL.Path, sel), r)
+	if len(routes) == 0 {
+		if matched := srv.served(w, r); matched != nil {
+			return matched
+		}
+
+		if matched := srv.stub(r); matched != nil {
+			matched.record(r)
+
+			if srv.html != nil {
+				srv.html.serve(matched, w, r)
+			} else {
+				matched.ServeHTTP(w, r)
+			}
+
+			return matched.route
+		}
+
+		if srv.recording != nil {
+			return srv.recording.serve(w, r)
+		}
+
+		if len(srv.router.fallback(r.URL.Path, sel.version)) != 0 {
+			srv.forward(w, r, nil, sel.version)
+
+			return nil
+		}
+
+		srv.unmatched(w, r)
+
+		return nil
+	}
+
+	if limit := routes[0].limit; limit != nil {
+		if !limit.acquire(r.Context()) {
+			reject(w, limit)
+
+			return routes[0]
+		}
+
+		defer limit.release()
+	}
+
+	if routes[0].static {
+		srv.proxy.ServeHTTP(w, r)
+
+		return routes[0]
+	}
+
+	srv.forward(w, r, routes, sel.version)
+
+	return nil
+}
+
+// forward passes the request to the dispatcher of the application with the candidate routes. Without
+// routes only the middlewares run, the request is reported as unmatched if none of them responds.
+func (srv *server) forward(w http.ResponseWriter, r *http.Request, routes []*route, version string) {
+	ids := make([]string, 0, len(routes))
+	for _, route := range routes {
+		ids = append(ids, strconv.Itoa(route.id))
+	}
+
+	body := srv.bodies.put(readBody(r))
+	defer srv.bodies.drop(body)
+
+	out := r.Clone(r.Context())
+
+	out.Header.Set(headerMethod, r.Method)
+	out.Header.Set(headerPath, r.URL.Path)
+	out.Header.Set(headerRoutes, strings.Join(ids, ","))
+	out.Header.Set(headerBody, body)
+
+	if len(version) != 0 {
+		out.Header.Set(headerServedVersion, version)
+	}
+
+	srv.keyClient(r, out)
+
+	out.Method = http.MethodPost
+	out.URL.Path = dispatchPath
+	out.URL.RawPath = ""
+
+	srv.proxy.ServeHTTP(w, out)
+}
+
+func (srv *server) unmatched(w http.ResponseWriter, r *http.Request) { // nolint:varnamelen
+	srv.report(r.Method, r.URL.Path)
+
+	http.NotFound(w, r)
+}
+
+// report counts the unmatched request, in strict mode it is logged and the test run is aborted if
+// requested.
+func (srv *server) report(method, path string) {
+	srv.mod.push(srv.mod.metrics.unmatched, 1, map[string]string{
+		"mock":   srv.target,
+		"method": method,
+	})
+
+	if srv.opts.strict {
+		srv.mod.logger.WithField("target", srv.target).
+			WithField("method", method).
+			WithField("path", path).
+			Error("unmatched mock request")
+
+		if srv.opts.abort {
+			srv.abort(fmt.Sprintf("unmatched mock request: %s %s%s", method, srv.target, path))
+		}
+	}
+}
+
+// abort interrupts the test run from the VU's event loop.
+func (srv *server) abort(reason string) {
+	newRunner(srv.mod.vu)(func() error {
+		return &errext.InterruptError{Reason: reason}
+	})
+}
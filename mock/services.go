@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/grafana/sobek"
+	"gopkg.in/yaml.v3"
+)
+
+// readServiceFile reads the service name to URL mapping from a JSON or YAML file.
+func readServiceFile(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename) // nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]string)
+
+	if isYAML(filename) {
+		err = yaml.Unmarshal(data, &services)
+	} else {
+		err = json.Unmarshal(data, &services)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+// register records the name of the mock definition.
+func (mod *Module) register(args *mockArgs) {
+	if len(args.options.name) != 0 {
+		mod.names[args.options.name] = args.target
+	}
+}
+
+// service returns the URL of the named service. Named mock servers take precedence over the
+// services loaded from file. The mock target is returned if the mock server is not running (e.g. skipped).
+func (mod *Module) service(name string) string {
+	if target, found := mod.names[name]; found {
+		mod.startDeferred(func(t string) bool { return t == target })
+
+		if addr, running := mod.lookup[target]; running {
+			return addr
+		}
+
+		return target
+	}
+
+	if loc, found := mod.services[name]; found {
+		return loc
+	}
+
+	mod.throwf("unknown service %s", errInvalidArg, name)
+
+	return ""
+}
+
+// loadServices loads service URLs from the given file (object of name and URL pairs).
+func (mod *Module) loadServices(filename string) sobek.Value {
+	services, err := readServiceFile(filename)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	for name, loc := range services {
+		mod.services[name] = loc
+	}
+
+	return mod.runtime().ToValue(services)
+}
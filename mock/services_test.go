@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadServiceFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	filename := filepath.Join(dir, "services.yaml")
+
+	require.NoError(t, os.WriteFile(filename, []byte("payments: https://payments.example.com\n"), 0o600))
+
+	services, err := readServiceFile(filename)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"payments": "https://payments.example.com"}, services)
+
+	filename = filepath.Join(dir, "services.json")
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"orders":"https://orders.example.com"}`), 0o600))
+
+	services, err = readServiceFile(filename)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"orders": "https://orders.example.com"}, services)
+
+	_, err = readServiceFile(filepath.Join(dir, "missing.json"))
+
+	assert.Error(t, err)
+}
+
+func TestService(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://payments.example.com", app => {}, { sync: true, name: "payments" })
+mock("https://orders.example.com", app => {}, { sync: true, name: "orders", skip: true })
+`)
+
+	require.NoError(t, err)
+
+	helper.module.services["inventory"] = "https://inventory.example.com"
+
+	assert.Equal(t, helper.module.lookup["https://payments.example.com"], helper.module.service("payments"))
+	assert.Equal(t, "https://orders.example.com", helper.module.service("orders"))
+	assert.Equal(t, "https://inventory.example.com", helper.module.service("inventory"))
+	assert.Panics(t, func() { helper.module.service("unknown") })
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+var (
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+	jwtPattern       = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`)
+	jwtTimeClaims    = []string{"iat", "exp", "nbf", "auth_time"}
+)
+
+// skewBody shifts RFC 3339 timestamps and JWT time claims found in the body by the given duration.
+// Signatures of the modified tokens are kept as is, so they will not verify.
+func skewBody(body []byte, delta time.Duration) []byte {
+	body = timestampPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		t, err := time.Parse(time.RFC3339Nano, string(match))
+		if err != nil {
+			return match
+		}
+
+		layout := time.RFC3339
+		if bytes.IndexByte(match, '.') >= 0 {
+			layout = time.RFC3339Nano
+		}
+
+		return []byte(t.Add(delta).Format(layout))
+	})
+
+	return jwtPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		parts := strings.Split(string(match), ".")
+
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return match
+		}
+
+		var claims map[string]interface{}
+
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&claims); err != nil {
+			return match
+		}
+
+		for _, name := range jwtTimeClaims {
+			num, ok := claims[name].(json.Number)
+			if !ok {
+				continue
+			}
+
+			if secs, err := num.Int64(); err == nil {
+				claims[name] = secs + int64(delta/time.Second)
+			}
+		}
+
+		data, err := json.Marshal(claims)
+		if err != nil {
+			return match
+		}
+
+		parts[1] = base64.RawURLEncoding.EncodeToString(data)
+
+		return []byte(strings.Join(parts, "."))
+	})
+}
+
+// getSkew parses the skew option. It is either an offset in milliseconds or an object
+// with offset (milliseconds) and drift properties.
+func getSkew(value sobek.Value) (time.Duration, float64) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return 0, 0
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		return time.Duration(value.ToInteger()) * time.Millisecond, 0
+	}
+
+	var (
+		offset time.Duration
+		drift  float64
+	)
+
+	if v := obj.Get("offset"); v != nil && !sobek.IsUndefined(v) {
+		offset = time.Duration(v.ToInteger()) * time.Millisecond
+	}
+
+	if v := obj.Get("drift"); v != nil && !sobek.IsUndefined(v) {
+		drift = v.ToFloat()
+	}
+
+	return offset, drift
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkewBody(t *testing.T) {
+	t.Parallel()
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"joe","iat":1700000000,"exp":1700003600}`))
+	token := "eyJhbGciOiJIUzI1NiJ9." + payload + ".c2ln"
+
+	body := `{"created":"2024-01-01T10:00:00Z","updated":"2024-01-01T10:00:00.5+01:00","token":"` + token + `"}`
+
+	out := string(skewBody([]byte(body), -time.Hour))
+
+	assert.Contains(t, out, `"created":"2024-01-01T09:00:00Z"`)
+	assert.Contains(t, out, `"updated":"2024-01-01T09:00:00.5+01:00"`)
+
+	start := strings.Index(out, `"token":"`) + len(`"token":"`)
+	parts := strings.Split(out[start:strings.LastIndex(out, `"`)], ".")
+
+	require.Len(t, parts, 3)
+	assert.Equal(t, "c2ln", parts[2])
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sub":"joe","iat":1699996400,"exp":1700000000}`, string(claims))
+}
+
+func TestClockSkew(t *testing.T) {
+	t.Parallel()
+
+	wall := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	clk := newClock(func() time.Time { return wall })
+
+	assert.False(t, clk.skewed())
+
+	clk.skew(time.Minute, 0.5)
+
+	assert.True(t, clk.skewed())
+	assert.Equal(t, wall.Add(time.Minute), clk.emitted())
+	assert.Equal(t, wall, clk.now())
+
+	wall = wall.Add(time.Hour)
+
+	assert.Equal(t, wall.Add(time.Minute+30*time.Minute), clk.emitted())
+	assert.Equal(t, wall, clk.now())
+	assert.Equal(t, 31*time.Minute, clk.skewDelta())
+
+	clk.reset()
+
+	assert.False(t, clk.skewed())
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const headerSlowHeaders = "X-Mock-Slow-Headers"
+
+// slowHeaders is a Slowloris-style response fault: the response header is sent in small chunks
+// with a delay before each of them, the body is sent right after the header.
+type slowHeaders struct {
+	interval time.Duration
+	chunk    int
+}
+
+// getSlowHeaders parses the slowHeaders route option. It is either the delay before each byte of the
+// header in milliseconds, or an object with interval (milliseconds) and chunk (bytes) properties.
+func getSlowHeaders(value sobek.Value) *slowHeaders {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	slow := &slowHeaders{chunk: 1}
+
+	if obj, isObj := value.(*sobek.Object); isObj {
+		slow.interval = millis(obj.Get("interval").Export())
+
+		if v := obj.Get("chunk"); v != nil && v.ToInteger() > 0 {
+			slow.chunk = int(v.ToInteger())
+		}
+	} else {
+		slow.interval = millis(value.Export())
+	}
+
+	if slow.interval <= 0 {
+		return nil
+	}
+
+	return slow
+}
+
+// slowHeadersOf returns the slow header fault of the response: the option of the route selected by the
+// dispatcher, or the X-Mock-Slow-Headers control header (delay before each byte in milliseconds).
+func (srv *server) slowHeadersOf(control http.Header) *slowHeaders {
+	if value := control.Get(headerSlowHeaders); len(value) != 0 {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms > 0 {
+			return &slowHeaders{interval: millis(ms), chunk: 1}
+		}
+	}
+
+	id, err := strconv.Atoi(control.Get(headerRoute))
+	if err != nil {
+		return nil
+	}
+
+	if r := srv.router.get(id); r != nil {
+		return r.slowHeaders
+	}
+
+	return nil
+}
+
+// writeSlow writes the HTTP/1.1 response with slowly sent header. The connection is closed after the response.
+func writeSlow(w io.Writer, status int, header http.Header, body []byte, slow *slowHeaders, sleep func(time.Duration)) error {
+	var head bytes.Buffer
+
+	fmt.Fprintf(&head, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+
+	header = header.Clone()
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("Connection", "close")
+	header.Write(&head) // nolint:errcheck,gosec
+
+	head.WriteString("\r\n")
+
+	data := head.Bytes()
+
+	for len(data) != 0 {
+		size := slow.chunk
+		if size > len(data) {
+			size = len(data)
+		}
+
+		sleep(slow.interval)
+
+		if _, err := w.Write(data[:size]); err != nil {
+			return err
+		}
+
+		if flusher, ok := w.(interface{ Flush() error }); ok {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+
+		data = data[size:]
+	}
+
+	_, err := w.Write(body)
+
+	return err
+}
+
+// flushSlow sends the response held back by the recorder because of a slow header fault. The connection
+// is hijacked, if it is not possible (e.g. HTTP/2) the response is sent normally after the total delay.
+func (srv *server) flushSlow(w http.ResponseWriter, r *http.Request, rec *recorder) { // nolint:varnamelen
+	sleep := func(d time.Duration) { sleepContext(r, d) }
+
+	conn, buff, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		var head bytes.Buffer
+
+		rec.header.Write(&head) // nolint:errcheck,gosec
+
+		sleep(rec.slow.interval * time.Duration((head.Len()+rec.slow.chunk-1)/rec.slow.chunk))
+
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes()) // nolint:errcheck,gosec
+
+		return
+	}
+
+	defer conn.Close() // nolint:errcheck
+
+	if err := writeSlow(buff.Writer, rec.status, rec.header, rec.body.Bytes(), rec.slow, sleep); err == nil {
+		buff.Flush() // nolint:errcheck,gosec
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSlow(t *testing.T) {
+	t.Parallel()
+
+	var (
+		buff  bytes.Buffer
+		total time.Duration
+		count int
+	)
+
+	sleep := func(d time.Duration) {
+		total += d
+		count++
+	}
+
+	header := http.Header{"Content-Type": []string{"text/plain"}}
+	slow := &slowHeaders{interval: 10 * time.Millisecond, chunk: 8}
+
+	require.NoError(t, writeSlow(&buff, http.StatusOK, header, []byte("hello"), slow, sleep))
+
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buff.Bytes())), nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/plain", res.Header.Get("Content-Type"))
+	assert.True(t, res.Close)
+
+	body, err := io.ReadAll(res.Body)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	headLen := buff.Len() - len("hello")
+
+	assert.Equal(t, (headLen+7)/8, count)
+	assert.Equal(t, time.Duration(count)*10*time.Millisecond, total)
+	assert.Empty(t, header.Get("Content-Length"))
+}
+
+func TestSlowHeaders(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.get("/slow", (req, res) => res.send("slow"), { slowHeaders: { interval: 5, chunk: 4 } })
+	app.get("/dynamic", (req, res) => {
+		res.set("X-Mock-Slow-Headers", "1")
+		res.send("dynamic")
+	})
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	for path, expected := range map[string]string{"/slow": "slow", "/dynamic": "dynamic"} {
+		start := time.Now()
+
+		res, err := http.Get(helper.module.lookup["https://example.com"] + path) // nolint:noctx
+
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(res.Body)
+
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, expected, string(body))
+		assert.Greater(t, time.Since(start), 40*time.Millisecond)
+		assert.Empty(t, res.Header.Get(headerSlowHeaders))
+	}
+}
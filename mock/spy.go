@@ -0,0 +1,379 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// spy is a stub defined by the script: it serves the configured response and records the calls,
+// so tests can verify how many times and with what payloads the system under test called it.
+type spy struct {
+	route *route
+
+	mu       sync.Mutex
+	tenancy  string // header identifying the tenant, see tenancy option
+	tenant   string // the spy serves only the requests of the tenant if not empty
+	response *stubResponse
+	calls    []*spyCall
+}
+
+type spyCall struct {
+	time   time.Time
+	method string
+	path   string
+	params map[string]string
+	query  url.Values
+	header http.Header
+	body   []byte
+}
+
+func newSpy(method, path string) *spy {
+	return &spy{
+		route:    &route{method: method, pattern: path, segments: compilePath(path)},
+		response: &stubResponse{Status: http.StatusOK},
+	}
+}
+
+// match returns the stub serving the request, nil if the spy doesn't match it. The call is not
+// recorded, see record.
+func (sp *spy) match(r *http.Request) *stub {
+	if !sp.route.accepts(r.Method) {
+		return nil
+	}
+
+	if _, ok := sp.route.match(r.URL.Path); !ok {
+		return nil
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if len(sp.tenant) != 0 && r.Header.Get(sp.tenancy) != sp.tenant {
+		return nil
+	}
+
+	return &stub{
+		Request:  &stubRequest{Method: sp.route.method, Path: sp.route.pattern},
+		Response: sp.response,
+		route:    sp.route,
+		spy:      sp,
+	}
+}
+
+// record records the call served by the spy.
+func (sp *spy) record(r *http.Request) {
+	params, _ := sp.route.match(r.URL.Path)
+
+	call := &spyCall{
+		time:   time.Now(),
+		method: r.Method,
+		path:   r.URL.Path,
+		params: params,
+		query:  r.URL.Query(),
+		header: r.Header.Clone(),
+		body:   readBody(r),
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if len(sp.calls) >= journalLimit {
+		sp.calls = sp.calls[1:]
+	}
+
+	sp.calls = append(sp.calls, call)
+}
+
+// returns sets the response of the spy: status, headers and body. Non-string bodies are encoded as JSON.
+func (sp *spy) returns(spec map[string]interface{}) error {
+	res, err := getStubResponse(spec)
+	if err != nil {
+		return err
+	}
+
+	sp.mu.Lock()
+	sp.response = res
+	sp.mu.Unlock()
+
+	return nil
+}
+
+// getStubResponse parses the response specification: status, headers and body. Non-string bodies are
+// encoded as JSON.
+func getStubResponse(spec map[string]interface{}) (*stubResponse, error) {
+	res := &stubResponse{Status: intKeyword(spec, "status", http.StatusOK), Headers: make(map[string]string)}
+
+	if headers, ok := spec["headers"].(map[string]interface{}); ok {
+		for name, value := range headers {
+			res.Headers[http.CanonicalHeaderKey(name)] = fmt.Sprint(value)
+		}
+	}
+
+	switch body := spec["body"].(type) {
+	case nil:
+	case string:
+		res.Body = body
+	case sobek.ArrayBuffer:
+		res.Body = string(body.Bytes())
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Body = string(data)
+
+		if _, found := res.Headers["Content-Type"]; !found {
+			res.Headers["Content-Type"] = "application/json"
+		}
+	}
+
+	return res, nil
+}
+
+func (sp *spy) export() []interface{} {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	out := make([]interface{}, 0, len(sp.calls))
+
+	for _, call := range sp.calls {
+		out = append(out, call.object())
+	}
+
+	return out
+}
+
+// withTenant restricts the spy to the requests of the tenant, so jobs sharing the mock can stub the
+// same route independently.
+func (sp *spy) withTenant(tenancy, name string) error {
+	if len(tenancy) == 0 {
+		return fmt.Errorf("%w: tenancy is not enabled", errInvalidArg)
+	}
+
+	sp.mu.Lock()
+	sp.tenancy, sp.tenant = tenancy, name
+	sp.mu.Unlock()
+
+	return nil
+}
+
+func (sp *spy) reset() {
+	sp.mu.Lock()
+	sp.calls = nil
+	sp.mu.Unlock()
+}
+
+// calledWith reports whether any of the calls matches the expected request parts.
+func (sp *spy) calledWith(expected map[string]interface{}) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	for _, call := range sp.calls {
+		if call.matches(expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (call *spyCall) object() map[string]interface{} {
+	query := make(map[string]interface{}, len(call.query))
+
+	for name := range call.query {
+		query[name] = call.query.Get(name)
+	}
+
+	params := make(map[string]interface{}, len(call.params))
+
+	for name, value := range call.params {
+		params[name] = value
+	}
+
+	return map[string]interface{}{
+		"time":    call.time.UnixMilli(),
+		"method":  call.method,
+		"path":    call.path,
+		"params":  params,
+		"query":   query,
+		"headers": flatHeader(call.header),
+		"body":    string(call.body),
+	}
+}
+
+// matches reports whether the call matches the expected params, query, headers and body. String body
+// is matched as substring, other bodies are compared with the JSON body (expected properties only).
+func (call *spyCall) matches(expected map[string]interface{}) bool {
+	values := func(name string, actual func(string) (string, bool)) bool {
+		props, _ := expected[name].(map[string]interface{})
+
+		for key, value := range props {
+			if got, found := actual(key); !found || got != fmt.Sprint(value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if !values("params", func(key string) (string, bool) { v, ok := call.params[key]; return v, ok }) ||
+		!values("query", func(key string) (string, bool) { return call.query.Get(key), call.query.Has(key) }) ||
+		!values("headers", func(key string) (string, bool) {
+			return call.header.Get(key), len(call.header.Values(key)) != 0
+		}) {
+		return false
+	}
+
+	switch body := expected["body"].(type) {
+	case nil:
+		return true
+	case string:
+		return strings.Contains(string(call.body), body)
+	default:
+		var actual interface{}
+
+		if err := json.Unmarshal(call.body, &actual); err != nil {
+			return false
+		}
+
+		return jsonSubset(normalizeJSON(body), actual)
+	}
+}
+
+// normalizeJSON converts the exported value to its JSON decoded form (e.g. numbers to float64).
+func normalizeJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var out interface{}
+
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+
+	return out
+}
+
+// jsonSubset reports whether the expected value is part of the actual one: objects match if the expected
+// properties match, other values must be equal.
+func jsonSubset(expected, actual interface{}) bool {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		obj, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for key, value := range exp {
+			if !jsonSubset(value, obj[key]) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		list, ok := actual.([]interface{})
+		if !ok || len(list) != len(exp) {
+			return false
+		}
+
+		for idx := range exp {
+			if !jsonSubset(exp[idx], list[idx]) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return expected == actual
+	}
+}
+
+// spied returns the stub of the first spy matching the request, the call is recorded when the stub
+// serves the request.
+func (srv *server) spied(r *http.Request) *stub {
+	srv.spyMu.RLock()
+	defer srv.spyMu.RUnlock()
+
+	for _, sp := range srv.spies {
+		if s := sp.match(r); s != nil {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// wrapSpies adds the stub method to the application object.
+func (srv *server) wrapSpies() {
+	srv.mustSet("stub", func(method, path string) *sobek.Object {
+		if err := checkPath(path); err != nil {
+			srv.mod.throwf("invalid parameter constraint of stub: %s", errInvalidArg, err)
+		}
+
+		sp := newSpy(strings.ToUpper(method), path)
+
+		srv.spyMu.Lock()
+		srv.spies = append(srv.spies, sp)
+		srv.spyMu.Unlock()
+
+		return srv.spyObject(sp)
+	})
+}
+
+func (srv *server) spyObject(sp *spy) *sobek.Object {
+	rt := srv.mod.runtime()
+	obj := rt.NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	mustSet("returns", func(spec map[string]interface{}) *sobek.Object {
+		if err := sp.returns(spec); err != nil {
+			srv.mod.throw(err)
+		}
+
+		return obj
+	})
+
+	mustSet("withTenant", func(name string) *sobek.Object {
+		if err := sp.withTenant(srv.opts.tenancy, name); err != nil {
+			srv.mod.throw(err)
+		}
+
+		return obj
+	})
+
+	mustSet("calledWith", sp.calledWith)
+
+	mustSet("reset", func() *sobek.Object {
+		sp.reset()
+
+		return obj
+	})
+
+	getter := rt.ToValue(func(sobek.FunctionCall) sobek.Value { return rt.ToValue(sp.export()) })
+
+	if err := obj.DefineAccessorProperty("calls", getter, nil, sobek.FLAG_FALSE, sobek.FLAG_TRUE); err != nil {
+		srv.mod.throw(err)
+	}
+
+	return obj
+}
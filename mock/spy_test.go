@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpy(t *testing.T) {
+	t.Parallel()
+
+	sp := newSpy(http.MethodPost, "/orders/:id")
+
+	require.NoError(t, sp.returns(map[string]interface{}{
+		"status":  int64(201),
+		"headers": map[string]interface{}{"x-order": 42},
+		"body":    map[string]interface{}{"ok": true},
+	}))
+
+	srv := &server{spies: []*spy{sp}}
+
+	assert.Nil(t, srv.stub(httptest.NewRequest(http.MethodGet, "/orders/1", nil)))
+
+	r := httptest.NewRequest(http.MethodPost, "/orders/1?dry=true", strings.NewReader(`{"item":"book","qty":2}`))
+	r.Header.Set("Authorization", "Bearer token")
+
+	matched := srv.stub(r)
+
+	require.NotNil(t, matched)
+	assert.Empty(t, sp.export(), "matching doesn't record the call")
+
+	matched.record(r)
+
+	w := httptest.NewRecorder()
+
+	matched.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "42", w.Header().Get("X-Order"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+
+	calls := sp.export()
+
+	require.Len(t, calls, 1)
+
+	call, _ := calls[0].(map[string]interface{})
+
+	assert.Equal(t, map[string]interface{}{"id": "1"}, call["params"])
+	assert.Equal(t, `{"item":"book","qty":2}`, call["body"])
+
+	assert.True(t, sp.calledWith(map[string]interface{}{
+		"params":  map[string]interface{}{"id": "1"},
+		"query":   map[string]interface{}{"dry": true},
+		"headers": map[string]interface{}{"authorization": "Bearer token"},
+		"body":    map[string]interface{}{"qty": int64(2)},
+	}))
+	assert.True(t, sp.calledWith(map[string]interface{}{"body": "book"}))
+	assert.False(t, sp.calledWith(map[string]interface{}{"params": map[string]interface{}{"id": "2"}}))
+	assert.False(t, sp.calledWith(map[string]interface{}{"body": map[string]interface{}{"qty": int64(3)}}))
+
+	sp.reset()
+
+	assert.Empty(t, sp.export())
+	assert.False(t, sp.calledWith(map[string]interface{}{}))
+}
+
+func TestSpyTenant(t *testing.T) {
+	t.Parallel()
+
+	blue, green := newSpy(http.MethodGet, "/items"), newSpy(http.MethodGet, "/items")
+
+	assert.ErrorIs(t, blue.withTenant("", "blue"), errInvalidArg)
+	require.NoError(t, blue.withTenant("X-Tenant", "blue"))
+	require.NoError(t, green.withTenant("X-Tenant", "green"))
+
+	srv := &server{spies: []*spy{blue, green}}
+
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	r.Header.Set("X-Tenant", "green")
+
+	matched := srv.stub(r)
+
+	require.NotNil(t, matched)
+
+	matched.record(r)
+
+	assert.Empty(t, blue.export())
+	assert.Len(t, green.export(), 1)
+
+	assert.Nil(t, srv.stub(httptest.NewRequest(http.MethodGet, "/items", nil)))
+}
+
+func TestJSONSubset(t *testing.T) {
+	t.Parallel()
+
+	actual := map[string]interface{}{"a": 1.0, "b": []interface{}{"x", map[string]interface{}{"c": true, "d": nil}}}
+
+	assert.True(t, jsonSubset(map[string]interface{}{}, actual))
+	assert.True(t, jsonSubset(map[string]interface{}{"b": []interface{}{"x", map[string]interface{}{"c": true}}}, actual))
+	assert.False(t, jsonSubset(map[string]interface{}{"b": []interface{}{"x"}}, actual))
+	assert.False(t, jsonSubset(map[string]interface{}{"a": "1"}, actual))
+}
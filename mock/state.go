@@ -0,0 +1,317 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// namespace is an isolated mock state: key-value store, counters and CRUD resources.
+type namespace struct {
+	mu        sync.Mutex
+	values    map[string]interface{}
+	counters  map[string]int64
+	resources map[string]*collection
+}
+
+// collection is a CRUD resource, items are identified by their id property.
+type collection struct {
+	seq   int64
+	items map[string]map[string]interface{}
+}
+
+func newNamespace() *namespace {
+	return &namespace{
+		values:    make(map[string]interface{}),
+		counters:  make(map[string]int64),
+		resources: make(map[string]*collection),
+	}
+}
+
+func (ns *namespace) get(key string) (interface{}, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	value, found := ns.values[key]
+
+	return value, found
+}
+
+func (ns *namespace) set(key string, value interface{}) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.values[key] = value
+}
+
+func (ns *namespace) remove(key string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	delete(ns.values, key)
+}
+
+func (ns *namespace) keys() []string {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return sortedKeys(ns.values)
+}
+
+func (ns *namespace) incr(name string, delta int64) int64 {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.counters[name] += delta
+
+	return ns.counters[name]
+}
+
+func (ns *namespace) counter(name string) int64 {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.counters[name]
+}
+
+func (ns *namespace) reset() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	ns.values = make(map[string]interface{})
+	ns.counters = make(map[string]int64)
+	ns.resources = make(map[string]*collection)
+}
+
+// collection returns the named CRUD resource, it must be called with ns.mu held.
+func (ns *namespace) collection(name string) *collection {
+	coll, found := ns.resources[name]
+	if !found {
+		coll = &collection{items: make(map[string]map[string]interface{})}
+		ns.resources[name] = coll
+	}
+
+	return coll
+}
+
+func itemID(item map[string]interface{}) (string, bool) {
+	id, found := item["id"]
+	if !found || id == nil {
+		return "", false
+	}
+
+	return fmt.Sprint(id), true
+}
+
+func (ns *namespace) list(name string) []interface{} {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	coll := ns.collection(name)
+	ids := make([]string, 0, len(coll.items))
+
+	for id := range coll.items {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, errA := strconv.ParseInt(ids[i], 10, 64)
+		b, errB := strconv.ParseInt(ids[j], 10, 64)
+
+		if errA == nil && errB == nil {
+			return a < b
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	items := make([]interface{}, 0, len(ids))
+
+	for _, id := range ids {
+		items = append(items, coll.items[id])
+	}
+
+	return items
+}
+
+func (ns *namespace) find(name, id string) (map[string]interface{}, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	item, found := ns.collection(name).items[id]
+
+	return item, found
+}
+
+// create stores the item, a sequential id is assigned if the item has no id.
+func (ns *namespace) create(name string, item map[string]interface{}) map[string]interface{} {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	coll := ns.collection(name)
+
+	id, found := itemID(item)
+	if !found {
+		coll.seq++
+
+		for coll.items[strconv.FormatInt(coll.seq, 10)] != nil {
+			coll.seq++
+		}
+
+		item["id"] = coll.seq
+		id = strconv.FormatInt(coll.seq, 10)
+	}
+
+	coll.items[id] = item
+
+	return item
+}
+
+// update merges the given properties into an existing item.
+func (ns *namespace) update(name, id string, props map[string]interface{}) (map[string]interface{}, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	item, found := ns.collection(name).items[id]
+	if !found {
+		return nil, false
+	}
+
+	for key, value := range props {
+		if key != "id" {
+			item[key] = value
+		}
+	}
+
+	return item, true
+}
+
+func (ns *namespace) delete(name, id string) bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	coll := ns.collection(name)
+
+	_, found := coll.items[id]
+
+	delete(coll.items, id)
+
+	return found
+}
+
+func (ns *namespace) clear(name string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	delete(ns.resources, name)
+}
+
+// stateStore keeps the state namespaces of a mock server, one per tenant.
+type stateStore struct {
+	mu      sync.Mutex
+	tenants map[string]*namespace
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{tenants: make(map[string]*namespace)}
+}
+
+func (store *stateStore) tenant(name string) *namespace {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns, found := store.tenants[name]
+	if !found {
+		ns = newNamespace()
+		store.tenants[name] = ns
+	}
+
+	return ns
+}
+
+func (store *stateStore) names() []string {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return sortedKeys(store.tenants)
+}
+
+// tenant returns the tenant of the request, empty string if tenancy is not enabled.
+func (srv *server) tenant(req *sobek.Object) string {
+	if len(srv.opts.tenancy) == 0 {
+		return ""
+	}
+
+	return srv.header(req, srv.opts.tenancy)
+}
+
+// stateObject returns the JavaScript interface of the given state namespace.
+func (srv *server) stateObject(ns *namespace) *sobek.Object {
+	runtime := srv.mod.runtime()
+	obj := runtime.NewObject()
+
+	set := func(target *sobek.Object, name string, value interface{}) {
+		if err := target.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	exportItem := func(value sobek.Value) map[string]interface{} {
+		item, ok := value.Export().(map[string]interface{})
+		if !ok {
+			srv.mod.throwf("resource item must be an object", errInvalidArg)
+		}
+
+		return item
+	}
+
+	orUndefined := func(value interface{}, found bool) sobek.Value {
+		if !found {
+			return sobek.Undefined()
+		}
+
+		return runtime.ToValue(value)
+	}
+
+	set(obj, "get", func(key string) sobek.Value { return orUndefined(ns.get(key)) })
+	set(obj, "set", func(key string, value sobek.Value) sobek.Value {
+		ns.set(key, value.Export())
+
+		return obj
+	})
+	set(obj, "delete", ns.remove)
+	set(obj, "keys", ns.keys)
+	set(obj, "incr", func(call sobek.FunctionCall) sobek.Value {
+		delta := int64(1)
+		if len(call.Arguments) > 1 {
+			delta = call.Argument(1).ToInteger()
+		}
+
+		return runtime.ToValue(ns.incr(call.Argument(0).String(), delta))
+	})
+	set(obj, "counter", ns.counter)
+	set(obj, "reset", ns.reset)
+	set(obj, "resource", func(name string) *sobek.Object {
+		res := runtime.NewObject()
+
+		set(res, "list", func() []interface{} { return ns.list(name) })
+		set(res, "get", func(id string) sobek.Value { return orUndefined(ns.find(name, id)) })
+		set(res, "create", func(item sobek.Value) interface{} { return ns.create(name, exportItem(item)) })
+		set(res, "update", func(id string, item sobek.Value) sobek.Value {
+			return orUndefined(ns.update(name, id, exportItem(item)))
+		})
+		set(res, "delete", func(id string) bool { return ns.delete(name, id) })
+		set(res, "clear", func() { ns.clear(name) })
+
+		return res
+	})
+
+	return obj
+}
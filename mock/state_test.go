@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore(t *testing.T) {
+	t.Parallel()
+
+	store := newStateStore()
+
+	acme := store.tenant("acme")
+	globex := store.tenant("globex")
+
+	assert.Same(t, acme, store.tenant("acme"))
+	assert.Equal(t, []string{"acme", "globex"}, store.names())
+
+	acme.set("plan", "gold")
+
+	value, found := acme.get("plan")
+
+	assert.True(t, found)
+	assert.Equal(t, "gold", value)
+
+	_, found = globex.get("plan")
+
+	assert.False(t, found)
+
+	assert.Equal(t, int64(1), acme.incr("logins", 1))
+	assert.Equal(t, int64(3), acme.incr("logins", 2))
+	assert.Zero(t, globex.counter("logins"))
+
+	first := acme.create("orders", map[string]interface{}{"item": "book"})
+	second := acme.create("orders", map[string]interface{}{"item": "pen"})
+
+	assert.Equal(t, int64(1), first["id"])
+	assert.Equal(t, int64(2), second["id"])
+	assert.Len(t, acme.list("orders"), 2)
+	assert.Empty(t, globex.list("orders"))
+
+	updated, found := acme.update("orders", "2", map[string]interface{}{"id": 99, "item": "pencil"})
+
+	require.True(t, found)
+	assert.Equal(t, "pencil", updated["item"])
+	assert.Equal(t, int64(2), updated["id"])
+
+	_, found = acme.update("orders", "3", map[string]interface{}{})
+
+	assert.False(t, found)
+
+	assert.True(t, acme.delete("orders", "1"))
+	assert.False(t, acme.delete("orders", "1"))
+
+	item, found := acme.find("orders", "2")
+
+	assert.True(t, found)
+	assert.Equal(t, "pencil", item["item"])
+
+	acme.reset()
+
+	assert.Empty(t, acme.keys())
+	assert.Empty(t, acme.list("orders"))
+	assert.Zero(t, acme.counter("logins"))
+}
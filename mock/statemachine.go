@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// scenario is a named state machine, the routes of its states are served only in the given state and
+// may move the scenario to another state when they are hit.
+type scenario struct {
+	mu      sync.RWMutex
+	name    string
+	initial string // the first defined state unless given
+	current string
+}
+
+func (sc *scenario) state() string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.current
+}
+
+func (sc *scenario) is(state string) bool {
+	return sc.state() == state
+}
+
+// declare registers the state, the first declared state is the initial one unless it was given.
+func (sc *scenario) declare(state string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if len(sc.initial) == 0 {
+		sc.initial, sc.current = state, state
+	}
+}
+
+// start sets the initial state, the scenario is moved to it if it was changed.
+func (sc *scenario) start(initial string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.initial != initial {
+		sc.initial, sc.current = initial, initial
+	}
+}
+
+func (sc *scenario) set(state string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.current = state
+}
+
+func (sc *scenario) reset() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.current = sc.initial
+}
+
+// move transitions the scenario to the next state if it is still in the given one, concurrent hits of
+// the same route transition once.
+func (sc *scenario) move(from, next string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.current != from {
+		return false
+	}
+
+	sc.current = next
+
+	return true
+}
+
+// step binds a route to a state of the scenario, next is the state the scenario moves to when the
+// route is hit (empty if the state does not change).
+type step struct {
+	scenario *scenario
+	state    string
+	next     string
+}
+
+// scenarioCondition matches if the scenario is in the state.
+func scenarioCondition(sc *scenario, state string) condition {
+	return condition{test: func(*http.Request, []byte) bool { return sc.is(state) }}
+}
+
+// transition moves the scenario of the route hit by the request to the next state.
+func (srv *server) transition(target *route) {
+	if target.step != nil && len(target.step.next) != 0 {
+		target.step.scenario.move(target.step.state, target.step.next)
+	}
+}
+
+// scenarioOf returns the scenario with the given name, it is created if it does not exist.
+func (srv *server) scenarioOf(name, initial string) *scenario {
+	srv.sceneMu.Lock()
+	defer srv.sceneMu.Unlock()
+
+	if srv.scenes == nil {
+		srv.scenes = make(map[string]*scenario)
+	}
+
+	sc, found := srv.scenes[name]
+	if !found {
+		sc = &scenario{name: name}
+		srv.scenes[name] = sc
+	}
+
+	if len(initial) != 0 {
+		sc.start(initial)
+	}
+
+	return sc
+}
+
+// wrapScenarios adds the scenario method to the application.
+func (srv *server) wrapScenarios() {
+	srv.mustSet("scenario", func(name string, initial string) *sobek.Object {
+		return srv.scenarioObject(srv.scenarioOf(name, initial))
+	})
+}
+
+func (srv *server) scenarioObject(sc *scenario) *sobek.Object {
+	obj := srv.mod.runtime().NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	mustSet("name", sc.name)
+	mustSet("state", func(state string) *sobek.Object {
+		sc.declare(state)
+
+		return srv.scenarioState(sc, state)
+	})
+	mustSet("current", sc.state)
+	mustSet("set", func(state string) *sobek.Object {
+		sc.set(state)
+
+		return obj
+	})
+	mustSet("reset", func() *sobek.Object {
+		sc.reset()
+
+		return obj
+	})
+
+	return obj
+}
+
+// scenarioState returns the object defining the routes of the scenario's state. It inherits from the
+// application object, its route definition methods register routes served only in the state.
+func (srv *server) scenarioState(sc *scenario, state string) *sobek.Object {
+	obj := srv.mod.runtime().CreateObject(srv.app)
+
+	bind := func(r *route) {
+		r.step = &step{scenario: sc, state: state}
+		r.conditions = append(r.conditions, scenarioCondition(sc, state))
+	}
+
+	for name, method := range routeMethods {
+		name, method := name, method
+
+		err := obj.Set(name, func(call sobek.FunctionCall) sobek.Value {
+			var r *route
+
+			srv.router.with(bind, func() { r = srv.defineRoute(name, method, call.Arguments) })
+
+			return srv.routeObject(r)
+		})
+		if err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	return obj
+}
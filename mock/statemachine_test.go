@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenario(t *testing.T) {
+	t.Parallel()
+
+	srv := &server{router: newRouter()}
+	sc := srv.scenarioOf("checkout", "")
+
+	assert.Same(t, sc, srv.scenarioOf("checkout", ""))
+
+	sc.declare("empty")
+	sc.declare("has-items")
+
+	assert.Equal(t, "empty", sc.state())
+
+	var empty, full *route
+
+	srv.router.with(func(r *route) {
+		r.step = &step{scenario: sc, state: "empty", next: "has-items"}
+		r.conditions = append(r.conditions, scenarioCondition(sc, "empty"))
+	}, func() { empty = srv.router.add(http.MethodGet, "/cart", nil) })
+
+	srv.router.with(func(r *route) {
+		r.step = &step{scenario: sc, state: "has-items"}
+		r.conditions = append(r.conditions, scenarioCondition(sc, "has-items"))
+	}, func() { full = srv.router.add(http.MethodGet, "/cart", nil) })
+
+	cart := func() []*route {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+
+		return srv.conditional(srv.router.candidates(http.MethodGet, "/cart", selector{}), req)
+	}
+
+	assert.Equal(t, []*route{empty}, cart())
+
+	srv.transition(empty)
+
+	assert.Equal(t, "has-items", sc.state())
+	assert.Equal(t, []*route{full}, cart())
+
+	srv.transition(full)
+	srv.transition(empty)
+
+	assert.Equal(t, "has-items", sc.state())
+
+	sc.reset()
+
+	assert.Equal(t, []*route{empty}, cart())
+
+	sc.set("unknown")
+
+	assert.Empty(t, cart())
+
+	srv.scenarioOf("checkout", "has-items")
+
+	assert.Equal(t, "has-items", sc.state())
+}
@@ -0,0 +1,271 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+// Storage policies of the recorded bodies.
+const (
+	bodiesMemory   = "memory"   // bodies kept in memory as is
+	bodiesDisk     = "disk"     // bodies spilled to disk
+	bodiesTruncate = "truncate" // bodies truncated to maxBody bytes
+	bodiesHash     = "hash"     // bodies replaced with their SHA-256 hash
+)
+
+const (
+	defaultMaxBody = 1024
+	segmentSize    = 64 << 20 // size of the body segment files
+)
+
+// journalOptions are the retention limits and the body storage policy of the request journal.
+type journalOptions struct {
+	limit     int
+	retention time.Duration
+	bodies    string
+	maxBody   int
+	dir       string
+}
+
+// getJournalOptions parses the journal option: limit (entries), retention (age of entries), bodies
+// (storage policy), maxBody (bytes kept by the truncate policy) and dir (directory of the disk policy,
+// temporary directory by default).
+func getJournalOptions(value sobek.Value) (*journalOptions, error) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil, nil
+	}
+
+	spec, isObj := value.Export().(map[string]interface{})
+	if !isObj {
+		return nil, fmt.Errorf("%w: journal must be an object", errInvalidArg)
+	}
+
+	opts := &journalOptions{limit: journalLimit, bodies: bodiesMemory, maxBody: defaultMaxBody}
+
+	if n, ok := toFloat(spec["limit"]); ok && n > 0 {
+		opts.limit = int(n)
+	}
+
+	opts.retention = duration(spec["retention"])
+
+	if policy, ok := spec["bodies"].(string); ok {
+		switch policy {
+		case bodiesMemory, bodiesDisk, bodiesTruncate, bodiesHash:
+			opts.bodies = policy
+		default:
+			return nil, fmt.Errorf("%w: unknown journal bodies policy %q", errInvalidArg, policy)
+		}
+	}
+
+	if size := getSize(spec["maxBody"]); size > 0 {
+		opts.maxBody = int(size)
+	}
+
+	if dir, ok := spec["dir"].(string); ok {
+		opts.dir = dir
+	}
+
+	return opts, nil
+}
+
+// bodySegment is a file of spilled bodies, it is removed when none of the retained entries refers to it.
+type bodySegment struct {
+	file *os.File
+	size int64
+	refs int
+}
+
+// spilledBodies refers to the request and response body of an entry in a segment file.
+type spilledBodies struct {
+	segment *bodySegment
+	offset  int64
+	reqLen  int
+	resLen  int
+}
+
+// read returns the part of the segment, nil if it is not readable anymore.
+func (sb *spilledBodies) read(offset int64, size int) []byte {
+	if size == 0 {
+		return nil
+	}
+
+	data := make([]byte, size)
+
+	if _, err := sb.segment.file.ReadAt(data, offset); err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// diskBodies writes the bodies of the entries to segment files in the directory.
+type diskBodies struct {
+	mu      sync.Mutex
+	dir     string
+	temp    bool // the directory was created by the storage
+	current *bodySegment
+	all     map[*bodySegment]bool
+}
+
+func newDiskBodies(dir string) (*diskBodies, error) {
+	disk := &diskBodies{dir: dir, all: make(map[*bodySegment]bool)}
+
+	if len(dir) != 0 {
+		return disk, os.MkdirAll(dir, 0o750)
+	}
+
+	var err error
+
+	disk.dir, err = os.MkdirTemp("", "xk6-mock-journal-")
+	disk.temp = true
+
+	return disk, err
+}
+
+// write appends the bodies to the current segment, a new segment is started if it is full.
+func (disk *diskBodies) write(reqBody, resBody []byte) (*spilledBodies, error) {
+	disk.mu.Lock()
+	defer disk.mu.Unlock()
+
+	if disk.current == nil || disk.current.size >= segmentSize {
+		file, err := os.CreateTemp(disk.dir, "bodies-*")
+		if err != nil {
+			return nil, err
+		}
+
+		if disk.current != nil && disk.current.refs == 0 {
+			disk.remove(disk.current)
+		}
+
+		disk.current = &bodySegment{file: file}
+		disk.all[disk.current] = true
+	}
+
+	seg := disk.current
+	spilled := &spilledBodies{segment: seg, offset: seg.size, reqLen: len(reqBody), resLen: len(resBody)}
+
+	for _, data := range [][]byte{reqBody, resBody} {
+		n, err := seg.file.WriteAt(data, seg.size)
+
+		seg.size += int64(n)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seg.refs++
+
+	return spilled, nil
+}
+
+// release drops the reference of the evicted entry, unreferenced full segments are removed.
+func (disk *diskBodies) release(spilled *spilledBodies) {
+	disk.mu.Lock()
+	defer disk.mu.Unlock()
+
+	seg := spilled.segment
+
+	if seg.refs--; seg.refs == 0 && seg != disk.current {
+		disk.remove(seg)
+	}
+}
+
+func (disk *diskBodies) remove(seg *bodySegment) {
+	delete(disk.all, seg)
+
+	seg.file.Close()           // nolint:errcheck,gosec
+	os.Remove(seg.file.Name()) // nolint:errcheck,gosec
+}
+
+// close removes the segments and the temporary directory.
+func (disk *diskBodies) close() error {
+	disk.mu.Lock()
+	defer disk.mu.Unlock()
+
+	for seg := range disk.all {
+		disk.remove(seg)
+	}
+
+	disk.current = nil
+
+	if disk.temp {
+		return os.RemoveAll(disk.dir)
+	}
+
+	return nil
+}
+
+// store returns the entry to be retained by the journal: the entry itself, or a copy with the bodies
+// stored according to the policy.
+func (j *journal) store(e *entry) *entry {
+	if j.bodies == bodiesMemory || len(j.bodies) == 0 {
+		return e
+	}
+
+	stored := *e
+
+	switch j.bodies {
+	case bodiesTruncate:
+		stored.body, stored.resBody = truncateBody(e.body, j.maxBody), truncateBody(e.resBody, j.maxBody)
+	case bodiesHash:
+		stored.body, stored.resBody = hashBody(e.body), hashBody(e.resBody)
+	case bodiesDisk:
+		spilled, err := j.disk.write(e.body, e.resBody)
+		if err != nil {
+			stored.body, stored.resBody = nil, nil
+
+			break
+		}
+
+		stored.body, stored.resBody, stored.spilled = nil, nil, spilled
+	}
+
+	return &stored
+}
+
+func truncateBody(body []byte, size int) []byte {
+	if len(body) <= size {
+		return body
+	}
+
+	return append([]byte(nil), body[:size]...)
+}
+
+func hashBody(body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	sum := sha256.Sum256(body)
+
+	return []byte("sha256:" + hex.EncodeToString(sum[:]))
+}
+
+// requestBody returns the request body of the entry, spilled bodies are read from disk.
+func (e *entry) requestBody() []byte {
+	if e.spilled != nil {
+		return e.spilled.read(e.spilled.offset, e.spilled.reqLen)
+	}
+
+	return e.body
+}
+
+// responseBody returns the response body of the entry, spilled bodies are read from disk.
+func (e *entry) responseBody() []byte {
+	if e.spilled != nil {
+		return e.spilled.read(e.spilled.offset+int64(e.spilled.reqLen), e.spilled.resLen)
+	}
+
+	return e.resBody
+}
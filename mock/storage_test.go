@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalBodies(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func() *entry {
+		return &entry{time: time.Now(), body: []byte("request body"), resBody: []byte("response body")}
+	}
+
+	jrnl := newJournal(journalLimit)
+
+	require.NoError(t, jrnl.configure(&journalOptions{limit: journalLimit, bodies: bodiesTruncate, maxBody: 7}))
+
+	live := newEntry()
+
+	jrnl.add(live)
+
+	assert.Equal(t, "request", string(jrnl.snapshot()[0].requestBody()))
+	assert.Equal(t, "respons", string(jrnl.snapshot()[0].responseBody()))
+	assert.Equal(t, "request body", string(live.body))
+
+	jrnl = newJournal(journalLimit)
+
+	require.NoError(t, jrnl.configure(&journalOptions{limit: journalLimit, bodies: bodiesHash}))
+
+	jrnl.add(newEntry())
+
+	sum := sha256.Sum256([]byte("request body"))
+
+	assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), string(jrnl.snapshot()[0].requestBody()))
+
+	jrnl = newJournal(journalLimit)
+
+	require.NoError(t, jrnl.configure(&journalOptions{limit: journalLimit, bodies: bodiesDisk}))
+
+	jrnl.add(newEntry())
+
+	stored := jrnl.snapshot()[0]
+
+	assert.Nil(t, stored.body)
+	assert.Equal(t, "request body", string(stored.requestBody()))
+	assert.Equal(t, "response body", string(stored.responseBody()))
+
+	dir := jrnl.disk.dir
+
+	require.NoError(t, jrnl.close())
+
+	_, err := os.Stat(dir)
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestJournalRetention(t *testing.T) {
+	t.Parallel()
+
+	jrnl := newJournal(journalLimit)
+
+	require.NoError(t, jrnl.configure(&journalOptions{limit: 3, retention: time.Minute, bodies: bodiesDisk}))
+
+	defer jrnl.close() // nolint:errcheck
+
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		jrnl.add(&entry{time: start.Add(time.Duration(i) * time.Second), path: string(rune('a' + i))})
+	}
+
+	entries := jrnl.snapshot()
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, "b", entries[0].path)
+
+	jrnl.add(&entry{time: start.Add(2 * time.Minute), path: "e"})
+
+	entries = jrnl.snapshot()
+
+	require.Len(t, entries, 1)
+	assert.Equal(t, "e", entries[0].path)
+}
+
+func TestDiskBodiesSegments(t *testing.T) {
+	t.Parallel()
+
+	disk, err := newDiskBodies(t.TempDir())
+
+	require.NoError(t, err)
+
+	first, err := disk.write([]byte("abc"), []byte("de"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(first.read(first.offset, first.reqLen)))
+
+	disk.current.size = segmentSize
+
+	second, err := disk.write(nil, []byte("fg"))
+
+	require.NoError(t, err)
+	assert.NotSame(t, first.segment, second.segment)
+	assert.Equal(t, "fg", string(second.read(second.offset, second.resLen)))
+
+	disk.release(first)
+
+	_, err = os.Stat(first.segment.file.Name())
+
+	assert.True(t, os.IsNotExist(err))
+	assert.Len(t, disk.all, 1)
+
+	require.NoError(t, disk.close())
+	assert.Empty(t, disk.all)
+}
@@ -0,0 +1,322 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	defaultClientHeader   = "X-Client-Id"
+	defaultStormWindow    = time.Second
+	defaultStormThreshold = 5
+
+	otherClients = "(other)" // clients tracked beyond the limit
+)
+
+// failure is a failed request of a client, followed by its retries.
+type failure struct {
+	last    time.Time
+	retries []time.Time // retries within the detection window
+	storm   *retryStorm
+}
+
+// retryStorm is a burst of identical requests of a client after error responses.
+type retryStorm struct {
+	client  string
+	method  string
+	path    string
+	start   time.Time
+	retries int
+}
+
+func (s *retryStorm) object() map[string]interface{} {
+	return map[string]interface{}{
+		"client":  s.client,
+		"method":  s.method,
+		"path":    s.path,
+		"start":   s.start.UnixMilli(),
+		"retries": s.retries,
+	}
+}
+
+// clientRetries are the request and retry counts of a client.
+type clientRetries struct {
+	requests int
+	retries  int
+	storms   int
+	first    time.Time
+	last     time.Time
+}
+
+func (c *clientRetries) object(client string) map[string]interface{} {
+	rate := 0.0
+
+	if elapsed := c.last.Sub(c.first); elapsed > 0 {
+		rate = float64(c.retries) / elapsed.Seconds()
+	}
+
+	return map[string]interface{}{
+		"client":   client,
+		"requests": c.requests,
+		"retries":  c.retries,
+		"storms":   c.storms,
+		"rate":     rate,
+	}
+}
+
+// retryDetector tracks the retries of failed requests (identical requests of the same client after
+// an error response). A retry storm is flagged when the number of retries within the window reaches
+// the threshold. Clients are identified by request header, or by remote address without the header.
+type retryDetector struct {
+	mu        sync.Mutex
+	header    string
+	window    time.Duration
+	threshold int
+	failures  map[string]*failure
+	storms    []*retryStorm
+	clients   map[string]*clientRetries
+}
+
+func newRetryDetector(header string, window time.Duration, threshold int) *retryDetector {
+	return &retryDetector{
+		header:    header,
+		window:    window,
+		threshold: threshold,
+		failures:  make(map[string]*failure),
+		clients:   make(map[string]*clientRetries),
+	}
+}
+
+// clone returns a detector with the same settings and without tracked requests.
+func (d *retryDetector) clone() *retryDetector {
+	return newRetryDetector(d.header, d.window, d.threshold)
+}
+
+// getRetryStorm parses the retryStorm option. It is either a boolean or an object with header, window
+// (in milliseconds) and threshold properties.
+func getRetryStorm(value sobek.Value) *retryDetector {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if value.ToBoolean() {
+			return newRetryDetector(defaultClientHeader, defaultStormWindow, defaultStormThreshold)
+		}
+
+		return nil
+	}
+
+	detector := newRetryDetector(defaultClientHeader, defaultStormWindow, defaultStormThreshold)
+
+	if v := obj.Get("header"); v != nil && !sobek.IsUndefined(v) {
+		detector.header = v.String()
+	}
+
+	if v := obj.Get("window"); v != nil && !sobek.IsUndefined(v) {
+		detector.window = millis(v.Export())
+	}
+
+	if v := obj.Get("threshold"); v != nil && !sobek.IsUndefined(v) && v.ToInteger() > 0 {
+		detector.threshold = int(v.ToInteger())
+	}
+
+	return detector
+}
+
+// client returns the identifier of the request's client.
+func (d *retryDetector) client(r *http.Request) string {
+	if id := r.Header.Get(d.header); len(id) != 0 {
+		return id
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+// fingerprint identifies identical requests by method, URL and body.
+func fingerprint(record *entry) string {
+	hash := fnv.New64a()
+
+	hash.Write(record.body) // nolint:errcheck,gosec
+
+	return record.method + " " + record.path + "?" + record.query.Encode() + " " + strconv.FormatUint(hash.Sum64(), 16)
+}
+
+func failed(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// observe tracks the exchange. It returns the time elapsed since the previous failed attempt if the
+// request is a retry (negative otherwise), and the storm if the retry started one.
+func (d *retryDetector) observe(client string, record *entry) (time.Duration, *retryStorm) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := client + " " + fingerprint(record)
+	prev := d.failures[key]
+
+	stats := d.clientStats(client, record.time)
+
+	stats.requests++
+	stats.last = record.time
+
+	var (
+		interval time.Duration
+		started  *retryStorm
+	)
+
+	if prev != nil {
+		stats.retries++
+		interval = record.time.Sub(prev.last)
+
+		retries := prev.retries[:0]
+
+		for _, at := range prev.retries {
+			if record.time.Sub(at) < d.window {
+				retries = append(retries, at)
+			}
+		}
+
+		prev.retries = append(retries, record.time)
+
+		if prev.storm != nil {
+			prev.storm.retries++
+		} else if len(prev.retries) >= d.threshold {
+			prev.storm = &retryStorm{
+				client:  client,
+				method:  record.method,
+				path:    record.path,
+				start:   prev.retries[0],
+				retries: len(prev.retries),
+			}
+
+			if len(d.storms) >= journalLimit {
+				d.storms = d.storms[1:]
+			}
+
+			d.storms = append(d.storms, prev.storm)
+			stats.storms++
+
+			snapshot := *prev.storm
+			started = &snapshot
+		}
+	}
+
+	switch {
+	case !failed(record.status):
+		delete(d.failures, key)
+	case prev != nil:
+		prev.last = record.time
+	default:
+		if len(d.failures) >= journalLimit {
+			d.failures = make(map[string]*failure)
+		}
+
+		d.failures[key] = &failure{last: record.time}
+	}
+
+	if prev == nil {
+		return -1, nil
+	}
+
+	return interval, started
+}
+
+// clientStats returns the retry counts of the client, it must be called with d.mu held. The number of
+// tracked clients is limited, further clients are counted together.
+func (d *retryDetector) clientStats(client string, now time.Time) *clientRetries {
+	stats, found := d.clients[client]
+	if found {
+		return stats
+	}
+
+	if len(d.clients) >= journalLimit {
+		client = otherClients
+
+		if stats, found = d.clients[client]; found {
+			return stats
+		}
+	}
+
+	stats = &clientRetries{first: now}
+	d.clients[client] = stats
+
+	return stats
+}
+
+// exportClients returns the request and retry counts and the retry rate (retries per second) of the
+// clients.
+func (d *retryDetector) exportClients() []interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	clients := make([]interface{}, 0, len(d.clients))
+
+	for _, client := range sortedKeys(d.clients) {
+		clients = append(clients, d.clients[client].object(client))
+	}
+
+	return clients
+}
+
+func (d *retryDetector) export() []interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	storms := make([]interface{}, 0, len(d.storms))
+
+	for _, storm := range d.storms {
+		storms = append(storms, storm.object())
+	}
+
+	return storms
+}
+
+// detectRetry emits the retry metrics of the exchange and logs the detected retry storms. The client
+// is not a metric tag (it would create a time series per client), it is logged and tracked by the
+// detector (see exportClients).
+func (srv *server) detectRetry(r *http.Request, record *entry) {
+	client := srv.retries.client(r)
+
+	interval, storm := srv.retries.observe(client, record)
+	if interval < 0 {
+		return
+	}
+
+	tags := map[string]string{
+		"mock":   srv.target,
+		"method": record.method,
+	}
+
+	srv.mod.push(srv.mod.metrics.retries, 1, tags)
+	srv.mod.push(srv.mod.metrics.retryInterval, metrics.D(interval), tags)
+
+	if storm == nil {
+		return
+	}
+
+	srv.mod.push(srv.mod.metrics.retryStorms, 1, tags)
+
+	srv.mod.logger.WithField("target", srv.target).
+		WithField("client", client).
+		WithField("method", record.method).
+		WithField("path", record.path).
+		WithField("retries", storm.retries).
+		Warn("retry storm detected")
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDetector(t *testing.T) {
+	t.Parallel()
+
+	detector := newRetryDetector(defaultClientHeader, time.Second, 3)
+	now := time.Now()
+
+	exchange := func(offset time.Duration, status int) (time.Duration, *retryStorm) {
+		return detector.observe("vu-1", &entry{time: now.Add(offset), method: http.MethodGet, path: "/orders", status: status})
+	}
+
+	interval, storm := exchange(0, http.StatusServiceUnavailable)
+
+	assert.Negative(t, interval)
+	assert.Nil(t, storm)
+
+	interval, storm = exchange(100*time.Millisecond, http.StatusServiceUnavailable)
+
+	assert.Equal(t, 100*time.Millisecond, interval)
+	assert.Nil(t, storm)
+
+	exchange(150*time.Millisecond, http.StatusServiceUnavailable)
+
+	_, storm = exchange(200*time.Millisecond, http.StatusServiceUnavailable)
+
+	if assert.NotNil(t, storm) {
+		assert.Equal(t, 3, storm.retries)
+		assert.Equal(t, "/orders", storm.path)
+	}
+
+	_, storm = exchange(250*time.Millisecond, http.StatusOK)
+
+	assert.Nil(t, storm)
+	assert.Len(t, detector.export(), 1)
+	assert.Equal(t, 4, detector.export()[0].(map[string]interface{})["retries"])
+
+	interval, _ = exchange(300*time.Millisecond, http.StatusOK)
+
+	assert.Negative(t, interval)
+
+	// slow retries (backoff) do not start a storm
+	for idx := 0; idx < 5; idx++ {
+		_, storm = exchange(time.Duration(idx+1)*2*time.Second, http.StatusTooManyRequests)
+
+		assert.Nil(t, storm)
+	}
+
+	clients := detector.exportClients()
+
+	require.Len(t, clients, 1)
+
+	client := clients[0].(map[string]interface{}) // nolint:forcetypeassert
+
+	assert.Equal(t, "vu-1", client["client"])
+	assert.Equal(t, 11, client["requests"])
+	assert.Equal(t, 8, client["retries"])
+	assert.Equal(t, 1, client["storms"])
+	assert.InDelta(t, 0.8, client["rate"], 0.001)
+
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+
+	assert.Equal(t, "192.0.2.1", detector.client(r))
+
+	r.Header.Set(defaultClientHeader, "vu-2")
+
+	assert.Equal(t, "vu-2", detector.client(r))
+}
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const headerStream = "X-Mock-Stream"
+
+// streaming is the chunked response of a route handler: the body is written in chunks of the given sizes,
+// each chunk is flushed to the client and the next one is written after the interval.
+type streaming struct {
+	chunks   []int
+	interval time.Duration
+	sleep    func(time.Duration)
+}
+
+// getStreaming parses the X-Mock-Stream control header (set by res.end and res.stream): the chunk sizes
+// and the interval between the chunks.
+func getStreaming(value string) *streaming {
+	if len(value) == 0 {
+		return nil
+	}
+
+	var spec struct {
+		Chunks   []int       `json:"chunks"`
+		Interval interface{} `json:"interval"`
+	}
+
+	if err := json.Unmarshal([]byte(value), &spec); err != nil {
+		return nil
+	}
+
+	stream := &streaming{interval: duration(spec.Interval)}
+
+	for _, size := range spec.Chunks {
+		if size > 0 {
+			stream.chunks = append(stream.chunks, size)
+		}
+	}
+
+	return stream
+}
+
+// streamOf returns the streaming of the response, nil if it is not streamed.
+func streamOf(r *http.Request, control http.Header) *streaming {
+	stream := getStreaming(control.Get(headerStream))
+	if stream != nil {
+		stream.sleep = func(d time.Duration) { sleepContext(r, d) }
+	}
+
+	return stream
+}
+
+// write writes the data at chunk boundaries, data after the last chunk is written as is.
+func (s *streaming) write(w http.ResponseWriter, data []byte) (int, error) {
+	written := 0
+
+	for len(data) != 0 {
+		if len(s.chunks) == 0 {
+			n, err := w.Write(data)
+
+			return written + n, err
+		}
+
+		size := s.chunks[0]
+		if size > len(data) {
+			size = len(data)
+		}
+
+		n, err := w.Write(data[:size])
+
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		data = data[size:]
+
+		if s.chunks[0] -= size; s.chunks[0] != 0 {
+			continue
+		}
+
+		s.chunks = s.chunks[1:]
+
+		http.NewResponseController(w).Flush() // nolint:errcheck,gosec
+
+		if len(s.chunks) != 0 && s.sleep != nil {
+			s.sleep(s.interval)
+		}
+	}
+
+	return written, nil
+}
+
+// chunks are the chunks written to a response object by write, they are sent by end.
+type chunks struct {
+	data   [][]byte
+	binary bool
+}
+
+func (c *chunks) add(chunk interface{}) {
+	_, isBuf := chunk.(sobek.ArrayBuffer)
+	c.binary = c.binary || isBuf
+
+	c.data = append(c.data, chunkBytes(chunk))
+}
+
+// chunksOf returns the chunks written to the response object, they are kept on the response by
+// chunksSymbol (created on the first write).
+func (srv *server) chunksOf(res *sobek.Object) *chunks {
+	if value := res.GetSymbol(chunksSymbol); value != nil {
+		if found, ok := value.Export().(*chunks); ok {
+			return found
+		}
+	}
+
+	created := new(chunks)
+
+	if err := res.SetSymbol(chunksSymbol, created); err != nil {
+		srv.mod.throw(err)
+	}
+
+	return created
+}
+
+// sendChunks sends the written chunks of the response, the server writes them to the client one by
+// one with the given interval.
+func (srv *server) sendChunks(res *sobek.Object, interval sobek.Value) sobek.Value {
+	runtime := srv.mod.runtime()
+	written := srv.chunksOf(res)
+
+	spec := map[string]interface{}{"chunks": chunkSizes(written.data)}
+
+	if interval != nil && !sobek.IsUndefined(interval) && !sobek.IsNull(interval) {
+		spec["interval"] = interval.Export()
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	srv.set(res, headerStream, string(data))
+
+	sendFn, ok := sobek.AssertFunction(res.Get("send"))
+	if !ok {
+		srv.mod.throwf("missing send method", errInvalidArg)
+	}
+
+	body := runtime.ToValue(string(bytes.Join(written.data, nil)))
+	if written.binary {
+		body = runtime.ToValue(runtime.NewArrayBuffer(bytes.Join(written.data, nil)))
+	}
+
+	if _, err := sendFn(res, body); err != nil {
+		srv.mod.throw(err)
+	}
+
+	written.data, written.binary = nil, false
+
+	return res
+}
+
+// writeMethod is the write method of the response objects, it adds a chunk to the streamed response.
+func (srv *server) writeMethod(call sobek.FunctionCall) sobek.Value {
+	res := call.This.ToObject(srv.mod.runtime())
+
+	srv.chunksOf(res).add(call.Argument(0).Export())
+
+	return res
+}
+
+// endMethod is the end method of the response objects, it sends the written chunks (and the given one).
+func (srv *server) endMethod(call sobek.FunctionCall) sobek.Value {
+	runtime := srv.mod.runtime()
+	res := call.This.ToObject(runtime)
+
+	if chunk := call.Argument(0); !sobek.IsUndefined(chunk) && !sobek.IsNull(chunk) {
+		srv.chunksOf(res).add(chunk.Export())
+	}
+
+	return srv.sendChunks(res, optionOf(runtime, call.Argument(1), "interval"))
+}
+
+// streamMethod is the stream method of the response objects, it sends the items of an iterable as chunks.
+func (srv *server) streamMethod(call sobek.FunctionCall) sobek.Value {
+	runtime := srv.mod.runtime()
+	res := call.This.ToObject(runtime)
+	written := srv.chunksOf(res)
+
+	for _, item := range srv.iterate(call.Argument(0)) {
+		written.add(item)
+	}
+
+	return srv.sendChunks(res, optionOf(runtime, call.Argument(1), "interval"))
+}
+
+// iterate returns the items of an array, a generator or any other iterable.
+func (srv *server) iterate(value sobek.Value) []interface{} {
+	runtime := srv.mod.runtime()
+
+	from, ok := sobek.AssertFunction(runtime.Get("Array").ToObject(runtime).Get("from"))
+	if !ok {
+		srv.mod.throwf("missing Array.from", errInvalidArg)
+	}
+
+	array, err := from(sobek.Undefined(), value)
+	if err != nil {
+		srv.mod.throw(err)
+	}
+
+	items, _ := array.Export().([]interface{})
+
+	return items
+}
+
+func optionOf(runtime *sobek.Runtime, opts sobek.Value, name string) sobek.Value {
+	if opts == nil || sobek.IsUndefined(opts) || sobek.IsNull(opts) {
+		return nil
+	}
+
+	return opts.ToObject(runtime).Get(name)
+}
+
+// chunkBytes converts a chunk to bytes: strings and array buffers are sent as is, other values as JSON lines.
+func chunkBytes(chunk interface{}) []byte {
+	switch val := chunk.(type) {
+	case string:
+		return []byte(val)
+	case sobek.ArrayBuffer:
+		return val.Bytes()
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil
+		}
+
+		return append(data, '\n')
+	}
+}
+
+func chunkSizes(chunks [][]byte) []int {
+	sizes := make([]int, 0, len(chunks))
+
+	for _, chunk := range chunks {
+		sizes = append(sizes, len(chunk))
+	}
+
+	return sizes
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flushLog records the data flushed to the client.
+type flushLog struct {
+	*httptest.ResponseRecorder
+	pending []byte
+	flushed []string
+}
+
+func (f *flushLog) Write(data []byte) (int, error) {
+	f.pending = append(f.pending, data...)
+
+	return f.ResponseRecorder.Write(data)
+}
+
+func (f *flushLog) Flush() {
+	f.flushed = append(f.flushed, string(f.pending))
+	f.pending = nil
+}
+
+func TestGetStreaming(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getStreaming(""))
+	assert.Nil(t, getStreaming("{"))
+
+	stream := getStreaming(`{"chunks":[3,0,2],"interval":"1s"}`)
+
+	require.NotNil(t, stream)
+	assert.Equal(t, []int{3, 2}, stream.chunks)
+	assert.Equal(t, time.Second, stream.interval)
+	assert.Equal(t, 20*time.Millisecond, getStreaming(`{"chunks":[1],"interval":20}`).interval)
+}
+
+func TestRecorderStreaming(t *testing.T) {
+	t.Parallel()
+
+	var slept []time.Duration
+
+	w := &flushLog{ResponseRecorder: httptest.NewRecorder()}
+
+	rec := newRecorder(w, nil)
+	rec.stream = func(control http.Header) *streaming {
+		stream := getStreaming(control.Get(headerStream))
+		stream.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+		return stream
+	}
+
+	rec.Header().Set("Content-Length", "9")
+	rec.Header().Set(headerStream, `{"chunks":[3,4,2],"interval":10}`)
+
+	rec.WriteHeader(http.StatusOK)
+
+	_, err := rec.Write([]byte("abcde"))
+
+	require.NoError(t, err)
+
+	_, err = rec.Write([]byte("fghi"))
+
+	require.NoError(t, err)
+
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Header().Get(headerStream))
+	assert.Equal(t, []string{"abc", "defg", "hi"}, w.flushed)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 10 * time.Millisecond}, slept)
+	assert.Equal(t, "abcdefghi", w.Body.String())
+	assert.Equal(t, "abcdefghi", rec.body.String())
+}
+
+func TestChunkBytes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []byte("data: 1\n\n"), chunkBytes("data: 1\n\n"))
+	assert.Equal(t, []byte("{\"n\":1}\n"), chunkBytes(map[string]interface{}{"n": 1}))
+	assert.Equal(t, []int{2, 0, 3}, chunkSizes([][]byte{[]byte("ab"), nil, []byte("cde")}))
+}
@@ -0,0 +1,274 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stubFile is the declarative mock definition format. Depending on the file extension
+// it is stored in YAML (.yaml, .yml) or JSON format.
+type stubFile struct {
+	Target string  `json:"target" yaml:"target"`
+	Stubs  []*stub `json:"stubs"  yaml:"stubs"`
+}
+
+type stub struct {
+	Request  *stubRequest  `json:"request"  yaml:"request"`
+	Response *stubResponse `json:"response" yaml:"response"`
+
+	route  *route
+	schema map[string]interface{} // the body is generated from the schema for each request
+	gen    *generator
+	spy    *spy // the spy recording the calls of the stub, if defined by the script
+}
+
+type stubRequest struct {
+	Method string            `json:"method"          yaml:"method"`
+	Path   string            `json:"path"            yaml:"path"`
+	Query  map[string]string `json:"query,omitempty" yaml:"query,omitempty"`
+}
+
+type stubResponse struct {
+	Status   int               `json:"status"             yaml:"status"`
+	Headers  map[string]string `json:"headers,omitempty"  yaml:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"     yaml:"body,omitempty"`
+	Encoding string            `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+var errInvalidStub = errors.New("invalid stub")
+
+// headerStub is the control header of the responses served by stubs, see stub.name.
+const headerStub = "X-Mock-Stub"
+
+// stubSkippedHeaders are response headers computed by the HTTP server, so they are not stored in stubs.
+var stubSkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Date":              true,
+	"Transfer-Encoding": true,
+}
+
+func newStub(record *entry) *stub {
+	req := &stubRequest{Method: record.method, Path: record.path}
+
+	if len(record.query) != 0 {
+		req.Query = make(map[string]string, len(record.query))
+
+		for name := range record.query {
+			req.Query[name] = record.query.Get(name)
+		}
+	}
+
+	res := &stubResponse{Status: record.status}
+
+	for name := range record.resHeader {
+		if stubSkippedHeaders[name] {
+			continue
+		}
+
+		if res.Headers == nil {
+			res.Headers = make(map[string]string)
+		}
+
+		res.Headers[name] = record.resHeader.Get(name)
+	}
+
+	if body := record.responseBody(); utf8.Valid(body) {
+		res.Body = string(body)
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(body)
+		res.Encoding = "base64"
+	}
+
+	return &stub{Request: req, Response: res}
+}
+
+// newStubFile creates stubs covering the endpoints observed in the recorded exchanges.
+// Unmatched requests are skipped, the latest response wins for repeated requests.
+func newStubFile(target string, records []*entry) *stubFile {
+	file := &stubFile{Target: target, Stubs: make([]*stub, 0)}
+	index := make(map[string]int)
+
+	for _, record := range records {
+		if record.status == 0 || record.status == http.StatusNotFound && len(record.route) == 0 {
+			continue
+		}
+
+		key := record.method + " " + record.path + "?" + record.query.Encode()
+
+		if idx, found := index[key]; found {
+			file.Stubs[idx] = newStub(record)
+
+			continue
+		}
+
+		index[key] = len(file.Stubs)
+		file.Stubs = append(file.Stubs, newStub(record))
+	}
+
+	return file
+}
+
+func isYAML(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func (file *stubFile) write(filename string) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	if isYAML(filename) {
+		data, err = yaml.Marshal(file)
+	} else {
+		data, err = json.MarshalIndent(file, "", "  ")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0o600)
+}
+
+func readStubFile(filename string) (*stubFile, error) {
+	data, err := os.ReadFile(filename) // nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	file := new(stubFile)
+
+	if isYAML(filename) {
+		err = yaml.Unmarshal(data, file)
+	} else {
+		err = json.Unmarshal(data, file)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range file.Stubs {
+		if s.Request == nil || s.Response == nil {
+			return nil, errInvalidStub
+		}
+
+		if len(s.Request.Method) == 0 {
+			s.Request.Method = http.MethodGet
+		}
+
+		s.Request.Method = strings.ToUpper(s.Request.Method)
+		s.route = &route{method: s.Request.Method, pattern: s.Request.Path, segments: compilePath(s.Request.Path)}
+	}
+
+	return file, nil
+}
+
+func (s *stub) matches(r *http.Request) bool {
+	if !s.route.accepts(r.Method) {
+		return false
+	}
+
+	if _, ok := s.route.match(r.URL.Path); !ok {
+		return false
+	}
+
+	query := r.URL.Query()
+
+	for name, value := range s.Request.Query {
+		if !query.Has(name) || query.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) { // nolint:varnamelen
+	w.Header().Set(headerStub, s.name())
+
+	body := []byte(s.Response.Body)
+
+	if s.gen != nil {
+		value, err := s.gen.generate(s.schema)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		body, _ = json.Marshal(value)
+	}
+
+	if s.Response.Encoding == "base64" {
+		if data, err := base64.StdEncoding.DecodeString(s.Response.Body); err == nil {
+			body = data
+		}
+	}
+
+	var params map[string]string
+
+	if s.route != nil {
+		params, _ = s.route.match(r.URL.Path)
+	}
+
+	for name, value := range s.Response.Headers {
+		w.Header().Set(name, expandTemplate(value, r, params))
+	}
+
+	status := s.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	w.Write(body) // nolint:errcheck,gosec
+}
+
+// name identifies the stub in the events, by the method and path of its request.
+func (s *stub) name() string {
+	return s.Request.Method + " " + s.Request.Path
+}
+
+// record records the call of the request served by the stub, if it was defined by the script.
+func (s *stub) record(r *http.Request) {
+	if s.spy != nil {
+		s.spy.record(r)
+	}
+}
+
+// stub returns the first stub matching the request, the stubs defined by the script are checked first.
+// It only matches the request, the calls are recorded by the serving path (see record).
+func (srv *server) stub(r *http.Request) *stub {
+	if s := srv.spied(r); s != nil {
+		return s
+	}
+
+	for _, s := range srv.stubs {
+		if s.matches(r) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// exportStubs writes stubs generated from the recorded exchanges of the server to the given file.
+func (srv *server) exportStubs(filename string) error {
+	return newStubFile(srv.target, srv.journal.snapshot()).write(filename)
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubFile(t *testing.T) {
+	t.Parallel()
+
+	records := []*entry{
+		{
+			method:    http.MethodGet,
+			path:      "/orders/1",
+			query:     url.Values{},
+			status:    http.StatusOK,
+			route:     "/orders/:id",
+			resHeader: http.Header{"Content-Type": {"application/json"}, "Date": {"today"}},
+			resBody:   []byte(`{"id":1,"status":"new"}`),
+		},
+		{
+			method:    http.MethodGet,
+			path:      "/orders/1",
+			query:     url.Values{},
+			status:    http.StatusOK,
+			route:     "/orders/:id",
+			resHeader: http.Header{"Content-Type": {"application/json"}},
+			resBody:   []byte(`{"id":1,"status":"shipped"}`),
+		},
+		{
+			method:    http.MethodGet,
+			path:      "/search",
+			query:     url.Values{"q": {"book"}},
+			status:    http.StatusOK,
+			route:     "/search",
+			resHeader: http.Header{},
+			resBody:   []byte{0xff},
+		},
+		{
+			method:    http.MethodGet,
+			path:      "/missing",
+			query:     url.Values{},
+			status:    http.StatusNotFound,
+			resHeader: http.Header{},
+		},
+	}
+
+	file := newStubFile("https://example.com", records)
+
+	require.Len(t, file.Stubs, 2)
+	assert.Equal(t, `{"id":1,"status":"shipped"}`, file.Stubs[0].Response.Body)
+	assert.NotContains(t, file.Stubs[0].Response.Headers, "Date")
+	assert.Equal(t, "base64", file.Stubs[1].Response.Encoding)
+
+	for _, name := range []string{"stubs.yaml", "stubs.json"} {
+		filename := filepath.Join(t.TempDir(), name)
+
+		require.NoError(t, file.write(filename))
+
+		loaded, err := readStubFile(filename)
+
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", loaded.Target)
+		require.Len(t, loaded.Stubs, 2)
+
+		assert.True(t, loaded.Stubs[1].matches(httptest.NewRequest(http.MethodGet, "/search?q=book", nil)))
+		assert.False(t, loaded.Stubs[1].matches(httptest.NewRequest(http.MethodGet, "/search?q=pen", nil)))
+		assert.False(t, loaded.Stubs[1].matches(httptest.NewRequest(http.MethodPost, "/search?q=book", nil)))
+
+		rec := httptest.NewRecorder()
+
+		loaded.Stubs[1].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=book", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []byte{0xff}, rec.Body.Bytes())
+	}
+}
@@ -0,0 +1,216 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// statistics collects per mock target data for the end-of-test summary.
+// It is shared by all module instances (VUs) of the process.
+type statistics struct {
+	mu      sync.Mutex
+	targets map[string]*targetStats
+}
+
+type targetStats struct {
+	requests      int
+	unmatched     int
+	faults        int // injected faults
+	routes        map[string]*routeStats
+	verifications map[string]*verificationStats // results of the verifications by name
+}
+
+type routeStats struct {
+	requests int
+	statuses map[string]int
+}
+
+type verification struct {
+	name   string
+	count  int
+	passed bool
+}
+
+// verificationStats aggregates the results of a verification evaluated repeatedly (e.g. by every VU
+// and iteration), so the statistics don't grow with the test duration.
+type verificationStats struct {
+	runs   int
+	failed int
+	count  int // request count of the last evaluation
+}
+
+func newStatistics() *statistics {
+	return &statistics{targets: make(map[string]*targetStats)}
+}
+
+func (stats *statistics) target(name string) *targetStats {
+	target, found := stats.targets[name]
+	if !found {
+		target = &targetStats{
+			routes:        make(map[string]*routeStats),
+			verifications: make(map[string]*verificationStats),
+		}
+		stats.targets[name] = target
+	}
+
+	return target
+}
+
+func (stats *statistics) request(target string, record *entry) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	tstats := stats.target(target)
+
+	tstats.requests++
+
+	if len(record.route) == 0 {
+		tstats.unmatched++
+
+		return
+	}
+
+	key := record.method + " " + record.route
+
+	rstats, found := tstats.routes[key]
+	if !found {
+		rstats = &routeStats{statuses: make(map[string]int)}
+		tstats.routes[key] = rstats
+	}
+
+	rstats.requests++
+	rstats.statuses[strconv.Itoa(record.status)]++
+}
+
+func (stats *statistics) verify(target string, ver *verification) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	tstats := stats.target(target)
+
+	vstats, found := tstats.verifications[ver.name]
+	if !found {
+		vstats = new(verificationStats)
+		tstats.verifications[ver.name] = vstats
+	}
+
+	vstats.runs++
+	vstats.count = ver.count
+
+	if !ver.passed {
+		vstats.failed++
+	}
+}
+
+// fault counts a fault injected by the mock server of the target.
+func (stats *statistics) fault(target string) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.target(target).faults++
+}
+
+func (stats *statistics) export() map[string]interface{} {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	servers := make(map[string]interface{}, len(stats.targets))
+
+	for name, tstats := range stats.targets {
+		routes := make(map[string]interface{}, len(tstats.routes))
+
+		for key, rstats := range tstats.routes {
+			statuses := make(map[string]interface{}, len(rstats.statuses))
+
+			for status, count := range rstats.statuses {
+				statuses[status] = count
+			}
+
+			routes[key] = map[string]interface{}{"requests": rstats.requests, "statuses": statuses}
+		}
+
+		verifications := make([]interface{}, 0, len(tstats.verifications))
+
+		for _, ver := range sortedKeys(tstats.verifications) {
+			vstats := tstats.verifications[ver]
+
+			verifications = append(verifications, map[string]interface{}{
+				"name":   ver,
+				"count":  vstats.count,
+				"passed": vstats.failed == 0,
+				"runs":   vstats.runs,
+				"failed": vstats.failed,
+			})
+		}
+
+		servers[name] = map[string]interface{}{
+			"requests":      tstats.requests,
+			"unmatched":     tstats.unmatched,
+			"faults":        tstats.faults,
+			"routes":        routes,
+			"verifications": verifications,
+		}
+	}
+
+	return map[string]interface{}{"servers": servers}
+}
+
+// text renders the statistics in a format similar to k6's end-of-test text summary.
+func (stats *statistics) text() string {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	var buff strings.Builder
+
+	for _, name := range sortedKeys(stats.targets) {
+		tstats := stats.targets[name]
+
+		fmt.Fprintf(&buff, "\n     mock %s\n", name)
+		fmt.Fprintf(&buff, "       requests.......: %d\n", tstats.requests)
+		fmt.Fprintf(&buff, "       unmatched......: %d\n", tstats.unmatched)
+		fmt.Fprintf(&buff, "       faults.........: %d\n", tstats.faults)
+
+		for _, key := range sortedKeys(tstats.routes) {
+			rstats := tstats.routes[key]
+
+			statuses := make([]string, 0, len(rstats.statuses))
+			for _, status := range sortedKeys(rstats.statuses) {
+				statuses = append(statuses, fmt.Sprintf("%s=%d", status, rstats.statuses[status]))
+			}
+
+			fmt.Fprintf(&buff, "       %s: %d (%s)\n", key, rstats.requests, strings.Join(statuses, " "))
+		}
+
+		for _, ver := range sortedKeys(tstats.verifications) {
+			vstats := tstats.verifications[ver]
+
+			mark := "✓"
+			if vstats.failed != 0 {
+				mark = "✗"
+			}
+
+			fmt.Fprintf(&buff, "       %s %s (%d) %d/%d passed\n", mark, ver, vstats.count, vstats.runs-vstats.failed, vstats.runs)
+		}
+	}
+
+	return buff.String()
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatistics(t *testing.T) {
+	t.Parallel()
+
+	stats := newStatistics()
+
+	stats.request("https://example.com", &entry{method: http.MethodGet, route: "/orders", status: 200})
+	stats.request("https://example.com", &entry{method: http.MethodGet, route: "/orders", status: 500})
+	stats.request("https://example.com", &entry{method: http.MethodGet, path: "/missing", status: 404})
+	stats.verify("https://example.com", &verification{name: "GET /orders once()", count: 1, passed: true})
+	stats.verify("https://example.com", &verification{name: "GET /orders once()", count: 2})
+	stats.fault("https://example.com")
+
+	exported := stats.export()["servers"].(map[string]interface{})["https://example.com"].(map[string]interface{}) // nolint:forcetypeassert
+
+	assert.Equal(t, 3, exported["requests"])
+	assert.Equal(t, 1, exported["unmatched"])
+
+	route := exported["routes"].(map[string]interface{})["GET /orders"].(map[string]interface{}) // nolint:forcetypeassert
+
+	assert.Equal(t, 2, route["requests"])
+	assert.Equal(t, 1, route["statuses"].(map[string]interface{})["500"])
+	assert.Equal(t, 1, exported["faults"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name": "GET /orders once()", "count": 2, "passed": false, "runs": 2, "failed": 1,
+	}}, exported["verifications"])
+
+	text := stats.text()
+
+	assert.Contains(t, text, "mock https://example.com")
+	assert.Contains(t, text, "GET /orders: 2 (200=1 500=1)")
+	assert.Contains(t, text, "faults.........: 1")
+	assert.Contains(t, text, "✗ GET /orders once() (2) 1/2 passed")
+}
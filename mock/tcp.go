@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+const tcpMaxExpect = 64 << 10
+
+var errExpectOverflow = errors.New("expected data not received")
+
+const (
+	tcpSend = iota
+	tcpExpect
+	tcpReply
+	tcpEcho
+	tcpHangup
+)
+
+// tcpStep is a step of the conversation with the client.
+type tcpStep struct {
+	kind    int
+	data    []byte
+	fn      sobek.Callable // computes the reply from the matched groups
+	pattern *regexp.Regexp
+}
+
+// jsRegexp converts the JavaScript RegExp object to Go regular expression. Other values are
+// matched literally. The i, m and s flags are supported.
+func jsRegexp(value sobek.Value) (*regexp.Regexp, error) {
+	obj, isObj := value.(*sobek.Object)
+	if !isObj || obj.ClassName() != "RegExp" {
+		return regexp.Compile(regexp.QuoteMeta(value.String()))
+	}
+
+	var flags string
+
+	for _, flag := range obj.Get("flags").String() {
+		if strings.ContainsRune("ims", flag) {
+			flags += string(flag)
+		}
+	}
+
+	source := obj.Get("source").String()
+	if len(flags) != 0 {
+		source = "(?" + flags + ")" + source
+	}
+
+	return regexp.Compile(source)
+}
+
+// tcpMock is a generic TCP mock serving a scripted conversation: it may send a banner, expect
+// data (literal or regular expression), reply, echo and close the connection.
+type tcpMock struct {
+	*endpoint
+	mu       sync.Mutex
+	steps    []*tcpStep
+	loop     int // steps from this index are repeated, -1 if not repeated
+	received [][]byte
+}
+
+func (mod *Module) newTCPMock(args *mockArgs) *tcpMock {
+	conv := &tcpMock{endpoint: mod.newEndpoint(args, "tcp"), loop: -1}
+
+	step := func(s *tcpStep) *sobek.Object {
+		conv.mu.Lock()
+		conv.steps = append(conv.steps, s)
+		conv.mu.Unlock()
+
+		return conv.obj
+	}
+
+	conv.mustSet("send", func(data sobek.Value) *sobek.Object {
+		return step(&tcpStep{kind: tcpSend, data: payloadBytes(data)})
+	})
+
+	conv.mustSet("expect", func(pattern sobek.Value) *sobek.Object {
+		re, err := jsRegexp(pattern)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		return step(&tcpStep{kind: tcpExpect, pattern: re})
+	})
+
+	conv.mustSet("reply", func(data sobek.Value) *sobek.Object {
+		if fn, isFn := sobek.AssertFunction(data); isFn {
+			return step(&tcpStep{kind: tcpReply, fn: fn})
+		}
+
+		return step(&tcpStep{kind: tcpReply, data: payloadBytes(data)})
+	})
+
+	conv.mustSet("echo", func() *sobek.Object {
+		return step(&tcpStep{kind: tcpEcho})
+	})
+
+	conv.mustSet("hangup", func() *sobek.Object {
+		return step(&tcpStep{kind: tcpHangup})
+	})
+
+	conv.mustSet("repeat", func() *sobek.Object {
+		conv.mu.Lock()
+		conv.loop = len(conv.steps)
+		conv.mu.Unlock()
+
+		return conv.obj
+	})
+
+	conv.mustSet("received", func() []interface{} {
+		conv.mu.Lock()
+		defer conv.mu.Unlock()
+
+		found := make([]interface{}, 0, len(conv.received))
+
+		for _, data := range conv.received {
+			found = append(found, string(data))
+		}
+
+		return found
+	})
+
+	return conv
+}
+
+// tcpSession is the state of a served connection.
+type tcpSession struct {
+	conn   net.Conn
+	buff   []byte
+	groups []string // groups of the last expect step's match
+}
+
+// expect reads until the buffered data matches the pattern, the data is consumed up to the end of the match.
+func (s *tcpSession) expect(pattern *regexp.Regexp) error {
+	chunk := make([]byte, 4096)
+
+	for {
+		if loc := pattern.FindSubmatchIndex(s.buff); loc != nil {
+			s.groups = make([]string, 0, len(loc)/2)
+
+			for idx := 0; idx < len(loc); idx += 2 {
+				if loc[idx] < 0 {
+					s.groups = append(s.groups, "")
+				} else {
+					s.groups = append(s.groups, string(s.buff[loc[idx]:loc[idx+1]]))
+				}
+			}
+
+			s.buff = s.buff[loc[1]:]
+
+			return nil
+		}
+
+		if len(s.buff) > tcpMaxExpect {
+			return errExpectOverflow
+		}
+
+		n, err := s.conn.Read(chunk)
+		if err != nil {
+			return err
+		}
+
+		s.buff = append(s.buff, chunk[:n]...)
+	}
+}
+
+// record stores the data matched by an expect step.
+func (conv *tcpMock) record(data string) {
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	if len(conv.received) >= journalLimit {
+		conv.received = conv.received[1:]
+	}
+
+	conv.received = append(conv.received, []byte(data))
+}
+
+// reply returns the data of the reply step.
+func (conv *tcpMock) reply(step *tcpStep, groups []string) []byte {
+	if step.fn == nil {
+		return step.data
+	}
+
+	var data []byte
+
+	err := conv.call(func() error {
+		result, err := step.fn(sobek.Undefined(), conv.mod.runtime().ToValue(groups))
+		if err != nil {
+			return err
+		}
+
+		data = payloadBytes(result)
+
+		return nil
+	})
+	if err != nil {
+		conv.mod.logger.WithError(err).WithField("target", conv.target).Warn("mock handler failed")
+	}
+
+	return data
+}
+
+// repeatable reports whether the steps from loop can be repeated, the repeated steps must wait for the client.
+func repeatable(steps []*tcpStep, loop int) bool {
+	if loop < 0 {
+		return false
+	}
+
+	for _, step := range steps[loop:] {
+		if step.kind == tcpExpect {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serveConn plays the conversation. When the steps are over (and not repeated), the client's data is discarded
+// until the client closes the connection.
+func (conv *tcpMock) serveConn(conn net.Conn) {
+	conv.mu.Lock()
+	steps, loop := conv.steps, conv.loop
+	conv.mu.Unlock()
+
+	if !repeatable(steps, loop) {
+		loop = -1
+	}
+
+	session := &tcpSession{conn: conn}
+
+	for idx := 0; ; idx++ {
+		if idx == len(steps) {
+			if loop < 0 {
+				io.Copy(io.Discard, conn) // nolint:errcheck,gosec
+
+				return
+			}
+
+			idx = loop
+		}
+
+		step := steps[idx]
+
+		var err error
+
+		switch step.kind {
+		case tcpSend:
+			_, err = conn.Write(step.data)
+		case tcpExpect:
+			if err = session.expect(step.pattern); err == nil {
+				conv.record(session.groups[0])
+			}
+		case tcpReply:
+			_, err = conn.Write(conv.reply(step, session.groups))
+		case tcpEcho:
+			if _, err := conn.Write(session.buff); err == nil {
+				io.Copy(conn, conn) // nolint:errcheck,gosec
+			}
+
+			return
+		case tcpHangup:
+			return
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				conv.mod.logger.WithError(err).WithField("target", conv.target).Debug("mock connection closed")
+			}
+
+			return
+		}
+	}
+}
+
+// tcp starts a generic TCP mock of the target (e.g. tcp://smtp.example.com:25).
+func (mod *Module) tcp(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+	conv := mod.newTCPMock(args)
+
+	return mod.startEndpoint(args, conv.endpoint, conv.serveConn)
+}
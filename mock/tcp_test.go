@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPSessionExpect(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+
+	defer client.Close() // nolint:errcheck
+
+	go func() {
+		client.Write([]byte("HELO cli"))        // nolint:errcheck
+		client.Write([]byte("ent\r\nQUIT\r\n")) // nolint:errcheck
+	}()
+
+	session := &tcpSession{conn: server}
+
+	require.NoError(t, session.expect(regexp.MustCompile(`HELO (\S+)\r\n`)))
+	assert.Equal(t, []string{"HELO client\r\n", "client"}, session.groups)
+	assert.Equal(t, "QUIT\r\n", string(session.buff))
+
+	require.NoError(t, session.expect(regexp.MustCompile(regexp.QuoteMeta("QUIT\r\n"))))
+	assert.Empty(t, session.buff)
+}
+
+func TestTCPMock(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+const conv = mock.tcp("tcp://smtp.example.com:25", conv => {
+	conv.send("220 smtp.example.com ESMTP\r\n")
+	conv.repeat()
+	conv.expect(/^HELO (\S+)\r\n/i).reply(match => "250 Hello " + match[1] + "\r\n")
+	conv.expect("QUIT\r\n").reply("221 Bye\r\n").hangup()
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(helper.module.lookup["tcp://smtp.example.com:25"], "tcp://"))
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	_, err = conn.Write([]byte("helo client\r\nQUIT\r\n"))
+
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+
+	for _, expected := range []string{"220 smtp.example.com ESMTP\r\n", "250 Hello client\r\n", "221 Bye\r\n"} {
+		line, err := reader.ReadString('\n')
+
+		require.NoError(t, err)
+		assert.Equal(t, expected, line)
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strings"
+)
+
+// expandTemplate replaces the placeholders of the stub's header value with request values:
+// {{request.method}}, {{request.path}}, {{request.url}} (path and query), {{request.host}},
+// {{request.headers.<name>}}, {{request.query.<name>}} and {{request.params.<name>}} (route
+// parameters). Unknown placeholders are replaced with empty string (see renderTemplate).
+func expandTemplate(value string, r *http.Request, params map[string]string) string {
+	if !strings.Contains(value, "{{") {
+		return value
+	}
+
+	return renderTemplate(value, func(path []string) (interface{}, bool) {
+		value := requestValue(path, r, params)
+
+		return value, len(value) != 0
+	})
+}
+
+func requestValue(path []string, r *http.Request, params map[string]string) string {
+	if len(path) < 2 || path[0] != "request" {
+		return ""
+	}
+
+	if len(path) == 2 {
+		switch path[1] {
+		case "method":
+			return r.Method
+		case "path":
+			return r.URL.Path
+		case "url":
+			return r.URL.RequestURI()
+		case "host":
+			return r.Host
+		}
+
+		return ""
+	}
+
+	if len(path) != 3 {
+		return ""
+	}
+
+	switch name := path[2]; path[1] {
+	case "headers":
+		return r.Header.Get(name)
+	case "query":
+		return r.URL.Query().Get(name)
+	case "params":
+		return params[name]
+	default:
+		return ""
+	}
+}
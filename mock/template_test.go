@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodPut, "http://api.example.com/users/42?page=2", nil)
+	req.Header.Set("X-Request-Id", "abc")
+
+	params := map[string]string{"id": "42"}
+
+	assert.Equal(t, "abc", expandTemplate("{{request.headers.X-Request-Id}}", req, params))
+	assert.Equal(t, "abc", expandTemplate("{{ request.headers.x-request-id }}", req, params))
+	assert.Equal(t, "abc", expandTemplate("{{request.headers['x-request-id']}}", req, params))
+	assert.Equal(t, "/users/42/profile", expandTemplate("/users/{{request.params.id}}/profile", req, params))
+	assert.Equal(t, "PUT /users/42?page=2", expandTemplate("{{request.method}} {{request.url}}", req, params))
+	assert.Equal(t, "2 api.example.com", expandTemplate("{{request.query.page}} {{request.host}}", req, params))
+	assert.Equal(t, "[]", expandTemplate("[{{request.cookies.id}}{{other}}]", req, params))
+	assert.Equal(t, "static", expandTemplate("static", req, params))
+}
+
+func TestStubHeaderTemplate(t *testing.T) {
+	t.Parallel()
+
+	s := &stub{
+		Request: &stubRequest{Method: http.MethodPost, Path: "/orders/:id"},
+		Response: &stubResponse{Status: http.StatusCreated, Headers: map[string]string{
+			"Content-Location": "/orders/{{request.params.id}}",
+			"X-Request-Id":     "{{request.headers.X-Request-Id}}",
+		}},
+		route: newPathMatcher("/orders/:id"),
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/orders/7", nil)
+	req.Header.Set("X-Request-Id", "abc")
+
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/orders/7", rec.Header().Get("Content-Location"))
+	assert.Equal(t, "abc", rec.Header().Get("X-Request-Id"))
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// throttleQuantum is the pacing period of throttled responses, the body is written in slices
+// transferable within the period.
+const throttleQuantum = 100 * time.Millisecond
+
+// bandwidthUnits are the multipliers of the bandwidth units to bytes per second.
+var bandwidthUnits = map[string]float64{ // nolint:gochecknoglobals
+	"bps":  1.0 / 8,
+	"kbps": 1e3 / 8,
+	"mbps": 1e6 / 8,
+	"gbps": 1e9 / 8,
+	"b/s":  1,
+	"kb/s": 1e3,
+	"mb/s": 1e6,
+	"gb/s": 1e9,
+}
+
+var bandwidthPattern = regexp.MustCompile(`^\s*([0-9]*\.?[0-9]+)\s*([a-zA-Z/]*)\s*$`) // nolint:gochecknoglobals
+
+// throttle limits the bandwidth of the response body.
+type throttle struct {
+	rate  float64 // bytes per second
+	sleep func(time.Duration)
+}
+
+// getThrottle parses the throttle route option: bandwidth with unit (bps, kbps, mbps, gbps for bits,
+// B/s, kB/s, MB/s, GB/s for bytes per second, e.g. "50kbps") or number of bytes per second.
+func getThrottle(value interface{}) *throttle {
+	var rate float64
+
+	switch v := value.(type) {
+	case string:
+		match := bandwidthPattern.FindStringSubmatch(v)
+		if match == nil {
+			return nil
+		}
+
+		num, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return nil
+		}
+
+		unit := strings.ToLower(match[2])
+		if len(unit) == 0 {
+			unit = "b/s"
+		}
+
+		multiplier, found := bandwidthUnits[unit]
+		if !found {
+			return nil
+		}
+
+		rate = num * multiplier
+	default:
+		if num, ok := toFloat(v); ok {
+			rate = num
+		}
+	}
+
+	if rate <= 0 {
+		return nil
+	}
+
+	return &throttle{rate: rate}
+}
+
+// throttleOf returns the throttle of the route selected by the dispatcher, nil if the response
+// is not throttled.
+func (srv *server) throttleOf(r *http.Request, control http.Header) *throttle {
+	id, err := strconv.Atoi(control.Get(headerRoute))
+	if err != nil {
+		return nil
+	}
+
+	target := srv.router.get(id)
+	if target == nil || target.throttle == nil {
+		return nil
+	}
+
+	return &throttle{rate: target.throttle.rate, sleep: func(d time.Duration) { sleepContext(r, d) }}
+}
+
+// throttledWriter writes the data in slices, each slice is flushed to the client and followed by
+// the delay of its transfer time at the throttle's rate.
+type throttledWriter struct {
+	http.ResponseWriter
+	throttle *throttle
+}
+
+func (w *throttledWriter) Write(data []byte) (int, error) {
+	slice := int(w.throttle.rate * throttleQuantum.Seconds())
+	if slice < 1 {
+		slice = 1
+	}
+
+	written := 0
+
+	for len(data) != 0 {
+		size := slice
+		if size > len(data) {
+			size = len(data)
+		}
+
+		n, err := w.ResponseWriter.Write(data[:size])
+
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		http.NewResponseController(w.ResponseWriter).Flush() // nolint:errcheck,gosec
+
+		if w.throttle.sleep != nil {
+			w.throttle.sleep(time.Duration(float64(n) / w.throttle.rate * float64(time.Second)))
+		}
+
+		data = data[size:]
+	}
+
+	return written, nil
+}
+
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
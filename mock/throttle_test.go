@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetThrottle(t *testing.T) {
+	t.Parallel()
+
+	assert.InDelta(t, 6250.0, getThrottle("50kbps").rate, 0.001)
+	assert.InDelta(t, 125000.0, getThrottle("1 Mbps").rate, 0.001)
+	assert.InDelta(t, 2000.0, getThrottle("2kB/s").rate, 0.001)
+	assert.InDelta(t, 512.0, getThrottle("512").rate, 0.001)
+	assert.InDelta(t, 1024.0, getThrottle(int64(1024)).rate, 0.001)
+
+	assert.Nil(t, getThrottle("fast"))
+	assert.Nil(t, getThrottle("10 parsecs"))
+	assert.Nil(t, getThrottle(0))
+	assert.Nil(t, getThrottle(nil))
+}
+
+func TestRecorderThrottle(t *testing.T) {
+	t.Parallel()
+
+	var slept time.Duration
+
+	w := &flushLog{ResponseRecorder: httptest.NewRecorder()}
+
+	rec := newRecorder(w, nil)
+	rec.throttle = func(control http.Header) *throttle {
+		return &throttle{rate: 40, sleep: func(d time.Duration) { slept += d }}
+	}
+
+	rec.WriteHeader(http.StatusOK)
+
+	_, err := rec.Write([]byte("0123456789"))
+
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"0123", "4567", "89"}, w.flushed)
+	assert.Equal(t, 250*time.Millisecond, slept)
+	assert.Equal(t, "0123456789", w.Body.String())
+}
@@ -0,0 +1,313 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const certValidity = 365 * 24 * time.Hour
+
+// tlsOptions are the settings of the mock server's TLS listener.
+type tlsOptions struct {
+	sessionTickets bool   // session resumption by TLS session tickets
+	ocsp           string // status of the stapled OCSP response, no response is stapled if empty
+}
+
+// getTLS parses the tls option. It is either a boolean or an object with sessionTickets (enabled by
+// default) and ocsp (good, revoked or unknown) properties.
+func getTLS(value sobek.Value) *tlsOptions {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if value.ToBoolean() {
+			return &tlsOptions{sessionTickets: true}
+		}
+
+		return nil
+	}
+
+	opts := &tlsOptions{sessionTickets: true}
+
+	if v := obj.Get("sessionTickets"); v != nil && !sobek.IsUndefined(v) {
+		opts.sessionTickets = v.ToBoolean()
+	}
+
+	if v := obj.Get("ocsp"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		opts.ocsp = v.String()
+	}
+
+	return opts
+}
+
+// tlsListener is the TLS configuration of a mock server, it counts the full and resumed handshakes
+// and records the fingerprints of the clients.
+type tlsListener struct {
+	config     *tls.Config
+	mu         sync.Mutex
+	cert       tls.Certificate
+	handshakes int
+	resumed    int
+	clients    clients
+}
+
+func newTLSListener(target string, opts *tlsOptions) (*tlsListener, error) {
+	cert, err := selfSignedCert(target)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := &tlsListener{cert: cert}
+
+	if len(opts.ocsp) != 0 {
+		if err := listener.staple(opts.ocsp); err != nil {
+			return nil, err
+		}
+	}
+
+	listener.config = &tls.Config{ // nolint:exhaustruct
+		MinVersion:             tls.VersionTLS12,
+		GetCertificate:         listener.certificate,
+		NextProtos:             []string{"h2", "http/1.1"},
+		SessionTicketsDisabled: !opts.sessionTickets,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			listener.mu.Lock()
+			defer listener.mu.Unlock()
+
+			listener.handshakes++
+
+			if state.DidResume {
+				listener.resumed++
+			}
+
+			return nil
+		},
+	}
+
+	return listener, nil
+}
+
+// selfSignedCert returns a certificate of the target's host, localhost and the loopback addresses.
+func selfSignedCert(target string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{ // nolint:exhaustruct
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"xk6-mock-server"}}, // nolint:exhaustruct
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	if u, err := url.Parse(target); err == nil && len(u.Hostname()) != 0 {
+		if ip := net.ParseIP(u.Hostname()); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, u.Hostname())
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil // nolint:exhaustruct
+}
+
+func (l *tlsListener) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cert := l.cert
+
+	return &cert, nil
+}
+
+// staple sets the OCSP response stapled to the certificate (good, revoked or unknown status),
+// no response is stapled if status is empty.
+func (l *tlsListener) staple(status string) error {
+	var response []byte
+
+	if len(status) != 0 {
+		var err error
+
+		signer, _ := l.cert.PrivateKey.(crypto.Signer)
+
+		if response, err = ocspStaple(l.cert.Leaf, l.cert.Leaf, signer, status); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	l.cert.OCSPStaple = response
+	l.mu.Unlock()
+
+	return nil
+}
+
+// rotateTicketKeys replaces the session ticket keys, the sessions of the issued tickets can not be resumed.
+func (l *tlsListener) rotateTicketKeys() error {
+	var key [32]byte
+
+	if _, err := rand.Read(key[:]); err != nil {
+		return err
+	}
+
+	l.config.SetSessionTicketKeys([][32]byte{key})
+
+	return nil
+}
+
+func (l *tlsListener) stats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return map[string]interface{}{"handshakes": l.handshakes, "resumed": l.resumed}
+}
+
+// wrap returns the TLS listener, the ClientHello of the connections are recorded.
+func (l *tlsListener) wrap(listener net.Listener) net.Listener {
+	return tls.NewListener(&helloListener{Listener: listener, record: l.clients.add}, l.config)
+}
+
+// tlsObject returns the JavaScript interface of the server's TLS listener.
+func (srv *server) tlsObject() *sobek.Object {
+	obj := srv.mod.runtime().NewObject()
+
+	set := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			srv.mod.throw(err)
+		}
+	}
+
+	set("stats", srv.tls.stats)
+	set("rotateTicketKeys", func() {
+		if err := srv.tls.rotateTicketKeys(); err != nil {
+			srv.mod.throw(err)
+		}
+	})
+	set("clients", srv.tls.clients.export)
+	set("ocsp", func(status string) {
+		if err := srv.tls.staple(status); err != nil {
+			srv.mod.throw(err)
+		}
+	})
+
+	return obj
+}
+
+// trust adds the certificates of the TLS mock servers to the trusted root certificates of the VU's
+// HTTP client, so requests rewritten to them pass the certificate verification.
+func (mod *Module) trust() {
+	state := mod.vu.State()
+	if state == nil || state.TLSConfig == nil || state.TLSConfig.InsecureSkipVerify {
+		return
+	}
+
+	var pool *x509.CertPool
+
+	for _, srv := range mod.servers {
+		if srv.tls == nil || mod.trusted[srv.tls] {
+			continue
+		}
+
+		if pool == nil {
+			pool = trustedPool(state.TLSConfig.RootCAs)
+		}
+
+		srv.tls.mu.Lock()
+		pool.AddCert(srv.tls.cert.Leaf)
+		srv.tls.mu.Unlock()
+
+		mod.trusted[srv.tls] = true
+	}
+
+	if pool != nil {
+		state.TLSConfig.RootCAs = pool
+	}
+}
+
+// transport returns the VU's HTTP transport trusting the certificates of the mock servers, the default
+// transport outside of VU context.
+func (mod *Module) transport() http.RoundTripper {
+	mod.trust()
+
+	if state := mod.vu.State(); state != nil && state.Transport != nil {
+		return state.Transport
+	}
+
+	return http.DefaultTransport
+}
+
+// trustedPool returns a copy of the root certificates, the system's pool if nil.
+func trustedPool(roots *x509.CertPool) *x509.CertPool {
+	if roots != nil {
+		return roots.Clone()
+	}
+
+	if pool, err := x509.SystemCertPool(); err == nil {
+		return pool
+	}
+
+	return x509.NewCertPool()
+}
+
+// https defines a mock server serving over TLS with a self-signed certificate, see the tls option.
+func (mod *Module) https(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() && !isDeferred(call.Arguments) {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+
+	if args.options.tls == nil {
+		args.options.tls = &tlsOptions{sessionTickets: true}
+	}
+
+	return mod.define(args)
+}
+
+// baseURL returns the URL of the mock server.
+func (srv *server) baseURL() string {
+	if srv.tls != nil {
+		return "https://" + srv.addr()
+	}
+
+	return "http://" + srv.addr()
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	t.Parallel()
+
+	cert, err := selfSignedCert("https://api.example.com")
+
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+
+	require.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("api.example.com"))
+	assert.NoError(t, leaf.VerifyHostname("127.0.0.1"))
+}
+
+func TestTLSResumption(t *testing.T) {
+	t.Parallel()
+
+	for _, tickets := range []bool{true, false} {
+		listener, err := newTLSListener("https://example.com", &tlsOptions{sessionTickets: tickets})
+
+		require.NoError(t, err)
+
+		tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+		require.NoError(t, err)
+
+		server := &http.Server{ // nolint:exhaustruct
+			Handler:           http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") }), // nolint:errcheck
+			ReadHeaderTimeout: time.Second,
+		}
+
+		go server.Serve(listener.wrap(tcp)) // nolint:errcheck
+
+		client := &http.Client{Transport: &http.Transport{ // nolint:exhaustruct
+			DisableKeepAlives: true,
+			TLSClientConfig: &tls.Config{ // nolint:exhaustruct,gosec
+				InsecureSkipVerify: true,
+				ClientSessionCache: tls.NewLRUClientSessionCache(4),
+			},
+		}}
+
+		get := func() {
+			res, err := client.Get("https://" + tcp.Addr().String())
+
+			require.NoError(t, err)
+
+			io.Copy(io.Discard, res.Body) // nolint:errcheck,gosec
+			res.Body.Close()              // nolint:errcheck,gosec
+		}
+
+		get()
+		get()
+
+		expected := 0
+		if tickets {
+			expected = 1
+		}
+
+		assert.Equal(t, map[string]interface{}{"handshakes": 2, "resumed": expected}, listener.stats())
+
+		require.NoError(t, listener.rotateTicketKeys())
+
+		get()
+
+		assert.Equal(t, map[string]interface{}{"handshakes": 3, "resumed": expected}, listener.stats())
+
+		require.NoError(t, server.Close())
+	}
+}
+
+func TestTrustedPool(t *testing.T) {
+	t.Parallel()
+
+	listener, err := newTLSListener("https://example.com", &tlsOptions{sessionTickets: true})
+
+	require.NoError(t, err)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	server := &http.Server{ // nolint:exhaustruct
+		Handler:           http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") }), // nolint:errcheck
+		ReadHeaderTimeout: time.Second,
+	}
+
+	go server.Serve(listener.wrap(tcp)) // nolint:errcheck
+
+	defer server.Close() // nolint:errcheck
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}                         // nolint:exhaustruct
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}} // nolint:exhaustruct
+
+	_, err = client.Get("https://" + tcp.Addr().String()) // nolint:noctx,bodyclose
+
+	require.Error(t, err)
+
+	pool := trustedPool(nil)
+
+	pool.AddCert(listener.cert.Leaf)
+
+	config.RootCAs = pool
+
+	res, err := client.Get("https://" + tcp.Addr().String()) // nolint:noctx
+
+	require.NoError(t, err)
+
+	res.Body.Close() // nolint:errcheck,gosec
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
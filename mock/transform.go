@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+// transformer modifies an outgoing response of the mock server.
+type transformer func(res *response, r *http.Request)
+
+// transformers is the response post-processing pipeline of a mock server.
+type transformers struct {
+	mu   sync.RWMutex
+	list []transformer
+}
+
+func newTransformers() *transformers {
+	return new(transformers)
+}
+
+func (ts *transformers) add(fn transformer) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.list = append(ts.list, fn)
+}
+
+func (ts *transformers) empty() bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return len(ts.list) == 0
+}
+
+// apply runs the transformers over the response in the order of their registration.
+func (ts *transformers) apply(res *response, r *http.Request) {
+	ts.mu.RLock()
+	list := ts.list
+	ts.mu.RUnlock()
+
+	for _, fn := range list {
+		fn(res, r)
+	}
+}
+
+// headerTransformer sets the response header given by name and value options.
+func headerTransformer(opts map[string]interface{}) transformer {
+	name, _ := opts["name"].(string)
+	value, _ := opts["value"].(string)
+
+	return func(res *response, _ *http.Request) {
+		if len(name) != 0 {
+			res.header.Set(name, value)
+		}
+	}
+}
+
+// compressTransformer gzip encodes the response body if the client accepts it.
+func compressTransformer(res *response, r *http.Request) { // nolint:varnamelen
+	if res.body.Len() == 0 || len(res.header.Get("Content-Encoding")) != 0 ||
+		!strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		return
+	}
+
+	var buff bytes.Buffer
+
+	writer := gzip.NewWriter(&buff)
+
+	if _, err := writer.Write(res.body.Bytes()); err != nil {
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		return
+	}
+
+	res.body.Reset()
+	res.body.Write(buff.Bytes())
+
+	res.header.Set("Content-Encoding", "gzip")
+	res.header.Add("Vary", "Accept-Encoding")
+}
+
+// linksTransformer rewrites the links of the mock target (and of the origins option) to the mock server's URL.
+// The mapping is resolved on the first response, when the server's address is known.
+func (srv *server) linksTransformer(opts map[string]interface{}) transformer {
+	var (
+		once     sync.Once
+		rewriter *linkRewriter
+	)
+
+	return func(res *response, _ *http.Request) {
+		once.Do(func() {
+			mapping := map[string]string{srv.target: srv.baseURL()}
+
+			if origins, ok := opts["origins"].([]interface{}); ok {
+				for _, origin := range origins {
+					if loc, ok := origin.(string); ok {
+						mapping[loc] = srv.baseURL()
+					}
+				}
+			}
+
+			rewriter = newLinkRewriter(mapping)
+		})
+
+		rewriter.rewriteResponse(res)
+	}
+}
+
+// scriptTransformer calls the JavaScript function with the response object. The function may modify
+// the status, headers and body properties of the object or return a new response object.
+func (srv *server) scriptTransformer(fn sobek.Callable) transformer {
+	return func(res *response, r *http.Request) { // nolint:varnamelen
+		headers := flatHeader(res.header)
+
+		err := srv.onEventLoop(func() error {
+			runtime := srv.mod.runtime()
+
+			obj := runtime.ToValue(map[string]interface{}{
+				"request": map[string]interface{}{"method": r.Method, "path": r.URL.Path},
+				"status":  res.status,
+				"headers": headers,
+				"body":    res.body.String(),
+			}).ToObject(runtime)
+
+			result, err := fn(sobek.Undefined(), obj)
+			if err != nil {
+				return err
+			}
+
+			if ret, isObj := result.(*sobek.Object); isObj {
+				obj = ret
+			}
+
+			applyTransformed(res, headers, obj)
+
+			return nil
+		})
+		if err != nil {
+			srv.mod.logger.WithError(err).WithField("target", srv.target).Warn("mock response transformer failed")
+		}
+	}
+}
+
+// applyTransformed copies the changes of the response object into the response.
+// Headers are only touched if their value was changed, so multi-valued headers are preserved.
+func applyTransformed(res *response, orig map[string]interface{}, obj *sobek.Object) {
+	if status := obj.Get("status"); status != nil && !sobek.IsUndefined(status) {
+		res.status = int(status.ToInteger())
+	}
+
+	if hdr, isObj := obj.Get("headers").(*sobek.Object); isObj {
+		changed := make(map[string]bool)
+
+		for _, name := range hdr.Keys() {
+			value := hdr.Get(name)
+			changed[http.CanonicalHeaderKey(name)] = true
+
+			if sobek.IsNull(value) || sobek.IsUndefined(value) {
+				res.header.Del(name)
+
+				continue
+			}
+
+			if prev, found := orig[http.CanonicalHeaderKey(name)]; !found || prev != value.String() {
+				res.header.Set(name, value.String())
+			}
+		}
+
+		for name := range orig {
+			if !changed[name] && !strings.HasPrefix(name, "X-Mock-") {
+				res.header.Del(name)
+			}
+		}
+	}
+
+	body := obj.Get("body")
+	if body == nil || sobek.IsUndefined(body) || sobek.IsNull(body) {
+		return
+	}
+
+	var data []byte
+
+	if buf, isBuf := body.Export().(sobek.ArrayBuffer); isBuf {
+		data = buf.Bytes()
+	} else {
+		data = []byte(body.String())
+	}
+
+	if !bytes.Equal(data, res.body.Bytes()) {
+		res.body.Reset()
+		res.body.Write(data)
+	}
+}
+
+// wrapTransform adds the transform method to the application. It registers a JavaScript transformer
+// function or a built-in transformer by name (header, links or compress) with its options.
+func (srv *server) wrapTransform() {
+	srv.mustSet("transform", func(kind sobek.Value, options sobek.Value) *sobek.Object {
+		if fn, isFunc := sobek.AssertFunction(kind); isFunc {
+			srv.trans.add(srv.scriptTransformer(fn))
+
+			return srv.app
+		}
+
+		opts := make(map[string]interface{})
+
+		if options != nil && !sobek.IsUndefined(options) && !sobek.IsNull(options) {
+			if exported, ok := options.Export().(map[string]interface{}); ok {
+				opts = exported
+			}
+		}
+
+		switch name := kind.String(); name {
+		case "header":
+			srv.trans.add(headerTransformer(opts))
+		case "links":
+			srv.trans.add(srv.linksTransformer(opts))
+		case "compress":
+			srv.trans.add(compressTransformer)
+		default:
+			srv.mod.throwf("unknown transformer: %s", errInvalidArg, name)
+		}
+
+		return srv.app
+	})
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransformers(t *testing.T) {
+	t.Parallel()
+
+	trans := newTransformers()
+
+	assert.True(t, trans.empty())
+
+	trans.add(headerTransformer(map[string]interface{}{"name": "X-Powered-By", "value": "mock"}))
+	trans.add(compressTransformer)
+
+	assert.False(t, trans.empty())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	res := newResponse()
+	res.body.WriteString("Hello World!")
+
+	trans.apply(res, r)
+
+	assert.Equal(t, "mock", res.header.Get("X-Powered-By"))
+	assert.Equal(t, "gzip", res.header.Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(bytes.NewReader(res.body.Bytes()))
+
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Hello World!", string(body))
+}
+
+func TestCompressTransformer_notAccepted(t *testing.T) {
+	t.Parallel()
+
+	res := newResponse()
+	res.body.WriteString("Hello World!")
+
+	compressTransformer(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Empty(t, res.header.Get("Content-Encoding"))
+	assert.Equal(t, "Hello World!", res.body.String())
+}
+
+func TestTransform(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.transform("header", { name: "X-Powered-By", value: "mock" })
+	app.transform(res => { res.body = res.body.toUpperCase() })
+	app.get("/", (req, res) => res.text("Hello World!"))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	res, err := req.Get(helper.module.lookup["https://example.com"])
+
+	require.NoError(t, err)
+	assert.Equal(t, "mock", res.GetHeader("X-Powered-By"))
+
+	body, err := res.ToString()
+
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO WORLD!", body)
+}
@@ -0,0 +1,309 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+)
+
+const (
+	methodUpgrade = "UPGRADE " // method prefix of the protocol upgrade routes
+	streamChunk   = 32 << 10
+)
+
+// upgradeProtocol returns the requested protocol of a (non WebSocket) upgrade request.
+func upgradeProtocol(r *http.Request) string {
+	if !strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		return ""
+	}
+
+	protocol := strings.TrimSpace(strings.Split(r.Header.Get("Upgrade"), ",")[0])
+	if strings.EqualFold(protocol, "websocket") {
+		return ""
+	}
+
+	return protocol
+}
+
+// bufferedConn is a hijacked connection, reads are served from the buffer of the HTTP server first.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(data []byte) (int, error) {
+	return c.reader.Read(data)
+}
+
+// NetConn returns the underlying connection.
+func (c *bufferedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// streamConn is a raw connection of a tunnel or an upgraded protocol, its events are emitted on the VU's event loop.
+type streamConn struct {
+	srv    *server
+	conn   net.Conn
+	mu     sync.Mutex
+	events map[string][]sobek.Callable
+}
+
+// object returns the JavaScript interface of the connection, it must be called on the event loop.
+func (sc *streamConn) object(props map[string]interface{}) *sobek.Object {
+	runtime := sc.srv.mod.runtime()
+	obj := runtime.NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			common.Throw(runtime, err)
+		}
+	}
+
+	for name, value := range props {
+		mustSet(name, value)
+	}
+
+	mustSet("send", func(data sobek.Value) {
+		if _, err := sc.conn.Write(payloadBytes(data)); err != nil {
+			common.Throw(runtime, err)
+		}
+	})
+
+	mustSet("on", func(event string, fn sobek.Callable) *sobek.Object {
+		sc.mu.Lock()
+		sc.events[event] = append(sc.events[event], fn)
+		sc.mu.Unlock()
+
+		return obj
+	})
+
+	mustSet("close", func() {
+		sc.conn.Close() // nolint:errcheck,gosec
+	})
+
+	return obj
+}
+
+// emit calls the event handlers on the event loop, data handlers receive the data as string and ArrayBuffer.
+func (sc *streamConn) emit(event string, data []byte) {
+	sc.mu.Lock()
+	handlers := sc.events[event]
+	sc.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn := fn
+
+		err := sc.srv.onEventLoop(func() error {
+			runtime := sc.srv.mod.runtime()
+
+			var err error
+
+			if event == "data" {
+				_, err = fn(sobek.Undefined(), runtime.ToValue(string(data)), runtime.ToValue(runtime.NewArrayBuffer(data)))
+			} else {
+				_, err = fn(sobek.Undefined())
+			}
+
+			return err
+		})
+		if err != nil {
+			sc.srv.mod.logger.WithError(err).WithField("target", sc.srv.target).Warn("mock handler failed")
+		}
+	}
+}
+
+// serve reads the data of the client until the connection is closed.
+func (sc *streamConn) serve() {
+	buff := make([]byte, streamChunk)
+
+	for {
+		n, err := sc.conn.Read(buff)
+		if n > 0 {
+			sc.emit("data", append([]byte(nil), buff[:n]...))
+		}
+
+		if err != nil {
+			sc.emit("close", nil)
+
+			return
+		}
+	}
+}
+
+// wrapTunnels adds the connect and upgrade methods to the application. The connect handler is called
+// with the tunnel of each CONNECT request, the upgrade handler is called with the connection of each
+// request upgraded to the given protocol.
+func (srv *server) wrapTunnels() {
+	srv.mustSet("connect", func(handler sobek.Callable) *sobek.Object {
+		srv.router.add(http.MethodConnect, "*", []sobek.Callable{handler})
+
+		return srv.app
+	})
+
+	srv.mustSet("upgrade", func(protocol string, path string, handler sobek.Callable) *sobek.Object {
+		srv.router.add(methodUpgrade+strings.ToLower(protocol), path, []sobek.Callable{handler})
+
+		return srv.app
+	})
+}
+
+// stream returns the tunnel or upgrade route of the given method and path.
+func (srv *server) stream(method, path string) *route {
+	for _, found := range srv.router.candidates(method, path, selector{}) {
+		if found.method == method {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// serveStream hijacks the connection, writes the response header and passes the connection to the
+// handler of the route.
+func (srv *server) serveStream(w http.ResponseWriter, endpoint *route, head string, props map[string]interface{}) {
+	conn, buff, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	defer conn.Close() // nolint:errcheck
+
+	if _, err := conn.Write([]byte(head + "\r\n")); err != nil {
+		return
+	}
+
+	stream := &streamConn{
+		srv:    srv,
+		conn:   &bufferedConn{Conn: conn, reader: buff.Reader},
+		events: make(map[string][]sobek.Callable),
+	}
+
+	err = srv.onEventLoop(func() error {
+		_, err := endpoint.handlers[0](sobek.Undefined(), stream.object(props))
+
+		return err
+	})
+	if err != nil {
+		srv.mod.logger.WithError(err).WithField("target", srv.target).Warn("mock handler failed")
+
+		return
+	}
+
+	stream.serve()
+}
+
+// serveConnect establishes the tunnel of a CONNECT request. Tunnels are passed to the connect handler,
+// without handler the mock server itself is served through the tunnel (plain HTTP).
+func (srv *server) serveConnect(w http.ResponseWriter, r *http.Request) {
+	head := "HTTP/1.1 200 Connection Established\r\n"
+
+	if endpoint := srv.stream(http.MethodConnect, "/"); endpoint != nil {
+		srv.serveStream(w, endpoint, head, map[string]interface{}{
+			"authority": r.Host,
+			"headers":   flatHeader(r.Header),
+		})
+
+		return
+	}
+
+	conn, buff, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if _, err := conn.Write([]byte(head + "\r\n")); err != nil {
+		conn.Close() // nolint:errcheck,gosec
+
+		return
+	}
+
+	tunnel := &http.Server{Handler: srv, ReadHeaderTimeout: readHeaderTimeout, ConnContext: connContext} // nolint:exhaustruct
+
+	tunnel.Serve(newConnListener(&bufferedConn{Conn: conn, reader: buff.Reader})) // nolint:errcheck,gosec
+}
+
+// serveUpgrade switches the connection to the requested protocol if an upgrade route matches the request.
+// It reports whether the request was served.
+func (srv *server) serveUpgrade(w http.ResponseWriter, r *http.Request, protocol string) bool {
+	endpoint := srv.stream(methodUpgrade+strings.ToLower(protocol), r.URL.Path)
+	if endpoint == nil {
+		return false
+	}
+
+	params, _ := endpoint.match(r.URL.Path)
+	head := "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: " + protocol + "\r\n"
+
+	srv.serveStream(w, endpoint, head, map[string]interface{}{
+		"protocol": protocol,
+		"path":     r.URL.Path,
+		"params":   params,
+		"headers":  flatHeader(r.Header),
+	})
+
+	return true
+}
+
+// connListener is a listener of a single, already accepted connection. It is closed when the connection is closed.
+type connListener struct {
+	conn net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	listener := &connListener{done: make(chan struct{})}
+	listener.conn = &notifyConn{Conn: conn, close: func() { listener.once.Do(func() { close(listener.done) }) }}
+
+	return listener
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	if conn := l.conn; conn != nil {
+		l.conn = nil
+
+		return conn, nil
+	}
+
+	<-l.done
+
+	return nil, io.EOF
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+// notifyConn calls close when the connection is closed.
+type notifyConn struct {
+	net.Conn
+	close func()
+}
+
+func (c *notifyConn) Close() error {
+	defer c.close()
+
+	return c.Conn.Close()
+}
+
+// NetConn returns the underlying connection.
+func (c *notifyConn) NetConn() net.Conn {
+	return c.Conn
+}
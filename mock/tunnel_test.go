@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnListener(t *testing.T) {
+	t.Parallel()
+
+	client, server := net.Pipe()
+
+	done := make(chan error)
+
+	go func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, r.URL.Path) }) // nolint:errcheck
+
+		done <- (&http.Server{Handler: handler, ReadHeaderTimeout: time.Second}).Serve(newConnListener(server)) // nolint:exhaustruct
+	}()
+
+	_, err := io.WriteString(client, "GET /tunneled HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+	require.NoError(t, err)
+
+	res, err := http.ReadResponse(bufio.NewReader(client), nil)
+
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, res.ContentLength))
+
+	require.NoError(t, err)
+	assert.Equal(t, "/tunneled", string(body))
+
+	require.NoError(t, client.Close())
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, io.EOF)
+	case <-time.After(time.Second):
+		assert.Fail(t, "server not stopped after the connection was closed")
+	}
+}
+
+func TestTunnels(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.upgrade("echo/1", "/streams/:id", conn => conn.on("data", data => conn.send(conn.params.id + ":" + data)))
+	app.connect(tunnel => tunnel.send("tunnel to " + tunnel.authority))
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	addr := strings.TrimPrefix(helper.module.lookup["https://example.com"], "http://")
+
+	exchange := func(request string) *bufio.Reader {
+		conn, err := net.Dial("tcp", addr)
+
+		require.NoError(t, err)
+
+		t.Cleanup(func() { conn.Close() }) // nolint:errcheck,gosec
+
+		require.NoError(t, conn.SetDeadline(time.Now().Add(time.Second)))
+
+		_, err = io.WriteString(conn, request)
+
+		require.NoError(t, err)
+
+		return bufio.NewReader(conn)
+	}
+
+	reader := exchange("GET /streams/42 HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo/1\r\n\r\nhello")
+
+	res, err := http.ReadResponse(reader, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+	line, err := reader.ReadString('o')
+
+	require.NoError(t, err)
+	assert.Equal(t, "42:hello", line)
+
+	reader = exchange("CONNECT db.example.com:5432 HTTP/1.1\r\nHost: db.example.com:5432\r\n\r\n")
+
+	res, err = http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	line, err = reader.ReadString('2')
+
+	require.NoError(t, err)
+	assert.Equal(t, "tunnel to db.example.com:5432", line)
+}
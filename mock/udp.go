@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"sync"
+
+	"github.com/grafana/sobek"
+)
+
+const udpMaxDatagram = 64 << 10
+
+type udpReply struct {
+	pattern *regexp.Regexp
+	data    []byte
+}
+
+type udpDatagram struct {
+	from string
+	data []byte
+}
+
+func (d *udpDatagram) object(runtime *sobek.Runtime) *sobek.Object {
+	obj := runtime.NewObject()
+
+	obj.Set("from", d.from)                           // nolint:errcheck,gosec
+	obj.Set("data", string(d.data))                   // nolint:errcheck,gosec
+	obj.Set("buffer", runtime.NewArrayBuffer(d.data)) // nolint:errcheck,gosec
+	obj.Set("size", len(d.data))                      // nolint:errcheck,gosec
+
+	return obj
+}
+
+// udpServer is a UDP responder mock. Datagrams matching a scripted reply's pattern are answered
+// with the reply, the others are passed to the handlers.
+type udpServer struct {
+	*endpoint
+	mu       sync.Mutex
+	replies  []*udpReply
+	handlers []sobek.Callable
+	received []*udpDatagram
+}
+
+func (mod *Module) newUDPServer(args *mockArgs) *udpServer {
+	server := &udpServer{endpoint: mod.newEndpoint(args, "udp")}
+
+	server.mustSet("handle", func(fn sobek.Callable) *sobek.Object {
+		server.mu.Lock()
+		server.handlers = append(server.handlers, fn)
+		server.mu.Unlock()
+
+		return server.obj
+	})
+
+	server.mustSet("reply", func(pattern sobek.Value, data sobek.Value) *sobek.Object {
+		re, err := jsRegexp(pattern)
+		if err != nil {
+			mod.throw(err)
+		}
+
+		server.mu.Lock()
+		server.replies = append(server.replies, &udpReply{pattern: re, data: payloadBytes(data)})
+		server.mu.Unlock()
+
+		return server.obj
+	})
+
+	server.mustSet("send", func(addr string, data sobek.Value) {
+		if server.packet == nil {
+			mod.throwf("the server is not started yet", errInvalidArg)
+		}
+
+		to, err := net.ResolveUDPAddr("udp", addr)
+		if err == nil {
+			_, err = server.packet.WriteTo(payloadBytes(data), to)
+		}
+
+		if err != nil {
+			mod.throw(err)
+		}
+	})
+
+	server.mustSet("received", func() []interface{} {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		found := make([]interface{}, 0, len(server.received))
+
+		for _, dgram := range server.received {
+			found = append(found, map[string]interface{}{"from": dgram.from, "data": string(dgram.data)})
+		}
+
+		return found
+	})
+
+	return server
+}
+
+// respond returns the replies of the datagram: the scripted reply of the first matching pattern,
+// or the values returned by the handlers.
+func (server *udpServer) respond(dgram *udpDatagram) [][]byte {
+	server.mu.Lock()
+
+	if len(server.received) >= journalLimit {
+		server.received = server.received[1:]
+	}
+
+	server.received = append(server.received, dgram)
+	replies, handlers := server.replies, server.handlers
+
+	server.mu.Unlock()
+
+	for _, reply := range replies {
+		if reply.pattern.Match(dgram.data) {
+			return [][]byte{reply.data}
+		}
+	}
+
+	var out [][]byte
+
+	for _, fn := range handlers {
+		err := server.call(func() error {
+			runtime := server.mod.runtime()
+
+			result, err := fn(sobek.Undefined(), dgram.object(runtime))
+			if err != nil || result == nil || sobek.IsUndefined(result) || sobek.IsNull(result) {
+				return err
+			}
+
+			if _, isArray := result.Export().([]interface{}); !isArray {
+				out = append(out, payloadBytes(result))
+
+				return nil
+			}
+
+			obj := result.ToObject(runtime)
+
+			for _, key := range obj.Keys() {
+				out = append(out, payloadBytes(obj.Get(key)))
+			}
+
+			return nil
+		})
+		if err != nil {
+			server.mod.logger.WithError(err).WithField("target", server.target).Warn("mock handler failed")
+		}
+	}
+
+	return out
+}
+
+// serveDatagrams serves the datagrams until the endpoint is closed. Datagrams are served in order of arrival.
+func (server *udpServer) serveDatagrams(packet net.PacketConn) {
+	buff := make([]byte, udpMaxDatagram)
+
+	for {
+		n, from, err := packet.ReadFrom(buff)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				server.mod.logger.WithError(err).WithField("target", server.target).Error("mock endpoint stopped")
+			}
+
+			return
+		}
+
+		dgram := &udpDatagram{from: from.String(), data: append([]byte(nil), buff[:n]...)}
+
+		for _, data := range server.respond(dgram) {
+			if _, err := packet.WriteTo(data, from); err != nil {
+				server.mod.logger.WithError(err).WithField("target", server.target).Warn("mock reply failed")
+			}
+		}
+	}
+}
+
+// udp starts a UDP responder mock of the target (e.g. udp://dns.example.com:53).
+func (mod *Module) udp(call sobek.FunctionCall) sobek.Value {
+	if mod.skipMock() {
+		return sobek.Undefined()
+	}
+
+	args := mod.newMockArgs(call)
+	server := mod.newUDPServer(args)
+
+	return mod.startPacketEndpoint(args, server.endpoint, server.serveDatagrams)
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUDPReply(t *testing.T) {
+	t.Parallel()
+
+	server := &udpServer{replies: []*udpReply{{pattern: regexp.MustCompile(`^ping`), data: []byte("pong")}}}
+
+	assert.Equal(t, [][]byte{[]byte("pong")}, server.respond(&udpDatagram{data: []byte("ping 1")}))
+	assert.Empty(t, server.respond(&udpDatagram{data: []byte("status")}))
+	assert.Len(t, server.received, 2)
+}
+
+func TestUDPServer(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+const server = mock.udp("udp://statsd.example.com:8125", server => {
+	server.reply("ping", "pong")
+	server.handle(dgram => dgram.data.toUpperCase())
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	conn, err := net.Dial("udp", strings.TrimPrefix(helper.module.lookup["udp://statsd.example.com:8125"], "udp://"))
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	buff := make([]byte, 64)
+
+	for data, expected := range map[string]string{"ping": "pong", "hits:1|c": "HITS:1|C"} {
+		_, err = conn.Write([]byte(data))
+
+		require.NoError(t, err)
+
+		n, err := conn.Read(buff)
+
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(buff[:n]))
+	}
+}
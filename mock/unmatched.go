@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	policyPassthrough = "passthrough"
+	policyBlock       = "block"
+	policyRespond     = "respond"
+
+	unmatchedTarget = "http://unmatched.mock"
+)
+
+var errNotMocked = errors.New("request to URL not mocked")
+
+// unmatchedPolicy is the handling of the requests to URLs not mocked: passed through to the network,
+// blocked with error, or answered by a catch-all mock server.
+type unmatchedPolicy struct {
+	mode    string
+	handler sobek.Value // handler of the catch-all server, nil for the default 501 Not Implemented
+	srv     *server     // the catch-all server, started by the first request
+}
+
+// getUnmatchedPolicy parses the unmatched policy: "passthrough", "block", "respond" or a route handler
+// (respond with the handler). Nil is returned for passthrough.
+func getUnmatchedPolicy(value sobek.Value) (*unmatchedPolicy, error) {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil, nil // nolint:nilnil
+	}
+
+	if _, isFunc := sobek.AssertFunction(value); isFunc {
+		return &unmatchedPolicy{mode: policyRespond, handler: value}, nil
+	}
+
+	switch mode := value.String(); mode {
+	case policyPassthrough:
+		return nil, nil // nolint:nilnil
+	case policyBlock, policyRespond:
+		return &unmatchedPolicy{mode: mode}, nil
+	default:
+		return nil, fmt.Errorf("%w: unmatched policy must be passthrough, block, respond or a handler", errInvalidArg)
+	}
+}
+
+// unmatched sets the handling of the requests to URLs not mocked.
+func (mod *Module) unmatched(policy sobek.Value) {
+	p, err := getUnmatchedPolicy(policy)
+	if err != nil {
+		mod.throw(err)
+	}
+
+	mod.policy = p
+}
+
+// unmockedURL applies the unmatched policy on the location not mocked. Blocked requests throw, answered
+// requests are rewritten to the catch-all server.
+func (mod *Module) unmockedURL(loc string) (string, bool) {
+	if mod.policy == nil || !(strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://")) {
+		return loc, false
+	}
+
+	if mod.policy.mode == policyBlock {
+		mod.throwf("%s", errNotMocked, loc)
+	}
+
+	parsed, err := url.Parse(loc)
+	if err != nil {
+		return loc, false
+	}
+
+	if mod.policy.srv == nil {
+		mod.policy.srv = mod.newMockServer(&mockArgs{
+			target:   unmatchedTarget,
+			callback: mod.catchAll(mod.policy.handler),
+			options:  new(options),
+		})
+	}
+
+	if mod.policy.srv == nil {
+		return loc, false
+	}
+
+	return mod.policy.srv.baseURL() + parsed.RequestURI(), true
+}
+
+// catchAll returns the definition of the catch-all server: the handler (501 Not Implemented by default)
+// answers every request.
+func (mod *Module) catchAll(handler sobek.Value) sobek.Callable {
+	runtime := mod.runtime()
+
+	if handler == nil {
+		handler = runtime.ToValue(func(call sobek.FunctionCall) sobek.Value {
+			mod.respond(call.Argument(1).ToObject(runtime), &stubResponse{
+				Status:  http.StatusNotImplemented,
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    `{"error":"not mocked"}`,
+			})
+
+			return sobek.Undefined()
+		})
+	}
+
+	return func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+		app := args[0].ToObject(runtime)
+
+		use, ok := sobek.AssertFunction(app.Get("use"))
+		if !ok {
+			return nil, fmt.Errorf("%w: missing use method", errInvalidArg)
+		}
+
+		return use(app, handler)
+	}
+}
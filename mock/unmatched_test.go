@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUnmatchedPolicy(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+	runtime := helper.vu.Runtime()
+
+	for _, value := range []sobek.Value{nil, sobek.Undefined(), runtime.ToValue("passthrough")} {
+		policy, err := getUnmatchedPolicy(value)
+
+		assert.NoError(t, err)
+		assert.Nil(t, policy)
+	}
+
+	policy, err := getUnmatchedPolicy(runtime.ToValue("block"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, policyBlock, policy.mode)
+
+	handler := runtime.ToValue(func(sobek.FunctionCall) sobek.Value { return sobek.Undefined() })
+
+	policy, err = getUnmatchedPolicy(handler)
+
+	assert.NoError(t, err)
+	assert.Equal(t, policyRespond, policy.mode)
+	assert.Equal(t, handler, policy.handler)
+
+	_, err = getUnmatchedPolicy(runtime.ToValue("ignore"))
+
+	assert.ErrorIs(t, err, errInvalidArg)
+}
+
+func TestUnmatchedBlock(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	helper.module.lookup["https://example.com"] = "https://example.net"
+
+	loc, ok := helper.module.rewriteURL("https://production.com/users")
+
+	assert.False(t, ok)
+	assert.Equal(t, "https://production.com/users", loc)
+
+	helper.module.unmatched(helper.vu.Runtime().ToValue("block"))
+
+	assert.Panics(t, func() { helper.module.rewriteURL("https://production.com/users") })
+	assert.NotPanics(t, func() { helper.module.rewriteURL("https://example.com/users") })
+	assert.NotPanics(t, func() { helper.module.rewriteURL("http://localhost:8080/users") })
+}
+
+func TestUnmatchedBlockTLS(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+mock("https://example.com", app => {
+	app.get("/", (req, res) => res.text("Hello World!"))
+}, { sync: true, tls: true })
+`)
+
+	require.NoError(t, err)
+
+	base := helper.module.lookup["https://example.com"]
+
+	require.True(t, strings.HasPrefix(base, "https://127.0.0.1:"))
+
+	helper.module.unmatched(helper.vu.Runtime().ToValue("block"))
+
+	for _, loc := range []string{base, base + "/", base + "/users?id=1"} {
+		rewritten, ok := "", true
+
+		assert.NotPanics(t, func() { rewritten, ok = helper.module.rewriteURL(loc) })
+		assert.False(t, ok)
+		assert.Equal(t, loc, rewritten)
+	}
+
+	assert.Panics(t, func() { helper.module.rewriteURL(base + "0/users") })
+}
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	defaultUsageInterval = time.Second
+	defaultCPULimit      = 0.9
+)
+
+// sizeUnits are the multipliers of the size units to bytes.
+var sizeUnits = map[string]float64{"": 1, "b": 1, "kb": 1e3, "mb": 1e6, "gb": 1e9} // nolint:gochecknoglobals
+
+// overloadGuard defines the resource limits of the mock server (zero means no limit). Exceeding any
+// of them means the mock itself may be the bottleneck of the test.
+type overloadGuard struct {
+	interval   time.Duration
+	cpu        float64 // fraction of the available CPU time
+	heap       uint64  // bytes
+	goroutines uint64
+	inflight   int64
+}
+
+// getOverloadGuard parses the overload option: the guard is opt-in, so missing or false option means
+// no resource sampling (each guarded server runs its own sampler), true means the default limits. The
+// limits are given by cpu (0..1), heap (bytes or size string), goroutines and inflight (requests)
+// properties. The CPU limit is 0.9 by default.
+func getOverloadGuard(value sobek.Value) *overloadGuard {
+	guard := &overloadGuard{interval: defaultUsageInterval, cpu: defaultCPULimit}
+
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	obj, isObj := value.(*sobek.Object)
+	if !isObj {
+		if !value.ToBoolean() {
+			return nil
+		}
+
+		return guard
+	}
+
+	spec, _ := obj.Export().(map[string]interface{})
+
+	if d := duration(spec["interval"]); d > 0 {
+		guard.interval = d
+	}
+
+	if cpu, ok := toFloat(spec["cpu"]); ok {
+		guard.cpu = cpu
+	}
+
+	guard.heap = getSize(spec["heap"])
+
+	if n, ok := toFloat(spec["goroutines"]); ok && n > 0 {
+		guard.goroutines = uint64(n)
+	}
+
+	if n, ok := toFloat(spec["inflight"]); ok && n > 0 {
+		guard.inflight = int64(n)
+	}
+
+	return guard
+}
+
+// getSize parses size in bytes: number or string with unit (B, kB, MB, GB), zero if invalid.
+func getSize(value interface{}) uint64 {
+	str, isStr := value.(string)
+	if !isStr {
+		if n, ok := toFloat(value); ok && n > 0 {
+			return uint64(n)
+		}
+
+		return 0
+	}
+
+	match := bandwidthPattern.FindStringSubmatch(str)
+	if match == nil {
+		return 0
+	}
+
+	num, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0
+	}
+
+	multiplier, found := sizeUnits[strings.ToLower(match[2])]
+	if !found {
+		return 0
+	}
+
+	return uint64(num * multiplier)
+}
+
+// exceeded returns the names of the exceeded limits.
+func (guard *overloadGuard) exceeded(usage *resourceUsage) []string {
+	var names []string
+
+	if guard.cpu > 0 && usage.cpu > guard.cpu {
+		names = append(names, "cpu")
+	}
+
+	if guard.heap > 0 && usage.heap > guard.heap {
+		names = append(names, "heap")
+	}
+
+	if guard.goroutines > 0 && usage.goroutines > guard.goroutines {
+		names = append(names, "goroutines")
+	}
+
+	if guard.inflight > 0 && usage.inflight > guard.inflight {
+		names = append(names, "inflight")
+	}
+
+	return names
+}
+
+// resourceUsage is a sample of the resource usage. The mock runs in the k6 process, CPU, heap and
+// goroutines are measured for the whole process.
+type resourceUsage struct {
+	cpu        float64 // fraction of the available CPU time since the previous sample
+	heap       uint64
+	goroutines uint64
+	inflight   int64
+}
+
+// usageMonitor samples the resource usage of the mock server periodically.
+type usageMonitor struct {
+	guard      *overloadGuard
+	inflight   int64 // in-flight requests, updated atomically
+	overloaded bool
+	lastCPU    time.Duration
+	lastTime   time.Time
+	done       chan struct{}
+	once       sync.Once
+}
+
+func newUsageMonitor(guard *overloadGuard) *usageMonitor {
+	return &usageMonitor{guard: guard, done: make(chan struct{})}
+}
+
+// start samples the resource usage in the guard's interval until the monitor is stopped.
+func (m *usageMonitor) start(observe func(*resourceUsage)) {
+	m.lastCPU, _ = processCPU()
+	m.lastTime = time.Now()
+
+	go func() {
+		ticker := time.NewTicker(m.guard.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.done:
+				return
+			case now := <-ticker.C:
+				observe(m.sample(now))
+			}
+		}
+	}()
+}
+
+func (m *usageMonitor) stop() {
+	m.once.Do(func() { close(m.done) })
+}
+
+// sample returns the current resource usage.
+func (m *usageMonitor) sample(now time.Time) *resourceUsage {
+	usage := &resourceUsage{inflight: atomic.LoadInt64(&m.inflight)}
+
+	if cpu, ok := processCPU(); ok {
+		if wall := now.Sub(m.lastTime) * time.Duration(runtime.GOMAXPROCS(0)); wall > 0 {
+			usage.cpu = float64(cpu-m.lastCPU) / float64(wall)
+		}
+
+		m.lastCPU = cpu
+	}
+
+	m.lastTime = now
+
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/sched/goroutines:goroutines"},
+	}
+
+	metrics.Read(samples)
+
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		usage.heap = samples[0].Value.Uint64()
+	}
+
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		usage.goroutines = samples[1].Value.Uint64()
+	}
+
+	return usage
+}
+
+// observeUsage emits the resource usage metrics of the mock server. Overload is logged when it
+// begins and ends, the mock_overload rate metric enables thresholds on it.
+func (srv *server) observeUsage(usage *resourceUsage) {
+	tags := map[string]string{"mock": srv.target}
+
+	srv.mod.push(srv.mod.metrics.cpu, usage.cpu, tags)
+	srv.mod.push(srv.mod.metrics.heap, float64(usage.heap), tags)
+	srv.mod.push(srv.mod.metrics.goroutines, float64(usage.goroutines), tags)
+	srv.mod.push(srv.mod.metrics.inflight, float64(usage.inflight), tags)
+
+	exceeded := srv.usage.guard.exceeded(usage)
+
+	overload := 0.0
+	if len(exceeded) != 0 {
+		overload = 1
+	}
+
+	srv.mod.push(srv.mod.metrics.overload, overload, tags)
+
+	logger := srv.mod.logger.WithField("target", srv.target).
+		WithField("cpu", usage.cpu).
+		WithField("heap", usage.heap).
+		WithField("goroutines", usage.goroutines).
+		WithField("inflight", usage.inflight)
+
+	switch {
+	case len(exceeded) != 0 && !srv.usage.overloaded:
+		logger.WithField("exceeded", strings.Join(exceeded, ",")).
+			Warn("mock server overloaded, test results may be skewed")
+	case len(exceeded) == 0 && srv.usage.overloaded:
+		logger.Info("mock server recovered from overload")
+	}
+
+	srv.usage.overloaded = len(exceeded) != 0
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, uint64(512e6), getSize("512MB"))
+	assert.Equal(t, uint64(1500), getSize("1.5 kB"))
+	assert.Equal(t, uint64(2048), getSize(int64(2048)))
+	assert.Equal(t, uint64(100), getSize("100"))
+	assert.Zero(t, getSize("huge"))
+	assert.Zero(t, getSize("1 TB"))
+	assert.Zero(t, getSize(nil))
+}
+
+func TestGetOverloadGuard(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getOverloadGuard(nil), "the overload guard is opt-in")
+}
+
+func TestOverloadGuardExceeded(t *testing.T) {
+	t.Parallel()
+
+	guard := &overloadGuard{cpu: 0.9, heap: 1000, inflight: 10}
+
+	assert.Empty(t, guard.exceeded(&resourceUsage{cpu: 0.5, heap: 500, goroutines: 1e6, inflight: 10}))
+	assert.Equal(t, []string{"cpu", "heap", "inflight"}, guard.exceeded(&resourceUsage{cpu: 0.95, heap: 2000, inflight: 11}))
+}
+
+func TestUsageMonitor(t *testing.T) {
+	t.Parallel()
+
+	monitor := newUsageMonitor(&overloadGuard{interval: 10 * time.Millisecond})
+
+	atomic.AddInt64(&monitor.inflight, 2)
+
+	samples := make(chan *resourceUsage, 1)
+
+	monitor.start(func(usage *resourceUsage) {
+		select {
+		case samples <- usage:
+		default:
+		}
+	})
+
+	usage := <-samples
+
+	monitor.stop()
+	monitor.stop()
+
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(2), usage.inflight)
+	assert.NotZero(t, usage.heap)
+	assert.NotZero(t, usage.goroutines)
+	assert.GreaterOrEqual(t, usage.cpu, 0.0)
+}
@@ -0,0 +1,434 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grafana/sobek"
+)
+
+// requestSchema contains the JSON Schemas of the request parts of a route. The params and query schemas
+// describe objects, their string values are converted to the types of the properties.
+type requestSchema struct {
+	params map[string]interface{}
+	query  map[string]interface{}
+	body   map[string]interface{}
+}
+
+// getRequestSchema parses the schema route option: params, query and body JSON Schemas.
+func getRequestSchema(value interface{}) (*requestSchema, error) {
+	spec, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, fmt.Errorf("%w: schema must be an object", errInvalidArg)
+	}
+
+	rs := new(requestSchema)
+
+	for name, target := range map[string]*map[string]interface{}{"params": &rs.params, "query": &rs.query, "body": &rs.body} {
+		switch part := spec[name].(type) {
+		case nil:
+		case map[string]interface{}:
+			*target = normalizeJSON(part).(map[string]interface{})
+		default:
+			return nil, fmt.Errorf("%w: %s schema must be an object", errInvalidArg, name)
+		}
+	}
+
+	for _, schema := range []map[string]interface{}{rs.params, rs.query, rs.body} {
+		if err := compileSchemaPatterns(schema); err != nil {
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+// compileSchemaPatterns reports the invalid pattern keywords of the schema.
+func compileSchemaPatterns(schema interface{}) error {
+	switch value := schema.(type) {
+	case map[string]interface{}:
+		if pattern, ok := value["pattern"].(string); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%w: invalid pattern %q: %s", errInvalidSchema, pattern, err)
+			}
+		}
+
+		for _, child := range value {
+			if err := compileSchemaPatterns(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range value {
+			if err := compileSchemaPatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// context returns the typed request context (params, query and body converted and validated by the
+// schemas) and the violations of the schemas.
+func (rs *requestSchema) context(params map[string]string, query map[string][]string, body interface{}) (map[string]interface{}, []string) {
+	var violations []string
+
+	values := make(map[string][]string, len(params))
+
+	for name, value := range params {
+		values[name] = []string{value}
+	}
+
+	ctx := map[string]interface{}{"body": body}
+
+	typed, errs := coerceValues(rs.params, values, "params")
+	ctx["params"] = typed
+	violations = append(violations, errs...)
+
+	typed, errs = coerceValues(rs.query, query, "query")
+	ctx["query"] = typed
+	violations = append(violations, errs...)
+
+	if rs.body != nil {
+		if body == nil {
+			violations = append(violations, "body: missing or not a JSON document")
+		} else {
+			violations = append(violations, checkSchema(rs.body, body, "body")...)
+		}
+	}
+
+	return ctx, violations
+}
+
+// coerceValues converts the string values to the types of the object schema's properties and validates
+// the resulting object. Multiple values are kept for array properties only.
+func coerceValues(schema map[string]interface{}, values map[string][]string, at string) (map[string]interface{}, []string) {
+	props, _ := schema["properties"].(map[string]interface{})
+	typed := make(map[string]interface{}, len(values))
+
+	for name, list := range values {
+		if len(list) == 0 {
+			continue
+		}
+
+		prop, _ := props[name].(map[string]interface{})
+
+		if hasType(prop, "array") {
+			items, _ := prop["items"].(map[string]interface{})
+			all := make([]interface{}, 0, len(list))
+
+			for _, value := range list {
+				all = append(all, coerce(items, value))
+			}
+
+			typed[name] = all
+
+			continue
+		}
+
+		typed[name] = coerce(prop, list[0])
+	}
+
+	if schema == nil {
+		return typed, nil
+	}
+
+	return typed, checkSchema(schema, typed, at)
+}
+
+// coerce converts the string to the type of the schema, it is kept as is if it can't be converted.
+func coerce(schema map[string]interface{}, value string) interface{} {
+	switch {
+	case hasType(schema, "integer"):
+		if num, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return float64(num)
+		}
+	case hasType(schema, "number"):
+		if num, err := strconv.ParseFloat(value, 64); err == nil {
+			return num
+		}
+	case hasType(schema, "boolean"):
+		if flag, err := strconv.ParseBool(value); err == nil {
+			return flag
+		}
+	}
+
+	return value
+}
+
+func hasType(schema map[string]interface{}, name string) bool {
+	switch typ := schema["type"].(type) {
+	case string:
+		return typ == name
+	case []interface{}:
+		for _, item := range typ {
+			if item == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// jsonType returns the JSON Schema type name of the decoded JSON value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// checkSchema returns the violations of the JSON Schema by the decoded JSON value. The type, enum,
+// const, required, properties, additionalProperties, items, minItems, maxItems, minimum, maximum,
+// minLength, maxLength, pattern, anyOf and oneOf keywords and the nullable OpenAPI extension are
+// supported.
+func checkSchema(schema map[string]interface{}, value interface{}, at string) []string { // nolint:cyclop,funlen
+	fail := func(format string, args ...interface{}) []string {
+		return []string{at + ": " + fmt.Sprintf(format, args...)}
+	}
+
+	if _, typed := schema["type"]; typed {
+		actual := jsonType(value)
+		types := schemaTypes(schema)
+
+		if !contains(types, actual) && !(actual == "integer" && contains(types, "number")) {
+			return fail("must be %s", strings.Join(types, " or "))
+		}
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inJSON(enum, value) {
+		return fail("must be one of %s", encodeJSON(enum))
+	}
+
+	if expected, ok := schema["const"]; ok && !inJSON([]interface{}{expected}, value) {
+		return fail("must be %s", encodeJSON(expected))
+	}
+
+	if alts, ok := schema["anyOf"].([]interface{}); ok && countMatching(alts, value) == 0 {
+		return fail("must match any of the schemas")
+	}
+
+	if alts, ok := schema["oneOf"].([]interface{}); ok && countMatching(alts, value) != 1 {
+		return fail("must match exactly one of the schemas")
+	}
+
+	var violations []string
+
+	switch v := value.(type) {
+	case float64:
+		if low, ok := toFloat(schema["minimum"]); ok && v < low {
+			violations = append(violations, fail("must be at least %v", low)...)
+		}
+
+		if high, ok := toFloat(schema["maximum"]); ok && v > high {
+			violations = append(violations, fail("must be at most %v", high)...)
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+
+		if low, ok := toFloat(schema["minLength"]); ok && float64(length) < low {
+			violations = append(violations, fail("must be at least %v characters long", low)...)
+		}
+
+		if high, ok := toFloat(schema["maxLength"]); ok && float64(length) > high {
+			violations = append(violations, fail("must be at most %v characters long", high)...)
+		}
+
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				violations = append(violations, fail("must match pattern %s", pattern)...)
+			}
+		}
+	case []interface{}:
+		if low, ok := toFloat(schema["minItems"]); ok && float64(len(v)) < low {
+			violations = append(violations, fail("must have at least %v items", low)...)
+		}
+
+		if high, ok := toFloat(schema["maxItems"]); ok && float64(len(v)) > high {
+			violations = append(violations, fail("must have at most %v items", high)...)
+		}
+
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for idx, item := range v {
+				violations = append(violations, checkSchema(items, item, fmt.Sprintf("%s[%d]", at, idx))...)
+			}
+		}
+	case map[string]interface{}:
+		violations = append(violations, checkObject(schema, v, at)...)
+	}
+
+	return violations
+}
+
+// checkObject returns the violations of the required, properties and additionalProperties keywords.
+func checkObject(schema map[string]interface{}, obj map[string]interface{}, at string) []string {
+	var violations []string
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, isStr := name.(string); isStr {
+				if _, found := obj[key]; !found {
+					violations = append(violations, at+"."+key+": required")
+				}
+			}
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+
+	for _, key := range sortedKeys(obj) {
+		if prop, ok := props[key].(map[string]interface{}); ok {
+			violations = append(violations, checkSchema(prop, obj[key], at+"."+key)...)
+
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				violations = append(violations, at+"."+key+": unexpected property")
+			}
+		case map[string]interface{}:
+			violations = append(violations, checkSchema(additional, obj[key], at+"."+key)...)
+		}
+	}
+
+	sort.Strings(violations)
+
+	return violations
+}
+
+func countMatching(schemas []interface{}, value interface{}) int {
+	count := 0
+
+	for _, alt := range schemas {
+		if sub, ok := alt.(map[string]interface{}); ok && len(checkSchema(sub, value, "")) == 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+func inJSON(list []interface{}, value interface{}) bool {
+	encoded := encodeJSON(value)
+
+	for _, item := range list {
+		if encodeJSON(item) == encoded {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
+
+// queryValues converts the query object of the request to lists of values.
+func queryValues(value sobek.Value) map[string][]string {
+	values := make(map[string][]string)
+
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return values
+	}
+
+	query, _ := value.Export().(map[string]interface{})
+
+	for name, item := range query {
+		switch v := item.(type) {
+		case []interface{}:
+			for _, elem := range v {
+				values[name] = append(values[name], fmt.Sprint(elem))
+			}
+		case []string:
+			values[name] = v
+		default:
+			values[name] = []string{fmt.Sprint(v)}
+		}
+	}
+
+	return values
+}
+
+// validated adds the typed context to the request object of the route with request schema. If the
+// request violates the schema, it is answered with 400 Bad Request listing the violations, and false
+// is returned.
+func (srv *server) validated(target *route, params map[string]string, req, res *sobek.Object) bool {
+	if target.schema == nil {
+		return true
+	}
+
+	var body interface{}
+
+	if v := req.Get("body"); v != nil && !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		body = normalizeJSON(v.Export())
+	}
+
+	ctx, violations := target.schema.context(params, queryValues(req.Get("query")), body)
+	if len(violations) == 0 {
+		if err := req.Set("context", ctx); err != nil {
+			srv.mod.throw(err)
+		}
+
+		return true
+	}
+
+	runtime := srv.mod.runtime()
+
+	status, _ := sobek.AssertFunction(res.Get("status"))
+	send, _ := sobek.AssertFunction(res.Get("json"))
+
+	if status == nil || send == nil {
+		return false
+	}
+
+	if _, err := status(res, runtime.ToValue(http.StatusBadRequest)); err != nil {
+		srv.mod.throw(err)
+	}
+
+	payload := map[string]interface{}{"error": "invalid request", "violations": violations}
+
+	if _, err := send(res, runtime.ToValue(payload)); err != nil {
+		srv.mod.throw(err)
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestSchemaContext(t *testing.T) {
+	t.Parallel()
+
+	rs, err := getRequestSchema(map[string]interface{}{
+		"params": map[string]interface{}{
+			"properties": map[string]interface{}{"id": map[string]interface{}{"type": "integer", "minimum": int64(1)}},
+		},
+		"query": map[string]interface{}{
+			"required": []interface{}{"page"},
+			"properties": map[string]interface{}{
+				"page":    map[string]interface{}{"type": "integer"},
+				"tag":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"archive": map[string]interface{}{"type": "boolean"},
+			},
+		},
+		"body": map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"type"},
+			"properties": map[string]interface{}{
+				"type": map[string]interface{}{"enum": []interface{}{"gold", "silver"}},
+				"qty":  map[string]interface{}{"type": "number", "maximum": int64(10)},
+			},
+			"additionalProperties": false,
+		},
+	})
+
+	require.NoError(t, err)
+
+	ctx, violations := rs.context(
+		map[string]string{"id": "42"},
+		map[string][]string{"page": {"2"}, "tag": {"a", "b"}, "archive": {"true"}, "other": {"x", "y"}},
+		map[string]interface{}{"type": "gold", "qty": 2.0},
+	)
+
+	assert.Empty(t, violations)
+	assert.Equal(t, map[string]interface{}{"id": 42.0}, ctx["params"])
+	assert.Equal(t, map[string]interface{}{
+		"page": 2.0, "tag": []interface{}{"a", "b"}, "archive": true, "other": "x",
+	}, ctx["query"])
+
+	_, violations = rs.context(
+		map[string]string{"id": "abc"},
+		map[string][]string{},
+		map[string]interface{}{"type": "bronze", "qty": 20.0, "note": "x"},
+	)
+
+	assert.Equal(t, []string{
+		"params.id: must be integer",
+		"query.page: required",
+		"body.note: unexpected property",
+		"body.qty: must be at most 10",
+		"body.type: must be one of [\"gold\",\"silver\"]",
+	}, violations)
+
+	_, violations = rs.context(map[string]string{"id": "0"}, map[string][]string{"page": {"1"}}, nil)
+
+	assert.Equal(t, []string{"params.id: must be at least 1", "body: missing or not a JSON document"}, violations)
+}
+
+func TestCheckSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := map[string]interface{}{
+		"type":      []interface{}{"string", "null"},
+		"pattern":   "^[a-z]+$",
+		"maxLength": 3.0,
+	}
+
+	assert.Empty(t, checkSchema(schema, "abc", "v"))
+	assert.Empty(t, checkSchema(schema, nil, "v"))
+	assert.Equal(t, []string{"v: must be at most 3 characters long"}, checkSchema(schema, "abcd", "v"))
+	assert.Equal(t, []string{"v: must match pattern ^[a-z]+$"}, checkSchema(schema, "ab1", "v"))
+	assert.Equal(t, []string{"v: must be string or null"}, checkSchema(schema, 1.0, "v"))
+
+	list := map[string]interface{}{"type": "array", "minItems": 1.0, "items": map[string]interface{}{"type": "integer"}}
+
+	assert.Equal(t, []string{"v[1]: must be integer"}, checkSchema(list, []interface{}{1.0, 1.5}, "v"))
+	assert.Equal(t, []string{"v: must have at least 1 items"}, checkSchema(list, []interface{}{}, "v"))
+
+	alts := map[string]interface{}{"oneOf": []interface{}{
+		map[string]interface{}{"type": "number"},
+		map[string]interface{}{"type": "integer"},
+	}}
+
+	assert.Empty(t, checkSchema(alts, 1.5, "v"))
+	assert.Equal(t, []string{"v: must match exactly one of the schemas"}, checkSchema(alts, 1.0, "v"))
+
+	_, err := getRequestSchema(map[string]interface{}{"body": map[string]interface{}{"pattern": "("}})
+
+	assert.ErrorIs(t, err, errInvalidSchema)
+}
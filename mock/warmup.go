@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const defaultWarmupRetryAfter = 1
+
+// warmup is the readiness gate of a mock server: until it is ready (app.ready() was called or the delay
+// passed) every request is answered with 503, so client startup retry and health-check logic can be tested.
+type warmup struct {
+	delay      time.Duration // zero means ready only by app.ready()
+	status     int
+	retryAfter int
+
+	mu    sync.Mutex
+	until time.Time
+	ready bool
+}
+
+// getWarmup parses the warmup option: true (ready by app.ready()), the warm-up delay or an object with delay,
+// status and retryAfter (seconds) properties.
+func getWarmup(value sobek.Value) *warmup {
+	if value == nil || sobek.IsUndefined(value) || sobek.IsNull(value) {
+		return nil
+	}
+
+	gate := &warmup{status: http.StatusServiceUnavailable, retryAfter: defaultWarmupRetryAfter}
+
+	switch val := value.Export().(type) {
+	case bool:
+		if !val {
+			return nil
+		}
+	case map[string]interface{}:
+		gate.delay = duration(val["delay"])
+		gate.status = intKeyword(val, "status", gate.status)
+		gate.retryAfter = intKeyword(val, "retryAfter", gate.retryAfter)
+	default:
+		gate.delay = duration(val)
+	}
+
+	return gate
+}
+
+// newWarmup returns the gate of a server with the given warmup option, the delay is measured from now.
+// Without warmup option the gate is open.
+func newWarmup(spec *warmup) *warmup {
+	if spec == nil {
+		return &warmup{status: http.StatusServiceUnavailable, retryAfter: defaultWarmupRetryAfter, ready: true}
+	}
+
+	gate := &warmup{delay: spec.delay, status: spec.status, retryAfter: spec.retryAfter}
+
+	gate.reset(spec.delay)
+
+	return gate
+}
+
+// reset closes the gate, it opens after the delay (or by setReady if the delay is zero).
+func (w *warmup) reset(delay time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ready = false
+	w.until = time.Time{}
+
+	if delay > 0 {
+		w.until = time.Now().Add(delay)
+	}
+}
+
+func (w *warmup) setReady() {
+	w.mu.Lock()
+	w.ready = true
+	w.mu.Unlock()
+}
+
+func (w *warmup) isReady() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.ready && !w.until.IsZero() && !time.Now().Before(w.until) {
+		w.ready = true
+	}
+
+	return w.ready
+}
+
+// reject responds to the request arriving during warm-up.
+func (w *warmup) reject(res http.ResponseWriter) {
+	if w.retryAfter > 0 {
+		res.Header().Set("Retry-After", strconv.Itoa(w.retryAfter))
+	}
+
+	http.Error(res, "mock server is warming up", w.status)
+}
+
+// wrapWarmup adds the readiness methods to the application object.
+func (srv *server) wrapWarmup() {
+	srv.mustSet("ready", srv.warmup.setReady)
+
+	srv.mustSet("unready", func(delay sobek.Value) {
+		var d time.Duration
+
+		if delay != nil && !sobek.IsUndefined(delay) && !sobek.IsNull(delay) {
+			d = duration(delay.Export())
+		}
+
+		srv.warmup.reset(d)
+	})
+
+	srv.mustSet("isReady", srv.warmup.isReady)
+}
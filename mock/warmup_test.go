@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, newWarmup(nil).isReady())
+
+	gate := newWarmup(&warmup{status: http.StatusServiceUnavailable, retryAfter: 5})
+
+	assert.False(t, gate.isReady())
+
+	w := httptest.NewRecorder()
+
+	gate.reject(w)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+
+	gate.setReady()
+
+	assert.True(t, gate.isReady())
+
+	gate.reset(20 * time.Millisecond)
+
+	assert.False(t, gate.isReady())
+	assert.Eventually(t, gate.isReady, time.Second, 5*time.Millisecond)
+
+	delayed := newWarmup(&warmup{delay: 20 * time.Millisecond, status: http.StatusBadGateway})
+
+	assert.False(t, delayed.isReady())
+	assert.Eventually(t, delayed.isReady, time.Second, 5*time.Millisecond)
+
+	w = httptest.NewRecorder()
+
+	delayed.reject(w)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,355 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	defaultWebhookAttempts = 3
+	defaultWebhookBackoff  = time.Second
+	defaultWebhookFactor   = 2
+	defaultWebhookTimeout  = 10 * time.Second
+)
+
+// webhookPolicy is the delivery policy of an outbound webhook, modeling real webhook providers:
+// failed deliveries are retried with exponential backoff until the success criteria are met.
+type webhookPolicy struct {
+	method      string
+	header      http.Header
+	maxAttempts int
+	backoff     time.Duration // delay before the first retry
+	factor      float64
+	maxBackoff  time.Duration // zero means unlimited
+	timeout     time.Duration // of one attempt
+	statuses    []int         // accepted status codes, any 2xx if empty
+	body        string        // the response body must contain it
+}
+
+// getWebhookPolicy parses the webhook options: method, headers, maxAttempts, timeout, backoff (a fixed
+// delay or an object with initial, factor and max properties) and success ({ status, body }) criteria.
+func getWebhookPolicy(opts map[string]interface{}) *webhookPolicy {
+	policy := &webhookPolicy{
+		method:      http.MethodPost,
+		header:      make(http.Header),
+		maxAttempts: intKeyword(opts, "maxAttempts", defaultWebhookAttempts),
+		backoff:     defaultWebhookBackoff,
+		factor:      defaultWebhookFactor,
+		timeout:     defaultWebhookTimeout,
+	}
+
+	if method, ok := opts["method"].(string); ok {
+		policy.method = strings.ToUpper(method)
+	}
+
+	if headers, ok := opts["headers"].(map[string]interface{}); ok {
+		for name, value := range headers {
+			policy.header.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	if value, found := opts["timeout"]; found {
+		policy.timeout = duration(value)
+	}
+
+	switch backoff := opts["backoff"].(type) {
+	case nil:
+	case map[string]interface{}:
+		if value, found := backoff["initial"]; found {
+			policy.backoff = duration(value)
+		}
+
+		if factor, ok := toFloat(backoff["factor"]); ok {
+			policy.factor = factor
+		}
+
+		policy.maxBackoff = duration(backoff["max"])
+	default:
+		policy.backoff, policy.factor = duration(backoff), 1
+	}
+
+	if success, ok := opts["success"].(map[string]interface{}); ok {
+		switch status := success["status"].(type) {
+		case []interface{}:
+			for _, code := range status {
+				if num, ok := toFloat(code); ok {
+					policy.statuses = append(policy.statuses, int(num))
+				}
+			}
+		default:
+			if num, ok := toFloat(status); ok {
+				policy.statuses = []int{int(num)}
+			}
+		}
+
+		if body, ok := success["body"].(string); ok {
+			policy.body = body
+		}
+	}
+
+	if policy.maxAttempts < 1 {
+		policy.maxAttempts = 1
+	}
+
+	return policy
+}
+
+// delay returns the backoff before the retry following the given (1-based) attempt.
+func (p *webhookPolicy) delay(attempt int) time.Duration {
+	d := time.Duration(float64(p.backoff) * math.Pow(p.factor, float64(attempt-1)))
+
+	if p.maxBackoff > 0 && d > p.maxBackoff {
+		return p.maxBackoff
+	}
+
+	return d
+}
+
+// succeeded reports whether the response acknowledges the delivery.
+func (p *webhookPolicy) succeeded(status int, body []byte) bool {
+	accepted := status >= 200 && status < 300
+
+	if len(p.statuses) != 0 {
+		accepted = false
+
+		for _, code := range p.statuses {
+			accepted = accepted || code == status
+		}
+	}
+
+	return accepted && bytes.Contains(body, []byte(p.body))
+}
+
+type webhookAttempt struct {
+	time     time.Time
+	status   int
+	err      string
+	duration time.Duration
+}
+
+type webhookDelivery struct {
+	url       string
+	delivered bool
+	attempts  []*webhookAttempt
+	duration  time.Duration
+}
+
+func (d *webhookDelivery) export() map[string]interface{} {
+	attempts := make([]interface{}, 0, len(d.attempts))
+
+	for _, attempt := range d.attempts {
+		attempts = append(attempts, map[string]interface{}{
+			"time":     attempt.time.UnixMilli(),
+			"status":   attempt.status,
+			"error":    attempt.err,
+			"duration": float64(attempt.duration) / float64(time.Millisecond),
+		})
+	}
+
+	return map[string]interface{}{
+		"url":       d.url,
+		"delivered": d.delivered,
+		"attempts":  attempts,
+		"duration":  float64(d.duration) / float64(time.Millisecond),
+	}
+}
+
+// deliverWebhook sends the payload to the URL, retrying by the policy. The onAttempt callback is
+// called after each attempt.
+func deliverWebhook(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	payload []byte,
+	policy *webhookPolicy,
+	onAttempt func(*webhookAttempt),
+) *webhookDelivery {
+	delivery := &webhookDelivery{url: url}
+	start := time.Now()
+
+	for n := 1; n <= policy.maxAttempts && !delivery.delivered; n++ {
+		if n > 1 && !waitContext(ctx, policy.delay(n-1)) {
+			break
+		}
+
+		attempt := &webhookAttempt{time: time.Now()}
+
+		status, body, err := sendWebhook(ctx, client, url, payload, policy)
+		if err != nil {
+			attempt.err = err.Error()
+		}
+
+		attempt.status = status
+		attempt.duration = time.Since(attempt.time)
+
+		delivery.attempts = append(delivery.attempts, attempt)
+		delivery.delivered = err == nil && policy.succeeded(status, body)
+
+		if onAttempt != nil {
+			onAttempt(attempt)
+		}
+	}
+
+	delivery.duration = time.Since(start)
+
+	return delivery
+}
+
+// waitContext waits for the given duration, it returns false if the context was canceled meanwhile.
+func waitContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func sendWebhook(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	payload []byte,
+	policy *webhookPolicy,
+) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, policy.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, policy.method, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for name, values := range policy.header {
+		req.Header[name] = values
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer res.Body.Close() // nolint:errcheck
+
+	body, err := io.ReadAll(res.Body)
+
+	return res.StatusCode, body, err
+}
+
+// webhookHost returns the host of the receiver URL, the webhook metrics are tagged with it instead of
+// the URL (paths and query strings would create a time series per delivery).
+func webhookHost(loc string) string {
+	parsed, err := url.Parse(loc)
+	if err != nil || len(parsed.Host) == 0 {
+		return loc
+	}
+
+	return parsed.Host
+}
+
+// wrapWebhooks adds the webhook method to the application object, it returns a promise resolved with
+// the delivery result when the webhook was delivered or the attempts were exhausted. Mocked receivers
+// are rewritten to their mock servers and the deliveries are sent over the VU's transport, so the
+// certificates of the mock servers are trusted.
+func (srv *server) wrapWebhooks() {
+	srv.mustSet("webhook", func(loc string, payload sobek.Value, opts sobek.Value) *sobek.Promise {
+		var spec map[string]interface{}
+
+		if obj, isObj := opts.(*sobek.Object); isObj {
+			spec, _ = obj.Export().(map[string]interface{})
+		}
+
+		policy := getWebhookPolicy(spec)
+
+		var body []byte
+
+		switch val := exportContent(payload).(type) {
+		case nil:
+		case string:
+			body = []byte(val)
+		case sobek.ArrayBuffer:
+			body = val.Bytes()
+		default:
+			data, err := json.Marshal(val)
+			if err != nil {
+				srv.mod.throw(err)
+			}
+
+			body = data
+
+			if len(policy.header.Get("Content-Type")) == 0 {
+				policy.header.Set("Content-Type", "application/json")
+			}
+		}
+
+		promise, resolve, _ := srv.mod.runtime().NewPromise()
+		callback := srv.mod.vu.RegisterCallback()
+
+		target := loc
+		if rewritten, ok := srv.mod.rewriteURL(loc); ok {
+			target = rewritten
+		}
+
+		client := &http.Client{Transport: srv.mod.transport()} // nolint:exhaustruct
+		tags := map[string]string{"mock": srv.target, "webhook": webhookHost(loc)}
+
+		go func() {
+			delivery := deliverWebhook(srv.mod.vu.Context(), client, target, body, policy,
+				func(attempt *webhookAttempt) {
+					status := "error"
+					if len(attempt.err) == 0 {
+						status = strconv.Itoa(attempt.status)
+					}
+
+					srv.mod.push(srv.mod.metrics.webhookAttempts, 1, withTag(tags, "status", status))
+				})
+
+			result := "failed"
+			if delivery.delivered {
+				result = "delivered"
+			}
+
+			srv.mod.push(srv.mod.metrics.webhookDeliveries, 1, withTag(tags, "result", result))
+			srv.mod.push(srv.mod.metrics.webhookDuration, metrics.D(delivery.duration), withTag(tags, "result", result))
+
+			delivery.url = loc
+
+			callback(func() error {
+				return resolve(delivery.export())
+			})
+		}()
+
+		return promise
+	})
+}
+
+func withTag(tags map[string]string, name, value string) map[string]string {
+	out := make(map[string]string, len(tags)+1)
+
+	for k, v := range tags {
+		out[k] = v
+	}
+
+	out[name] = value
+
+	return out
+}
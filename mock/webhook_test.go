@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := getWebhookPolicy(nil)
+
+	assert.Equal(t, http.MethodPost, policy.method)
+	assert.Equal(t, defaultWebhookAttempts, policy.maxAttempts)
+	assert.Equal(t, time.Second, policy.delay(1))
+	assert.Equal(t, 4*time.Second, policy.delay(3))
+	assert.True(t, policy.succeeded(http.StatusNoContent, nil))
+	assert.False(t, policy.succeeded(http.StatusFound, nil))
+
+	policy = getWebhookPolicy(map[string]interface{}{
+		"method":      "put",
+		"maxAttempts": int64(5),
+		"backoff":     map[string]interface{}{"initial": "100ms", "factor": int64(3), "max": "500ms"},
+		"success":     map[string]interface{}{"status": []interface{}{int64(202)}, "body": "ack"},
+	})
+
+	assert.Equal(t, http.MethodPut, policy.method)
+	assert.Equal(t, 5, policy.maxAttempts)
+	assert.Equal(t, 300*time.Millisecond, policy.delay(2))
+	assert.Equal(t, 500*time.Millisecond, policy.delay(3))
+	assert.True(t, policy.succeeded(http.StatusAccepted, []byte(`{"status":"ack"}`)))
+	assert.False(t, policy.succeeded(http.StatusAccepted, []byte(`{}`)))
+	assert.False(t, policy.succeeded(http.StatusOK, []byte(`ack`)))
+
+	policy = getWebhookPolicy(map[string]interface{}{"backoff": int64(50)})
+
+	assert.Equal(t, 50*time.Millisecond, policy.delay(4))
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		assert.Equal(t, `{"event":"paid"}`, string(body))
+		assert.Equal(t, "secret", r.Header.Get("X-Signature"))
+
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.Write([]byte("ok")) // nolint:errcheck
+	}))
+
+	defer srv.Close()
+
+	policy := getWebhookPolicy(map[string]interface{}{
+		"backoff": int64(5),
+		"headers": map[string]interface{}{"x-signature": "secret"},
+	})
+
+	var seen []int
+
+	delivery := deliverWebhook(context.Background(), srv.Client(), srv.URL, []byte(`{"event":"paid"}`), policy,
+		func(attempt *webhookAttempt) { seen = append(seen, attempt.status) })
+
+	assert.True(t, delivery.delivered)
+	assert.Equal(t, []int{503, 503, 200}, seen)
+	assert.Len(t, delivery.export()["attempts"], 3)
+
+	atomic.StoreInt32(&calls, 0)
+
+	policy.maxAttempts = 2
+
+	delivery = deliverWebhook(context.Background(), srv.Client(), srv.URL, []byte(`{"event":"paid"}`), policy, nil)
+
+	assert.False(t, delivery.delivered)
+	assert.Len(t, delivery.attempts, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	delivery = deliverWebhook(ctx, srv.Client(), "http://127.0.0.1:1", nil, policy, nil)
+
+	assert.False(t, delivery.delivered)
+	assert.Len(t, delivery.attempts, 1)
+	assert.NotEmpty(t, delivery.attempts[0].err)
+}
+
+func TestWebhookHost(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "example.com:8443", webhookHost("https://example.com:8443/hooks/42?token=secret"))
+	assert.Equal(t, "not a url", webhookHost("not a url"))
+}
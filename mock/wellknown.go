@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The well-known types of protocol buffers are bundled, so .proto files importing them can be loaded
+// without the protobuf include directory. Their values are converted to and from the canonical JSON
+// mapping: timestamps as RFC 3339 strings, durations as seconds with "s" suffix, wrappers as plain
+// values, Struct, Value and ListValue as JSON values and field masks as comma separated paths.
+
+// wellKnownProtos contains the sources of the well-known types by import path.
+var wellKnownProtos = map[string]string{ // nolint:gochecknoglobals
+	"google/protobuf/any.proto": `syntax = "proto3"; package google.protobuf;
+message Any { string type_url = 1; bytes value = 2; }`,
+	"google/protobuf/duration.proto": `syntax = "proto3"; package google.protobuf;
+message Duration { int64 seconds = 1; int32 nanos = 2; }`,
+	"google/protobuf/empty.proto": `syntax = "proto3"; package google.protobuf;
+message Empty {}`,
+	"google/protobuf/field_mask.proto": `syntax = "proto3"; package google.protobuf;
+message FieldMask { repeated string paths = 1; }`,
+	"google/protobuf/struct.proto": `syntax = "proto3"; package google.protobuf;
+message Struct { map<string, Value> fields = 1; }
+message Value {
+  oneof kind {
+    NullValue null_value = 1;
+    double number_value = 2;
+    string string_value = 3;
+    bool bool_value = 4;
+    Struct struct_value = 5;
+    ListValue list_value = 6;
+  }
+}
+enum NullValue { NULL_VALUE = 0; }
+message ListValue { repeated Value values = 1; }`,
+	"google/protobuf/timestamp.proto": `syntax = "proto3"; package google.protobuf;
+message Timestamp { int64 seconds = 1; int32 nanos = 2; }`,
+	"google/protobuf/wrappers.proto": `syntax = "proto3"; package google.protobuf;
+message DoubleValue { double value = 1; }
+message FloatValue { float value = 1; }
+message Int64Value { int64 value = 1; }
+message UInt64Value { uint64 value = 1; }
+message Int32Value { int32 value = 1; }
+message UInt32Value { uint32 value = 1; }
+message BoolValue { bool value = 1; }
+message StringValue { string value = 1; }
+message BytesValue { bytes value = 1; }`,
+}
+
+const (
+	wellKnownPackage = "google.protobuf."
+	nanosPerSecond   = int64(time.Second)
+)
+
+// isWrapper reports whether the well-known type is a wrapper of a scalar value.
+func isWrapper(name string) bool {
+	switch name {
+	case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value", "Int32Value", "UInt32Value",
+		"BoolValue", "StringValue", "BytesValue":
+		return true
+	}
+
+	return false
+}
+
+// wellKnownValue converts the decoded message to its JSON mapping if it is a well-known type.
+func wellKnownValue(msg *protoMessage, value map[string]interface{}) interface{} {
+	name, found := strings.CutPrefix(msg.fullName, wellKnownPackage)
+	if !found {
+		return value
+	}
+
+	switch {
+	case name == "Timestamp":
+		secs, nanos := wellKnownInt(value["seconds"]), wellKnownInt(value["nanos"])
+
+		return time.Unix(secs, nanos).UTC().Format(time.RFC3339Nano)
+	case name == "Duration":
+		return formatDuration(wellKnownInt(value["seconds"]), wellKnownInt(value["nanos"]))
+	case name == "FieldMask":
+		paths := make([]string, 0)
+
+		list, _ := value["paths"].([]interface{})
+		for _, path := range list {
+			paths = append(paths, fmt.Sprint(path))
+		}
+
+		return strings.Join(paths, ",")
+	case name == "Struct":
+		if fields, ok := value["fields"].(map[string]interface{}); ok {
+			return fields
+		}
+
+		return map[string]interface{}{}
+	case name == "ListValue":
+		if values, ok := value["values"].([]interface{}); ok {
+			return values
+		}
+
+		return []interface{}{}
+	case name == "Value":
+		for _, kind := range []string{"numberValue", "stringValue", "boolValue", "structValue", "listValue"} {
+			if v, ok := value[kind]; ok {
+				return v
+			}
+		}
+
+		return nil
+	case isWrapper(name):
+		if v, ok := value["value"]; ok {
+			return v
+		}
+
+		return zeroValue(msg.byNumber[1].typeName)
+	}
+
+	return value
+}
+
+// wellKnownMessage converts the JSON mapping of a well-known type to the fields of the message,
+// other values are returned as is.
+func wellKnownMessage(msg *protoMessage, value interface{}) interface{} {
+	name, found := strings.CutPrefix(msg.fullName, wellKnownPackage)
+	if !found {
+		return value
+	}
+
+	str, isStr := value.(string)
+
+	switch {
+	case name == "Timestamp" && isStr:
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return value
+		}
+
+		return map[string]interface{}{"seconds": t.Unix(), "nanos": int64(t.Nanosecond())}
+	case name == "Duration" && isStr:
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return value
+		}
+
+		return map[string]interface{}{"seconds": int64(d) / nanosPerSecond, "nanos": int64(d) % nanosPerSecond}
+	case name == "FieldMask" && isStr:
+		paths := make([]interface{}, 0)
+
+		for _, path := range strings.Split(str, ",") {
+			if len(path) != 0 {
+				paths = append(paths, path)
+			}
+		}
+
+		return map[string]interface{}{"paths": paths}
+	case name == "Struct":
+		return map[string]interface{}{"fields": value}
+	case name == "ListValue":
+		return map[string]interface{}{"values": value}
+	case name == "Value":
+		return valueKind(value)
+	case isWrapper(name):
+		return map[string]interface{}{"value": value}
+	}
+
+	return value
+}
+
+// valueKind returns the fields of google.protobuf.Value holding the JSON value.
+func valueKind(value interface{}) map[string]interface{} {
+	switch value.(type) {
+	case nil:
+		return map[string]interface{}{"nullValue": "NULL_VALUE"}
+	case string:
+		return map[string]interface{}{"stringValue": value}
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	case map[string]interface{}:
+		return map[string]interface{}{"structValue": value}
+	case []interface{}:
+		return map[string]interface{}{"listValue": value}
+	}
+
+	return map[string]interface{}{"numberValue": value}
+}
+
+func wellKnownInt(value interface{}) int64 {
+	num, _ := toFloat(value)
+
+	return int64(num)
+}
+
+// formatDuration formats the duration in the JSON mapping of google.protobuf.Duration, e.g. "1.5s".
+func formatDuration(secs, nanos int64) string {
+	sign := ""
+
+	if secs < 0 || nanos < 0 {
+		sign, secs, nanos = "-", -secs, -nanos
+	}
+
+	if nanos == 0 {
+		return fmt.Sprintf("%s%ds", sign, secs)
+	}
+
+	return fmt.Sprintf("%s%d.%s", sign, secs, strings.TrimRight(fmt.Sprintf("%09d", nanos), "0")) + "s"
+}
+
+// zeroValue returns the default value of the scalar type.
+func zeroValue(typeName string) interface{} {
+	switch typeName {
+	case "string", "bytes":
+		return ""
+	case "bool":
+		return false
+	}
+
+	return 0
+}
@@ -0,0 +1,438 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"crypto/sha1" // nolint:gosec
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/js/common"
+)
+
+const (
+	methodWebSocket = "WEBSOCKET" // method of the WebSocket routes
+
+	wsGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxPayload = 16 << 20
+	wsCloseWait  = time.Second
+
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+
+	wsNormalClosure = 1000
+	wsNoStatus      = 1005
+)
+
+var errFrameTooLarge = errors.New("WebSocket frame too large")
+
+// isWebSocket reports whether the request is a WebSocket opening handshake.
+func isWebSocket(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// wsAccept returns the Sec-WebSocket-Accept value of the key.
+func wsAccept(key string) string {
+	hash := sha1.Sum([]byte(key + wsGUID)) // nolint:gosec
+
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// readWSFrame reads a client frame, the payload is unmasked.
+func readWSFrame(reader io.Reader) (bool, byte, []byte, error) {
+	var head [2]byte
+
+	if _, err := io.ReadFull(reader, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin, opcode, masked := head[0]&0x80 != 0, head[0]&0x0f, head[1]&0x80 != 0
+	size := uint64(head[1] & 0x7f)
+
+	switch size {
+	case 126:
+		var ext [2]byte
+
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if size > wsMaxPayload {
+		return false, 0, nil, errFrameTooLarge
+	}
+
+	var mask [4]byte
+
+	if masked {
+		if _, err := io.ReadFull(reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, size)
+
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for idx := range payload {
+			payload[idx] ^= mask[idx%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// appendWSFrame appends an unmasked, final frame.
+func appendWSFrame(data []byte, opcode byte, payload []byte) []byte {
+	data = append(data, 0x80|opcode)
+
+	switch size := len(payload); {
+	case size < 126:
+		data = append(data, byte(size))
+	case size <= 0xffff:
+		data = binary.BigEndian.AppendUint16(append(data, 126), uint16(size))
+	default:
+		data = binary.BigEndian.AppendUint64(append(data, 127), uint64(size))
+	}
+
+	return append(data, payload...)
+}
+
+// wsConn is a WebSocket connection of a mocked endpoint, its events are emitted on the VU's event loop.
+type wsConn struct {
+	srv     *server
+	conn    net.Conn
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	events  map[string][]sobek.Callable
+	closing bool
+}
+
+func (ws *wsConn) write(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	_, err := ws.conn.Write(appendWSFrame(nil, opcode, payload))
+
+	return err
+}
+
+// close starts the closing handshake, the connection is closed when the client responds (or after a timeout).
+func (ws *wsConn) close(code int, reason string) {
+	ws.mu.Lock()
+	closing := ws.closing
+	ws.closing = true
+	ws.mu.Unlock()
+
+	if closing {
+		return
+	}
+
+	payload := append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+
+	ws.write(wsClose, payload)                           // nolint:errcheck,gosec
+	ws.conn.SetReadDeadline(time.Now().Add(wsCloseWait)) // nolint:errcheck,gosec
+}
+
+// object returns the JavaScript interface of the connection, it must be called on the event loop.
+func (ws *wsConn) object(r *http.Request, params map[string]string) *sobek.Object {
+	runtime := ws.srv.mod.runtime()
+	obj := runtime.NewObject()
+
+	mustSet := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			common.Throw(runtime, err)
+		}
+	}
+
+	query := make(map[string]string)
+
+	for name, values := range r.URL.Query() {
+		query[name] = values[0]
+	}
+
+	mustSet("path", r.URL.Path)
+	mustSet("params", params)
+	mustSet("query", query)
+	mustSet("headers", flatHeader(r.Header))
+
+	mustSet("send", func(data sobek.Value) {
+		opcode := byte(wsText)
+		if _, isBuf := data.Export().(sobek.ArrayBuffer); isBuf {
+			opcode = wsBinary
+		}
+
+		if err := ws.write(opcode, payloadBytes(data)); err != nil {
+			common.Throw(runtime, err)
+		}
+	})
+
+	mustSet("on", func(event string, fn sobek.Callable) *sobek.Object {
+		ws.mu.Lock()
+		ws.events[event] = append(ws.events[event], fn)
+		ws.mu.Unlock()
+
+		return obj
+	})
+
+	mustSet("close", func(code sobek.Value, reason sobek.Value) {
+		status := wsNormalClosure
+		if code != nil && !sobek.IsUndefined(code) && !sobek.IsNull(code) {
+			status = int(code.ToInteger())
+		}
+
+		ws.close(status, optionalString(reason))
+	})
+
+	return obj
+}
+
+// emit calls the event handlers on the event loop. Message data is passed as string for text
+// messages and as ArrayBuffer for binary messages.
+func (ws *wsConn) emit(event string, opcode byte, data []byte, code int) {
+	ws.mu.Lock()
+	handlers := ws.events[event]
+	ws.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn := fn
+
+		err := ws.srv.onEventLoop(func() error {
+			runtime := ws.srv.mod.runtime()
+
+			var arg sobek.Value
+
+			switch {
+			case event == "close":
+				arg = runtime.ToValue(code)
+			case opcode == wsBinary:
+				arg = runtime.ToValue(runtime.NewArrayBuffer(data))
+			default:
+				arg = runtime.ToValue(string(data))
+			}
+
+			_, err := fn(sobek.Undefined(), arg)
+
+			return err
+		})
+		if err != nil {
+			ws.srv.mod.logger.WithError(err).WithField("target", ws.srv.target).Warn("mock handler failed")
+		}
+	}
+}
+
+// serve reads the messages of the client until the connection is closed.
+func (ws *wsConn) serve(reader *bufio.Reader) {
+	var (
+		message []byte
+		kind    byte
+	)
+
+	for {
+		fin, opcode, payload, err := readWSFrame(reader)
+		if err != nil {
+			ws.emit("close", 0, nil, wsNoStatus)
+
+			return
+		}
+
+		switch opcode {
+		case wsText, wsBinary:
+			kind, message = opcode, payload
+		case wsContinuation:
+			message = append(message, payload...)
+		case wsPing:
+			ws.write(wsPong, payload) // nolint:errcheck,gosec
+
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			code := wsNoStatus
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+
+			ws.close(code, "")
+			ws.emit("close", 0, nil, code)
+
+			return
+		}
+
+		if fin {
+			ws.emit("message", kind, message, 0)
+			message = nil
+		}
+	}
+}
+
+// wrapWS adds the ws method to the application, it defines a WebSocket endpoint. The handler is called
+// with the connection for each accepted client.
+func (srv *server) wrapWS() {
+	srv.mustSet("ws", func(path string, handler sobek.Callable) *sobek.Object {
+		srv.router.add(methodWebSocket, path, []sobek.Callable{handler})
+
+		return srv.app
+	})
+}
+
+// socket returns the WebSocket route of the request.
+func (srv *server) socket(r *http.Request) *route {
+	for _, found := range srv.router.candidates(methodWebSocket, r.URL.Path, selector{}) {
+		if found.method == methodWebSocket {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// serveWS completes the opening handshake, calls the endpoint's handler and serves the connection.
+func (srv *server) serveWS(w http.ResponseWriter, r *http.Request, endpoint *route) { // nolint:varnamelen
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if len(key) == 0 || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusBadRequest)
+
+		return
+	}
+
+	conn, buff, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	defer conn.Close() // nolint:errcheck
+
+	handshake := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n"
+
+	if protocols := r.Header.Get("Sec-WebSocket-Protocol"); len(protocols) != 0 {
+		handshake += "Sec-WebSocket-Protocol: " + strings.TrimSpace(strings.Split(protocols, ",")[0]) + "\r\n"
+	}
+
+	if _, err := conn.Write([]byte(handshake + "\r\n")); err != nil {
+		return
+	}
+
+	ws := &wsConn{srv: srv, conn: conn, events: make(map[string][]sobek.Callable)}
+	params, _ := endpoint.match(r.URL.Path)
+
+	err = srv.onEventLoop(func() error {
+		_, err := endpoint.handlers[0](sobek.Undefined(), ws.object(r, params))
+
+		return err
+	})
+	if err != nil {
+		srv.mod.logger.WithError(err).WithField("target", srv.target).Warn("mock handler failed")
+
+		return
+	}
+
+	ws.serve(buff.Reader)
+}
+
+// rewriteWS returns the mock server's URL of the given WebSocket URL, ok is false if it is not mocked.
+func (mod *Module) rewriteWS(loc string) (string, bool) {
+	var scheme string
+
+	switch {
+	case strings.HasPrefix(loc, "ws://"):
+		scheme = "http"
+	case strings.HasPrefix(loc, "wss://"):
+		scheme = "https"
+	default:
+		return loc, false
+	}
+
+	rewritten, ok := mod.rewriteURL(scheme + loc[strings.Index(loc, ":"):])
+	if !ok {
+		return loc, false
+	}
+
+	return "ws" + strings.TrimPrefix(rewritten, "http"), true
+}
+
+// websockets wraps the WebSocket API of the k6/ws (connect function) or k6/experimental/websockets
+// (WebSocket class) module, so the URLs of the mocked targets are rewritten to the mock servers.
+func (mod *Module) websockets(module *sobek.Object) *sobek.Object {
+	runtime := mod.runtime()
+
+	rewrite := func(args []sobek.Value) {
+		if len(args) == 0 {
+			return
+		}
+
+		if loc, ok := mod.rewriteWS(args[0].String()); ok {
+			args[0] = runtime.ToValue(loc)
+		}
+	}
+
+	if connect, ok := sobek.AssertFunction(module.Get("connect")); ok {
+		wrapper := func(call sobek.FunctionCall) sobek.Value {
+			rewrite(call.Arguments)
+
+			result, err := connect(call.This, call.Arguments...)
+			if err != nil {
+				mod.throw(err)
+			}
+
+			return result
+		}
+
+		if err := module.Set("connect", wrapper); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	if ctor := module.Get("WebSocket"); ctor != nil && !sobek.IsUndefined(ctor) {
+		wrapper := func(call sobek.ConstructorCall) *sobek.Object {
+			rewrite(call.Arguments)
+
+			obj, err := runtime.New(ctor, call.Arguments...)
+			if err != nil {
+				mod.throw(err)
+			}
+
+			return obj
+		}
+
+		if err := module.Set("WebSocket", wrapper); err != nil {
+			mod.throw(err)
+		}
+	}
+
+	return module
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2023 Iván Szkiba
+//
+// SPDX-License-Identifier: MIT
+
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maskWSFrame returns a masked client frame.
+func maskWSFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := appendWSFrame(nil, opcode, payload)
+	start := len(frame) - len(payload)
+
+	frame[1] |= 0x80
+
+	masked := append(append(append([]byte{}, frame[:start]...), mask...), frame[start:]...)
+
+	for idx := range payload {
+		masked[start+4+idx] ^= mask[idx%4]
+	}
+
+	return masked
+}
+
+func TestWSFrame(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", wsAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+
+	for _, size := range []int{0, 125, 126, 0xffff, 0x10000} {
+		payload := bytes.Repeat([]byte{'x'}, size)
+
+		fin, opcode, data, err := readWSFrame(bytes.NewReader(maskWSFrame(wsBinary, payload)))
+
+		require.NoError(t, err)
+		assert.True(t, fin)
+		assert.Equal(t, byte(wsBinary), opcode)
+		assert.Equal(t, payload, data)
+	}
+
+	head := binary.BigEndian.AppendUint64([]byte{0x82, 127}, wsMaxPayload+1)
+
+	_, _, _, err := readWSFrame(bytes.NewReader(head))
+
+	assert.ErrorIs(t, err, errFrameTooLarge)
+}
+
+func TestWSServer(t *testing.T) {
+	t.Parallel()
+
+	helper := newHelper(t)
+
+	_, err := helper.vu.Runtime().RunString(`
+const app = mock("https://chat.example.com", app => {
+	app.ws("/rooms/:room", conn => {
+		conn.send("welcome to " + conn.params.room)
+		conn.on("message", msg => msg == "bye" ? conn.close() : conn.send(msg.toUpperCase()))
+	})
+}, { sync: true })
+`)
+
+	require.NoError(t, err)
+
+	addr := strings.TrimPrefix(helper.module.lookup["https://chat.example.com"], "http://")
+
+	conn, err := net.Dial("tcp", addr)
+
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint:errcheck
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(time.Second)))
+
+	_, err = conn.Write([]byte("GET /rooms/lobby HTTP/1.1\r\nHost: chat.example.com\r\nUpgrade: websocket\r\n" +
+		"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+
+	res, err := http.ReadResponse(reader, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-WebSocket-Accept"))
+
+	expect := func(opcode byte, payload string) {
+		t.Helper()
+
+		_, op, data, err := readWSFrame(reader)
+
+		require.NoError(t, err)
+		assert.Equal(t, opcode, op)
+		assert.Equal(t, payload, string(data))
+	}
+
+	expect(wsText, "welcome to lobby")
+
+	_, err = conn.Write(maskWSFrame(wsText, []byte("hello")))
+
+	require.NoError(t, err)
+	expect(wsText, "HELLO")
+
+	_, err = conn.Write(maskWSFrame(wsText, []byte("bye")))
+
+	require.NoError(t, err)
+	expect(wsClose, "\x03\xe8")
+}